@@ -304,6 +304,28 @@ def get_episode_name(show_id, season_number, episode_number, max_length=60, forc
         log_message(f"TMDB episode fetch failed - Network error: {e}", level="ERROR")
         return None, None, None
 
+@lru_cache(maxsize=128)
+def get_season_episode_counts(show_id):
+    """
+    Return ((season_number, episode_count), ...) for a show, as used to map
+    anime absolute episode numbers. Returns an empty tuple on failure.
+    """
+    api_key = get_api_key()
+    if not api_key:
+        log_message("TMDb API key not found in environment variables.", level="ERROR")
+        return ()
+
+    try:
+        url = f"https://api.themoviedb.org/3/tv/{show_id}"
+        response = session.get(url, params={'api_key': api_key}, timeout=10)
+        response.raise_for_status()
+        seasons = response.json().get('seasons', [])
+    except requests.exceptions.RequestException as e:
+        log_message(f"TMDB season fetch failed for show ID {show_id} - Network error: {e}", level="ERROR")
+        return ()
+
+    return tuple((season.get('season_number', 0), season.get('episode_count', 0)) for season in seasons)
+
 def map_absolute_episode(show_id, absolute_episode, api_key, max_length=60):
     """
     Maps an absolute episode number to season and episode using AniDB-style mapping.
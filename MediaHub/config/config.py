@@ -152,9 +152,185 @@ def is_rclone_mount_enabled():
 def is_mount_check_interval():
     return get_env_int('MOUNT_CHECK_INTERVAL', 30)
 
+def get_path_mappings():
+    """Get symlink target path mappings from CINESYNC_PATH_MAP.
+
+    Format: from=to pairs separated by semicolons, e.g. /mnt/media=/data;/mnt/zurg=/zurg
+    Returns a list of (from_prefix, to_prefix) tuples, longest prefix first.
+    """
+    raw = os.getenv('CINESYNC_PATH_MAP', '').strip()
+    mappings = []
+    for pair in raw.split(';'):
+        if '=' not in pair:
+            continue
+        source, target = pair.split('=', 1)
+        source, target = source.strip().rstrip('/\\'), target.strip().rstrip('/\\')
+        if source and target:
+            mappings.append((source, target))
+    mappings.sort(key=lambda m: len(m[0]), reverse=True)
+    return mappings
+
+def is_companion_files_enabled():
+    return os.getenv('SYMLINK_COMPANION_FILES', 'false').lower() == 'true'
+
+# Every supported sidecar type, linked when nothing narrows it down
+DEFAULT_COMPANION_EXTENSIONS = '.srt,.ass,.ssa,.sub,.idx,.vtt,.sup,.nfo,.jpg,.jpeg,.png,.tbn'
+
+def get_companion_extensions(file_path=None):
+    """Get extensions of sidecar files linked alongside a media file.
+
+    COMPANION_EXTENSIONS wins when set; otherwise the layout profile of the
+    file's library decides, falling back to every supported sidecar type.
+    """
+    value = os.getenv('COMPANION_EXTENSIONS', '').strip()
+    if not value:
+        from MediaHub.utils.layout_profiles import get_profile_companion_extensions
+        value = get_profile_companion_extensions(get_layout_profile(file_path)) or DEFAULT_COMPANION_EXTENSIONS
+    return {ext.strip().lower() if ext.strip().startswith('.') else '.' + ext.strip().lower()
+            for ext in value.split(',') if ext.strip()}
+
+def _get_library_pairs(name):
+    """Get per-library settings from a variable of source=value pairs separated by semicolons.
+
+    Returns a list of (source_prefix, value) tuples, longest prefix first.
+    """
+    raw = os.getenv(name, '').strip()
+    pairs = []
+    for pair in raw.split(';'):
+        if '=' not in pair:
+            continue
+        source, value = pair.split('=', 1)
+        source, value = os.path.normpath(source.strip()), value.strip().lower()
+        if source and value:
+            pairs.append((source, value))
+    pairs.sort(key=lambda p: len(p[0]), reverse=True)
+    return pairs
+
+def _get_library_setting(name, file_path):
+    """Get the value a per-library variable sets for the library of a source file, None when it sets none"""
+    if file_path:
+        file_path = os.path.normpath(file_path)
+        for source, value in _get_library_pairs(name):
+            if file_path == source or file_path.startswith(source + os.sep):
+                return value
+    return None
+
+def get_library_layout_profiles():
+    """Get per-library destination layout profiles from LIBRARY_LAYOUT_PROFILES.
+
+    Format: source=profile pairs separated by semicolons, e.g. /mnt/movies=plex;/mnt/anime=jellyfin
+    Returns a list of (source_prefix, profile) tuples, longest prefix first.
+    """
+    return _get_library_pairs('LIBRARY_LAYOUT_PROFILES')
+
+def get_layout_profile(file_path=None):
+    """Get the destination layout profile (plex, jellyfin, emby, kodi or custom) for a source file"""
+    profile = _get_library_setting('LIBRARY_LAYOUT_PROFILES', file_path)
+    if profile:
+        return profile
+    return os.getenv('LAYOUT_PROFILE', 'custom').strip().lower() or 'custom'
+
+def get_extras_handling(file_path=None):
+    """Get how extras named by media server conventions are handled for a source file.
+
+    link routes them into the extras folders of their title, skip leaves them
+    out and size (the default) lets the extras size limits decide.
+    LIBRARY_EXTRAS_HANDLING picks a mode per library, e.g. /mnt/movies=link;/mnt/anime=skip
+    """
+    mode = _get_library_setting('LIBRARY_EXTRAS_HANDLING', file_path) or os.getenv('EXTRAS_HANDLING', 'size').strip().lower()
+    if mode not in ('link', 'skip', 'size'):
+        log_message(f"Unknown extras handling '{mode}', expected link, skip or size", level="WARNING")
+        return 'size'
+    return mode
+
+def get_library_link_mode(file_path=None):
+    """Get how a source file's library is linked: symlink (the default) or strm.
+
+    CINESYNC_LIBRARY_LINK_MODES picks a mode per library, e.g. /mnt/remote=strm
+    """
+    mode = _get_library_setting('CINESYNC_LIBRARY_LINK_MODES', file_path) or 'symlink'
+    if mode not in ('symlink', 'strm'):
+        log_message(f"Unknown link mode '{mode}', expected symlink or strm", level="WARNING")
+        return 'symlink'
+    return mode
+
+def get_specials_handling(file_path=None):
+    """Get whether season 0 specials of a source file's library are linked (link) or skipped (skip).
+
+    LIBRARY_SPECIALS_HANDLING picks a mode per library, e.g. /mnt/anime=skip
+    """
+    mode = _get_library_setting('LIBRARY_SPECIALS_HANDLING', file_path) or os.getenv('SPECIALS_HANDLING', 'link').strip().lower()
+    if mode not in ('link', 'skip'):
+        log_message(f"Unknown specials handling '{mode}', expected link or skip", level="WARNING")
+        return 'link'
+    return mode
+
+_ROUTE_CONDITION_PATTERN = re.compile(r'^(resolution|size|library|path)\s*(>=|<=|!=|=|>|<)\s*(.+)$', re.IGNORECASE)
+
+def get_destination_routes():
+    """Get destination routing rules from DESTINATION_ROUTES.
+
+    Format: rules separated by semicolons, each "conditions => destination" with
+    conditions joined by &, e.g. resolution>=2160 => /mnt/ssd;size>=40GB & path=*REMUX* => /mnt/ssd
+    Returns a list of (conditions, destination) tuples in the configured order,
+    where conditions is a list of (field, operator, value) tuples.
+    """
+    raw = os.getenv('DESTINATION_ROUTES', '').strip()
+    routes = []
+    for rule in raw.split(';'):
+        if not rule.strip():
+            continue
+        if '=>' not in rule:
+            log_message(f"Ignoring destination route without '=>': {rule.strip()}", level="WARNING")
+            continue
+        condition_text, destination = rule.rsplit('=>', 1)
+        destination = destination.strip()
+
+        conditions = []
+        for condition in condition_text.split('&'):
+            match = _ROUTE_CONDITION_PATTERN.match(condition.strip())
+            if not match:
+                conditions = None
+                break
+            conditions.append((match.group(1).lower(), match.group(2), match.group(3).strip()))
+
+        if not conditions or not destination:
+            log_message(f"Ignoring invalid destination route: {rule.strip()}", level="WARNING")
+            continue
+        routes.append((conditions, os.path.normpath(destination)))
+    return routes
+
+def get_partial_file_patterns():
+    """Get glob patterns of files that download clients are still writing"""
+    default = '*.part,*.partial,*.!qB,*.crdownload,*.tmp'
+    value = os.getenv('PARTIAL_FILE_PATTERNS', default) or default
+    return [pattern.strip().lower() for pattern in value.split(',') if pattern.strip()]
+
+def get_file_stabilization_seconds():
+    """Seconds a new file must go unmodified before it is processed, 0 disables the wait"""
+    return max(0, get_env_int('FILE_STABILIZATION_SECONDS', 30))
+
+def is_path_map_validation_enabled():
+    return os.getenv('CINESYNC_PATH_MAP_VALIDATE', 'false').lower() == 'true'
+
 def is_anime_scan():
     return os.getenv('ANIME_SCAN', 'false').lower() == 'true'
 
+def get_absolute_numbering_dirs():
+    """Get source directories whose anime is numbered absolutely (e.g. "Title - 125")"""
+    value = os.getenv('ANIME_ABSOLUTE_NUMBERING_DIRS', '').strip()
+    return [os.path.normpath(path.strip()) for path in value.split(',') if path.strip()]
+
+def is_absolute_numbering_enabled(file_path):
+    """Check whether a file lives in a library that uses anime absolute numbering"""
+    if not file_path:
+        return False
+    file_path = os.path.normpath(file_path)
+    for directory in get_absolute_numbering_dirs():
+        if directory == '*' or file_path == directory or file_path.startswith(directory + os.sep):
+            return True
+    return False
+
 def is_cinesync_layout_enabled():
     return os.getenv('CINESYNC_LAYOUT', 'false').lower() == 'true'
 
@@ -223,6 +399,14 @@ def get_sonarr_season_folder_format():
     return os.getenv('MEDIAINFO_SONARR_SEASON_FOLDER_FORMAT',
                     'Season{season}')
 
+def get_collision_suffix_format():
+    """Get the suffix added to a destination name that is already taken by another source.
+    "{n}" is replaced with the version number, starting at 2."""
+    value = os.getenv('COLLISION_SUFFIX_FORMAT', '').strip()
+    if '{n}' not in value:
+        return '[Version {n}]'
+    return value
+
 def get_rename_tags():
     """Get rename tags from environment variable and properly clean them"""
     tags_env = os.getenv('RENAME_TAGS', '')
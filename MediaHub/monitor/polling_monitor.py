@@ -19,6 +19,7 @@ from MediaHub.processors.symlink_utils import delete_broken_symlinks, delete_bro
 from MediaHub.utils.logging_utils import log_message
 from MediaHub.utils.global_events import terminate_flag, error_event, shutdown_event, set_shutdown, is_shutdown_requested
 from MediaHub.utils.webdav_api import send_source_file_update
+from MediaHub.utils.file_utils import is_partial_download
 import requests
 
 # Load .env file from the parent directory
@@ -31,6 +32,9 @@ else:
 # Add state variables for mount status tracking
 mount_state = None
 
+# Last seen (size, mtime) of new entries deferred while they are still being written
+pending_stability = {}
+
 def signal_handler(signum, frame):
     """Handle shutdown signals gracefully"""
     log_message(f"Received signal {signum}, shutting down polling monitor...", level="INFO")
@@ -205,16 +209,79 @@ def scan_directories(dirs_to_watch, current_files, last_mod_times=None):
 
     return new_files, modified_dirs, last_mod_times
 
+def get_entry_signature(path):
+    """
+    Returns (size, mtime, has_partial) for a file, or for a directory the total
+    size and newest mtime of the files below it and whether any is a partial download.
+    """
+    if not os.path.isdir(path):
+        stat = os.stat(path)
+        return stat.st_size, stat.st_mtime, is_partial_download(os.path.basename(path))
+
+    total_size = 0
+    newest_mtime = os.stat(path).st_mtime
+    has_partial = False
+    for root, _, files in os.walk(path):
+        for file in files:
+            if is_partial_download(file):
+                has_partial = True
+            try:
+                stat = os.stat(os.path.join(root, file))
+            except OSError:
+                continue
+            total_size += stat.st_size
+            newest_mtime = max(newest_mtime, stat.st_mtime)
+    return total_size, newest_mtime, has_partial
+
+def is_entry_stable(path, stabilization_seconds, now=None):
+    """
+    Checks whether a new file or folder finished being written. It is stable once it
+    holds no partial downloads, its size and mtime match the previous check and it was
+    not modified for stabilization_seconds.
+    """
+    if stabilization_seconds <= 0:
+        pending_stability.pop(path, None)
+        return True
+
+    try:
+        size, mtime, has_partial = get_entry_signature(path)
+    except OSError:
+        # Vanished before it settled, the next scan reports it as removed or not at all
+        pending_stability.pop(path, None)
+        return False
+
+    now = now if now is not None else time.time()
+    previous = pending_stability.get(path)
+    pending_stability[path] = (size, mtime)
+
+    if has_partial or now - mtime < stabilization_seconds:
+        return False
+    if previous is not None and previous != (size, mtime):
+        return False
+
+    pending_stability.pop(path, None)
+    return True
+
 def process_changes(current_files, new_files, dest_dir, modified_dirs=None, max_processes=None, db_max_workers=None, db_batch_size=None):
     """
     Updated process_changes to create symlinks for added files using parallel processing.
     Uses database configurations for optimal performance.
+    Returns the added entries deferred because they are still being written, by directory,
+    and the modified directories holding files that are still being written.
     """
     src_dirs, _ = get_directories()
     # Use passed configuration values or fall back to function calls
     max_processes = max_processes or get_max_processes()
     db_max_workers = db_max_workers or get_db_max_workers()
     max_workers = min(max_processes, db_max_workers)
+    stabilization_seconds = get_file_stabilization_seconds()
+    deferred_files = {}
+    deferred_dirs = set()
+
+    # Forget deferred entries that were removed before they settled
+    for path in list(pending_stability):
+        if not os.path.exists(path):
+            pending_stability.pop(path, None)
 
     # Process modified directories with parallel processing
     if modified_dirs:
@@ -222,15 +289,16 @@ def process_changes(current_files, new_files, dest_dir, modified_dirs=None, max_
             mod_dir_tasks = []
 
             for mod_dir, mod_details in modified_dirs.items():
-                task = executor.submit(_process_modified_directory, mod_dir, mod_details, src_dirs, dest_dir, db_batch_size)
-                mod_dir_tasks.append(task)
+                task = executor.submit(_process_modified_directory, mod_dir, mod_details, src_dirs, dest_dir, db_batch_size, stabilization_seconds)
+                mod_dir_tasks.append((mod_dir, task))
 
-            for task in as_completed(mod_dir_tasks):
+            for mod_dir, task in mod_dir_tasks:
                 if is_shutdown_requested():
                     log_message("Shutdown requested during modified directory processing. Stopping tasks.", level="WARNING")
                     break
                 try:
-                    task.result()
+                    if task.result():
+                        deferred_dirs.add(mod_dir)
                 except Exception as e:
                     log_message(f"Error in modified directory task: {str(e)}", level="ERROR")
                     set_shutdown()
@@ -245,6 +313,13 @@ def process_changes(current_files, new_files, dest_dir, modified_dirs=None, max_
             added_files = files - old_files
             removed_files = old_files - files
 
+            unstable_files = {file for file in added_files
+                              if not is_entry_stable(os.path.join(directory, file), stabilization_seconds)}
+            if unstable_files:
+                log_message(f"Waiting for {len(unstable_files)} files in {directory} to finish writing: {unstable_files}", level="INFO")
+                deferred_files[directory] = unstable_files
+                added_files = added_files - unstable_files
+
             if added_files:
                 log_message(f"New files detected in {directory}: {added_files}", level="INFO")
 
@@ -300,8 +375,13 @@ def process_changes(current_files, new_files, dest_dir, modified_dirs=None, max_
                 log_message(f"Error in removal processing task: {str(e)}", level="ERROR")
                 error_event.set()
 
-def _process_modified_directory(mod_dir, mod_details, src_dirs, dest_dir, db_batch_size=None):
-    """Helper function to process a single modified directory."""
+    return deferred_files, deferred_dirs
+
+def _process_modified_directory(mod_dir, mod_details, src_dirs, dest_dir, db_batch_size=None, stabilization_seconds=0):
+    """
+    Helper function to process a single modified directory.
+    Returns the added files that were deferred because they are still being written.
+    """
     try:
         current_dir_files = set(os.listdir(mod_dir))
         db_results = search_database_silent(mod_dir)
@@ -311,6 +391,12 @@ def _process_modified_directory(mod_dir, mod_details, src_dirs, dest_dir, db_bat
         added_files = current_dir_files - db_file_names
         removed_files = db_file_names - current_dir_files
 
+        unstable_files = {file for file in added_files
+                          if not is_entry_stable(os.path.join(mod_dir, file), stabilization_seconds)}
+        if unstable_files:
+            log_message(f"Waiting for {len(unstable_files)} files in {mod_dir} to finish writing: {unstable_files}", level="INFO")
+            added_files = added_files - unstable_files
+
         # Process added files
         if added_files:
             for added_file in added_files:
@@ -356,6 +442,8 @@ def _process_modified_directory(mod_dir, mod_details, src_dirs, dest_dir, db_bat
             log_message(f"Processing {len(removed_file_paths)} removed files from modified directory using batch processing", level="INFO")
             delete_broken_symlinks_batch(dest_dir, removed_file_paths)
 
+        return unstable_files
+
     except Exception as e:
         log_message(f"Error processing modified directory {mod_dir}: {str(e)}", level="ERROR")
         raise
@@ -501,8 +589,13 @@ def main():
 
             # Process changes with parallel processing using loaded configuration
             if not is_shutdown_requested():
-                process_changes(current_files, new_files, dest_dir, modified_dirs,
+                deferred_files, deferred_dirs = process_changes(current_files, new_files, dest_dir, modified_dirs,
                               max_processes, db_max_workers, db_batch_size)
+                # Leave deferred entries out so the next scan reports them as new again
+                for directory, files in deferred_files.items():
+                    new_files[directory] = new_files[directory] - files
+                for mod_dir in deferred_dirs:
+                    last_mod_times[mod_dir] = modified_dirs[mod_dir]['old_mod_time']
                 current_files = new_files
             else:
                 log_message("Shutdown requested, stopping file processing", level="INFO")
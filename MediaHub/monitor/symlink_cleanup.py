@@ -64,6 +64,7 @@ def run_symlink_cleanup(dest_dir):
                             log_message(f"Symlink not found in database, deleting directly: {file_path}", level="DEBUG")
                             try:
                                 os.remove(file_path)
+                                remove_companion_links(file_path)
                                 log_message(f"Manually deleted broken symlink: {file_path}", level="INFO")
                             except Exception as rm_error:
                                 log_message(f"Error removing symlink: {str(rm_error)}", level="ERROR")
@@ -7,9 +7,10 @@ from MediaHub.utils.file_utils import fetch_json, extract_resolution_from_filena
 from MediaHub.api.tmdb_api import search_tv_show, determine_tmdb_media_type
 from MediaHub.config.config import *
 from MediaHub.utils.mediainfo import *
-from MediaHub.api.tmdb_api_helpers import get_episode_name
+from MediaHub.api.tmdb_api_helpers import get_episode_name, get_season_episode_counts
 from MediaHub.processors.db_utils import track_file_failure
 from MediaHub.utils.file_utils import clean_query
+from MediaHub.utils.parser.parse_absolute import parse_absolute_episode, resolve_absolute_episodes
 
 def is_anime_file_legacy(filename):
     """
@@ -266,6 +267,20 @@ def process_anime_show(src_file, root, file, dest_dir, actual_dir, tmdb_folder_i
         else:
             actual_episode = episode_number
 
+    # Libraries using absolute numbering get their season/episode from the provider's season sizes
+    absolute = parse_absolute_episode(file) if is_absolute_numbering_enabled(src_file) else None
+    if absolute and show_id:
+        resolved = resolve_absolute_episodes(absolute, get_season_episode_counts(show_id))
+        if resolved:
+            season_number = str(resolved[0][0]).zfill(2)
+            actual_episode = str(resolved[0][1]).zfill(2)
+            mapped = ", ".join(f"S{season:02d}E{episode:02d}" for season, episode in resolved)
+            log_message(f"Absolute episode {absolute} maps to {mapped}", level="DEBUG")
+            if resolved[-1][0] != resolved[0][0]:
+                log_message(f"Absolute episodes {absolute} span seasons, filing the file under season {season_number}", level="WARNING")
+        else:
+            log_message(f"Absolute episode {absolute} is beyond the known episodes for show ID {show_id}", level="WARNING")
+
     if rename_enabled and show_id:
         try:
             try:
@@ -354,5 +369,6 @@ def process_anime_show(src_file, root, file, dest_dir, actual_dir, tmdb_folder_i
         'imdb_id': imdb_id,
         'tvdb_id': tvdb_id,
         'language': language,
-        'quality': quality
+        'quality': quality,
+        'absolute_episode': absolute.start if absolute else None
     }
@@ -19,9 +19,10 @@ from MediaHub.config.config import (
     get_db_throttle_rate, get_db_max_retries, get_db_retry_delay,
     get_db_batch_size, get_db_max_workers, get_db_max_records,
     get_db_connection_timeout, get_db_cache_size,
-    get_cinesync_ip, get_cinesync_api_port
+    get_cinesync_ip, get_cinesync_api_port, is_absolute_numbering_enabled
 )
 from MediaHub.api.tmdb_api_helpers import get_movie_data, get_show_data
+from MediaHub.utils.destination_routing import find_destination_root
 
 def format_file_size(size):
     """Format file size in human readable format"""
@@ -32,7 +33,7 @@ def format_file_size(size):
             return f"{size:.1f} {unit}"
         size /= 1024.0
     return f"{size:.1f} PB"
-from MediaHub.utils.dashboard_utils import send_dashboard_notification
+from MediaHub.utils.dashboard_utils import send_dashboard_notification, get_operation_batch_id
 
 # Load environment variables
 dotenv_path = find_dotenv('../.env')
@@ -233,7 +234,10 @@ def initialize_db(conn):
             "sport_location": "TEXT",
             "sport_session": "TEXT",
             "sport_venue": "TEXT",
-            "sport_date": "TEXT"
+            "sport_date": "TEXT",
+            "episode_numbers": "TEXT",
+            "absolute_episode": "TEXT",
+            "destination_root": "TEXT"
         }
 
         # Add missing columns
@@ -562,6 +566,22 @@ def save_processed_file(conn, source_path, dest_path=None, tmdb_id=None, season_
                 VALUES (?, ?, ?, ?, ?, ?)
             """, (source_path, dest_path, tmdb_id, season_number, reason, file_size))
 
+        # Multi-episode files record every episode they cover alongside the first one
+        if "episode_numbers" in columns:
+            cursor.execute("UPDATE processed_files SET episode_numbers = ? WHERE file_path = ?",
+                           (get_episode_numbers(source_path, episode_number, tmdb_id, season_number), source_path))
+
+        # Anime libraries with absolute numbering keep the original absolute episode
+        if "absolute_episode" in columns:
+            absolute = get_absolute_episode(source_path)
+            cursor.execute("UPDATE processed_files SET absolute_episode = ? WHERE file_path = ?",
+                           (str(absolute) if absolute else None, source_path))
+
+        # Record which destination root DESTINATION_ROUTES sent the file to
+        if "destination_root" in columns:
+            cursor.execute("UPDATE processed_files SET destination_root = ? WHERE file_path = ?",
+                           (find_destination_root(dest_path), source_path))
+
         conn.commit()
 
         # Notify WebDavHub about the file addition if it's a new file and not skipped
@@ -572,6 +592,53 @@ def save_processed_file(conn, source_path, dest_path=None, tmdb_id=None, season_
         log_message(f"Error in save_processed_file: {e}", level="ERROR")
         conn.rollback()
 
+def get_episode_numbers(source_path, episode_number, tmdb_id=None, season_number=None):
+    """
+    Return the comma separated episodes a multi-episode file covers, or None.
+
+    The span is parsed from the source filename and only kept when it starts
+    with the episode the file was matched to, so remapped numbering such as
+    anime absolute episodes is never contradicted. Absolute spans are resolved
+    through the show's season sizes and keep the episodes of the file's season.
+    """
+    if not source_path or episode_number in (None, ""):
+        return None
+    try:
+        episode = int(episode_number)
+    except (TypeError, ValueError):
+        return None
+
+    absolute = get_absolute_episode(source_path)
+    if absolute:
+        if absolute.end == absolute.start:
+            return None
+        resolved = None
+        if tmdb_id:
+            from MediaHub.api.tmdb_api_helpers import get_season_episode_counts
+            from MediaHub.utils.parser.parse_absolute import resolve_absolute_episodes
+            resolved = resolve_absolute_episodes(absolute, get_season_episode_counts(tmdb_id))
+        if not resolved:
+            # Without season sizes the span is taken to stay in one season
+            return ",".join(str(episode + offset) for offset in range(absolute.end - absolute.start + 1))
+        season = int(season_number) if str(season_number or "").isdigit() else resolved[0][0]
+        episodes = [number for resolved_season, number in resolved if resolved_season == season]
+        if len(episodes) < 2 or episodes[0] != episode:
+            return None
+        return ",".join(str(number) for number in episodes)
+
+    from MediaHub.utils.parser.extractor import extract_all_metadata
+    episodes = extract_all_metadata(os.path.basename(source_path)).episodes
+    if len(episodes) < 2 or episodes[0] != episode:
+        return None
+    return ",".join(str(n) for n in episodes)
+
+def get_absolute_episode(source_path):
+    """Return the absolute episode parsed from a file in an absolute numbering library, or None"""
+    if not source_path or not is_absolute_numbering_enabled(source_path):
+        return None
+    from MediaHub.utils.parser.parse_absolute import parse_absolute_episode
+    return parse_absolute_episode(os.path.basename(source_path))
+
 @throttle
 @retry_on_db_lock
 @with_connection(main_pool)
@@ -833,6 +900,26 @@ def get_destination_path(conn, source_path):
         conn.rollback()
         return None
 
+@throttle
+@retry_on_db_lock
+@with_connection(main_pool)
+def get_processed_files_in_dir(conn, source_dir):
+    """Get the linked files below a source directory with the title metadata they were linked with"""
+    source_dir = normalize_file_path(source_dir).rstrip(os.sep)
+    try:
+        cursor = conn.cursor()
+        cursor.execute("""
+            SELECT file_path, destination_path, tmdb_id, season_number, media_type, proper_name, year, imdb_id
+            FROM processed_files
+            WHERE file_path LIKE ? AND destination_path IS NOT NULL AND destination_path != ''
+        """, (source_dir + os.sep + '%',))
+        columns = ['file_path', 'destination_path', 'tmdb_id', 'season_number', 'media_type', 'proper_name', 'year', 'imdb_id']
+        return [dict(zip(columns, row)) for row in cursor.fetchall()]
+    except (sqlite3.Error, DatabaseError) as e:
+        log_message(f"Error in get_processed_files_in_dir: {e}", level="ERROR")
+        conn.rollback()
+        return []
+
 def _check_path_exists_batch(paths_batch):
     """Check existence of a batch of paths and build reverse index - used for parallel processing"""
     existing_paths = []
@@ -1218,6 +1305,7 @@ def track_file_addition(source_path, dest_path, tmdb_id=None, season_number=None
     try:
         payload = {
             'operation': 'add',
+            'batchId': get_operation_batch_id(),
             'sourcePath': source_path,
             'destinationPath': dest_path,
             'tmdbId': str(tmdb_id) if tmdb_id else '',
@@ -1315,6 +1403,7 @@ def track_file_deletion(source_path, dest_path, tmdb_id=None, season_number=None
 
         payload = {
             'operation': 'delete',
+            'batchId': get_operation_batch_id(),
             'sourcePath': source_path,
             'destinationPath': dest_path,
             'tmdbId': str(tmdb_id) if tmdb_id else '',
@@ -1513,6 +1602,7 @@ def track_force_recreation(source_path, new_dest_path, new_tmdb_id, new_season_n
     try:
         payload = {
             'operation': 'force_recreate',
+            'batchId': get_operation_batch_id(),
             'sourcePath': source_path,
             'destinationPath': new_dest_path,
             'tmdbId': str(new_tmdb_id) if new_tmdb_id else '',
@@ -1565,6 +1655,7 @@ def track_file_failure(source_path, tmdb_id=None, season_number=None, reason="",
     try:
         payload = {
             'operation': 'failed',
+            'batchId': get_operation_batch_id(),
             'sourcePath': source_path,
             'tmdbId': str(tmdb_id) if tmdb_id else '',
             'seasonNumber': str(season_number) if season_number else '',
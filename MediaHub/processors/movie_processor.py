@@ -12,6 +12,8 @@ from MediaHub.api.tmdb_api_helpers import get_movie_data
 from MediaHub.processors.symlink_utils import load_skip_patterns, should_skip_file
 from MediaHub.utils.meta_extraction_engine import get_ffprobe_media_info
 from MediaHub.processors.db_utils import track_file_failure
+from MediaHub.utils.parser.parse_edition import parse_edition, format_edition_tag
+from MediaHub.utils.parser.parse_part import find_stack_part, format_stack_suffix
 
 # Add the mediainfo directory to the path
 import sys
@@ -54,8 +56,16 @@ def process_movie(src_file, root, file, dest_dir, actual_dir, tmdb_folder_id_ena
         # Don't process, but let symlink_creator handle this as a skip, not a failure
         return "SKIP_EXTRA", None
 
+    # A movie split over several files (cd1/cd2) is matched without its part
+    # marker so every part lands under the same title
+    stack_part = None
+    stack = find_stack_part(src_file)
+    if stack:
+        stack_part, stacked_name = stack
+        log_message(f"Detected part {stack_part} of a multi-part movie: {file}", level="DEBUG")
+        movie_result = clean_query(stacked_name)
     # Use passed metadata if available, otherwise parse (avoid redundant parsing)
-    if file_metadata:
+    elif file_metadata:
         movie_result = file_metadata
     else:
         movie_result = clean_query(parent_folder_name)
@@ -458,9 +468,15 @@ def process_movie(src_file, root, file, dest_dir, actual_dir, tmdb_folder_id_ena
                 details_parts.append(combined_str)
 
             details_str = ' '.join(details_parts)
-            enhanced_movie_folder = f"{clean_movie_name} {details_str}".strip()
 
-        new_name = f"{enhanced_movie_folder}{os.path.splitext(file)[1]}"
+            # {edition} keeps different cuts of the same film on different names
+            edition_tag = ''
+            if any(tag.strip().strip('{}').strip().lower() == 'edition' for tag in tags_to_use):
+                edition_tag = format_edition_tag(parse_edition(file))
+
+            enhanced_movie_folder = ' '.join(part for part in (clean_movie_name, edition_tag, details_str) if part).strip()
+
+        new_name = f"{enhanced_movie_folder}{format_stack_suffix(stack_part)}{os.path.splitext(file)[1]}"
     else:
         new_name = file
 
@@ -141,6 +141,38 @@ def get_source_file_info(file_path: str) -> Optional[dict]:
         conn.close()
 
 
+def is_source_file_locked(file_path: str) -> bool:
+    """
+    Check whether a source file was locked against automatic processing in WebDavHub.
+
+    Args:
+        file_path: Path to the file
+
+    Returns:
+        True if the file is locked, False otherwise or when the lock cannot be read
+    """
+    if not os.path.exists(get_source_db_path()):
+        return False
+
+    conn = get_source_db_connection()
+    if not conn:
+        return False
+
+    try:
+        cursor = conn.cursor()
+        normalized_path = normalize_file_path(file_path)
+        cursor.execute(
+            "SELECT 1 FROM locked_files WHERE file_path = ? OR file_path = ?",
+            (normalized_path, file_path)
+        )
+        return cursor.fetchone() is not None
+    except sqlite3.Error:
+        # Databases created before locking have no locked_files table
+        return False
+    finally:
+        conn.close()
+
+
 def check_source_db_availability() -> bool:
     """
     Check if the source files database is available and accessible.
@@ -21,6 +21,11 @@ from threading import Event
 from MediaHub.processors.movie_processor import process_movie
 from MediaHub.processors.show_processor import process_show
 from MediaHub.utils.logging_utils import log_message
+from MediaHub.utils.path_mapping import map_symlink_target, read_symlink_target, symlink_target_exists
+from MediaHub.utils.layout_profiles import apply_layout_profile
+from MediaHub.utils.destination_routing import route_destination, rebase_destination
+from MediaHub.utils.extras import detect_extra, extras_destination, find_title_record
+from MediaHub.utils.strm_files import STRM_EXTENSION, uses_strm, strm_destination, strm_content, is_strm_file, read_strm, write_strm
 from MediaHub.utils.file_utils import build_dest_index, is_anime_file, should_skip_processing
 from MediaHub.monitor.symlink_cleanup import run_symlink_cleanup
 from MediaHub.utils.webdav_api import send_structured_message
@@ -37,6 +42,7 @@ from MediaHub.processors.db_utils import track_force_recreation
 from MediaHub.processors.source_files_db import *
 from MediaHub.processors.sports_processor import process_sports
 from MediaHub.utils.file_utils import parse_media_file
+from MediaHub.utils.parser.parse_edition import parse_edition
 from MediaHub.processors.sports_processor import is_sports_file
 
 log_imported_db = False
@@ -451,6 +457,7 @@ def _cleanup_old_symlink(old_symlink_info, new_dest_file=None):
             if os.path.exists(old_symlink_info['path']):
                 os.remove(old_symlink_info['path'])
                 log_message(f"Force mode: Removed old symlink at {old_symlink_info['path']}", level="INFO")
+            remove_companion_links(old_symlink_info['path'])
 
             # Delete if parent directory is empty
             if os.path.exists(old_symlink_info['parent_dir']) and not os.listdir(old_symlink_info['parent_dir']):
@@ -473,6 +480,68 @@ first_selection_cache = {
     'is_cached': False
 }
 
+def _create_link(src_file, dest_file):
+    """Link src_file at dest_file, writing a .strm file for .strm destinations, and return what the link points to"""
+    if dest_file.lower().endswith(STRM_EXTENSION):
+        link_target = strm_content(src_file)
+        write_strm(dest_file, link_target)
+    else:
+        link_target = map_symlink_target(src_file)
+        os.symlink(link_target, dest_file)
+    return link_target
+
+def _links_to(path, src_file, normalized_src_file):
+    """Check whether the symlink or .strm file at path links src_file"""
+    if is_strm_file(path):
+        return read_strm(path) == strm_content(src_file)
+    return normalize_file_path(read_symlink_target(path)) == normalized_src_file
+
+def _link_extra(src_file, folder, title):
+    """Link an extra into the extras folder of the title it belongs to, with the title's metadata"""
+    dest_file = extras_destination(title['destination_path'], folder, src_file)
+    if uses_strm(src_file):
+        dest_file = strm_destination(dest_file)
+    if os.path.lexists(dest_file):
+        log_message(f"Extra already linked: {dest_file}", level="DEBUG")
+        save_processed_file(src_file, dest_file, title['tmdb_id'], title['season_number'], None, None, None,
+                          title['media_type'], title['proper_name'], title['year'], None, title['imdb_id'])
+        return (dest_file, True, src_file)
+
+    try:
+        os.makedirs(os.path.dirname(dest_file), exist_ok=True)
+        link_target = _create_link(src_file, dest_file)
+    except OSError as e:
+        log_message(f"Error creating symlink for extra {src_file}: {e}", level="ERROR")
+        track_file_failure(src_file, title['tmdb_id'], title['season_number'], "Symlink creation error", f"Error creating symlink: {e}")
+        return None
+
+    log_message(f"Created symlink for extra: {dest_file} -> {link_target}", level="INFO")
+    save_processed_file(src_file, dest_file, title['tmdb_id'], title['season_number'], None, None, None,
+                      title['media_type'], title['proper_name'], title['year'], None, title['imdb_id'])
+
+    try:
+        send_structured_message("symlink_created", {
+            "source_file": src_file,
+            "destination_file": dest_file,
+            "media_name": os.path.basename(os.path.dirname(os.path.dirname(dest_file))),
+            "filename": os.path.basename(dest_file),
+            "media_type": "movie" if title['media_type'] == 'Movie' else "tv",
+            "tmdb_id": title['tmdb_id'],
+            "season_number": None,
+            "episode_number": None,
+            "timestamp": time.time(),
+            "force_mode": False
+        })
+    except Exception as e:
+        log_message(f"Error sending symlink notification: {e}", level="DEBUG")
+
+    try:
+        track_file_addition(src_file, dest_file, title['tmdb_id'], None)
+    except Exception as e:
+        log_message(f"Error updating cache for new symlink: {e}", level="DEBUG")
+
+    return (dest_file, True, src_file)
+
 def reset_first_selection_cache():
     """Reset the first selection cache for a new batch."""
     global first_selection_cache
@@ -521,6 +590,11 @@ def process_file(args, force=False, batch_apply=False):
     # Normalize path
     src_file = normalize_file_path(src_file)
 
+    # Locked files keep their metadata, name and links until unlocked in WebDavHub
+    if is_source_file_locked(src_file):
+        log_message(f"Skipping locked file: {src_file}", level="INFO")
+        return
+
     # Handle skip flag
     if skip:
         force = True
@@ -557,6 +631,7 @@ def process_file(args, force=False, batch_apply=False):
 
                 # Remove the symlink
                 os.remove(existing_dest_path)
+                remove_companion_links(existing_dest_path)
                 log_message(f"Skip mode: Removed existing symlink for {file}", level="INFO")
 
                 # Cleanup MediaCover if TMDB ID exists
@@ -625,13 +700,13 @@ def process_file(args, force=False, batch_apply=False):
         log_message(f"File not found in database: {src_file}", level="DEBUG")
 
     if existing_dest_path and not force:
-        if not os.path.exists(existing_dest_path):
+        if not os.path.lexists(existing_dest_path) or not symlink_target_exists(existing_dest_path):
             dir_path = os.path.dirname(existing_dest_path)
             if os.path.exists(dir_path):
                 for filename in os.listdir(dir_path):
                     potential_new_path = os.path.join(dir_path, filename)
                     if os.path.islink(potential_new_path):
-                        link_target = normalize_file_path(os.readlink(potential_new_path))
+                        link_target = normalize_file_path(read_symlink_target(potential_new_path))
                         if link_target == src_file:
                             log_message(f"Detected renamed file: {existing_dest_path} -> {potential_new_path}", level="INFO")
                             update_renamed_file(existing_dest_path, potential_new_path)
@@ -664,6 +739,24 @@ def process_file(args, force=False, batch_apply=False):
         save_processed_file(src_file, existing_symlink, tmdb_id)
         return
 
+    # Extras named by media server conventions follow the extras handling of their library
+    extras_handling = get_extras_handling(src_file)
+    extra = detect_extra(src_file) if extras_handling != 'size' and not user_requested_force_extra else None
+    if extra:
+        extra_folder, title_dir = extra
+        if extras_handling == 'skip':
+            reason = "Extra skipped by library setting"
+            log_message(f"Skipping extra file: {file} ({reason})", level="INFO")
+            save_processed_file(src_file, None, tmdb_id, season_number, reason)
+            return
+
+        title = find_title_record(src_file, title_dir)
+        if not title:
+            # Not recorded, so the next scan links it once its title is linked
+            log_message(f"Title of extra {file} is not linked yet, leaving it for a later scan", level="INFO")
+            return
+        return _link_extra(src_file, extra_folder, title)
+
     # Show detection logic
     is_show = False
     is_anime_show = False
@@ -853,6 +946,14 @@ def process_file(args, force=False, batch_apply=False):
                                   media_type, proper_name, year, episode_number_str, imdb_id, is_anime_genre)
                 return
 
+            # Skip season 0 specials in libraries that leave them out
+            if not is_extra and str(season_number) in ('0', '00') and get_specials_handling(src_file) == 'skip':
+                reason = "Special skipped by library setting"
+                log_message(f"Skipping special: {file} ({reason})", level="INFO")
+                save_processed_file(src_file, None, tmdb_id, season_number, reason, None, None,
+                                  media_type, proper_name, year, episode_number_str, imdb_id, is_anime_genre)
+                return
+
             show_processed = True
         else:
             # Check for sports content before falling back to movie processing
@@ -935,6 +1036,12 @@ def process_file(args, force=False, batch_apply=False):
             _cleanup_old_symlink(old_symlink_info)
         return
 
+    destination_root = route_destination(src_file, dest_dir)
+    dest_file = rebase_destination(dest_file, dest_dir, destination_root)
+    dest_file = apply_layout_profile(dest_file, destination_root, src_file)
+    # Libraries in strm mode get a .strm file named like the symlink would be
+    if uses_strm(src_file):
+        dest_file = strm_destination(dest_file)
     os.makedirs(os.path.dirname(dest_file), exist_ok=True)
 
     # Comprehensive check for existing symlinks in the destination directory
@@ -945,11 +1052,10 @@ def process_file(args, force=False, batch_apply=False):
         log_message(f"Checking destination directory for existing symlinks: {dest_dir}", level="DEBUG")
         for filename in os.listdir(dest_dir):
             potential_symlink = os.path.join(dest_dir, filename)
-            if os.path.islink(potential_symlink):
+            if os.path.islink(potential_symlink) or is_strm_file(potential_symlink):
                 try:
-                    link_target = normalize_file_path(os.readlink(potential_symlink))
-                    log_message(f"Found symlink {potential_symlink} -> {link_target}", level="DEBUG")
-                    if link_target == normalized_src_file:
+                    log_message(f"Found link {potential_symlink}", level="DEBUG")
+                    if _links_to(potential_symlink, src_file, normalized_src_file):
                         existing_symlink_for_source = potential_symlink
                         log_message(f"Found existing symlink for source: {existing_symlink_for_source}", level="DEBUG")
                         break
@@ -972,6 +1078,7 @@ def process_file(args, force=False, batch_apply=False):
                 log_message(f"Renaming {existing_name}", level="INFO")
                 log_message(f"Updating existing symlink: {dest_file} -> {src_file} (was: {existing_symlink_for_source})", level="INFO")
                 os.remove(existing_symlink_for_source)
+                remove_companion_links(existing_symlink_for_source)
             else:
                 # If rename is not enabled, keep the existing symlink
                 # For sports content, check SportsDB event ID instead of TMDB ID
@@ -1002,11 +1109,13 @@ def process_file(args, force=False, batch_apply=False):
                 new_name = os.path.basename(dest_file)
                 log_message(f"Force mode with rename enabled: Found existing symlink for source with different name: {existing_name} -> {new_name}", level="INFO")
                 os.remove(existing_symlink_for_source)
+                remove_companion_links(existing_symlink_for_source)
             else:
                 existing_name = os.path.basename(existing_symlink_for_source)
                 new_name = os.path.basename(dest_file)
                 log_message(f"Force mode with rename disabled: Found existing symlink for source with different name: {existing_name} -> {new_name}", level="INFO")
                 os.remove(existing_symlink_for_source)
+                remove_companion_links(existing_symlink_for_source)
     elif existing_symlink_for_source == dest_file:
         # For sports content, check SportsDB event ID instead of TMDB ID
         if media_type == 'Sports':
@@ -1025,9 +1134,8 @@ def process_file(args, force=False, batch_apply=False):
             log_message(f"Symlink exists but metadata incomplete (fallback) - processing to extract metadata: {dest_file} -> {src_file}", level="INFO")
 
     # Check if symlink already exists at the exact destination path
-    if os.path.islink(dest_file):
-        existing_src = normalize_file_path(os.readlink(dest_file))
-        if existing_src == normalized_src_file:
+    if os.path.islink(dest_file) or is_strm_file(dest_file):
+        if _links_to(dest_file, src_file, normalized_src_file):
             # For sports content, check SportsDB event ID instead of TMDB ID
             if media_type == 'Sports':
                 has_complete_metadata = bool(tmdb_id and media_type and proper_name and year)  # tmdb_id contains sportsdb_event_id for sports
@@ -1056,22 +1164,26 @@ def process_file(args, force=False, batch_apply=False):
             # The version numbering will be handled later in the code
 
 
-    if os.path.exists(dest_file) and not os.path.islink(dest_file):
+    if os.path.exists(dest_file) and not os.path.islink(dest_file) and not is_strm_file(dest_file):
         log_message(f"File already exists at destination: {os.path.basename(dest_file)}", level="INFO")
         return
 
     # Check for filename conflicts and generate unique filename if needed
     original_dest_file = dest_file
-    dest_file = generate_unique_filename(dest_file, src_file)
+    edition = parse_edition(os.path.basename(src_file)) if media_type == 'Movie' else None
+    dest_file = generate_unique_filename(dest_file, src_file, edition)
     
     if dest_file != original_dest_file:
-        log_message(f"Filename conflict detected, using versioned name: {os.path.basename(dest_file)}", level="INFO")
+        log_message(f"Filename conflict detected, using disambiguated name: {os.path.basename(dest_file)}", level="INFO")
 
     # Create symlink
     try:
-        os.symlink(src_file, dest_file)
-        log_message(f"Created symlink: {dest_file} -> {src_file}", level="INFO")
+        link_target = _create_link(src_file, dest_file)
+        log_message(f"Created symlink: {dest_file} -> {link_target}", level="INFO")
         log_message(f"Processed file: {src_file} to {dest_file}", level="INFO")
+        # Media servers play a .strm file by itself, companions would sit next to nothing they read
+        if not is_strm_file(dest_file):
+            link_companion_files(src_file, dest_file)
 
         # Extract media information for structured message
         new_folder_name = os.path.basename(os.path.dirname(dest_file))
@@ -1,5 +1,6 @@
 import os
 import platform
+import re
 import sqlite3
 import subprocess
 import json
@@ -7,62 +8,61 @@ import sys
 import time
 from concurrent.futures import ThreadPoolExecutor, as_completed
 from MediaHub.utils.logging_utils import log_message
+from MediaHub.utils.path_mapping import map_symlink_target, read_symlink_target
 from MediaHub.config.config import *
 from MediaHub.processors.db_utils import *
 from MediaHub.processors.process_db import *
 from MediaHub.utils.webdav_api import send_structured_message, send_file_deletion
 from MediaHub.api.media_cover import cleanup_tmdb_covers
+from MediaHub.utils.parser.parse_edition import format_edition_tag
+from MediaHub.utils.parser.patterns import EDITION_TAG_PATTERN
 
-def generate_unique_filename(dest_file, src_file=None):
-	"""Generate a unique filename by adding version numbers if conflicts occur.
+def generate_unique_filename(dest_file, src_file=None, edition=None):
+	"""Generate a unique filename by adding an edition tag or version numbers if conflicts occur.
 	
 	Args:
 		dest_file: The intended destination file path
 		src_file: The source file path (optional, used to check if existing symlink points to same source)
+		edition: The edition of the source (optional, tried as "{edition-...}" before version numbering)
 	
 	Returns:
-		A unique file path, either the original or with version numbering
+		A unique file path, either the original or with an edition tag or version numbering
 	"""
-	if not os.path.exists(dest_file):
+	if not os.path.lexists(dest_file):
 		return dest_file
 	
 	# If the existing file is a symlink pointing to the same source, return original path
-	if src_file and os.path.islink(dest_file):
-		try:
-			existing_target = normalize_file_path(os.readlink(dest_file))
-			normalized_src = normalize_file_path(src_file)
-			if existing_target == normalized_src:
-				return dest_file
-		except (OSError, IOError):
-			pass
-	
-	# If the existing file is a symlink pointing to a different source, create versioned name
-	if os.path.islink(dest_file):
-		dir_path = os.path.dirname(dest_file)
-		base_name = os.path.basename(dest_file)
-		name, ext = os.path.splitext(base_name)
-		
-		counter = 2
-		while True:
-			versioned_name = f"{name} [Version {counter}]{ext}"
-			versioned_path = os.path.join(dir_path, versioned_name)
-			if not os.path.exists(versioned_path):
-				return versioned_path
-			counter += 1
+	if src_file and _links_to_source(dest_file, src_file):
+		return dest_file
 	
-	# For regular files, also create versioned name
 	dir_path = os.path.dirname(dest_file)
-	base_name = os.path.basename(dest_file)
-	name, ext = os.path.splitext(base_name)
+	name, ext = os.path.splitext(os.path.basename(dest_file))
 	
+	# Another cut of the same film takes its edition tag before falling back to a version number
+	edition_tag = format_edition_tag(edition)
+	if edition_tag and not EDITION_TAG_PATTERN.search(name):
+		edition_path = os.path.join(dir_path, f"{name} {edition_tag}{ext}")
+		if not os.path.lexists(edition_path) or (src_file and _links_to_source(edition_path, src_file)):
+			return edition_path
+	
+	suffix_format = get_collision_suffix_format()
 	counter = 2
 	while True:
-		versioned_name = f"{name} [Version {counter}]{ext}"
+		versioned_name = f"{name} {suffix_format.replace('{n}', str(counter))}{ext}"
 		versioned_path = os.path.join(dir_path, versioned_name)
-		if not os.path.exists(versioned_path):
+		if not os.path.lexists(versioned_path) or (src_file and _links_to_source(versioned_path, src_file)):
 			return versioned_path
 		counter += 1
 
+def _links_to_source(dest_file, src_file):
+	"""Check whether dest_file is a symlink to src_file."""
+	if not os.path.islink(dest_file):
+		return False
+	try:
+		return normalize_file_path(read_symlink_target(dest_file)) == normalize_file_path(src_file)
+	except (OSError, IOError):
+		return False
+
 def _move_symlink_to_trash(symlink_path):
 	"""Move a symlink to central db/trash (next to DB files), preserving link target."""
 	try:
@@ -84,10 +84,104 @@ def _move_symlink_to_trash(symlink_path):
 		log_message(f"Failed to move symlink to trash {symlink_path}: {e}", level="WARNING")
 		return False
 
+# Tags a companion may carry between the media file's name and its extension:
+# languages like en, eng or pt-BR and subtitle flags like forced or sdh
+_COMPANION_TAG = r'(?:[a-z]{2,3}(?:[-_][a-z0-9]{2,4})?|forced|sdh|cc|hi|default|foreign|full|signs|songs)'
+# Artwork named after the media file, as Kodi and Jellyfin read it
+_COMPANION_ARTWORK = r'(?:poster|fanart|banner|thumb|landscape|clearlogo|clearart|discart|logo|keyart)'
+
+def _companion_suffix_pattern(base_name):
+	"""Match a companion name of base_name and capture its suffix, e.g. ".en.forced.srt"."""
+	return re.compile(
+		re.escape(base_name) + rf'((?:\.{_COMPANION_TAG})*\.[^.]+|-{_COMPANION_ARTWORK}\.[^.]+)$',
+		re.IGNORECASE)
+
+def _companion_entries(directory, base_name, extensions):
+	"""Yield (path, suffix) for the entries of directory that are companions of base_name."""
+	pattern = _companion_suffix_pattern(base_name)
+	try:
+		entries = os.listdir(directory)
+	except OSError:
+		return
+	for entry in entries:
+		match = pattern.match(entry)
+		if match and os.path.splitext(entry)[1].lower() in extensions:
+			yield os.path.join(directory, entry), match.group(1)
+
+def find_companion_files(src_file):
+	"""Find sidecar files next to a media file that belong to it.
+
+	Matches the media file's exact base name followed only by language or
+	subtitle flag tags, like movie.srt, movie.en.srt, movie.en.forced.srt and
+	movie.nfo, or by an artwork type, like movie-poster.jpg, so other files
+	that merely start with the same name are left alone. Returns
+	(companion_path, suffix) tuples where suffix is the part after the base
+	name, e.g. ".en.srt".
+	"""
+	src_dir = os.path.dirname(src_file)
+	base_name = os.path.splitext(os.path.basename(src_file))[0]
+	extensions = get_companion_extensions(src_file)
+	return [(candidate, suffix) for candidate, suffix in _companion_entries(src_dir, base_name, extensions)
+			if candidate != src_file and os.path.isfile(candidate)]
+
+def find_companion_links(dest_file):
+	"""Find the companion symlinks created next to dest_file."""
+	extensions = {ext.strip().lower() for ext in DEFAULT_COMPANION_EXTENSIONS.split(',')} | get_companion_extensions()
+	if os.path.splitext(dest_file)[1].lower() in extensions:
+		# dest_file is a companion itself
+		return []
+	dest_dir = os.path.dirname(dest_file)
+	base_name = os.path.splitext(os.path.basename(dest_file))[0]
+	return [(link, suffix) for link, suffix in _companion_entries(dest_dir, base_name, extensions)
+			if link != dest_file and os.path.islink(link)]
+
+def remove_companion_links(dest_file):
+	"""Remove the companion symlinks of a media symlink that is being removed.
+
+	A renamed media symlink gets its companions linked again under the new
+	name once it is created.
+	"""
+	removed = []
+	for link, _ in find_companion_links(dest_file):
+		try:
+			os.remove(link)
+			removed.append(link)
+			log_message(f"Removed companion symlink: {link}", level="INFO")
+		except OSError as e:
+			log_message(f"Failed to remove companion symlink {link}: {e}", level="WARNING")
+	return removed
+
+def link_companion_files(src_file, dest_file):
+	"""Symlink sidecar subtitles, .nfo and artwork next to dest_file.
+
+	Companions keep their suffix (including language tags) and take the
+	destination's renamed base name, so movie.en.srt follows the movie.
+	"""
+	if not is_companion_files_enabled():
+		return []
+
+	dest_dir = os.path.dirname(dest_file)
+	dest_base = os.path.splitext(os.path.basename(dest_file))[0]
+	linked = []
+
+	for companion, suffix in find_companion_files(src_file):
+		companion_dest = os.path.join(dest_dir, dest_base + suffix)
+		if os.path.lexists(companion_dest):
+			continue
+		try:
+			os.symlink(map_symlink_target(companion), companion_dest)
+			linked.append(companion_dest)
+			log_message(f"Created companion symlink: {companion_dest} -> {companion}", level="INFO")
+		except OSError as e:
+			log_message(f"Failed to create companion symlink {companion_dest}: {e}", level="WARNING")
+
+	return linked
+
 def _safe_delete_symlink(path):
 	try:
 		if not os.path.lexists(path):
 			return True
+		remove_companion_links(path)
 		if os.path.islink(path):
 			if (os.getenv('TRASH_ENABLED', 'true').lower() in ['true','1','yes']) and _move_symlink_to_trash(path):
 				return True
@@ -569,6 +663,7 @@ def delete_broken_symlinks(dest_dir, removed_path=None):
                                                 if not os.path.exists(target):
                                                     log_message(f"Found broken symlink: {dest_path}", level="INFO")
                                                     os.remove(dest_path)
+                                                    remove_companion_links(dest_path)
                                                     symlinks_deleted = True
                                                     log_message(f"Deleted broken symlink: {dest_path}", level="INFO")
                                                     send_file_deletion(removed_path, dest_path, tmdb_id, season_number, "Source file removed, cleaned up broken symlink")
@@ -742,13 +837,14 @@ def _check_all_symlinks(dest_dir):
     for file in files:
         file_path = os.path.join(dest_dir, file)
         if os.path.islink(file_path):
-            target = os.readlink(file_path)
+            target = read_symlink_target(file_path)
             normalized_target = normalize_file_path(target)
             log_message(f"Checking symlink: {file_path} -> {normalized_target}", level="DEBUG")
 
             if not os.path.exists(target):
                 log_message(f"Deleting broken symlink: {file_path}", level="INFO")
                 os.remove(file_path)
+                remove_companion_links(file_path)
 
                 with sqlite3.connect(DB_FILE) as conn1, sqlite3.connect(PROCESS_DB) as conn2:
                     cursor1 = conn1.cursor()
@@ -799,7 +895,7 @@ def get_existing_symlink_info(src_file):
         for filename in os.listdir(dir_path):
             full_path = os.path.join(dir_path, filename)
             if os.path.islink(full_path):
-                target_path = os.readlink(full_path)
+                target_path = read_symlink_target(full_path)
 
                 # Normalize paths for consistent comparison
                 normalized_src_file = normalize_file_path(src_file)
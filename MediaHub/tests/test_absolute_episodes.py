@@ -0,0 +1,87 @@
+"""
+Tests for anime absolute episode numbering.
+
+Run from the repository root:
+    python3 -m unittest MediaHub.tests.test_absolute_episodes
+"""
+import unittest
+
+from MediaHub.utils.parser.extractor import extract_all_metadata
+from MediaHub.utils.parser.parse_absolute import (
+    AbsoluteEpisode,
+    parse_absolute_episode,
+    resolve_absolute_episode,
+    resolve_absolute_episodes,
+)
+
+# Episodes per season, as the provider reports them; season 0 holds specials
+SEASON_EPISODE_COUNTS = ((0, 4), (1, 61), (2, 63), (3, 14))
+
+
+class ResolveAbsoluteEpisodeTest(unittest.TestCase):
+    def test_maps_absolute_numbers_to_season_and_episode(self):
+        cases = [
+            (1, (1, 1)),
+            (61, (1, 61)),
+            (62, (2, 1)),
+            (124, (2, 63)),
+            (125, (3, 1)),
+            (138, (3, 14)),
+            (139, None),
+        ]
+        for absolute, expected in cases:
+            with self.subTest(absolute=absolute):
+                self.assertEqual(resolve_absolute_episode(absolute, SEASON_EPISODE_COUNTS), expected)
+
+    def test_season_order_does_not_matter(self):
+        self.assertEqual(resolve_absolute_episode(125, reversed(SEASON_EPISODE_COUNTS)), (3, 1))
+
+    def test_resolves_every_number_of_a_span(self):
+        cases = [
+            (AbsoluteEpisode(125, 126), [(3, 1), (3, 2)]),
+            (AbsoluteEpisode(124, 125), [(2, 63), (3, 1)]),
+            (AbsoluteEpisode(138, 139), None),
+        ]
+        for absolute, expected in cases:
+            with self.subTest(absolute=str(absolute)):
+                self.assertEqual(resolve_absolute_episodes(absolute, SEASON_EPISODE_COUNTS), expected)
+
+
+class ParseAbsoluteEpisodeTest(unittest.TestCase):
+    def test_parses_numbers_spans_and_versions(self):
+        cases = [
+            ("Anime - 125 [1080p].mkv", AbsoluteEpisode(125, 125)),
+            ("Anime - 125v2 [1080p].mkv", AbsoluteEpisode(125, 125, 2)),
+            ("Anime - 125-126 [1080p].mkv", AbsoluteEpisode(125, 126)),
+            ("Anime - S03E01 - 125 [1080p].mkv", None),
+            ("Movie - 1995-2005 [1080p].mkv", None),
+        ]
+        for filename, expected in cases:
+            with self.subTest(filename=filename):
+                self.assertEqual(parse_absolute_episode(filename), expected)
+
+
+class ExtractAbsoluteEpisodeTest(unittest.TestCase):
+    def test_absolute_numbers_are_episodes_not_titles(self):
+        cases = [
+            ("Anime - 125 [1080p].mkv", 125, [125]),
+            ("Anime - 125v2 [1080p].mkv", 125, [125]),
+            ("Anime - 125-126 [1080p].mkv", 125, [125, 126]),
+        ]
+        for filename, episode, episodes in cases:
+            with self.subTest(filename=filename):
+                metadata = extract_all_metadata(filename)
+                self.assertEqual(metadata.title, "Anime")
+                self.assertEqual(metadata.episode, episode)
+                self.assertEqual(metadata.episodes, episodes)
+                self.assertTrue(metadata.is_tv_show)
+
+    def test_year_like_numbers_stay_in_movie_titles(self):
+        metadata = extract_all_metadata("Blade Runner - 2049 (2017) [1080p].mkv")
+        self.assertEqual(metadata.title, "Blade Runner 2049")
+        self.assertIsNone(metadata.episode)
+        self.assertFalse(metadata.is_tv_show)
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -1,3 +1,4 @@
+import os
 import time
 import requests
 from threading import Lock
@@ -128,6 +129,10 @@ def force_dashboard_recheck():
     """Force a recheck of dashboard availability."""
     _dashboard_checker.force_recheck()
 
+def get_operation_batch_id():
+    """Get the WebDavHub batch a bulk run reports its file operations to, empty outside one"""
+    return os.environ.get('CINESYNC_OPERATION_BATCH_ID', '')
+
 def send_dashboard_notification(url, payload, operation_type="notification", max_retries=2):
     """
     Send notification to dashboard with retry logic.
@@ -0,0 +1,108 @@
+import os
+import re
+import fnmatch
+from MediaHub.utils.logging_utils import log_message
+from MediaHub.config.config import get_destination_routes
+
+_SIZE_PATTERN = re.compile(r'^(\d+(?:\.\d+)?)\s*([KMGT]?B?)$', re.IGNORECASE)
+_SIZE_UNITS = {'': 1, 'B': 1, 'K': 1024, 'KB': 1024, 'M': 1024 ** 2, 'MB': 1024 ** 2,
+               'G': 1024 ** 3, 'GB': 1024 ** 3, 'T': 1024 ** 4, 'TB': 1024 ** 4}
+_RESOLUTION_PATTERN = re.compile(r'(?<![0-9])(4320|2160|1440|1080|720|576|540|480|360)[pi](?![a-z0-9])', re.IGNORECASE)
+_RESOLUTION_ALIASES = {'8k': 4320, '4k': 2160, 'uhd': 2160, 'fhd': 1080, 'hd': 720, 'sd': 480}
+
+def parse_size(value):
+    """Parse a size such as 500MB or 40GB into bytes, None when invalid"""
+    match = _SIZE_PATTERN.match(value.strip())
+    if not match:
+        return None
+    return int(float(match.group(1)) * _SIZE_UNITS[match.group(2).upper()])
+
+def parse_resolution(value):
+    """Parse a resolution such as 2160, 1080p or 4K into its line count, None when invalid"""
+    value = value.strip().lower()
+    if value in _RESOLUTION_ALIASES:
+        return _RESOLUTION_ALIASES[value]
+    value = value.rstrip('pi')
+    return int(value) if value.isdigit() else None
+
+def get_file_resolution(file_path):
+    """Get the resolution of a media file from its name or folder name, None when unknown"""
+    for name in (os.path.basename(file_path), os.path.basename(os.path.dirname(file_path))):
+        match = _RESOLUTION_PATTERN.search(name)
+        if match:
+            return int(match.group(1))
+        if re.search(r'\b(4k|uhd)\b', name, re.IGNORECASE):
+            return 2160
+    return None
+
+def _compare(actual, operator, expected):
+    if actual is None or expected is None:
+        return False
+    return {
+        '>=': actual >= expected,
+        '<=': actual <= expected,
+        '>': actual > expected,
+        '<': actual < expected,
+        '=': actual == expected,
+        '!=': actual != expected,
+    }[operator]
+
+def _in_library(src_file, library):
+    library = os.path.normpath(library)
+    src_file = os.path.normpath(src_file)
+    return src_file == library or src_file.startswith(library + os.sep)
+
+def _matches(condition, src_file):
+    field, operator, value = condition
+    if field == 'resolution':
+        return _compare(get_file_resolution(src_file), operator, parse_resolution(value))
+    if field == 'size':
+        try:
+            size = os.path.getsize(src_file)
+        except OSError:
+            return False
+        return _compare(size, operator, parse_size(value))
+    if field == 'library':
+        matched = _in_library(src_file, value)
+    else:
+        matched = fnmatch.fnmatch(src_file.lower(), value.lower())
+    if operator == '=':
+        return matched
+    if operator == '!=':
+        return not matched
+    return False
+
+def route_destination(src_file, default_root):
+    """Pick the destination root of a source file.
+
+    Rules from DESTINATION_ROUTES are evaluated in order and the first one whose
+    conditions all match wins; files no rule matches go to default_root.
+    """
+    for conditions, destination in get_destination_routes():
+        if all(_matches(condition, src_file) for condition in conditions):
+            log_message(f"Routing {os.path.basename(src_file)} to destination {destination}", level="DEBUG")
+            return destination
+    return default_root
+
+def rebase_destination(dest_file, default_root, destination_root):
+    """Move a destination path built below default_root to the same place below destination_root"""
+    if not dest_file or os.path.normpath(destination_root) == os.path.normpath(default_root):
+        return dest_file
+    relative = os.path.relpath(dest_file, default_root)
+    if relative == os.pardir or relative.startswith(os.pardir + os.sep):
+        return dest_file
+    return os.path.join(destination_root, relative)
+
+def find_destination_root(dest_file):
+    """Get the destination root a destination path lies in: the longest matching
+    route destination, else DESTINATION_DIR. None when it lies in neither."""
+    if not dest_file:
+        return None
+    roots = [destination for _, destination in get_destination_routes()]
+    if os.getenv('DESTINATION_DIR'):
+        roots.append(os.path.normpath(os.getenv('DESTINATION_DIR')))
+    dest_file = os.path.normpath(dest_file)
+    for root in sorted(set(roots), key=len, reverse=True):
+        if dest_file == root or dest_file.startswith(root + os.sep):
+            return root
+    return None
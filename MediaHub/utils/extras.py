@@ -0,0 +1,65 @@
+import os
+import re
+from MediaHub.processors.db_utils import get_processed_files_in_dir, normalize_file_path
+
+# Extras by the file name suffix media servers recognize, with the folder each
+# kind is collected in next to its title
+EXTRA_SUFFIXES = {
+    'behindthescenes': 'Behind The Scenes',
+    'deleted': 'Deleted Scenes',
+    'featurette': 'Featurettes',
+    'interview': 'Interviews',
+    'scene': 'Scenes',
+    'short': 'Shorts',
+    'trailer': 'Trailers',
+    'other': 'Other',
+}
+
+_SUFFIX_PATTERN = re.compile(r'-(' + '|'.join(EXTRA_SUFFIXES) + r')$', re.IGNORECASE)
+_SEASON_FOLDER_PATTERN = re.compile(r'^(Season\s*\d+|Specials)$', re.IGNORECASE)
+
+def _folder_key(name):
+    return re.sub(r'[\s._-]', '', name).lower()
+
+# Source folders holding extras, keyed by their name without separators so
+# "Behind the Scenes", "behind_the_scenes" and "BehindTheScenes" all match
+EXTRA_FOLDERS = {_folder_key(folder): folder for folder in EXTRA_SUFFIXES.values()}
+EXTRA_FOLDERS['extras'] = 'Other'
+
+def detect_extra(src_file):
+    """Recognize an extra by its name suffix (Movie-trailer.mkv) or folder (Movie/Trailers/clip.mkv).
+
+    Returns (extras folder, source directory of its title), or None for files that are not extras.
+    """
+    base = os.path.splitext(os.path.basename(src_file))[0]
+    match = _SUFFIX_PATTERN.search(base)
+    if match:
+        return EXTRA_SUFFIXES[match.group(1).lower()], os.path.dirname(src_file)
+
+    parent = os.path.dirname(src_file)
+    folder = EXTRA_FOLDERS.get(_folder_key(os.path.basename(parent)))
+    if folder:
+        return folder, os.path.dirname(parent)
+    return None
+
+def find_title_record(src_file, title_dir):
+    """Find the linked main file of the title an extra belongs to, None until one is linked"""
+    src_file = normalize_file_path(src_file)
+    title_dir = normalize_file_path(title_dir).rstrip(os.sep)
+    candidates = [
+        record for record in get_processed_files_in_dir(title_dir)
+        if record['file_path'] != src_file
+        and record['file_path'].startswith(title_dir + os.sep)
+        and not detect_extra(record['file_path'])
+    ]
+    if not candidates:
+        return None
+    # The file closest to the title folder is the movie itself or an episode
+    return min(candidates, key=lambda record: (not record['tmdb_id'], record['file_path'].count(os.sep), record['file_path']))
+
+def extras_destination(title_dest_file, folder, src_file):
+    """Get where an extra is linked: an extras folder in the title folder of title_dest_file"""
+    title_dest_dir = os.path.dirname(title_dest_file)
+    if _SEASON_FOLDER_PATTERN.match(os.path.basename(title_dest_dir)):
+        title_dest_dir = os.path.dirname(title_dest_dir)
+    return os.path.join(title_dest_dir, folder, os.path.basename(src_file))
@@ -2,6 +2,7 @@ import re
 import os
 import json
 import builtins
+import fnmatch
 import unicodedata
 from typing import Tuple, Optional, Dict, List, Set, Union, Any
 from functools import lru_cache
@@ -563,14 +564,26 @@ def normalize_query(query: str) -> str:
 def should_skip_processing(filename: str) -> bool:
     """
     Determine if a file should be skipped from MediaHub processing.
-    Returns True if the file should be skipped (metadata files)
+    Returns True if the file should be skipped (metadata files and partial downloads)
     """
     if not isinstance(filename, str):
         return False
 
+    # Skip files a download client is still writing
+    if is_partial_download(filename):
+        return True
+
     # Skip only metadata files - allow .srt and .strm to be processed
     return filename.lower().endswith(('.sub', '.idx', '.vtt'))
 
+def is_partial_download(filename: str) -> bool:
+    """
+    Check whether a file name matches PARTIAL_FILE_PATTERNS, the names download
+    clients give files that are not complete yet
+    """
+    name = filename.lower()
+    return any(fnmatch.fnmatchcase(name, pattern) for pattern in get_partial_file_patterns())
+
 def is_extras_file(file: str, file_path: str, is_movie: bool = False) -> bool:
     """
     Determine if the file is an extra based on size limits.
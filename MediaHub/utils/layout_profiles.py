@@ -0,0 +1,91 @@
+import os
+import re
+from MediaHub.utils.logging_utils import log_message
+from MediaHub.config.config import get_layout_profile
+
+SUBTITLE_EXTENSIONS = '.srt,.ass,.ssa,.sub,.idx,.vtt,.sup'
+ARTWORK_EXTENSIONS = '.jpg,.jpeg,.png'
+
+# Destination layout presets for the supported media servers.
+#   id_tag:      how database IDs are written in folder and file names, None drops them
+#   season:      season folder name, formatted with the season number
+#   companions:  sidecar extensions linked next to each media file
+LAYOUT_PROFILES = {
+    'plex': {
+        'id_tag': '{{{source}-{id}}}',
+        'season': 'Season {season:02d}',
+        'companions': f'{SUBTITLE_EXTENSIONS},{ARTWORK_EXTENSIONS}',
+    },
+    'jellyfin': {
+        'id_tag': '[{source}id-{id}]',
+        'season': 'Season {season:02d}',
+        'companions': f'{SUBTITLE_EXTENSIONS},.nfo,{ARTWORK_EXTENSIONS}',
+    },
+    'emby': {
+        'id_tag': '[{source}id={id}]',
+        'season': 'Season {season:02d}',
+        'companions': f'{SUBTITLE_EXTENSIONS},.nfo,{ARTWORK_EXTENSIONS}',
+    },
+    'kodi': {
+        'id_tag': None,
+        'season': 'Season {season}',
+        'companions': f'{SUBTITLE_EXTENSIONS},.nfo,{ARTWORK_EXTENSIONS},.tbn',
+    },
+}
+
+# Matches every ID tag style the processors and presets produce:
+# {tmdb-1}, {tmdbid-1}, [tmdbid-1] and [tmdbid=1]
+_ID_TAG_PATTERN = re.compile(r'\s*(?:\{(tmdb|imdb|tvdb)(?:id)?-([^}]+)\}|\[(tmdb|imdb|tvdb)id[-=]([^\]]+)\])', re.IGNORECASE)
+_SEASON_FOLDER_PATTERN = re.compile(r'^Season\s*(\d+)$', re.IGNORECASE)
+
+def get_profile(name):
+    """Get the preset for a profile name, None for custom or unknown profiles"""
+    return LAYOUT_PROFILES.get((name or '').lower())
+
+def get_profile_companion_extensions(name):
+    """Get the comma separated companion extensions of a profile, None for custom"""
+    profile = get_profile(name)
+    return profile['companions'] if profile else None
+
+def _rewrite_id_tags(name, id_tag):
+    def replace(match):
+        source = (match.group(1) or match.group(3)).lower()
+        value = match.group(2) or match.group(4)
+        if id_tag is None:
+            return ''
+        return ' ' + id_tag.format(source=source, id=value)
+    return _ID_TAG_PATTERN.sub(replace, name).strip()
+
+def _rewrite_component(component, profile, is_file):
+    if not is_file:
+        season_match = _SEASON_FOLDER_PATTERN.match(component)
+        if season_match:
+            return profile['season'].format(season=int(season_match.group(1)))
+        return _rewrite_id_tags(component, profile['id_tag'])
+
+    base, ext = os.path.splitext(component)
+    return _rewrite_id_tags(base, profile['id_tag']) + ext
+
+def apply_layout_profile(dest_file, dest_root, src_file):
+    """Rewrite a destination path below dest_root to the layout profile of src_file's library.
+
+    Only ID tags and season folder names are touched; the custom profile keeps
+    the path built from the naming settings unchanged.
+    """
+    profile_name = get_layout_profile(src_file)
+    profile = get_profile(profile_name)
+    if not profile or not dest_file or not dest_root:
+        return dest_file
+
+    dest_root = os.path.normpath(dest_root)
+    relative = os.path.relpath(os.path.normpath(dest_file), dest_root)
+    if relative.startswith(os.pardir):
+        return dest_file
+
+    parts = relative.split(os.sep)
+    rewritten = [_rewrite_component(part, profile, i == len(parts) - 1) for i, part in enumerate(parts)]
+    layout_file = os.path.join(dest_root, *rewritten)
+
+    if layout_file != dest_file:
+        log_message(f"Applied {profile_name} layout: {relative} -> {os.path.join(*rewritten)}", level="DEBUG")
+    return layout_file
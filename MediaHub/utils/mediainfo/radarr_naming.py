@@ -11,6 +11,7 @@ from MediaHub.utils.logging_utils import log_message
 from MediaHub.config.config import get_mediainfo_radarr_tags, mediainfo_parser
 from MediaHub.utils.meta_extraction_engine import get_ffprobe_media_info
 from MediaHub.utils.mediainfo import extract_media_info, keywords
+from MediaHub.utils.parser.parse_edition import parse_edition, format_edition_tag
 
 def get_radarr_movie_filename(movie_name, year, file_path, root_path, media_info=None):
     """
@@ -63,6 +64,12 @@ def get_radarr_movie_filename(movie_name, year, file_path, root_path, media_info
                     if value:
                         filename_parts.append(value)
 
+                # Handle {edition}, the Plex style edition tag that keeps editions apart
+                elif field_name.lower() == 'edition':
+                    edition_tag = format_edition_tag(parse_edition(os.path.basename(file_path)))
+                    if edition_tag:
+                        filename_parts.append(edition_tag)
+
                 # Handle MediaInfo 3D
                 elif field_name == 'MediaInfo 3D' and 'MediaInfo 3D' in media_info:
                     value = media_info['MediaInfo 3D']
@@ -4,10 +4,11 @@ import json
 from typing import Dict, Any, List, Optional, Tuple
 from dataclasses import dataclass
 
-from MediaHub.utils.parser.patterns import FILE_EXTENSION_PATTERNS, SPORTS_PATTERNS, SPORTS_SESSION_PATTERNS
+from MediaHub.utils.parser.patterns import EDITION_TAG_PATTERN, FILE_EXTENSION_PATTERNS, SPORTS_PATTERNS, SPORTS_SESSION_PATTERNS, EPISODE_PATTERNS, MAX_MULTI_EPISODE_SPAN
 from MediaHub.utils.parser.parse_year import is_valid_year, extract_year, find_all_years_in_filename, should_include_year_in_title, _determine_year_context
 from MediaHub.utils.parser.utils import clean_title_string
 from MediaHub.utils.parser.parse_anime import is_anime_filename, extract_anime_title
+from MediaHub.utils.parser.parse_absolute import parse_absolute_episode, strip_absolute_episode_tags
 
 # Cache for parsed filename structures to avoid redundant parsing
 _filename_cache = {}
@@ -98,6 +99,8 @@ class MediaMetadata:
     # TV-specific
     season: Optional[int] = None
     episode: Optional[int] = None
+    # Every episode a multi-episode file covers, e.g. [1, 2, 3] for S01E01-E03
+    episodes: List[int] = None
 
     # Sports-specific
     is_sports: bool = False
@@ -134,6 +137,8 @@ class MediaMetadata:
             self.audio_channels = []
         if self.languages is None:
             self.languages = []
+        if self.episodes is None:
+            self.episodes = []
 
     def to_dict(self) -> Dict[str, Any]:
         """Convert to dictionary for JSON serialization."""
@@ -157,7 +162,10 @@ def extract_all_metadata(filename: str) -> MediaMetadata:
     if filename in _filename_cache:
         return _filename_cache[filename]
 
-    parsed = _parse_filename_structure(filename)
+    # Version tags and absolute spans ("- 125v2", "- 125-126") would otherwise
+    # be read as part of the title
+    absolute = parse_absolute_episode(filename)
+    parsed = _parse_filename_structure(strip_absolute_episode_tags(filename))
 
     # Extract alternative title from original filename before any cleaning
     from MediaHub.utils.parser.utils import extract_alternative_title
@@ -176,8 +184,28 @@ def extract_all_metadata(filename: str) -> MediaMetadata:
 
     # Check for TV show patterns first (prioritize over sports when clear season/episode patterns exist)
     is_tv = _is_tv_show(parsed)
+    season = _extract_season_from_parsed(parsed)
     episode = _extract_episode_from_parsed(parsed)
 
+    # Absolute numbered anime ("Title - 125") the general parser left in the
+    # title. Year-like numbers stay titles, as in "Blade Runner - 2049".
+    if absolute and episode is None and season is None and not 1900 <= absolute.start <= 2099:
+        if title:
+            title = re.sub(rf'\s+0*{absolute.start}$', '', title).strip() or title
+        episode = absolute.start
+
+    # Specials named without an S00 marker belong to season 0. Only names that
+    # already read as TV qualify, so movie titles like "Batman Special 2" stay movies.
+    if episode is None and (is_tv or season is not None):
+        special_match = EPISODE_PATTERNS['special'].search(parsed.original)
+        if special_match:
+            season = 0
+            episode = int(special_match.group(1))
+
+    episodes = _extract_episode_numbers_from_parsed(parsed, episode)
+    if absolute and episode == absolute.start:
+        episodes = absolute.numbers
+
     # If we found an episode number
     if episode is not None:
         is_tv = True
@@ -220,8 +248,9 @@ def extract_all_metadata(filename: str) -> MediaMetadata:
         is_subbed=_extract_subbed_flag_from_parsed(parsed),
         release_group=_extract_release_group_from_parsed(parsed),
         edition=_extract_edition_from_parsed(parsed),
-        season=_extract_season_from_parsed(parsed),
+        season=season,
         episode=episode,
+        episodes=episodes,
         is_tv_show=is_tv,
         is_movie=is_movie,
         episode_title=_extract_episode_title_from_parsed(parsed),
@@ -1564,6 +1593,10 @@ def _extract_release_group_from_parsed(parsed: ParsedFilename) -> Optional[str]:
 
 def _extract_edition_from_parsed(parsed: ParsedFilename) -> Optional[str]:
     """Extract edition information from parsed filename data."""
+    edition_tag = EDITION_TAG_PATTERN.search(parsed.original)
+    if edition_tag:
+        return edition_tag.group(1).strip()
+
     for term in parsed.technical_terms:
         if term['type'] == 'edition':
             return term['term']
@@ -1656,8 +1689,8 @@ def _extract_season_from_parsed(parsed: ParsedFilename) -> Optional[int]:
         if match:
             return int(match.group(1))
 
-        # Handle season x episode format like 1x18, 1x1024, or 1x18-20, 1x1024-1026
-        match = re.match(r'^(\d{1,2})x\d{1,4}(?:-\d{1,4})?$', clean_part, re.IGNORECASE)
+        # Handle season x episode format like 1x18, 1x1024, 1x18-20, or 1x01x02
+        match = re.match(r'^(\d{1,2})x\d{1,4}(?:-\d{1,4}|(?:x\d{1,4})+)?$', clean_part, re.IGNORECASE)
         if match:
             return int(match.group(1))
 
@@ -1765,6 +1798,11 @@ def _extract_episode_from_parsed(parsed: ParsedFilename) -> Optional[int]:
         if match:
             return int(match.group(1))
 
+        # Handle double episode format like 1x01x02 (return first episode)
+        match = re.match(r'^\d{1,2}x(\d{1,4})(?:x\d{1,4})+$', clean_part, re.IGNORECASE)
+        if match:
+            return int(match.group(1))
+
     # Check for season x episode patterns in the original filename
     episode_patterns = [
         r'\b\d{1,2}x(\d{1,4})-\d{1,4}\b',  # "1x18-20", "1x1024-1026" (return first episode)
@@ -1816,6 +1854,55 @@ def _extract_episode_from_parsed(parsed: ParsedFilename) -> Optional[int]:
     return None
 
 
+def _extract_episode_numbers_from_parsed(parsed: ParsedFilename, episode: Optional[int]) -> List[int]:
+    """
+    Extract every episode covered by a multi-episode file.
+
+    A dash marks a range (S01E01-E03, S01E01-03, 1x01-03) and repeated
+    markers list episodes individually (S01E01E02, 1x01x02). Files covering a
+    single episode return just that episode.
+    """
+    match = EPISODE_PATTERNS['multi_episode'].search(parsed.original)
+    if not match:
+        match = EPISODE_PATTERNS['multi_episode_x'].search(parsed.original)
+        if match:
+            numbers = [int(match.group(2))] + [int(n) for n in re.findall(r'x(\d{1,4})', match.group(3), re.IGNORECASE)]
+            return _ordered_unique(numbers) if episode in numbers else ([episode] if episode is not None else [])
+    if not match:
+        match = EPISODE_PATTERNS['season_x_episode_range'].search(parsed.original)
+        if match:
+            return _expand_episode_range(int(match.group(2)), int(match.group(3)), episode)
+        return [episode] if episode is not None else []
+
+    numbers = [int(match.group(2))]
+    for separator, number in EPISODE_PATTERNS['multi_episode_segment'].findall(match.group(3)):
+        number = int(number)
+        if separator == '-':
+            numbers = numbers[:-1] + _expand_episode_range(numbers[-1], number, None)
+        else:
+            numbers.append(number)
+
+    # Only trust the span when it agrees with the episode used for matching
+    if episode is not None and episode not in numbers:
+        return [episode]
+    return _ordered_unique(numbers)
+
+
+def _expand_episode_range(start: int, end: int, episode: Optional[int]) -> List[int]:
+    """Expand an inclusive episode range, rejecting reversed or implausible spans."""
+    if start < end <= start + MAX_MULTI_EPISODE_SPAN:
+        return list(range(start, end + 1))
+    if episode is not None:
+        return [episode]
+    return [start]
+
+
+def _ordered_unique(numbers: List[int]) -> List[int]:
+    """Drop duplicate episode numbers while keeping their order."""
+    seen = set()
+    return [n for n in numbers if not (n in seen or seen.add(n))]
+
+
 def _extract_episode_title_from_parsed(parsed: ParsedFilename) -> Optional[str]:
     """Extract episode title from parsed filename data."""
     if not _is_tv_show(parsed):
@@ -0,0 +1,101 @@
+import re
+from dataclasses import dataclass
+from typing import Iterable, List, Optional, Tuple
+
+from MediaHub.utils.parser.patterns import ABSOLUTE_EPISODE_PATTERNS, MAX_MULTI_EPISODE_SPAN
+
+
+@dataclass
+class AbsoluteEpisode:
+    """An absolute episode number, or span, parsed from an anime filename."""
+    start: int
+    end: int
+    version: Optional[int] = None
+
+    @property
+    def numbers(self) -> List[int]:
+        return list(range(self.start, self.end + 1))
+
+    def __str__(self) -> str:
+        if self.end != self.start:
+            return f"{self.start}-{self.end}"
+        return str(self.start)
+
+
+def _is_year_span(start: int, end: int) -> bool:
+    return 1900 <= start <= 2099 and 1900 <= end <= 2099
+
+
+def parse_absolute_episode(filename: str) -> Optional[AbsoluteEpisode]:
+    """
+    Parse an absolute episode number such as "Title - 125", "Title - 125v2" or
+    "Title - 125-126" from a filename.
+
+    Files that carry season markers (S01E05, 1x05) are not absolute numbered
+    and return None.
+    """
+    if not filename:
+        return None
+    if ABSOLUTE_EPISODE_PATTERNS['season_marker'].search(filename):
+        return None
+
+    match = ABSOLUTE_EPISODE_PATTERNS['absolute'].search(filename)
+    if not match:
+        return None
+
+    start = int(match.group(1))
+    end = int(match.group(2)) if match.group(2) else start
+    version = int(match.group(3)) if match.group(3) else None
+
+    if start < 1:
+        return None
+    if end != start and (end < start or end - start > MAX_MULTI_EPISODE_SPAN or _is_year_span(start, end)):
+        return None
+
+    return AbsoluteEpisode(start=start, end=end, version=version)
+
+
+def strip_absolute_episode_tags(filename: str) -> str:
+    """
+    Reduce "Title - 125v2" and "Title - 125-126" to "Title - 125" so the
+    general parser sees a plain anime episode number.
+    """
+    absolute = parse_absolute_episode(filename)
+    if not absolute or (absolute.end == absolute.start and absolute.version is None):
+        return filename
+
+    match = ABSOLUTE_EPISODE_PATTERNS['absolute'].search(filename)
+    return filename[:match.start(1)] + match.group(1) + filename[match.end():]
+
+
+def resolve_absolute_episode(absolute: int, season_episode_counts: Iterable[Tuple[int, int]]) -> Optional[Tuple[int, int]]:
+    """
+    Map an absolute episode number to (season, episode) using the number of
+    episodes in each season. Specials (season 0) never take part in absolute
+    numbering.
+
+    Returns None when the absolute number is past the last known episode.
+    """
+    remaining = absolute
+    for season, episode_count in sorted(season_episode_counts):
+        if season < 1 or episode_count < 1:
+            continue
+        if remaining <= episode_count:
+            return season, remaining
+        remaining -= episode_count
+    return None
+
+
+def resolve_absolute_episodes(absolute: AbsoluteEpisode, season_episode_counts: Iterable[Tuple[int, int]]) -> Optional[List[Tuple[int, int]]]:
+    """
+    Map every number of an absolute episode or span to (season, episode). A
+    span may cross a season boundary, e.g. "- 12-13" covering the last episode
+    of season 1 and the first of season 2.
+
+    Returns None when any number is past the last known episode.
+    """
+    season_episode_counts = tuple(season_episode_counts)
+    resolved = [resolve_absolute_episode(number, season_episode_counts) for number in absolute.numbers]
+    if None in resolved:
+        return None
+    return resolved
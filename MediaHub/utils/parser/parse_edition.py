@@ -0,0 +1,44 @@
+import re
+from typing import Optional
+
+from MediaHub.utils.parser.patterns import EDITION_TAG_PATTERN, NAMED_EDITION_PATTERNS
+
+
+def _clean_edition(edition: str) -> str:
+    edition = re.sub(r'[._]+', ' ', edition)
+    return re.sub(r'\s+', ' ', edition).strip()
+
+
+def parse_edition(filename: str) -> Optional[str]:
+    """
+    Detect the edition of a movie release, e.g. "Director's Cut".
+
+    A Plex style "{edition-...}" tag wins over anything else in the name and is
+    returned as written. Otherwise the first known edition name found in the
+    filename is returned in its canonical form.
+    """
+    if not filename:
+        return None
+
+    match = EDITION_TAG_PATTERN.search(filename)
+    if match:
+        edition = _clean_edition(match.group(1))
+        return edition or None
+
+    for pattern, name in NAMED_EDITION_PATTERNS:
+        match = pattern.search(filename)
+        if match:
+            if name == 'Anniversary Edition':
+                return f"{match.group(1).lower()} Anniversary Edition"
+            return name
+
+    return None
+
+
+def format_edition_tag(edition: Optional[str]) -> str:
+    """Format an edition as the "{edition-...}" tag used in destination names."""
+    if not edition:
+        return ''
+    edition = _clean_edition(edition.replace('{', '').replace('}', ''))
+    return f"{{edition-{edition}}}" if edition else ''
+
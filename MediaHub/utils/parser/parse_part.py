@@ -0,0 +1,69 @@
+import os
+import re
+from typing import Optional, Tuple
+
+from MediaHub.utils.parser.patterns import STACK_PART_PATTERN
+
+
+def _split_part(stem: str) -> Optional[Tuple[int, str]]:
+    matches = list(STACK_PART_PATTERN.finditer(stem))
+    if not matches:
+        return None
+    match = matches[-1]
+    part = int(match.group(1))
+    if part < 1:
+        return None
+    base = stem[:match.start()] + stem[match.end():]
+    base = re.sub(r'[\s._-]+$', '', re.sub(r'^[\s._-]+', '', base))
+    base = re.sub(r'([\s._-])[\s._-]+', r'\1', base)
+    return (part, base) if base else None
+
+
+def parse_stack_part(filename: str) -> Optional[Tuple[int, str]]:
+    """
+    Detect a cdN/partN/discN marker in a filename.
+
+    Returns the part number and the filename with the marker removed, or None.
+    """
+    if not filename:
+        return None
+    stem, ext = os.path.splitext(os.path.basename(filename))
+    split = _split_part(stem)
+    if not split:
+        return None
+    part, base = split
+    return part, base + ext
+
+
+def find_stack_part(src_file: str) -> Optional[Tuple[int, str]]:
+    """
+    Detect whether a file is one part of a movie split over several files.
+
+    A marker alone is not enough, since titles such as "Deathly Hallows Part 2"
+    carry one too: another file in the same folder must share the name apart
+    from a different part number. Returns the part number and the filename
+    without the marker, or None.
+    """
+    parsed = parse_stack_part(src_file)
+    if not parsed:
+        return None
+    part, base = parsed
+
+    folder = os.path.dirname(src_file)
+    try:
+        siblings = os.listdir(folder)
+    except OSError:
+        return None
+
+    for sibling in siblings:
+        if sibling == os.path.basename(src_file):
+            continue
+        other = parse_stack_part(sibling)
+        if other and other[0] != part and other[1].lower() == base.lower():
+            return part, base
+    return None
+
+
+def format_stack_suffix(part: Optional[int]) -> str:
+    """Format the " - partN" suffix Plex and Jellyfin stack into one movie."""
+    return f" - part{part}" if part else ''
@@ -201,6 +201,22 @@ EPISODE_PATTERNS = {
     'anime_bracket_episode': re.compile(r'\[(\d{1,3})\]', re.IGNORECASE),
     'season_x_episode': re.compile(r'\b(\d{1,2})x(\d{1,3})\b', re.IGNORECASE),
     'season_x_episode_range': re.compile(r'\b(\d{1,2})x(\d{1,3})-(\d{1,3})\b', re.IGNORECASE),
+    # Multi-episode files: S01E01E02, S01E01-E03, S01E01-03, 1x01x02
+    'multi_episode': re.compile(r'\bS(\d{1,2})E(\d{1,4})((?:-?E\d{1,4}|-\d{1,4})+)\b', re.IGNORECASE),
+    'multi_episode_x': re.compile(r'\b(\d{1,2})x(\d{1,4})((?:x\d{1,4})+)\b', re.IGNORECASE),
+    'multi_episode_segment': re.compile(r'(-?)E?(\d{1,4})', re.IGNORECASE),
+    # Specials named without S00 after the title, e.g. "Show.S02.Special.2" or "Show Season 1 Specials 03".
+    # Only applied to names that already parse as TV; a leading "Special" is left alone too.
+    'special': re.compile(r'(?<=\S)[\s._]+(?:-[\s._]+)?Specials?[\s._-]*(\d{1,3})\b', re.IGNORECASE),
+}
+
+# Largest span accepted for a ranged multi-episode file such as S01E01-E03
+MAX_MULTI_EPISODE_SPAN = 50
+
+# Anime absolute numbering: "Title - 125", "Title - 125v2", "Title - 125-126"
+ABSOLUTE_EPISODE_PATTERNS = {
+    'absolute': re.compile(r'\s-\s(\d{1,4})(?:-(\d{1,4}))?(?:v(\d))?(?=[\s\[\(._]|$)', re.IGNORECASE),
+    'season_marker': re.compile(r'\bS\d{1,2}E\d{1,4}|\b\d{1,2}x\d{1,4}\b', re.IGNORECASE),
 }
 
 # Language patterns
@@ -246,6 +262,31 @@ def _build_edition_patterns():
 
 EDITION_PATTERNS = _build_edition_patterns()
 
+# Plex style edition tag: "Title (1982) {edition-Final Cut}"
+EDITION_TAG_PATTERN = re.compile(r'\{edition-([^{}]+)\}', re.IGNORECASE)
+
+# Release edition names, in the order they are preferred, with the name used
+# in destination filenames
+_SEP = r'[\s._-]*'
+NAMED_EDITION_PATTERNS = [
+    (re.compile(rf'\bDirector{_SEP}\'?s{_SEP}(?:Cut|Edition)\b', re.IGNORECASE), "Director's Cut"),
+    (re.compile(rf'\bFinal{_SEP}Cut\b', re.IGNORECASE), 'Final Cut'),
+    (re.compile(rf'\bExtended(?:{_SEP}(?:Cut|Edition))?\b', re.IGNORECASE), 'Extended'),
+    (re.compile(rf'\bTheatrical(?:{_SEP}(?:Cut|Edition|Version))?\b', re.IGNORECASE), 'Theatrical'),
+    (re.compile(rf'\bUltimate{_SEP}Edition\b', re.IGNORECASE), 'Ultimate Edition'),
+    (re.compile(rf'\bSpecial{_SEP}Edition\b', re.IGNORECASE), 'Special Edition'),
+    (re.compile(rf'\bCollector{_SEP}\'?s{_SEP}Edition\b', re.IGNORECASE), "Collector's Edition"),
+    (re.compile(rf'\b(\d{{1,3}}(?:st|nd|rd|th)){_SEP}Anniversary(?:{_SEP}Edition)?\b', re.IGNORECASE), 'Anniversary Edition'),
+    (re.compile(r'\bUnrated\b', re.IGNORECASE), 'Unrated'),
+    (re.compile(r'\bUncut\b', re.IGNORECASE), 'Uncut'),
+    (re.compile(r'\bIMAX\b', re.IGNORECASE), 'IMAX'),
+    (re.compile(r'\bCriterion\b', re.IGNORECASE), 'Criterion'),
+    (re.compile(r'\bRemaster(?:ed)?\b', re.IGNORECASE), 'Remastered'),
+]
+
+# Part of a movie split over several files: "movie.cd1.avi", "Movie (1999) - part2.mkv"
+STACK_PART_PATTERN = re.compile(r'(?<![a-z0-9])(?:cd|dvd|part|pt|disc|disk)[\s._-]*(\d{1,2})(?![a-z0-9])', re.IGNORECASE)
+
 # Repack/Proper patterns
 REPACK_PATTERNS = {
     'repack': re.compile(r'\b(REPACK|Repack)\b', re.IGNORECASE),
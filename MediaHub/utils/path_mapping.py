@@ -0,0 +1,45 @@
+import os
+from MediaHub.utils.logging_utils import log_message
+from MediaHub.config.config import get_path_mappings, is_path_map_validation_enabled
+
+def _replace_prefix(path, mappings):
+    """Replace the first matching prefix in path using (from, to) pairs."""
+    for source, target in mappings:
+        if path == source or path.startswith(source + os.sep) or path.startswith(source + '/'):
+            return target + path[len(source):]
+    return path
+
+def map_symlink_target(src_file):
+    """Rewrite a symlink target to the path the media server sees.
+
+    Applies CINESYNC_PATH_MAP. When CINESYNC_PATH_MAP_VALIDATE is enabled and the
+    rewritten target does not exist from this host, the original path is kept.
+    """
+    mappings = get_path_mappings()
+    if not mappings:
+        return src_file
+
+    mapped = _replace_prefix(src_file, mappings)
+    if mapped != src_file and is_path_map_validation_enabled() and not os.path.exists(mapped):
+        log_message(f"Mapped symlink target does not exist, keeping original path: {mapped}", level="WARNING")
+        return src_file
+
+    return mapped
+
+def unmap_symlink_target(target):
+    """Translate a mapped symlink target back to the local source path."""
+    mappings = get_path_mappings()
+    if not mappings:
+        return target
+    return _replace_prefix(target, [(to, source) for source, to in mappings])
+
+def read_symlink_target(path):
+    """Read a symlink and return its target as a local source path."""
+    return unmap_symlink_target(os.readlink(path))
+
+def symlink_target_exists(path):
+    """Check whether a symlink's local source file exists, honoring path mappings."""
+    try:
+        return os.path.exists(read_symlink_target(path))
+    except OSError:
+        return os.path.exists(path)
@@ -0,0 +1,77 @@
+import os
+from urllib.parse import quote
+from MediaHub.config.config import get_library_link_mode
+
+# Libraries linked in strm mode get small text files holding the URL or path a
+# media server should play instead of symlinks. The server reads, moves and
+# recreates them wherever it handles links, so both sides must agree on the
+# contents: strm_content mirrors Content in WebDavHub's pkg/strm.
+STRM_EXTENSION = '.strm'
+
+def uses_strm(src_file):
+    """Check whether the library of a source file is linked with .strm files"""
+    return get_library_link_mode(src_file) == 'strm'
+
+def strm_destination(dest_file):
+    """Get the .strm path standing in for the link dest_file"""
+    return os.path.splitext(dest_file)[0] + STRM_EXTENSION
+
+def _path_map():
+    mapping = {}
+    for entry in os.getenv('CINESYNC_STRM_PATH_MAP', '').split(';'):
+        if '=' not in entry:
+            continue
+        prefix, value = entry.split('=', 1)
+        prefix, value = prefix.strip(), value.strip()
+        if prefix:
+            mapping[os.path.normpath(prefix)] = value
+    return mapping
+
+def strm_content(src_file):
+    """Get what the .strm file of a source file holds.
+
+    The longest matching prefix of CINESYNC_STRM_PATH_MAP is replaced, escaping
+    the rest of the path when the replacement is a URL. Without a match the
+    source path itself is used.
+    """
+    src_file = os.path.normpath(src_file)
+    match = None
+    for prefix, replacement in _path_map().items():
+        if src_file == prefix or src_file.startswith(prefix.rstrip(os.sep) + os.sep):
+            if match is None or len(prefix) > len(match[0]):
+                match = (prefix, replacement)
+    if match is None:
+        return src_file
+
+    prefix, replacement = match
+    rest = src_file[len(prefix):]
+    if '://' not in replacement:
+        return os.path.normpath(replacement + os.sep + rest)
+    # The characters Go's url.PathEscape leaves alone, so both sides write the same URL
+    segments = rest.replace(os.sep, '/').split('/')
+    return replacement.rstrip('/') + '/'.join(quote(segment, safe="$&+:=@") for segment in segments)
+
+def is_strm_file(path):
+    """Check whether path is a .strm file rather than a symlink or real media"""
+    return (path.lower().endswith(STRM_EXTENSION) and os.path.isfile(path)
+            and not os.path.islink(path))
+
+def read_strm(path):
+    """Get the URL or path a .strm file holds, None when it cannot be read"""
+    try:
+        with open(path, 'r', encoding='utf-8') as f:
+            return f.read().strip()
+    except (OSError, UnicodeDecodeError):
+        return None
+
+def write_strm(path, content):
+    """Create or replace the .strm file at path, renaming it into place so readers never see it half written"""
+    os.makedirs(os.path.dirname(path), exist_ok=True)
+    tmp = path + '.tmp'
+    with open(tmp, 'w', encoding='utf-8') as f:
+        f.write(content + '\n')
+    try:
+        os.replace(tmp, path)
+    except OSError:
+        os.remove(tmp)
+        raise
@@ -1,4 +1,4 @@
-import { useState } from 'react';
+import { useState, useEffect } from 'react';
 import { useNavigate, useLocation } from 'react-router-dom';
 import {
   Box,
@@ -32,6 +32,14 @@ interface LocationState {
   };
 }
 
+interface Branding {
+  appName: string;
+  logoUrl?: string;
+  loginRedirect: string;
+}
+
+const defaultBranding: Branding = { appName: 'CineSync', loginRedirect: '/dashboard' };
+
 export default function Login({ toggleTheme, mode }: { toggleTheme: () => void; mode: 'light' | 'dark' }) {
   const [username, setUsername] = useState('');
   const [password, setPassword] = useState('');
@@ -41,9 +49,16 @@ export default function Login({ toggleTheme, mode }: { toggleTheme: () => void;
   const navigate = useNavigate();
   const location = useLocation();
   const { login } = useAuth();
+  const [branding, setBranding] = useState<Branding>(defaultBranding);
+
+  useEffect(() => {
+    axios.get<Branding>('/api/branding')
+      .then(response => setBranding({ ...defaultBranding, ...response.data }))
+      .catch(() => setBranding(defaultBranding));
+  }, []);
 
-  // Get the return URL from location state or default to dashboard
-  const from = (location.state as LocationState)?.from?.pathname || '/dashboard';
+  // Get the return URL from location state or default to the configured landing page
+  const from = (location.state as LocationState)?.from?.pathname || branding.loginRedirect;
 
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
@@ -126,7 +141,7 @@ export default function Login({ toggleTheme, mode }: { toggleTheme: () => void;
               animate={{ scale: 1 }}
               transition={{ delay: 0.2, type: "spring", stiffness: 200 }}
             >
-              <img src={logo} alt="CineSync Logo" style={{ display: 'block', margin: '0 auto 20px auto', maxWidth: '160px', width: '100%', height: 'auto' }} />
+              <img src={branding.logoUrl || logo} alt={`${branding.appName} Logo`} style={{ display: 'block', margin: '0 auto 20px auto', maxWidth: '160px', width: '100%', height: 'auto' }} />
               <Typography
                 component="h1"
                 variant="h4"
@@ -140,7 +155,7 @@ export default function Login({ toggleTheme, mode }: { toggleTheme: () => void;
                   WebkitTextFillColor: 'transparent',
                 }}
               >
-                CineSync Login
+                {branding.appName} Login
               </Typography>
             </motion.div>
 
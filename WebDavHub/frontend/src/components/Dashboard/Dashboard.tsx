@@ -37,6 +37,7 @@ interface Stats {
   storageUsed: string;
   totalMovies: number;
   totalShows: number;
+  eventSubscribers?: Record<string, number>;
   scanning?: boolean;
   progress?: {
     currentPath: string;
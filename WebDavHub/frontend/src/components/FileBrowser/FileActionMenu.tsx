@@ -237,7 +237,7 @@ const FileActionMenu: React.FC<FileActionMenuProps> = ({ file, currentPath, onVi
       setSuccessDialogOpen(true);
       if (onRename) onRename(file);
     } catch (error: any) {
-      setRenameError(error.response?.data || error.message || 'Failed to rename file');
+      setRenameError(error.response?.data?.message || error.message || 'Failed to rename file');
       setRenameLoading(false);
     }
   };
@@ -82,7 +82,7 @@ const DatabaseSearch: React.FC = () => {
         },
       });
 
-      setRecords(response.data.records || []);
+      setRecords(response.data.items || []);
       setTotalRecords(response.data.total || 0);
       setStats(response.data.stats || null);
 
@@ -202,7 +202,7 @@ const DatabaseSearch: React.FC = () => {
           }
         } catch (error: any) {
           console.error('Failed to delete files with destination paths:', error);
-          errors.push(`Failed to delete ${filesWithDestination.length} files: ${error.response?.data?.error || error.message}`);
+          errors.push(`Failed to delete ${filesWithDestination.length} files: ${error.response?.data?.message || error.message}`);
         }
       }
 
@@ -218,7 +218,7 @@ const DatabaseSearch: React.FC = () => {
           }
         } catch (error: any) {
           console.error('Failed to delete database records:', error);
-          errors.push(`Failed to delete ${filesWithoutDestination.length} database records: ${error.response?.data?.error || error.message}`);
+          errors.push(`Failed to delete ${filesWithoutDestination.length} database records: ${error.response?.data?.message || error.message}`);
         }
       }
 
@@ -244,8 +244,8 @@ const DatabaseSearch: React.FC = () => {
         setError(`Failed to delete any files. ${errors.length} errors occurred.`);
       }
     } catch (error: any) {
-      console.error('Failed to delete selected files:', error.response?.data?.error || error.message);
-      setError(error.response?.data?.error || error.message || 'Failed to delete selected files');
+      console.error('Failed to delete selected files:', error.response?.data?.message || error.message);
+      setError(error.response?.data?.message || error.message || 'Failed to delete selected files');
     } finally {
       setBulkActionLoading(false);
     }
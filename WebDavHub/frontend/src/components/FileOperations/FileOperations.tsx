@@ -8,6 +8,7 @@ import DatabaseSearch from './DatabaseSearch';
 import ProcessingAnimation from './ProcessingAnimation';
 import { useSSEEventListener } from '../../hooks/useCentralizedSSE';
 import { FileItem } from '../FileBrowser/types';
+import { pageCount } from '../../types/paging';
 import ModifyDialog from '../FileBrowser/ModifyDialog/ModifyDialog';
 
 const MotionFab = motion(Fab);
@@ -244,7 +245,7 @@ function FileOperations() {
         }
 
         // Handle both null and empty array cases for files
-        let filesArray = data.files;
+        let filesArray = data.items;
         if (filesArray === null || filesArray === undefined) {
           filesArray = [];
         } else if (!Array.isArray(filesArray)) {
@@ -257,7 +258,7 @@ function FileOperations() {
         if (filesArray.length === 0) {
           console.log('No source files found - all files may be processed or directory is empty');
           setSourceFiles([]);
-          setSourceTotalPages(data.totalPages || 1);
+          setSourceTotalPages(pageCount(data));
           setSourceTotalFiles(data.total || 0);
           setHasSourceDirectories(true);
           setError('');
@@ -281,7 +282,7 @@ function FileOperations() {
         }));
 
         setSourceFiles(convertedFiles);
-        setSourceTotalPages(data.totalPages || 1);
+        setSourceTotalPages(pageCount(data));
         setSourceTotalFiles(data.total || 0);
         setHasSourceDirectories(true);
 
@@ -359,7 +360,7 @@ function FileOperations() {
       const response = await axios.get('/api/file-operations', { params });
       const data = response.data;
 
-      setOperations(data.operations || []);
+      setOperations(data.items || []);
       setTotalOperations(data.total || 0);
       setStatusCounts(data.statusCounts || {
         created: 0,
@@ -771,8 +772,8 @@ function FileOperations() {
         setBulkDeleteDialogOpen(false);
       }
     } catch (error: any) {
-      console.error('Failed to delete skipped files:', error.response?.data?.error || error.message);
-      setError(error.response?.data?.error || error.message || 'Failed to delete skipped files');
+      console.error('Failed to delete skipped files:', error.response?.data?.message || error.message);
+      setError(error.response?.data?.message || error.message || 'Failed to delete skipped files');
     } finally {
       setBulkDeleteLoading(false);
     }
@@ -914,7 +915,7 @@ function FileOperations() {
           }
         } catch (error: any) {
           console.error('Failed to delete files with destination paths:', error);
-          errors.push(`Failed to delete ${filesWithDestination.length} files: ${error.response?.data?.error || error.message}`);
+          errors.push(`Failed to delete ${filesWithDestination.length} files: ${error.response?.data?.message || error.message}`);
         }
       }
 
@@ -931,7 +932,7 @@ function FileOperations() {
           }
         } catch (error: any) {
           console.error('Failed to delete database records:', error);
-          errors.push(`Failed to delete ${filesWithoutDestination.length} database records: ${error.response?.data?.error || error.message}`);
+          errors.push(`Failed to delete ${filesWithoutDestination.length} database records: ${error.response?.data?.message || error.message}`);
           }
         }
       }
@@ -970,8 +971,8 @@ function FileOperations() {
       setSelectedFiles(new Set());
       setBulkDeleteDialogOpen(false);
     } catch (error: any) {
-      console.error('Failed to delete selected files:', error.response?.data?.error || error.message);
-      setError(error.response?.data?.error || error.message || 'Failed to delete selected files');
+      console.error('Failed to delete selected files:', error.response?.data?.message || error.message);
+      setError(error.response?.data?.message || error.message || 'Failed to delete selected files');
     } finally {
       setBulkActionLoading(false);
     }
@@ -133,8 +133,8 @@ const JobsTable: React.FC<JobsTableProps> = ({ onRefresh: _ }) => {
           )
         );
 
-        // If job completed or failed, refresh the full job list to get updated timers
-        if (data.status === 'completed' || data.status === 'failed') {
+        // If job finished, refresh the full job list to get updated timers
+        if (data.status === 'completed' || data.status === 'failed' || data.status === 'cancelled') {
           setTimeout(() => fetchJobs(false), 1000);
         }
       }
@@ -163,7 +163,7 @@ export default function useSeasonFolders({ data, folderName, currentPath, mediaT
       setSnackbar({ open: true, message: 'File renamed', severity: 'success' });
       fetchSeasonFolders();
     } catch (e: any) {
-      setSnackbar({ open: true, message: e?.response?.data || 'Rename failed', severity: 'error' });
+      setSnackbar({ open: true, message: e?.response?.data?.message || 'Rename failed', severity: 'error' });
     }
   };
   const handleDeleteConfirm = async () => {
@@ -159,11 +159,8 @@ export function AuthProvider({ children }: { children: ReactNode }) {
       const response = await axios.post('/api/auth/login', { username, password });
       if (response.status === 200 && response.data.token) {
         localStorage.setItem('cineSyncJWT', response.data.token);
-        // Fetch user info after login
-        const meRes = await axios.get('/api/me', {
-          headers: { Authorization: `Bearer ${response.data.token}` },
-        });
-        setUser(meRes.data);
+        // The login response carries the user profile
+        setUser({ username: response.data.username });
         setIsAuthenticated(true);
         // Trigger SSE reconnection with new token
         triggerSSEReconnection();
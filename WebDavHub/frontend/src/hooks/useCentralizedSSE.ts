@@ -14,6 +14,14 @@ export interface SSEConnectionState {
   reconnectAttempts: number;
 }
 
+// The server closes event streams that are not pinged within its idle timeout
+// (CINESYNC_SSE_IDLE_TIMEOUT, 2 minutes by default)
+const SSE_PING_INTERVAL_MS = 30000;
+
+function newSessionId(): string {
+  return Math.random().toString(36).slice(2) + Date.now().toString(36);
+}
+
 export interface SSEEventHandler {
   id: string;
   eventTypes: string[];
@@ -35,6 +43,7 @@ export function useCentralizedSSE() {
   });
 
   const eventSourcesRef = useRef<Map<string, EventSource>>(new Map());
+  const sessionIdsRef = useRef<Map<EventSource, string>>(new Map());
   const eventHandlersRef = useRef<Map<string, SSEEventHandler>>(new Map());
   const reconnectTimeoutRef = useRef<NodeJS.Timeout | null>(null);
 
@@ -71,6 +80,7 @@ export function useCentralizedSSE() {
         console.log(`Cleaning up unused SSE connection for ${source}`);
         eventSource.close();
         eventSourcesRef.current.delete(source);
+        sessionIdsRef.current.delete(eventSource);
       }
     });
 
@@ -113,12 +123,16 @@ export function useCentralizedSSE() {
 
     try {
       const token = localStorage.getItem('cineSyncJWT');
-      const eventSourceUrl = token
-        ? `${endpoint}?token=${encodeURIComponent(token)}`
-        : endpoint;
+      const sessionId = newSessionId();
+      const params = new URLSearchParams({ session: sessionId });
+      if (token) {
+        params.set('token', token);
+      }
+      const eventSourceUrl = `${endpoint}?${params.toString()}`;
 
       const eventSource = new EventSource(eventSourceUrl);
       eventSourcesRef.current.set(source, eventSource);
+      sessionIdsRef.current.set(eventSource, sessionId);
 
       eventSource.onopen = () => {
         console.log(`SSE connection established for ${source}`);
@@ -161,6 +175,7 @@ export function useCentralizedSSE() {
       eventSource.onerror = (error) => {
         console.warn(`SSE connection error for ${source}:`, error);
         eventSourcesRef.current.delete(source);
+        sessionIdsRef.current.delete(eventSource);
         updateConnectionState();
       };
 
@@ -186,6 +201,30 @@ export function useCentralizedSSE() {
     };
   }, [connectToSource, cleanupUnusedConnections]);
 
+  // Keep open streams alive; streams of a suspended tab stop pinging and are
+  // closed by the server
+  useEffect(() => {
+    const interval = setInterval(() => {
+      const sessions = Array.from(sessionIdsRef.current.values());
+      if (sessions.length === 0) {
+        return;
+      }
+      const token = localStorage.getItem('cineSyncJWT');
+      fetch('/api/events/ping', {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+          ...(token ? { Authorization: `Bearer ${token}` } : {})
+        },
+        body: JSON.stringify({ sessions })
+      }).catch(error => {
+        console.warn('Failed to ping SSE sessions:', error);
+      });
+    }, SSE_PING_INTERVAL_MS);
+
+    return () => clearInterval(interval);
+  }, []);
+
   // Cleanup on unmount
   useEffect(() => {
     return () => {
@@ -193,6 +232,7 @@ export function useCentralizedSSE() {
         eventSource.close();
       });
       eventSourcesRef.current.clear();
+      sessionIdsRef.current.clear();
 
       if (reconnectTimeoutRef.current) {
         clearTimeout(reconnectTimeoutRef.current);
@@ -210,6 +250,7 @@ export function useCentralizedSSE() {
         eventSource.close();
       });
       eventSourcesRef.current.clear();
+      sessionIdsRef.current.clear();
 
       activeSources.forEach(source => {
         connectToSource(source);
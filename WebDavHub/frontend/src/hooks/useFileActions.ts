@@ -72,7 +72,7 @@ export function useFileActions({ currentPath, onRename, onDeleted, onModify }: U
       setFileBeingRenamed(null);
       if (onRename) onRename(file);
     } catch (error: any) {
-      setRenameError(error.response?.data || error.message || 'Failed to rename file');
+      setRenameError(error.response?.data?.message || error.message || 'Failed to rename file');
       setRenameLoading(false);
     }
   };
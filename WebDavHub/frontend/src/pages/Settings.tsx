@@ -45,6 +45,7 @@ const Settings: React.FC = () => {
   const theme = useTheme();
 
   const [config, setConfig] = useState<ConfigValue[]>([]);
+  const [configETag, setConfigETag] = useState<string | null>(null);
   const [loading, setLoading] = useState(true);
   const [saving, setSaving] = useState(false);
   const [error, setError] = useState<string | null>(null);
@@ -258,6 +259,7 @@ const Settings: React.FC = () => {
       });
       const data: ConfigResponse = response.data;
       setConfig(data.config);
+      setConfigETag(response.headers['etag'] || null);
     } catch (err) {
       setError(err instanceof Error ? err.message : 'Failed to load configuration');
     } finally {
@@ -302,7 +304,9 @@ const Settings: React.FC = () => {
         };
       });
 
-      const response = await axios.post('/api/config/update', { updates });
+      const response = await axios.post('/api/config/update', { updates }, {
+        headers: configETag ? { 'If-Match': configETag } : {}
+      });
 
       if (response.status !== 200) {
         throw new Error('Failed to save configuration');
@@ -325,6 +329,11 @@ const Settings: React.FC = () => {
       setSuccess('Configuration saved successfully');
       setShowConfirmDialog(false);
     } catch (err) {
+      if (axios.isAxiosError(err) && err.response?.status === 412) {
+        setError('Configuration was changed elsewhere. Reloaded the latest values, please review and save again.');
+        await fetchConfig();
+        return;
+      }
       setError(err instanceof Error ? err.message : 'Failed to save configuration');
     } finally {
       setSaving(false);
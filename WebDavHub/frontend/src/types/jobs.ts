@@ -1,5 +1,6 @@
 // Job management types for WebDavHub
 import { useState, useEffect } from 'react';
+import { PagedResponse } from './paging';
 
 export interface Job {
   id: string;
@@ -67,11 +68,23 @@ export interface JobsResponse {
   status: string;
 }
 
-export interface JobExecutionResponse {
-  executions: JobExecution[];
+export interface JobExecutionResponse extends PagedResponse<JobExecution> {
   status: string;
 }
 
+// A job_update event from /api/jobs/events
+export interface JobUpdateEvent {
+  type: 'job_update';
+  event: 'started' | 'progress' | 'completed' | 'failed' | 'cancelled' | 'updated';
+  jobId: string;
+  status: JobStatus;
+  message: string;
+  timestamp: string;
+  executionId?: string;
+  outputLines?: number;
+  elapsedMs?: number;
+}
+
 // Helper functions for job status and type display
 export const getJobStatusColor = (status: JobStatus): string => {
   switch (status) {
@@ -0,0 +1,12 @@
+// Paging envelope shared by the WebDavHub list endpoints
+export interface PagedResponse<T> {
+  items: T[];
+  total: number;
+  page: number;
+  pageSize: number;
+  nextCursor?: string;
+}
+
+// Number of pages a paged response spans, at least one
+export const pageCount = (response: Pick<PagedResponse<unknown>, 'total' | 'pageSize'>): number =>
+  Math.max(1, Math.ceil((response.total || 0) / (response.pageSize || 1)));
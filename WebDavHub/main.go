@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -11,57 +10,28 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
-	"runtime"
 	"strings"
 	"syscall"
 	"time"
 
 	"cinesync/pkg/api"
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/cache"
 	"cinesync/pkg/config"
 	"cinesync/pkg/auth"
 	"cinesync/pkg/db"
+	"cinesync/pkg/diskspace"
 	"cinesync/pkg/env"
 	"cinesync/pkg/logger"
+	"cinesync/pkg/middleware"
 	"cinesync/pkg/server"
 	"cinesync/pkg/spoofing"
+	"cinesync/pkg/sse"
 	"cinesync/pkg/webdav"
 
 	"github.com/joho/godotenv"
 )
 
-// globalPanicRecoveryMiddleware provides top-level panic recovery for the entire server
-func globalPanicRecoveryMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				logger.Error("Global panic recovered for %s %s: %v", r.Method, r.URL.Path, err)
-
-				buf := make([]byte, 1024)
-				for {
-					n := runtime.Stack(buf, false)
-					if n < len(buf) {
-						buf = buf[:n]
-						break
-					}
-					buf = make([]byte, 2*len(buf))
-				}
-				logger.Error("Stack trace: %s", string(buf))
-
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusInternalServerError)
-				response := map[string]interface{}{
-					"error":   "Internal Server Error",
-					"message": "Service temporarily unavailable",
-					"status":  500,
-				}
-				json.NewEncoder(w).Encode(response)
-			}
-		}()
-
-		next.ServeHTTP(w, r)
-	})
-}
-
 // getNetworkIP returns the local network IP address
 func getNetworkIP() string {
 	conn, err := net.Dial("udp", "8.8.8.8:80")
@@ -74,7 +44,8 @@ func getNetworkIP() string {
 	return localAddr.IP.String()
 }
 
-// handleMediaCover serves poster and fanart images from the MediaCover directory
+// handleMediaCover serves poster, fanart and banner images from the artwork
+// cache and other files from the MediaCover directory
 func handleMediaCover(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/MediaCover/")
 	if path == "" {
@@ -82,6 +53,11 @@ func handleMediaCover(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Artwork is served from the artwork cache, fetched on first request
+	if tmdbID, fileName, ok := strings.Cut(path, "/"); ok && api.ServeMediaCover(w, r, tmdbID, fileName) {
+		return
+	}
+
 	// Construct the full file path
 	filePath := filepath.Join("../db", "MediaCover", path)
 
@@ -93,36 +69,15 @@ func handleMediaCover(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		http.NotFound(w, r)
-		return
-	}
-
-	// Set appropriate content type based on file extension
-	ext := strings.ToLower(filepath.Ext(filePath))
-	switch ext {
-	case ".jpg", ".jpeg":
-		w.Header().Set("Content-Type", "image/jpeg")
-	case ".png":
-		w.Header().Set("Content-Type", "image/png")
-	case ".webp":
-		w.Header().Set("Content-Type", "image/webp")
-	default:
-		w.Header().Set("Content-Type", "application/octet-stream")
-	}
-
-	// Set cache headers for better performance
-	w.Header().Set("Cache-Control", "public, max-age=86400") // Cache for 24 hours
-
-	// Serve the file
-	http.ServeFile(w, r, filePath)
+	// Serve the file, cacheable for 24 hours
+	api.ServeImageFile(w, r, filePath)
 }
 
 func main() {
 	// Load .env from one directory above
 	dotenvPath := filepath.Join("..", ".env")
 	_ = godotenv.Load(dotenvPath)
+	env.ApplyProfile()
 
 	// Initialize logger early so we can use it for warnings
 	logger.Init()
@@ -181,6 +136,14 @@ func main() {
 		logger.Fatal("Failed to initialize server: %v", err)
 	}
 
+	// Requests are answered with 503 until the database and initial scan are ready
+	readinessTimeout, err := time.ParseDuration(env.GetString("CINESYNC_READINESS_TIMEOUT", "10m"))
+	if err != nil {
+		logger.Warn("Invalid CINESYNC_READINESS_TIMEOUT, using 10m: %v", err)
+		readinessTimeout = 10 * time.Minute
+	}
+	middleware.MarkReadyAfter(readinessTimeout)
+
 	// Set the root directory for file operations
 	api.SetRootDir(effectiveRootDir)
 
@@ -190,9 +153,56 @@ func main() {
 	projectDir := ".."
 	api.InitializeImageCache(projectDir)
 
+	// Cache posters and fanart locally for MediaCover requests
+	api.InitArtwork()
+
 	// Initialize job manager
 	api.InitJobManager()
 
+	// Start watching source directories when CINESYNC_WATCH is enabled
+	api.InitSourceWatcher()
+
+	// Purge pruned records whose trash retention has ended
+	db.StartTrashPurger()
+
+	// Pick up edits to the users file without a restart
+	auth.StartUsersWatcher()
+
+	// Share login throttling state between replicas when asked to
+	switch env.GetString("CINESYNC_RATE_LIMIT_STORE", "memory") {
+	case "sqlite":
+		limitDB := env.GetString("CINESYNC_RATE_LIMIT_DB", filepath.Join(projectDir, "db", "auth_limits.db"))
+		if store, err := db.NewSQLiteLimitStore(limitDB); err != nil {
+			logger.Warn("Falling back to in-memory login throttling: %v", err)
+		} else {
+			auth.SetLimitStore(store)
+			logger.Info("Using shared login throttling state in %s", limitDB)
+		}
+	case "redis":
+		redisURL := env.GetString("CINESYNC_REDIS_URL", "")
+		if redisURL == "" {
+			logger.Warn("Falling back to in-memory login throttling: CINESYNC_REDIS_URL is not set")
+		} else if redisCache, err := cache.NewRedisCache(redisURL); err != nil {
+			logger.Warn("Falling back to in-memory login throttling: %v", err)
+		} else {
+			auth.SetLimitStore(cache.NewRedisLimitStore(redisCache))
+			logger.Info("Using shared login throttling state in Redis")
+		}
+	}
+
+	// Keep the audit log on disk unless asked to keep it in memory
+	if env.GetString("CINESYNC_AUDIT_STORE", "sqlite") == "sqlite" {
+		auditDB := env.GetString("CINESYNC_AUDIT_DB", filepath.Join(projectDir, "db", "audit.db"))
+		if store, err := db.NewSQLiteAuditStore(auditDB); err != nil {
+			logger.Warn("Falling back to an in-memory audit log: %v", err)
+		} else {
+			auth.SetAuditStore(store)
+		}
+	}
+
+	// Connect the shared cache now so a bad CINESYNC_REDIS_URL shows at startup
+	cache.Default()
+
 	// Create a new mux for API routes
 	apiMux := http.NewServeMux()
 	apiMux.HandleFunc("/api/health", api.HandleHealth)
@@ -201,13 +211,29 @@ func main() {
 	apiMux.HandleFunc("/api/source-browse/", api.HandleSourceFiles)
 	apiMux.HandleFunc("/api/stream/", api.HandleStream)
 	apiMux.HandleFunc("/api/stats", api.HandleStats)
+	apiMux.HandleFunc("/api/stats/access", db.HandleAccessStats)
+	apiMux.HandleFunc("/api/stats/http", api.HandleHTTPStats)
+	apiMux.HandleFunc("/api/activity", api.HandleActivity)
+	apiMux.HandleFunc("/api/diagnostics", api.HandleDiagnostics)
 	apiMux.HandleFunc("/api/auth/test", api.HandleAuthTest)
 	apiMux.HandleFunc("/api/auth/enabled", api.HandleAuthEnabled)
+	apiMux.HandleFunc("/api/branding", api.HandleBranding)
 	apiMux.HandleFunc("/api/auth/login", auth.HandleLogin)
+	apiMux.HandleFunc("/api/auth/logout", auth.HandleLogout)
 	apiMux.HandleFunc("/api/auth/check", auth.HandleAuthCheck)
+	apiMux.HandleFunc("/api/auth/invite", auth.HandleInvite)
+	apiMux.HandleFunc("/api/auth/register", auth.HandleRegister)
+	apiMux.HandleFunc("/api/auth/change-password", auth.HandleChangePassword)
+	apiMux.HandleFunc("/api/auth/users/reload", auth.HandleReloadUsers)
+	apiMux.HandleFunc("/api/auth/audit", auth.HandleAudit)
+	apiMux.HandleFunc("/api/auth/saml/metadata", auth.HandleSAMLMetadata)
+	apiMux.HandleFunc("/api/auth/saml/login", auth.HandleSAMLLogin)
+	apiMux.HandleFunc("/api/auth/saml/acs", auth.HandleSAMLACS)
 	apiMux.HandleFunc("/api/readlink", api.HandleReadlink)
 	apiMux.HandleFunc("/api/delete", api.HandleDelete)
 	apiMux.HandleFunc("/api/restore-symlinks", api.HandleRestoreSymlinks)
+	apiMux.HandleFunc("/api/maintenance/symlink-manifest", api.HandleSymlinkManifest)
+	apiMux.HandleFunc("/api/maintenance/symlink-manifest/restore", api.HandleSymlinkManifestRestore)
 	apiMux.HandleFunc("/api/rename", api.HandleRename)
 	apiMux.HandleFunc("/api/download", api.HandleDownload)
 	apiMux.HandleFunc("/api/me", auth.HandleMe)
@@ -216,7 +242,10 @@ func main() {
 	apiMux.HandleFunc("/api/tmdb/category-content", api.WithTmdbValidation(api.HandleTmdbCategoryContent))
 	apiMux.HandleFunc("/api/file-details", api.HandleFileDetails)
 	apiMux.HandleFunc("/api/tmdb-cache", api.HandleTmdbCache)
+	apiMux.HandleFunc("/api/metadata/test", api.HandleMetadataTest)
+	apiMux.HandleFunc("/api/notifications/test", api.HandleNotificationTest)
 	apiMux.HandleFunc("/api/image-cache", api.HandleImageCache)
+	apiMux.HandleFunc("/api/image-proxy", api.HandleImageProxy)
 	apiMux.HandleFunc("/api/MediaCover/", spoofing.HandleMediaCover)
 
 	apiMux.HandleFunc("/api/python-bridge", api.HandlePythonBridge)
@@ -227,19 +256,42 @@ func main() {
 	apiMux.HandleFunc("/api/mediahub/events", api.HandleMediaHubEvents)
 	apiMux.HandleFunc("/api/recent-media", api.HandleRecentMedia)
 	apiMux.HandleFunc("/api/file-operations", db.HandleFileOperations)
-	apiMux.HandleFunc("/api/file-operations/bulk", db.HandleFileOperations)
+	apiMux.HandleFunc("/api/file-operations/bulk", diskspace.Guard(db.HandleFileOperations))
 	apiMux.HandleFunc("/api/file-operations/events", db.HandleFileOperationEvents)
+	apiMux.HandleFunc("/api/file-operations/", db.HandleOperationBatch)
 	apiMux.HandleFunc("/api/database/source-files", db.HandleSourceFiles)
+	apiMux.HandleFunc("/api/database/source-files/lock", db.HandleSourceFileLock)
 	apiMux.HandleFunc("/api/database/source-scans", db.HandleSourceScans)
+	apiMux.HandleFunc("/api/database/source-scans/", db.HandleSourceScanDiff)
 	apiMux.HandleFunc("/api/dashboard/events", db.HandleDashboardEvents)
+	apiMux.HandleFunc("/api/dashboard/recent", api.HandleRecentlyAdded)
 	apiMux.HandleFunc("/api/database/search", db.HandleDatabaseSearch)
+	apiMux.HandleFunc("/api/search", api.HandleUnifiedSearch)
+	apiMux.HandleFunc("/api/library/monitored", api.HandleTitleMonitoring)
+	apiMux.HandleFunc("/api/collections", api.HandleCollections)
+	apiMux.HandleFunc("/api/collections/refresh", api.HandleCollectionsRefresh)
+	apiMux.HandleFunc("/api/maintenance/reidentify", api.HandleReidentify)
+	apiMux.HandleFunc("/api/maintenance/reconcile", db.HandleReconcile)
 	apiMux.HandleFunc("/api/database/stats", db.HandleDatabaseStats)
+	apiMux.HandleFunc("/api/database/pool-stats", db.HandleDatabasePoolStats)
 	apiMux.HandleFunc("/api/database/export", db.HandleDatabaseExport)
+	apiMux.HandleFunc("/api/database/import", db.HandleDatabaseImport)
 	apiMux.HandleFunc("/api/database/update", db.HandleDatabaseUpdate)
-	apiMux.HandleFunc("/api/config", config.HandleGetConfig)
+	apiMux.HandleFunc("/api/database/bulk-edit", db.HandleDatabaseBulkEdit)
+	apiMux.HandleFunc("/api/database/prune", db.HandleDatabasePrune)
+	apiMux.HandleFunc("/api/database/trash", db.HandleTrash)
+	apiMux.HandleFunc("/api/database/trash/restore", db.HandleTrashRestore)
+	apiMux.HandleFunc("/api/database/unmatched", api.HandleUnmatched)
+	apiMux.HandleFunc("/api/database/unmatched/match", api.HandleUnmatchedMatch)
+	apiMux.HandleFunc("/api/database/unmatched/ignore", api.HandleUnmatchedIgnore)
+	apiMux.HandleFunc("/api/config", config.HandleConfig)
 	apiMux.HandleFunc("/api/config/update", config.HandleUpdateConfig)
 	apiMux.HandleFunc("/api/config/update-silent", config.HandleUpdateConfigSilent)
 	apiMux.HandleFunc("/api/config/events", config.HandleConfigEvents)
+	apiMux.HandleFunc("/api/events/ping", sse.HandlePing)
+	apiMux.HandleFunc("/api/config/schema", config.HandleConfigSchema)
+	apiMux.HandleFunc("/api/config/effective", config.HandleEffectiveConfig)
+	apiMux.HandleFunc("/api/errors", apierror.HandleCodes)
 	apiMux.HandleFunc("/api/restart", api.HandleRestart)
 
 	// Processing endpoints
@@ -264,6 +316,10 @@ func main() {
 		api.HandleJobsRouter(w, r)
 	})
 
+	apiMux.HandleFunc("/api/schedule", api.HandleSchedule)
+	apiMux.HandleFunc("/api/import/arr", api.HandleArrImport)
+	apiMux.HandleFunc("/api/scan/trigger", db.HandleScanTrigger)
+
 	// Spoofing configuration endpoints with mux in context
 	apiMux.HandleFunc("/api/spoofing/config", func(w http.ResponseWriter, r *http.Request) {
 		ctx := context.WithValue(r.Context(), "mux", apiMux)
@@ -290,12 +346,14 @@ func main() {
 		// For all /api/ paths, apply JWT middleware if CINESYNC_AUTH_ENABLED is true
 		authRequired := env.IsBool("CINESYNC_AUTH_ENABLED", true)
 		if authRequired {
-			auth.JWTMiddleware(apiMux).ServeHTTP(w, r) // JWTMiddleware wraps the entire apiMux for protected routes
+			auth.JWTMiddleware(auth.CSRFMiddleware(apiMux)).ServeHTTP(w, r) // JWTMiddleware wraps the entire apiMux for protected routes
 		} else {
 			apiMux.ServeHTTP(w, r)
 		}
 	})
-	rootMux.Handle("/api/", apiRouter)
+	// The liveness probe skips the API chain: readiness, auth and stats
+	rootMux.HandleFunc(middleware.LivenessPath, middleware.HandleLiveness)
+	rootMux.Handle("/api/", middleware.HTTPStats(middleware.ServerTiming(middleware.RequireReady(middleware.LimitRequestBody(middleware.Compress(apiRouter))))))
 
 	// SignalR Handler (for spoofing endpoints)
 	signalrRouter := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -303,8 +361,21 @@ func main() {
 	})
 	rootMux.Handle("/signalr/", signalrRouter)
 
-	// WebDAV Handler
-	rootMux.Handle("/webdav/", auth.BasicAuthMiddleware(http.StripPrefix("/webdav", webdavHandler)))
+	// WebDAV Handler. With a dedicated WebDAV listener the share is served only
+	// there and the API listener refuses WebDAV methods.
+	webdavPrefix := webdav.MountPrefix()
+	webdavRoute := auth.BasicAuthMiddleware(http.StripPrefix(webdavPrefix, webdavHandler))
+	webdavAddr := webdavListenAddr(*ip, *port)
+	var webdavMux *http.ServeMux
+	if webdavAddr != "" {
+		webdavMux = http.NewServeMux()
+		webdavMux.Handle(webdavPrefix+"/", webdavRoute)
+		webdavMux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Only WebDAV is served on this listener", http.StatusNotFound)
+		})
+	} else {
+		rootMux.Handle(webdavPrefix+"/", webdavRoute)
+	}
 
 	// MediaCover Handler (no authentication required for poster images)
 	rootMux.HandleFunc("/MediaCover/", handleMediaCover)
@@ -314,7 +385,7 @@ func main() {
 		if r.URL.Path == "/" {
 			logger.Info("Root path / accessed by %s", r.RemoteAddr)
 			w.Header().Set("Content-Type", "text/plain")
-			w.Write([]byte("CineSync Server is active.\nAPI access at /api/\nWebDAV access at /webdav/\n"))
+			w.Write([]byte("CineSync Server is active.\nAPI access at /api/\nWebDAV access at " + webdavPrefix + "/\n"))
 			return
 		}
 		http.NotFound(w, r)
@@ -418,6 +489,7 @@ func main() {
 		<-shutdown
 		logger.Info("Shutting down: stopping job manager and checkpointing SQLite WAL...")
 		api.StopJobManager()
+		api.StopSourceWatcher()
 		if db.DB() != nil {
 			db.DB().Exec("PRAGMA wal_checkpoint(TRUNCATE);")
 			db.DB().Exec("PRAGMA optimize;")
@@ -449,14 +521,67 @@ func main() {
 		logger.Warn("Authentication is disabled")
 	}
 
-	// Wrap the root mux with global panic recovery
+	// Both listeners share one access log so its file is opened once
+	accessLog := middleware.AccessLog()
+
+	// Recover is outermost so a panic anywhere below, the access log included,
+	// still gets a response
+	var apiHandler http.Handler = rootMux
+	if webdavMux != nil {
+		apiHandler = rejectWebDAVMethods(rootMux)
+	}
 	server := &http.Server{
 		Addr:         addr,
-		Handler:      globalPanicRecoveryMiddleware(rootMux),
+		Handler:      middleware.Recover(accessLog(middleware.SecurityHeaders(apiHandler))),
 		ReadTimeout:  60 * time.Second,
 		WriteTimeout: 60 * time.Second,
 		IdleTimeout:  300 * time.Second,
 	}
 
-	log.Fatal(server.ListenAndServe())
+	if webdavMux != nil {
+		webdavServer := &http.Server{
+			Addr:    webdavAddr,
+			Handler: middleware.Recover(accessLog(middleware.SecurityHeaders(webdavMux))),
+			// Only the headers are bounded; uploads extend their own read
+			// deadline while the body keeps arriving
+			ReadHeaderTimeout: 60 * time.Second,
+			IdleTimeout:       300 * time.Second,
+		}
+		logger.Info("WebDAV server started on %s", webdavAddr)
+		go func() {
+			log.Fatal(listenAndServe(webdavServer, "CINESYNC_WEBDAV_TLS_CERT", "CINESYNC_WEBDAV_TLS_KEY"))
+		}()
+	}
+
+	// The redirect listener points at the API, so it only runs when the API is served over TLS
+	if tlsConfigured("CINESYNC_API_TLS_CERT", "CINESYNC_API_TLS_KEY") {
+		startHTTPSRedirect(server.Addr)
+	}
+	log.Fatal(listenAndServe(server, "CINESYNC_API_TLS_CERT", "CINESYNC_API_TLS_KEY"))
+}
+
+// webdavListenAddr returns the address of a dedicated WebDAV listener, or ""
+// when WebDAV shares the API listener
+func webdavListenAddr(apiIP string, apiPort int) string {
+	webdavPort := env.GetInt("CINESYNC_WEBDAV_PORT", 0)
+	webdavIP := env.GetString("CINESYNC_WEBDAV_IP", "")
+	if webdavIP == "" {
+		webdavIP = apiIP
+	}
+	if webdavPort <= 0 || (webdavPort == apiPort && webdavIP == apiIP) {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", webdavIP, webdavPort)
+}
+
+// rejectWebDAVMethods answers 405 for WebDAV-only methods on the API listener
+func rejectWebDAVMethods(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK":
+			http.Error(w, "WebDAV is served on a separate listener", http.StatusMethodNotAllowed)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
@@ -0,0 +1,22 @@
+package activity
+
+import "time"
+
+// Event types recorded in the activity feed
+const (
+	TypeLogin         = "login"
+	TypeScan          = "scan"
+	TypeFileOperation = "file_operation"
+	TypeConfig        = "config"
+	TypeJob           = "job"
+)
+
+// Event is a single entry in the activity feed
+type Event struct {
+	Timestamp time.Time              `json:"timestamp"`
+	Type      string                 `json:"type"`
+	Action    string                 `json:"action"`
+	Actor     string                 `json:"actor,omitempty"`
+	Message   string                 `json:"message"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
@@ -0,0 +1,219 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"cinesync/pkg/activity"
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/db"
+	"cinesync/pkg/logger"
+	"cinesync/pkg/paging"
+)
+
+const (
+	maxActivityPageSize = 500
+	// maxActivityOffset is how deep the feed can be paged. Every source is
+	// read up to the requested page, so the window has to stay bounded.
+	maxActivityOffset = 10000
+)
+
+// HandleActivity returns a time-ordered feed combining logins, scans, file
+// operations, job runs and configuration changes. Logins and configuration
+// changes are read from the audit log.
+// Query parameters: type (comma separated), page or cursor, limit.
+// Sources are only read as far as the requested page, so total counts the
+// events loaded so far rather than the whole history.
+func HandleActivity(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	page := paging.Parse(r, 50, maxActivityPageSize)
+	if page.Offset < 0 || page.Offset > maxActivityOffset {
+		page.Offset = maxActivityOffset
+	}
+
+	types := make(map[string]bool)
+	for _, t := range strings.Split(query.Get("type"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types[t] = true
+		}
+	}
+	wants := func(eventType string) bool {
+		return len(types) == 0 || types[eventType]
+	}
+
+	// Each source only needs to supply enough events to fill the requested page,
+	// plus one to tell whether another page follows
+	window := page.Offset + page.Limit + 1
+	var events []activity.Event
+
+	events = append(events, auditActivity(wants(activity.TypeLogin), wants(activity.TypeConfig), window)...)
+	if wants(activity.TypeScan) {
+		events = append(events, scanActivity(window)...)
+	}
+	if wants(activity.TypeFileOperation) {
+		events = append(events, fileOperationActivity(window)...)
+	}
+	if wants(activity.TypeJob) {
+		events = append(events, jobActivity(window)...)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.After(events[j].Timestamp)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(paging.Slice(events, page))
+}
+
+// auditActivity converts recent logins and configuration changes from the
+// audit log into activity events
+func auditActivity(logins, config bool, limit int) []activity.Event {
+	var types []string
+	if logins {
+		types = append(types, auth.AuditLogin, auth.AuditLoginFailed, auth.AuditRegister)
+	}
+	if config {
+		types = append(types, auth.AuditConfigChange)
+	}
+	if len(types) == 0 {
+		return nil
+	}
+
+	audited, _, err := auth.QueryAudit(auth.AuditFilter{Types: types}, paging.Request{Limit: limit})
+	if err != nil {
+		logger.Warn("Failed to load audit events for activity feed: %v", err)
+		return nil
+	}
+
+	events := make([]activity.Event, 0, len(audited))
+	for _, event := range audited {
+		if event.Type == auth.AuditConfigChange {
+			keys := strings.Split(event.Detail, ",")
+			events = append(events, activity.Event{
+				Timestamp: event.Timestamp,
+				Type:      activity.TypeConfig,
+				Action:    "updated",
+				Actor:     event.Username,
+				Message:   fmt.Sprintf("Configuration updated: %s", strings.Join(keys, ", ")),
+				Details:   map[string]interface{}{"keys": keys},
+			})
+			continue
+		}
+
+		message := fmt.Sprintf("User '%s' logged in", event.Username)
+		switch event.Type {
+		case auth.AuditLoginFailed:
+			message = fmt.Sprintf("Failed login attempt for user '%s'", event.Username)
+		case auth.AuditRegister:
+			message = fmt.Sprintf("User '%s' registered", event.Username)
+		}
+		events = append(events, activity.Event{
+			Timestamp: event.Timestamp,
+			Type:      activity.TypeLogin,
+			Action:    event.Type,
+			Actor:     event.Username,
+			Message:   message,
+			Details:   map[string]interface{}{"remoteAddr": event.IP, "method": event.Detail},
+		})
+	}
+	return events
+}
+
+// scanActivity converts recent source scans into activity events
+func scanActivity(limit int) []activity.Event {
+	scans, err := db.GetRecentSourceScans(limit)
+	if err != nil {
+		logger.Warn("Failed to load scans for activity feed: %v", err)
+		return nil
+	}
+
+	events := make([]activity.Event, 0, len(scans))
+	for _, scan := range scans {
+		timestamp := time.Unix(scan.StartedAt, 0)
+		if scan.CompletedAt != nil {
+			timestamp = time.Unix(*scan.CompletedAt, 0)
+		}
+		events = append(events, activity.Event{
+			Timestamp: timestamp,
+			Type:      activity.TypeScan,
+			Action:    scan.Status,
+			Message:   fmt.Sprintf("%s scan %s: %d discovered, %d updated, %d removed", scan.ScanType, scan.Status, scan.FilesDiscovered, scan.FilesUpdated, scan.FilesRemoved),
+			Details: map[string]interface{}{
+				"scanId":     scan.ID,
+				"scanType":   scan.ScanType,
+				"totalFiles": scan.TotalFiles,
+			},
+		})
+	}
+	return events
+}
+
+// fileOperationActivity converts recent MediaHub file operations into activity events
+func fileOperationActivity(limit int) []activity.Event {
+	operations, err := db.GetRecentFileOperations(limit)
+	if err != nil {
+		logger.Warn("Failed to load file operations for activity feed: %v", err)
+		return nil
+	}
+
+	events := make([]activity.Event, 0, len(operations))
+	for _, op := range operations {
+		timestamp, err := time.Parse(time.RFC3339, op.Timestamp)
+		if err != nil {
+			continue
+		}
+		events = append(events, activity.Event{
+			Timestamp: timestamp,
+			Type:      activity.TypeFileOperation,
+			Action:    op.Status,
+			Message:   fmt.Sprintf("%s: %s", op.Status, op.FileName),
+			Details: map[string]interface{}{
+				"filePath":        op.FilePath,
+				"destinationPath": op.DestinationPath,
+				"reason":          op.Reason,
+			},
+		})
+	}
+	return events
+}
+
+// jobActivity converts recent job executions into activity events
+func jobActivity(limit int) []activity.Event {
+	if jobManager == nil {
+		return nil
+	}
+
+	var events []activity.Event
+	for _, job := range jobManager.GetJobs() {
+		for _, execution := range jobManager.GetJobExecutions(job.ID, limit) {
+			timestamp := execution.StartTime
+			if execution.EndTime != nil {
+				timestamp = *execution.EndTime
+			}
+			events = append(events, activity.Event{
+				Timestamp: timestamp,
+				Type:      activity.TypeJob,
+				Action:    string(execution.Status),
+				Message:   fmt.Sprintf("Job %s %s", job.Name, execution.Status),
+				Details: map[string]interface{}{
+					"jobId":       job.ID,
+					"executionId": execution.ID,
+					"error":       execution.Error,
+				},
+			})
+		}
+	}
+	return events
+}
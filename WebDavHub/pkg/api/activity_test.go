@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"cinesync/pkg/activity"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/paging"
+)
+
+func getActivity(t *testing.T, query string) paging.PagedResponse[activity.Event] {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	HandleActivity(recorder, httptest.NewRequest(http.MethodGet, "/api/activity?"+query, nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("GET /api/activity?%s answered %d", query, recorder.Code)
+	}
+	var response paging.PagedResponse[activity.Event]
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func TestActivityReadsTheAuditLog(t *testing.T) {
+	t.Setenv("CINESYNC_AUTH_ENABLED", "false")
+	auth.SetAuditStore(auth.NewMemoryAuditStore())
+	t.Cleanup(func() { auth.SetAuditStore(auth.NewMemoryAuditStore()) })
+
+	auth.RecordConfigChange(httptest.NewRequest(http.MethodPut, "/api/config", nil), []string{"SOURCE_DIR", "LOG_LEVEL"})
+
+	response := getActivity(t, "type=config")
+	if len(response.Items) != 1 {
+		t.Fatalf("feed has %d events, want the audited configuration change", len(response.Items))
+	}
+	if event := response.Items[0]; event.Type != activity.TypeConfig || event.Message != "Configuration updated: SOURCE_DIR, LOG_LEVEL" {
+		t.Fatalf("event = %+v", event)
+	}
+}
+
+func TestActivityClampsHugePages(t *testing.T) {
+	t.Setenv("CINESYNC_AUTH_ENABLED", "false")
+
+	for _, page := range []string{"page=" + strconv.Itoa(maxActivityOffset), "page=9223372036854775807", "offset=9223372036854775807"} {
+		if response := getActivity(t, "type=login&"+page); len(response.Items) != 0 {
+			t.Fatalf("%s returned %d events", page, len(response.Items))
+		}
+	}
+}
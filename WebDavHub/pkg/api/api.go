@@ -1,11 +1,15 @@
 package api
 
 import (
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
 	"cinesync/pkg/logger"
+	"cinesync/pkg/middleware"
 	"cinesync/pkg/db"
 	"cinesync/pkg/env"
 	"cinesync/pkg/config"
 	"cinesync/pkg/spoofing"
+	"cinesync/pkg/sse"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -66,6 +70,7 @@ func SetRootDir(dir string) {
 	if err := db.InitTmdbCacheTable(); err != nil {
 		logger.Warn("Failed to initialize TMDB cache table: %v", err)
 	}
+	initialScanPending := false
 	if err := db.InitSourceDB(); err != nil {
 		logger.Warn("Failed to initialize source files DB: %v", err)
 	} else {
@@ -75,7 +80,10 @@ func SetRootDir(dir string) {
 		// Check if this is a new database and trigger initial scan
 		if db.IsNewDatabase() {
 			logger.Info("New source database detected, scheduling initial scan")
+			initialScanPending = true
 			go func() {
+				// The API stays unavailable until the first scan has populated the database
+				defer middleware.MarkReady()
 				time.Sleep(3 * time.Second) // Give the system time to fully initialize
 				if err := db.ScanSourceDirectories("startup"); err != nil {
 					logger.Error("Failed to perform initial scan: %v", err)
@@ -83,6 +91,9 @@ func SetRootDir(dir string) {
 			}()
 		}
 	}
+	if !initialScanPending {
+		middleware.MarkReady()
+	}
 
 	// Initialize folder cache for fast navigation
 	if !isPlaceholderConfig {
@@ -95,6 +106,7 @@ func SetRootDir(dir string) {
 	}
 
 	db.SetFileOperationNotifier(handleFileOperationNotification)
+	db.SetLibraryChangeNotifier(spoofing.InvalidateLibraryCache)
 }
 
 // UpdateRootDir updates the root directory when configuration changes
@@ -206,6 +218,10 @@ type Stats struct {
 	Port         string `json:"port"`
 	TotalMovies  int    `json:"totalMovies"`
 	TotalShows   int    `json:"totalShows"`
+	// EventSubscribers counts open event stream subscribers per stream
+	EventSubscribers map[string]int `json:"eventSubscribers"`
+	// Enrichment reports the metadata enrichment workers
+	Enrichment EnrichmentStats `json:"enrichment"`
 }
 
 type ReadlinkRequest struct {
@@ -229,10 +245,11 @@ type DeleteRequest struct {
 }
 
 type DeleteResponse struct {
-	Success      bool     `json:"success"`
-	Error        string   `json:"error,omitempty"`
-	DeletedCount int      `json:"deletedCount,omitempty"`
-	Errors       []string `json:"errors,omitempty"`
+	Success          bool     `json:"success"`
+	Error            string   `json:"error,omitempty"`
+	DeletedCount     int      `json:"deletedCount,omitempty"`
+	Errors           []string `json:"errors,omitempty"`
+	PermissionFailed int      `json:"permissionFailed,omitempty"`
 }
 
 type RenameRequest struct {
@@ -297,7 +314,9 @@ func HandleHealth(w http.ResponseWriter, r *http.Request) {
 
 	response := map[string]interface{}{
 		"status": "ok",
+		"ready": middleware.IsReady(),
 		"timestamp": time.Now().Unix(),
+		"watcher": SourceWatcherStatus(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -306,8 +325,7 @@ func HandleHealth(w http.ResponseWriter, r *http.Request) {
 
 // HandleConfigStatus returns the configuration status
 func HandleConfigStatus(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
 		return
 	}
 
@@ -542,6 +560,7 @@ func HandleFiles(w http.ResponseWriter, r *http.Request) {
 	logger.Info("Listing directory: %s (API path: %s)", dir, path)
 
 	// Try database-first approach for folder listing with pagination
+	stopDBTiming := middleware.StartTiming(r.Context(), "db")
 	var dbFolders []db.FolderInfo
 	var totalDbFolders int
 	var dbErr error
@@ -558,6 +577,7 @@ func HandleFiles(w http.ResponseWriter, r *http.Request) {
 	} else if searchQuery != "" {
 		dbFolders, totalDbFolders, dbErr = db.SearchFoldersFromDatabase(path, searchQuery, page, limit)
 		if dbErr != nil {
+			stopDBTiming()
 			w.Header().Set("Content-Type", "application/json")
 			w.Header().Set("X-Total-Count", "0")
 			w.Header().Set("X-Page", fmt.Sprintf("%d", page))
@@ -579,6 +599,7 @@ func HandleFiles(w http.ResponseWriter, r *http.Request) {
 			useDatabase = true
 		}
 	}
+	stopDBTiming()
 
 	// Only read filesystem if database is not available AND it's not a search query
 	var entries []os.DirEntry
@@ -595,6 +616,7 @@ func HandleFiles(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Process all entries
+	stopMetadataTiming := middleware.StartTiming(r.Context(), "metadata")
 	var tmdbID string
 	var mediaType string
 
@@ -941,8 +963,8 @@ func HandleFiles(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("X-Limit", fmt.Sprintf("%d", limit))
 	w.Header().Set("X-Total-Pages", fmt.Sprintf("%d", (totalFiles+limit-1)/limit))
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(files)
+	stopMetadataTiming()
+	middleware.WriteJSON(w, r, files)
 }
 
 // HandleSourceFiles handles requests for browsing source directories
@@ -1369,8 +1391,10 @@ func HandleStats(w http.ResponseWriter, r *http.Request) {
 
 	// Return cached stats if they're still valid (unless force refresh requested)
 	if !forceRefresh && !lastStatsUpdate.IsZero() && time.Since(lastStatsUpdate) < statsCacheDuration {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(lastStats)
+		cached := lastStats
+		cached.EventSubscribers = sse.SubscriberCounts()
+		cached.Enrichment = defaultEnrichmentQueue().Stats()
+		middleware.WriteJSON(w, r, cached)
 		return
 	}
 
@@ -1398,7 +1422,9 @@ func HandleStats(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get all stats from MediaHub database - no file system scanning needed
+	stopDBTiming := middleware.StartTiming(r.Context(), "db")
 	totalFiles, totalFolders, totalSize, movieCount, showCount, err := db.GetAllStatsFromDB()
+	stopDBTiming()
 
 	if err != nil {
 		// Set reasonable defaults
@@ -1444,13 +1470,13 @@ func HandleStats(w http.ResponseWriter, r *http.Request) {
 
 	statsScanInProgress = false
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(stats)
+	stats.EventSubscribers = sse.SubscriberCounts()
+	stats.Enrichment = defaultEnrichmentQueue().Stats()
+	middleware.WriteJSON(w, r, stats)
 }
 
 func HandleAuthTest(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
 		return
 	}
 
@@ -1459,8 +1485,7 @@ func HandleAuthTest(w http.ResponseWriter, r *http.Request) {
 }
 
 func HandleAuthEnabled(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
 		return
 	}
 	enabled := true
@@ -1468,7 +1493,7 @@ func HandleAuthEnabled(w http.ResponseWriter, r *http.Request) {
 		enabled = false
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]bool{"enabled": enabled})
+	json.NewEncoder(w).Encode(map[string]interface{}{"enabled": enabled, "queryParam": auth.QueryTokenParam()})
 }
 
 func executeReadlink(path string) (string, error) {
@@ -1484,8 +1509,7 @@ func executeReadlink(path string) (string, error) {
 }
 
 func HandleReadlink(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if !apierror.RequireMethod(w, r, http.MethodPost) {
 		return
 	}
 	var req ReadlinkRequest
@@ -1542,9 +1566,8 @@ func HandleReadlink(w http.ResponseWriter, r *http.Request) {
 func HandleDelete(w http.ResponseWriter, r *http.Request) {
 	logger.Info("Request: %s %s", r.Method, r.URL.Path)
 
-	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+	if !apierror.RequireMethod(w, r, http.MethodPost, http.MethodDelete) {
 		logger.Warn("Invalid method: %s", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -1564,7 +1587,7 @@ func HandleDelete(w http.ResponseWriter, r *http.Request) {
 
 	// Handle bulk deletion if paths array is provided
 	if len(req.Paths) > 0 {
-		handleBulkDelete(w, req.Paths)
+		handleBulkDelete(w, r, req.Paths)
 		return
 	}
 
@@ -1574,8 +1597,7 @@ func HandleDelete(w http.ResponseWriter, r *http.Request) {
 
 // HandleRestoreSymlinks restores files by calling MediaHub's restore functionality
 func HandleRestoreSymlinks(w http.ResponseWriter, r *http.Request) {
-    if r.Method != http.MethodPost {
-        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+    if !apierror.RequireMethod(w, r, http.MethodPost) {
         return
     }
     
@@ -1744,7 +1766,7 @@ func restoreDeletedFileByID(idStr string) bool {
                 }
 
                 if err := restoreFromTrash(foundFile, destinationPath); err != nil {
-                    logger.Warn("Failed to restore episode file from trash: %v", err)
+                    logger.Warn("Failed to restore episode file from trash: %v", db.ClassifyPermissionError(err))
                     return false
                 }
                 
@@ -1759,7 +1781,7 @@ func restoreDeletedFileByID(idStr string) bool {
                 }
             } else {
                 if err := restoreFromTrash(trashPath, destinationPath); err != nil {
-                    logger.Warn("Failed to restore file from trash: %v", err)
+                    logger.Warn("Failed to restore file from trash: %v", db.ClassifyPermissionError(err))
                     return false
                 }
                 logger.Info("Restored file from trash: %s -> %s", trashPath, destinationPath)
@@ -1893,7 +1915,12 @@ func handleSingleDelete(w http.ResponseWriter, relativePath string) {
 
 	trashedPath, moveErr := moveToTrash(path)
 	if moveErr != nil {
+		moveErr = db.ClassifyPermissionError(moveErr)
 		logger.Warn("Error: failed to move to trash %s: %v", path, moveErr)
+		if db.IsPermissionError(moveErr) {
+			http.Error(w, fmt.Sprintf("Failed to move to trash: %v", moveErr), http.StatusForbidden)
+			return
+		}
 		http.Error(w, "Failed to move to trash", http.StatusInternalServerError)
 		return
 	}
@@ -1989,15 +2016,21 @@ func handleSingleDelete(w http.ResponseWriter, relativePath string) {
 	json.NewEncoder(w).Encode(DeleteResponse{Success: true})
 }
 
-// handleBulkDelete handles deletion of multiple files
-func handleBulkDelete(w http.ResponseWriter, paths []string) {
+// handleBulkDelete handles deletion of multiple files. A retry carrying the
+// same Idempotency-Key gets the first run's response.
+func handleBulkDelete(w http.ResponseWriter, r *http.Request, paths []string) {
 	if len(paths) == 0 {
 		logger.Warn("Error: no paths provided for bulk deletion")
 		http.Error(w, "No paths provided", http.StatusBadRequest)
 		return
 	}
+	idempotent, ok := db.BeginIdempotentRequest(w, r, "delete", paths)
+	if !ok {
+		return
+	}
+	defer idempotent.Release()
 
-	var deletedCount int
+	var deletedCount, permissionFailed int
 	var errors []string
 
 	for _, relativePath := range paths {
@@ -2081,6 +2114,10 @@ func handleBulkDelete(w http.ResponseWriter, paths []string) {
 
 		trashedPath, moveErr := moveToTrash(path)
 		if moveErr != nil {
+			moveErr = db.ClassifyPermissionError(moveErr)
+			if db.IsPermissionError(moveErr) {
+				permissionFailed++
+			}
 			errors = append(errors, fmt.Sprintf("Failed to move to trash %s: %v", path, moveErr))
 			continue
 		}
@@ -2193,8 +2230,12 @@ func handleBulkDelete(w http.ResponseWriter, paths []string) {
 	}
 
 	response := DeleteResponse{
-		Success:      success,
-		DeletedCount: deletedCount,
+		Success:          success,
+		DeletedCount:     deletedCount,
+		PermissionFailed: permissionFailed,
+	}
+	if permissionFailed > 0 {
+		logger.Warn("%d of %d deletions failed on permissions, check the ownership and mount options of %s", permissionFailed, len(paths), rootDir)
 	}
 
 	if len(errors) > 0 {
@@ -2206,8 +2247,7 @@ func handleBulkDelete(w http.ResponseWriter, paths []string) {
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	idempotent.WriteJSON(w, http.StatusOK, "", response)
 }
 
 // deleteFromDatabase removes a file record from the MediaHub database if it exists
@@ -2446,36 +2486,35 @@ func isDirectoryEffectivelyEmpty(dirPath string) (isEmpty bool, err error) {
 func HandleRename(w http.ResponseWriter, r *http.Request) {
 	logger.Info("Request: %s %s", r.Method, r.URL.Path)
 
-	if r.Method != http.MethodPost {
+	if !apierror.RequireMethod(w, r, http.MethodPost) {
 		logger.Warn("Invalid method: %s", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		logger.Warn("Error: failed to read request body: %v", err)
-		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Failed to read request body")
 		return
 	}
 
 	var req RenameRequest
 	if err := json.Unmarshal(body, &req); err != nil {
 		logger.Warn("Error: invalid request body: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
 		return
 	}
 
 	if req.OldPath == "" || req.NewName == "" {
 		logger.Warn("Error: missing oldPath or newName")
-		http.Error(w, "oldPath and newName are required", http.StatusBadRequest)
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeFileOpInvalid, "oldPath and newName are required")
 		return
 	}
 
 	cleanOldPath := filepath.Clean(req.OldPath)
 	if cleanOldPath == "." || cleanOldPath == ".." || strings.HasPrefix(cleanOldPath, "..") {
 		logger.Warn("Error: invalid oldPath: %s", cleanOldPath)
-		http.Error(w, "Invalid oldPath", http.StatusBadRequest)
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeFileOpInvalid, "Invalid oldPath")
 		return
 	}
 
@@ -2494,37 +2533,42 @@ func HandleRename(w http.ResponseWriter, r *http.Request) {
 	absOld, err := filepath.Abs(oldFullPath)
 	if err != nil {
 		logger.Warn("Error: failed to get absolute old path: %v", err)
-		http.Error(w, "Invalid oldPath", http.StatusBadRequest)
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeFileOpInvalid, "Invalid oldPath")
 		return
 	}
 	absRoot, err := filepath.Abs(rootDir)
 	if err != nil {
 		logger.Warn("Error: failed to get absolute root path: %v", err)
-		http.Error(w, "Server configuration error", http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Server configuration error")
 		return
 	}
 	if !strings.HasPrefix(absOld, absRoot) {
 		logger.Warn("Error: oldPath outside root directory: %s", absOld)
-		http.Error(w, "Invalid oldPath", http.StatusBadRequest)
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeFileOpInvalid, "Invalid oldPath")
 		return
 	}
 
 	if _, err := os.Stat(oldFullPath); os.IsNotExist(err) {
 		logger.Warn("Error: file or directory not found: %s", oldFullPath)
-		http.Error(w, "File or directory not found", http.StatusNotFound)
+		apierror.WriteError(w, http.StatusNotFound, apierror.CodeFileOpNotFound, "File or directory not found")
 		return
 	}
 
 	if _, err := os.Stat(newFullPath); err == nil {
 		logger.Warn("Error: target already exists: %s", newFullPath)
-		http.Error(w, "Target already exists", http.StatusConflict)
+		apierror.WriteError(w, http.StatusConflict, apierror.CodeFileOpConflict, "Target already exists")
 		return
 	}
 
 	err = os.Rename(oldFullPath, newFullPath)
 	if err != nil {
+		err = db.ClassifyPermissionError(err)
 		logger.Warn("Error: failed to rename %s to %s: %v", oldFullPath, newFullPath, err)
-		http.Error(w, "Failed to rename file or directory", http.StatusInternalServerError)
+		if db.IsPermissionError(err) {
+			apierror.WriteError(w, http.StatusForbidden, apierror.CodeFileOpPermissionDenied, err.Error())
+			return
+		}
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to rename file or directory")
 		return
 	}
 
@@ -2662,7 +2706,7 @@ func HandleTmdbCache(w http.ResponseWriter, r *http.Request) {
 				params.Set("mediaType", mediaType)
 			}
 			tmdbUrl := backendHost + "/api/tmdb/search?" + params.Encode()
-			req, _ := http.NewRequest("GET", tmdbUrl, nil)
+			req, _ := http.NewRequestWithContext(r.Context(), "GET", tmdbUrl, nil)
 			req.Header = r.Header
 			resp, err := httpClientWithTimeout.Do(req)
 			if err != nil || resp.StatusCode != 200 {
@@ -2764,6 +2808,10 @@ func HandleMediaHubMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if message.Type == "symlink_created" || message.Type == "file_deleted" {
+		spoofing.InvalidateLibraryCache()
+	}
+
 	if message.Type == "symlink_created" {
 		handleSymlinkCreated(message.Data)
 	} else if message.Type == "source_file_update" {
@@ -2877,6 +2925,8 @@ func handleSymlinkCreated(data map[string]interface{}) {
 		db.UpdateSourceFileProcessingStatus(sourceFile, "processed", tmdbId, seasonNumber)
 	}
 
+	fetchProcessedArtwork(tmdbId, mediaType)
+
 	// Determine folder name based on media type
 	folderName := "Movies"
 	if mediaType == "tvshow" || mediaType == "tv" {
@@ -3012,7 +3062,7 @@ func handleFileDeleted(data map[string]interface{}) {
 
 	// Track the deletion in file_deletions table for UI display
 	if err := db.TrackFileDeletion(sourcePath, destinationPath, tmdbID, seasonNumber, reason); err != nil {
-		logger.Warn("Failed to track file deletion", "error", err)
+		logger.Warn("Failed to track file deletion: %v", err)
 	}
 
 	// Broadcast SignalR events for external file deletion to notify Bazarr
@@ -3165,11 +3215,13 @@ func HandleMediaHubEvents(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	middleware.AllowOrigin(w, r)
 
 	// Subscribe to MediaHub notifications
 	notificationCh := subscribeToMediaHubUpdates()
 	defer unsubscribeFromMediaHubUpdates(notificationCh)
+	session := sse.Open(r, "mediahub")
+	defer session.Close()
 
 	// Send initial connection message
 	fmt.Fprintf(w, "data: {\"type\":\"connected\",\"timestamp\":%f}\n\n", float64(time.Now().Unix()))
@@ -3185,6 +3237,8 @@ func HandleMediaHubEvents(w http.ResponseWriter, r *http.Request) {
 			if flusher, ok := w.(http.Flusher); ok {
 				flusher.Flush()
 			}
+		case <-session.Done():
+			return
 		case <-r.Context().Done():
 			return
 		}
@@ -3199,20 +3253,23 @@ func HandleRecentMedia(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get recent media from database
+	stopDBTiming := middleware.StartTiming(r.Context(), "db")
 	recentMedia, err := db.GetRecentMedia(10)
+	stopDBTiming()
 	if err != nil {
 		http.Error(w, "Failed to retrieve recent media", http.StatusInternalServerError)
 		return
 	}
 
 	// Get MediaHub database connection to look up base_path
-	mediaHubDB, err := db.GetDatabaseConnection()
+	mediaHubDB, err := db.GetReadConnection()
 	if err != nil {
 		logger.Warn("Failed to get MediaHub database connection for base_path lookup: %v", err)
 	}
 
 	// Convert database format to API format for compatibility
 	// Initialize as empty slice to ensure JSON encodes as [] not null
+	stopMetadataTiming := middleware.StartTiming(r.Context(), "metadata")
 	var result []map[string]interface{}
 	for _, media := range recentMedia {
 		item := map[string]interface{}{
@@ -3259,9 +3316,9 @@ func HandleRecentMedia(w http.ResponseWriter, r *http.Request) {
 
 		result = append(result, item)
 	}
+	stopMetadataTiming()
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	middleware.WriteJSON(w, r, result)
 }
 
 // HandleRestart handles server restart requests
@@ -3327,7 +3384,7 @@ func moveToTrash(fullPath string) (string, error) {
 	// If file already exists in trash, replace it (overwrite)
 	if _, err := os.Stat(trashed); err == nil {
 		if err := os.RemoveAll(trashed); err != nil {
-			return "", fmt.Errorf("failed to remove existing trash file: %v", err)
+			return "", fmt.Errorf("failed to remove existing trash file: %w", err)
 		}
 	}
 
@@ -3335,16 +3392,16 @@ func moveToTrash(fullPath string) (string, error) {
 	if info, err := os.Lstat(absPath); err == nil && info.Mode()&os.ModeSymlink != 0 {
 		linkTarget, err := os.Readlink(absPath)
 		if err != nil {
-			return "", fmt.Errorf("failed to read symlink target: %v", err)
+			return "", fmt.Errorf("failed to read symlink target: %w", err)
 		}
 
 		if err := os.Symlink(linkTarget, trashed); err != nil {
-			return "", fmt.Errorf("failed to create symlink in trash: %v", err)
+			return "", fmt.Errorf("failed to create symlink in trash: %w", err)
 		}
 
 		if err := os.Remove(absPath); err != nil {
 			os.Remove(trashed)
-			return "", fmt.Errorf("failed to remove original symlink: %v", err)
+			return "", fmt.Errorf("failed to remove original symlink: %w", err)
 		}
 
 		return trashed, nil
@@ -3360,16 +3417,16 @@ func restoreFromTrash(trashPath, destinationPath string) error {
 	if info, err := os.Lstat(trashPath); err == nil && info.Mode()&os.ModeSymlink != 0 {
 		linkTarget, err := os.Readlink(trashPath)
 		if err != nil {
-			return fmt.Errorf("failed to read symlink target: %v", err)
+			return fmt.Errorf("failed to read symlink target: %w", err)
 		}
 
 		if err := os.Symlink(linkTarget, destinationPath); err != nil {
-			return fmt.Errorf("failed to create symlink at destination: %v", err)
+			return fmt.Errorf("failed to create symlink at destination: %w", err)
 		}
 
 		if err := os.Remove(trashPath); err != nil {
 			os.Remove(destinationPath)
-			return fmt.Errorf("failed to remove symlink from trash: %v", err)
+			return fmt.Errorf("failed to remove symlink from trash: %w", err)
 		}
 
 		return nil
@@ -3383,6 +3440,8 @@ func restoreFromTrash(trashPath, destinationPath string) error {
 
 // broadcastFileRenameEvents broadcasts SignalR events when files are renamed
 func broadcastFileRenameEvents(oldPath, newPath string) {
+	spoofing.InvalidateLibraryCache()
+
 	if isMovieFile(oldPath) || isMovieFile(newPath) {
 		if movieFile := getMovieFileFromPath(newPath); movieFile != nil {
 			spoofing.BroadcastMovieFileUpdated(movieFile)
@@ -3553,6 +3612,8 @@ func getEpisodeFileFromPath(filePath string) map[string]interface{} {
 
 // broadcastFileDeletionEvents broadcasts SignalR events when files are deleted
 func broadcastFileDeletionEvents(filePath string) {
+	spoofing.InvalidateLibraryCache()
+
 	mediaHubDB, err := db.GetDatabaseConnection()
 	if err != nil {
 		logger.Warn("Failed to get database connection for deletion event: %v", err)
@@ -3618,6 +3679,8 @@ func handleFileOperationNotification(operation, filePath string) {
 }
 
 func broadcastFileAdditionEvents(filePath string) {
+	spoofing.InvalidateLibraryCache()
+
 	mediaHubDB, err := db.GetDatabaseConnection()
 	if err != nil {
 		return
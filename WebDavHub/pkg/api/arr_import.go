@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/arrimport"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/logger"
+)
+
+// HandleArrImport serves POST /api/import/arr. It imports the library of an
+// existing Sonarr or Radarr instance from a {"app", "baseUrl", "apiKey",
+// "pathMappings", "dryRun"} body and responds with a per-item summary. Items
+// that fail do not stop the import.
+func HandleArrImport(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	var request arrimport.Request
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if err := request.Validate(); err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	summary, err := arrimport.Import(r.Context(), request)
+	if err != nil {
+		logger.Error("Failed to import %s library: %v", request.App, err)
+		if errors.Is(err, arrimport.ErrUpstream) {
+			apierror.WriteError(w, http.StatusBadGateway, apierror.CodeImportUpstream, err.Error())
+			return
+		}
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
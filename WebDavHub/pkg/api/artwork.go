@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"time"
+
+	"cinesync/pkg/artwork"
+	"cinesync/pkg/db"
+)
+
+// artworkFetchTimeout bounds the artwork download started for a processed file
+const artworkFetchTimeout = 2 * time.Minute
+
+// InitArtwork installs the artwork cache, downloading from TMDB with the
+// configured API key and resolving the media type of titles from the library
+func InitArtwork() {
+	artwork.SetDefault(artwork.NewCache(artwork.NewTMDBProvider(getTmdbApiKey), db.GetTitleMediaType))
+}
+
+// fetchProcessedArtwork queues a download of the configured artwork of a title
+// MediaHub has just linked on the enrichment workers, so the message is
+// answered at once
+func fetchProcessedArtwork(tmdbID, mediaType string) {
+	cache := artwork.Default()
+	if cache == nil || tmdbID == "" || !artwork.DownloadEnabled() {
+		return
+	}
+	title := artwork.Title{ID: tmdbID, MediaType: artwork.MediaMovie}
+	if mediaType == "tvshow" || mediaType == "tv" {
+		title.MediaType = artwork.MediaTV
+	}
+	defaultEnrichmentQueue().Enqueue(enrichmentTask{
+		name:    "artwork " + tmdbID,
+		timeout: artworkFetchTimeout,
+		run: func(ctx context.Context) error {
+			cache.Fetch(ctx, title, artwork.Types())
+			return nil
+		},
+	})
+}
+
+// ServeMediaCover serves a MediaCover image, <tmdb id>/<type>[-<width>].jpg,
+// from the artwork cache, downloading it first when it is not cached yet. It
+// reports false without writing anything for names that are not artwork.
+func ServeMediaCover(w http.ResponseWriter, r *http.Request, tmdbID, fileName string) bool {
+	artworkType, width, ok := artwork.ParseFileName(path.Base(fileName))
+	cache := artwork.Default()
+	if !ok || cache == nil {
+		return false
+	}
+	localPath, err := cache.Get(r.Context(), artwork.Title{ID: tmdbID}, artworkType, width)
+	if err != nil {
+		http.NotFound(w, r)
+		return true
+	}
+	ServeImageFile(w, r, localPath)
+	return true
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+)
+
+const (
+	defaultBrandName     = "CineSync"
+	defaultLoginRedirect = "/dashboard"
+)
+
+// Branding is the body of GET /api/branding
+type Branding struct {
+	AppName       string `json:"appName"`
+	LogoURL       string `json:"logoUrl,omitempty"`
+	LoginRedirect string `json:"loginRedirect"`
+}
+
+// GetBranding returns the configured branding with defaults for unset values
+func GetBranding() Branding {
+	branding := Branding{
+		AppName:       strings.TrimSpace(env.GetString("CINESYNC_BRAND_NAME", defaultBrandName)),
+		LogoURL:       strings.TrimSpace(env.GetString("CINESYNC_BRAND_LOGO_URL", "")),
+		LoginRedirect: strings.TrimSpace(env.GetString("CINESYNC_LOGIN_REDIRECT", defaultLoginRedirect)),
+	}
+	if branding.AppName == "" {
+		branding.AppName = defaultBrandName
+	}
+	if !isLocalRedirect(branding.LoginRedirect) {
+		if branding.LoginRedirect != "" {
+			logger.Warn("Ignoring CINESYNC_LOGIN_REDIRECT %q, it must be a path within CineSync", branding.LoginRedirect)
+		}
+		branding.LoginRedirect = defaultLoginRedirect
+	}
+	return branding
+}
+
+// isLocalRedirect reports whether target is a path on this site, so a
+// misconfigured redirect cannot send users to another host after login
+func isLocalRedirect(target string) bool {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.Contains(target, "\\") {
+		return false
+	}
+	parsed, err := url.Parse(target)
+	return err == nil && parsed.Scheme == "" && parsed.Host == ""
+}
+
+// HandleBranding serves GET /api/branding. It is public because the login
+// page needs the app name and logo before anyone has signed in.
+func HandleBranding(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(GetBranding())
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/db"
+	"cinesync/pkg/logger"
+)
+
+// CollectionRefreshStatus reports the progress of a collection refresh
+type CollectionRefreshStatus struct {
+	Running    bool       `json:"running"`
+	Total      int        `json:"total"`
+	Processed  int        `json:"processed"`
+	Failed     int        `json:"failed"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+var (
+	collectionRefreshMu     sync.Mutex
+	collectionRefreshStatus CollectionRefreshStatus
+)
+
+// tmdbMovieCollection is the part of TMDB movie details naming its collection
+type tmdbMovieCollection struct {
+	BelongsToCollection *struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"belongs_to_collection"`
+}
+
+// recordMovieCollection stores the collection membership found in the TMDB
+// details of a movie, so it follows every refresh of the metadata
+func recordMovieCollection(tmdbID string, details []byte) error {
+	id, err := strconv.Atoi(tmdbID)
+	if err != nil {
+		return fmt.Errorf("invalid tmdb id %q", tmdbID)
+	}
+
+	var movie tmdbMovieCollection
+	if err := json.Unmarshal(details, &movie); err != nil {
+		return err
+	}
+	if movie.BelongsToCollection == nil {
+		return db.SetMovieCollection(id, nil)
+	}
+	return db.SetMovieCollection(id, &db.MovieCollection{
+		ID:   movie.BelongsToCollection.ID,
+		Name: movie.BelongsToCollection.Name,
+	})
+}
+
+// HandleCollections serves GET /api/collections, the TMDB collections that
+// library movies belong to with their members. ?id= returns one collection.
+func HandleCollections(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	collectionID := 0
+	if value := r.URL.Query().Get("id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "id must be a positive collection id")
+			return
+		}
+		collectionID = id
+	}
+
+	collections, err := db.ListCollections(collectionID)
+	if err != nil {
+		logger.Error("Failed to list collections: %v", err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list collections")
+		return
+	}
+	if collectionID > 0 && len(collections) == 0 {
+		apierror.WriteError(w, http.StatusNotFound, apierror.CodeNotFound, "No library movie belongs to this collection")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collections)
+}
+
+// HandleCollectionsRefresh serves /api/collections/refresh. POST fetches the
+// TMDB details of every library movie in the background to update collection
+// membership; GET reports the progress of the last refresh.
+func HandleCollectionsRefresh(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		collectionRefreshMu.Lock()
+		status := collectionRefreshStatus
+		collectionRefreshMu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+		return
+	}
+
+	movies, err := db.GetLibraryMovies()
+	if err != nil {
+		logger.Error("Failed to list library movies: %v", err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list library movies")
+		return
+	}
+
+	collectionRefreshMu.Lock()
+	if collectionRefreshStatus.Running {
+		collectionRefreshMu.Unlock()
+		apierror.WriteError(w, http.StatusConflict, apierror.CodeCollectionRefreshInProgress, "Collections are already being refreshed")
+		return
+	}
+	now := time.Now()
+	collectionRefreshStatus = CollectionRefreshStatus{Running: true, Total: len(movies), StartedAt: &now}
+	status := collectionRefreshStatus
+	collectionRefreshMu.Unlock()
+
+	ids := make([]int, 0, len(movies))
+	for id := range movies {
+		ids = append(ids, id)
+	}
+	go refreshMovieCollections(ids, getTmdbApiKey())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(status)
+}
+
+// refreshMovieCollections fetches the details of each movie one at a time,
+// sharing the TMDB queue with interactive lookups
+func refreshMovieCollections(ids []int, apiKey string) {
+	logger.Info("Refreshing collection membership of %d movies", len(ids))
+	failed := 0
+	for i, id := range ids {
+		if err := refreshMovieCollection(id, apiKey); err != nil {
+			logger.Debug("Failed to refresh collection of movie %d: %v", id, err)
+			failed++
+		}
+
+		collectionRefreshMu.Lock()
+		collectionRefreshStatus.Processed = i + 1
+		collectionRefreshStatus.Failed = failed
+		collectionRefreshMu.Unlock()
+	}
+
+	now := time.Now()
+	collectionRefreshMu.Lock()
+	collectionRefreshStatus.Running = false
+	collectionRefreshStatus.FinishedAt = &now
+	collectionRefreshMu.Unlock()
+	if failed < len(ids) {
+		db.NotifyLibraryChanged()
+	}
+	logger.Info("Refreshed collection membership of %d movies, %d failed", len(ids)-failed, failed)
+}
+
+func refreshMovieCollection(tmdbID int, apiKey string) error {
+	acquireTmdbQueue()
+	defer releaseTmdbQueue()
+
+	id := strconv.Itoa(tmdbID)
+	resp, err := tmdbGet(context.Background(), "https://api.themoviedb.org/3/movie/"+id+"?api_key="+url.QueryEscape(apiKey))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("TMDB returned HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return recordMovieCollection(id, body)
+}
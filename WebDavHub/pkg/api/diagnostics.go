@@ -0,0 +1,171 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cinesync/pkg/auth"
+	"cinesync/pkg/db"
+	"cinesync/pkg/env"
+)
+
+// DiagnosticCheck is the result of a single environment self-test
+type DiagnosticCheck struct {
+	Name        string `json:"name"`
+	Passed      bool   `json:"passed"`
+	Message     string `json:"message"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+func passedCheck(name, message string) DiagnosticCheck {
+	return DiagnosticCheck{Name: name, Passed: true, Message: message}
+}
+
+func failedCheck(name string, err error, remediation string) DiagnosticCheck {
+	return DiagnosticCheck{Name: name, Message: err.Error(), Remediation: remediation}
+}
+
+// HandleDiagnostics runs environment self-tests and reports each result with
+// a hint on how to fix failures
+func HandleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	destDir := env.GetString("DESTINATION_DIR", rootDir)
+	checks := []DiagnosticCheck{}
+	checks = append(checks, checkSourceDirectories()...)
+	checks = append(checks,
+		checkDestinationWritable(destDir),
+		checkSymlinkSupport(destDir),
+		checkDatabaseWritable(),
+		checkJWTSecret(),
+		checkPythonBridge(),
+	)
+
+	healthy := true
+	for _, check := range checks {
+		if !check.Passed {
+			healthy = false
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"healthy": healthy,
+		"checks":  checks,
+	})
+}
+
+// checkSourceDirectories verifies every configured source directory can be listed
+func checkSourceDirectories() []DiagnosticCheck {
+	sourceDirs := getSourceDirectories()
+	if len(sourceDirs) == 0 {
+		return []DiagnosticCheck{failedCheck("source_readable", fmt.Errorf("SOURCE_DIR is not configured"),
+			"Set SOURCE_DIR to one or more comma separated folders containing your media")}
+	}
+
+	checks := make([]DiagnosticCheck, 0, len(sourceDirs))
+	for _, dir := range sourceDirs {
+		name := "source_readable:" + dir
+		if _, err := os.ReadDir(dir); err != nil {
+			checks = append(checks, failedCheck(name, err,
+				"Check the path exists and that the user running CineSync has read and execute permission on it"))
+			continue
+		}
+		checks = append(checks, passedCheck(name, "Source directory is readable"))
+	}
+	return checks
+}
+
+// checkDestinationWritable creates and removes a file in the destination
+func checkDestinationWritable(destDir string) DiagnosticCheck {
+	const name = "destination_writable"
+	file, err := os.CreateTemp(destDir, ".cinesync-diagnostics-*")
+	if err != nil {
+		return failedCheck(name, err,
+			"Ensure DESTINATION_DIR exists and the user running CineSync has write permission on it")
+	}
+	file.Close()
+	os.Remove(file.Name())
+	return passedCheck(name, "Destination directory is writable")
+}
+
+// checkSymlinkSupport creates a real symlink in the destination to prove the
+// filesystem and permissions allow it, then removes it
+func checkSymlinkSupport(destDir string) DiagnosticCheck {
+	const name = "symlink_creation"
+	target, err := os.CreateTemp(destDir, ".cinesync-diagnostics-target-*")
+	if err != nil {
+		return failedCheck(name, err, "Fix destination write permissions first")
+	}
+	target.Close()
+	defer os.Remove(target.Name())
+
+	link := filepath.Join(destDir, fmt.Sprintf(".cinesync-diagnostics-link-%d", time.Now().UnixNano()))
+	if err := os.Symlink(target.Name(), link); err != nil {
+		return failedCheck(name, err,
+			"The destination filesystem does not allow symlinks. On Windows enable Developer Mode or run as administrator; on network shares enable symlink support")
+	}
+	defer os.Remove(link)
+
+	resolved, err := os.Readlink(link)
+	if err != nil || resolved != target.Name() {
+		return failedCheck(name, fmt.Errorf("symlink was created but does not resolve correctly"),
+			"Check that the destination filesystem preserves symlinks")
+	}
+	return passedCheck(name, "Symlinks can be created in the destination")
+}
+
+// checkDatabaseWritable verifies the source database accepts writes
+func checkDatabaseWritable() DiagnosticCheck {
+	const name = "database_writable"
+	if err := db.CheckSourceDatabaseWritable(); err != nil {
+		return failedCheck(name, err,
+			"Ensure the db folder is writable and not on a network share, and that no other process holds a lock")
+	}
+	return passedCheck(name, "Database accepts writes")
+}
+
+// checkJWTSecret verifies a signing secret is configured for auth tokens
+func checkJWTSecret() DiagnosticCheck {
+	const name = "jwt_secret"
+	if strings.TrimSpace(os.Getenv("JWT_SECRET")) == "" {
+		return failedCheck(name, fmt.Errorf("JWT_SECRET is not set"),
+			"Set JWT_SECRET to a long random string so login tokens cannot be forged")
+	}
+	return passedCheck(name, "JWT secret is configured")
+}
+
+// checkPythonBridge verifies the Python interpreter runs and MediaHub is present
+func checkPythonBridge() DiagnosticCheck {
+	const name = "python_bridge"
+	pythonCmd := getPythonCommand()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, pythonCmd, "--version").CombinedOutput()
+	if err != nil {
+		return failedCheck(name, fmt.Errorf("failed to run %s: %v", pythonCmd, err),
+			"Install Python 3 or set PYTHON_COMMAND to the interpreter path")
+	}
+
+	if _, err := os.Stat(filepath.Join("..", "MediaHub", "main.py")); err != nil {
+		return failedCheck(name, fmt.Errorf("MediaHub not found: %v", err),
+			"Run CineSync from the WebDavHub folder so ../MediaHub/main.py can be found")
+	}
+	return passedCheck(name, strings.TrimSpace(string(output))+" is available")
+}
@@ -9,7 +9,9 @@ import (
 	"strings"
 	"syscall"
 
+	"cinesync/pkg/db"
 	"cinesync/pkg/logger"
+	"cinesync/pkg/mimetype"
 )
 
 // DownloadRequest and DownloadResponse for download API
@@ -108,10 +110,12 @@ func HandleDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	w.Header().Set("Content-Disposition", "attachment; filename=\""+fileInfo.Name()+"\"")
-	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Type", mimetype.Detect(fileInfo.Name(), file))
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", fileInfo.Size()))
 	w.WriteHeader(http.StatusOK)
-	if _, err := io.Copy(w, file); err != nil {
+	written, err := io.Copy(w, file)
+	db.RecordFileAccess(absFile, written)
+	if err != nil {
 		// Check if this is a client disconnect (expected when user closes player)
 		if isClientDisconnectError(err) {
 			logger.Info("Client disconnected during download: %s", cleanPath)
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+)
+
+const (
+	// defaultMetadataConcurrency is how many enrichment tasks run at once
+	// unless CINESYNC_METADATA_CONCURRENCY says otherwise
+	defaultMetadataConcurrency = 4
+	// enrichmentQueueSize bounds the tasks waiting for a worker; tasks beyond
+	// it are dropped, since what they would fetch is also fetched on demand
+	enrichmentQueueSize = 1000
+	// enrichmentRateKey is the rate limiter bucket enrichment requests share,
+	// next to the per-client buckets of the TMDB proxy
+	enrichmentRateKey = "metadata-enrichment"
+)
+
+// enrichmentTask is metadata work started for a file MediaHub has linked
+type enrichmentTask struct {
+	name    string
+	timeout time.Duration
+	run     func(ctx context.Context) error
+}
+
+// enrichmentQueue runs metadata lookups for processed files on a fixed number
+// of workers, separate from the TMDB proxy queue and from MediaHub's file
+// workers. The symlink already exists when a task is queued, so a slow or
+// failing provider delays only the metadata, never the link.
+type enrichmentQueue struct {
+	tasks   chan enrichmentTask
+	workers int
+	// wait is called before every task so enrichment stays within the
+	// TMDB rate limit
+	wait func()
+
+	mu      sync.Mutex
+	active  int
+	dropped int
+}
+
+var (
+	enrichment     *enrichmentQueue
+	enrichmentOnce sync.Once
+)
+
+// metadataConcurrency returns the number of enrichment workers, from
+// CINESYNC_METADATA_CONCURRENCY
+func metadataConcurrency() int {
+	workers := env.GetInt("CINESYNC_METADATA_CONCURRENCY", defaultMetadataConcurrency)
+	if workers < 1 {
+		workers = 1
+	}
+	return workers
+}
+
+// newEnrichmentQueue starts workers goroutines running the queued tasks
+func newEnrichmentQueue(workers int, wait func()) *enrichmentQueue {
+	q := &enrichmentQueue{
+		tasks:   make(chan enrichmentTask, enrichmentQueueSize),
+		workers: workers,
+		wait:    wait,
+	}
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// defaultEnrichmentQueue returns the queue processed files are enriched on,
+// starting its workers on first use
+func defaultEnrichmentQueue() *enrichmentQueue {
+	enrichmentOnce.Do(func() {
+		workers := metadataConcurrency()
+		enrichment = newEnrichmentQueue(workers, func() { waitForRateLimit(enrichmentRateKey) })
+		logger.Info("Metadata enrichment running on %d workers", workers)
+	})
+	return enrichment
+}
+
+// Enqueue queues a task without blocking. A full queue drops the task.
+func (q *enrichmentQueue) Enqueue(task enrichmentTask) bool {
+	select {
+	case q.tasks <- task:
+		return true
+	default:
+		q.mu.Lock()
+		q.dropped++
+		q.mu.Unlock()
+		logger.Warn("Metadata enrichment queue is full, skipping %s", task.name)
+		return false
+	}
+}
+
+func (q *enrichmentQueue) work() {
+	for task := range q.tasks {
+		q.wait()
+
+		q.mu.Lock()
+		q.active++
+		q.mu.Unlock()
+
+		q.run(task)
+
+		q.mu.Lock()
+		q.active--
+		q.mu.Unlock()
+	}
+}
+
+// run performs a task, logging instead of returning its failure
+func (q *enrichmentQueue) run(task enrichmentTask) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			logger.Error("Metadata enrichment %s panicked: %v", task.name, recovered)
+		}
+	}()
+
+	ctx := context.Background()
+	if task.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, task.timeout)
+		defer cancel()
+	}
+	if err := task.run(ctx); err != nil {
+		logger.Warn("Metadata enrichment %s failed: %v", task.name, err)
+	}
+}
+
+// EnrichmentStats is the state of the enrichment workers reported in /api/stats
+type EnrichmentStats struct {
+	Workers int `json:"workers"`
+	Active  int `json:"active"`
+	Queued  int `json:"queued"`
+	// Dropped counts tasks skipped because the queue was full
+	Dropped int `json:"dropped"`
+}
+
+// Stats returns the number of workers and of running, queued and dropped tasks
+func (q *enrichmentQueue) Stats() EnrichmentStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return EnrichmentStats{Workers: q.workers, Active: q.active, Queued: len(q.tasks), Dropped: q.dropped}
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"net/http"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/middleware"
+)
+
+// HandleHTTPStats serves GET /api/stats/http, the request count, error rate
+// and latency percentiles of every API route since startup
+func HandleHTTPStats(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	middleware.WriteJSON(w, r, middleware.CollectedHTTPStats())
+}
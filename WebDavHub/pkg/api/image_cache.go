@@ -9,9 +9,11 @@ import (
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"cinesync/pkg/httpclient"
 	"cinesync/pkg/logger"
 )
 
@@ -25,9 +27,7 @@ const (
 )
 
 // HTTP client for faster poster loading
-var httpClient = &http.Client{
-	Timeout: 1 * time.Second,
-}
+var httpClient = httpclient.New(1 * time.Second)
 
 // Environment variable overrides
 func getMaxCacheSizeMB() int {
@@ -156,6 +156,19 @@ func (ics *ImageCacheService) DownloadAndCacheImage(posterPath string, size stri
 	}
 
 	// Check cache limits before downloading
+	ics.cleanupIfOverLimit()
+
+	// Download from TMDB at the requested size
+	tmdbURL := fmt.Sprintf("https://image.tmdb.org/t/p/%s%s", size, posterPath)
+	if err := ics.downloadToCache(httpClient, tmdbURL, localPath); err != nil {
+		return "", err
+	}
+	return localPath, nil
+}
+
+// cleanupIfOverLimit trims the cache once it holds more than the configured
+// size or number of files
+func (ics *ImageCacheService) cleanupIfOverLimit() {
 	totalSize, fileCount, err := ics.GetCacheStats()
 	if err == nil {
 		maxSizeBytes := int64(ics.maxSizeMB) * 1024 * 1024
@@ -165,25 +178,29 @@ func (ics *ImageCacheService) DownloadAndCacheImage(posterPath string, size stri
 			}
 		}
 	}
+}
 
-	// Download from TMDB at the requested size
-	tmdbURL := fmt.Sprintf("https://image.tmdb.org/t/p/%s%s", size, posterPath)
-
-	resp, err := httpClient.Get(tmdbURL)
+// downloadToCache fetches sourceURL with client and stores it at localPath,
+// writing to a temporary file first so readers never see a partial image
+func (ics *ImageCacheService) downloadToCache(client *http.Client, sourceURL, localPath string) error {
+	resp, err := client.Get(sourceURL)
 	if err != nil {
-		return "", fmt.Errorf("failed to download image: %w", err)
+		return fmt.Errorf("failed to download image: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to download image: status %d", resp.StatusCode)
+		return fmt.Errorf("failed to download image: status %d", resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" && !strings.HasPrefix(contentType, "image/") {
+		return fmt.Errorf("failed to download image: unexpected content type %s", contentType)
 	}
 
 	// Create temporary file with unique name to avoid conflicts
 	tempFile := fmt.Sprintf("%s.tmp.%d", localPath, time.Now().UnixNano())
 	file, err := os.Create(tempFile)
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %w", err)
+		return fmt.Errorf("failed to create temp file: %w", err)
 	}
 
 	// Copy with size limit
@@ -191,7 +208,7 @@ func (ics *ImageCacheService) DownloadAndCacheImage(posterPath string, size stri
 	if err != nil && err != io.EOF {
 		file.Close()
 		os.Remove(tempFile)
-		return "", fmt.Errorf("failed to write image: %w", err)
+		return fmt.Errorf("failed to write image: %w", err)
 	}
 
 	// Close file before rename (Windows requirement)
@@ -200,15 +217,15 @@ func (ics *ImageCacheService) DownloadAndCacheImage(posterPath string, size stri
 	// Final check for race condition
 	if _, err := os.Stat(localPath); err == nil {
 		os.Remove(tempFile)
-		return localPath, nil
+		return nil
 	}
 
 	// Use Windows-safe rename with retries
 	if err := windowsSafeRename(tempFile, localPath); err != nil {
 		os.Remove(tempFile)
-		return "", fmt.Errorf("failed to move image: %w", err)
+		return fmt.Errorf("failed to move image: %w", err)
 	}
-	return localPath, nil
+	return nil
 }
 
 
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/env"
+	"cinesync/pkg/httpclient"
+	"cinesync/pkg/logger"
+)
+
+// defaultImageProxyHosts are the hosts the image proxy fetches from unless
+// CINESYNC_IMAGE_PROXY_HOSTS lists others
+const defaultImageProxyHosts = "image.tmdb.org"
+
+// maxImageProxyRedirects bounds the redirects followed for one image
+const maxImageProxyRedirects = 3
+
+var errImageHostNotAllowed = errors.New("image host is not allowed")
+
+// imageProxyClient fetches proxied images. Every redirect is checked against
+// the allowlist as well, so an allowed host cannot bounce the proxy to an
+// internal address.
+var imageProxyClient = &http.Client{
+	Transport: httpclient.Transport(),
+	Timeout:   10 * time.Second,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxImageProxyRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxImageProxyRedirects)
+		}
+		_, err := checkImageProxyURL(req.URL.String())
+		return err
+	},
+}
+
+// imageProxyHosts returns the allowlisted hosts from the comma separated
+// CINESYNC_IMAGE_PROXY_HOSTS
+func imageProxyHosts() map[string]bool {
+	hosts := make(map[string]bool)
+	for _, host := range strings.Split(env.GetString("CINESYNC_IMAGE_PROXY_HOSTS", defaultImageProxyHosts), ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts[host] = true
+		}
+	}
+	return hosts
+}
+
+// checkImageProxyURL parses raw and returns it when it is an http or https URL
+// on an allowlisted host and default port, without credentials
+func checkImageProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Hostname() == "" || u.User != nil {
+		return nil, fmt.Errorf("url must be an http or https URL")
+	}
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		return nil, errImageHostNotAllowed
+	}
+	if !imageProxyHosts()[strings.ToLower(u.Hostname())] {
+		return nil, errImageHostNotAllowed
+	}
+	return u, nil
+}
+
+// getCachedURLPath returns the local path of a proxied image. The name has no
+// extension; the content type is sniffed when the image is served.
+func (ics *ImageCacheService) getCachedURLPath(imageURL string) string {
+	hash := md5.Sum([]byte("url:" + imageURL))
+	return filepath.Join(ics.cacheDir, fmt.Sprintf("proxy-%x", hash))
+}
+
+// downloadURLToCache fetches an allowlisted image URL into the cache unless
+// it is cached already, and returns its local path
+func (ics *ImageCacheService) downloadURLToCache(imageURL string) (string, error) {
+	localPath := ics.getCachedURLPath(imageURL)
+	if _, err := os.Stat(localPath); err == nil {
+		return localPath, nil
+	}
+
+	// Use per-file mutex to prevent concurrent downloads of the same image
+	mutexInterface, _ := ics.downloadMutex.LoadOrStore(localPath, &sync.Mutex{})
+	mutex := mutexInterface.(*sync.Mutex)
+	mutex.Lock()
+	defer func() {
+		mutex.Unlock()
+		ics.downloadMutex.Delete(localPath)
+	}()
+
+	if _, err := os.Stat(localPath); err == nil {
+		return localPath, nil
+	}
+
+	ics.cleanupIfOverLimit()
+	if err := ics.downloadToCache(imageProxyClient, imageURL, localPath); err != nil {
+		return "", err
+	}
+	return localPath, nil
+}
+
+// ServeImageFile serves a local image, such as a MediaCover poster, cacheable
+// for a day with an ETag from its size and modification time, answering
+// conditional requests with 304
+func ServeImageFile(w http.ResponseWriter, r *http.Request, filePath string) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil || stat.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".jpg", ".jpeg":
+		w.Header().Set("Content-Type", "image/jpeg")
+	case ".png":
+		w.Header().Set("Content-Type", "image/png")
+	case ".webp":
+		w.Header().Set("Content-Type", "image/webp")
+	}
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, stat.ModTime().UnixNano(), stat.Size()))
+
+	http.ServeContent(w, r, filepath.Base(filePath), stat.ModTime(), file)
+}
+
+// HandleImageProxy serves GET /api/image-proxy?url=, a poster or other image
+// from an allowlisted remote host such as TMDB, fetched once and then served
+// from the image cache. Image elements cannot send an Authorization header,
+// so the token may also be passed as the token parameter.
+func HandleImageProxy(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet, http.MethodHead) {
+		return
+	}
+	if !auth.RequireStreamAuthenticated(w, r) {
+		return
+	}
+
+	imageURL, err := checkImageProxyURL(r.URL.Query().Get("url"))
+	if errors.Is(err, errImageHostNotAllowed) {
+		apierror.WriteError(w, http.StatusForbidden, apierror.CodeImageHostNotAllowed, "Images from this host are not proxied")
+		return
+	}
+	if err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	if imageCacheService == nil || !imageCacheService.enabled {
+		http.Redirect(w, r, imageURL.String(), http.StatusTemporaryRedirect)
+		return
+	}
+
+	localPath, err := imageCacheService.downloadURLToCache(imageURL.String())
+	if err != nil {
+		logger.Warn("Failed to proxy image %s: %v", imageURL, err)
+		apierror.WriteError(w, http.StatusBadGateway, apierror.CodeImageFetchFailed, "Failed to fetch the image")
+		return
+	}
+	ServeImageFile(w, r, localPath)
+}
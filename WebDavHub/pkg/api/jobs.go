@@ -4,12 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
 	"cinesync/pkg/jobs"
 	"cinesync/pkg/logger"
+	"cinesync/pkg/middleware"
+	"cinesync/pkg/paging"
+	"cinesync/pkg/sse"
 )
 
 var jobManager *jobs.Manager
@@ -20,6 +22,7 @@ func InitJobManager() {
 		jobManager = jobs.NewManager()
 		logger.Info("Job manager initialized")
 	}
+	initLibraryScheduler()
 }
 
 // StopJobManager stops the global job manager
@@ -28,9 +31,77 @@ func StopJobManager() {
 		jobManager.Stop()
 		logger.Info("Job manager stopped")
 	}
+	if libraryScheduler != nil {
+		libraryScheduler.Stop()
+	}
+}
+
+// parseJobFilter reads the status, type, since and until query parameters.
+// status and type take comma separated lists; since and until take RFC 3339
+// timestamps or dates, with until covering the whole of its date.
+func parseJobFilter(r *http.Request) (jobs.JobFilter, error) {
+	var filter jobs.JobFilter
+	query := r.URL.Query()
+
+	for _, value := range splitQueryList(query.Get("status")) {
+		status := jobs.JobStatus(strings.ToLower(value))
+		switch status {
+		case jobs.JobStatusIdle, jobs.JobStatusRunning, jobs.JobStatusCompleted, jobs.JobStatusFailed, jobs.JobStatusCancelled, jobs.JobStatusDisabled:
+			filter.Statuses = append(filter.Statuses, status)
+		default:
+			return filter, fmt.Errorf("unknown status %q", value)
+		}
+	}
+	for _, value := range splitQueryList(query.Get("type")) {
+		jobType := jobs.JobType(strings.ToLower(value))
+		switch jobType {
+		case jobs.JobTypeProcess, jobs.JobTypeService, jobs.JobTypeCommand, jobs.JobTypeFunction:
+			filter.Types = append(filter.Types, jobType)
+		default:
+			return filter, fmt.Errorf("unknown type %q", value)
+		}
+	}
+
+	for _, bound := range []struct {
+		name     string
+		target   **time.Time
+		endOfDay bool
+	}{{"since", &filter.Since, false}, {"until", &filter.Until, true}} {
+		value := query.Get(bound.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			day, dayErr := time.ParseInLocation("2006-01-02", value, time.Local)
+			if dayErr != nil {
+				return filter, fmt.Errorf("%s must be an RFC 3339 timestamp or a YYYY-MM-DD date", bound.name)
+			}
+			t = day
+			if bound.endOfDay {
+				t = day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+			}
+		}
+		*bound.target = &t
+	}
+	if filter.Since != nil && filter.Until != nil && filter.Until.Before(*filter.Since) {
+		return filter, fmt.Errorf("until is before since")
+	}
+	return filter, nil
+}
+
+func splitQueryList(value string) []string {
+	var values []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
 }
 
-// HandleJobs handles GET /api/jobs - list all jobs
+// HandleJobs handles GET /api/jobs - list jobs, optionally filtered by
+// status, type and last execution date
 func HandleJobs(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -42,7 +113,13 @@ func HandleJobs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	jobsList := jobManager.GetJobs()
+	filter, err := parseJobFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	jobsList := jobManager.FilterJobs(filter)
 
 	response := jobs.JobsResponse{
 		Jobs:   jobsList,
@@ -266,19 +343,12 @@ func HandleJobExecutions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse limit parameter
-	limit := 10 // default limit
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
-			limit = parsedLimit
-		}
-	}
+	page := paging.Parse(r, 10, 0)
+	executions := jobManager.GetJobExecutions(jobID, 0)
 
-	executions := jobManager.GetJobExecutions(jobID, limit)
-	
 	response := jobs.JobExecutionResponse{
-		Executions: executions,
-		Status:     "success",
+		PagedResponse: paging.Slice(executions, page),
+		Status:        "success",
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -337,7 +407,9 @@ func HandleJobsRouter(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "Invalid URL path", http.StatusBadRequest)
 }
 
-// HandleJobEvents handles GET /api/jobs/events - Server-Sent Events for job status updates
+// HandleJobEvents handles GET /api/jobs/events - Server-Sent Events for job
+// status transitions and progress ticks of running jobs. ?jobId= limits the
+// stream to a comma separated list of jobs.
 func HandleJobEvents(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -353,12 +425,19 @@ func HandleJobEvents(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	middleware.AllowOrigin(w, r)
 	w.Header().Set("Access-Control-Allow-Headers", "Cache-Control")
 
 	// Subscribe to job status updates
 	subscriber := jobManager.Subscribe()
 	defer jobManager.Unsubscribe(subscriber)
+	session := sse.Open(r, "jobs")
+	defer session.Close()
+
+	jobIDs := make(map[string]bool)
+	for _, id := range splitQueryList(r.URL.Query().Get("jobId")) {
+		jobIDs[id] = true
+	}
 
 	// Send initial connection event
 	fmt.Fprintf(w, "data: {\"type\":\"connected\",\"timestamp\":\"%s\"}\n\n", time.Now().Format(time.RFC3339))
@@ -371,14 +450,34 @@ func HandleJobEvents(w http.ResponseWriter, r *http.Request) {
 		select {
 		case <-r.Context().Done():
 			return
+		case <-session.Done():
+			return
 		case update := <-subscriber:
-			data, err := json.Marshal(map[string]interface{}{
+			if len(jobIDs) > 0 && !jobIDs[update.JobID] {
+				continue
+			}
+			event := map[string]interface{}{
 				"type":      "job_update",
+				"event":     update.Event,
 				"jobId":     update.JobID,
 				"status":    update.Status,
 				"message":   update.Message,
 				"timestamp": update.Timestamp.Format(time.RFC3339),
-			})
+			}
+			if update.ExecutionID != "" {
+				event["executionId"] = update.ExecutionID
+			}
+			if update.Event == jobs.JobEventProgress {
+				event["outputLines"] = update.OutputLines
+			}
+			if update.Total > 0 {
+				event["processed"] = update.Processed
+				event["total"] = update.Total
+			}
+			if update.Elapsed > 0 {
+				event["elapsedMs"] = update.Elapsed.Milliseconds()
+			}
+			data, err := json.Marshal(event)
 			if err != nil {
 				logger.Error("Failed to marshal job update: %v", err)
 				continue
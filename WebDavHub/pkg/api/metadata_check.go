@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/logger"
+)
+
+// tmdbAPIBase is the TMDB API root the credential check calls
+var tmdbAPIBase = "https://api.themoviedb.org/3"
+
+// MetadataTestResult reports whether the metadata provider accepted the credentials
+type MetadataTestResult struct {
+	Provider        string `json:"provider"`
+	Success         bool   `json:"success"`
+	StatusCode      int    `json:"statusCode,omitempty"`
+	Message         string `json:"message"`
+	UsingDefaultKey bool   `json:"usingDefaultKey"`
+	LatencyMs       int64  `json:"latencyMs"`
+}
+
+// HandleMetadataTest serves POST /api/metadata/test. It makes one
+// authenticated TMDB call with the configured API key, or with the apiKey of
+// an optional {"apiKey"} body so a key can be checked before it is saved, and
+// reports the provider's verdict. Nothing is persisted.
+func HandleMetadataTest(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	var request struct {
+		APIKey string `json:"apiKey"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+			apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+			return
+		}
+	}
+
+	ip := r.RemoteAddr
+	if colon := strings.LastIndex(ip, ":"); colon != -1 {
+		ip = ip[:colon]
+	}
+	if !checkTmdbRateLimit(ip) {
+		w.Header().Set("Retry-After", "10")
+		apierror.WriteError(w, http.StatusTooManyRequests, apierror.CodeMetadataRateLimited, "Rate limit exceeded")
+		return
+	}
+
+	acquireTmdbQueue()
+	defer releaseTmdbQueue()
+
+	apiKey := strings.TrimSpace(request.APIKey)
+	usingDefaultKey := false
+	if apiKey == "" {
+		apiKey = getTmdbApiKey()
+		usingDefaultKey = apiKey != strings.TrimSpace(os.Getenv("TMDB_API_KEY"))
+	}
+
+	result := testTmdbCredentials(r.Context(), apiKey)
+	result.UsingDefaultKey = usingDefaultKey
+	if !result.Success {
+		logger.Warn("TMDB credential test failed: %s", result.Message)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// testTmdbCredentials calls the TMDB configuration endpoint, the cheapest
+// request that still requires a valid API key
+func testTmdbCredentials(ctx context.Context, apiKey string) MetadataTestResult {
+	result := MetadataTestResult{Provider: "tmdb"}
+	started := time.Now()
+
+	resp, err := tmdbGet(ctx, tmdbAPIBase+"/configuration?"+url.Values{"api_key": {apiKey}}.Encode())
+	result.LatencyMs = time.Since(started).Milliseconds()
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			result.Message = "TMDB did not respond within " + tmdbTimeout().String()
+		} else {
+			result.Message = "TMDB could not be reached: " + err.Error()
+		}
+		return result
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode == http.StatusOK {
+		result.Success = true
+		result.Message = "TMDB accepted the API key"
+		return result
+	}
+
+	// TMDB explains rejections in {"status_code", "status_message"}
+	var body struct {
+		StatusMessage string `json:"status_message"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&body)
+	result.Message = body.StatusMessage
+	if result.Message == "" {
+		result.Message = "TMDB responded with " + resp.Status
+	}
+	return result
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"cinesync/pkg/auth"
+	"cinesync/pkg/db"
+	"cinesync/pkg/logger"
+	"cinesync/pkg/spoofing"
+)
+
+// HandleTitleMonitoring serves /api/library/monitored. GET lists the stored
+// monitored flags, optionally for one mediaType; PUT sets the flag of a title
+// from a {"tmdbId", "mediaType", "monitored"} body. Titles are monitored until
+// they are explicitly unmonitored.
+func HandleTitleMonitoring(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		mediaType := r.URL.Query().Get("mediaType")
+		if mediaType != "" && mediaType != db.MonitoredMediaMovie && mediaType != db.MonitoredMediaTV {
+			http.Error(w, "Invalid mediaType. Must be 'movie' or 'tv'", http.StatusBadRequest)
+			return
+		}
+
+		titles, err := db.GetTitleMonitoring(mediaType)
+		if err != nil {
+			logger.Error("Failed to load monitored titles: %v", err)
+			http.Error(w, "Failed to load monitored titles", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(titles)
+
+	case http.MethodPut:
+		if !auth.RequireAuthenticated(w, r) {
+			return
+		}
+		var request struct {
+			TmdbID    int    `json:"tmdbId"`
+			MediaType string `json:"mediaType"`
+			Monitored *bool  `json:"monitored"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if request.TmdbID <= 0 || request.Monitored == nil {
+			http.Error(w, "tmdbId and monitored are required", http.StatusBadRequest)
+			return
+		}
+		if request.MediaType != db.MonitoredMediaMovie && request.MediaType != db.MonitoredMediaTV {
+			http.Error(w, "Invalid mediaType. Must be 'movie' or 'tv'", http.StatusBadRequest)
+			return
+		}
+
+		if err := db.SetTitleMonitored(request.TmdbID, request.MediaType, *request.Monitored); err != nil {
+			logger.Error("Failed to update monitored state: %v", err)
+			http.Error(w, "Failed to update monitored state", http.StatusInternalServerError)
+			return
+		}
+		spoofing.InvalidateLibraryCache()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(db.TitleMonitoring{
+			TmdbID:    request.TmdbID,
+			MediaType: request.MediaType,
+			Monitored: *request.Monitored,
+			UpdatedAt: time.Now().Unix(),
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/notify"
+)
+
+// NotificationTestResponse reports the test delivery to each webhook
+type NotificationTestResponse struct {
+	Success bool            `json:"success"`
+	Results []notify.Result `json:"results"`
+}
+
+// HandleNotificationTest serves POST /api/notifications/test. It posts a
+// sample event to every webhook in CINESYNC_WEBHOOK_URLS under the configured
+// timeout, without retries, and reports each webhook's status code and
+// latency. Success is set when every webhook accepted the event.
+func HandleNotificationTest(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+	if !notify.Enabled() {
+		apierror.WriteError(w, http.StatusConflict, apierror.CodeWebhooksNotConfigured, "No webhooks are configured")
+		return
+	}
+
+	response := NotificationTestResponse{Success: true, Results: notify.Test()}
+	for _, result := range response.Results {
+		if !result.Success {
+			response.Success = false
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
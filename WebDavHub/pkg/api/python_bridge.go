@@ -16,6 +16,9 @@ import (
 	"io"
 	"cinesync/pkg/logger"
 	"cinesync/pkg/env"
+	"cinesync/pkg/db"
+	"cinesync/pkg/diskspace"
+	"cinesync/pkg/middleware"
 )
 
 // PythonBridgeRequest represents the request payload for running the python bridge
@@ -36,6 +39,7 @@ type PythonBridgeResponse struct {
 	Error            string                 `json:"error,omitempty"`
 	Done             bool                   `json:"done,omitempty"`
 	StructuredData   *StructuredMessage     `json:"structuredData,omitempty"`
+	BatchID          string                 `json:"batchId,omitempty"`
 }
 
 // StructuredMessage represents structured data from Python processors
@@ -572,6 +576,12 @@ func HandlePythonBridge(w http.ResponseWriter, r *http.Request) {
 			}
 		} else {
 			logger.Info("Python bridge processing completed successfully for: %s", filepath.Base(realPath))
+			// A file or folder linked to IDs the user picked is a manual match
+			if manualIDs := req.SelectedIds["tmdb"] + req.SelectedIds["imdb"] + req.SelectedIds["tvdb"]; manualIDs != "" {
+				if err := db.RecordIdentificationOverride(realPath, req.SelectedIds["tmdb"]); err != nil {
+					logger.Warn("Failed to record manual match of %s: %v", realPath, err)
+				}
+			}
 			if sendErr := sendResponse(PythonBridgeResponse{Done: true}); sendErr != nil {
 				if !isClientDisconnectError(sendErr) {
 					logger.Error("Error sending completion response: %v", sendErr)
@@ -636,7 +646,7 @@ func handleBulkAutoProcess(w http.ResponseWriter, r *http.Request, req PythonBri
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	middleware.AllowOrigin(w, r)
 	w.Header().Set("Access-Control-Allow-Headers", "Cache-Control")
 
 	sendResponse := func(response PythonBridgeResponse) error {
@@ -658,7 +668,29 @@ func handleBulkAutoProcess(w http.ResponseWriter, r *http.Request, req PythonBri
 		return nil
 	}
 
-	if err := sendResponse(PythonBridgeResponse{Output: "Starting bulk auto processing...\n"}); err != nil {
+	// Refuse to start when the batch would fill the destination
+	required := diskspace.EstimateRequired(getSourceDirectories())
+	if err := diskspace.Check(rootDir, required); err != nil {
+		logger.Warn("Bulk auto processing refused: %v", err)
+		sendResponse(PythonBridgeResponse{Error: err.Error(), Done: true})
+		return
+	}
+
+	// Per-file results MediaHub reports with this run's batch id are stored
+	// under the batch so they can be queried afterwards
+	batchStatus := "failed"
+	batchID, err := db.StartOperationBatch("bulk_auto_process")
+	if err != nil {
+		logger.Warn("Failed to start operation batch: %v", err)
+	} else {
+		defer func() {
+			if err := db.CompleteOperationBatch(batchID, batchStatus); err != nil {
+				logger.Warn("Failed to complete batch %s: %v", batchID, err)
+			}
+		}()
+	}
+
+	if err := sendResponse(PythonBridgeResponse{Output: "Starting bulk auto processing...\n", BatchID: batchID}); err != nil {
 		logger.Error("Error sending initial response: %v", err)
 		return
 	}
@@ -686,6 +718,9 @@ func handleBulkAutoProcess(w http.ResponseWriter, r *http.Request, req PythonBri
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, pythonCmd, args...)
+	if batchID != "" {
+		cmd.Env = append(os.Environ(), db.OperationBatchEnv+"="+batchID)
+	}
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -742,6 +777,13 @@ func handleBulkAutoProcess(w http.ResponseWriter, r *http.Request, req PythonBri
 		doneChan <- cmd.Wait()
 	}()
 
+	// A batch that takes space is stopped once the destination falls below
+	// the reserve while it runs
+	spaceLow := make(chan error, 1)
+	if required > 0 {
+		go diskspace.Watch(ctx, rootDir, func(err error) { spaceLow <- err })
+	}
+
 	clientDisconnected := make(chan bool, 1)
 	go func() {
 		<-r.Context().Done()
@@ -755,8 +797,20 @@ func handleBulkAutoProcess(w http.ResponseWriter, r *http.Request, req PythonBri
 			sendResponse(PythonBridgeResponse{Error: err.Error(), Done: true})
 		} else {
 			logger.Info("Auto processing completed successfully")
+			batchStatus = "completed"
 			sendResponse(PythonBridgeResponse{Done: true})
 		}
+	case err := <-spaceLow:
+		logger.Warn("Bulk auto processing stopped: %v", err)
+		cancel()
+		select {
+		case <-doneChan:
+		case <-time.After(5 * time.Second):
+			if cmd.Process != nil {
+				cmd.Process.Kill()
+			}
+		}
+		sendResponse(PythonBridgeResponse{Error: err.Error(), Done: true})
 	case <-clientDisconnected:
 		logger.Info("Client disconnected during bulk processing, terminating")
 		cancel()
@@ -916,10 +970,23 @@ func HandleSkipProcessing(w http.ResponseWriter, r *http.Request) {
 
 	logger.Info("Executing skip processing command: %s %v", pythonCmd, args)
 
-	// Execute the command
-	cmd := exec.Command(pythonCmd, args...)
+	// Execute the command, bounded by the bridge timeout and the client connection
+	ctx, cancel := context.WithTimeout(r.Context(), env.BridgeTimeout())
+	defer cancel()
+	cmd := exec.CommandContext(ctx, pythonCmd, args...)
 
 	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		logger.Warn("Skip processing timed out for: %s", req.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusGatewayTimeout)
+		json.NewEncoder(w).Encode(ProcessingResponse{
+			Success: false,
+			Message: "Skip processing timed out, please retry",
+			Details: fmt.Sprintf("No result within %s", env.BridgeTimeout()),
+		})
+		return
+	}
 	if err != nil {
 		logger.Error("Skip processing failed: %v, output: %s", err, string(output))
 		response := ProcessingResponse{
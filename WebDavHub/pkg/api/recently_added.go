@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/db"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+	"cinesync/pkg/paging"
+)
+
+const (
+	defaultRecentlyAddedPageSize = 20
+	maxRecentlyAddedPageSize     = 100
+)
+
+// RecentlyAddedItem is a title of the recently added feed with its poster
+type RecentlyAddedItem struct {
+	db.RecentlyAddedTitle
+	PosterPath string `json:"posterPath,omitempty"`
+}
+
+// HandleRecentlyAdded serves GET /api/dashboard/recent, the most recently
+// processed titles, newest first. ?library= limits it to one base path and
+// ?type= to movie or tv. Pages hold CINESYNC_RECENT_FEED_PAGE_SIZE titles
+// unless ?pageSize= asks for another size.
+func HandleRecentlyAdded(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	filter := db.RecentlyAddedFilter{
+		Library:   r.URL.Query().Get("library"),
+		MediaType: r.URL.Query().Get("type"),
+	}
+	if filter.MediaType != "" && filter.MediaType != "movie" && filter.MediaType != "tv" {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "type must be movie or tv")
+		return
+	}
+
+	pageSize := env.GetInt("CINESYNC_RECENT_FEED_PAGE_SIZE", defaultRecentlyAddedPageSize)
+	if pageSize < 1 || pageSize > maxRecentlyAddedPageSize {
+		pageSize = defaultRecentlyAddedPageSize
+	}
+	page := paging.Parse(r, pageSize, maxRecentlyAddedPageSize)
+	titles, total, err := db.ListRecentlyAdded(filter, page.Limit, page.Offset)
+	if err != nil {
+		logger.Error("Failed to list recently added titles: %v", err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list recently added titles")
+		return
+	}
+
+	items := make([]RecentlyAddedItem, 0, len(titles))
+	for _, title := range titles {
+		posterPath, _, _, _, _ := getTmdbDataFromCacheByID(title.TmdbID, title.MediaType)
+		items = append(items, RecentlyAddedItem{RecentlyAddedTitle: title, PosterPath: posterPath})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(paging.NewResponse(items, total, page))
+}
@@ -0,0 +1,399 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/db"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+)
+
+const defaultReidentifyInterval = 250 * time.Millisecond
+
+// ReidentifyChange is a title whose name or year changed at TMDB
+type ReidentifyChange struct {
+	TmdbID    int    `json:"tmdbId"`
+	MediaType string `json:"mediaType"`
+	OldName   string `json:"oldName"`
+	NewName   string `json:"newName"`
+	OldYear   string `json:"oldYear,omitempty"`
+	NewYear   string `json:"newYear,omitempty"`
+	Files     int    `json:"files"`
+	Relinked  int    `json:"relinked"`
+	Failed    int    `json:"failed"`
+}
+
+// ReidentifyStatus reports the progress of a library reidentification. A dry
+// run refreshes the metadata and lists the changed titles without touching
+// their symlinks.
+type ReidentifyStatus struct {
+	ID         string             `json:"id,omitempty"`
+	Running    bool               `json:"running"`
+	DryRun     bool               `json:"dryRun"`
+	Total      int                `json:"total"`
+	Processed  int                `json:"processed"`
+	Failed     int                `json:"failed"`
+	Overridden int                `json:"overridden"`
+	Locked     int                `json:"locked"`
+	Changes    []ReidentifyChange `json:"changes"`
+	StartedAt  *time.Time         `json:"startedAt,omitempty"`
+	FinishedAt *time.Time         `json:"finishedAt,omitempty"`
+	Cancelled  bool               `json:"cancelled,omitempty"`
+}
+
+var (
+	reidentifyMu     sync.Mutex
+	reidentifyStatus = ReidentifyStatus{Changes: []ReidentifyChange{}}
+	reidentifyCancel context.CancelFunc
+)
+
+// reidentifyTitle is one TMDB entry of the library with the files linked to it
+type reidentifyTitle struct {
+	tmdbID    int
+	mediaType string
+	files     []db.IdentifiedFile
+}
+
+// tmdbTitleDetails is the part of TMDB movie or show details reidentification uses
+type tmdbTitleDetails struct {
+	ID           int    `json:"id"`
+	Title        string `json:"title"`
+	Name         string `json:"name"`
+	PosterPath   string `json:"poster_path"`
+	ReleaseDate  string `json:"release_date"`
+	FirstAirDate string `json:"first_air_date"`
+}
+
+// reidentifyInterval is the pause between two titles, from
+// CINESYNC_REIDENTIFY_INTERVAL, so a library-wide run leaves TMDB capacity
+// for interactive lookups
+func reidentifyInterval() time.Duration {
+	interval, err := time.ParseDuration(env.GetString("CINESYNC_REIDENTIFY_INTERVAL", defaultReidentifyInterval.String()))
+	if err != nil || interval < 0 {
+		return defaultReidentifyInterval
+	}
+	return interval
+}
+
+// groupReidentifyTitles groups the files by TMDB entry, leaving out those
+// matched by hand and those locked. It returns the titles in a stable order
+// and the numbers of files skipped as overridden and as locked.
+func groupReidentifyTitles(files []db.IdentifiedFile, overrides db.IdentificationOverrides, locked db.LockedFiles) ([]*reidentifyTitle, int, int) {
+	byKey := make(map[string]*reidentifyTitle)
+	var titles []*reidentifyTitle
+	overridden, lockedCount := 0, 0
+	for _, file := range files {
+		if locked.Contains(file.FilePath) {
+			lockedCount++
+			continue
+		}
+		if overrides.Covers(file.FilePath) {
+			overridden++
+			continue
+		}
+		key := file.MediaType + ":" + strconv.Itoa(file.TmdbID)
+		title, ok := byKey[key]
+		if !ok {
+			title = &reidentifyTitle{tmdbID: file.TmdbID, mediaType: file.MediaType}
+			byKey[key] = title
+			titles = append(titles, title)
+		}
+		title.files = append(title.files, file)
+	}
+	return titles, overridden, lockedCount
+}
+
+// normalizeTitle drops case, punctuation and spacing, which MediaHub changes
+// when it turns a title into a folder name
+func normalizeTitle(title string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// titleChange compares the name and year MediaHub linked a file under with
+// fresh TMDB details, returning nil when they still agree
+func titleChange(title *reidentifyTitle, details tmdbTitleDetails) *ReidentifyChange {
+	newName, newDate := details.Title, details.ReleaseDate
+	if title.mediaType == "tv" {
+		newName, newDate = details.Name, details.FirstAirDate
+	}
+	newYear := ""
+	if len(newDate) >= 4 {
+		newYear = newDate[:4]
+	}
+	if newName == "" {
+		return nil
+	}
+
+	stored := title.files[0]
+	nameChanged := normalizeTitle(stored.ProperName) != normalizeTitle(newName)
+	yearChanged := stored.Year != "" && newYear != "" && stored.Year != newYear
+	if !nameChanged && !yearChanged {
+		return nil
+	}
+	return &ReidentifyChange{
+		TmdbID:    title.tmdbID,
+		MediaType: title.mediaType,
+		OldName:   stored.ProperName,
+		NewName:   newName,
+		OldYear:   stored.Year,
+		NewYear:   newYear,
+		Files:     len(title.files),
+	}
+}
+
+// fetchTitleDetails fetches the current TMDB details of a title, bypassing the
+// cache, and stores them as the cached metadata
+func fetchTitleDetails(ctx context.Context, title *reidentifyTitle, apiKey string) (tmdbTitleDetails, error) {
+	acquireTmdbQueue()
+	defer releaseTmdbQueue()
+
+	var details tmdbTitleDetails
+	id := strconv.Itoa(title.tmdbID)
+	resp, err := tmdbGet(ctx, "https://api.themoviedb.org/3/"+title.mediaType+"/"+id+"?api_key="+url.QueryEscape(apiKey))
+	if err != nil {
+		return details, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return details, fmt.Errorf("TMDB returned HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return details, err
+	}
+	if err := json.Unmarshal(body, &details); err != nil {
+		return details, err
+	}
+
+	name := details.Title
+	if name == "" {
+		name = details.Name
+	}
+	db.UpsertTmdbCache("id:"+id+":"+title.mediaType, fmt.Sprintf(`{"id":%d,"title":%q,"poster_path":%q,"release_date":%q,"first_air_date":%q,"media_type":%q}`,
+		details.ID, name, details.PosterPath, details.ReleaseDate, details.FirstAirDate, title.mediaType))
+	if title.mediaType == "movie" {
+		if err := recordMovieCollection(id, body); err != nil {
+			logger.Debug("Failed to update collection of movie %s: %v", id, err)
+		}
+	}
+	return details, nil
+}
+
+// relinkFile reruns MediaHub on a source file pinned to its TMDB id, which
+// recreates the symlink under the current name
+func relinkFile(ctx context.Context, file db.IdentifiedFile) error {
+	args := []string{"../MediaHub/main.py", file.FilePath, "--force", "--auto-select", "--disable-monitor",
+		"--tmdb", strconv.Itoa(file.TmdbID)}
+	if file.MediaType == "movie" {
+		args = append(args, "--force-movie")
+	} else {
+		args = append(args, "--force-show")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, env.BridgeTimeout())
+	defer cancel()
+	output, err := exec.CommandContext(ctx, getPythonCommand(), args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, lastLine(string(output)))
+	}
+	return nil
+}
+
+func lastLine(output string) string {
+	output = strings.TrimSpace(output)
+	if i := strings.LastIndexByte(output, '\n'); i >= 0 {
+		return output[i+1:]
+	}
+	return output
+}
+
+// reidentifyLibrary refreshes every title one at a time and, unless dryRun,
+// relinks the files of titles whose name or year changed
+func reidentifyLibrary(ctx context.Context, titles []*reidentifyTitle, apiKey string, dryRun bool) {
+	logger.Info("Reidentifying %d titles (dry run: %t)", len(titles), dryRun)
+	interval := reidentifyInterval()
+	failed := 0
+	for i, title := range titles {
+		if i > 0 && interval > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(interval):
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		var change *ReidentifyChange
+		details, err := fetchTitleDetails(ctx, title, apiKey)
+		if err != nil && ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			logger.Debug("Failed to refresh %s %d: %v", title.mediaType, title.tmdbID, err)
+			failed++
+		} else if change = titleChange(title, details); change != nil && !dryRun {
+			for _, file := range title.files {
+				if err := relinkFile(ctx, file); err != nil {
+					logger.Warn("Failed to relink %s after %q was renamed to %q: %v", file.FilePath, change.OldName, change.NewName, err)
+					change.Failed++
+					continue
+				}
+				change.Relinked++
+			}
+		}
+
+		reidentifyMu.Lock()
+		reidentifyStatus.Processed = i + 1
+		reidentifyStatus.Failed = failed
+		if change != nil {
+			reidentifyStatus.Changes = append(reidentifyStatus.Changes, *change)
+		}
+		reidentifyMu.Unlock()
+	}
+
+	now := time.Now()
+	reidentifyMu.Lock()
+	reidentifyStatus.Running = false
+	reidentifyStatus.FinishedAt = &now
+	reidentifyStatus.Cancelled = ctx.Err() != nil
+	reidentifyCancel = nil
+	changes := len(reidentifyStatus.Changes)
+	reidentifyMu.Unlock()
+
+	if changes > 0 && !dryRun {
+		db.InvalidateFolderCache()
+		db.NotifyLibraryChanged()
+	}
+	logger.Info("Reidentified %d titles: %d changed, %d failed", len(titles)-failed, changes, failed)
+}
+
+// HandleReidentify serves /api/maintenance/reidentify. POST refreshes the
+// TMDB details of every identified title in the background, leaving files
+// matched by hand or locked alone. It is a dry run that only lists the renamed titles
+// unless ?dryRun=false, which also recreates their symlinks. GET reports the
+// progress of the last run and DELETE cancels a running one.
+func HandleReidentify(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet, http.MethodPost, http.MethodDelete) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		reidentifyMu.Lock()
+		status := reidentifyStatus
+		status.Changes = append([]ReidentifyChange{}, reidentifyStatus.Changes...)
+		reidentifyMu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+		return
+	case http.MethodDelete:
+		reidentifyMu.Lock()
+		cancel := reidentifyCancel
+		reidentifyMu.Unlock()
+		if cancel == nil {
+			apierror.WriteError(w, http.StatusNotFound, apierror.CodeNotFound, "No reidentification is running")
+			return
+		}
+		cancel()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	dryRun := true
+	if value := r.URL.Query().Get("dryRun"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "dryRun must be true or false")
+			return
+		}
+		dryRun = parsed
+	}
+
+	apiKey := getTmdbApiKey()
+	if apiKey == "" {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "TMDB_API_KEY is not configured")
+		return
+	}
+	files, err := db.ListIdentifiedFiles()
+	if err != nil {
+		logger.Error("Failed to list identified files: %v", err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list identified files")
+		return
+	}
+	overrides, err := db.GetIdentificationOverrides()
+	if err != nil {
+		logger.Error("Failed to read identification overrides: %v", err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read identification overrides")
+		return
+	}
+	locked, err := db.GetLockedFiles()
+	if err != nil {
+		logger.Error("Failed to read locked files: %v", err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read locked files")
+		return
+	}
+	titles, overridden, lockedCount := groupReidentifyTitles(files, overrides, locked)
+
+	reidentifyMu.Lock()
+	if reidentifyStatus.Running {
+		reidentifyMu.Unlock()
+		apierror.WriteError(w, http.StatusConflict, apierror.CodeReidentifyInProgress, "The library is already being reidentified")
+		return
+	}
+	id := uuid.New().String()
+	lease, err := db.AcquireOperation(db.OperationReidentify, id, nil)
+	if err != nil {
+		reidentifyMu.Unlock()
+		db.WriteOperationConflict(w, err)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	reidentifyStatus = ReidentifyStatus{
+		ID:         id,
+		Running:    true,
+		DryRun:     dryRun,
+		Total:      len(titles),
+		Overridden: overridden,
+		Locked:     lockedCount,
+		Changes:    []ReidentifyChange{},
+		StartedAt:  &now,
+	}
+	reidentifyCancel = cancel
+	status := reidentifyStatus
+	reidentifyMu.Unlock()
+
+	go func() {
+		defer lease.Release()
+		reidentifyLibrary(ctx, titles, apiKey, dryRun)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(status)
+}
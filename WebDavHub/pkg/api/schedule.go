@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/db"
+	"cinesync/pkg/jobs"
+	"cinesync/pkg/logger"
+)
+
+var libraryScheduler *jobs.LibraryScheduler
+
+// HandleSchedule serves /api/schedule, the per-library scan schedules. GET
+// lists the schedule of every source directory; PUT changes the schedule of
+// one library from a {"library", "scheduleType", "intervalSeconds",
+// "cronExpression", "enabled"} body, where omitted fields are kept. Setting
+// enabled to false pauses the library without touching the others.
+func HandleSchedule(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet, http.MethodPut) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	if libraryScheduler == nil {
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Library scheduler not initialized")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(jobs.LibrarySchedulesResponse{
+			Schedules: libraryScheduler.GetSchedules(),
+			Status:    "success",
+		})
+		return
+	}
+
+	var updateReq jobs.UpdateLibraryScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if updateReq.Library == "" {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "library is required")
+		return
+	}
+
+	schedule, err := libraryScheduler.UpdateSchedule(updateReq)
+	if err != nil {
+		if errors.Is(err, jobs.ErrLibraryNotFound) {
+			apierror.WriteError(w, http.StatusNotFound, apierror.CodeNotFound, "Library is not a configured source directory")
+			return
+		}
+		logger.Warn("Failed to update schedule of library %s: %v", updateReq.Library, err)
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedule)
+}
+
+// initLibraryScheduler starts the per-library scan schedules
+func initLibraryScheduler() {
+	if libraryScheduler == nil {
+		libraryScheduler = jobs.NewLibraryScheduler(db.SourceDirectories, db.RunLibraryScan)
+		logger.Info("Library scheduler initialized")
+	}
+}
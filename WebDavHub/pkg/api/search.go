@@ -0,0 +1,244 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cinesync/pkg/auth"
+	"cinesync/pkg/cache"
+	"cinesync/pkg/db"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+)
+
+// Where a unified search answer came from
+const (
+	SearchSourceLibrary  = "library"
+	SearchSourceProvider = "provider"
+	SearchSourceNone     = "none"
+)
+
+// searchProviderCacheTTL is how long provider results are reused for the same query
+const searchProviderCacheTTL = 10 * time.Minute
+
+// errSearchRateLimited is returned when a client exceeds the TMDB rate limit
+var errSearchRateLimited = errors.New("provider search rate limited")
+
+// SearchResult is a title returned by the unified search
+type SearchResult struct {
+	Title      string `json:"title"`
+	Year       string `json:"year,omitempty"`
+	TmdbID     string `json:"tmdbId,omitempty"`
+	MediaType  string `json:"mediaType,omitempty"`
+	PosterPath string `json:"posterPath,omitempty"`
+	Overview   string `json:"overview,omitempty"`
+	InLibrary  bool   `json:"inLibrary"`
+	FileCount  int    `json:"fileCount,omitempty"`
+}
+
+// SearchResponse is the body of GET /api/search
+type SearchResponse struct {
+	Query   string         `json:"query"`
+	Source  string         `json:"source"`
+	Results []SearchResult `json:"results"`
+}
+
+const searchProviderCachePrefix = "tmdb:search:"
+
+// searchProviderEnabled reports whether library misses fall through to TMDB,
+// from CINESYNC_SEARCH_PROVIDER_FALLBACK
+func searchProviderEnabled() bool {
+	return env.IsBool("CINESYNC_SEARCH_PROVIDER_FALLBACK", false)
+}
+
+// HandleUnifiedSearch serves GET /api/search?query=&type=movie|tv. Library
+// titles are returned as soon as there is a match. On a miss, and when the
+// provider fallback is enabled and not disabled with provider=false, TMDB is
+// searched and its titles are returned flagged as not in the library.
+func HandleUnifiedSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("query"))
+	if query == "" {
+		http.Error(w, "Missing query parameter", http.StatusBadRequest)
+		return
+	}
+
+	mediaType := r.URL.Query().Get("type")
+	if mediaType != "" && mediaType != "movie" && mediaType != "tv" {
+		http.Error(w, "Invalid type. Must be 'movie' or 'tv'", http.StatusBadRequest)
+		return
+	}
+
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 100 {
+			limit = parsedLimit
+		}
+	}
+
+	response := SearchResponse{Query: query, Source: SearchSourceNone, Results: []SearchResult{}}
+
+	titles, err := db.SearchLibraryTitles(query, mediaType, limit)
+	if err != nil {
+		logger.Error("Failed to search library titles: %v", err)
+		http.Error(w, "Failed to search library", http.StatusInternalServerError)
+		return
+	}
+
+	if len(titles) > 0 {
+		response.Source = SearchSourceLibrary
+		for _, title := range titles {
+			response.Results = append(response.Results, SearchResult{
+				Title:     title.Title,
+				Year:      title.Year,
+				TmdbID:    title.TmdbID,
+				MediaType: title.MediaType,
+				InLibrary: true,
+				FileCount: title.FileCount,
+			})
+		}
+	} else if searchProviderEnabled() && r.URL.Query().Get("provider") != "false" {
+		results, err := searchProvider(r, query, mediaType, limit)
+		if err != nil {
+			if errors.Is(err, errSearchRateLimited) {
+				http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+			logger.Warn("Provider search for %q failed: %v", query, err)
+			writeTmdbError(w, err, "Failed to contact TMDb")
+			return
+		}
+		if len(results) > 0 {
+			response.Source = SearchSourceProvider
+			response.Results = results
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// searchProvider searches TMDB for titles, sharing the TMDB proxy's request
+// queue and per-client rate limit. Results are cached per query.
+func searchProvider(r *http.Request, query, mediaType string, limit int) ([]SearchResult, error) {
+	cacheKey := searchProviderCachePrefix + mediaType + "|" + strings.ToLower(query)
+
+	if data, ok, err := cache.Default().Get(cacheKey); err != nil {
+		logger.Warn("Search cache lookup failed: %v", err)
+	} else if ok {
+		var cached []SearchResult
+		if json.Unmarshal(data, &cached) == nil {
+			return truncateSearchResults(cached, limit), nil
+		}
+	}
+
+	apiKey := getTmdbApiKey()
+	if apiKey == "" {
+		return nil, fmt.Errorf("TMDB API key not configured")
+	}
+
+	ip := r.RemoteAddr
+	if colon := strings.LastIndex(ip, ":"); colon != -1 {
+		ip = ip[:colon]
+	}
+	if !checkTmdbRateLimit(ip) {
+		return nil, errSearchRateLimited
+	}
+
+	acquireTmdbQueue()
+	defer releaseTmdbQueue()
+
+	params := url.Values{}
+	params.Set("api_key", apiKey)
+	params.Set("query", query)
+	params.Set("include_adult", "false")
+
+	endpoint := "multi"
+	if mediaType != "" {
+		endpoint = mediaType
+	}
+
+	resp, err := tmdbGet(r.Context(), "https://api.themoviedb.org/3/search/"+endpoint+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("TMDb returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Results []struct {
+			ID           int    `json:"id"`
+			MediaType    string `json:"media_type"`
+			Title        string `json:"title"`
+			Name         string `json:"name"`
+			ReleaseDate  string `json:"release_date"`
+			FirstAirDate string `json:"first_air_date"`
+			PosterPath   string `json:"poster_path"`
+			Overview     string `json:"overview"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode TMDb response: %w", err)
+	}
+
+	results := []SearchResult{}
+	for _, item := range body.Results {
+		itemType := item.MediaType
+		if itemType == "" {
+			itemType = mediaType
+		}
+		if itemType != "movie" && itemType != "tv" {
+			continue
+		}
+
+		title, date := item.Title, item.ReleaseDate
+		if itemType == "tv" {
+			title, date = item.Name, item.FirstAirDate
+		}
+		year := ""
+		if len(date) >= 4 {
+			year = date[:4]
+		}
+
+		results = append(results, SearchResult{
+			Title:      title,
+			Year:       year,
+			TmdbID:     strconv.Itoa(item.ID),
+			MediaType:  itemType,
+			PosterPath: item.PosterPath,
+			Overview:   item.Overview,
+			InLibrary:  false,
+		})
+	}
+
+	if data, err := json.Marshal(results); err == nil {
+		if err := cache.Default().Set(cacheKey, data, searchProviderCacheTTL); err != nil {
+			logger.Warn("Search cache store failed: %v", err)
+		}
+	}
+
+	return truncateSearchResults(results, limit), nil
+}
+
+func truncateSearchResults(results []SearchResult, limit int) []SearchResult {
+	if len(results) > limit {
+		return results[:limit]
+	}
+	return results
+}
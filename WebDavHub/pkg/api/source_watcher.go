@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cinesync/pkg/db"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+	"cinesync/pkg/watcher"
+)
+
+var sourceWatcher *watcher.Watcher
+
+// InitSourceWatcher starts watching the source directories when CINESYNC_WATCH
+// is enabled, so new files are processed without waiting for a scan
+func InitSourceWatcher() {
+	if !env.IsBool("CINESYNC_WATCH", false) || sourceWatcher != nil {
+		return
+	}
+	// MediaHub's real-time monitor watches the same directories, and two
+	// watchers would process every file twice
+	if env.IsBool("MEDIAHUB_AUTO_START", true) || env.IsBool("RTM_AUTO_START", false) {
+		logger.Warn("CINESYNC_WATCH is ignored while MEDIAHUB_AUTO_START or RTM_AUTO_START starts MediaHub's monitor, which already watches the sources")
+		return
+	}
+
+	dirs := db.SourceDirectories()
+	if len(dirs) == 0 {
+		logger.Warn("CINESYNC_WATCH is enabled but no source directories are configured")
+		return
+	}
+
+	stabilize := env.GetInt("CINESYNC_WATCH_STABILIZE_SECONDS", env.GetInt("FILE_STABILIZATION_SECONDS", 30))
+	if stabilize < 1 {
+		stabilize = 1
+	}
+	fallback := env.GetInt("CINESYNC_WATCH_FALLBACK_INTERVAL", 900)
+	if fallback < 60 {
+		fallback = 60
+	}
+	batchDelay := env.GetInt("CINESYNC_WATCH_BATCH_SECONDS", 10)
+	if batchDelay < 0 {
+		batchDelay = 0
+	}
+
+	sourceWatcher = watcher.New(watcher.Options{
+		Dirs:             dirs,
+		StabilizeDelay:   time.Duration(stabilize) * time.Second,
+		FallbackInterval: time.Duration(fallback) * time.Second,
+		BatchDelay:       time.Duration(batchDelay) * time.Second,
+		PartialPatterns:  partialFilePatterns(),
+		Process:          processWatchedFiles,
+		FallbackScan: func() error {
+			err := db.RunSourceScan("watch_fallback", db.ScanModeFull)
+			if errors.Is(err, db.ErrScanInProgress) || errors.Is(err, db.ErrOperationConflict) {
+				return nil
+			}
+			return err
+		},
+	})
+	sourceWatcher.Start()
+}
+
+// StopSourceWatcher stops the source watcher if it is running
+func StopSourceWatcher() {
+	if sourceWatcher != nil {
+		sourceWatcher.Stop()
+		sourceWatcher = nil
+	}
+}
+
+// SourceWatcherStatus returns the watcher status reported by the health endpoint
+func SourceWatcherStatus() watcher.Status {
+	if sourceWatcher == nil {
+		return watcher.Status{Enabled: false, Mode: watcher.ModeDisabled}
+	}
+	return sourceWatcher.GetStatus()
+}
+
+// partialFilePatterns returns the comma separated PARTIAL_FILE_PATTERNS, shared
+// with MediaHub, or nil to use the watcher defaults
+func partialFilePatterns() []string {
+	var patterns []string
+	for _, pattern := range strings.Split(env.GetString("PARTIAL_FILE_PATTERNS", ""), ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// processWatchedFiles runs MediaHub on a batch of settled files and refreshes
+// their entries in the source database. Files sharing a folder are processed
+// with one MediaHub run on the folder, and each run has the bridge deadline.
+func processWatchedFiles(ctx context.Context, paths []string) error {
+	// A monitor started by hand after the watcher picks the files up itself
+	if status, err := getMediaHubStatus(); err == nil && status.MonitorRunning {
+		logger.Debug("Left %d watched files to the running MediaHub monitor", len(paths))
+		return nil
+	}
+
+	var errs []error
+	for _, target := range watchedTargets(paths) {
+		if err := runMediaHubOn(ctx, target); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", target, err))
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		// Scans already running pick the files up themselves
+		if err := db.RunSourcePathScan(target); err != nil {
+			logger.Debug("Skipped source database refresh for %s: %v", target, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// watchedTargets returns the paths to run MediaHub on for a batch: the folder
+// of files that arrived together, or the file when it came alone. Locked files
+// are left out, and so are folders holding one, as are the source directories
+// themselves since running on them would process the whole source.
+func watchedTargets(paths []string) []string {
+	sources := map[string]bool{}
+	for _, dir := range db.SourceDirectories() {
+		sources[filepath.Clean(dir)] = true
+	}
+
+	var folders []string
+	files := map[string][]string{}
+	locked := map[string]bool{}
+	for _, path := range paths {
+		dir := filepath.Dir(path)
+		if db.IsFileLocked(path) {
+			logger.Debug("Skipped locked file %s", path)
+			locked[dir] = true
+			continue
+		}
+		if _, seen := files[dir]; !seen {
+			folders = append(folders, dir)
+		}
+		files[dir] = append(files[dir], path)
+	}
+
+	var targets []string
+	for _, dir := range folders {
+		if len(files[dir]) > 1 && !locked[dir] && !sources[dir] {
+			targets = append(targets, dir)
+			continue
+		}
+		targets = append(targets, files[dir]...)
+	}
+	return targets
+}
+
+// runMediaHubOn runs MediaHub on a single file or folder
+func runMediaHubOn(ctx context.Context, path string) error {
+	ctx, cancel := context.WithTimeout(ctx, env.BridgeTimeout())
+	defer cancel()
+	cmd := exec.CommandContext(ctx, getPythonCommand(), "../MediaHub/main.py", path, "--auto-select", "--disable-monitor")
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("MediaHub did not finish within %s", env.BridgeTimeout())
+	}
+	if err != nil {
+		message := strings.TrimSpace(string(output))
+		if len(message) > 500 {
+			message = message[len(message)-500:]
+		}
+		if message != "" {
+			return fmt.Errorf("MediaHub failed: %v: %s", err, message)
+		}
+		return fmt.Errorf("MediaHub failed: %v", err)
+	}
+	return nil
+}
@@ -2,9 +2,14 @@ package api
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
+	"strings"
 
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/config"
 	"cinesync/pkg/logger"
+	"cinesync/pkg/middleware"
 	"cinesync/pkg/spoofing"
 )
 
@@ -21,7 +26,7 @@ type SpoofingConfigRequest struct {
 
 // HandleSpoofingConfig handles GET and POST requests for the spoofing configuration
 func HandleSpoofingConfig(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	middleware.AllowOrigin(w, r)
 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, OPTIONS")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
@@ -74,9 +79,17 @@ func HandleSpoofingConfig(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 }
 
-// HandleSpoofingSwitch handles requests to toggle spoofing on/off
+// SpoofingSwitchRequest is the optional body of POST /api/spoofing/switch.
+// Without a body the switch toggles spoofing on or off.
+type SpoofingSwitchRequest struct {
+	Enabled     *bool  `json:"enabled"`
+	ServiceType string `json:"serviceType"`
+}
+
+// HandleSpoofingSwitch handles requests to toggle spoofing on/off or to switch
+// the service type it presents. The change applies to the next request.
 func HandleSpoofingSwitch(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	middleware.AllowOrigin(w, r)
 	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
@@ -92,25 +105,47 @@ func HandleSpoofingSwitch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get current configuration and toggle enabled state
-	config := spoofing.GetConfig()
-	config.Enabled = !config.Enabled
+	var req SpoofingSwitchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+	req.ServiceType = strings.ToLower(strings.TrimSpace(req.ServiceType))
+	if req.ServiceType != "" && !spoofing.IsValidServiceType(req.ServiceType) {
+		apierror.WriteErrorDetails(w, http.StatusBadRequest, apierror.CodeSpoofingInvalidServiceType,
+			"Unknown service type "+req.ServiceType, map[string]interface{}{"allowed": spoofing.ServiceTypes})
+		return
+	}
+
+	// Work on a copy so a rejected change leaves the live configuration alone
+	switched := *spoofing.GetConfig()
+	switch {
+	case req.Enabled != nil:
+		switched.Enabled = *req.Enabled
+	case req.ServiceType == "":
+		switched.Enabled = !switched.Enabled
+	}
+	if req.ServiceType != "" {
+		switched.ServiceType = req.ServiceType
+	}
 
 	// Validate and save configuration
-	if err := spoofing.SetConfig(config); err != nil {
-		logger.Warn("Failed to toggle spoofing: %v", err)
+	if err := spoofing.SetConfig(&switched); err != nil {
+		logger.Warn("Failed to switch spoofing: %v", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	logger.Info("Spoofing switched: enabled=%t, service type %s", switched.Enabled, switched.ServiceType)
+	config.NotifySpoofingChanged(switched.Enabled, switched.ServiceType)
 
 	// Return updated configuration
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(config)
+	json.NewEncoder(w).Encode(switched)
 }
 
 // HandleRegenerateAPIKey handles requests to regenerate the spoofing API key
 func HandleRegenerateAPIKey(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	middleware.AllowOrigin(w, r)
 	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
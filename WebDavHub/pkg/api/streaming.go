@@ -2,6 +2,7 @@ package api
 
 import (
 	"cinesync/pkg/logger"
+	"cinesync/pkg/mimetype"
 	"fmt"
 	"io"
 	"net/http"
@@ -81,22 +82,9 @@ func HandleStream(w http.ResponseWriter, r *http.Request) {
 	logger.Info("File size: %s", formatFileSize(fileInfo.Size()))
 
 	// Set content type based on file extension
-	contentType := "video/mp4" // default
-	switch strings.ToLower(filepath.Ext(decodedPath)) {
-	case ".mp4":
-		contentType = "video/mp4"
-	case ".webm":
-		contentType = "video/webm"
-	case ".mkv":
-		contentType = "video/x-matroska"
-	case ".avi":
-		contentType = "video/x-msvideo"
-	case ".mov":
-		contentType = "video/quicktime"
-	case ".wmv":
-		contentType = "video/x-ms-wmv"
-	case ".flv":
-		contentType = "video/x-flv"
+	contentType := mimetype.TypeByExtension(decodedPath)
+	if contentType == "" {
+		contentType = "video/mp4" // default
 	}
 	logger.Info("Content-Type: %s", contentType)
 
@@ -0,0 +1,284 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/db"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+	"cinesync/pkg/strm"
+)
+
+const symlinkManifestVersion = 1
+
+// Outcomes of restoring one manifest entry
+const (
+	manifestLinkRestored      = "restored"
+	manifestLinkUnchanged     = "unchanged"
+	manifestLinkConflict      = "conflict"
+	manifestLinkMissingTarget = "missing_target"
+	manifestLinkFailed        = "failed"
+)
+
+// SymlinkManifest is a snapshot of every symlink and .strm file under the
+// destination, independent of the database
+type SymlinkManifest struct {
+	Version     int                    `json:"version"`
+	CreatedAt   time.Time              `json:"createdAt"`
+	Destination string                 `json:"destination"`
+	Links       []SymlinkManifestEntry `json:"links"`
+}
+
+// SymlinkManifestEntry is one link, with its path relative to the destination
+// and its target exactly as stored in the link. For a .strm file Type is
+// manifestTypeStrm and Target is the URL or path the file holds.
+type SymlinkManifestEntry struct {
+	Path   string `json:"path"`
+	Target string `json:"target"`
+	Type   string `json:"type,omitempty"`
+}
+
+// manifestTypeStrm marks manifest entries of .strm files; entries without a
+// type are symlinks
+const manifestTypeStrm = "strm"
+
+// SymlinkRestoreResult is the outcome of restoring one manifest entry that
+// was not recreated or already in place
+type SymlinkRestoreResult struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// SymlinkRestoreResponse is the body of POST /api/maintenance/symlink-manifest/restore.
+// PermissionFailed is the part of Failed refused by file permissions.
+type SymlinkRestoreResponse struct {
+	Restored         int                    `json:"restored"`
+	Unchanged        int                    `json:"unchanged"`
+	Conflicts        int                    `json:"conflicts"`
+	MissingTargets   int                    `json:"missingTargets"`
+	Failed           int                    `json:"failed"`
+	PermissionFailed int                    `json:"permissionFailed"`
+	Results          []SymlinkRestoreResult `json:"results"`
+}
+
+// BuildSymlinkManifest walks destDir and records every symlink and .strm file
+// in it
+func BuildSymlinkManifest(destDir string) (*SymlinkManifest, error) {
+	manifest := &SymlinkManifest{
+		Version:     symlinkManifestVersion,
+		CreatedAt:   time.Now().UTC(),
+		Destination: destDir,
+		Links:       []SymlinkManifestEntry{},
+	}
+
+	err := filepath.WalkDir(destDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == destDir {
+				return err
+			}
+			logger.Warn("Skipping %s in symlink manifest: %v", path, err)
+			return nil
+		}
+		entry := SymlinkManifestEntry{}
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			entry.Target, err = os.Readlink(path)
+		case d.Type().IsRegular() && strings.EqualFold(filepath.Ext(path), strm.Extension):
+			entry.Type = manifestTypeStrm
+			entry.Target, err = strm.Read(path)
+		default:
+			return nil
+		}
+		if err != nil {
+			logger.Warn("Skipping %s in symlink manifest: %v", path, err)
+			return nil
+		}
+		rel, err := filepath.Rel(destDir, path)
+		if err != nil {
+			return err
+		}
+		entry.Path = filepath.ToSlash(rel)
+		manifest.Links = append(manifest.Links, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// RestoreSymlinkManifest recreates the links of manifest under destDir. Links
+// that already exist with the same target are left alone, and a path holding
+// anything else is reported as a conflict rather than replaced. A link is only
+// created when its target exists.
+func RestoreSymlinkManifest(destDir string, manifest *SymlinkManifest) SymlinkRestoreResponse {
+	response := SymlinkRestoreResponse{Results: []SymlinkRestoreResult{}}
+	for _, link := range manifest.Links {
+		status, err := restoreManifestLink(destDir, link)
+		switch status {
+		case manifestLinkRestored:
+			response.Restored++
+			continue
+		case manifestLinkUnchanged:
+			response.Unchanged++
+			continue
+		case manifestLinkConflict:
+			response.Conflicts++
+		case manifestLinkMissingTarget:
+			response.MissingTargets++
+		default:
+			response.Failed++
+			err = db.ClassifyPermissionError(err)
+			if db.IsPermissionError(err) {
+				response.PermissionFailed++
+			}
+		}
+
+		result := SymlinkRestoreResult{Path: link.Path, Status: status}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		response.Results = append(response.Results, result)
+	}
+	return response
+}
+
+func restoreManifestLink(destDir string, link SymlinkManifestEntry) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(link.Path))
+	if link.Path == "" || link.Target == "" || filepath.IsAbs(rel) || rel == "." ||
+		rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return manifestLinkFailed, fmt.Errorf("invalid manifest entry")
+	}
+	linkPath := filepath.Join(destDir, rel)
+	if link.Type == manifestTypeStrm {
+		return restoreManifestStrm(linkPath, link)
+	}
+
+	if info, err := os.Lstat(linkPath); err == nil {
+		if info.Mode()&os.ModeSymlink != 0 {
+			if current, err := os.Readlink(linkPath); err == nil && current == link.Target {
+				return manifestLinkUnchanged, nil
+			}
+			return manifestLinkConflict, fmt.Errorf("symlink points elsewhere")
+		}
+		return manifestLinkConflict, fmt.Errorf("path exists and is not a symlink")
+	} else if !os.IsNotExist(err) {
+		return manifestLinkFailed, err
+	}
+
+	target := link.Target
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(linkPath), target)
+	}
+	if _, err := os.Stat(target); err != nil {
+		return manifestLinkMissingTarget, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(linkPath), 0755); err != nil {
+		return manifestLinkFailed, err
+	}
+	if err := os.Symlink(link.Target, linkPath); err != nil {
+		return manifestLinkFailed, err
+	}
+	return manifestLinkRestored, nil
+}
+
+// restoreManifestStrm writes the .strm file of a manifest entry. Only a local
+// path it holds is checked for existence; URLs are played by the media server.
+func restoreManifestStrm(linkPath string, link SymlinkManifestEntry) (string, error) {
+	if info, err := os.Lstat(linkPath); err == nil {
+		if strm.IsFile(linkPath, info) {
+			if current, err := strm.Read(linkPath); err == nil && current == link.Target {
+				return manifestLinkUnchanged, nil
+			}
+			return manifestLinkConflict, fmt.Errorf(".strm file holds a different target")
+		}
+		return manifestLinkConflict, fmt.Errorf("path exists and is not a .strm file")
+	} else if !os.IsNotExist(err) {
+		return manifestLinkFailed, err
+	}
+
+	if filepath.IsAbs(link.Target) {
+		if _, err := os.Stat(link.Target); err != nil {
+			return manifestLinkMissingTarget, err
+		}
+	}
+	if err := strm.Write(linkPath, link.Target); err != nil {
+		return manifestLinkFailed, err
+	}
+	return manifestLinkRestored, nil
+}
+
+// HandleSymlinkManifest serves GET /api/maintenance/symlink-manifest, a
+// download of every symlink under the destination and where it points
+func HandleSymlinkManifest(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	destDir := env.GetString("DESTINATION_DIR", rootDir)
+	manifest, err := BuildSymlinkManifest(destDir)
+	if err != nil {
+		logger.Error("Failed to build symlink manifest of %s: %v", destDir, err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read the destination directory")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=symlink_manifest_%s.json", time.Now().Format("2006-01-02")))
+	json.NewEncoder(w).Encode(manifest)
+}
+
+// HandleSymlinkManifestRestore serves POST
+// /api/maintenance/symlink-manifest/restore, which recreates the missing
+// links of a manifest posted as the body
+func HandleSymlinkManifestRestore(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	var manifest SymlinkManifest
+	if err := json.NewDecoder(r.Body).Decode(&manifest); err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid manifest")
+		return
+	}
+	if manifest.Version != symlinkManifestVersion {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, fmt.Sprintf("Unsupported manifest version %d", manifest.Version))
+		return
+	}
+
+	destDir := env.GetString("DESTINATION_DIR", rootDir)
+	if info, err := os.Stat(destDir); err != nil || !info.IsDir() {
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Destination directory is not accessible")
+		return
+	}
+
+	response := RestoreSymlinkManifest(destDir, &manifest)
+	logger.Info("Restored %d symlinks from manifest: %d unchanged, %d conflicts, %d missing targets, %d failed",
+		response.Restored, response.Unchanged, response.Conflicts, response.MissingTargets, response.Failed)
+	if response.PermissionFailed > 0 {
+		logger.Warn("%d manifest symlink(s) could not be restored due to permissions, check the ownership and mount options of %s", response.PermissionFailed, destDir)
+	}
+	if response.Restored > 0 {
+		db.InvalidateFolderCache()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
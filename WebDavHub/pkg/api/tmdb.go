@@ -1,6 +1,8 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"io"
 	"net/http"
 	"net/url"
@@ -9,11 +11,14 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"cinesync/pkg/cache"
 	"cinesync/pkg/logger"
 	"encoding/json"
 	"fmt"
 	"strconv"
 	"cinesync/pkg/db"
+	"cinesync/pkg/env"
+	"cinesync/pkg/httpclient"
 )
 
 // WithTmdbValidation wraps TMDB handlers with common validation and queue management
@@ -76,9 +81,60 @@ var tmdbRateWindow = 10 * time.Second
 var tmdbRateMap = make(map[string][]time.Time)
 var tmdbRateMu sync.Mutex
 
-// HTTP client for faster TMDB requests
-var tmdbHttpClient = &http.Client{
-	Timeout: 5 * time.Second,
+// HTTP client for faster TMDB requests. Deadlines are set per call by tmdbGet.
+var tmdbHttpClient = httpclient.New(0)
+
+const defaultTmdbTimeout = 5 * time.Second
+
+// tmdbTimeout returns the deadline for a single TMDB request
+func tmdbTimeout() time.Duration {
+	timeout, err := time.ParseDuration(env.GetString("CINESYNC_TMDB_TIMEOUT", defaultTmdbTimeout.String()))
+	if err != nil || timeout <= 0 {
+		return defaultTmdbTimeout
+	}
+	return timeout
+}
+
+// tmdbGet issues a GET bound to ctx and the configured TMDB timeout, so a
+// hung upstream or a disconnected client releases the request
+func tmdbGet(ctx context.Context, rawURL string) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, tmdbTimeout())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp, err := tmdbHttpClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The deadline also covers reading the body, so cancel once it is closed
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose releases a request context when the response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// writeTmdbError answers 504 when TMDB did not respond in time, so clients
+// know the request can be retried, and 502 for any other upstream failure
+func writeTmdbError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "TMDb request timed out", http.StatusGatewayTimeout)
+		return
+	}
+	http.Error(w, message, http.StatusBadGateway)
 }
 
 // Global TMDB request queue to allow concurrent processing
@@ -88,11 +144,35 @@ var tmdbQueueMu sync.Mutex
 var tmdbRequestCounter = 0
 var tmdbCounterMu sync.Mutex
 
-// Simple in-memory cache for details
-var tmdbDetailsCache = struct {
-	mu    sync.Mutex
-	items map[string][]byte
-}{items: make(map[string][]byte)}
+const tmdbDetailsCachePrefix = "tmdb:details:"
+
+const defaultTmdbCacheTTL = 24 * time.Hour
+
+// tmdbCacheTTL returns how long fetched details stay cached
+func tmdbCacheTTL() time.Duration {
+	ttl, err := time.ParseDuration(env.GetString("CINESYNC_TMDB_CACHE_TTL", defaultTmdbCacheTTL.String()))
+	if err != nil || ttl < 0 {
+		return defaultTmdbCacheTTL
+	}
+	return ttl
+}
+
+// getCachedTmdbDetails looks up details in the shared cache; cache errors count as a miss
+func getCachedTmdbDetails(cacheKey string) ([]byte, bool) {
+	data, ok, err := cache.Default().Get(tmdbDetailsCachePrefix + cacheKey)
+	if err != nil {
+		logger.Warn("TMDB cache lookup failed: %v", err)
+		return nil, false
+	}
+	return data, ok
+}
+
+// cacheTmdbDetails stores details in the shared cache
+func cacheTmdbDetails(cacheKey string, body []byte) {
+	if err := cache.Default().Set(tmdbDetailsCachePrefix+cacheKey, body, tmdbCacheTTL()); err != nil {
+		logger.Warn("TMDB cache store failed: %v", err)
+	}
+}
 
 // Initialize the TMDB queue
 func initTmdbQueue() {
@@ -202,10 +282,10 @@ func HandleTmdbProxy(w http.ResponseWriter, r *http.Request, tmdbApiKey string)
 		tmdbUrl = "https://api.themoviedb.org/3/search/movie?" + params.Encode()
 	}
 
-	resp, err := tmdbHttpClient.Get(tmdbUrl)
+	resp, err := tmdbGet(r.Context(), tmdbUrl)
 	if err != nil {
 		logger.Warn("Error forwarding to TMDb: %v", err)
-		http.Error(w, "Failed to contact TMDb", http.StatusBadGateway)
+		writeTmdbError(w, err, "Failed to contact TMDb")
 		return
 	}
 	defer resp.Body.Close()
@@ -230,16 +310,13 @@ func HandleTmdbDetails(w http.ResponseWriter, r *http.Request, tmdbApiKey string
 
 		// Only check cache if skipCache is false
 		if !skipCache {
-			tmdbDetailsCache.mu.Lock()
-			if data, ok := tmdbDetailsCache.items[cacheKey]; ok {
-				tmdbDetailsCache.mu.Unlock()
+			if data, ok := getCachedTmdbDetails(cacheKey); ok {
 				w.Header().Set("Content-Type", "application/json")
 				w.Header().Set("X-TMDB-Details-Cache", "HIT")
 				// Don't re-cache data that's already cached - reduces excessive DB writes
 				w.Write(data)
 				return
 			}
-			tmdbDetailsCache.mu.Unlock()
 		}
 		// Fetch details directly by ID
 		var detailsUrl string
@@ -247,20 +324,20 @@ func HandleTmdbDetails(w http.ResponseWriter, r *http.Request, tmdbApiKey string
 		var err error
 		if mediaType == "tv" {
 			detailsUrl = "https://api.themoviedb.org/3/tv/" + id + "?api_key=" + url.QueryEscape(tmdbApiKey) + "&append_to_response=credits,keywords"
-			resp, err = tmdbHttpClient.Get(detailsUrl)
+			resp, err = tmdbGet(r.Context(), detailsUrl)
 		} else if mediaType == "movie" {
 			detailsUrl = "https://api.themoviedb.org/3/movie/" + id + "?api_key=" + url.QueryEscape(tmdbApiKey) + "&append_to_response=credits,keywords"
-			resp, err = tmdbHttpClient.Get(detailsUrl)
+			resp, err = tmdbGet(r.Context(), detailsUrl)
 		} else {
 			// Try TV first, then fallback to movie if not found
 			detailsUrl = "https://api.themoviedb.org/3/tv/" + id + "?api_key=" + url.QueryEscape(tmdbApiKey) + "&append_to_response=credits,keywords"
-			resp, err = tmdbHttpClient.Get(detailsUrl)
+			resp, err = tmdbGet(r.Context(), detailsUrl)
 			if err != nil || resp.StatusCode != 200 {
 				if resp != nil {
 					resp.Body.Close()
 				}
 				detailsUrl = "https://api.themoviedb.org/3/movie/" + id + "?api_key=" + url.QueryEscape(tmdbApiKey) + "&append_to_response=credits,keywords"
-				resp, err = tmdbHttpClient.Get(detailsUrl)
+				resp, err = tmdbGet(r.Context(), detailsUrl)
 			}
 		}
 		if err != nil || resp.StatusCode != 200 {
@@ -269,7 +346,7 @@ func HandleTmdbDetails(w http.ResponseWriter, r *http.Request, tmdbApiKey string
 			} else {
 				logger.Warn("TMDb details fetch by ID failed - HTTP %d: ID '%s' not found for media type '%s'", resp.StatusCode, id, mediaType)
 			}
-			http.Error(w, "Failed to fetch details from TMDb", http.StatusBadGateway)
+			writeTmdbError(w, err, "Failed to fetch details from TMDb")
 			return
 		}
 		defer resp.Body.Close()
@@ -279,6 +356,12 @@ func HandleTmdbDetails(w http.ResponseWriter, r *http.Request, tmdbApiKey string
 			return
 		}
 
+		if strings.Contains(detailsUrl, "/3/movie/") {
+			if err := recordMovieCollection(id, body); err != nil {
+				logger.Debug("Failed to record collection of movie %s: %v", id, err)
+			}
+		}
+
 		// If TV, fetch episodes for each season or specific episodes
 		if mediaType == "tv" {
 			var details map[string]interface{}
@@ -296,7 +379,7 @@ func HandleTmdbDetails(w http.ResponseWriter, r *http.Request, tmdbApiKey string
 						}
 
 						seasonUrl := "https://api.themoviedb.org/3/tv/" + id + "/season/" + fmt.Sprintf("%d", int(sn)) + "?api_key=" + url.QueryEscape(tmdbApiKey)
-						seasonResp, err := tmdbHttpClient.Get(seasonUrl)
+						seasonResp, err := tmdbGet(r.Context(), seasonUrl)
 						if err == nil && seasonResp.StatusCode == 200 {
 							seasonBody, _ := io.ReadAll(seasonResp.Body)
 							seasonResp.Body.Close()
@@ -337,9 +420,7 @@ func HandleTmdbDetails(w http.ResponseWriter, r *http.Request, tmdbApiKey string
 		// Only cache if skipCache is false
 		if !skipCache {
 			// Store in id-based cache (format for DB cache)
-			tmdbDetailsCache.mu.Lock()
-			tmdbDetailsCache.items[cacheKey] = body
-			tmdbDetailsCache.mu.Unlock()
+			cacheTmdbDetails(cacheKey, body)
 
 			// Format and upsert for persistent DB cache
 			var tmdbObj map[string]interface{}
@@ -392,15 +473,12 @@ func HandleTmdbDetails(w http.ResponseWriter, r *http.Request, tmdbApiKey string
 
 	// Only check cache if skipCache is false
 	if !skipCache {
-		tmdbDetailsCache.mu.Lock()
-		if data, ok := tmdbDetailsCache.items[cacheKey]; ok {
-			tmdbDetailsCache.mu.Unlock()
+		if data, ok := getCachedTmdbDetails(cacheKey); ok {
 			w.Header().Set("Content-Type", "application/json")
 			w.Header().Set("X-TMDB-Details-Cache", "HIT")
 			w.Write(data)
 			return
 		}
-		tmdbDetailsCache.mu.Unlock()
 	}
 
 	// 1. Search for the movie/TV show to get the ID
@@ -409,10 +487,10 @@ func HandleTmdbDetails(w http.ResponseWriter, r *http.Request, tmdbApiKey string
 		searchType = "tv"
 	}
 	searchUrl := "https://api.themoviedb.org/3/search/" + searchType + "?api_key=" + url.QueryEscape(tmdbApiKey) + "&query=" + url.QueryEscape(query) + "&include_adult=false"
-	resp, err := tmdbHttpClient.Get(searchUrl)
+	resp, err := tmdbGet(r.Context(), searchUrl)
 	if err != nil || resp.StatusCode != 200 {
 		logger.Warn("TMDb search failed: %v", err)
-		http.Error(w, "Failed to search TMDb", http.StatusBadGateway)
+		writeTmdbError(w, err, "Failed to search TMDb")
 		return
 	}
 	defer resp.Body.Close()
@@ -436,14 +514,14 @@ func HandleTmdbDetails(w http.ResponseWriter, r *http.Request, tmdbApiKey string
 	} else {
 		detailsUrl = "https://api.themoviedb.org/3/movie/" + id + "?api_key=" + url.QueryEscape(tmdbApiKey) + "&append_to_response=credits,keywords"
 	}
-	detailsResp, err := tmdbHttpClient.Get(detailsUrl)
+	detailsResp, err := tmdbGet(r.Context(), detailsUrl)
 	if err != nil || detailsResp.StatusCode != 200 {
 		if err != nil {
 			logger.Warn("TMDb details fetch failed after search - Network error: %v", err)
 		} else {
 			logger.Warn("TMDb details fetch failed after search - HTTP %d: ID '%s' not found", detailsResp.StatusCode, id)
 		}
-		http.Error(w, "Failed to fetch details from TMDb", http.StatusBadGateway)
+		writeTmdbError(w, err, "Failed to fetch details from TMDb")
 		return
 	}
 	defer detailsResp.Body.Close()
@@ -464,7 +542,7 @@ func HandleTmdbDetails(w http.ResponseWriter, r *http.Request, tmdbApiKey string
 					sn, ok := season["season_number"].(float64)
 					if !ok { continue }
 					seasonUrl := "https://api.themoviedb.org/3/tv/" + id + "/season/" + fmt.Sprintf("%d", int(sn)) + "?api_key=" + url.QueryEscape(tmdbApiKey)
-					seasonResp, err := tmdbHttpClient.Get(seasonUrl)
+					seasonResp, err := tmdbGet(r.Context(), seasonUrl)
 					if err == nil && seasonResp.StatusCode == 200 {
 						seasonBody, _ := io.ReadAll(seasonResp.Body)
 						seasonResp.Body.Close()
@@ -484,9 +562,7 @@ func HandleTmdbDetails(w http.ResponseWriter, r *http.Request, tmdbApiKey string
 
 	// Only cache if skipCache is false
 	if !skipCache {
-		tmdbDetailsCache.mu.Lock()
-		tmdbDetailsCache.items[cacheKey] = body
-		tmdbDetailsCache.mu.Unlock()
+		cacheTmdbDetails(cacheKey, body)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -549,10 +625,10 @@ func HandleTmdbCategoryContent(w http.ResponseWriter, r *http.Request, tmdbApiKe
 
 	tmdbUrl := endpoint + "?" + params.Encode()
 
-	resp, err := tmdbHttpClient.Get(tmdbUrl)
+	resp, err := tmdbGet(r.Context(), tmdbUrl)
 	if err != nil {
 		logger.Warn("Error fetching category content from TMDb: %v", err)
-		http.Error(w, "Failed to contact TMDb", http.StatusBadGateway)
+		writeTmdbError(w, err, "Failed to contact TMDb")
 		return
 	}
 	defer resp.Body.Close()
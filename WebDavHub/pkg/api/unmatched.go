@@ -0,0 +1,232 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/db"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+	"cinesync/pkg/paging"
+)
+
+var seasonEpisodePattern = regexp.MustCompile(`^[Ss]\d{1,3}[Ee]\d{1,4}$`)
+
+// UnmatchedMatchRequest applies a manual match to an unmatched file. At least
+// one ID is required; mediaType forces MediaHub to treat the file as a movie
+// or a show.
+type UnmatchedMatchRequest struct {
+	Path          string `json:"path"`
+	TmdbID        string `json:"tmdbId,omitempty"`
+	ImdbID        string `json:"imdbId,omitempty"`
+	TvdbID        string `json:"tvdbId,omitempty"`
+	MediaType     string `json:"mediaType,omitempty"`
+	SeasonEpisode string `json:"seasonEpisode,omitempty"`
+}
+
+// UnmatchedIgnoreRequest removes a file from the unmatched queue for good
+type UnmatchedIgnoreRequest struct {
+	Path string `json:"path"`
+}
+
+// args returns the MediaHub arguments that reprocess the file with the IDs
+func (req *UnmatchedMatchRequest) args() ([]string, error) {
+	args := []string{"../MediaHub/main.py", req.Path, "--force", "--auto-select", "--disable-monitor"}
+
+	switch strings.ToLower(req.MediaType) {
+	case "":
+	case "movie":
+		args = append(args, "--force-movie")
+	case "tv", "show":
+		args = append(args, "--force-show")
+	default:
+		return nil, errors.New("mediaType must be movie or tv")
+	}
+
+	if req.TmdbID == "" && req.ImdbID == "" && req.TvdbID == "" {
+		return nil, errors.New("one of tmdbId, imdbId or tvdbId is required")
+	}
+	if req.TmdbID != "" {
+		if !isDigits(req.TmdbID) {
+			return nil, errors.New("tmdbId must be numeric")
+		}
+		args = append(args, "--tmdb", req.TmdbID)
+	}
+	if req.ImdbID != "" {
+		if !strings.HasPrefix(req.ImdbID, "tt") || !isDigits(req.ImdbID[2:]) {
+			return nil, errors.New("imdbId must look like tt0000000")
+		}
+		args = append(args, "--imdb", req.ImdbID)
+	}
+	if req.TvdbID != "" {
+		if !isDigits(req.TvdbID) {
+			return nil, errors.New("tvdbId must be numeric")
+		}
+		args = append(args, "--tvdb", req.TvdbID)
+	}
+	if req.SeasonEpisode != "" {
+		if !seasonEpisodePattern.MatchString(req.SeasonEpisode) {
+			return nil, errors.New("seasonEpisode must look like S01E02")
+		}
+		args = append(args, "--season-episode", strings.ToUpper(req.SeasonEpisode))
+	}
+	return args, nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// HandleUnmatched serves GET /api/database/unmatched, the queue of files
+// MediaHub could not identify together with what it parsed from them
+func HandleUnmatched(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	page := paging.Parse(r, 50, 500)
+	files, total, err := db.ListUnmatched(strings.TrimSpace(r.URL.Query().Get("search")), page)
+	if err != nil {
+		logger.Error("Failed to list unmatched files: %v", err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list unmatched files")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(paging.NewResponse(files, total, page))
+}
+
+// HandleUnmatchedMatch serves POST /api/database/unmatched/match. It reruns
+// MediaHub on the file with the chosen IDs and answers once the file has
+// left the unmatched queue.
+func HandleUnmatchedMatch(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	var req UnmatchedMatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if req.Path == "" {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "path is required")
+		return
+	}
+	args, err := req.args()
+	if err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	if !requireUnmatched(w, req.Path) {
+		return
+	}
+
+	logger.Info("Applying manual match to unmatched file: %s %v", req.Path, args[2:])
+	ctx, cancel := context.WithTimeout(r.Context(), env.BridgeTimeout())
+	defer cancel()
+	output, err := exec.CommandContext(ctx, getPythonCommand(), args...).CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		logger.Warn("Manual match timed out for: %s", req.Path)
+		apierror.WriteError(w, http.StatusGatewayTimeout, apierror.CodeInternal, "Manual match timed out, please retry")
+		return
+	}
+
+	if _, lookupErr := db.GetUnmatched(req.Path); !errors.Is(lookupErr, db.ErrUnmatchedNotFound) {
+		if err == nil {
+			err = lookupErr
+		}
+		logger.Warn("Manual match did not resolve %s: %v", req.Path, err)
+		apierror.WriteErrorDetails(w, http.StatusUnprocessableEntity, apierror.CodeUnmatchedMatchFailed,
+			"MediaHub could not link the file with the chosen IDs", map[string]string{"output": string(output)})
+		return
+	}
+
+	logger.Info("Manual match applied to: %s", req.Path)
+	if err := db.RecordIdentificationOverride(req.Path, req.TmdbID); err != nil {
+		logger.Warn("Failed to record manual match of %s: %v", req.Path, err)
+	}
+	db.NotifyLibraryChanged()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ProcessingResponse{
+		Success: true,
+		Message: "Manual match applied",
+		Details: string(output),
+	})
+}
+
+// HandleUnmatchedIgnore serves POST /api/database/unmatched/ignore. Ignored
+// files are recorded as skipped by the user, so MediaHub does not pick them up
+// again and they no longer show in the queue.
+func HandleUnmatchedIgnore(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	var req UnmatchedIgnoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if req.Path == "" {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "path is required")
+		return
+	}
+
+	if err := db.IgnoreUnmatched(req.Path); err != nil {
+		if errors.Is(err, db.ErrUnmatchedNotFound) {
+			apierror.WriteError(w, http.StatusNotFound, apierror.CodeNotFound, err.Error())
+			return
+		}
+		logger.Error("Failed to ignore unmatched file %s: %v", req.Path, err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to ignore file")
+		return
+	}
+
+	logger.Info("Unmatched file ignored: %s", req.Path)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ProcessingResponse{
+		Success: true,
+		Message: "File ignored",
+	})
+}
+
+// requireUnmatched answers 404 unless the path is in the unmatched queue
+func requireUnmatched(w http.ResponseWriter, path string) bool {
+	_, err := db.GetUnmatched(path)
+	if errors.Is(err, db.ErrUnmatchedNotFound) {
+		apierror.WriteError(w, http.StatusNotFound, apierror.CodeNotFound, err.Error())
+		return false
+	}
+	if err != nil {
+		logger.Error("Failed to look up unmatched file %s: %v", path, err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to look up file")
+		return false
+	}
+	return true
+}
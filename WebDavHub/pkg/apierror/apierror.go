@@ -0,0 +1,257 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Code is a stable, machine-readable error identifier. Clients should branch
+// on the code and only show the message to users.
+type Code string
+
+// General codes
+const (
+	CodeMethodNotAllowed Code = "METHOD_NOT_ALLOWED"
+	CodeInvalidRequest   Code = "INVALID_REQUEST"
+	CodeNotFound         Code = "NOT_FOUND"
+	CodeInternal         Code = "INTERNAL_ERROR"
+)
+
+// Authentication codes
+const (
+	CodeAuthMissingToken       Code = "AUTH_MISSING_TOKEN"
+	CodeAuthInvalidToken       Code = "AUTH_INVALID_TOKEN"
+	CodeAuthInvalidCredentials Code = "AUTH_INVALID_CREDENTIALS"
+	CodeAuthForbidden          Code = "AUTH_FORBIDDEN"
+	CodeAuthPasswordPolicy     Code = "AUTH_PASSWORD_POLICY"
+	CodeAuthPasswordManaged    Code = "AUTH_PASSWORD_MANAGED"
+	CodeAuthInviteInvalid      Code = "AUTH_INVITE_INVALID"
+	CodeAuthInviteExpired      Code = "AUTH_INVITE_EXPIRED"
+	CodeAuthInviteUsed         Code = "AUTH_INVITE_USED"
+	CodeAuthInvalidUsername    Code = "AUTH_INVALID_USERNAME"
+	CodeAuthUserExists         Code = "AUTH_USER_EXISTS"
+	CodeAuthLockedOut          Code = "AUTH_LOCKED_OUT"
+	CodeAuthSAMLInvalid        Code = "AUTH_SAML_INVALID"
+	CodeAuthUserStoreInvalid   Code = "AUTH_USER_STORE_INVALID"
+	CodeAuthCSRFInvalid        Code = "AUTH_CSRF_INVALID"
+)
+
+// Configuration codes
+const (
+	CodeConfigValidationFailed   Code = "CONFIG_VALIDATION_FAILED"
+	CodeConfigUnknownKey         Code = "CONFIG_UNKNOWN_KEY"
+	CodeConfigLocked             Code = "CONFIG_LOCKED"
+	CodeConfigPreconditionNeeded Code = "CONFIG_PRECONDITION_REQUIRED"
+	CodeConfigConflict           Code = "CONFIG_CONFLICT"
+	CodeConfigWriteFailed        Code = "CONFIG_WRITE_FAILED"
+)
+
+// File operation codes
+const (
+	CodeFileOpInvalid          Code = "FILEOP_INVALID"
+	CodeFileOpUnknownOperation Code = "FILEOP_UNKNOWN_OPERATION"
+	CodeFileOpConflict         Code = "FILEOP_CONFLICT"
+	CodeFileOpNotFound         Code = "FILEOP_NOT_FOUND"
+	CodeFileOpDatabase         Code = "FILEOP_DATABASE_ERROR"
+	CodeFileOpPermissionDenied Code = "FILEOP_PERMISSION_DENIED"
+)
+
+// Import codes
+const (
+	CodeImportUpstream Code = "IMPORT_UPSTREAM_ERROR"
+)
+
+// Metadata provider codes
+const (
+	CodeMetadataRateLimited Code = "METADATA_RATE_LIMITED"
+)
+
+// Database dump codes
+const (
+	CodeDatabaseDumpIncompatible Code = "DATABASE_DUMP_INCOMPATIBLE"
+)
+
+// Database prune codes
+const (
+	CodePruneConfirmationRequired Code = "PRUNE_CONFIRMATION_REQUIRED"
+	CodePruneConfirmationInvalid  Code = "PRUNE_CONFIRMATION_INVALID"
+	CodePruneInProgress           Code = "PRUNE_IN_PROGRESS"
+)
+
+// Unmatched file codes
+const (
+	CodeUnmatchedMatchFailed Code = "UNMATCHED_MATCH_FAILED"
+)
+
+// Collection codes
+const (
+	CodeCollectionRefreshInProgress Code = "COLLECTION_REFRESH_IN_PROGRESS"
+)
+
+// Reidentify codes
+const (
+	CodeReidentifyInProgress Code = "REIDENTIFY_IN_PROGRESS"
+)
+
+// Reconcile codes
+const (
+	CodeReconcileInProgress Code = "RECONCILE_IN_PROGRESS"
+)
+
+// Operation registry codes
+const (
+	CodeOperationConflict Code = "OPERATION_CONFLICT"
+)
+
+// Spoofing codes
+const (
+	CodeSpoofingInvalidServiceType Code = "SPOOFING_INVALID_SERVICE_TYPE"
+)
+
+// Image proxy codes
+const (
+	CodeImageHostNotAllowed Code = "IMAGE_HOST_NOT_ALLOWED"
+	CodeImageFetchFailed    Code = "IMAGE_FETCH_FAILED"
+)
+
+// Idempotency codes
+const (
+	CodeIdempotencyKeyInvalid Code = "IDEMPOTENCY_KEY_INVALID"
+	CodeIdempotencyKeyReused  Code = "IDEMPOTENCY_KEY_REUSED"
+	CodeIdempotencyInProgress Code = "IDEMPOTENCY_IN_PROGRESS"
+)
+
+// Notification codes
+const (
+	CodeWebhooksNotConfigured Code = "WEBHOOKS_NOT_CONFIGURED"
+)
+
+// Disk space codes
+const (
+	CodeInsufficientSpace Code = "INSUFFICIENT_SPACE"
+)
+
+// CodeInfo documents an error code
+type CodeInfo struct {
+	Code        Code   `json:"code"`
+	Status      int    `json:"status"`
+	Description string `json:"description"`
+}
+
+// codes lists every code with the HTTP status it is normally sent with
+var codes = []CodeInfo{
+	{CodeMethodNotAllowed, http.StatusMethodNotAllowed, "The endpoint does not support the request method"},
+	{CodeInvalidRequest, http.StatusBadRequest, "The request body or parameters are malformed"},
+	{CodeNotFound, http.StatusNotFound, "The requested resource does not exist"},
+	{CodeInternal, http.StatusInternalServerError, "The server failed to complete the request"},
+	{CodeAuthMissingToken, http.StatusUnauthorized, "No bearer token or token parameter was sent"},
+	{CodeAuthInvalidToken, http.StatusUnauthorized, "The token is invalid or has expired"},
+	{CodeAuthInvalidCredentials, http.StatusUnauthorized, "The username or password is wrong"},
+	{CodeAuthForbidden, http.StatusForbidden, "The user is not allowed to perform the action"},
+	{CodeAuthPasswordPolicy, http.StatusBadRequest, "The password does not meet the password policy; details.rule names the rule"},
+	{CodeAuthPasswordManaged, http.StatusBadRequest, "The password is managed by the environment and cannot be changed here"},
+	{CodeAuthInviteInvalid, http.StatusUnauthorized, "The invite is malformed or its signature is wrong"},
+	{CodeAuthInviteExpired, http.StatusGone, "The invite has expired"},
+	{CodeAuthInviteUsed, http.StatusGone, "The invite has already been used"},
+	{CodeAuthInvalidUsername, http.StatusBadRequest, "The username is not allowed"},
+	{CodeAuthUserExists, http.StatusConflict, "A user with that name already exists"},
+	{CodeAuthLockedOut, http.StatusTooManyRequests, "Too many failed logins from the client or for the account; Retry-After says when to try again"},
+	{CodeAuthSAMLInvalid, http.StatusUnauthorized, "The SAML response failed signature, issuer, audience, validity or replay checks"},
+	{CodeAuthUserStoreInvalid, http.StatusUnprocessableEntity, "The users file is malformed; the users loaded before stay in effect"},
+	{CodeAuthCSRFInvalid, http.StatusForbidden, "A state-changing request authenticated by the session cookie lacks the X-CSRF-Token header matching the CSRF cookie"},
+	{CodeConfigValidationFailed, http.StatusBadRequest, "A configuration value failed validation"},
+	{CodeConfigUnknownKey, http.StatusBadRequest, "The configuration key is not defined; details.key names it"},
+	{CodeConfigLocked, http.StatusForbidden, "The configuration key is locked; details.key and details.lockedBy describe it"},
+	{CodeConfigPreconditionNeeded, http.StatusPreconditionRequired, "The If-Match header is required"},
+	{CodeConfigConflict, http.StatusPreconditionFailed, "The configuration changed since it was read; reload and retry"},
+	{CodeConfigWriteFailed, http.StatusInternalServerError, "The configuration file could not be saved"},
+	{CodeFileOpInvalid, http.StatusBadRequest, "The file operation request is missing required fields"},
+	{CodeFileOpUnknownOperation, http.StatusBadRequest, "The file operation is not one of the supported operations"},
+	{CodeFileOpConflict, http.StatusConflict, "The file operation conflicts with the current state"},
+	{CodeFileOpNotFound, http.StatusNotFound, "The file operation or batch does not exist"},
+	{CodeFileOpDatabase, http.StatusInternalServerError, "The file operation could not be read from or written to the database"},
+	{CodeFileOpPermissionDenied, http.StatusForbidden, "The file system refused the operation; the message names the folder and the uid that cannot write to it"},
+	{CodeImportUpstream, http.StatusBadGateway, "The Sonarr or Radarr instance could not be reached or rejected the request"},
+	{CodeMetadataRateLimited, http.StatusTooManyRequests, "Too many metadata provider requests from the client; Retry-After says when to try again"},
+	{CodeDatabaseDumpIncompatible, http.StatusUnprocessableEntity, "The dump has a different schema version, an unknown column or a malformed line"},
+	{CodePruneConfirmationRequired, http.StatusPreconditionRequired, "Deleting needs the confirmationToken returned by a dry run"},
+	{CodePruneConfirmationInvalid, http.StatusPreconditionFailed, "The confirmation token is unknown, expired or was issued for a different selection"},
+	{CodePruneInProgress, http.StatusConflict, "Another prune job is still running"},
+	{CodeUnmatchedMatchFailed, http.StatusUnprocessableEntity, "MediaHub could not link the file with the chosen IDs; details.output has its log"},
+	{CodeCollectionRefreshInProgress, http.StatusConflict, "Collection membership is already being refreshed"},
+	{CodeReidentifyInProgress, http.StatusConflict, "The library is already being reidentified"},
+	{CodeReconcileInProgress, http.StatusConflict, "A reconciliation of the database against the disk is already running"},
+	{CodeOperationConflict, http.StatusConflict, "A conflicting job is running on the same library; details.blockingJob names it"},
+	{CodeSpoofingInvalidServiceType, http.StatusBadRequest, "The service type is not one spoofing can present; details.allowed lists the valid ones"},
+	{CodeImageHostNotAllowed, http.StatusForbidden, "The image URL is not on a host listed in CINESYNC_IMAGE_PROXY_HOSTS"},
+	{CodeImageFetchFailed, http.StatusBadGateway, "The remote image could not be fetched"},
+	{CodeIdempotencyKeyInvalid, http.StatusBadRequest, "The Idempotency-Key header is longer than 200 characters"},
+	{CodeIdempotencyKeyReused, http.StatusUnprocessableEntity, "The Idempotency-Key was already used for a different request"},
+	{CodeIdempotencyInProgress, http.StatusConflict, "The first request with this Idempotency-Key is still running; retry after Retry-After"},
+	{CodeWebhooksNotConfigured, http.StatusConflict, "No webhooks are configured in CINESYNC_WEBHOOK_URLS"},
+	{CodeInsufficientSpace, http.StatusInsufficientStorage, "The destination has less free space than CINESYNC_MIN_FREE_BYTES or CINESYNC_MIN_FREE_PERCENT reserve"},
+}
+
+// Error is the body of every structured error response
+type Error struct {
+	Code    Code        `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// WriteError writes a structured error response
+func WriteError(w http.ResponseWriter, status int, code Code, message string) {
+	WriteErrorDetails(w, status, code, message, nil)
+}
+
+// WriteErrorDetails writes a structured error response carrying details
+func WriteErrorDetails(w http.ResponseWriter, status int, code Code, message string, details interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Error{Code: code, Message: message, Details: details})
+}
+
+// MethodNotAllowed writes the error for an unsupported request method. The
+// Allow header lists the methods the endpoint does support.
+func MethodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+	}
+	WriteError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+}
+
+// RequireMethod reports whether the request uses one of the allowed methods,
+// answering 405 Method Not Allowed with an Allow header when it does not
+func RequireMethod(w http.ResponseWriter, r *http.Request, allowed ...string) bool {
+	for _, method := range allowed {
+		if r.Method == method {
+			return true
+		}
+	}
+	MethodNotAllowed(w, allowed...)
+	return false
+}
+
+// Codes returns the documented error codes sorted by code
+func Codes() []CodeInfo {
+	list := make([]CodeInfo, len(codes))
+	copy(list, codes)
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Code < list[j].Code
+	})
+	return list
+}
+
+// HandleCodes serves GET /api/errors, the list of error codes
+func HandleCodes(w http.ResponseWriter, r *http.Request) {
+	if !RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"codes": Codes(),
+	})
+}
@@ -0,0 +1,60 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireMethodAnswersWithAllow(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	if RequireMethod(recorder, httptest.NewRequest(http.MethodDelete, "/", nil), http.MethodGet, http.MethodPost) {
+		t.Fatal("DELETE was allowed")
+	}
+	if recorder.Code != http.StatusMethodNotAllowed || recorder.Header().Get("Allow") != "GET, POST" {
+		t.Fatalf("answered %d with Allow %q", recorder.Code, recorder.Header().Get("Allow"))
+	}
+
+	var body Error
+	if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil || body.Code != CodeMethodNotAllowed {
+		t.Fatalf("body = %+v, %v", body, err)
+	}
+}
+
+func TestWriteErrorDetails(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	WriteErrorDetails(recorder, http.StatusConflict, CodeConfigLocked, "Locked", map[string]string{"key": "TMDB_API_KEY"})
+
+	if recorder.Code != http.StatusConflict || recorder.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("answered %d with Content-Type %q", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	var body struct {
+		Code    Code              `json:"code"`
+		Message string            `json:"message"`
+		Details map[string]string `json:"details"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != CodeConfigLocked || body.Message != "Locked" || body.Details["key"] != "TMDB_API_KEY" {
+		t.Fatalf("body = %+v", body)
+	}
+}
+
+func TestCodesAreSortedAndDocumentedOnce(t *testing.T) {
+	seen := make(map[Code]bool)
+	list := Codes()
+	for i, info := range list {
+		if seen[info.Code] {
+			t.Errorf("%s is documented twice", info.Code)
+		}
+		seen[info.Code] = true
+		if info.Status < 400 || info.Description == "" {
+			t.Errorf("%s has status %d and description %q", info.Code, info.Status, info.Description)
+		}
+		if i > 0 && list[i-1].Code > info.Code {
+			t.Errorf("%s is listed after %s", info.Code, list[i-1].Code)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package arrimport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cinesync/pkg/httpclient"
+)
+
+// requestTimeout bounds each call to the Sonarr or Radarr API
+const requestTimeout = 60 * time.Second
+
+// client talks to the v3 API shared by Sonarr and Radarr
+type client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+func newClient(baseURL, apiKey string) *client {
+	return &client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: httpclient.New(requestTimeout),
+	}
+}
+
+// get fetches an API path and decodes the JSON response into out
+func (c *client) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	endpoint := c.baseURL + "/api/v3/" + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Api-Key", c.apiKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("%s rejected the API key", path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if message := strings.TrimSpace(string(body)); message != "" {
+			return fmt.Errorf("%s returned status %d: %s", path, resp.StatusCode, message)
+		}
+		return fmt.Errorf("%s returned status %d", path, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", path, err)
+	}
+	return nil
+}
+
+// radarrMovie is a movie as returned by GET /api/v3/movie
+type radarrMovie struct {
+	ID        int    `json:"id"`
+	Title     string `json:"title"`
+	Year      int    `json:"year"`
+	TmdbID    int    `json:"tmdbId"`
+	ImdbID    string `json:"imdbId"`
+	HasFile   bool   `json:"hasFile"`
+	MovieFile *struct {
+		Path string `json:"path"`
+		Size int64  `json:"size"`
+	} `json:"movieFile"`
+}
+
+// sonarrSeries is a series as returned by GET /api/v3/series. tmdbId is only
+// sent by Sonarr v4.
+type sonarrSeries struct {
+	ID     int    `json:"id"`
+	Title  string `json:"title"`
+	Year   int    `json:"year"`
+	TvdbID int    `json:"tvdbId"`
+	TmdbID int    `json:"tmdbId"`
+	ImdbID string `json:"imdbId"`
+}
+
+// sonarrEpisodeFile is a file as returned by GET /api/v3/episodefile
+type sonarrEpisodeFile struct {
+	ID           int    `json:"id"`
+	SeasonNumber int    `json:"seasonNumber"`
+	Path         string `json:"path"`
+	Size         int64  `json:"size"`
+}
+
+// sonarrEpisode is an episode as returned by GET /api/v3/episode
+type sonarrEpisode struct {
+	SeasonNumber  int  `json:"seasonNumber"`
+	EpisodeNumber int  `json:"episodeNumber"`
+	EpisodeFileID int  `json:"episodeFileId"`
+	HasFile       bool `json:"hasFile"`
+}
+
+func (c *client) movies(ctx context.Context) ([]radarrMovie, error) {
+	var movies []radarrMovie
+	err := c.get(ctx, "movie", nil, &movies)
+	return movies, err
+}
+
+func (c *client) series(ctx context.Context) ([]sonarrSeries, error) {
+	var series []sonarrSeries
+	err := c.get(ctx, "series", nil, &series)
+	return series, err
+}
+
+func (c *client) episodeFiles(ctx context.Context, seriesID int) ([]sonarrEpisodeFile, error) {
+	var files []sonarrEpisodeFile
+	err := c.get(ctx, "episodefile", url.Values{"seriesId": {strconv.Itoa(seriesID)}}, &files)
+	return files, err
+}
+
+func (c *client) episodes(ctx context.Context, seriesID int) ([]sonarrEpisode, error) {
+	var episodes []sonarrEpisode
+	err := c.get(ctx, "episode", url.Values{"seriesId": {strconv.Itoa(seriesID)}}, &episodes)
+	return episodes, err
+}
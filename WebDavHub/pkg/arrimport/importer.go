@@ -0,0 +1,379 @@
+package arrimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cinesync/pkg/db"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+)
+
+// Supported source applications
+const (
+	AppSonarr = "sonarr"
+	AppRadarr = "radarr"
+)
+
+// Item statuses reported in the import summary
+const (
+	StatusImported    = "imported"
+	StatusWouldImport = "would_import"
+	StatusSkipped     = "skipped"
+	StatusFailed      = "failed"
+)
+
+// ErrUpstream wraps failures to read the library from Sonarr or Radarr, as
+// opposed to failures of single items
+var ErrUpstream = errors.New("failed to read library from source application")
+
+// PathMapping rewrites paths as seen by Sonarr/Radarr into paths as seen by
+// CineSync, for when the applications run in different containers
+type PathMapping struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Request is the body of POST /api/import/arr
+type Request struct {
+	App          string        `json:"app"`
+	BaseURL      string        `json:"baseUrl"`
+	APIKey       string        `json:"apiKey"`
+	PathMappings []PathMapping `json:"pathMappings,omitempty"`
+	DryRun       bool          `json:"dryRun,omitempty"`
+}
+
+// Validate checks the request fields
+func (r *Request) Validate() error {
+	r.App = strings.ToLower(strings.TrimSpace(r.App))
+	if r.App != AppSonarr && r.App != AppRadarr {
+		return fmt.Errorf("app must be sonarr or radarr")
+	}
+	if r.BaseURL == "" || !(strings.HasPrefix(r.BaseURL, "http://") || strings.HasPrefix(r.BaseURL, "https://")) {
+		return fmt.Errorf("baseUrl must be an http or https URL")
+	}
+	if r.APIKey == "" {
+		return fmt.Errorf("apiKey is required")
+	}
+	return nil
+}
+
+// ItemResult is the outcome of importing one movie or episode file
+type ItemResult struct {
+	Title           string `json:"title"`
+	SourcePath      string `json:"sourcePath,omitempty"`
+	DestinationPath string `json:"destinationPath,omitempty"`
+	Status          string `json:"status"`
+	Reason          string `json:"reason,omitempty"`
+}
+
+// Summary is the result of an import run. PermissionFailed counts the failed
+// items refused by file permissions or a read-only file system.
+type Summary struct {
+	App              string       `json:"app"`
+	DryRun           bool         `json:"dryRun"`
+	Total            int          `json:"total"`
+	Imported         int          `json:"imported"`
+	Skipped          int          `json:"skipped"`
+	Failed           int          `json:"failed"`
+	PermissionFailed int          `json:"permissionFailed"`
+	Items            []ItemResult `json:"items"`
+}
+
+func (s *Summary) add(item ItemResult) {
+	s.Total++
+	switch item.Status {
+	case StatusImported, StatusWouldImport:
+		s.Imported++
+	case StatusSkipped:
+		s.Skipped++
+	case StatusFailed:
+		s.Failed++
+	}
+	s.Items = append(s.Items, item)
+}
+
+// addFailure adds an item that failed with err, explaining permission errors
+func (s *Summary) addFailure(item ItemResult, action string, err error) {
+	err = db.ClassifyPermissionError(err)
+	if db.IsPermissionError(err) {
+		s.PermissionFailed++
+	}
+	item.Status, item.Reason = StatusFailed, fmt.Sprintf("%s: %v", action, err)
+	s.add(item)
+}
+
+// importer links the files of one Sonarr or Radarr library into DESTINATION_DIR
+type importer struct {
+	request Request
+	client  *client
+	destDir string
+	tmdbTag bool
+	summary *Summary
+}
+
+// Import pulls the movies or series of a Sonarr/Radarr instance and links
+// their files into the CineSync layout under DESTINATION_DIR, recording each
+// file in the MediaHub database with the metadata the application already
+// matched. Items fail independently; only failing to read the library at all
+// returns an error.
+func Import(ctx context.Context, request Request) (*Summary, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	destDir := env.GetString("DESTINATION_DIR", "")
+	if destDir == "" {
+		return nil, fmt.Errorf("DESTINATION_DIR is not configured")
+	}
+
+	imp := &importer{
+		request: request,
+		client:  newClient(request.BaseURL, request.APIKey),
+		destDir: destDir,
+		tmdbTag: env.IsBool("TMDB_FOLDER_ID", true),
+		summary: &Summary{App: request.App, DryRun: request.DryRun, Items: []ItemResult{}},
+	}
+
+	logger.Info("Importing %s library from %s (dry run: %t)", request.App, request.BaseURL, request.DryRun)
+
+	var err error
+	if request.App == AppRadarr {
+		err = imp.importMovies(ctx)
+	} else {
+		err = imp.importSeries(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	summary := imp.summary
+	logger.Info("Imported %s library: %d imported, %d skipped, %d failed", request.App, summary.Imported, summary.Skipped, summary.Failed)
+	if summary.PermissionFailed > 0 {
+		logger.Warn("%d %s import item(s) failed on permissions, check the ownership and mount options of DESTINATION_DIR", summary.PermissionFailed, request.App)
+	}
+	if summary.Imported > 0 && !request.DryRun {
+		db.NotifyLibraryChanged()
+	}
+	return summary, nil
+}
+
+func (imp *importer) importMovies(ctx context.Context) error {
+	movies, err := imp.client.movies(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+
+	movieFolder := env.GetString("CUSTOM_MOVIE_FOLDER", "Movies")
+	for _, movie := range movies {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		name := titleWithYear(movie.Title, movie.Year)
+		if !movie.HasFile || movie.MovieFile == nil || movie.MovieFile.Path == "" {
+			imp.summary.add(ItemResult{Title: name, Status: StatusSkipped, Reason: "movie has no file"})
+			continue
+		}
+
+		source := imp.mapPath(movie.MovieFile.Path)
+		folder := name
+		if imp.tmdbTag && movie.TmdbID > 0 {
+			folder += fmt.Sprintf(" {tmdb-%d}", movie.TmdbID)
+		}
+		destination := filepath.Join(imp.destDir, movieFolder, folder, name+filepath.Ext(source))
+
+		imp.linkAndRecord(ItemResult{Title: name, SourcePath: source, DestinationPath: destination}, db.ProcessedFileRecord{
+			SourcePath:      source,
+			DestinationPath: destination,
+			BasePath:        movieFolder,
+			TmdbID:          positiveID(movie.TmdbID),
+			ImdbID:          movie.ImdbID,
+			MediaType:       "movie",
+			ProperName:      name,
+			Year:            positiveID(movie.Year),
+			FileSize:        movie.MovieFile.Size,
+		})
+	}
+	return nil
+}
+
+func (imp *importer) importSeries(ctx context.Context) error {
+	seriesList, err := imp.client.series(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+
+	showFolder := env.GetString("CUSTOM_SHOW_FOLDER", "Shows")
+	for _, series := range seriesList {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		name := titleWithYear(series.Title, series.Year)
+
+		files, err := imp.client.episodeFiles(ctx, series.ID)
+		if err == nil && len(files) == 0 {
+			imp.summary.add(ItemResult{Title: name, Status: StatusSkipped, Reason: "series has no files"})
+			continue
+		}
+		var episodes []sonarrEpisode
+		if err == nil {
+			episodes, err = imp.client.episodes(ctx, series.ID)
+		}
+		if err != nil {
+			// One unreadable series fails on its own without stopping the import
+			imp.summary.add(ItemResult{Title: name, Status: StatusFailed, Reason: err.Error()})
+			continue
+		}
+
+		episodeNumbers := make(map[int][]int)
+		for _, episode := range episodes {
+			if episode.HasFile && episode.EpisodeFileID > 0 {
+				episodeNumbers[episode.EpisodeFileID] = append(episodeNumbers[episode.EpisodeFileID], episode.EpisodeNumber)
+			}
+		}
+
+		folder := name
+		if imp.tmdbTag && series.TmdbID > 0 {
+			folder += fmt.Sprintf(" {tmdb-%d}", series.TmdbID)
+		}
+
+		for _, file := range files {
+			numbers := episodeNumbers[file.ID]
+			if len(numbers) == 0 {
+				imp.summary.add(ItemResult{Title: name, SourcePath: imp.mapPath(file.Path), Status: StatusSkipped, Reason: "file is not linked to an episode"})
+				continue
+			}
+
+			sort.Ints(numbers)
+			episodeCode := fmt.Sprintf("S%02d", file.SeasonNumber)
+			for _, number := range numbers {
+				episodeCode += fmt.Sprintf("E%02d", number)
+			}
+
+			source := imp.mapPath(file.Path)
+			destination := filepath.Join(imp.destDir, showFolder, folder,
+				fmt.Sprintf("Season %d", file.SeasonNumber),
+				sanitizeName(series.Title)+" - "+episodeCode+filepath.Ext(source))
+
+			imp.linkAndRecord(ItemResult{Title: name + " " + episodeCode, SourcePath: source, DestinationPath: destination}, db.ProcessedFileRecord{
+				SourcePath:      source,
+				DestinationPath: destination,
+				BasePath:        showFolder,
+				TmdbID:          positiveID(series.TmdbID),
+				TvdbID:          positiveID(series.TvdbID),
+				ImdbID:          series.ImdbID,
+				MediaType:       "tv",
+				ProperName:      name,
+				Year:            positiveID(series.Year),
+				SeasonNumber:    strconv.Itoa(file.SeasonNumber),
+				EpisodeNumber:   strconv.Itoa(numbers[0]),
+				FileSize:        file.Size,
+			})
+		}
+	}
+	return nil
+}
+
+// linkAndRecord symlinks one file into place and records it, adding the
+// outcome to the summary
+func (imp *importer) linkAndRecord(item ItemResult, record db.ProcessedFileRecord) {
+	if _, err := os.Stat(item.SourcePath); err != nil {
+		item.Status, item.Reason = StatusFailed, fmt.Sprintf("source file is not accessible: %v", err)
+		imp.summary.add(item)
+		return
+	}
+
+	alreadyLinked := false
+	if _, err := os.Lstat(item.DestinationPath); err == nil {
+		target, readErr := os.Readlink(item.DestinationPath)
+		if readErr != nil || target != item.SourcePath {
+			item.Status, item.Reason = StatusFailed, "destination already exists"
+			imp.summary.add(item)
+			return
+		}
+		alreadyLinked = true
+	}
+
+	if imp.request.DryRun {
+		item.Status = StatusWouldImport
+		if alreadyLinked {
+			item.Status, item.Reason = StatusSkipped, "already linked"
+		}
+		imp.summary.add(item)
+		return
+	}
+
+	if !alreadyLinked {
+		if err := os.MkdirAll(filepath.Dir(item.DestinationPath), 0755); err != nil {
+			imp.summary.addFailure(item, "failed to create destination folder", err)
+			return
+		}
+		if err := os.Symlink(item.SourcePath, item.DestinationPath); err != nil {
+			imp.summary.addFailure(item, "failed to create symlink", err)
+			return
+		}
+	}
+
+	// Already linked files are recorded again so the database catches up
+	if err := db.RecordProcessedFile(record); err != nil {
+		logger.Warn("Failed to record imported file %s: %v", item.SourcePath, err)
+		item.Status, item.Reason = StatusFailed, err.Error()
+		imp.summary.add(item)
+		return
+	}
+
+	item.Status = StatusImported
+	if alreadyLinked {
+		item.Status, item.Reason = StatusSkipped, "already linked"
+	}
+	imp.summary.add(item)
+}
+
+// mapPath applies the first matching path mapping
+func (imp *importer) mapPath(path string) string {
+	for _, mapping := range imp.request.PathMappings {
+		from := strings.TrimRight(mapping.From, "/\\")
+		if from == "" {
+			continue
+		}
+		if path == from || strings.HasPrefix(path, from+"/") || strings.HasPrefix(path, from+"\\") {
+			return filepath.FromSlash(strings.TrimRight(mapping.To, "/\\") + path[len(from):])
+		}
+	}
+	return path
+}
+
+// titleWithYear formats a title the way MediaHub names title folders
+func titleWithYear(title string, year int) string {
+	title = sanitizeName(title)
+	if year > 0 {
+		return fmt.Sprintf("%s (%d)", title, year)
+	}
+	return title
+}
+
+// sanitizeName removes characters that are not allowed in file names
+func sanitizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) {
+			return -1
+		}
+		return r
+	}, name)
+	return strings.TrimSpace(name)
+}
+
+func positiveID(id int) string {
+	if id <= 0 {
+		return ""
+	}
+	return strconv.Itoa(id)
+}
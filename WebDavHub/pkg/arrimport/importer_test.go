@@ -0,0 +1,104 @@
+package arrimport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestRadarr serves movies from GET /api/v3/movie to clients sending apiKey
+func newTestRadarr(t *testing.T, apiKey string, movies []radarrMovie) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != apiKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/v3/movie" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(movies)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func testMovie(title string, year int, path string) radarrMovie {
+	movie := radarrMovie{Title: title, Year: year, TmdbID: 603, HasFile: path != ""}
+	if path != "" {
+		movie.MovieFile = &struct {
+			Path string `json:"path"`
+			Size int64  `json:"size"`
+		}{Path: path}
+	}
+	return movie
+}
+
+func TestDryRunReportsWithoutLinking(t *testing.T) {
+	source := t.TempDir()
+	destination := t.TempDir()
+	t.Setenv("DESTINATION_DIR", destination)
+	t.Setenv("CUSTOM_MOVIE_FOLDER", "Movies")
+	t.Setenv("TMDB_FOLDER_ID", "true")
+	if err := os.WriteFile(filepath.Join(source, "matrix.mkv"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	baseURL := newTestRadarr(t, "key", []radarrMovie{
+		testMovie("The Matrix", 1999, "/radarr/matrix.mkv"),
+		testMovie("Missing: File", 2001, "/radarr/missing.mkv"),
+		testMovie("No File", 2002, ""),
+	})
+	summary, err := Import(context.Background(), Request{
+		App:          "Radarr",
+		BaseURL:      baseURL,
+		APIKey:       "key",
+		PathMappings: []PathMapping{{From: "/radarr/", To: source}},
+		DryRun:       true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary.Total != 3 || summary.Imported != 1 || summary.Failed != 1 || summary.Skipped != 1 {
+		t.Fatalf("summary = %+v", summary)
+	}
+	want := filepath.Join(destination, "Movies", "The Matrix (1999) {tmdb-603}", "The Matrix (1999).mkv")
+	if item := summary.Items[0]; item.Status != StatusWouldImport || item.DestinationPath != want {
+		t.Fatalf("item = %+v, want would_import at %s", item, want)
+	}
+	if summary.Items[1].Title != "Missing File (2001)" {
+		t.Fatalf("title = %q, want illegal characters removed", summary.Items[1].Title)
+	}
+	if _, err := os.Lstat(want); !os.IsNotExist(err) {
+		t.Fatal("dry run created a link")
+	}
+}
+
+func TestRejectedAPIKeyIsAnUpstreamError(t *testing.T) {
+	t.Setenv("DESTINATION_DIR", t.TempDir())
+	baseURL := newTestRadarr(t, "key", nil)
+
+	_, err := Import(context.Background(), Request{App: AppRadarr, BaseURL: baseURL, APIKey: "wrong", DryRun: true})
+	if !errors.Is(err, ErrUpstream) {
+		t.Fatalf("Import = %v, want ErrUpstream", err)
+	}
+}
+
+func TestValidateRejectsIncompleteRequests(t *testing.T) {
+	for _, request := range []Request{
+		{App: "lidarr", BaseURL: "http://arr", APIKey: "key"},
+		{App: AppSonarr, BaseURL: "arr:8989", APIKey: "key"},
+		{App: AppSonarr, BaseURL: "http://arr"},
+	} {
+		if err := request.Validate(); err == nil {
+			t.Errorf("Validate(%+v) accepted the request", request)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+// Package artwork keeps posters, fanart and banners of library titles in a
+// local cache. Artwork is downloaded from a metadata provider while files are
+// processed, or on the first request for it, and served from disk afterwards,
+// so MediaCover requests keep working while the provider is unreachable.
+package artwork
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cinesync/pkg/env"
+	"cinesync/pkg/httpclient"
+	"cinesync/pkg/logger"
+)
+
+// Artwork types
+const (
+	TypePoster = "poster"
+	TypeFanart = "fanart"
+	TypeBanner = "banner"
+)
+
+// Media types a title can have
+const (
+	MediaMovie = "movie"
+	MediaTV    = "tv"
+)
+
+const (
+	// defaultDir is where MediaHub keeps MediaCover artwork as well
+	defaultDir = "../db/MediaCover"
+	// defaultTypes are downloaded during processing unless
+	// CINESYNC_ARTWORK_TYPES lists others
+	defaultTypes = "poster,fanart"
+	// maxArtworkSize bounds a downloaded image
+	maxArtworkSize = 20 * 1024 * 1024
+	// missingRetry is how long artwork a provider does not have is not asked
+	// for again
+	missingRetry = 6 * time.Hour
+)
+
+// knownTypes are the artwork types the cache stores
+var knownTypes = map[string]bool{TypePoster: true, TypeFanart: true, TypeBanner: true}
+
+// ErrNotFound is returned for artwork the provider does not have
+var ErrNotFound = errors.New("artwork not found")
+
+// Title identifies the title artwork belongs to. MediaType may be empty when
+// only the id is known; the cache then asks its resolver.
+type Title struct {
+	ID        string
+	MediaType string
+}
+
+// Provider looks up where a title's artwork can be downloaded
+type Provider interface {
+	// Name identifies the provider in logs
+	Name() string
+	// URL returns the remote address of the artwork of a type at a width in
+	// pixels, 0 for the provider's default size, or ErrNotFound
+	URL(ctx context.Context, title Title, artworkType string, width int) (string, error)
+}
+
+// Cache stores artwork under <dir>/<title id>/<type>.jpg, and size variants
+// under <type>-<width>.jpg, the layout Sonarr and Radarr use for MediaCover
+type Cache struct {
+	provider  Provider
+	mediaType func(id string) string
+	client    *http.Client
+
+	locks   sync.Map
+	mu      sync.Mutex
+	missing map[string]time.Time
+}
+
+// NewCache returns a cache downloading from provider. mediaType resolves the
+// media type of titles requested by id only and may be nil.
+func NewCache(provider Provider, mediaType func(id string) string) *Cache {
+	return &Cache{
+		provider:  provider,
+		mediaType: mediaType,
+		client:    httpclient.New(30 * time.Second),
+		missing:   make(map[string]time.Time),
+	}
+}
+
+var (
+	defaultCache   *Cache
+	defaultCacheMu sync.RWMutex
+)
+
+// SetDefault installs the cache MediaCover requests and processing use
+func SetDefault(cache *Cache) {
+	defaultCacheMu.Lock()
+	defaultCache = cache
+	defaultCacheMu.Unlock()
+}
+
+// Default returns the installed cache, nil before SetDefault
+func Default() *Cache {
+	defaultCacheMu.RLock()
+	defer defaultCacheMu.RUnlock()
+	return defaultCache
+}
+
+// Dir returns the cache directory from CINESYNC_ARTWORK_DIR
+func Dir() string {
+	return env.GetString("CINESYNC_ARTWORK_DIR", defaultDir)
+}
+
+// DownloadEnabled reports whether artwork is downloaded while files are
+// processed, from CINESYNC_ARTWORK_DOWNLOAD
+func DownloadEnabled() bool {
+	return env.IsBool("CINESYNC_ARTWORK_DOWNLOAD", true)
+}
+
+// Types returns the artwork types downloaded during processing, from the
+// comma separated CINESYNC_ARTWORK_TYPES. Unknown types are ignored.
+func Types() []string {
+	var types []string
+	for _, t := range strings.Split(env.GetString("CINESYNC_ARTWORK_TYPES", defaultTypes), ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if knownTypes[t] {
+			types = append(types, t)
+		} else if t != "" {
+			logger.Warn("Ignoring unknown artwork type in CINESYNC_ARTWORK_TYPES: %s", t)
+		}
+	}
+	return types
+}
+
+// ParseFileName splits a MediaCover file name such as poster.jpg or
+// fanart-360.jpg into its artwork type and width, 0 for the default size
+func ParseFileName(name string) (string, int, bool) {
+	base := strings.TrimSuffix(strings.ToLower(name), filepath.Ext(name))
+	artworkType, widthText, hasWidth := strings.Cut(base, "-")
+	if !knownTypes[artworkType] {
+		return "", 0, false
+	}
+	if !hasWidth {
+		return artworkType, 0, true
+	}
+	width, err := strconv.Atoi(widthText)
+	if err != nil || width <= 0 {
+		return "", 0, false
+	}
+	return artworkType, width, true
+}
+
+// validID reports whether id can name a cache directory: a positive number,
+// so a request cannot reach outside the cache
+func validID(id string) bool {
+	n, err := strconv.Atoi(id)
+	return err == nil && n > 0
+}
+
+// Path returns where the artwork of a type and width is cached
+func Path(id, artworkType string, width int) string {
+	name := artworkType + ".jpg"
+	if width > 0 {
+		name = fmt.Sprintf("%s-%d.jpg", artworkType, width)
+	}
+	return filepath.Join(Dir(), id, name)
+}
+
+// Get returns the local path of a title's artwork, downloading it first when
+// it is not cached yet. A size variant the provider cannot deliver falls back
+// to the cached default size.
+func (c *Cache) Get(ctx context.Context, title Title, artworkType string, width int) (string, error) {
+	if !validID(title.ID) || !knownTypes[artworkType] {
+		return "", ErrNotFound
+	}
+	path, err := c.get(ctx, title, artworkType, width)
+	if err != nil && width > 0 {
+		if base, baseErr := c.get(ctx, title, artworkType, 0); baseErr == nil {
+			return base, nil
+		}
+	}
+	return path, err
+}
+
+// Fetch downloads the artwork types of a title that are not cached yet.
+// Missing artwork and provider failures are logged, not returned, since
+// processing carries on without artwork.
+func (c *Cache) Fetch(ctx context.Context, title Title, types []string) {
+	if !validID(title.ID) {
+		return
+	}
+	for _, artworkType := range types {
+		if _, err := c.get(ctx, title, artworkType, 0); err != nil && !errors.Is(err, ErrNotFound) {
+			logger.Warn("Failed to download %s artwork for %s: %v", artworkType, title.ID, err)
+		}
+	}
+}
+
+func (c *Cache) get(ctx context.Context, title Title, artworkType string, width int) (string, error) {
+	path := Path(title.ID, artworkType, width)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	// One download per file; later callers find it on disk
+	lock, _ := c.locks.LoadOrStore(path, &sync.Mutex{})
+	mutex := lock.(*sync.Mutex)
+	mutex.Lock()
+	defer mutex.Unlock()
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if c.isMissing(path) {
+		return "", ErrNotFound
+	}
+
+	if title.MediaType == "" && c.mediaType != nil {
+		title.MediaType = c.mediaType(title.ID)
+	}
+	remoteURL, err := c.provider.URL(ctx, title, artworkType, width)
+	if errors.Is(err, ErrNotFound) {
+		c.markMissing(path)
+		return "", err
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s lookup failed: %w", c.provider.Name(), err)
+	}
+	if err := c.download(ctx, remoteURL, path); err != nil {
+		return "", err
+	}
+	logger.Debug("Cached %s artwork for %s from %s", artworkType, title.ID, c.provider.Name())
+	return path, nil
+}
+
+// download stores the image at remoteURL in path, replacing it atomically so
+// readers never see a partial file
+func (c *Cache) download(ctx context.Context, remoteURL, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, remoteURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download artwork: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		c.markMissing(path)
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("artwork download returned %s", resp.Status)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" && !strings.HasPrefix(contentType, "image/") {
+		return fmt.Errorf("artwork download returned %s instead of an image", contentType)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create artwork directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".artwork-*")
+	if err != nil {
+		return fmt.Errorf("failed to create artwork file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, io.LimitReader(resp.Body, maxArtworkSize+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save artwork: %w", err)
+	}
+	if n > maxArtworkSize {
+		return fmt.Errorf("artwork is larger than %d bytes", maxArtworkSize)
+	}
+	if n == 0 {
+		return errors.New("artwork download was empty")
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *Cache) isMissing(path string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	until, ok := c.missing[path]
+	if ok && time.Now().After(until) {
+		delete(c.missing, path)
+		return false
+	}
+	return ok
+}
+
+func (c *Cache) markMissing(path string) {
+	c.mu.Lock()
+	c.missing[path] = time.Now().Add(missingRetry)
+	c.mu.Unlock()
+}
@@ -0,0 +1,111 @@
+package artwork
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeProvider serves every artwork from one URL, or reports it missing
+type fakeProvider struct {
+	url     string
+	lookups atomic.Int32
+}
+
+func (p *fakeProvider) Name() string { return "fake" }
+
+func (p *fakeProvider) URL(ctx context.Context, title Title, artworkType string, width int) (string, error) {
+	p.lookups.Add(1)
+	if width > 0 || artworkType == TypeBanner {
+		return "", ErrNotFound
+	}
+	return p.url, nil
+}
+
+func newTestCache(t *testing.T) (*Cache, *fakeProvider, *atomic.Int32) {
+	t.Helper()
+	t.Setenv("CINESYNC_ARTWORK_DIR", t.TempDir())
+	var downloads atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads.Add(1)
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write([]byte("jpeg"))
+	}))
+	t.Cleanup(server.Close)
+	provider := &fakeProvider{url: server.URL}
+	return NewCache(provider, nil), provider, &downloads
+}
+
+func TestGetDownloadsOnceAndServesFromDisk(t *testing.T) {
+	cache, _, downloads := newTestCache(t)
+	title := Title{ID: "603", MediaType: MediaMovie}
+
+	for i := 0; i < 2; i++ {
+		path, err := cache.Get(context.Background(), title, TypePoster, 0)
+		if err != nil || path != Path("603", TypePoster, 0) {
+			t.Fatalf("Get = %q, %v", path, err)
+		}
+	}
+	if data, _ := os.ReadFile(Path("603", TypePoster, 0)); string(data) != "jpeg" {
+		t.Fatalf("cached file holds %q", data)
+	}
+	if downloads.Load() != 1 {
+		t.Fatalf("downloaded %d times, want once", downloads.Load())
+	}
+}
+
+func TestGetFallsBackToDefaultSize(t *testing.T) {
+	cache, _, _ := newTestCache(t)
+	path, err := cache.Get(context.Background(), Title{ID: "603", MediaType: MediaMovie}, TypeFanart, 360)
+	if err != nil || path != Path("603", TypeFanart, 0) {
+		t.Fatalf("Get = %q, %v, want the default size", path, err)
+	}
+}
+
+func TestMissingArtworkIsNotAskedForAgain(t *testing.T) {
+	cache, provider, _ := newTestCache(t)
+	title := Title{ID: "603", MediaType: MediaMovie}
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Get(context.Background(), title, TypeBanner, 0); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get = %v, want ErrNotFound", err)
+		}
+	}
+	if provider.lookups.Load() != 1 {
+		t.Fatalf("provider asked %d times, want once", provider.lookups.Load())
+	}
+}
+
+func TestGetRejectsIDsOutsideTheCache(t *testing.T) {
+	cache, provider, _ := newTestCache(t)
+	for _, id := range []string{"../etc", "0", ""} {
+		if _, err := cache.Get(context.Background(), Title{ID: id}, TypePoster, 0); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q) = %v, want ErrNotFound", id, err)
+		}
+	}
+	if provider.lookups.Load() != 0 {
+		t.Fatal("provider was asked for an invalid id")
+	}
+}
+
+func TestParseFileName(t *testing.T) {
+	for name, want := range map[string]struct {
+		artworkType string
+		width       int
+		ok          bool
+	}{
+		"poster.jpg":     {TypePoster, 0, true},
+		"Fanart-360.jpg": {TypeFanart, 360, true},
+		"banner-0.jpg":   {"", 0, false},
+		"logo.png":       {"", 0, false},
+	} {
+		artworkType, width, ok := ParseFileName(name)
+		if artworkType != want.artworkType || width != want.width || ok != want.ok {
+			t.Errorf("ParseFileName(%q) = %q, %d, %v", name, artworkType, width, ok)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package artwork
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"cinesync/pkg/httpclient"
+)
+
+var (
+	// tmdbAPIBase is the TMDB API root image lists are read from
+	tmdbAPIBase = "https://api.themoviedb.org/3"
+	// tmdbImageBase is the root TMDB images are downloaded from
+	tmdbImageBase = "https://image.tmdb.org/t/p/"
+)
+
+// tmdbImageListTTL is how long a title's image list is reused for its other
+// artwork types and sizes
+const tmdbImageListTTL = 10 * time.Minute
+
+// tmdbWidths are the image widths TMDB renders per artwork type, smallest
+// first, and the width used by default. TMDB has no banners.
+var tmdbWidths = map[string]struct {
+	widths       []int
+	defaultWidth int
+}{
+	TypePoster: {[]int{92, 154, 185, 342, 500, 780}, 500},
+	TypeFanart: {[]int{300, 780, 1280}, 1280},
+}
+
+// tmdbImages is the part of /{movie|tv}/{id}/images the provider uses; TMDB
+// sorts each list by rating
+type tmdbImages struct {
+	Posters   []tmdbImage `json:"posters"`
+	Backdrops []tmdbImage `json:"backdrops"`
+}
+
+type tmdbImage struct {
+	FilePath string `json:"file_path"`
+}
+
+type tmdbImageList struct {
+	images    *tmdbImages
+	fetchedAt time.Time
+}
+
+// TMDBProvider finds artwork through the TMDB images API
+type TMDBProvider struct {
+	apiKey func() string
+	client *http.Client
+
+	mu    sync.Mutex
+	lists map[string]tmdbImageList
+}
+
+// NewTMDBProvider returns a provider authenticating with the key apiKey
+// returns at the time of each request
+func NewTMDBProvider(apiKey func() string) *TMDBProvider {
+	return &TMDBProvider{
+		apiKey: apiKey,
+		client: httpclient.New(10 * time.Second),
+		lists:  make(map[string]tmdbImageList),
+	}
+}
+
+// Name identifies the provider in logs
+func (p *TMDBProvider) Name() string {
+	return "TMDB"
+}
+
+// URL returns the TMDB image URL of a title's best rated artwork of a type,
+// rendered at the smallest TMDB width not below width
+func (p *TMDBProvider) URL(ctx context.Context, title Title, artworkType string, width int) (string, error) {
+	sizes, ok := tmdbWidths[artworkType]
+	if !ok {
+		return "", ErrNotFound
+	}
+	images, err := p.images(ctx, title)
+	if err != nil {
+		return "", err
+	}
+
+	var list []tmdbImage
+	switch artworkType {
+	case TypePoster:
+		list = images.Posters
+	case TypeFanart:
+		list = images.Backdrops
+	}
+	if len(list) == 0 || list[0].FilePath == "" {
+		return "", ErrNotFound
+	}
+
+	size := "original"
+	if width == 0 {
+		width = sizes.defaultWidth
+	}
+	for _, w := range sizes.widths {
+		if w >= width {
+			size = fmt.Sprintf("w%d", w)
+			break
+		}
+	}
+	return tmdbImageBase + size + list[0].FilePath, nil
+}
+
+// images returns the image list of a title, from memory when it was read
+// recently
+func (p *TMDBProvider) images(ctx context.Context, title Title) (*tmdbImages, error) {
+	kind := "movie"
+	if title.MediaType == MediaTV {
+		kind = "tv"
+	}
+	key := kind + "/" + title.ID
+
+	p.mu.Lock()
+	list, ok := p.lists[key]
+	p.mu.Unlock()
+	if ok && time.Since(list.fetchedAt) < tmdbImageListTTL {
+		return list.images, nil
+	}
+
+	query := url.Values{}
+	query.Set("api_key", p.apiKey())
+	query.Set("include_image_language", "en,null")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tmdbAPIBase+"/"+key+"/images?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("TMDB returned %s", resp.Status)
+	}
+
+	images := &tmdbImages{}
+	if err := json.NewDecoder(resp.Body).Decode(images); err != nil {
+		return nil, fmt.Errorf("failed to decode TMDB images: %w", err)
+	}
+
+	p.mu.Lock()
+	now := time.Now()
+	for k, l := range p.lists {
+		if now.Sub(l.fetchedAt) >= tmdbImageListTTL {
+			delete(p.lists, k)
+		}
+	}
+	p.lists[key] = tmdbImageList{images: images, fetchedAt: now}
+	p.mu.Unlock()
+	return images, nil
+}
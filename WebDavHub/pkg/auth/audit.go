@@ -0,0 +1,323 @@
+package auth
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/logger"
+	"cinesync/pkg/paging"
+)
+
+// Audit event types
+const (
+	AuditLogin          = "login"
+	AuditLoginFailed    = "login_failed"
+	AuditLoginLockedOut = "login_locked_out"
+	AuditRegister       = "register"
+	AuditAccessDenied   = "access_denied"
+	AuditConfigChange   = "config_change"
+)
+
+// Audit outcomes
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+const (
+	// memoryAuditSize is how many events the in-memory store keeps
+	memoryAuditSize = 10000
+	// maxAuditExport caps the events a CSV or JSON export returns
+	maxAuditExport = 100000
+)
+
+// AuditEvent is one authentication or authorization event
+type AuditEvent struct {
+	ID        int64     `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Type      string    `json:"type"`
+	Outcome   string    `json:"outcome"`
+	Username  string    `json:"username,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	Method    string    `json:"method,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	// Detail says how the client authenticated or why it was refused
+	Detail string `json:"detail,omitempty"`
+}
+
+// AuditFilter selects audit events. Empty fields match every event; Username
+// is matched without regard to case.
+type AuditFilter struct {
+	Types    []string
+	Username string
+	IP       string
+	Outcome  string
+	Since    time.Time
+	Until    time.Time
+}
+
+// Matches reports whether an event passes the filter
+func (f AuditFilter) Matches(event AuditEvent) bool {
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			if event.Type == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	switch {
+	case f.Username != "" && !strings.EqualFold(event.Username, f.Username):
+		return false
+	case f.IP != "" && event.IP != f.IP:
+		return false
+	case f.Outcome != "" && event.Outcome != f.Outcome:
+		return false
+	case !f.Since.IsZero() && event.Timestamp.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !event.Timestamp.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// AuditStore keeps the audit log
+type AuditStore interface {
+	// Append stores an event, assigning its id
+	Append(event AuditEvent) error
+	// Query returns the window of events matching filter, newest first, and
+	// the number of matching events. A window without a limit returns all.
+	Query(filter AuditFilter, window paging.Request) ([]AuditEvent, int, error)
+}
+
+// MemoryAuditStore is an AuditStore private to one process that keeps the
+// most recent events
+type MemoryAuditStore struct {
+	mutex  sync.Mutex
+	events []AuditEvent
+	nextID int64
+}
+
+// NewMemoryAuditStore creates an empty in-memory store
+func NewMemoryAuditStore() *MemoryAuditStore {
+	return &MemoryAuditStore{nextID: 1}
+}
+
+func (s *MemoryAuditStore) Append(event AuditEvent) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	event.ID = s.nextID
+	s.nextID++
+	s.events = append(s.events, event)
+	if len(s.events) > memoryAuditSize {
+		s.events = append([]AuditEvent(nil), s.events[len(s.events)-memoryAuditSize:]...)
+	}
+	return nil
+}
+
+func (s *MemoryAuditStore) Query(filter AuditFilter, window paging.Request) ([]AuditEvent, int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var matching []AuditEvent
+	for i := len(s.events) - 1; i >= 0; i-- {
+		if filter.Matches(s.events[i]) {
+			matching = append(matching, s.events[i])
+		}
+	}
+	return paging.Window(matching, window), len(matching), nil
+}
+
+var (
+	auditStore      AuditStore = NewMemoryAuditStore()
+	auditStoreMutex sync.RWMutex
+)
+
+// SetAuditStore replaces the store audit events are written to
+func SetAuditStore(store AuditStore) {
+	auditStoreMutex.Lock()
+	auditStore = store
+	auditStoreMutex.Unlock()
+}
+
+func getAuditStore() AuditStore {
+	auditStoreMutex.RLock()
+	defer auditStoreMutex.RUnlock()
+	return auditStore
+}
+
+// remoteHost returns the address of the client without its port
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// recordAudit writes an event about request r to the audit log. A store
+// failure is logged; it never fails the request.
+func recordAudit(eventType, outcome, username string, r *http.Request, detail string) {
+	event := AuditEvent{
+		Timestamp: time.Now().UTC(),
+		Type:      eventType,
+		Outcome:   outcome,
+		Username:  username,
+		IP:        remoteHost(r),
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Detail:    detail,
+	}
+	if err := getAuditStore().Append(event); err != nil {
+		logger.Warn("Failed to write %s audit event for '%s': %v", eventType, username, err)
+	}
+}
+
+// RecordConfigChange writes a configuration change made by request r to the
+// audit log. Only key names are recorded since values may hold secrets.
+func RecordConfigChange(r *http.Request, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	recordAudit(AuditConfigChange, AuditSuccess, RequestUsername(r), r, strings.Join(keys, ","))
+}
+
+// QueryAudit returns the window of audit events matching filter, newest
+// first, and the number of matching events
+func QueryAudit(filter AuditFilter, window paging.Request) ([]AuditEvent, int, error) {
+	return getAuditStore().Query(filter, window)
+}
+
+// parseAuditTime reads a since or until parameter given as RFC 3339 or as
+// unix seconds
+func parseAuditTime(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), true
+	}
+	return time.Time{}, false
+}
+
+// csvCell neutralizes a value a spreadsheet would run as a formula. Usernames
+// and paths come from clients, so a leading = + - @ tab or carriage return is
+// escaped with a quote.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// HandleAudit serves GET /api/auth/audit to administrators. Events are
+// filtered by type (comma separated), username, ip, outcome and the time range
+// since/until, and paged with page, pageSize or cursor. format=csv or
+// format=json downloads the matching events instead of a page, at most
+// maxAuditExport of them; X-Total-Count tells how many matched, and a cut
+// export sets X-Truncated and the X-Next-Cursor to continue from.
+func HandleAudit(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if !RequireAdmin(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := AuditFilter{
+		Username: strings.TrimSpace(query.Get("username")),
+		IP:       strings.TrimSpace(query.Get("ip")),
+		Outcome:  query.Get("outcome"),
+	}
+	for _, t := range strings.Split(query.Get("type"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			filter.Types = append(filter.Types, t)
+		}
+	}
+	if filter.Outcome != "" && filter.Outcome != AuditSuccess && filter.Outcome != AuditFailure {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "outcome must be success or failure")
+		return
+	}
+	var ok bool
+	if filter.Since, ok = parseAuditTime(query.Get("since")); !ok {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "since must be an RFC 3339 time or unix seconds")
+		return
+	}
+	if filter.Until, ok = parseAuditTime(query.Get("until")); !ok {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "until must be an RFC 3339 time or unix seconds")
+		return
+	}
+
+	format := query.Get("format")
+	if format != "" && format != "csv" && format != "json" {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "format must be csv or json")
+		return
+	}
+	window := paging.Parse(r, 50, 500)
+	if format != "" {
+		// Exports default to every event, up to the cap, and still honour
+		// an explicit window so larger logs can be fetched in parts
+		window = paging.Parse(r, maxAuditExport, maxAuditExport)
+	}
+
+	events, total, err := getAuditStore().Query(filter, window)
+	if err != nil {
+		logger.Error("Failed to query audit log: %v", err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to query audit log")
+		return
+	}
+
+	if format != "" {
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		if next := window.Offset + len(events); next < total {
+			w.Header().Set("X-Truncated", "true")
+			w.Header().Set("X-Next-Cursor", paging.EncodeCursor(next))
+		}
+	}
+
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", "attachment; filename=audit_log.csv")
+		csvWriter := csv.NewWriter(w)
+		defer csvWriter.Flush()
+		csvWriter.Write([]string{"ID", "Timestamp", "Type", "Outcome", "Username", "IP", "Method", "Path", "Detail"})
+		for _, event := range events {
+			csvWriter.Write([]string{
+				strconv.FormatInt(event.ID, 10),
+				event.Timestamp.Format(time.RFC3339),
+				csvCell(event.Type),
+				csvCell(event.Outcome),
+				csvCell(event.Username),
+				csvCell(event.IP),
+				csvCell(event.Method),
+				csvCell(event.Path),
+				csvCell(event.Detail),
+			})
+		}
+	case "json":
+		if events == nil {
+			events = []AuditEvent{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", "attachment; filename=audit_log.json")
+		json.NewEncoder(w).Encode(events)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(paging.NewResponse(events, total, window))
+	}
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useAuditEvents gives the test an audit log holding events
+func useAuditEvents(t *testing.T, events ...AuditEvent) {
+	t.Helper()
+	t.Setenv("CINESYNC_AUTH_ENABLED", "false")
+	previous := getAuditStore()
+	store := NewMemoryAuditStore()
+	for _, event := range events {
+		if event.Timestamp.IsZero() {
+			event.Timestamp = time.Now()
+		}
+		store.Append(event)
+	}
+	SetAuditStore(store)
+	t.Cleanup(func() { SetAuditStore(previous) })
+}
+
+func serveAudit(query string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	HandleAudit(recorder, httptest.NewRequest(http.MethodGet, "/api/auth/audit?"+query, nil))
+	return recorder
+}
+
+func TestAuditCSVNeutralizesFormulas(t *testing.T) {
+	useAuditEvents(t,
+		AuditEvent{Type: AuditLoginFailed, Outcome: AuditFailure, Username: "=HYPERLINK(\"http://evil\")"},
+		AuditEvent{Type: AuditLoginFailed, Outcome: AuditFailure, Username: "@SUM(A1)", Path: "-1+1"},
+	)
+
+	recorder := serveAudit("format=csv")
+	rows, err := csv.NewReader(recorder.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows[1:] {
+		for _, cell := range row {
+			if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+				t.Errorf("cell %q would run as a formula", cell)
+			}
+		}
+	}
+}
+
+func TestAuditExportReportsTruncation(t *testing.T) {
+	useAuditEvents(t,
+		AuditEvent{Type: AuditLogin, Outcome: AuditSuccess, Username: "a"},
+		AuditEvent{Type: AuditLogin, Outcome: AuditSuccess, Username: "b"},
+		AuditEvent{Type: AuditLogin, Outcome: AuditSuccess, Username: "c"},
+	)
+
+	cut := serveAudit("format=json&limit=2")
+	if cut.Header().Get("X-Total-Count") != "3" || cut.Header().Get("X-Truncated") != "true" {
+		t.Fatalf("cut export headers = %v, want a total of 3 and X-Truncated", cut.Header())
+	}
+	rest := serveAudit("format=json&limit=2&cursor=" + cut.Header().Get("X-Next-Cursor"))
+	if rest.Header().Get("X-Truncated") != "" {
+		t.Fatal("last part of the export is marked truncated")
+	}
+	if !strings.Contains(rest.Body.String(), `"username":"a"`) {
+		t.Fatalf("last part = %s, want the oldest event", rest.Body.String())
+	}
+
+	full := serveAudit("format=json")
+	if full.Header().Get("X-Truncated") != "" {
+		t.Fatal("complete export is marked truncated")
+	}
+}
+
+func TestAuditExportHonoursTimeRange(t *testing.T) {
+	now := time.Now()
+	useAuditEvents(t,
+		AuditEvent{Type: AuditLogin, Outcome: AuditSuccess, Username: "old", Timestamp: now.Add(-2 * time.Hour)},
+		AuditEvent{Type: AuditLogin, Outcome: AuditSuccess, Username: "new", Timestamp: now},
+	)
+
+	body := serveAudit("format=json&since=" + now.Add(-time.Hour).UTC().Format(time.RFC3339)).Body.String()
+	if strings.Contains(body, `"old"`) || !strings.Contains(body, `"new"`) {
+		t.Fatalf("export since an hour ago = %s", body)
+	}
+}
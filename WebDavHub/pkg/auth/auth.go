@@ -3,11 +3,15 @@ package auth
 import (
 	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
+	"cinesync/pkg/apierror"
 	"cinesync/pkg/env"
 	"cinesync/pkg/logger"
+	"cinesync/pkg/middleware"
 	"os"
 	"time"
 
@@ -30,103 +34,106 @@ func GetCredentials() Credentials {
 	}
 }
 
-// isAuthEndpoint checks if the request is for an authentication-related endpoint
-func isAuthEndpoint(path string) bool {
-	authEndpoints := []string{
-		"/api/health",
-		"/api/auth/enabled",
-		"/api/auth/test",
-		"/api/auth/login",
-		"/api/auth/check",
-		"/api/download",
-		"/api/config-status",
-		"/api/config",
-		"/api/config/update",
-		"/api/config/update-silent",
-		"/api/config/events",
-		"/api/mediahub/message",
-		"/api/mediahub/events",
-		"/api/mediahub/logs",
-		"/api/mediahub/logs/export",
-		"/api/file-operations",
-		"/api/file-operations/bulk",
-		"/api/file-operations/events",
-		"/api/source-browse",
-		"/api/database/source-files",
-		"/api/database/source-scans",
-		"/api/dashboard/events",
-		"/api/database/stats",
-		"/api/database/search",
-		"/api/database/export",
-		"/api/stats",
-		"/api/jobs",
-		"/api/python-bridge/terminate",
-		"/api/v3/system/status",
-		"/api/system/status",
-		"/api/v3/health",
-		"/api/v3/rootfolder",
-		"/api/v3/qualityprofile",
-		"/api/v3/language",
-		"/api/v3/languageprofile",
-		"/api/v3/tag",
-		"/api/v3/movie",
-		"/api/v3/moviefile",
-		"/api/v3/series",
-		"/api/v3/episode",
-		"/api/v3/episodefile",
-		"/api/v3/images/movies/MediaCover",
-		"/api/v3/images/series/MediaCover",
-		"/api/spoofing/config",
-		"/api/spoofing/switch",
-		"/api/spoofing/regenerate-key",
-		"/images/movies/MediaCover",
-		"/images/series/MediaCover",
-		"/MediaCover",
-		"/api",
-	}
-	for _, endpoint := range authEndpoints {
-		if path == endpoint {
-			return true
-		}
-		// Also check if path starts with endpoint followed by "/"
-		if strings.HasPrefix(path, endpoint+"/") {
-			return true
-		}
+// errInvalidCredentials is returned by validateCredentials for a wrong
+// username or password
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// loginLockedOutError is returned by validateCredentials while the client or
+// account is locked out after too many failures
+type loginLockedOutError struct {
+	until time.Time
+}
+
+func (e *loginLockedOutError) Error() string {
+	return "too many failed login attempts"
+}
+
+// retryAfter returns the Retry-After value for the lockout, in seconds
+func (e *loginLockedOutError) retryAfter() string {
+	return fmt.Sprintf("%d", int(time.Until(e.until).Seconds())+1)
+}
+
+// validateCredentials checks a login attempt from r, for the login page and
+// WebDAV alike, and returns the user's role. Attempts during a lockout are
+// refused without checking the password; failures count towards a lockout.
+func validateCredentials(username, password string, r *http.Request) (string, error) {
+	if until := loginLockedUntil(username, r); !until.IsZero() {
+		return "", &loginLockedOutError{until: until}
+	}
+	role, ok := checkCredentials(username, password)
+	if !ok {
+		recordLoginFailure(username, r)
+		return "", errInvalidCredentials
 	}
-	return false
+	recordLoginSuccess(username, r)
+	return role, nil
 }
 
-// validateCredentials checks if the provided credentials match the stored ones
-func validateCredentials(username, password string) bool {
+// checkCredentials checks the provided credentials against the environment
+// administrator and then the user store, returning the user's role
+func checkCredentials(username, password string) (string, bool) {
 	credentials := GetCredentials()
-	return subtle.ConstantTimeCompare([]byte(username), []byte(credentials.Username)) == 1 &&
-		subtle.ConstantTimeCompare([]byte(password), []byte(credentials.Password)) == 1
+	if subtle.ConstantTimeCompare([]byte(username), []byte(credentials.Username)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(credentials.Password)) == 1 {
+		return RoleAdmin, true
+	}
+	if user, ok := authenticateStoredUser(username, password); ok {
+		return user.Role, true
+	}
+	return "", false
 }
 
 // JWTClaims defines the structure for JWT claims
 type JWTClaims struct {
 	Username string `json:"username"`
+	Role     string `json:"role,omitempty"`
+	// Provider is providerSAML for tokens issued after a SAML login, whose
+	// users are not in the user store
+	Provider string `json:"provider,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateJWT generates a JWT for a given username
-func GenerateJWT(username string) (string, error) {
-	claims := JWTClaims{
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+// providerSAML marks tokens issued to users signed in by the identity provider
+const providerSAML = "saml"
+
+// tokenLifetime is how long a JWT stays valid after it is issued
+const tokenLifetime = 24 * time.Hour
+
+func init() {
+	// Issue times carry milliseconds, so a token issued just before a password
+	// change is told apart from the one issued right after it
+	jwt.TimePrecision = time.Millisecond
+}
+
+// GenerateJWT generates a JWT for a given username and role
+func GenerateJWT(username, role string) (string, error) {
+	token, _, err := generateJWTWithExpiry(username, role)
+	return token, err
+}
+
+// generateJWTWithExpiry generates a JWT and returns when it expires
+func generateJWTWithExpiry(username, role string) (string, time.Time, error) {
+	return signClaims(JWTClaims{Username: username, Role: role})
+}
+
+// signClaims stamps claims with their issue and expiry times and signs them
+func signClaims(claims JWTClaims) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := jwt.NewNumericDate(now.Add(tokenLifetime))
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: expiresAt,
+		IssuedAt:  jwt.NewNumericDate(now),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	signed, err := token.SignedString(jwtSecret)
+	return signed, expiresAt.Time, err
 }
 
 // JWTMiddleware protects endpoints with JWT auth
 func JWTMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Allow public endpoints
-		if isAuthEndpoint(r.URL.Path) || strings.HasPrefix(r.URL.Path, "/static/") {
+		if IsPublicPath(r.URL.Path) || strings.HasPrefix(r.URL.Path, "/static/") {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -141,85 +148,203 @@ func JWTMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		header := r.Header.Get("Authorization")
-		tokenStr := ""
-		if strings.HasPrefix(header, "Bearer ") {
-			tokenStr = strings.TrimPrefix(header, "Bearer ")
-		} else if token := r.URL.Query().Get("token"); token != "" {
-			tokenStr = token
-		}
-
-		if tokenStr == "" {
+		_, method, err := resolveRequestAuth(r)
+		if method == "" {
 			logger.Warn("Missing or invalid token for path: %s", r.URL.Path)
-			http.Error(w, "Missing or invalid Authorization header or token parameter", http.StatusUnauthorized)
+			apierror.WriteError(w, http.StatusUnauthorized, apierror.CodeAuthMissingToken, "Missing or invalid Authorization header or token parameter")
 			return
 		}
-
-		token, err := jwt.ParseWithClaims(tokenStr, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
-		if err != nil || !token.Valid {
+		if err != nil {
 			logger.Warn("Invalid or expired token for path %s: %v", r.URL.Path, err)
-			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+			apierror.WriteError(w, http.StatusUnauthorized, apierror.CodeAuthInvalidToken, "Invalid or expired token")
 			return
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// loginRequest is the body accepted by the login endpoint
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// loginResponse is returned by a successful login. It carries the profile
+// /api/me would return, so clients need no second request to start up.
+type loginResponse struct {
+	Token     string    `json:"token"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// CSRFToken is set when the login also started a cookie session
+	CSRFToken string `json:"csrfToken,omitempty"`
+}
+
 // HandleLogin handles the login endpoint (JWT version)
 func HandleLogin(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if !apierror.RequireMethod(w, r, http.MethodPost) {
 		return
 	}
-	var creds Credentials
-	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var creds loginRequest
+	if err := middleware.DecodeStrictJSON(r.Body, &creds); err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		logger.Warn("Invalid request body: %v", err)
 		return
 	}
-	if !validateCredentials(creds.Username, creds.Password) {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+	role, err := validateCredentials(creds.Username, creds.Password, r)
+	var lockedOut *loginLockedOutError
+	if errors.As(err, &lockedOut) {
+		w.Header().Set("Retry-After", lockedOut.retryAfter())
+		apierror.WriteError(w, http.StatusTooManyRequests, apierror.CodeAuthLockedOut, "Too many failed login attempts, try again later")
+		logger.Warn("Rejected login for user '%s' from %s during lockout", creds.Username, r.RemoteAddr)
+		recordAudit(AuditLoginLockedOut, AuditFailure, creds.Username, r, "password")
+		return
+	}
+	if err != nil {
+		apierror.WriteError(w, http.StatusUnauthorized, apierror.CodeAuthInvalidCredentials, "Invalid credentials")
 		logger.Warn("Failed login attempt for user '%s'", creds.Username)
+		recordAudit(AuditLoginFailed, AuditFailure, creds.Username, r, "password")
 		return
 	}
-	token, err := GenerateJWT(creds.Username)
+	token, expiresAt, err := generateJWTWithExpiry(creds.Username, role)
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to generate token")
 		logger.Warn("Failed to generate token for user '%s': %v", creds.Username, err)
 		return
 	}
+	csrf := setSessionCookies(w, r, token, expiresAt)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"token": token})
+	json.NewEncoder(w).Encode(loginResponse{
+		Token:     token,
+		Username:  creds.Username,
+		Role:      role,
+		ExpiresAt: expiresAt,
+		CSRFToken: csrf,
+	})
 	logger.Info("Successful login for user '%s'", creds.Username)
+	recordAudit(AuditLogin, AuditSuccess, creds.Username, r, "password")
 }
 
-// HandleAuthCheck checks if the JWT is valid
-func HandleAuthCheck(w http.ResponseWriter, r *http.Request) {
-	header := r.Header.Get("Authorization")
-	valid := false
-	if strings.HasPrefix(header, "Bearer ") {
-		tokenStr := strings.TrimPrefix(header, "Bearer ")
+// Authentication methods reported by the auth check
+const (
+	AuthMethodBearer   = "bearer"
+	AuthMethodQuery    = "query"
+	AuthMethodCookie   = "cookie"
+	AuthMethodDisabled = "disabled"
+)
+
+// authSource is one place a request can carry credentials
+type authSource struct {
+	method string
+	token  func(r *http.Request) string
+}
+
+// authSources lists where credentials are looked for, in order. The first
+// source that carries a token decides the outcome.
+var authSources = []authSource{
+	{method: AuthMethodBearer, token: func(r *http.Request) string {
+		header := r.Header.Get("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") {
+			return ""
+		}
+		return strings.TrimPrefix(header, "Bearer ")
+	}},
+	{method: AuthMethodQuery, token: func(r *http.Request) string {
+		name := QueryTokenParam()
+		if name == "" {
+			return ""
+		}
+		return r.URL.Query().Get(name)
+	}},
+	{method: AuthMethodCookie, token: sessionCookieToken},
+}
+
+// defaultQueryTokenParam is the query parameter a token is accepted in unless
+// CINESYNC_AUTH_QUERY_PARAM names another
+const defaultQueryTokenParam = "token"
+
+// QueryTokenParam returns the query parameter API requests may pass their
+// token in, from CINESYNC_AUTH_QUERY_PARAM. Query tokens end up in access logs
+// and browser history, so operators can rename the parameter or set it empty
+// to accept only the Authorization header.
+func QueryTokenParam() string {
+	return strings.TrimSpace(env.GetString("CINESYNC_AUTH_QUERY_PARAM", defaultQueryTokenParam))
+}
+
+// streamQueryTokenParam returns the query parameter event streams may pass
+// their token in. EventSource cannot set headers, so streams keep a query
+// token while CINESYNC_AUTH_STREAM_QUERY_PARAM allows it, which it does by
+// default, under the configured name or "token" when query tokens are
+// otherwise disabled.
+func streamQueryTokenParam() string {
+	if !env.IsBool("CINESYNC_AUTH_STREAM_QUERY_PARAM", true) {
+		return ""
+	}
+	if name := QueryTokenParam(); name != "" {
+		return name
+	}
+	return defaultQueryTokenParam
+}
+
+// resolveRequestAuth validates the first credentials found in the request and
+// returns their claims and the method they came with. The method is empty when
+// the request carries no credentials.
+func resolveRequestAuth(r *http.Request) (*JWTClaims, string, error) {
+	for _, source := range authSources {
+		tokenStr := source.token(r)
+		if tokenStr == "" {
+			continue
+		}
 		token, err := jwt.ParseWithClaims(tokenStr, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
 			return jwtSecret, nil
 		})
-		if err == nil && token.Valid {
-			valid = true
+		if err != nil {
+			return nil, source.method, err
+		}
+		claims, ok := token.Claims.(*JWTClaims)
+		if !ok || !token.Valid {
+			return nil, source.method, errors.New("invalid token claims")
+		}
+		if tokenRevoked(claims) {
+			return nil, source.method, errors.New("token was issued before the password changed")
 		}
+		return claims, source.method, nil
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"isAuthenticated": valid,
+	return nil, "", nil
+}
+
+// HandleAuthCheck reports whether the request is authenticated, resolving
+// credentials the same way JWTMiddleware does, and with which identity and method
+func HandleAuthCheck(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	response := map[string]interface{}{
+		"isAuthenticated": false,
 		"authEnabled":     true,
-	})
+	}
+
+	if !env.IsBool("CINESYNC_AUTH_ENABLED", true) {
+		response["isAuthenticated"] = true
+		response["authEnabled"] = false
+		response["method"] = AuthMethodDisabled
+	} else if claims, method, err := resolveRequestAuth(r); method != "" && err == nil {
+		response["isAuthenticated"] = true
+		response["method"] = method
+		response["username"] = claims.Username
+		response["role"] = claimsRole(claims)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
 }
 
 // BasicAuthMiddleware provides HTTP Basic Authentication for a handler.
 func BasicAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Check if auth is enabled via environment variable
-		if !env.IsBool("CINESYNC_AUTH_ENABLED", true) { // Default to true if not set, consistent with main server auth
+		// WebDAV auth can be set independently and follows the main setting by default
+		if !env.IsBool("CINESYNC_WEBDAV_AUTH_ENABLED", env.IsBool("CINESYNC_AUTH_ENABLED", true)) {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -233,39 +358,245 @@ func BasicAuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		if !validateCredentials(username, password) {
+		_, err := validateCredentials(username, password, r)
+		var lockedOut *loginLockedOutError
+		if errors.As(err, &lockedOut) {
+			logger.Warn("[WebDAV Auth] Rejected basic auth for user '%s' from %s during lockout", username, r.RemoteAddr)
+			recordAudit(AuditLoginLockedOut, AuditFailure, username, r, "webdav")
+			w.Header().Set("Retry-After", lockedOut.retryAfter())
+			http.Error(w, "Too many failed login attempts, try again later", http.StatusTooManyRequests)
+			return
+		}
+		if err != nil {
 			logger.Warn("[WebDAV Auth] Invalid basic auth credentials for user '%s' from %s for path %s", username, r.RemoteAddr, r.URL.Path)
+			recordAudit(AuditLoginFailed, AuditFailure, username, r, "webdav")
 			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		// Hand the account and its WebDAV root to the handler so it can scope the view
+		user := WebDAVUser{Username: username, Root: webdavRootFor(username)}
+		next.ServeHTTP(w, r.WithContext(withWebDAVUser(r.Context(), user)))
 	})
 }
 
+// requestClaims returns the validated JWT claims of the request's bearer token
+func requestClaims(r *http.Request) (*JWTClaims, bool) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		// CSRFMiddleware has already checked state-changing cookie requests
+		if token := sessionCookieToken(r); token != "" {
+			return parseClaims(token)
+		}
+		return nil, false
+	}
+	return parseClaims(strings.TrimPrefix(header, "Bearer "))
+}
+
+// streamRequestClaims returns the validated JWT claims of an event stream
+// request. EventSource and WebSocket clients cannot set headers, so the token
+// may also be passed as a query parameter, see streamQueryTokenParam.
+func streamRequestClaims(r *http.Request) (*JWTClaims, bool) {
+	if claims, ok := requestClaims(r); ok {
+		return claims, true
+	}
+	name := streamQueryTokenParam()
+	if name == "" {
+		return nil, false
+	}
+	if token := r.URL.Query().Get(name); token != "" {
+		return parseClaims(token)
+	}
+	return nil, false
+}
+
+// parseClaims validates a signed token and returns its claims
+func parseClaims(tokenStr string) (*JWTClaims, bool) {
+	token, err := jwt.ParseWithClaims(tokenStr, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, false
+	}
+	claims, ok := token.Claims.(*JWTClaims)
+	if !ok || tokenRevoked(claims) {
+		return nil, false
+	}
+	return claims, true
+}
+
+// RequestUsername returns the user a request is authenticated as, or "" when
+// it carries no valid token
+func RequestUsername(r *http.Request) string {
+	if claims, ok := requestClaims(r); ok {
+		return claims.Username
+	}
+	return ""
+}
+
+// RequireAuthenticated writes 401 and returns false unless authentication is
+// disabled or the request carries a valid token
+func RequireAuthenticated(w http.ResponseWriter, r *http.Request) bool {
+	if !env.IsBool("CINESYNC_AUTH_ENABLED", true) {
+		return true
+	}
+	if _, ok := requestClaims(r); !ok {
+		apierror.WriteError(w, http.StatusUnauthorized, apierror.CodeAuthMissingToken, "Missing or invalid Authorization header")
+		return false
+	}
+	return true
+}
+
+// RequireStreamAuthenticated is RequireAuthenticated for event streams, which
+// also accept the token as a query parameter
+func RequireStreamAuthenticated(w http.ResponseWriter, r *http.Request) bool {
+	if !env.IsBool("CINESYNC_AUTH_ENABLED", true) {
+		return true
+	}
+	if _, ok := streamRequestClaims(r); !ok {
+		logger.Warn("Rejected unauthenticated event stream subscriber for %s", r.URL.Path)
+		apierror.WriteError(w, http.StatusUnauthorized, apierror.CodeAuthMissingToken, "Missing or invalid Authorization header or token parameter")
+		return false
+	}
+	return true
+}
+
+// RequireAdmin writes an error and returns false unless authentication is
+// disabled or the request carries an administrator token
+func RequireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if !env.IsBool("CINESYNC_AUTH_ENABLED", true) {
+		return true
+	}
+	_, ok := requireAdmin(w, r)
+	return ok
+}
+
+// isAdminClaims reports whether the claims belong to an administrator, by
+// the user's current role rather than the one in the token
+func isAdminClaims(claims *JWTClaims) bool {
+	return claimsRole(claims) == RoleAdmin
+}
+
+// requireAdmin writes an error and returns false unless the request carries an
+// administrator token
+func requireAdmin(w http.ResponseWriter, r *http.Request) (*JWTClaims, bool) {
+	claims, ok := requestClaims(r)
+	if !ok {
+		apierror.WriteError(w, http.StatusUnauthorized, apierror.CodeAuthMissingToken, "Missing or invalid Authorization header")
+		return nil, false
+	}
+	if !isAdminClaims(claims) {
+		logger.Warn("User '%s' attempted an admin-only action on %s", claims.Username, r.URL.Path)
+		recordAudit(AuditAccessDenied, AuditFailure, claims.Username, r, "admin privileges required")
+		apierror.WriteError(w, http.StatusForbidden, apierror.CodeAuthForbidden, "Admin privileges required")
+		return nil, false
+	}
+	return claims, true
+}
+
 // HandleMe returns the current user's info from the JWT
 func HandleMe(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+
 	header := r.Header.Get("Authorization")
+	tokenStr := strings.TrimPrefix(header, "Bearer ")
 	if !strings.HasPrefix(header, "Bearer ") {
-		http.Error(w, "Missing or invalid Authorization header", http.StatusUnauthorized)
+		tokenStr = sessionCookieToken(r)
+	}
+	if tokenStr == "" {
+		apierror.WriteError(w, http.StatusUnauthorized, apierror.CodeAuthMissingToken, "Missing or invalid Authorization header")
 		return
 	}
-	tokenStr := strings.TrimPrefix(header, "Bearer ")
 	token, err := jwt.ParseWithClaims(tokenStr, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return jwtSecret, nil
 	})
 	if err != nil || !token.Valid {
-		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+		apierror.WriteError(w, http.StatusUnauthorized, apierror.CodeAuthInvalidToken, "Invalid or expired token")
 		return
 	}
 	claims, ok := token.Claims.(*JWTClaims)
 	if !ok {
-		http.Error(w, "Invalid token claims", http.StatusUnauthorized)
+		apierror.WriteError(w, http.StatusUnauthorized, apierror.CodeAuthInvalidToken, "Invalid token claims")
+		return
+	}
+	if tokenRevoked(claims) {
+		apierror.WriteError(w, http.StatusUnauthorized, apierror.CodeAuthInvalidToken, "Invalid or expired token")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"username": claims.Username,
+		"role":     claimsRole(claims),
+	})
+}
+
+// writePasswordPolicyError reports which password rule a request failed
+func writePasswordPolicyError(w http.ResponseWriter, err *PasswordPolicyError) {
+	apierror.WriteErrorDetails(w, http.StatusBadRequest, apierror.CodeAuthPasswordPolicy, err.Message, map[string]string{
+		"rule": err.Rule,
+	})
+}
+
+// HandleChangePassword lets a stored user change their own password. Every
+// token issued before the change stops working; the response carries a new one.
+func HandleChangePassword(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	claims, ok := requestClaims(r)
+	if !ok {
+		apierror.WriteError(w, http.StatusUnauthorized, apierror.CodeAuthMissingToken, "Missing or invalid Authorization header")
+		return
+	}
+
+	var req struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	if claims.Username == GetCredentials().Username {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeAuthPasswordManaged, "The administrator password is managed by CINESYNC_PASSWORD")
+		return
+	}
+	if _, ok := authenticateStoredUser(claims.Username, req.CurrentPassword); !ok {
+		logger.Warn("Failed password change attempt for user '%s'", claims.Username)
+		apierror.WriteError(w, http.StatusUnauthorized, apierror.CodeAuthInvalidCredentials, "Current password is incorrect")
+		return
+	}
+
+	if err := UpdateUserPassword(claims.Username, req.NewPassword); err != nil {
+		var policyErr *PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			writePasswordPolicyError(w, policyErr)
+			return
+		}
+		logger.Error("Failed to change password for user '%s': %v", claims.Username, err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to change password")
+		return
+	}
+
+	// Tokens issued before the change, this one included, no longer work, so
+	// the caller gets a fresh one to stay signed in
+	token, expiresAt, err := generateJWTWithExpiry(claims.Username, claimsRole(claims))
+	if err != nil {
+		logger.Error("Failed to issue token for user '%s': %v", claims.Username, err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Password changed, sign in again")
+		return
+	}
+
+	logger.Info("Password changed for user '%s', earlier sessions signed out", claims.Username)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "success",
+		"token":     token,
+		"expiresAt": expiresAt,
 	})
 }
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+	"cinesync/pkg/middleware"
+)
+
+const (
+	// SessionCookieName holds the JWT of a cookie session. It is HttpOnly, so
+	// scripts cannot read it.
+	SessionCookieName = "cinesync_session"
+	// CSRFCookieName holds the CSRF token of a cookie session. Scripts read it
+	// and echo it in CSRFHeaderName on every state-changing request.
+	CSRFCookieName = "cinesync_csrf"
+	// CSRFHeaderName carries the CSRF token of a state-changing request
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// CookieAuthEnabled reports whether logins also start a cookie session, from
+// CINESYNC_AUTH_COOKIE
+func CookieAuthEnabled() bool {
+	return env.IsBool("CINESYNC_AUTH_COOKIE", false)
+}
+
+// sessionCookieToken returns the JWT of the request's session cookie when
+// cookie sessions are enabled
+func sessionCookieToken(r *http.Request) string {
+	if !CookieAuthEnabled() {
+		return ""
+	}
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// csrfToken derives the CSRF token of a session from its JWT. The token is
+// signed with the JWT secret, so it cannot be forged for a session and the
+// server keeps no state to check it.
+func csrfToken(sessionToken string) string {
+	mac := hmac.New(sha256.New, jwtSecret)
+	mac.Write([]byte("csrf:" + sessionToken))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// setSessionCookies starts a cookie session for token and returns its CSRF
+// token. Nothing is set unless cookie sessions are enabled.
+func setSessionCookies(w http.ResponseWriter, r *http.Request, token string, expiresAt time.Time) string {
+	if !CookieAuthEnabled() {
+		return ""
+	}
+	csrf := csrfToken(token)
+	secure := middleware.IsSecureRequest(r)
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookieName,
+		Value:    csrf,
+		Path:     "/",
+		Expires:  expiresAt,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return csrf
+}
+
+// clearSessionCookies ends a cookie session
+func clearSessionCookies(w http.ResponseWriter, r *http.Request) {
+	secure := middleware.IsSecureRequest(r)
+	for _, name := range []string{SessionCookieName, CSRFCookieName} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: name == SessionCookieName,
+			Secure:   secure,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+}
+
+// isSafeMethod reports whether a request method cannot change state
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// validCSRF reports whether the CSRF header matches both the CSRF cookie and
+// the token signed for the session cookie
+func validCSRF(r *http.Request, sessionToken string) bool {
+	header := r.Header.Get(CSRFHeaderName)
+	if header == "" {
+		return false
+	}
+	cookie, err := r.Cookie(CSRFCookieName)
+	if err != nil || subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) != 1 {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(header), []byte(csrfToken(sessionToken))) == 1
+}
+
+// CSRFMiddleware rejects state-changing requests authenticated by a session
+// cookie unless they carry the session's CSRF token in X-CSRF-Token, a double
+// submit of the CSRF cookie. Requests with an Authorization header or an API
+// key are not sent by browsers on their own and are exempt, as are requests
+// whose cookie holds no valid session.
+func CSRFMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isSafeMethod(r.Method) || r.Header.Get("Authorization") != "" || r.Header.Get("X-Api-Key") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		sessionToken := sessionCookieToken(r)
+		if sessionToken == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, ok := parseClaims(sessionToken); !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !validCSRF(r, sessionToken) {
+			logger.Warn("Rejected %s %s from %s: missing or invalid CSRF token", r.Method, r.URL.Path, r.RemoteAddr)
+			apierror.WriteError(w, http.StatusForbidden, apierror.CodeAuthCSRFInvalid, "Missing or invalid "+CSRFHeaderName+" header")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HandleLogout serves POST /api/auth/logout, ending the cookie session
+func HandleLogout(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+	clearSessionCookies(w, r)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "logged_out"})
+}
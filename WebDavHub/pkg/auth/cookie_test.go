@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSRFMiddleware(t *testing.T) {
+	t.Setenv("CINESYNC_AUTH_COOKIE", "true")
+	t.Setenv("CINESYNC_USERNAME", "admin")
+	session, err := GenerateJWT("admin", RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csrf := csrfToken(session)
+
+	for _, tt := range []struct {
+		name          string
+		method        string
+		csrfCookie    string
+		csrfHeader    string
+		authorization string
+		status        int
+	}{
+		{"matching token", http.MethodPost, csrf, csrf, "", http.StatusOK},
+		{"missing token", http.MethodPost, csrf, "", "", http.StatusForbidden},
+		{"mismatched token", http.MethodPost, csrf, "forged", "", http.StatusForbidden},
+		{"token not signed for the session", http.MethodDelete, "forged", "forged", "", http.StatusForbidden},
+		{"safe method", http.MethodGet, "", "", "", http.StatusOK},
+		{"bearer request", http.MethodPatch, "", "", "Bearer " + session, http.StatusOK},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := CSRFMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			r := httptest.NewRequest(tt.method, "/api/config", nil)
+			r.AddCookie(&http.Cookie{Name: SessionCookieName, Value: session})
+			if tt.csrfCookie != "" {
+				r.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: tt.csrfCookie})
+			}
+			if tt.csrfHeader != "" {
+				r.Header.Set(CSRFHeaderName, tt.csrfHeader)
+			}
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"cinesync/pkg/env"
+)
+
+// maxCredentialCacheEntries bounds the verified credentials kept in memory
+const maxCredentialCacheEntries = 1024
+
+// credentialCacheEntry is a password recently verified against a stored hash
+type credentialCacheEntry struct {
+	passwordHash string
+	expires      time.Time
+}
+
+// credentialCache remembers passwords that recently matched, so WebDAV
+// clients, which send Basic credentials with every request, do not pay for a
+// PBKDF2 derivation each time. Entries are keyed by an HMAC of the username
+// and password under a per-process key, so plaintext passwords are never
+// kept, and are tied to the hash they matched, so changing a password
+// invalidates them.
+type credentialCache struct {
+	mutex   sync.Mutex
+	key     []byte
+	entries map[[sha256.Size]byte]credentialCacheEntry
+}
+
+var verifiedCredentials = newCredentialCache()
+
+func newCredentialCache() *credentialCache {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return &credentialCache{key: key, entries: make(map[[sha256.Size]byte]credentialCacheEntry)}
+}
+
+// credentialCacheTTL is how long a verified password is remembered,
+// CINESYNC_CREDENTIAL_CACHE_SECONDS; 0 disables the cache
+func credentialCacheTTL() time.Duration {
+	return time.Duration(env.GetInt("CINESYNC_CREDENTIAL_CACHE_SECONDS", 60)) * time.Second
+}
+
+func (c *credentialCache) entryKey(username, password string) [sha256.Size]byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(username))
+	mac.Write([]byte{0})
+	mac.Write([]byte(password))
+	var key [sha256.Size]byte
+	copy(key[:], mac.Sum(nil))
+	return key
+}
+
+// verified reports whether password was recently verified against passwordHash
+func (c *credentialCache) verified(username, password, passwordHash string) bool {
+	if credentialCacheTTL() <= 0 {
+		return false
+	}
+	key := c.entryKey(username, password)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	if time.Now().After(entry.expires) || entry.passwordHash != passwordHash {
+		delete(c.entries, key)
+		return false
+	}
+	return true
+}
+
+// remember records that password matched passwordHash
+func (c *credentialCache) remember(username, password, passwordHash string) {
+	ttl := credentialCacheTTL()
+	if ttl <= 0 {
+		return
+	}
+	key := c.entryKey(username, password)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := time.Now()
+	if len(c.entries) >= maxCredentialCacheEntries {
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxCredentialCacheEntries {
+			c.entries = make(map[[sha256.Size]byte]credentialCacheEntry)
+		}
+	}
+	c.entries[key] = credentialCacheEntry{passwordHash: passwordHash, expires: now.Add(ttl)}
+}
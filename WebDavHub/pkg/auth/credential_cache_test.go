@@ -0,0 +1,31 @@
+package auth
+
+import "testing"
+
+func TestCredentialCacheRemembersVerifiedPasswords(t *testing.T) {
+	t.Setenv("CINESYNC_CREDENTIAL_CACHE_SECONDS", "60")
+	cache := newCredentialCache()
+
+	if cache.verified("alice", "secret", "hash1") {
+		t.Fatal("unknown credentials reported as verified")
+	}
+	cache.remember("alice", "secret", "hash1")
+	if !cache.verified("alice", "secret", "hash1") {
+		t.Fatal("remembered credentials not verified")
+	}
+	if cache.verified("alice", "wrong", "hash1") || cache.verified("bob", "secret", "hash1") {
+		t.Fatal("other credentials verified by a cached entry")
+	}
+	if cache.verified("alice", "secret", "hash2") {
+		t.Fatal("cached entry outlived a password change")
+	}
+}
+
+func TestCredentialCacheCanBeDisabled(t *testing.T) {
+	t.Setenv("CINESYNC_CREDENTIAL_CACHE_SECONDS", "0")
+	cache := newCredentialCache()
+	cache.remember("alice", "secret", "hash1")
+	if cache.verified("alice", "secret", "hash1") {
+		t.Fatal("credentials cached while the cache is disabled")
+	}
+}
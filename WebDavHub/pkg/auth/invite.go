@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const defaultInviteTTL = 48 * time.Hour
+
+// InviteClaims defines the claims carried by a registration invite
+type InviteClaims struct {
+	Role      string `json:"role"`
+	InvitedBy string `json:"invitedBy"`
+	jwt.RegisteredClaims
+}
+
+// inviteSigningKey derives a key separate from the session key so an invite
+// can never be presented as a login token and vice versa
+func inviteSigningKey() []byte {
+	mac := hmac.New(sha256.New, jwtSecret)
+	mac.Write([]byte("cinesync-invite"))
+	return mac.Sum(nil)
+}
+
+// inviteTTL returns how long a new invite stays valid
+func inviteTTL() time.Duration {
+	ttl, err := time.ParseDuration(env.GetString("CINESYNC_INVITE_TTL", defaultInviteTTL.String()))
+	if err != nil || ttl <= 0 {
+		return defaultInviteTTL
+	}
+	return ttl
+}
+
+// GenerateInvite creates a signed single-use invite for a new account
+func GenerateInvite(invitedBy, role string) (string, time.Time, error) {
+	if role != RoleAdmin {
+		role = RoleUser
+	}
+	expiresAt := time.Now().Add(inviteTTL())
+	claims := InviteClaims{
+		Role:      role,
+		InvitedBy: invitedBy,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(inviteSigningKey())
+	return signed, expiresAt, err
+}
+
+// parseInvite validates an invite's signature and expiry
+func parseInvite(invite string) (*InviteClaims, error) {
+	token, err := jwt.ParseWithClaims(invite, &InviteClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return inviteSigningKey(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return nil, ErrInviteExpired
+	}
+	if err != nil {
+		return nil, ErrInvalidInvite
+	}
+	claims, ok := token.Claims.(*InviteClaims)
+	if !ok || !token.Valid || claims.ID == "" || claims.ExpiresAt == nil {
+		return nil, ErrInvalidInvite
+	}
+	return claims, nil
+}
+
+// RegisterWithInvite consumes an invite and creates the user it was issued for
+func RegisterWithInvite(invite, username, password string) (*User, error) {
+	claims, err := parseInvite(invite)
+	if err != nil {
+		return nil, err
+	}
+
+	userStoreMutex.Lock()
+	defer userStoreMutex.Unlock()
+
+	store, err := loadUserStore()
+	if err != nil {
+		return nil, err
+	}
+	if _, used := store.UsedInvites[claims.ID]; used {
+		return nil, ErrInviteUsed
+	}
+
+	// Mark the invite used in the same write that creates the user
+	store.UsedInvites[claims.ID] = claims.ExpiresAt.Unix()
+	return createUserLocked(store, username, password, claims.Role)
+}
+
+// HandleInvite issues a registration invite. Only administrators may call it.
+func HandleInvite(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	claims, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+			return
+		}
+	}
+
+	invite, expiresAt, err := GenerateInvite(claims.Username, req.Role)
+	if err != nil {
+		logger.Error("Failed to generate invite: %v", err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to generate invite")
+		return
+	}
+
+	logger.Info("Invite issued by '%s'", claims.Username)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"invite":    invite,
+		"expiresAt": expiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// HandleRegister creates an account from a valid, unused invite
+func HandleRegister(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req struct {
+		Invite   string `json:"invite"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if req.Invite == "" || req.Username == "" || req.Password == "" {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "invite, username and password are required")
+		return
+	}
+
+	user, err := RegisterWithInvite(req.Invite, req.Username, req.Password)
+	var policyErr *PasswordPolicyError
+	switch {
+	case err == nil:
+	case errors.As(err, &policyErr):
+		writePasswordPolicyError(w, policyErr)
+		return
+	case errors.Is(err, ErrInviteUsed):
+		apierror.WriteError(w, http.StatusGone, apierror.CodeAuthInviteUsed, err.Error())
+		return
+	case errors.Is(err, ErrInviteExpired):
+		apierror.WriteError(w, http.StatusGone, apierror.CodeAuthInviteExpired, err.Error())
+		return
+	case errors.Is(err, ErrInvalidInvite):
+		logger.Warn("Rejected registration for '%s': %v", req.Username, err)
+		apierror.WriteError(w, http.StatusUnauthorized, apierror.CodeAuthInviteInvalid, err.Error())
+		return
+	case errors.Is(err, ErrUserExists):
+		apierror.WriteError(w, http.StatusConflict, apierror.CodeAuthUserExists, err.Error())
+		return
+	case errors.Is(err, ErrInvalidUsername):
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeAuthInvalidUsername, err.Error())
+		return
+	default:
+		logger.Error("Failed to register user '%s': %v", req.Username, err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to register user")
+		return
+	}
+
+	token, err := GenerateJWT(user.Username, user.Role)
+	if err != nil {
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to generate token")
+		return
+	}
+
+	logger.Info("Registered user '%s' with role %s", user.Username, user.Role)
+	recordAudit(AuditRegister, AuditSuccess, user.Username, r, "invite")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"username": user.Username,
+		"role":     user.Role,
+		"token":    token,
+	})
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegisterWithInvite(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		ttl      string
+		register int
+		err      error
+	}{
+		{"valid invite", "1h", 1, nil},
+		{"reused invite", "1h", 2, ErrInviteUsed},
+		{"expired invite", "1ms", 1, ErrInviteExpired},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			useUserStore(t)
+			t.Setenv("CINESYNC_USERNAME", "admin")
+			t.Setenv("CINESYNC_INVITE_TTL", tt.ttl)
+
+			invite, _, err := GenerateInvite("admin", RoleUser)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.err == ErrInviteExpired {
+				time.Sleep(20 * time.Millisecond)
+			}
+
+			for i := 1; i < tt.register; i++ {
+				if _, err := RegisterWithInvite(invite, "first", "Correct-Horse-42"); err != nil {
+					t.Fatalf("first registration: %v", err)
+				}
+			}
+			user, err := RegisterWithInvite(invite, "alice", "Correct-Horse-42")
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("RegisterWithInvite() error = %v, want %v", err, tt.err)
+			}
+
+			_, lookupErr := GetUser("alice")
+			if tt.err != nil {
+				if lookupErr == nil {
+					t.Fatal("user was created with a rejected invite")
+				}
+				return
+			}
+			if lookupErr != nil || user.Role != RoleUser {
+				t.Fatalf("registered user = %+v, lookup error %v", user, lookupErr)
+			}
+			if _, ok := authenticateStoredUser("alice", "Correct-Horse-42"); !ok {
+				t.Fatal("registered user cannot sign in with the chosen password")
+			}
+		})
+	}
+}
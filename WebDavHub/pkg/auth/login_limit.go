@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+)
+
+// LimitStore keeps failed login counters and lockouts. Replicas behind a load
+// balancer must share one store so a client cannot spread attempts across them.
+type LimitStore interface {
+	// Increment records a failure for key and returns the failures in the
+	// current window, starting a new window when the previous one has ended
+	Increment(key string, window time.Duration) (int, error)
+	// Lock blocks key until the given time
+	Lock(key string, until time.Time) error
+	// LockedUntil returns when the lock on key ends, the zero time when unlocked
+	LockedUntil(key string) (time.Time, error)
+	// Reset clears the failures and lock of key
+	Reset(key string) error
+}
+
+// memoryLimitEntry is the state of one key in the in-memory store
+type memoryLimitEntry struct {
+	count       int
+	windowStart time.Time
+	lockedUntil time.Time
+}
+
+// MemoryLimitStore is the default LimitStore, private to one process
+type MemoryLimitStore struct {
+	mutex   sync.Mutex
+	entries map[string]*memoryLimitEntry
+}
+
+// NewMemoryLimitStore creates an empty in-memory store
+func NewMemoryLimitStore() *MemoryLimitStore {
+	return &MemoryLimitStore{entries: make(map[string]*memoryLimitEntry)}
+}
+
+func (s *MemoryLimitStore) Increment(key string, window time.Duration) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	s.prune(now, window)
+	entry, exists := s.entries[key]
+	if !exists {
+		entry = &memoryLimitEntry{}
+		s.entries[key] = entry
+	}
+	if now.Sub(entry.windowStart) >= window {
+		entry.count, entry.windowStart = 0, now
+	}
+	entry.count++
+	return entry.count, nil
+}
+
+func (s *MemoryLimitStore) Lock(key string, until time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, exists := s.entries[key]
+	if !exists {
+		entry = &memoryLimitEntry{}
+		s.entries[key] = entry
+	}
+	entry.lockedUntil = until
+	return nil
+}
+
+func (s *MemoryLimitStore) LockedUntil(key string) (time.Time, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if entry, exists := s.entries[key]; exists && time.Now().Before(entry.lockedUntil) {
+		return entry.lockedUntil, nil
+	}
+	return time.Time{}, nil
+}
+
+func (s *MemoryLimitStore) Reset(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// prune drops entries whose window and lock have both ended
+func (s *MemoryLimitStore) prune(now time.Time, window time.Duration) {
+	for key, entry := range s.entries {
+		if now.Sub(entry.windowStart) >= window && !now.Before(entry.lockedUntil) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+var (
+	limitStore      LimitStore = NewMemoryLimitStore()
+	limitStoreMutex sync.RWMutex
+)
+
+// SetLimitStore replaces the store used for login throttling
+func SetLimitStore(store LimitStore) {
+	limitStoreMutex.Lock()
+	limitStore = store
+	limitStoreMutex.Unlock()
+}
+
+func getLimitStore() LimitStore {
+	limitStoreMutex.RLock()
+	defer limitStoreMutex.RUnlock()
+	return limitStore
+}
+
+// loginLimitKeys returns the counters a login attempt is charged to: the
+// client address, so one client cannot guess many accounts, and the username
+// from that address, so a client guessing one account locks only itself out
+// of it instead of locking its owner out everywhere
+func loginLimitKeys(username string, r *http.Request) []string {
+	host := remoteHost(r)
+	return []string{"login:ip:" + host, "login:user:" + host + ":" + strings.ToLower(username)}
+}
+
+// loginLockedUntil returns when the lockout covering this attempt ends, the
+// zero time when it may proceed. Store failures let the attempt through.
+func loginLockedUntil(username string, r *http.Request) time.Time {
+	store := getLimitStore()
+	var until time.Time
+	for _, key := range loginLimitKeys(username, r) {
+		lockedUntil, err := store.LockedUntil(key)
+		if err != nil {
+			logger.Warn("Failed to read login lockout for %s: %v", key, err)
+			continue
+		}
+		if lockedUntil.After(until) {
+			until = lockedUntil
+		}
+	}
+	return until
+}
+
+// recordLoginFailure counts a failed attempt, locking the client, or the
+// account from that client, out after CINESYNC_LOGIN_MAX_ATTEMPTS failures within CINESYNC_LOGIN_WINDOW_MINUTES
+func recordLoginFailure(username string, r *http.Request) {
+	maxAttempts := env.GetInt("CINESYNC_LOGIN_MAX_ATTEMPTS", 5)
+	if maxAttempts <= 0 {
+		return
+	}
+	window := time.Duration(env.GetInt("CINESYNC_LOGIN_WINDOW_MINUTES", 15)) * time.Minute
+	lockout := time.Duration(env.GetInt("CINESYNC_LOGIN_LOCKOUT_MINUTES", 15)) * time.Minute
+
+	store := getLimitStore()
+	for _, key := range loginLimitKeys(username, r) {
+		count, err := store.Increment(key, window)
+		if err != nil {
+			logger.Warn("Failed to record login failure for %s: %v", key, err)
+			continue
+		}
+		if count < maxAttempts {
+			continue
+		}
+		if err := store.Lock(key, time.Now().Add(lockout)); err != nil {
+			logger.Warn("Failed to lock out %s: %v", key, err)
+			continue
+		}
+		logger.Warn("Locked out %s for %s after %d failed logins", key, lockout, count)
+	}
+}
+
+// recordLoginSuccess clears the failures of the account from this client. The
+// client's own counter is left to expire, so logging into an account of one's
+// own does not reset attempts against others.
+func recordLoginSuccess(username string, r *http.Request) {
+	keys := loginLimitKeys(username, r)
+	if err := getLimitStore().Reset(keys[1]); err != nil {
+		logger.Warn("Failed to reset login failures for %s: %v", keys[1], err)
+	}
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// useLoginLimits gives the test a fresh limit store locking out after
+// maxAttempts failures
+func useLoginLimits(t *testing.T, maxAttempts string) {
+	t.Helper()
+	t.Setenv("CINESYNC_USERNAME", "admin")
+	t.Setenv("CINESYNC_PASSWORD", "secret")
+	t.Setenv("CINESYNC_LOGIN_MAX_ATTEMPTS", maxAttempts)
+	t.Setenv("CINESYNC_LOGIN_WINDOW_MINUTES", "15")
+	t.Setenv("CINESYNC_LOGIN_LOCKOUT_MINUTES", "15")
+	previous := getLimitStore()
+	SetLimitStore(NewMemoryLimitStore())
+	t.Cleanup(func() { SetLimitStore(previous) })
+}
+
+func requestFrom(remoteAddr string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = remoteAddr
+	return r
+}
+
+func TestLockoutIsPerClient(t *testing.T) {
+	useLoginLimits(t, "3")
+	attacker := requestFrom("203.0.113.5:40000")
+	for i := 0; i < 3; i++ {
+		validateCredentials("admin", "wrong", attacker)
+	}
+
+	if _, err := validateCredentials("admin", "secret", attacker); err == nil {
+		t.Fatal("locked out client logged in with the right password")
+	}
+	if _, err := validateCredentials("admin", "secret", requestFrom("198.51.100.7:40000")); err != nil {
+		t.Fatalf("account owner on another address was locked out: %v", err)
+	}
+}
+
+func TestSuccessResetsAccountFailures(t *testing.T) {
+	useLoginLimits(t, "3")
+	r := requestFrom("203.0.113.5:40000")
+	validateCredentials("admin", "wrong", r)
+	validateCredentials("admin", "wrong", r)
+	if _, err := validateCredentials("admin", "secret", r); err != nil {
+		t.Fatalf("login before the limit failed: %v", err)
+	}
+
+	if count, _ := getLimitStore().Increment(loginLimitKeys("admin", r)[1], time.Hour); count != 1 {
+		t.Fatalf("account failures after a success = %d, want them reset", count-1)
+	}
+}
+
+func TestBasicAuthIsThrottled(t *testing.T) {
+	useLoginLimits(t, "2")
+	t.Setenv("CINESYNC_WEBDAV_AUTH_ENABLED", "true")
+	handler := BasicAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(password string) *httptest.ResponseRecorder {
+		r := requestFrom("203.0.113.5:40000")
+		r.SetBasicAuth("admin", password)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, r)
+		return recorder
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := serve("wrong").Code; code != http.StatusUnauthorized {
+			t.Fatalf("failed attempt %d answered %d, want 401", i+1, code)
+		}
+	}
+	recorder := serve("secret")
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("attempt during lockout answered %d, want 429", recorder.Code)
+	}
+	if recorder.Header().Get("Retry-After") == "" {
+		t.Fatal("lockout response has no Retry-After")
+	}
+}
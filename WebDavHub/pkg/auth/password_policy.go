@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+)
+
+// PasswordPolicyError describes which password rule was not met
+type PasswordPolicyError struct {
+	Rule    string
+	Message string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return e.Message
+}
+
+// PasswordPolicy holds the configurable password requirements
+type PasswordPolicy struct {
+	MinLength        int
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	CheckBlocklist   bool
+}
+
+// commonPasswords is a small built-in blocklist of the most frequently used
+// passwords. CINESYNC_PASSWORD_BLOCKLIST can point at a file to extend it.
+var commonPasswords = []string{
+	"123456", "123456789", "12345678", "1234567890", "12345", "1234567",
+	"password", "password1", "password123", "passw0rd", "qwerty", "qwerty123",
+	"qwertyuiop", "abc123", "111111", "000000", "123123", "iloveyou",
+	"admin", "admin123", "administrator", "letmein", "welcome", "welcome1",
+	"monkey", "dragon", "football", "baseball", "sunshine", "princess",
+	"master", "shadow", "superman", "trustno1", "changeme", "secret",
+	"cinesync", "plex", "jellyfin", "emby", "radarr", "sonarr",
+}
+
+var (
+	blocklistOnce sync.Once
+	blocklist     map[string]bool
+)
+
+// GetPasswordPolicy reads the password policy from the environment
+func GetPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:        env.GetInt("CINESYNC_PASSWORD_MIN_LENGTH", 8),
+		RequireUppercase: env.IsBool("CINESYNC_PASSWORD_REQUIRE_UPPERCASE", true),
+		RequireLowercase: env.IsBool("CINESYNC_PASSWORD_REQUIRE_LOWERCASE", true),
+		RequireDigit:     env.IsBool("CINESYNC_PASSWORD_REQUIRE_DIGIT", true),
+		RequireSymbol:    env.IsBool("CINESYNC_PASSWORD_REQUIRE_SYMBOL", false),
+		CheckBlocklist:   env.IsBool("CINESYNC_PASSWORD_BLOCKLIST_ENABLED", true),
+	}
+}
+
+// ValidatePassword checks a password against the configured policy and
+// returns a *PasswordPolicyError naming the first rule it fails
+func ValidatePassword(pw string) error {
+	return GetPasswordPolicy().Validate(pw)
+}
+
+// Validate checks a password against the policy
+func (p PasswordPolicy) Validate(pw string) error {
+	if len([]rune(pw)) < p.MinLength {
+		return &PasswordPolicyError{Rule: "min_length", Message: fmt.Sprintf("password must be at least %d characters", p.MinLength)}
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, c := range pw {
+		switch {
+		case unicode.IsUpper(c):
+			hasUpper = true
+		case unicode.IsLower(c):
+			hasLower = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c) || unicode.IsSpace(c):
+			hasSymbol = true
+		}
+	}
+
+	if p.RequireUppercase && !hasUpper {
+		return &PasswordPolicyError{Rule: "uppercase", Message: "password must contain an uppercase letter"}
+	}
+	if p.RequireLowercase && !hasLower {
+		return &PasswordPolicyError{Rule: "lowercase", Message: "password must contain a lowercase letter"}
+	}
+	if p.RequireDigit && !hasDigit {
+		return &PasswordPolicyError{Rule: "digit", Message: "password must contain a digit"}
+	}
+	if p.RequireSymbol && !hasSymbol {
+		return &PasswordPolicyError{Rule: "symbol", Message: "password must contain a symbol"}
+	}
+	if p.CheckBlocklist && isCommonPassword(pw) {
+		return &PasswordPolicyError{Rule: "common", Message: "password is too common, choose a less predictable one"}
+	}
+	return nil
+}
+
+// isCommonPassword reports whether a password is on the blocklist, ignoring case
+func isCommonPassword(pw string) bool {
+	blocklistOnce.Do(loadPasswordBlocklist)
+	return blocklist[strings.ToLower(pw)]
+}
+
+// loadPasswordBlocklist builds the blocklist from the built-in list and the
+// optional file named by CINESYNC_PASSWORD_BLOCKLIST (one password per line)
+func loadPasswordBlocklist() {
+	blocklist = make(map[string]bool, len(commonPasswords))
+	for _, pw := range commonPasswords {
+		blocklist[pw] = true
+	}
+
+	path := env.GetString("CINESYNC_PASSWORD_BLOCKLIST", "")
+	if path == "" {
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		logger.Warn("Failed to open password blocklist %s: %v", path, err)
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			blocklist[strings.ToLower(line)] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Warn("Failed to read password blocklist %s: %v", path, err)
+	}
+}
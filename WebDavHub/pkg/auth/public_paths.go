@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"sort"
+	"strings"
+)
+
+// publicEndpoints are served without a JWT. Each entry also covers every path
+// below it, so "/api/auth/check" makes "/api/auth/check/..." public too. Every
+// other /api endpoint requires a token, a token parameter or a session cookie.
+var publicEndpoints = []string{
+	// Sign-in, and what the login page needs before it
+	"/api/health",
+	"/api/auth/enabled",
+	"/api/branding",
+	"/api/auth/test",
+	"/api/auth/login",
+	"/api/auth/register",
+	"/api/auth/check",
+	"/api/auth/saml",
+	"/api/config-status",
+
+	// Called by MediaHub, which has no token
+	"/api/mediahub/message",
+	"/api/file-operations",
+	"/api/database/source-files",
+	"/api/database/source-scans",
+
+	// Auth-optional in the web UI, which may call them without a token
+	"/api/download",
+	"/api/config",
+	"/api/mediahub/events",
+	"/api/mediahub/logs",
+	"/api/file-operations/events",
+	"/api/source-browse",
+	"/api/dashboard/events",
+	"/api/database/stats",
+	"/api/database/search",
+	"/api/database/export",
+	"/api/stats",
+	"/api/jobs",
+	"/api/python-bridge/terminate",
+	"/api/spoofing/config",
+	"/api/spoofing/switch",
+	"/api/spoofing/regenerate-key",
+	"/api/spoofing/folders/available",
+
+	// The spoofed Radarr and Sonarr API, which checks its own API key
+	"/api/v3",
+	"/api/system/status",
+
+	// Poster and fanart images
+	"/images/movies/MediaCover",
+	"/images/series/MediaCover",
+	"/MediaCover",
+}
+
+// publicExactPaths are public themselves, without the paths below them. The
+// spoofed API answers /api, which must not open the rest of /api.
+var publicExactPaths = map[string]bool{
+	"/api":  true,
+	"/api/": true,
+}
+
+// publicPaths is built once from publicEndpoints so lookups cost one step per
+// path segment instead of a scan of the whole list
+var publicPaths = newPathTrie(publicEndpoints)
+
+// pathTrie matches paths segment by segment against a set of prefixes
+type pathTrie struct {
+	children map[string]*pathTrie
+	terminal bool
+}
+
+// newPathTrie builds a trie from endpoints. Trailing slashes are ignored and
+// entries already covered by a shorter entry are dropped.
+func newPathTrie(endpoints []string) *pathTrie {
+	normalized := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		endpoint = strings.TrimRight(endpoint, "/")
+		if strings.HasPrefix(endpoint, "/") {
+			normalized = append(normalized, endpoint)
+		}
+	}
+	// Shorter entries first, so covered entries are seen after their prefix
+	sort.Slice(normalized, func(i, j int) bool {
+		return len(normalized[i]) < len(normalized[j])
+	})
+
+	root := &pathTrie{}
+	for _, endpoint := range normalized {
+		root.insert(endpoint)
+	}
+	return root
+}
+
+func (t *pathTrie) insert(endpoint string) {
+	node := t
+	for _, segment := range strings.Split(endpoint[1:], "/") {
+		if node.terminal {
+			return
+		}
+		child, ok := node.children[segment]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[string]*pathTrie)
+			}
+			child = &pathTrie{}
+			node.children[segment] = child
+		}
+		node = child
+	}
+	node.terminal = true
+	node.children = nil
+}
+
+// matches reports whether path equals an entry or lies below one
+func (t *pathTrie) matches(path string) bool {
+	if !strings.HasPrefix(path, "/") {
+		return false
+	}
+	node := t
+	rest := path[1:]
+	for {
+		segment, remainder, more := strings.Cut(rest, "/")
+		child, ok := node.children[segment]
+		if !ok {
+			return false
+		}
+		if child.terminal {
+			return true
+		}
+		if !more {
+			return false
+		}
+		node, rest = child, remainder
+	}
+}
+
+// IsPublicPath reports whether path can be requested without authentication
+func IsPublicPath(path string) bool {
+	return publicExactPaths[path] || publicPaths.matches(path)
+}
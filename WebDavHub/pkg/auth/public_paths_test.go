@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+// isPublicPathLinear is the scan the trie replaced, kept as the reference the
+// trie must agree with
+func isPublicPathLinear(path string) bool {
+	if publicExactPaths[path] {
+		return true
+	}
+	for _, endpoint := range publicEndpoints {
+		if path == endpoint || strings.HasPrefix(path, endpoint+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+var publicPathCases = []struct {
+	path   string
+	public bool
+}{
+	{"/api/health", true},
+	{"/api/auth/login", true},
+	{"/api/auth/saml/acs", true},
+	{"/api/config", true},
+	{"/api/config/update-silent", true},
+	{"/api/file-operations/bulk", true},
+	{"/api/v3/movie/12", true},
+	{"/api/v3/images/movies/MediaCover/1/poster.jpg", true},
+	{"/api/spoofing/folders/available", true},
+	{"/MediaCover/1/fanart.jpg", true},
+	{"/api", true},
+	{"/api/", true},
+	{"/api/database/prune", false},
+	{"/api/database/trash/restore", false},
+	{"/api/auth/users/reload", false},
+	{"/api/auth/invite", false},
+	{"/api/me", false},
+	{"/api/files/Movies", false},
+	{"/api/healthcheck", false},
+	{"/api/configuration", false},
+	{"/api/spoofing/circuit-breaker/status", false},
+	{"api/health", false},
+	{"", false},
+}
+
+func TestIsPublicPath(t *testing.T) {
+	for _, tt := range publicPathCases {
+		if got := IsPublicPath(tt.path); got != tt.public {
+			t.Errorf("IsPublicPath(%q) = %v, want %v", tt.path, got, tt.public)
+		}
+		if got := isPublicPathLinear(tt.path); got != tt.public {
+			t.Errorf("linear match of %q = %v, want %v", tt.path, got, tt.public)
+		}
+	}
+}
+
+func BenchmarkIsPublicPath(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for _, tt := range publicPathCases {
+			IsPublicPath(tt.path)
+		}
+	}
+}
+
+func BenchmarkIsPublicPathLinear(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for _, tt := range publicPathCases {
+			isPublicPathLinear(tt.path)
+		}
+	}
+}
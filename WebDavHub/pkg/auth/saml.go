@@ -0,0 +1,633 @@
+package auth
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/env"
+	"cinesync/pkg/httpclient"
+	"cinesync/pkg/logger"
+
+	"github.com/beevik/etree"
+)
+
+const (
+	samlBindingHTTPPost     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	samlBindingHTTPRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	samlStatusSuccess       = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	samlConfirmationBearer  = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+
+	// samlClockSkew is the clock difference tolerated when checking validity windows
+	samlClockSkew = 2 * time.Minute
+	// samlRequestTTL is how long a login started here may take to come back
+	samlRequestTTL = 10 * time.Minute
+	// samlMetadataRefresh is how often the identity provider metadata is fetched again
+	samlMetadataRefresh = 24 * time.Hour
+)
+
+// errSAMLUnsigned is returned when an element carries no signature
+var errSAMLUnsigned = errors.New("element is not signed")
+
+// samlIdentityProvider is what CineSync needs from the IdP metadata
+type samlIdentityProvider struct {
+	entityID    string
+	ssoURL      string
+	certs       []*x509.Certificate
+	metadataURL string
+	fetchedAt   time.Time
+}
+
+// idpMetadata is the part of an EntityDescriptor read from the IdP
+type idpMetadata struct {
+	EntityID         string `xml:"entityID,attr"`
+	IDPSSODescriptor *struct {
+		KeyDescriptors []struct {
+			Use          string   `xml:"use,attr"`
+			Certificates []string `xml:"KeyInfo>X509Data>X509Certificate"`
+		} `xml:"KeyDescriptor"`
+		SingleSignOnServices []struct {
+			Binding  string `xml:"Binding,attr"`
+			Location string `xml:"Location,attr"`
+		} `xml:"SingleSignOnService"`
+	} `xml:"IDPSSODescriptor"`
+}
+
+// samlState tracks the identity provider, logins in flight and assertions
+// already used, so a captured assertion cannot be replayed
+type samlState struct {
+	mutex      sync.Mutex
+	idp        *samlIdentityProvider
+	requests   map[string]time.Time
+	assertions map[string]time.Time
+}
+
+var saml = &samlState{
+	requests:   make(map[string]time.Time),
+	assertions: make(map[string]time.Time),
+}
+
+var samlHTTPClient = httpclient.New(15 * time.Second)
+
+// samlBaseURL is the public URL CineSync is reached at, without a trailing slash
+func samlBaseURL() string {
+	return strings.TrimRight(env.GetString("CINESYNC_SAML_BASE_URL", ""), "/")
+}
+
+// samlEntityID is the service provider entity ID, the metadata URL by default
+func samlEntityID() string {
+	return env.GetString("CINESYNC_SAML_ENTITY_ID", samlBaseURL()+"/api/auth/saml/metadata")
+}
+
+// samlACSURL is where the identity provider posts its responses
+func samlACSURL() string {
+	return samlBaseURL() + "/api/auth/saml/acs"
+}
+
+// samlEnabled writes 404 and returns false unless SAML is enabled and configured
+func samlEnabled(w http.ResponseWriter) bool {
+	if !env.IsBool("CINESYNC_SAML_ENABLED", false) {
+		apierror.WriteError(w, http.StatusNotFound, apierror.CodeNotFound, "SAML single sign-on is not enabled")
+		return false
+	}
+	if samlBaseURL() == "" || env.GetString("CINESYNC_SAML_IDP_METADATA_URL", "") == "" {
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "CINESYNC_SAML_BASE_URL and CINESYNC_SAML_IDP_METADATA_URL must be set")
+		return false
+	}
+	return true
+}
+
+// identityProvider returns the IdP described by CINESYNC_SAML_IDP_METADATA_URL,
+// fetching the metadata when it is missing or stale. A failed refresh keeps
+// the previous metadata.
+func (s *samlState) identityProvider() (*samlIdentityProvider, error) {
+	metadataURL := env.GetString("CINESYNC_SAML_IDP_METADATA_URL", "")
+
+	s.mutex.Lock()
+	cached := s.idp
+	s.mutex.Unlock()
+	if cached != nil && cached.metadataURL == metadataURL && time.Since(cached.fetchedAt) < samlMetadataRefresh {
+		return cached, nil
+	}
+
+	idp, err := fetchIdentityProvider(metadataURL)
+	if err != nil {
+		if cached != nil && cached.metadataURL == metadataURL {
+			logger.Warn("Failed to refresh SAML identity provider metadata, keeping the previous copy: %v", err)
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	s.idp = idp
+	s.mutex.Unlock()
+	logger.Info("Loaded SAML identity provider %s with %d signing certificates", idp.entityID, len(idp.certs))
+	return idp, nil
+}
+
+func fetchIdentityProvider(metadataURL string) (*samlIdentityProvider, error) {
+	resp, err := samlHTTPClient.Get(metadataURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch identity provider metadata: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("identity provider metadata returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity provider metadata: %w", err)
+	}
+	return parseIdentityProvider(body, metadataURL)
+}
+
+// parseIdentityProvider reads the entity ID, redirect binding endpoint and
+// signing certificates from an IdP EntityDescriptor
+func parseIdentityProvider(data []byte, metadataURL string) (*samlIdentityProvider, error) {
+	var metadata idpMetadata
+	if err := xml.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("invalid identity provider metadata: %w", err)
+	}
+	if metadata.EntityID == "" || metadata.IDPSSODescriptor == nil {
+		return nil, errors.New("identity provider metadata has no IDPSSODescriptor")
+	}
+
+	idp := &samlIdentityProvider{entityID: metadata.EntityID, metadataURL: metadataURL, fetchedAt: time.Now()}
+	for _, service := range metadata.IDPSSODescriptor.SingleSignOnServices {
+		if service.Binding == samlBindingHTTPRedirect {
+			idp.ssoURL = service.Location
+			break
+		}
+	}
+	for _, key := range metadata.IDPSSODescriptor.KeyDescriptors {
+		if key.Use != "" && key.Use != "signing" {
+			continue
+		}
+		for _, encoded := range key.Certificates {
+			der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+			if err != nil {
+				return nil, fmt.Errorf("invalid identity provider certificate: %w", err)
+			}
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, fmt.Errorf("invalid identity provider certificate: %w", err)
+			}
+			idp.certs = append(idp.certs, cert)
+		}
+	}
+	if idp.ssoURL == "" {
+		return nil, errors.New("identity provider metadata has no HTTP-Redirect SingleSignOnService")
+	}
+	if len(idp.certs) == 0 {
+		return nil, errors.New("identity provider metadata has no signing certificate")
+	}
+	return idp, nil
+}
+
+// loadSAMLKeyPair reads the service provider certificate and key. Both are
+// optional; without them AuthnRequests are sent unsigned.
+func loadSAMLKeyPair() (*x509.Certificate, *rsa.PrivateKey, error) {
+	certPath := env.GetString("CINESYNC_SAML_SP_CERT", "")
+	keyPath := env.GetString("CINESYNC_SAML_SP_KEY", "")
+	if certPath == "" || keyPath == "" {
+		return nil, nil, nil
+	}
+
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read SAML certificate: %w", err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, nil, errors.New("SAML certificate is not PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid SAML certificate: %w", err)
+	}
+
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read SAML key: %w", err)
+	}
+	block, _ = pem.Decode(keyPEM)
+	if block == nil {
+		return nil, nil, errors.New("SAML key is not PEM encoded")
+	}
+	var key *rsa.PrivateKey
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, nil, errors.New("SAML key must be an RSA key")
+		}
+		key = rsaKey
+	} else if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+		return nil, nil, fmt.Errorf("invalid SAML key: %w", err)
+	}
+	return cert, key, nil
+}
+
+// HandleSAMLMetadata serves the service provider metadata to register with the IdP
+func HandleSAMLMetadata(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if !samlEnabled(w) {
+		return
+	}
+	cert, _, err := loadSAMLKeyPair()
+	if err != nil {
+		logger.Warn("Failed to load SAML key pair: %v", err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load the SAML certificate")
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&b, `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="%s">`, xmlEscape(samlEntityID()))
+	fmt.Fprintf(&b, `<md:SPSSODescriptor AuthnRequestsSigned="%t" WantAssertionsSigned="true" protocolSupportEnumeration="%s">`, cert != nil, nsSAMLProtocol)
+	if cert != nil {
+		fmt.Fprintf(&b, `<md:KeyDescriptor use="signing"><ds:KeyInfo xmlns:ds="%s"><ds:X509Data><ds:X509Certificate>%s</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>`,
+			nsXMLDSig, base64.StdEncoding.EncodeToString(cert.Raw))
+	}
+	b.WriteString(`<md:NameIDFormat>urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified</md:NameIDFormat>`)
+	fmt.Fprintf(&b, `<md:AssertionConsumerService Binding="%s" Location="%s" index="0" isDefault="true"/>`, samlBindingHTTPPost, xmlEscape(samlACSURL()))
+	b.WriteString(`</md:SPSSODescriptor></md:EntityDescriptor>`)
+
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	w.Write([]byte(b.String()))
+}
+
+// HandleSAMLLogin starts a service provider initiated login by redirecting
+// to the identity provider with an AuthnRequest
+func HandleSAMLLogin(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if !samlEnabled(w) {
+		return
+	}
+	idp, err := saml.identityProvider()
+	if err != nil {
+		logger.Warn("SAML login unavailable: %v", err)
+		apierror.WriteError(w, http.StatusBadGateway, apierror.CodeInternal, "The identity provider metadata could not be loaded")
+		return
+	}
+	_, key, err := loadSAMLKeyPair()
+	if err != nil {
+		logger.Warn("Failed to load SAML key pair: %v", err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load the SAML key")
+		return
+	}
+
+	idBytes := make([]byte, 20)
+	if _, err := rand.Read(idBytes); err != nil {
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to create the login request")
+		return
+	}
+	requestID := "_" + hex.EncodeToString(idBytes)
+	request := fmt.Sprintf(`<samlp:AuthnRequest xmlns:samlp="%s" xmlns:saml="%s" ID="%s" Version="2.0" IssueInstant="%s" Destination="%s" AssertionConsumerServiceURL="%s" ProtocolBinding="%s"><saml:Issuer>%s</saml:Issuer><samlp:NameIDPolicy AllowCreate="true"/></samlp:AuthnRequest>`,
+		nsSAMLProtocol, nsSAMLAssertion, requestID, time.Now().UTC().Format(time.RFC3339), xmlEscape(idp.ssoURL),
+		xmlEscape(samlACSURL()), samlBindingHTTPPost, xmlEscape(samlEntityID()))
+
+	var deflated bytes.Buffer
+	writer, _ := flate.NewWriter(&deflated, flate.BestCompression)
+	writer.Write([]byte(request))
+	writer.Close()
+
+	// The redirect binding signs the query string rather than the XML
+	query := "SAMLRequest=" + url.QueryEscape(base64.StdEncoding.EncodeToString(deflated.Bytes()))
+	if key != nil {
+		query += "&SigAlg=" + url.QueryEscape(algRSASHA256)
+		digest := sha256.Sum256([]byte(query))
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to sign the login request")
+			return
+		}
+		query += "&Signature=" + url.QueryEscape(base64.StdEncoding.EncodeToString(signature))
+	}
+
+	saml.mutex.Lock()
+	saml.purge(time.Now())
+	saml.requests[requestID] = time.Now().Add(samlRequestTTL)
+	saml.mutex.Unlock()
+
+	separator := "?"
+	if strings.Contains(idp.ssoURL, "?") {
+		separator = "&"
+	}
+	http.Redirect(w, r, idp.ssoURL+separator+query, http.StatusFound)
+}
+
+// HandleSAMLACS consumes the identity provider's response, and on success
+// stores a CineSync token in the browser and opens the app
+func HandleSAMLACS(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if !samlEnabled(w) {
+		return
+	}
+	idp, err := saml.identityProvider()
+	if err != nil {
+		logger.Warn("SAML login unavailable: %v", err)
+		apierror.WriteError(w, http.StatusBadGateway, apierror.CodeInternal, "The identity provider metadata could not be loaded")
+		return
+	}
+
+	encoded := r.PostFormValue("SAMLResponse")
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+	if encoded == "" || err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Missing or malformed SAMLResponse")
+		return
+	}
+
+	identity, err := saml.consumeResponse(data, idp, time.Now())
+	if err != nil {
+		logger.Warn("Rejected SAML response from %s: %v", r.RemoteAddr, err)
+		recordAudit(AuditLoginFailed, AuditFailure, "", r, "saml: "+err.Error())
+		apierror.WriteError(w, http.StatusUnauthorized, apierror.CodeAuthSAMLInvalid, "SAML response rejected: "+err.Error())
+		return
+	}
+
+	token, expiresAt, err := signClaims(JWTClaims{Username: identity.username, Role: identity.role, Provider: providerSAML})
+	if err != nil {
+		logger.Warn("Failed to generate token for SAML user '%s': %v", identity.username, err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to generate token")
+		return
+	}
+	logger.Info("Successful SAML login for user '%s' (%s)", identity.username, identity.role)
+	recordAudit(AuditLogin, AuditSuccess, identity.username, r, "saml")
+	setSessionCookies(w, r, token, expiresAt)
+
+	// The app keeps its token in local storage, so hand it over with a small
+	// page whose only script is allowed through its nonce
+	nonceBytes := make([]byte, 16)
+	rand.Read(nonceBytes)
+	nonce := base64.StdEncoding.EncodeToString(nonceBytes)
+	tokenJSON, _ := json.Marshal(token)
+
+	w.Header().Set("Content-Security-Policy", fmt.Sprintf("default-src 'none'; script-src 'nonce-%s'", nonce))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<!DOCTYPE html><html><head><meta charset="utf-8"><title>CineSync</title></head><body><script nonce="%s">localStorage.setItem('cineSyncJWT', %s);window.location.replace('/');</script></body></html>`,
+		nonce, tokenJSON)
+}
+
+// samlIdentity is the CineSync user an assertion maps to
+type samlIdentity struct {
+	username string
+	role     string
+}
+
+// consumeResponse validates a SAML Response and maps its assertion to a user.
+// Everything is read from the element whose signature was checked, so content
+// smuggled elsewhere in the document is never trusted.
+func (s *samlState) consumeResponse(data []byte, idp *samlIdentityProvider, now time.Time) (*samlIdentity, error) {
+	root, err := parseSAMLDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid XML: %w", err)
+	}
+	if !samlIs(root, nsSAMLProtocol, "Response") {
+		return nil, errors.New("document is not a SAML Response")
+	}
+
+	// Signatures reference elements by ID, so IDs must be unique
+	seen := map[string]bool{}
+	duplicate := false
+	samlWalk(root, func(el *etree.Element) {
+		if id := samlAttr(el, "ID"); id != "" {
+			duplicate = duplicate || seen[id]
+			seen[id] = true
+		}
+	})
+	if duplicate {
+		return nil, errors.New("duplicate ID attributes")
+	}
+
+	if destination := samlAttr(root, "Destination"); destination != "" && destination != samlACSURL() {
+		return nil, fmt.Errorf("response is addressed to %s", destination)
+	}
+	status := samlChild(root, nsSAMLProtocol, "Status")
+	if status == nil || samlChild(status, nsSAMLProtocol, "StatusCode") == nil ||
+		samlAttr(samlChild(status, nsSAMLProtocol, "StatusCode"), "Value") != samlStatusSuccess {
+		return nil, errors.New("identity provider did not report success")
+	}
+	if len(samlChildren(root, nsSAMLAssertion, "EncryptedAssertion")) > 0 {
+		return nil, errors.New("encrypted assertions are not supported")
+	}
+	assertion := samlChild(root, nsSAMLAssertion, "Assertion")
+	if assertion == nil {
+		return nil, errors.New("response must contain exactly one assertion")
+	}
+
+	// Either the whole response or the assertion must be signed by the IdP. A
+	// response signature that is present but invalid is never ignored. The
+	// assertion is read from what the signature covers from here on.
+	signedRoot, err := verifySAMLSignature(root, idp.certs, now)
+	switch {
+	case err == nil:
+		if assertion = samlChild(signedRoot, nsSAMLAssertion, "Assertion"); assertion == nil {
+			return nil, errors.New("signed response must contain exactly one assertion")
+		}
+	case errors.Is(err, errSAMLUnsigned):
+		if assertion, err = verifySAMLSignature(assertion, idp.certs, now); err != nil {
+			return nil, fmt.Errorf("invalid assertion signature: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid response signature: %w", err)
+	}
+
+	issuer := samlChild(assertion, nsSAMLAssertion, "Issuer")
+	if issuer == nil || strings.TrimSpace(samlText(issuer)) != idp.entityID {
+		return nil, errors.New("assertion was not issued by the configured identity provider")
+	}
+
+	notOnOrAfter, err := checkSAMLConditions(assertion, now)
+	if err != nil {
+		return nil, err
+	}
+	subject := samlChild(assertion, nsSAMLAssertion, "Subject")
+	if subject == nil {
+		return nil, errors.New("assertion has no subject")
+	}
+	inResponseTo, confirmedUntil, err := checkSAMLSubjectConfirmation(subject, now)
+	if err != nil {
+		return nil, err
+	}
+	if confirmedUntil.Before(notOnOrAfter) || notOnOrAfter.IsZero() {
+		notOnOrAfter = confirmedUntil
+	}
+
+	identity, err := mapSAMLIdentity(assertion, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	assertionID := samlAttr(assertion, "ID")
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.purge(now)
+	if _, used := s.assertions[assertionID]; used {
+		return nil, errors.New("assertion was already used")
+	}
+	if inResponseTo != "" {
+		if _, pending := s.requests[inResponseTo]; !pending {
+			return nil, errors.New("response does not answer a pending login request")
+		}
+		delete(s.requests, inResponseTo)
+	} else if !env.IsBool("CINESYNC_SAML_ALLOW_IDP_INITIATED", false) {
+		return nil, errors.New("identity provider initiated logins are not allowed")
+	}
+	s.assertions[assertionID] = notOnOrAfter.Add(samlClockSkew)
+	return identity, nil
+}
+
+// purge forgets expired login requests and used assertions
+func (s *samlState) purge(now time.Time) {
+	for id, expires := range s.requests {
+		if now.After(expires) {
+			delete(s.requests, id)
+		}
+	}
+	for id, expires := range s.assertions {
+		if now.After(expires) {
+			delete(s.assertions, id)
+		}
+	}
+}
+
+// checkSAMLConditions checks the validity window and that the assertion is
+// meant for this service provider, returning when it stops being valid
+func checkSAMLConditions(assertion *etree.Element, now time.Time) (time.Time, error) {
+	conditions := samlChild(assertion, nsSAMLAssertion, "Conditions")
+	if conditions == nil {
+		return time.Time{}, errors.New("assertion has no conditions")
+	}
+	if value := samlAttr(conditions, "NotBefore"); value != "" {
+		notBefore, err := time.Parse(time.RFC3339, value)
+		if err != nil || now.Add(samlClockSkew).Before(notBefore) {
+			return time.Time{}, errors.New("assertion is not valid yet")
+		}
+	}
+	var notOnOrAfter time.Time
+	if value := samlAttr(conditions, "NotOnOrAfter"); value != "" {
+		var err error
+		notOnOrAfter, err = time.Parse(time.RFC3339, value)
+		if err != nil || !now.Add(-samlClockSkew).Before(notOnOrAfter) {
+			return time.Time{}, errors.New("assertion has expired")
+		}
+	}
+
+	restrictions := samlChildren(conditions, nsSAMLAssertion, "AudienceRestriction")
+	if len(restrictions) == 0 {
+		return time.Time{}, errors.New("assertion has no audience restriction")
+	}
+	entityID := samlEntityID()
+	for _, restriction := range restrictions {
+		allowed := false
+		for _, audience := range samlChildren(restriction, nsSAMLAssertion, "Audience") {
+			if strings.TrimSpace(samlText(audience)) == entityID {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return time.Time{}, errors.New("assertion is meant for a different audience")
+		}
+	}
+	return notOnOrAfter, nil
+}
+
+// checkSAMLSubjectConfirmation requires a bearer confirmation addressed to
+// this ACS that has not expired, returning the request it answers and when
+// it expires
+func checkSAMLSubjectConfirmation(subject *etree.Element, now time.Time) (string, time.Time, error) {
+	for _, confirmation := range samlChildren(subject, nsSAMLAssertion, "SubjectConfirmation") {
+		if samlAttr(confirmation, "Method") != samlConfirmationBearer {
+			continue
+		}
+		data := samlChild(confirmation, nsSAMLAssertion, "SubjectConfirmationData")
+		if data == nil {
+			continue
+		}
+		notOnOrAfter, err := time.Parse(time.RFC3339, samlAttr(data, "NotOnOrAfter"))
+		if err != nil || !now.Add(-samlClockSkew).Before(notOnOrAfter) {
+			continue
+		}
+		if recipient := samlAttr(data, "Recipient"); recipient != "" && recipient != samlACSURL() {
+			continue
+		}
+		return samlAttr(data, "InResponseTo"), notOnOrAfter, nil
+	}
+	return "", time.Time{}, errors.New("assertion has no valid bearer subject confirmation")
+}
+
+// mapSAMLIdentity picks the username from CINESYNC_SAML_USERNAME_ATTRIBUTE
+// or the NameID, and makes the user an admin when CINESYNC_SAML_ROLE_ATTRIBUTE
+// holds one of CINESYNC_SAML_ADMIN_VALUES
+func mapSAMLIdentity(assertion, subject *etree.Element) (*samlIdentity, error) {
+	attributes := map[string][]string{}
+	for _, statement := range samlChildren(assertion, nsSAMLAssertion, "AttributeStatement") {
+		for _, attribute := range samlChildren(statement, nsSAMLAssertion, "Attribute") {
+			name := samlAttr(attribute, "Name")
+			for _, value := range samlChildren(attribute, nsSAMLAssertion, "AttributeValue") {
+				attributes[name] = append(attributes[name], strings.TrimSpace(samlText(value)))
+			}
+		}
+	}
+
+	username := ""
+	if name := env.GetString("CINESYNC_SAML_USERNAME_ATTRIBUTE", ""); name != "" {
+		if values := attributes[name]; len(values) > 0 {
+			username = values[0]
+		}
+	} else if nameID := samlChild(subject, nsSAMLAssertion, "NameID"); nameID != nil {
+		username = strings.TrimSpace(samlText(nameID))
+	}
+	if username == "" || len(username) > 256 {
+		return nil, errors.New("assertion does not name a usable username")
+	}
+
+	role := RoleUser
+	if name := env.GetString("CINESYNC_SAML_ROLE_ATTRIBUTE", ""); name != "" {
+		adminValues := map[string]bool{}
+		for _, value := range strings.Split(env.GetString("CINESYNC_SAML_ADMIN_VALUES", ""), ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				adminValues[value] = true
+			}
+		}
+		for _, value := range attributes[name] {
+			if adminValues[value] {
+				role = RoleAdmin
+			}
+		}
+	}
+	return &samlIdentity{username: username, role: role}, nil
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
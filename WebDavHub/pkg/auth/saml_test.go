@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+const testIdPEntityID = "https://idp.example.com"
+
+// samlTestIdP is an identity provider with a fresh signing key
+type samlTestIdP struct {
+	t       *testing.T
+	signer  *dsig.SigningContext
+	idp     *samlIdentityProvider
+	baseURL string
+}
+
+func newSAMLTestIdP(t *testing.T) *samlTestIdP {
+	t.Helper()
+	t.Setenv("CINESYNC_SAML_BASE_URL", "https://cinesync.example.com")
+	t.Setenv("CINESYNC_SAML_ENTITY_ID", "")
+	t.Setenv("CINESYNC_SAML_ALLOW_IDP_INITIATED", "true")
+	t.Setenv("CINESYNC_SAML_USERNAME_ATTRIBUTE", "")
+	t.Setenv("CINESYNC_SAML_ROLE_ATTRIBUTE", "")
+
+	keyStore := dsig.RandomKeyStoreForTest()
+	_, certDER, err := keyStore.GetKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := dsig.NewDefaultSigningContext(keyStore)
+	signer.Canonicalizer = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+
+	return &samlTestIdP{
+		t:       t,
+		signer:  signer,
+		idp:     &samlIdentityProvider{entityID: testIdPEntityID, certs: []*x509.Certificate{cert}},
+		baseURL: "https://cinesync.example.com",
+	}
+}
+
+// assertion returns an assertion for username that passes every check but
+// the signature
+func (p *samlTestIdP) assertion(id, username string, now time.Time) string {
+	notBefore := now.Add(-time.Minute).UTC().Format(time.RFC3339)
+	notOnOrAfter := now.Add(5 * time.Minute).UTC().Format(time.RFC3339)
+	return fmt.Sprintf(`<saml:Assertion xmlns:saml="%s" ID="%s" Version="2.0" IssueInstant="%s">`+
+		`<saml:Issuer>%s</saml:Issuer>`+
+		`<saml:Subject><saml:NameID>%s</saml:NameID>`+
+		`<saml:SubjectConfirmation Method="%s"><saml:SubjectConfirmationData NotOnOrAfter="%s" Recipient="%s"/></saml:SubjectConfirmation>`+
+		`</saml:Subject>`+
+		`<saml:Conditions NotBefore="%s" NotOnOrAfter="%s"><saml:AudienceRestriction><saml:Audience>%s</saml:Audience></saml:AudienceRestriction></saml:Conditions>`+
+		`</saml:Assertion>`,
+		nsSAMLAssertion, id, notBefore, testIdPEntityID, username, samlConfirmationBearer, notOnOrAfter,
+		samlACSURL(), notBefore, notOnOrAfter, samlEntityID())
+}
+
+// sign returns the element in xml with an enveloped signature
+func (p *samlTestIdP) sign(xml string) string {
+	p.t.Helper()
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(xml); err != nil {
+		p.t.Fatal(err)
+	}
+	signed, err := p.signer.SignEnveloped(doc.Root())
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	out := etree.NewDocument()
+	out.SetRoot(signed)
+	s, err := out.WriteToString()
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	return s
+}
+
+// response wraps content in a successful Response
+func (p *samlTestIdP) response(content string) []byte {
+	return []byte(fmt.Sprintf(`<samlp:Response xmlns:samlp="%s" xmlns:saml="%s" ID="_response" Version="2.0" Destination="%s">`+
+		`<saml:Issuer>%s</saml:Issuer>`+
+		`<samlp:Status><samlp:StatusCode Value="%s"/></samlp:Status>%s</samlp:Response>`,
+		nsSAMLProtocol, nsSAMLAssertion, samlACSURL(), testIdPEntityID, samlStatusSuccess, content))
+}
+
+func newTestSAMLState() *samlState {
+	return &samlState{requests: map[string]time.Time{}, assertions: map[string]time.Time{}}
+}
+
+func TestSAMLSignedAssertionIsAccepted(t *testing.T) {
+	idp := newSAMLTestIdP(t)
+	now := time.Now()
+	data := idp.response(idp.sign(idp.assertion("_a1", "alice", now)))
+
+	identity, err := newTestSAMLState().consumeResponse(data, idp.idp, now)
+	if err != nil {
+		t.Fatalf("valid signed assertion rejected: %v", err)
+	}
+	if identity.username != "alice" || identity.role != RoleUser {
+		t.Fatalf("identity = %+v, want alice as %s", identity, RoleUser)
+	}
+}
+
+func TestSAMLSignedResponseIsAccepted(t *testing.T) {
+	idp := newSAMLTestIdP(t)
+	now := time.Now()
+	data := []byte(idp.sign(string(idp.response(idp.assertion("_a1", "alice", now)))))
+
+	identity, err := newTestSAMLState().consumeResponse(data, idp.idp, now)
+	if err != nil {
+		t.Fatalf("valid signed response rejected: %v", err)
+	}
+	if identity.username != "alice" {
+		t.Fatalf("username = %q, want alice", identity.username)
+	}
+}
+
+func TestSAMLTamperedAssertionIsRejected(t *testing.T) {
+	idp := newSAMLTestIdP(t)
+	now := time.Now()
+	signed := idp.sign(idp.assertion("_a1", "alice", now))
+	tampered := strings.Replace(signed, "<saml:NameID>alice<", "<saml:NameID>admin<", 1)
+	if tampered == signed {
+		t.Fatal("test assertion has no NameID to tamper with")
+	}
+
+	if _, err := newTestSAMLState().consumeResponse(idp.response(tampered), idp.idp, now); err == nil {
+		t.Fatal("tampered assertion was accepted")
+	}
+}
+
+func TestSAMLUnsignedAssertionIsRejected(t *testing.T) {
+	idp := newSAMLTestIdP(t)
+	now := time.Now()
+	data := idp.response(idp.assertion("_a1", "alice", now))
+
+	if _, err := newTestSAMLState().consumeResponse(data, idp.idp, now); err == nil {
+		t.Fatal("unsigned assertion was accepted")
+	}
+}
+
+func TestSAMLUntrustedSignerIsRejected(t *testing.T) {
+	idp := newSAMLTestIdP(t)
+	other := newSAMLTestIdP(t)
+	now := time.Now()
+	data := other.response(other.sign(other.assertion("_a1", "alice", now)))
+
+	if _, err := newTestSAMLState().consumeResponse(data, idp.idp, now); err == nil {
+		t.Fatal("assertion signed by an unknown key was accepted")
+	}
+}
+
+func TestSAMLSignatureWrappingIsRejected(t *testing.T) {
+	idp := newSAMLTestIdP(t)
+	now := time.Now()
+	signed := idp.sign(idp.assertion("_a1", "alice", now))
+	signatureStart := strings.Index(signed, "<ds:Signature")
+	signatureEnd := strings.Index(signed, "</ds:Signature>") + len("</ds:Signature>")
+	if signatureStart < 0 || signatureEnd < signatureStart {
+		t.Fatal("signed assertion has no signature")
+	}
+	signature := signed[signatureStart:signatureEnd]
+
+	// The forged assertion carries the genuine signature, which still
+	// references the genuine assertion hidden next to it
+	forged := strings.Replace(idp.assertion("_forged", "admin", now), "</saml:Assertion>", signature+"</saml:Assertion>", 1)
+	for name, content := range map[string]string{
+		"genuine in extensions": `<samlp:Extensions>` + signed + `</samlp:Extensions>` + forged,
+		"genuine inside forged": strings.Replace(forged, "</saml:Assertion>", signed+"</saml:Assertion>", 1),
+		"forged reusing id":     strings.Replace(forged, `ID="_forged"`, `ID="_a1"`, 1) + `<samlp:Extensions>` + signed + `</samlp:Extensions>`,
+	} {
+		identity, err := newTestSAMLState().consumeResponse(idp.response(content), idp.idp, now)
+		if err == nil {
+			t.Errorf("%s: wrapped response accepted for %q", name, identity.username)
+		}
+	}
+}
+
+func TestSAMLAssertionReplayIsRejected(t *testing.T) {
+	idp := newSAMLTestIdP(t)
+	now := time.Now()
+	data := idp.response(idp.sign(idp.assertion("_a1", "alice", now)))
+	state := newTestSAMLState()
+
+	if _, err := state.consumeResponse(data, idp.idp, now); err != nil {
+		t.Fatalf("first use rejected: %v", err)
+	}
+	if _, err := state.consumeResponse(data, idp.idp, now); err == nil {
+		t.Fatal("replayed assertion was accepted")
+	}
+}
+
+func TestSAMLDocumentWithDTDIsRejected(t *testing.T) {
+	data := []byte(`<!DOCTYPE r [<!ENTITY x "y">]><r>&x;</r>`)
+	if _, err := parseSAMLDocument(data); err == nil {
+		t.Fatal("document with a DTD was parsed")
+	}
+}
@@ -0,0 +1,529 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cinesync/pkg/logger"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+
+	passwordHashIterations = 120000
+	passwordSaltBytes      = 16
+	passwordKeyBytes       = 32
+)
+
+var (
+	ErrUserExists       = errors.New("user already exists")
+	ErrUserNotFound     = errors.New("user not found")
+	ErrInvalidUsername  = errors.New("username must be 3-64 characters of letters, digits, '.', '-' or '_'")
+	ErrInviteUsed       = errors.New("invite has already been used")
+	ErrInviteExpired    = errors.New("invite has expired")
+	ErrInvalidInvite    = errors.New("invalid invite")
+	errMalformedPwdHash = errors.New("malformed password hash")
+)
+
+// User is an account stored in the multi-user store. The environment
+// credentials remain the built-in administrator and are not stored here.
+type User struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"passwordHash"`
+	Role         string    `json:"role"`
+	CreatedAt    time.Time `json:"createdAt"`
+	// WebDAVRoot confines the user's WebDAV view to this subtree of the share,
+	// e.g. "/Movies/Kids". Empty shows the whole share.
+	WebDAVRoot string `json:"webdavRoot,omitempty"`
+	// TokensValidAfter is when the password last changed. Tokens issued
+	// before it are rejected, which signs out every session of the old password.
+	TokensValidAfter *time.Time `json:"tokensValidAfter,omitempty"`
+}
+
+// userStoreData is the on-disk layout of the user store
+type userStoreData struct {
+	Users       []User           `json:"users"`
+	UsedInvites map[string]int64 `json:"usedInvites"`
+}
+
+var userStoreMutex sync.Mutex
+
+// loadedUsers is the last user store read from disk that passed validation.
+// Requests are served from it so a malformed edit of the file cannot lock
+// everyone out; ReloadUsers and the users file watcher replace it.
+var (
+	loadedUsers   *userStoreData
+	loadedModTime time.Time
+	loadedSize    int64
+)
+
+// getUserStorePath returns the path to the user store file
+func getUserStorePath() string {
+	return filepath.Join("..", "db", "users.json")
+}
+
+// loadUserStore returns a copy of the loaded user store, reading it from disk
+// on first use. The caller must hold userStoreMutex.
+func loadUserStore() (*userStoreData, error) {
+	if loadedUsers == nil {
+		store, err := readUserStore()
+		if err != nil {
+			return nil, err
+		}
+		loadedUsers = store
+	}
+	return loadedUsers.clone(), nil
+}
+
+// readUserStore reads and validates the user store file, returning an empty
+// store if none exists. The caller must hold userStoreMutex.
+func readUserStore() (*userStoreData, error) {
+	store := &userStoreData{UsedInvites: map[string]int64{}}
+
+	path := getUserStorePath()
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		loadedModTime, loadedSize = time.Time{}, 0
+		return store, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user store: %v", err)
+	}
+
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("failed to parse user store: %v", err)
+	}
+	// Hand-written entries may leave out the role or write the root loosely
+	for i := range store.Users {
+		if store.Users[i].Role == "" {
+			store.Users[i].Role = RoleUser
+		}
+		if root := store.Users[i].WebDAVRoot; root != "" && !hasParentSegment(root) {
+			store.Users[i].WebDAVRoot = cleanWebDAVRoot(root)
+		}
+	}
+	if err := store.validate(); err != nil {
+		return nil, fmt.Errorf("invalid user store: %v", err)
+	}
+	if store.UsedInvites == nil {
+		store.UsedInvites = map[string]int64{}
+	}
+	loadedModTime, loadedSize = info.ModTime(), info.Size()
+	return store, nil
+}
+
+// saveUserStore atomically writes the user store to disk and makes it the
+// loaded store. The caller must hold userStoreMutex.
+func saveUserStore(store *userStoreData) error {
+	// Forget consumed invites once they could no longer be replayed anyway
+	now := time.Now().Unix()
+	for id, expiresAt := range store.UsedInvites {
+		if expiresAt < now {
+			delete(store.UsedInvites, id)
+		}
+	}
+
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := getUserStorePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	loadedUsers = store.clone()
+	if info, err := os.Stat(path); err == nil {
+		loadedModTime, loadedSize = info.ModTime(), info.Size()
+	}
+	return nil
+}
+
+// ReloadUsers re-reads the user store from disk and returns the number of
+// users in it. A file that cannot be read or fails validation is rejected and
+// the previously loaded users stay in effect.
+func ReloadUsers() (int, error) {
+	userStoreMutex.Lock()
+	defer userStoreMutex.Unlock()
+
+	store, err := readUserStore()
+	if err != nil {
+		return 0, err
+	}
+	if loadedUsers != nil && revokeChangedUsers(loadedUsers, store) {
+		if err := saveUserStore(store); err != nil {
+			logger.Warn("Failed to save token revocations of changed users: %v", err)
+		}
+	}
+	loadedUsers = store
+	return len(store.Users), nil
+}
+
+// revokeChangedUsers stamps the users whose password hash differs from the
+// previous store, so tokens issued for the old password stop working, and
+// users added to the file, so tokens of an earlier user of the same name do
+// not come back. Tokens of removed users fail their lookup in tokenRevoked.
+// It reports whether any user was stamped.
+func revokeChangedUsers(previous, store *userStoreData) bool {
+	now := tokenCutoff()
+	changed := false
+	for i, u := range store.Users {
+		j := previous.findUser(u.Username)
+		if j < 0 {
+			if u.TokensValidAfter != nil {
+				continue
+			}
+			store.Users[i].TokensValidAfter = &now
+			changed = true
+			logger.Info("User '%s' was added to the users file", u.Username)
+			continue
+		}
+		if previous.Users[j].PasswordHash == u.PasswordHash {
+			continue
+		}
+		// Whoever edited the file may have advanced the timestamp already
+		previousAfter := previous.Users[j].TokensValidAfter
+		if u.TokensValidAfter != nil && (previousAfter == nil || u.TokensValidAfter.After(*previousAfter)) {
+			continue
+		}
+		store.Users[i].TokensValidAfter = &now
+		changed = true
+		logger.Info("Password of user '%s' changed in the users file, signing out its sessions", u.Username)
+	}
+	for _, u := range previous.Users {
+		if store.findUser(u.Username) < 0 {
+			logger.Info("User '%s' was removed from the users file, signing out its sessions", u.Username)
+		}
+	}
+	return changed
+}
+
+// tokenCutoff returns the current time at the precision of token issue
+// times, so a token issued right after a password change is still valid
+func tokenCutoff() time.Time {
+	return time.Now().UTC().Truncate(jwt.TimePrecision)
+}
+
+// tokenRevoked reports whether claims were issued to a user who is no longer
+// stored, or before their user was created or last changed password. The
+// environment administrator and SAML users are not stored and are never
+// revoked this way.
+func tokenRevoked(claims *JWTClaims) bool {
+	if claims.Provider == providerSAML || (claims.Provider == "" && claims.Username == GetCredentials().Username) {
+		return false
+	}
+	user, err := GetUser(claims.Username)
+	if err != nil {
+		return true
+	}
+	cutoff := user.CreatedAt
+	if user.TokensValidAfter != nil && user.TokensValidAfter.After(cutoff) {
+		cutoff = *user.TokensValidAfter
+	}
+	cutoff = cutoff.Truncate(jwt.TimePrecision)
+	// Issue times travel as fractional seconds and may parse back one
+	// millisecond early
+	return claims.IssuedAt == nil || claims.IssuedAt.Time.Add(jwt.TimePrecision).Before(cutoff)
+}
+
+// claimsRole returns the current role of the user claims were issued to. A
+// stored user's role is read from the store, so a demotion applies to tokens
+// issued before it; SAML users keep the role their assertion granted.
+func claimsRole(claims *JWTClaims) string {
+	if claims.Provider == providerSAML {
+		return claims.Role
+	}
+	if claims.Username == GetCredentials().Username {
+		return RoleAdmin
+	}
+	user, err := GetUser(claims.Username)
+	if err != nil {
+		return ""
+	}
+	return user.Role
+}
+
+// clone returns a deep copy so callers can modify a store before saving it
+func (s *userStoreData) clone() *userStoreData {
+	copied := &userStoreData{
+		Users:       append([]User(nil), s.Users...),
+		UsedInvites: make(map[string]int64, len(s.UsedInvites)),
+	}
+	for id, expiresAt := range s.UsedInvites {
+		copied.UsedInvites[id] = expiresAt
+	}
+	return copied
+}
+
+// validate checks every stored user has a valid, unique name, a known role
+// and a password hash VerifyPassword can read
+func (s *userStoreData) validate() error {
+	seen := make(map[string]bool, len(s.Users))
+	for i, u := range s.Users {
+		if err := validateUsername(u.Username); err != nil {
+			return fmt.Errorf("user %d: %v", i+1, err)
+		}
+		name := strings.ToLower(u.Username)
+		if seen[name] {
+			return fmt.Errorf("user %q is listed twice", u.Username)
+		}
+		seen[name] = true
+		if u.Role != RoleAdmin && u.Role != RoleUser {
+			return fmt.Errorf("user %q has unknown role %q", u.Username, u.Role)
+		}
+		if _, _, _, err := parsePasswordHash(u.PasswordHash); err != nil {
+			return fmt.Errorf("user %q: %v", u.Username, err)
+		}
+		if hasParentSegment(u.WebDAVRoot) {
+			return fmt.Errorf("user %q has a webdavRoot outside the share", u.Username)
+		}
+	}
+	return nil
+}
+
+// findUser returns the index of a user in the store, or -1
+func (s *userStoreData) findUser(username string) int {
+	for i, u := range s.Users {
+		if strings.EqualFold(u.Username, username) {
+			return i
+		}
+	}
+	return -1
+}
+
+// validateUsername checks that a username is safe to store and display
+func validateUsername(username string) error {
+	if len(username) < 3 || len(username) > 64 {
+		return ErrInvalidUsername
+	}
+	for _, c := range username {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_') {
+			return ErrInvalidUsername
+		}
+	}
+	return nil
+}
+
+// GetUser returns a stored user by name
+func GetUser(username string) (*User, error) {
+	userStoreMutex.Lock()
+	defer userStoreMutex.Unlock()
+
+	store, err := loadUserStore()
+	if err != nil {
+		return nil, err
+	}
+	i := store.findUser(username)
+	if i < 0 {
+		return nil, ErrUserNotFound
+	}
+	user := store.Users[i]
+	return &user, nil
+}
+
+// ListUsers returns all stored users
+func ListUsers() ([]User, error) {
+	userStoreMutex.Lock()
+	defer userStoreMutex.Unlock()
+
+	store, err := loadUserStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.Users, nil
+}
+
+// CreateUser adds a user with a hashed password to the store
+func CreateUser(username, password, role string) (*User, error) {
+	userStoreMutex.Lock()
+	defer userStoreMutex.Unlock()
+
+	store, err := loadUserStore()
+	if err != nil {
+		return nil, err
+	}
+	return createUserLocked(store, username, password, role)
+}
+
+// createUserLocked adds a user to an already loaded store and saves it.
+// The caller must hold userStoreMutex.
+func createUserLocked(store *userStoreData, username, password, role string) (*User, error) {
+	if err := validateUsername(username); err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(username, GetCredentials().Username) || store.findUser(username) >= 0 {
+		return nil, ErrUserExists
+	}
+	if role != RoleAdmin {
+		role = RoleUser
+	}
+	if err := ValidatePassword(password); err != nil {
+		return nil, err
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := User{Username: username, PasswordHash: hash, Role: role, CreatedAt: time.Now().UTC()}
+	store.Users = append(store.Users, user)
+	if err := saveUserStore(store); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateUserPassword replaces a stored user's password after checking it
+// against the password policy
+func UpdateUserPassword(username, newPassword string) error {
+	if err := ValidatePassword(newPassword); err != nil {
+		return err
+	}
+
+	userStoreMutex.Lock()
+	defer userStoreMutex.Unlock()
+
+	store, err := loadUserStore()
+	if err != nil {
+		return err
+	}
+	i := store.findUser(username)
+	if i < 0 {
+		return ErrUserNotFound
+	}
+
+	hash, err := HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	now := tokenCutoff()
+	store.Users[i].PasswordHash = hash
+	store.Users[i].TokensValidAfter = &now
+	return saveUserStore(store)
+}
+
+// authenticateStoredUser checks credentials against the user store and
+// returns the matching user
+func authenticateStoredUser(username, password string) (*User, bool) {
+	user, err := GetUser(username)
+	if err != nil {
+		// Spend the same time as for a known user, so response times do
+		// not tell which usernames exist
+		VerifyPassword(password, dummyPasswordHash())
+		return nil, false
+	}
+	if verifiedCredentials.verified(username, password, user.PasswordHash) {
+		return user, true
+	}
+	if !VerifyPassword(password, user.PasswordHash) {
+		return nil, false
+	}
+	verifiedCredentials.remember(username, password, user.PasswordHash)
+	return user, true
+}
+
+// dummyPasswordHash is a hash no password is checked against for real, used to
+// time failed lookups like real verifications
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := HashPassword("")
+	return hash
+})
+
+// HashPassword hashes a password with PBKDF2-HMAC-SHA256 and a random salt.
+// The result has the form pbkdf2-sha256$<iterations>$<salt>$<key>.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltBytes)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := pbkdf2SHA256([]byte(password), salt, passwordHashIterations, passwordKeyBytes)
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s",
+		passwordHashIterations,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// VerifyPassword reports whether password matches a hash from HashPassword
+func VerifyPassword(password, encoded string) bool {
+	iterations, salt, key, err := parsePasswordHash(encoded)
+	if err != nil {
+		return false
+	}
+	derived := pbkdf2SHA256([]byte(password), salt, iterations, len(key))
+	return subtle.ConstantTimeCompare(derived, key) == 1
+}
+
+func parsePasswordHash(encoded string) (int, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return 0, nil, nil, errMalformedPwdHash
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return 0, nil, nil, errMalformedPwdHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return 0, nil, nil, errMalformedPwdHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(key) == 0 {
+		return 0, nil, nil, errMalformedPwdHash
+	}
+	return iterations, salt, key, nil
+}
+
+// pbkdf2SHA256 derives a key as described in RFC 8018 section 5.2
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	derived := make([]byte, 0, blocks*hashLen)
+	var counter [4]byte
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(counter[:], uint32(block))
+		prf.Write(counter[:])
+		u := prf.Sum(nil)
+
+		t := make([]byte, len(u))
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		derived = append(derived, t...)
+	}
+	return derived[:keyLen]
+}
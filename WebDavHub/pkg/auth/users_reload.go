@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+)
+
+const usersWatchInterval = 5 * time.Second
+
+var usersWatcherOnce sync.Once
+
+// HandleReloadUsers serves POST /api/auth/users/reload. It re-reads the users
+// file and reports the new user count; a malformed file is rejected with 422
+// and the users loaded before stay in effect.
+func HandleReloadUsers(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	claims, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	count, err := ReloadUsers()
+	if err != nil {
+		logger.Warn("Users reload requested by '%s' rejected: %v", claims.Username, err)
+		apierror.WriteError(w, http.StatusUnprocessableEntity, apierror.CodeAuthUserStoreInvalid, err.Error())
+		return
+	}
+
+	logger.Info("Users reloaded by '%s': %d users", claims.Username, count)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"users": count,
+	})
+}
+
+// StartUsersWatcher reloads the users file whenever it changes on disk, unless
+// CINESYNC_USERS_WATCH is false
+func StartUsersWatcher() {
+	if !env.IsBool("CINESYNC_USERS_WATCH", true) {
+		return
+	}
+	usersWatcherOnce.Do(func() {
+		go watchUsers(usersWatchInterval)
+	})
+}
+
+// watchUsers polls the users file's modification time and size. A change that
+// fails validation is reported once and skipped until the file changes again.
+func watchUsers(interval time.Duration) {
+	var rejectedModTime time.Time
+	var rejectedSize int64
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		var modTime time.Time
+		var size int64
+		info, err := os.Stat(getUserStorePath())
+		if err == nil {
+			modTime, size = info.ModTime(), info.Size()
+		} else if !os.IsNotExist(err) {
+			continue
+		}
+
+		userStoreMutex.Lock()
+		changed := !modTime.Equal(loadedModTime) || size != loadedSize
+		userStoreMutex.Unlock()
+		if !changed || (modTime.Equal(rejectedModTime) && size == rejectedSize) {
+			continue
+		}
+
+		count, err := ReloadUsers()
+		if err != nil {
+			logger.Warn("Keeping the loaded users, the changed users file was rejected: %v", err)
+			rejectedModTime, rejectedSize = modTime, size
+			continue
+		}
+		logger.Info("Users file changed, reloaded %d users", count)
+	}
+}
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// useUserStore runs the test in a directory whose user store starts empty
+func useUserStore(t *testing.T) {
+	t.Helper()
+	root := t.TempDir()
+	work := filepath.Join(root, "work")
+	if err := os.Mkdir(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+	userStoreMutex.Lock()
+	loadedUsers = nil
+	userStoreMutex.Unlock()
+	t.Cleanup(func() {
+		os.Chdir(previous)
+		userStoreMutex.Lock()
+		loadedUsers = nil
+		userStoreMutex.Unlock()
+	})
+}
+
+// writeUsersFile replaces the users file as a hand edit would
+func writeUsersFile(t *testing.T, users []User) {
+	t.Helper()
+	data, err := json.Marshal(userStoreData{Users: users})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(getUserStorePath(), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReloadUsers(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTokensOfRemovedUsersAreRevoked(t *testing.T) {
+	useUserStore(t)
+	t.Setenv("CINESYNC_USERNAME", "admin")
+	user, err := CreateUser("alice", "Correct-Horse-42", RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := GenerateJWT("alice", RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := parseClaims(token); !ok {
+		t.Fatal("token issued right after the user was created is rejected")
+	}
+
+	writeUsersFile(t, nil)
+	if _, ok := parseClaims(token); ok {
+		t.Fatal("token of a removed user is still accepted")
+	}
+
+	// Putting the entry back must not revive tokens of the removed user
+	time.Sleep(5 * time.Millisecond)
+	writeUsersFile(t, []User{{Username: "alice", PasswordHash: user.PasswordHash, Role: RoleUser}})
+	if _, ok := parseClaims(token); ok {
+		t.Fatal("token of a removed user is accepted after the user was added again")
+	}
+	fresh, err := GenerateJWT("alice", RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := parseClaims(fresh); !ok {
+		t.Fatal("token issued after the user was added again is rejected")
+	}
+}
+
+func TestUnstoredIdentitiesAreNotRevoked(t *testing.T) {
+	useUserStore(t)
+	t.Setenv("CINESYNC_USERNAME", "admin")
+
+	issuedAt := jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(time.Now())}
+	for _, tt := range []struct {
+		name    string
+		claims  JWTClaims
+		revoked bool
+	}{
+		{"environment administrator", JWTClaims{Username: "admin", Role: RoleAdmin, RegisteredClaims: issuedAt}, false},
+		{"SAML user", JWTClaims{Username: "carol", Role: RoleUser, Provider: providerSAML, RegisteredClaims: issuedAt}, false},
+		{"unknown user", JWTClaims{Username: "carol", Role: RoleUser, RegisteredClaims: issuedAt}, true},
+	} {
+		if got := tokenRevoked(&tt.claims); got != tt.revoked {
+			t.Errorf("%s: tokenRevoked = %v, want %v", tt.name, got, tt.revoked)
+		}
+	}
+}
+
+func TestDemotedAdministratorLosesAdminAccess(t *testing.T) {
+	useUserStore(t)
+	t.Setenv("CINESYNC_USERNAME", "admin")
+	t.Setenv("CINESYNC_AUTH_ENABLED", "true")
+	user, err := CreateUser("alice", "Correct-Horse-42", RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := GenerateJWT("alice", RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requireAdminStatus := func() int {
+		r := httptest.NewRequest(http.MethodPost, "/api/database/prune", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		if RequireAdmin(w, r) {
+			return http.StatusOK
+		}
+		return w.Code
+	}
+	if got := requireAdminStatus(); got != http.StatusOK {
+		t.Fatalf("administrator got %d", got)
+	}
+
+	user.Role = RoleUser
+	user.TokensValidAfter = &user.CreatedAt
+	writeUsersFile(t, []User{*user})
+	if got := requireAdminStatus(); got != http.StatusForbidden {
+		t.Fatalf("demoted administrator got %d, want %d", got, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+	"path"
+	"strings"
+)
+
+// WebDAVUser is the account behind an authenticated WebDAV request
+type WebDAVUser struct {
+	Username string
+	// Root is the subtree the user is confined to, "/" for the whole share
+	Root string
+}
+
+type webdavUserKey struct{}
+
+// WebDAVUserFromContext returns the account BasicAuthMiddleware authenticated.
+// It is absent when WebDAV authentication is disabled.
+func WebDAVUserFromContext(ctx context.Context) (WebDAVUser, bool) {
+	user, ok := ctx.Value(webdavUserKey{}).(WebDAVUser)
+	return user, ok
+}
+
+// withWebDAVUser attaches the authenticated account to a request context
+func withWebDAVUser(ctx context.Context, user WebDAVUser) context.Context {
+	return context.WithValue(ctx, webdavUserKey{}, user)
+}
+
+// webdavRootFor returns the WebDAV root of an authenticated user. The
+// environment administrator always sees the whole share.
+func webdavRootFor(username string) string {
+	if strings.EqualFold(username, GetCredentials().Username) {
+		return "/"
+	}
+	user, err := GetUser(username)
+	if err != nil || user.WebDAVRoot == "" {
+		return "/"
+	}
+	return user.WebDAVRoot
+}
+
+// cleanWebDAVRoot normalizes a root to a slash-separated absolute path
+func cleanWebDAVRoot(root string) string {
+	return path.Clean("/" + strings.ReplaceAll(root, "\\", "/"))
+}
+
+// hasParentSegment reports whether a root tries to climb out of the share
+func hasParentSegment(root string) bool {
+	for _, segment := range strings.Split(strings.ReplaceAll(root, "\\", "/"), "/") {
+		if segment == ".." {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+)
+
+// Cache stores byte values under string keys. Implementations are safe for
+// concurrent use; the Redis one can be shared between replicas.
+type Cache interface {
+	// Get returns the value of key and whether it was present and unexpired
+	Get(key string) ([]byte, bool, error)
+	// Set stores value under key, expiring after ttl; ttl <= 0 never expires
+	Set(key string, value []byte, ttl time.Duration) error
+	// Delete removes key
+	Delete(key string) error
+	// Invalidate removes every key starting with prefix
+	Invalidate(prefix string) error
+}
+
+const defaultMaxEntries = 10000
+
+var (
+	defaultCache Cache
+	defaultMutex sync.Mutex
+)
+
+// Default returns the process-wide cache, configured from the environment on
+// first use: Redis when CINESYNC_REDIS_URL is set, otherwise an in-memory LRU
+// bounded by CINESYNC_CACHE_MAX_ENTRIES
+func Default() Cache {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+
+	if defaultCache == nil {
+		defaultCache = newFromEnv()
+	}
+	return defaultCache
+}
+
+// SetDefault replaces the process-wide cache
+func SetDefault(c Cache) {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	defaultCache = c
+}
+
+func newFromEnv() Cache {
+	if redisURL := env.GetString("CINESYNC_REDIS_URL", ""); redisURL != "" {
+		redisCache, err := NewRedisCache(redisURL)
+		if err == nil {
+			logger.Info("Using Redis cache at %s", redisCache.addr)
+			return redisCache
+		}
+		logger.Warn("Falling back to in-memory cache: %v", err)
+	}
+	return NewMemoryCache(env.GetInt("CINESYNC_CACHE_MAX_ENTRIES", defaultMaxEntries))
+}
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewMemoryCache(2)
+	c.Set("a", []byte("1"), 0)
+	c.Set("b", []byte("2"), 0)
+	c.Get("a")
+	c.Set("c", []byte("3"), 0)
+
+	if _, ok, _ := c.Get("b"); ok {
+		t.Error("least recently used key was kept")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok, _ := c.Get(key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+}
+
+func TestMemoryCacheExpiresAndInvalidates(t *testing.T) {
+	c := NewMemoryCache(0)
+	c.Set("stats:a", []byte("1"), time.Nanosecond)
+	c.Set("stats:b", []byte("2"), 0)
+	c.Set("files:a", []byte("3"), 0)
+	time.Sleep(time.Millisecond)
+
+	if _, ok, _ := c.Get("stats:a"); ok {
+		t.Error("expired key was returned")
+	}
+	c.Invalidate("stats:")
+	if _, ok, _ := c.Get("stats:b"); ok {
+		t.Error("invalidated key was returned")
+	}
+	if value, ok, _ := c.Get("files:a"); !ok || string(value) != "3" {
+		t.Error("key outside the prefix was invalidated")
+	}
+}
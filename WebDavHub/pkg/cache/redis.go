@@ -0,0 +1,300 @@
+package cache
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	redisKeyPrefix   = "cinesync:"
+	redisPoolSize    = 8
+	redisTimeout     = 2 * time.Second
+	redisScanBatch   = 500
+	redisMaxBulkSize = 512 * 1024 * 1024
+)
+
+// RedisCache is a Cache kept in Redis so replicas share it. It speaks RESP
+// directly over a small connection pool; keys are namespaced under
+// "cinesync:" so Invalidate never touches data of other applications.
+type RedisCache struct {
+	addr     string
+	useTLS   bool
+	username string
+	password string
+	database int
+	pool     chan *redisConn
+}
+
+// redisConn is one pooled connection
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+// redisError is an error reply from the server
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// NewRedisCache connects to the server of a redis:// or rediss:// URL of the
+// form redis://[user:password@]host[:port][/database]
+func NewRedisCache(rawURL string) (*RedisCache, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	if parsed.Scheme != "redis" && parsed.Scheme != "rediss" {
+		return nil, fmt.Errorf("invalid Redis URL scheme %q", parsed.Scheme)
+	}
+
+	c := &RedisCache{
+		addr:   parsed.Host,
+		useTLS: parsed.Scheme == "rediss",
+		pool:   make(chan *redisConn, redisPoolSize),
+	}
+	if parsed.Port() == "" {
+		c.addr = net.JoinHostPort(parsed.Hostname(), "6379")
+	}
+	if parsed.User != nil {
+		c.username = parsed.User.Username()
+		c.password, _ = parsed.User.Password()
+	}
+	if database := strings.Trim(parsed.Path, "/"); database != "" {
+		if c.database, err = strconv.Atoi(database); err != nil {
+			return nil, fmt.Errorf("invalid Redis database %q", database)
+		}
+	}
+
+	// Fail early on a wrong address or credentials
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	c.release(conn, nil)
+	return c, nil
+}
+
+func (c *RedisCache) Get(key string) ([]byte, bool, error) {
+	reply, err := c.do("GET", redisKeyPrefix+key)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected GET reply %T", reply)
+	}
+	return value, true, nil
+}
+
+func (c *RedisCache) Set(key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", redisKeyPrefix + key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := c.do(args...)
+	return err
+}
+
+func (c *RedisCache) Delete(key string) error {
+	_, err := c.do("DEL", redisKeyPrefix+key)
+	return err
+}
+
+func (c *RedisCache) Invalidate(prefix string) error {
+	conn, err := c.acquire()
+	if err != nil {
+		return err
+	}
+
+	pattern := escapeRedisPattern(redisKeyPrefix+prefix) + "*"
+	cursor := "0"
+	for {
+		reply, err := conn.command("SCAN", cursor, "MATCH", pattern, "COUNT", strconv.Itoa(redisScanBatch))
+		if err != nil {
+			c.release(conn, err)
+			return err
+		}
+		page, ok := reply.([]interface{})
+		if !ok || len(page) != 2 {
+			c.release(conn, nil)
+			return fmt.Errorf("redis: unexpected SCAN reply")
+		}
+		next, _ := page[0].([]byte)
+		keys, _ := page[1].([]interface{})
+		if len(keys) > 0 {
+			args := []string{"DEL"}
+			for _, key := range keys {
+				if name, ok := key.([]byte); ok {
+					args = append(args, string(name))
+				}
+			}
+			if _, err := conn.command(args...); err != nil {
+				c.release(conn, err)
+				return err
+			}
+		}
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			break
+		}
+	}
+	c.release(conn, nil)
+	return nil
+}
+
+// do runs one command on a pooled connection
+func (c *RedisCache) do(args ...string) (interface{}, error) {
+	conn, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.command(args...)
+	c.release(conn, err)
+	return reply, err
+}
+
+func (c *RedisCache) acquire() (*redisConn, error) {
+	select {
+	case conn := <-c.pool:
+		return conn, nil
+	default:
+		return c.dial()
+	}
+}
+
+// release returns conn to the pool, or closes it after a network or protocol
+// error since the stream may be out of sync. Error replies leave it usable.
+func (c *RedisCache) release(conn *redisConn, err error) {
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		conn.conn.Close()
+		return
+	}
+	select {
+	case c.pool <- conn:
+	default:
+		conn.conn.Close()
+	}
+}
+
+func (c *RedisCache) dial() (*redisConn, error) {
+	dialer := &net.Dialer{Timeout: redisTimeout}
+	var conn net.Conn
+	var err error
+	if c.useTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.addr, nil)
+	} else {
+		conn, err = dialer.Dial("tcp", c.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+
+	rc := &redisConn{conn: conn, reader: bufio.NewReader(conn), writer: bufio.NewWriter(conn)}
+	if c.password != "" {
+		args := []string{"AUTH", c.password}
+		if c.username != "" {
+			args = []string{"AUTH", c.username, c.password}
+		}
+		if _, err := rc.command(args...); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if c.database != 0 {
+		if _, err := rc.command("SELECT", strconv.Itoa(c.database)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return rc, nil
+}
+
+// command writes args as a RESP array and reads the reply
+func (rc *redisConn) command(args ...string) (interface{}, error) {
+	rc.conn.SetDeadline(time.Now().Add(redisTimeout))
+	fmt.Fprintf(rc.writer, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(rc.writer, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := rc.writer.Flush(); err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	return rc.readReply()
+}
+
+// readReply parses one RESP2 reply: simple strings, errors, integers, bulk
+// strings ([]byte, nil when null) and arrays
+func (rc *redisConn) readReply() (interface{}, error) {
+	line, err := rc.reader.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		value, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid integer %q", line[1:])
+		}
+		return value, nil
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size > redisMaxBulkSize {
+			return nil, fmt.Errorf("redis: invalid bulk length %q", line[1:])
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(rc.reader, data); err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return data[:size], nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid array length %q", line[1:])
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			if items[i], err = rc.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", line[0])
+}
+
+// escapeRedisPattern escapes the glob characters SCAN MATCH interprets
+func escapeRedisPattern(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
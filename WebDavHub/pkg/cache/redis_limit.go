@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// redisIncrementScript counts a failure and starts the window on the first
+// one, in one step so a crash between the two never leaves a counter that
+// does not expire
+const redisIncrementScript = `local count = redis.call('INCR', KEYS[1])
+if count == 1 then redis.call('PEXPIRE', KEYS[1], ARGV[1]) end
+return count`
+
+// RedisLimitStore keeps login throttling state in Redis, so CineSync replicas
+// using the same server share counters and lockouts. Counters and locks
+// expire on their own. It implements auth.LimitStore.
+type RedisLimitStore struct {
+	redis *RedisCache
+}
+
+// NewRedisLimitStore keeps throttling state on the server of the Redis client
+func NewRedisLimitStore(redis *RedisCache) *RedisLimitStore {
+	return &RedisLimitStore{redis: redis}
+}
+
+func (s *RedisLimitStore) Increment(key string, window time.Duration) (int, error) {
+	ttl := window.Milliseconds()
+	if ttl <= 0 {
+		ttl = 1
+	}
+	reply, err := s.redis.do("EVAL", redisIncrementScript, "1", redisKeyPrefix+"limit:count:"+key, strconv.FormatInt(ttl, 10))
+	if err != nil {
+		return 0, err
+	}
+	count, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis: unexpected EVAL reply %T", reply)
+	}
+	return int(count), nil
+}
+
+func (s *RedisLimitStore) Lock(key string, until time.Time) error {
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		return nil
+	}
+	return s.redis.Set("limit:lock:"+key, []byte(strconv.FormatInt(until.UnixMilli(), 10)), ttl)
+}
+
+func (s *RedisLimitStore) LockedUntil(key string) (time.Time, error) {
+	value, ok, err := s.redis.Get("limit:lock:" + key)
+	if err != nil || !ok {
+		return time.Time{}, err
+	}
+	millis, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("redis: invalid lock value %q", value)
+	}
+	until := time.UnixMilli(millis)
+	if !time.Now().Before(until) {
+		return time.Time{}, nil
+	}
+	return until, nil
+}
+
+func (s *RedisLimitStore) Reset(key string) error {
+	_, err := s.redis.do("DEL", redisKeyPrefix+"limit:count:"+key, redisKeyPrefix+"limit:lock:"+key)
+	return err
+}
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readTestReply(data string) (interface{}, error) {
+	rc := &redisConn{reader: bufio.NewReader(strings.NewReader(data))}
+	return rc.readReply()
+}
+
+func TestReadReplyParsesRESP(t *testing.T) {
+	for data, want := range map[string]interface{}{
+		"+OK\r\n":                              "OK",
+		":42\r\n":                              int64(42),
+		"$5\r\nhello\r\n":                      []byte("hello"),
+		"$-1\r\n":                              nil,
+		"*2\r\n$1\r\n0\r\n*1\r\n$3\r\nkey\r\n": []interface{}{[]byte("0"), []interface{}{[]byte("key")}},
+	} {
+		got, err := readTestReply(data)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("readReply(%q) = %#v, %v, want %#v", data, got, err, want)
+		}
+	}
+}
+
+func TestReadReplyRejectsBadReplies(t *testing.T) {
+	if _, err := readTestReply("-WRONGPASS invalid password\r\n"); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("error reply = %v, want the server's error", err)
+	}
+	for _, data := range []string{"$999999999999\r\n", "$5\r\nhi", "?\r\n"} {
+		if _, err := readTestReply(data); err == nil {
+			t.Errorf("readReply(%q) accepted a malformed reply", data)
+		}
+	}
+}
+
+func TestEscapeRedisPattern(t *testing.T) {
+	if got := escapeRedisPattern(`cinesync:a*b?[c]\`); got != `cinesync:a\*b\?\[c\]\\` {
+		t.Fatalf("escapeRedisPattern = %q", got)
+	}
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -14,13 +16,22 @@ import (
 	"sync"
 	"time"
 
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/env"
 	"cinesync/pkg/logger"
+	"cinesync/pkg/middleware"
+	"cinesync/pkg/naming"
+	"cinesync/pkg/sse"
+	"cinesync/pkg/webdav"
 )
 
 // SSE client management for configuration change notifications
 var (
 	configClients = make(map[chan string]bool)
 	configMutex   sync.RWMutex
+	// Serializes read-check-write cycles on the .env file
+	configWriteMutex sync.Mutex
 	// Callback function to update root directory when DESTINATION_DIR changes
 	updateRootDirCallback func()
 )
@@ -43,12 +54,19 @@ type ConfigValue struct {
 	Locked      bool   `json:"locked,omitempty"`
 	LockedBy    string `json:"lockedBy,omitempty"`
 	Hidden      bool   `json:"hidden,omitempty"`
+	Secret      bool   `json:"secret,omitempty"`
+	// Effective is the value in use after CINESYNC_PROFILE defaults applied,
+	// and Source says where it came from: env, env-file, api, profile or
+	// default
+	Effective string `json:"effective,omitempty"`
+	Source    string `json:"source,omitempty"`
 }
 
 // ConfigResponse represents the response structure for configuration
 type ConfigResponse struct {
-	Config []ConfigValue `json:"config"`
-	Status string        `json:"status"`
+	Config  []ConfigValue `json:"config"`
+	Profile string        `json:"profile,omitempty"`
+	Status  string        `json:"status"`
 }
 
 // UpdateConfigRequest represents the request structure for updating configuration
@@ -56,6 +74,10 @@ type UpdateConfigRequest struct {
 	Updates []ConfigValue `json:"updates"`
 }
 
+// envFilePath returns the path configuration is read from and saved to.
+// Tests point it at a temporary file.
+var envFilePath = getEnvFilePath
+
 // getEnvFilePath returns the path to the .env file
 func getEnvFilePath() string {
 	if _, err := os.Stat("/.dockerenv"); err == nil {
@@ -174,6 +196,7 @@ func getConfigDefinitions() []ConfigValue {
 		// Directory Paths
 		{Key: "SOURCE_DIR", Category: "Directory Paths", Type: "string", Required: true, Description: "Source directory for input files"},
 		{Key: "DESTINATION_DIR", Category: "Directory Paths", Type: "string", Required: true, Description: "Destination directory for output files"},
+		{Key: "DESTINATION_ROUTES", Category: "Directory Paths", Type: "string", Required: false, Description: "Rules routing files to other destination roots by resolution, size, library or path, as \"conditions => destination\" separated by semicolons"},
 		{Key: "USE_SOURCE_STRUCTURE", Category: "Directory Paths", Type: "boolean", Required: false, Description: "Use source structure for organizing files"},
 
 		// Media Folders Configuration
@@ -233,6 +256,7 @@ func getConfigDefinitions() []ConfigValue {
 		{Key: "TMDB_API_KEY", Category: "TMDb/IMDB Configuration", Type: "string", Required: false, Description: "Your TMDb API key for accessing TMDb services"},
 		{Key: "LANGUAGE", Category: "TMDb/IMDB Configuration", Type: "string", Required: false, Description: "Language for TMDb API requests"},
 		{Key: "ANIME_SCAN", Category: "TMDb/IMDB Configuration", Type: "boolean", Required: false, Description: "Enable or disable anime-specific scanning"},
+		{Key: "ANIME_ABSOLUTE_NUMBERING_DIRS", Category: "TMDb/IMDB Configuration", Type: "array", Required: false, Description: "Source directories whose anime uses absolute episode numbering"},
 		{Key: "TMDB_FOLDER_ID", Category: "TMDb/IMDB Configuration", Type: "boolean", Required: false, Description: "Enable or disable TMDb folder ID functionality"},
 		{Key: "IMDB_FOLDER_ID", Category: "TMDb/IMDB Configuration", Type: "boolean", Required: false, Description: "Enable or disable IMDb folder ID functionality"},
 		{Key: "TVDB_FOLDER_ID", Category: "TMDb/IMDB Configuration", Type: "boolean", Required: false, Description: "Enable or disable TVDb folder ID functionality"},
@@ -242,6 +266,7 @@ func getConfigDefinitions() []ConfigValue {
 		// Renaming Structure Configuration
 		{Key: "RENAME_ENABLED", Category: "Renaming Structure Configuration", Type: "boolean", Required: false, Description: "Enable or disable file renaming based on TMDb data"},
 		{Key: "RENAME_TAGS", Category: "Renaming Structure Configuration", Type: "array", Required: false, Description: "Optional tags to include in file renaming"},
+		{Key: "COLLISION_SUFFIX_FORMAT", Category: "Renaming Structure Configuration", Type: "string", Required: false, Description: "Suffix added when a destination name is already taken by a different source. {n} is the version number"},
 		{Key: "MEDIAINFO_PARSER", Category: "Renaming Structure Configuration", Type: "boolean", Required: false, Description: "Determines if MediaInfo will be used to gather metadata information"},
 		{Key: "MEDIAINFO_RADARR_TAGS", Category: "Renaming Structure Configuration", Type: "string", Required: false, Description: "Specifies the tags from MediaInfo to be used for Radarr movie renaming"},
 		{Key: "MEDIAINFO_SONARR_STANDARD_EPISODE_FORMAT", Category: "Renaming Structure Configuration", Type: "string", Required: false, Description: "Sonarr standard episode format for MediaInfo renaming"},
@@ -251,6 +276,8 @@ func getConfigDefinitions() []ConfigValue {
 
 		// System Configuration
 		{Key: "RELATIVE_SYMLINK", Category: "System Configuration", Type: "boolean", Required: false, Description: "Create relative symlinks instead of absolute symlinks"},
+		{Key: "CINESYNC_PATH_MAP", Category: "System Configuration", Type: "string", Required: false, Description: "Rewrite symlink target prefixes for the media server's view (from=to pairs separated by semicolons)"},
+		{Key: "CINESYNC_PATH_MAP_VALIDATE", Category: "System Configuration", Type: "boolean", Required: false, Description: "Only rewrite symlink targets when the mapped path exists"},
 		{Key: "MAX_PROCESSES", Category: "System Configuration", Type: "integer", Required: false, Description: "Set the maximum number of parallel processes for creating symlinks"},
 		{Key: "MAX_CORES", Category: "System Configuration", Type: "integer", Required: false, Description: "Set the maximum number of CPU cores to use (0 for auto-detect, specific number to limit CPU usage)"},
 
@@ -258,11 +285,42 @@ func getConfigDefinitions() []ConfigValue {
 		{Key: "SKIP_EXTRAS_FOLDER", Category: "File Handling Configuration", Type: "boolean", Required: false, Description: "Enable or disable the creation and processing of extras folder files"},
 		{Key: "SHOW_EXTRAS_SIZE_LIMIT", Category: "File Handling Configuration", Type: "integer", Required: false, Description: "Maximum allowed file size for show extras in MB"},
 		{Key: "MOVIE_EXTRAS_SIZE_LIMIT", Category: "File Handling Configuration", Type: "integer", Required: false, Description: "Maximum allowed file size for movie extras in MB (trailers, deleted scenes, etc.)"},
+		{Key: "EXTRAS_HANDLING", Category: "File Handling Configuration", Type: "string", Required: false, Description: "Extras named by media server conventions (-trailer, Featurettes, ...): size, link into the title's extras folders, or skip"},
+		{Key: "LIBRARY_EXTRAS_HANDLING", Category: "File Handling Configuration", Type: "string", Required: false, Description: "Per-library extras handling as source=mode pairs separated by semicolons"},
+		{Key: "SPECIALS_HANDLING", Category: "File Handling Configuration", Type: "string", Required: false, Description: "Season 0 specials: link or skip"},
+		{Key: "LIBRARY_SPECIALS_HANDLING", Category: "File Handling Configuration", Type: "string", Required: false, Description: "Per-library specials handling as source=mode pairs separated by semicolons"},
 		{Key: "ALLOWED_EXTENSIONS", Category: "File Handling Configuration", Type: "array", Required: false, Description: "Allowed file extensions for processing"},
 		{Key: "SKIP_ADULT_PATTERNS", Category: "File Handling Configuration", Type: "boolean", Required: false, Description: "Enable or disable skipping of specific file patterns"},
+		{Key: "CINESYNC_MIN_FILE_SIZE", Category: "File Handling Configuration", Type: "string", Required: false, Description: "Minimum size for media files picked up by the source scanner (e.g. 50MB)"},
+		{Key: "CINESYNC_EXCLUDE_PATTERNS", Category: "File Handling Configuration", Type: "array", Required: false, Description: "Regular expressions for file names the source scanner should skip"},
+		{Key: "CINESYNC_INCLUDE_EXTENSIONS", Category: "File Handling Configuration", Type: "array", Required: false, Description: "Only scan files with these extensions (empty scans all)"},
+		{Key: "CINESYNC_EXCLUDE_EXTENSIONS", Category: "File Handling Configuration", Type: "array", Required: false, Description: "Extensions the source scanner should skip"},
+		{Key: "CINESYNC_SCAN_WORKERS", Category: "File Handling Configuration", Type: "integer", Required: false, Description: "Number of files the source scanner checks concurrently (defaults to the CPU count)"},
+		{Key: "CINESYNC_SCAN_BATCH_SIZE", Category: "File Handling Configuration", Type: "integer", Required: false, Description: "Number of source scanner writes committed per database transaction"},
+		{Key: "CINESYNC_SCAN_DIFF_RETENTION", Category: "File Handling Configuration", Type: "integer", Required: false, Description: "Number of recent source scans that keep the list of files they added, changed or removed (0 disables)"},
+		{Key: "CINESYNC_SCAN_MAX_DEPTH", Category: "File Handling Configuration", Type: "integer", Required: false, Description: "Deepest directory level below a source directory the source scanner descends into (0 is unlimited)"},
+		{Key: "CINESYNC_SCAN_FOLLOW_SYMLINKS", Category: "File Handling Configuration", Type: "boolean", Required: false, Description: "Follow symlinked directories while scanning; directories reached twice are skipped to stop link loops"},
+		{Key: "CINESYNC_HASH_WORKERS", Category: "File Handling Configuration", Type: "integer", Required: false, Description: "Number of files the file hash backfill job hashes concurrently"},
+		{Key: "CINESYNC_SCAN_TRIGGER_DEBOUNCE", Category: "File Handling Configuration", Type: "integer", Required: false, Description: "Seconds a scan requested through /api/scan/trigger waits for further triggers before it starts"},
+		{Key: "FILE_STABILIZATION_SECONDS", Category: "File Handling Configuration", Type: "integer", Required: false, Description: "Seconds a new file's size and modification time must stay unchanged before it is processed, 0 disables the wait"},
+		{Key: "PARTIAL_FILE_PATTERNS", Category: "File Handling Configuration", Type: "array", Required: false, Description: "File name patterns of incomplete downloads that are never processed"},
+		{Key: "SYMLINK_COMPANION_FILES", Category: "File Handling Configuration", Type: "boolean", Required: false, Description: "Symlink subtitles, .nfo and artwork that share a media file's base name alongside it"},
+		{Key: "COMPANION_EXTENSIONS", Category: "File Handling Configuration", Type: "array", Required: false, Description: "Extensions treated as companion files, overrides the layout profile"},
+		{Key: "LAYOUT_PROFILE", Category: "File Handling Configuration", Type: "string", Required: false, Description: "Destination layout preset: plex, jellyfin, emby, kodi or custom"},
+		{Key: "LIBRARY_LAYOUT_PROFILES", Category: "File Handling Configuration", Type: "string", Required: false, Description: "Per-library layout presets as source=profile pairs separated by semicolons"},
+		{Key: "CINESYNC_LIBRARY_LINK_MODES", Category: "File Handling Configuration", Type: "string", Required: false, Description: "Per-library link mode, symlink or strm, as source=mode pairs separated by semicolons"},
+		{Key: "CINESYNC_STRM_PATH_MAP", Category: "File Handling Configuration", Type: "string", Required: false, Description: "Source prefixes replaced by a URL or path in .strm files, as source=target pairs separated by semicolons"},
+		{Key: "CINESYNC_PATH_RULESET", Category: "File Handling Configuration", Type: "string", Required: false, Description: "File system rules for paths rendered from templates: default, posix, windows, smb or exfat"},
+		{Key: "CINESYNC_PATH_REPLACEMENT", Category: "File Handling Configuration", Type: "string", Required: false, Description: "Replacement for characters the path ruleset forbids; empty strips them"},
+		{Key: "CINESYNC_PATH_LOWERCASE", Category: "File Handling Configuration", Type: "boolean", Required: false, Description: "Lowercase paths rendered from templates"},
+		{Key: "CINESYNC_PATH_MAX_LENGTH", Category: "File Handling Configuration", Type: "integer", Required: false, Description: "Longest relative path in bytes rendered from templates, shortening the longest names first; 0 for no limit"},
 		{Key: "FILE_OPERATIONS_AUTO_MODE", Category: "File Handling Configuration", Type: "boolean", Required: false, Description: "Enable auto-processing mode for file operations", Hidden: true},
 
 		// Real-Time Monitoring Configuration
+		{Key: "CINESYNC_WATCH", Category: "Real-Time Monitoring Configuration", Type: "boolean", Required: false, Description: "Watch source directories with inotify and process new files as soon as they finish writing; replaces MediaHub's monitor, so it needs MEDIAHUB_AUTO_START and RTM_AUTO_START off"},
+		{Key: "CINESYNC_WATCH_STABILIZE_SECONDS", Category: "Real-Time Monitoring Configuration", Type: "integer", Required: false, Description: "Seconds a watched file's size must stay unchanged before it is processed, defaults to FILE_STABILIZATION_SECONDS"},
+		{Key: "CINESYNC_WATCH_BATCH_SECONDS", Category: "Real-Time Monitoring Configuration", Type: "integer", Required: false, Description: "Seconds to wait after a watched file settles for more files, which are then processed together"},
+		{Key: "CINESYNC_WATCH_FALLBACK_INTERVAL", Category: "Real-Time Monitoring Configuration", Type: "integer", Required: false, Description: "Seconds between source scans when file events are unavailable, for example when the inotify watch limit is reached"},
 		{Key: "SLEEP_TIME", Category: "Real-Time Monitoring Configuration", Type: "integer", Required: false, Description: "Sleep time (in seconds) for real-time monitoring script"},
 		{Key: "SYMLINK_CLEANUP_INTERVAL", Category: "Real-Time Monitoring Configuration", Type: "integer", Required: false, Description: "Cleanup interval for deleting broken symbolic links"},
 
@@ -275,9 +333,114 @@ func getConfigDefinitions() []ConfigValue {
 		{Key: "CINESYNC_IP", Category: "CineSync Configuration", Type: "string", Required: false, Description: "The IP address to bind the CineSync server"},
 		{Key: "CINESYNC_API_PORT", Category: "CineSync Configuration", Type: "integer", Required: false, Description: "The port on which the API server runs"},
 		{Key: "CINESYNC_UI_PORT", Category: "CineSync Configuration", Type: "integer", Required: false, Description: "The port on which the UI server runs"},
+		{Key: "CINESYNC_WEBDAV_IP", Category: "CineSync Configuration", Type: "string", Required: false, Description: "IP address for a dedicated WebDAV listener (defaults to CINESYNC_IP)"},
+		{Key: "CINESYNC_WEBDAV_PORT", Category: "CineSync Configuration", Type: "integer", Required: false, Description: "Port for a dedicated WebDAV listener; leave empty to serve WebDAV on the API port"},
+		{Key: "CINESYNC_WEBDAV_AUTH_ENABLED", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Require authentication for WebDAV (defaults to CINESYNC_AUTH_ENABLED)"},
+		{Key: "CINESYNC_TLS_CERT", Category: "CineSync Configuration", Type: "string", Required: false, Description: "TLS certificate file used by every listener without its own certificate"},
+		{Key: "CINESYNC_TLS_KEY", Category: "CineSync Configuration", Type: "string", Required: false, Description: "TLS private key file used by every listener without its own key"},
+		{Key: "CINESYNC_ACME_DOMAINS", Category: "CineSync Configuration", Type: "array", Required: false, Description: "Domains to obtain Let's Encrypt certificates for, used by listeners without a certificate file"},
+		{Key: "CINESYNC_ACME_EMAIL", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Contact email for the ACME account"},
+		{Key: "CINESYNC_ACME_CACHE_DIR", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Directory ACME certificates and account keys are kept in"},
+		{Key: "CINESYNC_HTTPS_REDIRECT_PORT", Category: "CineSync Configuration", Type: "integer", Required: false, Description: "Plain HTTP port that redirects to HTTPS when TLS is enabled"},
+		{Key: "CINESYNC_SECURITY_HEADERS", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Send security headers on every response"},
+		{Key: "CINESYNC_HSTS", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Send Strict-Transport-Security on TLS responses"},
+		{Key: "CINESYNC_TRUSTED_PROXIES", Category: "CineSync Configuration", Type: "array", Required: false, Description: "Addresses or CIDR ranges of reverse proxies whose X-Forwarded-Proto is trusted (default loopback)"},
+		{Key: "CINESYNC_HSTS_MAX_AGE", Category: "CineSync Configuration", Type: "integer", Required: false, Description: "HSTS max-age in seconds"},
+		{Key: "CINESYNC_NOSNIFF", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Send X-Content-Type-Options: nosniff"},
+		{Key: "CINESYNC_FRAME_OPTIONS", Category: "CineSync Configuration", Type: "string", Required: false, Description: "X-Frame-Options value; empty disables the header"},
+		{Key: "CINESYNC_CSP", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Content-Security-Policy value; empty disables the header"},
+		{Key: "CINESYNC_CORS_ORIGINS", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Comma separated origins allowed to call the API from another site, * for any; empty allows only the web UI itself"},
+		{Key: "SPOOFING_MAX_LIST_SIZE", Category: "CineSync Configuration", Type: "integer", Required: false, Description: "Most titles a spoofed Radarr/Sonarr list returns to clients that do not page; cut lists send X-Truncated and X-Total-Count"},
+		{Key: "CINESYNC_API_TLS_CERT", Category: "CineSync Configuration", Type: "string", Required: false, Description: "TLS certificate file for the API listener"},
+		{Key: "CINESYNC_API_TLS_KEY", Category: "CineSync Configuration", Type: "string", Required: false, Description: "TLS private key file for the API listener"},
+		{Key: "CINESYNC_WEBDAV_TLS_CERT", Category: "CineSync Configuration", Type: "string", Required: false, Description: "TLS certificate file for the dedicated WebDAV listener"},
+		{Key: "CINESYNC_WEBDAV_TLS_KEY", Category: "CineSync Configuration", Type: "string", Required: false, Description: "TLS private key file for the dedicated WebDAV listener"},
+		{Key: "CINESYNC_PROFILE", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Defaults for settings left unset: prod keeps auth on, logs at INFO and disallows cross-origin requests; dev turns auth off, logs at DEBUG and allows any origin"},
 		{Key: "CINESYNC_AUTH_ENABLED", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Enable or disable CineSync authentication"},
+		{Key: "CINESYNC_AUTH_QUERY_PARAM", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Query parameter API requests may pass their token in; empty accepts only the Authorization header"},
+		{Key: "CINESYNC_AUTH_STREAM_QUERY_PARAM", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Let event streams and image requests pass their token as a query parameter even when CINESYNC_AUTH_QUERY_PARAM is empty"},
+		{Key: "CINESYNC_AUTH_COOKIE", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Also start an HttpOnly cookie session on login; state-changing requests it authenticates must send the CSRF cookie back in X-CSRF-Token"},
+		{Key: "CINESYNC_WEBHOOK_URLS", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Comma separated webhook URLs that job failures are posted to as JSON"},
+		{Key: "CINESYNC_WEBHOOK_TIMEOUT", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Timeout of each webhook request (e.g. 10s)"},
+		{Key: "CINESYNC_WEBHOOK_RETRIES", Category: "CineSync Configuration", Type: "integer", Required: false, Description: "Times a webhook request failing with a network error or 5xx response is retried"},
+		{Key: "CINESYNC_BRAND_NAME", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Application name shown on the login page"},
+		{Key: "CINESYNC_BRAND_LOGO_URL", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Logo shown on the login page instead of the CineSync logo"},
+		{Key: "CINESYNC_LOGIN_REDIRECT", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Page opened after login when none was requested, a path such as /dashboard"},
 		{Key: "CINESYNC_USERNAME", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Username for CineSync authentication"},
 		{Key: "CINESYNC_PASSWORD", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Password for CineSync authentication"},
+		{Key: "CINESYNC_INVITE_TTL", Category: "CineSync Configuration", Type: "string", Required: false, Description: "How long registration invites stay valid (e.g. 48h)"},
+		{Key: "CINESYNC_PASSWORD_MIN_LENGTH", Category: "CineSync Configuration", Type: "integer", Required: false, Description: "Minimum password length for registered users"},
+		{Key: "CINESYNC_PASSWORD_REQUIRE_UPPERCASE", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Require an uppercase letter in user passwords"},
+		{Key: "CINESYNC_PASSWORD_REQUIRE_LOWERCASE", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Require a lowercase letter in user passwords"},
+		{Key: "CINESYNC_PASSWORD_REQUIRE_DIGIT", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Require a digit in user passwords"},
+		{Key: "CINESYNC_PASSWORD_REQUIRE_SYMBOL", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Require a symbol in user passwords"},
+		{Key: "CINESYNC_PASSWORD_BLOCKLIST_ENABLED", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Reject common passwords"},
+		{Key: "CINESYNC_PASSWORD_BLOCKLIST", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Optional file of additional blocked passwords, one per line"},
+		{Key: "CINESYNC_LOGIN_MAX_ATTEMPTS", Category: "CineSync Configuration", Type: "integer", Required: false, Description: "Failed logins from one client or for one account before it is locked out, 0 disables"},
+		{Key: "CINESYNC_LOGIN_WINDOW_MINUTES", Category: "CineSync Configuration", Type: "integer", Required: false, Description: "Minutes over which failed logins are counted"},
+		{Key: "CINESYNC_LOGIN_LOCKOUT_MINUTES", Category: "CineSync Configuration", Type: "integer", Required: false, Description: "Minutes a client or account stays locked out"},
+		{Key: "CINESYNC_USERS_WATCH", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Reload db/users.json when it changes on disk; malformed edits are ignored"},
+		{Key: "CINESYNC_CREDENTIAL_CACHE_SECONDS", Category: "CineSync Configuration", Type: "integer", Required: false, Description: "Seconds a verified password is remembered so WebDAV requests skip rehashing it (0 disables)"},
+		{Key: "CINESYNC_RATE_LIMIT_STORE", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Where login throttling state is kept: memory, or sqlite or redis (CINESYNC_REDIS_URL) to share it between replicas"},
+		{Key: "CINESYNC_RATE_LIMIT_DB", Category: "CineSync Configuration", Type: "string", Required: false, Description: "SQLite file for shared login throttling state, on storage every replica can reach"},
+		{Key: "CINESYNC_AUDIT_STORE", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Where the authentication audit log is kept: sqlite, or memory for the latest events of this process"},
+		{Key: "CINESYNC_AUDIT_DB", Category: "CineSync Configuration", Type: "string", Required: false, Description: "SQLite file of the authentication audit log"},
+		{Key: "CINESYNC_AUDIT_RETENTION_DAYS", Category: "CineSync Configuration", Type: "integer", Required: false, Description: "Days audit events are kept in the database (0 keeps them forever)"},
+		{Key: "CINESYNC_SAML_ENABLED", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Allow signing in through a SAML 2.0 identity provider"},
+		{Key: "CINESYNC_SAML_BASE_URL", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Public URL CineSync is reached at, used for the SAML entity ID and assertion consumer URL"},
+		{Key: "CINESYNC_SAML_IDP_METADATA_URL", Category: "CineSync Configuration", Type: "string", Required: false, Description: "URL of the identity provider's SAML metadata"},
+		{Key: "CINESYNC_SAML_ENTITY_ID", Category: "CineSync Configuration", Type: "string", Required: false, Description: "SAML service provider entity ID, defaults to the metadata URL"},
+		{Key: "CINESYNC_SAML_SP_CERT", Category: "CineSync Configuration", Type: "string", Required: false, Description: "PEM certificate published in the service provider metadata"},
+		{Key: "CINESYNC_SAML_SP_KEY", Category: "CineSync Configuration", Type: "string", Required: false, Description: "PEM RSA key used to sign SAML login requests"},
+		{Key: "CINESYNC_SAML_USERNAME_ATTRIBUTE", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Assertion attribute holding the username, the NameID when empty"},
+		{Key: "CINESYNC_SAML_ROLE_ATTRIBUTE", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Assertion attribute checked for CINESYNC_SAML_ADMIN_VALUES"},
+		{Key: "CINESYNC_SAML_ADMIN_VALUES", Category: "CineSync Configuration", Type: "array", Required: false, Description: "Role attribute values that make a SAML user an admin"},
+		{Key: "CINESYNC_SAML_ALLOW_IDP_INITIATED", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Accept SAML logins started from the identity provider"},
+		{Key: "WEBDAV_PREFIX", Category: "CineSync Configuration", Type: "string", Required: false, Description: "URL prefix the WebDAV share is mounted under"},
+		{Key: "WEBDAV_VIRTUAL_LAYOUT", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Present WebDAV files in a virtual folder layout built from database metadata"},
+		{Key: "WEBDAV_MOVIE_LAYOUT", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Folder template for movies in the virtual WebDAV layout"},
+		{Key: "WEBDAV_SHOW_LAYOUT", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Folder template for shows in the virtual WebDAV layout"},
+		{Key: "WEBDAV_READ_ONLY", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Refuse uploads and every other change through WebDAV"},
+		{Key: "CINESYNC_MIME_TYPES", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Extension to Content-Type overrides for downloads and WebDAV (e.g. .mkv=video/webm)"},
+		{Key: "CINESYNC_MIME_SNIFF", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Identify files of unknown extension by their content instead of serving application/octet-stream"},
+		{Key: "CINESYNC_MIN_FREE_BYTES", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Free space that must remain on the destination after a batch (e.g. 10GB)"},
+		{Key: "CINESYNC_MIN_FREE_PERCENT", Category: "CineSync Configuration", Type: "integer", Required: false, Description: "Free space that must remain on the destination as a percentage of its size"},
+		{Key: "CINESYNC_READINESS_TIMEOUT", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Longest time the API answers 503 while waiting for the initial scan (e.g. 10m)"},
+		{Key: "CINESYNC_TMDB_TIMEOUT", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Deadline for each outbound TMDB request (e.g. 5s)"},
+		{Key: "HTTP_PROXY", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Proxy for outbound HTTP requests to TMDB, Sonarr/Radarr and SAML identity providers"},
+		{Key: "HTTPS_PROXY", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Proxy for outbound HTTPS requests to TMDB, Sonarr/Radarr and SAML identity providers"},
+		{Key: "NO_PROXY", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Comma separated hosts, domains and networks reached without the proxy"},
+		{Key: "CINESYNC_CA_BUNDLE", Category: "CineSync Configuration", Type: "string", Required: false, Description: "PEM file of extra certificate authorities trusted for outbound requests"},
+		{Key: "CINESYNC_TLS_INSECURE_SKIP_VERIFY", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Skip TLS certificate verification of outbound requests (local development only)"},
+		{Key: "CINESYNC_TMDB_CACHE_TTL", Category: "CineSync Configuration", Type: "string", Required: false, Description: "How long fetched TMDB details stay cached (e.g. 24h)"},
+		{Key: "CINESYNC_CACHE_MAX_ENTRIES", Category: "CineSync Configuration", Type: "integer", Required: false, Description: "Values the in-memory cache holds before evicting the least recently used"},
+		{Key: "CINESYNC_REDIS_URL", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Redis URL (redis://[user:password@]host:port/db) for a cache shared between replicas"},
+		{Key: "CINESYNC_BRIDGE_TIMEOUT", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Deadline for one-shot MediaHub commands run by the API, the source watcher and database updates (e.g. 5m)"},
+		{Key: "CINESYNC_IMAGE_PROXY_HOSTS", Category: "CineSync Configuration", Type: "array", Required: false, Description: "Hosts the image proxy may fetch remote posters from (default image.tmdb.org)"},
+		{Key: "CINESYNC_ARTWORK_DOWNLOAD", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Download artwork of processed titles into the local artwork cache"},
+		{Key: "CINESYNC_ARTWORK_TYPES", Category: "CineSync Configuration", Type: "array", Required: false, Description: "Artwork types downloaded during processing: poster, fanart, banner"},
+		{Key: "CINESYNC_ARTWORK_DIR", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Directory MediaCover artwork is cached in, one folder per TMDB id"},
+		{Key: "CINESYNC_METADATA_CONCURRENCY", Category: "CineSync Configuration", Type: "integer", Required: false, Description: "Metadata lookups run at once for processed files, separate from MediaHub's workers"},
+		{Key: "CINESYNC_RECENT_FEED_PAGE_SIZE", Category: "CineSync Configuration", Type: "integer", Required: false, Description: "Titles per page of the dashboard's recently added feed (1-100)"},
+		{Key: "CINESYNC_REIDENTIFY_INTERVAL", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Pause between titles during a library reidentification (e.g. 250ms)"},
+		{Key: "CINESYNC_SSE_IDLE_TIMEOUT", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Close event streams whose client has not pinged for this long (e.g. 2m, 0 disables)"},
+		{Key: "CINESYNC_SSE_MAX_LIFETIME", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Close event streams after they have been open this long (e.g. 12h, 0 disables)"},
+		{Key: "CINESYNC_SEARCH_PROVIDER_FALLBACK", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Search TMDB from /api/search when a title is not in the library"},
+		{Key: "CINESYNC_MAX_BODY_SIZE", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Maximum API request body size (e.g. 1MB)"},
+		{Key: "CINESYNC_MAX_BODY_SIZE_OVERRIDES", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Per-endpoint body size limits as /api/path=size, comma separated"},
+		{Key: "CINESYNC_COMPRESSION", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Compress API responses with gzip or deflate when the client accepts it"},
+		{Key: "CINESYNC_COMPRESSION_MIN_SIZE", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Smallest response body that is compressed (e.g. 1KB)"},
+		{Key: "CINESYNC_SERVER_TIMING", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Add Server-Timing headers breaking API requests down into database, metadata and serialization time (for debugging)"},
+		{Key: "CINESYNC_ACCESS_LOG", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Write an HTTP access log to stdout, stderr or a file path (empty disables)"},
+		{Key: "CINESYNC_ACCESS_LOG_FORMAT", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Access log format: combined, common or json"},
+		{Key: "CINESYNC_ACCESS_LOG_EXCLUDE", Category: "CineSync Configuration", Type: "array", Required: false, Description: "Paths left out of the access log: prefixes, *suffix or path.Match patterns"},
+		{Key: "CINESYNC_FILEOP_RETRY_ATTEMPTS", Category: "CineSync Configuration", Type: "integer", Required: false, Description: "Attempts per item of bulk file operations before a transient I/O failure is recorded as failed"},
+		{Key: "CINESYNC_FILEOP_RETRY_BACKOFF_MS", Category: "CineSync Configuration", Type: "integer", Required: false, Description: "Delay before the first retry of a file operation in milliseconds, doubling after each retry"},
+		{Key: "CINESYNC_IDEMPOTENCY_TTL", Category: "CineSync Configuration", Type: "string", Required: false, Description: "How long the result of a bulk operation is kept for retries with the same Idempotency-Key (e.g. 24h)"},
+		{Key: "CINESYNC_TRASH_RETENTION_DAYS", Category: "CineSync Configuration", Type: "integer", Required: false, Description: "Days pruned symlinks and records stay in the trash and can be restored (0 prunes permanently)"},
+		{Key: "CINESYNC_OPERATION_RESULTS_RETENTION_DAYS", Category: "CineSync Configuration", Type: "integer", Required: false, Description: "Days the per-item results of finished bulk operations are kept (0 keeps them forever)"},
+		{Key: "CINESYNC_TRASH_DIR", Category: "CineSync Configuration", Type: "string", Required: false, Description: "Directory pruned symlinks are moved to (default ../db/.cinesync-trash)"},
+		{Key: "CINESYNC_ACCESS_STATS", Category: "CineSync Configuration", Type: "boolean", Required: false, Description: "Record when files are served via download or WebDAV for access statistics"},
 
 		// Database Configuration
 		{Key: "DB_THROTTLE_RATE", Category: "Database Configuration", Type: "integer", Required: false, Description: "Throttle rate for database operations (requests per second)"},
@@ -285,12 +448,16 @@ func getConfigDefinitions() []ConfigValue {
 		{Key: "DB_RETRY_DELAY", Category: "Database Configuration", Type: "string", Required: false, Description: "Delay (in seconds) between retry attempts for database operations"},
 		{Key: "DB_BATCH_SIZE", Category: "Database Configuration", Type: "integer", Required: false, Description: "Batch size for processing records from the database"},
 		{Key: "DB_MAX_WORKERS", Category: "Database Configuration", Type: "integer", Required: false, Description: "Maximum number of parallel workers for database operations"},
+		{Key: "DB_READ_POOL_SIZE", Category: "Database Configuration", Type: "integer", Required: false, Description: "Number of read-only connections the web UI uses for database queries"},
+		{Key: "DB_BUSY_TIMEOUT", Category: "Database Configuration", Type: "integer", Required: false, Description: "Milliseconds SQLite waits for a locked database before reporting it busy"},
+		{Key: "DB_LOCK_RETRIES", Category: "Database Configuration", Type: "integer", Required: false, Description: "How often the web server retries a database operation that failed because the database is locked"},
+		{Key: "DB_LOCK_RETRY_BACKOFF_MS", Category: "Database Configuration", Type: "integer", Required: false, Description: "Delay before the first retry of a locked database operation, doubling up to 2 seconds"},
 	}
 }
 
 // createEnvFileFromEnvironment creates a .env file from current environment variables
 func createEnvFileFromEnvironment() error {
-	envPath := getEnvFilePath()
+	envPath := envFilePath()
 	logger.Info("Creating .env file from environment variables at: %s", envPath)
 
 	// Get all configuration definitions
@@ -299,7 +466,7 @@ func createEnvFileFromEnvironment() error {
 	// Collect environment variables that match our configuration
 	envVars := make(map[string]string)
 	for _, def := range definitions {
-		if value := os.Getenv(def.Key); value != "" {
+		if value := os.Getenv(def.Key); value != "" && !env.IsProfileDefault(def.Key) {
 			envVars[def.Key] = value
 		}
 	}
@@ -351,7 +518,7 @@ func createEnvFileFromEnvironment() error {
 
 // readEnvFile reads the .env file and returns a map of key-value pairs
 func readEnvFile() (map[string]string, error) {
-	envPath := getEnvFilePath()
+	envPath := envFilePath()
 
 	// Check if .env file exists
 	if _, err := os.Stat(envPath); os.IsNotExist(err) {
@@ -432,7 +599,7 @@ func readFromEnvironment() map[string]string {
 	envVars := make(map[string]string)
 
 	for _, def := range definitions {
-		if value := os.Getenv(def.Key); value != "" {
+		if value := os.Getenv(def.Key); value != "" && !env.IsProfileDefault(def.Key) {
 			envVars[def.Key] = value
 		} else if def.Key == "4K_SEPARATION" {
 			// Check for Kubernetes-compatible alternative _4K_SEPARATION
@@ -448,7 +615,7 @@ func readFromEnvironment() map[string]string {
 
 // writeEnvFile writes the environment variables back to the .env file
 func writeEnvFile(envVars map[string]string) error {
-	envPath := getEnvFilePath()
+	envPath := envFilePath()
 
 	// Read the original file to preserve comments and structure
 	originalFile, err := os.Open(envPath)
@@ -602,6 +769,16 @@ func validateConfigValue(config ConfigValue) error {
 		}
 	}
 
+	if config.Key == "CINESYNC_PATH_RULESET" && config.Value != "" && !naming.IsValidRuleset(config.Value) {
+		return fmt.Errorf("invalid path ruleset for %s: %s", config.Key, config.Value)
+	}
+
+	if config.Key == "WEBDAV_PREFIX" {
+		if err := webdav.ValidatePrefix(config.Value); err != nil {
+			return fmt.Errorf("invalid value for %s: %v", config.Key, err)
+		}
+	}
+
 	// Check required fields
 	if config.Required && config.Value == "" {
 		return fmt.Errorf("required field %s cannot be empty", config.Key)
@@ -610,10 +787,45 @@ func validateConfigValue(config ConfigValue) error {
 	return nil
 }
 
+// configETag returns a strong ETag representing the current configuration version
+func configETag(envVars map[string]string) string {
+	keys := make([]string, 0, len(envVars))
+	for key := range envVars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	for _, key := range keys {
+		hash.Write([]byte(key))
+		hash.Write([]byte{0})
+		hash.Write([]byte(envVars[key]))
+		hash.Write([]byte{0})
+	}
+	return `"` + hex.EncodeToString(hash.Sum(nil))[:16] + `"`
+}
+
+// etagMatches reports whether an If-Match header value matches the given ETag
+func etagMatches(ifMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeValidationError reports a configuration value that failed validation
+func writeValidationError(w http.ResponseWriter, key string, err error) {
+	apierror.WriteErrorDetails(w, http.StatusBadRequest, apierror.CodeConfigValidationFailed, err.Error(), map[string]string{
+		"key": key,
+	})
+}
+
 // HandleGetConfig handles GET requests for configuration
 func HandleGetConfig(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
 		return
 	}
 
@@ -628,9 +840,10 @@ func HandleGetConfig(w http.ResponseWriter, r *http.Request) {
 	for _, def := range definitions {
 		value := envVars[def.Key]
 		locked, lockedBy := isConfigLocked(def.Key)
+		effective, source := effectiveConfigValue(def.Key, envVars)
 		configValues = append(configValues, ConfigValue{
 			Key:         def.Key,
-			Value:       value,
+			Value:       redactConfigValue(def.Key, value),
 			Description: def.Description,
 			Category:    def.Category,
 			Type:        def.Type,
@@ -640,6 +853,9 @@ func HandleGetConfig(w http.ResponseWriter, r *http.Request) {
 			Locked:      locked,
 			LockedBy:    lockedBy,
 			Hidden:      def.Hidden,
+			Secret:      isSensitiveConfigKey(def.Key),
+			Effective:   redactConfigValue(def.Key, effective),
+			Source:      source,
 		})
 	}
 
@@ -652,43 +868,85 @@ func HandleGetConfig(w http.ResponseWriter, r *http.Request) {
 	})
 
 	response := ConfigResponse{
-		Config: configValues,
-		Status: "success",
+		Config:  configValues,
+		Profile: env.Profile(),
+		Status:  "success",
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", configETag(envVars))
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		logger.Error("Failed to encode config response: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to encode response")
+	}
+}
+
+// HandleConfigSchema returns the configuration definitions without values.
+// Secret fields are marked so clients can render them as write-only, and the
+// active profile is listed with the defaults it applies.
+func HandleConfigSchema(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	definitions := getConfigDefinitions()
+	for i := range definitions {
+		definitions[i].Secret = isSensitiveConfigKey(definitions[i].Key)
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"fields":          definitions,
+		"profile":         env.Profile(),
+		"profileDefaults": env.ProfileDefaults(),
+		"status":          "success",
+	})
 }
 
 // HandleUpdateConfig handles POST requests for updating configuration
 func HandleUpdateConfig(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if !apierror.RequireMethod(w, r, http.MethodPost) {
 		return
 	}
 
 	var request UpdateConfigRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	if err := middleware.DecodeStrictJSON(r.Body, &request); err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
 	// Validate all updates first
 	for _, update := range request.Updates {
 		if err := validateConfigValue(update); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeValidationError(w, update.Key, err)
 			return
 		}
 	}
 
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		apierror.WriteError(w, http.StatusPreconditionRequired, apierror.CodeConfigPreconditionNeeded, "If-Match header is required")
+		return
+	}
+
+	configWriteMutex.Lock()
+	defer configWriteMutex.Unlock()
+
 	// Read current environment variables
 	envVars, _ := readEnvFile()
 
+	// Reject the update if the configuration changed since the client read it
+	if !etagMatches(ifMatch, configETag(envVars)) {
+		w.Header().Set("ETag", configETag(envVars))
+		apierror.WriteError(w, http.StatusPreconditionFailed, apierror.CodeConfigConflict, "Configuration was modified by another client, reload and try again")
+		return
+	}
+
 	// Apply updates
 	for _, update := range request.Updates {
+		if isRedactedSecret(update.Key, update.Value) {
+			continue
+		}
 		if update.Value == "" {
 			delete(envVars, update.Key)
 		} else {
@@ -699,7 +957,7 @@ func HandleUpdateConfig(w http.ResponseWriter, r *http.Request) {
 	// Write back to file
 	if err := writeEnvFile(envVars); err != nil {
 		logger.Error("Failed to write .env file: %v", err)
-		http.Error(w, "Failed to save configuration", http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeConfigWriteFailed, "Failed to save configuration")
 		return
 	}
 
@@ -708,72 +966,66 @@ func HandleUpdateConfig(w http.ResponseWriter, r *http.Request) {
 	for key, value := range envVars {
 		os.Setenv(key, value)
 	}
+	env.ApplyProfile()
 
 
 
 	// Check for special configuration updates that require additional actions
-	authSettingsChanged := false
-	serverRestartRequired := false
+	updatedKeys := make([]string, 0, len(request.Updates))
 	for _, update := range request.Updates {
-		if update.Key == "DESTINATION_DIR" && update.Value != "" {
-			logger.Info("DESTINATION_DIR updated, refreshing root directory")
-			if updateRootDirCallback != nil {
-				updateRootDirCallback()
-			}
-		}
-		// Check if authentication settings changed
-		if update.Key == "CINESYNC_AUTH_ENABLED" || update.Key == "CINESYNC_USERNAME" || update.Key == "CINESYNC_PASSWORD" {
-			authSettingsChanged = true
-			logger.Info("Authentication settings changed: %s", update.Key)
-		}
-		// Check if server restart is required
-		if update.Key == "CINESYNC_IP" || update.Key == "CINESYNC_API_PORT" || update.Key == "CINESYNC_UI_PORT" {
-			serverRestartRequired = true
-			logger.Info("Server restart required for setting: %s", update.Key)
+		if !isRedactedSecret(update.Key, update.Value) {
+			updatedKeys = append(updatedKeys, update.Key)
 		}
 	}
+	applyConfigSideEffects(updatedKeys, envVars)
+	auth.RecordConfigChange(r, updatedKeys)
+	recordAPIUpdates(updatedKeys)
 
 	// Notify all connected clients about configuration changes
 	notifyConfigChange()
-
-	// If auth settings changed, notify clients to re-authenticate
-	if authSettingsChanged {
-		notifyAuthSettingsChanged()
-	}
-
-	// If server restart is required, notify clients
-	if serverRestartRequired {
-		notifyServerRestartRequired()
-	}
+	notifyFollowUpEvents(updatedKeys)
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", configETag(envVars))
 	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Configuration updated successfully"})
 }
 
-// HandleUpdateConfigSilent handles configuration updates without triggering SSE notifications
+// HandleUpdateConfigSilent handles configuration updates without triggering SSE notifications.
+// If-Match is optional here; it is only checked when the client sends it.
 func HandleUpdateConfigSilent(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if !apierror.RequireMethod(w, r, http.MethodPost) {
 		return
 	}
 
 	var request UpdateConfigRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+	if err := middleware.DecodeStrictJSON(r.Body, &request); err != nil {
 		logger.Error("Failed to decode config update request: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
 	if len(request.Updates) == 0 {
-		http.Error(w, "No updates provided", http.StatusBadRequest)
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "No updates provided")
 		return
 	}
 
+	configWriteMutex.Lock()
+	defer configWriteMutex.Unlock()
+
 	// Read current environment variables
 	envVars, _ := readEnvFile()
 
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && !etagMatches(ifMatch, configETag(envVars)) {
+		w.Header().Set("ETag", configETag(envVars))
+		apierror.WriteError(w, http.StatusPreconditionFailed, apierror.CodeConfigConflict, "Configuration was modified by another client, reload and try again")
+		return
+	}
+
 	// Apply updates
 	for _, update := range request.Updates {
+		if isRedactedSecret(update.Key, update.Value) {
+			continue
+		}
 		if update.Value == "" {
 			delete(envVars, update.Key)
 		} else {
@@ -783,7 +1035,7 @@ func HandleUpdateConfigSilent(w http.ResponseWriter, r *http.Request) {
 
 	// Write back to file
 	if err := writeEnvFile(envVars); err != nil {
-		http.Error(w, "Failed to save configuration", http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeConfigWriteFailed, "Failed to save configuration")
 		return
 	}
 
@@ -791,6 +1043,16 @@ func HandleUpdateConfigSilent(w http.ResponseWriter, r *http.Request) {
 	for key, value := range envVars {
 		os.Setenv(key, value)
 	}
+	env.ApplyProfile()
+
+	silentKeys := make([]string, 0, len(request.Updates))
+	for _, update := range request.Updates {
+		if !isRedactedSecret(update.Key, update.Value) {
+			silentKeys = append(silentKeys, update.Key)
+		}
+	}
+	auth.RecordConfigChange(r, silentKeys)
+	recordAPIUpdates(silentKeys)
 
 	// Handle special configuration updates that require additional actions (but no SSE notifications)
 	for _, update := range request.Updates {
@@ -802,9 +1064,78 @@ func HandleUpdateConfigSilent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", configETag(envVars))
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
 
+// applyConfigSideEffects runs the actions some settings need after they change
+func applyConfigSideEffects(keys []string, envVars map[string]string) {
+	for _, key := range keys {
+		if key == "DESTINATION_DIR" && envVars[key] != "" {
+			logger.Info("DESTINATION_DIR updated, refreshing root directory")
+			if updateRootDirCallback != nil {
+				updateRootDirCallback()
+			}
+		}
+	}
+}
+
+// notifyFollowUpEvents tells clients to re-authenticate or restart when the
+// changed keys require it
+func notifyFollowUpEvents(keys []string) {
+	authSettingsChanged := false
+	serverRestartRequired := false
+	for _, key := range keys {
+		// Check if authentication settings changed
+		if key == "CINESYNC_AUTH_ENABLED" || key == "CINESYNC_USERNAME" || key == "CINESYNC_PASSWORD" {
+			authSettingsChanged = true
+			logger.Info("Authentication settings changed: %s", key)
+		}
+		// Check if server restart is required
+		if key == "CINESYNC_IP" || key == "CINESYNC_API_PORT" || key == "CINESYNC_UI_PORT" || key == "CINESYNC_WEBDAV_IP" || key == "CINESYNC_WEBDAV_PORT" {
+			serverRestartRequired = true
+			logger.Info("Server restart required for setting: %s", key)
+		}
+	}
+
+	// If auth settings changed, notify clients to re-authenticate
+	if authSettingsChanged {
+		notifyAuthSettingsChanged()
+	}
+
+	// If server restart is required, notify clients
+	if serverRestartRequired {
+		notifyServerRestartRequired()
+	}
+}
+
+// notifyConfigKeysChanged sends a configuration change notification listing the changed keys
+// and their new values. Secret values are always redacted.
+func notifyConfigKeysChanged(keys []string) {
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		values[key] = redactConfigValue(key, os.Getenv(key))
+	}
+
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"type":      "config_changed",
+		"keys":      keys,
+		"values":    values,
+		"timestamp": time.Now().Unix(),
+	})
+	message := fmt.Sprintf("data: %s\n\n", payload)
+
+	for client := range configClients {
+		select {
+		case client <- message:
+		default:
+		}
+	}
+}
+
 // notifyConfigChange sends configuration change notifications to all connected SSE clients
 func notifyConfigChange() {
 	configMutex.RLock()
@@ -820,6 +1151,28 @@ func notifyConfigChange() {
 	}
 }
 
+// NotifySpoofingChanged tells connected SSE clients that spoofing was switched
+// on or off or now presents another service type
+func NotifySpoofingChanged(enabled bool, serviceType string) {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	event, _ := json.Marshal(map[string]interface{}{
+		"type":        "spoofing_changed",
+		"enabled":     enabled,
+		"serviceType": serviceType,
+		"timestamp":   time.Now().Unix(),
+	})
+	message := fmt.Sprintf("data: %s\n\n", event)
+
+	for client := range configClients {
+		select {
+		case client <- message:
+		default:
+		}
+	}
+}
+
 // notifyAuthSettingsChanged sends auth settings change notifications to all connected SSE clients
 func notifyAuthSettingsChanged() {
 	configMutex.RLock()
@@ -850,10 +1203,13 @@ func notifyServerRestartRequired() {
 	}
 }
 
-// HandleConfigEvents handles Server-Sent Events for configuration changes
+// HandleConfigEvents handles Server-Sent Events for configuration changes.
+// Subscribers must authenticate, with a bearer token or the token parameter.
 func HandleConfigEvents(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if !auth.RequireStreamAuthenticated(w, r) {
 		return
 	}
 
@@ -861,7 +1217,7 @@ func HandleConfigEvents(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	middleware.AllowOrigin(w, r)
 	w.Header().Set("Access-Control-Allow-Headers", "Cache-Control")
 
 	// Create a channel for this client
@@ -872,6 +1228,9 @@ func HandleConfigEvents(w http.ResponseWriter, r *http.Request) {
 	configClients[clientChan] = true
 	configMutex.Unlock()
 
+	session := sse.Open(r, "config")
+	defer session.Close()
+
 	// Handle client disconnect
 	defer func() {
 		configMutex.Lock()
@@ -886,6 +1245,8 @@ func HandleConfigEvents(w http.ResponseWriter, r *http.Request) {
 		case message := <-clientChan:
 			fmt.Fprint(w, message)
 			w.(http.Flusher).Flush()
+		case <-session.Done():
+			return
 		case <-r.Context().Done():
 			return
 		}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useEnvFile points the configuration at a temporary .env file holding
+// contents and runs the test in a scratch directory
+func useEnvFile(t *testing.T, contents string) string {
+	t.Helper()
+	root := t.TempDir()
+	work := filepath.Join(root, "work")
+	if err := os.Mkdir(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+
+	path := filepath.Join(root, ".env")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	envFilePath = func() string { return path }
+	t.Cleanup(func() { envFilePath = getEnvFilePath })
+
+	// Saving configuration exports every key, so restore them afterwards
+	for _, line := range strings.Split(contents, "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			t.Setenv(key, value)
+		}
+	}
+	t.Setenv("CINESYNC_AUTH_ENABLED", "false")
+	return path
+}
+
+// currentETag returns the ETag GET /api/config reports
+func currentETag(t *testing.T) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	HandleGetConfig(w, httptest.NewRequest(http.MethodGet, "/api/config", nil))
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("GET /api/config returned no ETag")
+	}
+	return etag
+}
+
+func TestUpdateConfigChecksIfMatch(t *testing.T) {
+	const update = `{"updates":[{"key":"CUSTOM_MOVIE_FOLDER","value":"Films","type":"string"}]}`
+
+	for _, tt := range []struct {
+		name    string
+		ifMatch func(etag string) string
+		status  int
+		saved   bool
+	}{
+		{"matching version", func(etag string) string { return etag }, http.StatusOK, true},
+		{"stale version", func(string) string { return `"0123456789abcdef"` }, http.StatusPreconditionFailed, false},
+		{"no version", func(string) string { return "" }, http.StatusPreconditionRequired, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useEnvFile(t, "CUSTOM_MOVIE_FOLDER=Movies\nCUSTOM_SHOW_FOLDER=Shows\n")
+			etag := currentETag(t)
+
+			r := httptest.NewRequest(http.MethodPost, "/api/config/update", strings.NewReader(update))
+			if ifMatch := tt.ifMatch(etag); ifMatch != "" {
+				r.Header.Set("If-Match", ifMatch)
+			}
+			w := httptest.NewRecorder()
+			HandleUpdateConfig(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved := strings.Contains(string(data), "CUSTOM_MOVIE_FOLDER=Films"); saved != tt.saved {
+				t.Fatalf("update saved = %v, want %v:\n%s", saved, tt.saved, data)
+			}
+			if tt.saved && w.Header().Get("ETag") == etag {
+				t.Fatal("ETag did not change after the update")
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/env"
+)
+
+// Sources a configuration value in effect can come from
+const (
+	// SourceDefault means the key is unset and the built-in default applies
+	SourceDefault = "default"
+	// SourceProfile means the value is a CINESYNC_PROFILE default
+	SourceProfile = "profile"
+	// SourceEnvFile means the value was loaded from .env
+	SourceEnvFile = "env-file"
+	// SourceEnv means the value was in the environment the server started with
+	SourceEnv = "env"
+	// SourceAPI means the value was saved through the configuration API since
+	// the server started
+	SourceAPI = "api"
+)
+
+var (
+	apiUpdatesMu sync.Mutex
+	apiUpdates   = make(map[string]string)
+)
+
+// EffectiveConfigValue is a key of GET /api/config/effective
+type EffectiveConfigValue struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Source   string `json:"source"`
+	Category string `json:"category"`
+	Secret   bool   `json:"secret,omitempty"`
+}
+
+// recordAPIUpdates remembers the values keys were given through the
+// configuration API, so they report SourceAPI while still in effect
+func recordAPIUpdates(keys []string) {
+	apiUpdatesMu.Lock()
+	defer apiUpdatesMu.Unlock()
+	for _, key := range keys {
+		apiUpdates[key] = os.Getenv(key)
+	}
+}
+
+// effectiveConfigValue returns the value of key the server runs with and its
+// source. A value the API saved wins over .env, which it was written to, and a
+// value .env shares with the starting environment is reported as env, since
+// either would have set it.
+func effectiveConfigValue(key string, envVars map[string]string) (string, string) {
+	value, exists := os.LookupEnv(key)
+	if env.IsProfileDefault(key) {
+		return value, SourceProfile
+	}
+	if !exists {
+		return "", SourceDefault
+	}
+
+	apiUpdatesMu.Lock()
+	apiValue, fromAPI := apiUpdates[key]
+	apiUpdatesMu.Unlock()
+	if fromAPI && apiValue == value {
+		return value, SourceAPI
+	}
+	if processValue, ok := env.ProcessEnv(key); ok && processValue == value {
+		return value, SourceEnv
+	}
+	if fileValue, ok := envVars[key]; ok && fileValue == value {
+		return value, SourceEnvFile
+	}
+	return value, SourceEnv
+}
+
+// HandleEffectiveConfig serves GET /api/config/effective, every configuration
+// key with the value in effect and where it came from. Secrets are redacted.
+// Unset keys report SourceDefault without a value, as their default is built
+// into the code reading them.
+func HandleEffectiveConfig(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	envVars, _ := readEnvFile()
+	definitions := getConfigDefinitions()
+	values := make([]EffectiveConfigValue, 0, len(definitions))
+	for _, def := range definitions {
+		value, source := effectiveConfigValue(def.Key, envVars)
+		values = append(values, EffectiveConfigValue{
+			Key:      def.Key,
+			Value:    redactConfigValue(def.Key, value),
+			Source:   source,
+			Category: def.Category,
+			Secret:   isSensitiveConfigKey(def.Key),
+		})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Key < values[j].Key
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"config":  values,
+		"profile": env.Profile(),
+		"status":  "success",
+	})
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/logger"
+	"cinesync/pkg/middleware"
+)
+
+// HandleConfig routes /api/config by method: GET returns the configuration
+// and PATCH merges a partial update into it
+func HandleConfig(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		HandleGetConfig(w, r)
+	case http.MethodPatch:
+		HandlePatchConfig(w, r)
+	default:
+		apierror.MethodNotAllowed(w, http.MethodGet, http.MethodPatch)
+	}
+}
+
+// flattenConfigPatch flattens nested objects into environment style keys so
+// {"PLEX": {"URL": "..."}} merges into PLEX_URL without touching PLEX_TOKEN.
+// Scalars are converted to their string form and null clears a key.
+func flattenConfigPatch(prefix string, value interface{}, out map[string]string) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			fullKey := strings.ToUpper(strings.TrimSpace(key))
+			if prefix != "" {
+				fullKey = prefix + "_" + fullKey
+			}
+			if err := flattenConfigPatch(fullKey, child, out); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		out[prefix] = strings.Join(items, ",")
+	case nil:
+		out[prefix] = ""
+	case string:
+		out[prefix] = v
+	case bool:
+		out[prefix] = fmt.Sprint(v)
+	case float64:
+		out[prefix] = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Errorf("unsupported value for %s", prefix)
+	}
+	return nil
+}
+
+// HandlePatchConfig merges only the provided keys into the current configuration
+func HandlePatchConfig(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodPatch) {
+		return
+	}
+	// /api/config is public, so writes to .env check the caller themselves
+	if !auth.RequireAdmin(w, r) {
+		return
+	}
+
+	var body map[string]interface{}
+	if err := middleware.DecodeStrictJSON(r.Body, &body); err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	patch := make(map[string]string)
+	if err := flattenConfigPatch("", body, patch); err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	if len(patch) == 0 {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "No updates provided")
+		return
+	}
+
+	definitions := make(map[string]ConfigValue)
+	for _, def := range getConfigDefinitions() {
+		definitions[def.Key] = def
+	}
+
+	// Validate every key before touching the file
+	for key, value := range patch {
+		if isRedactedSecret(key, value) {
+			delete(patch, key)
+			continue
+		}
+		def, ok := definitions[key]
+		if !ok {
+			apierror.WriteErrorDetails(w, http.StatusBadRequest, apierror.CodeConfigUnknownKey, fmt.Sprintf("unknown configuration key: %s", key), map[string]string{
+				"key": key,
+			})
+			return
+		}
+		if locked, lockedBy := isConfigLocked(key); locked {
+			apierror.WriteErrorDetails(w, http.StatusForbidden, apierror.CodeConfigLocked, fmt.Sprintf("configuration key %s is locked by %s", key, lockedBy), map[string]string{
+				"key":      key,
+				"lockedBy": lockedBy,
+			})
+			return
+		}
+		def.Value = value
+		if err := validateConfigValue(def); err != nil {
+			writeValidationError(w, key, err)
+			return
+		}
+	}
+
+	configWriteMutex.Lock()
+	defer configWriteMutex.Unlock()
+
+	envVars, _ := readEnvFile()
+
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && !etagMatches(ifMatch, configETag(envVars)) {
+		w.Header().Set("ETag", configETag(envVars))
+		apierror.WriteError(w, http.StatusPreconditionFailed, apierror.CodeConfigConflict, "Configuration was modified by another client, reload and try again")
+		return
+	}
+
+	changedKeys := []string{}
+	for key, value := range patch {
+		if envVars[key] == value {
+			continue
+		}
+		if value == "" {
+			delete(envVars, key)
+		} else {
+			envVars[key] = value
+		}
+		changedKeys = append(changedKeys, key)
+	}
+	sort.Strings(changedKeys)
+
+	if len(changedKeys) > 0 {
+		if err := writeEnvFile(envVars); err != nil {
+			logger.Error("Failed to write .env file: %v", err)
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeConfigWriteFailed, "Failed to save configuration")
+			return
+		}
+
+		for _, key := range changedKeys {
+			if value, ok := envVars[key]; ok {
+				os.Setenv(key, value)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+
+		applyConfigSideEffects(changedKeys, envVars)
+		auth.RecordConfigChange(r, changedKeys)
+		recordAPIUpdates(changedKeys)
+		notifyConfigKeysChanged(changedKeys)
+		notifyFollowUpEvents(changedKeys)
+		logger.Info("Configuration patched: %s", strings.Join(changedKeys, ", "))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", configETag(envVars))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"changed": changedKeys,
+	})
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"cinesync/pkg/auth"
+)
+
+func TestPatchConfigLeavesOtherKeysUntouched(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		body string
+		want map[string]string
+	}{
+		{
+			name: "flat key",
+			body: `{"CUSTOM_MOVIE_FOLDER":"Films"}`,
+			want: map[string]string{"CUSTOM_MOVIE_FOLDER": "Films", "CUSTOM_SHOW_FOLDER": "Shows", "LANGUAGE": "English"},
+		},
+		{
+			name: "nested key",
+			body: `{"CUSTOM":{"SHOW_FOLDER":"Series"}}`,
+			want: map[string]string{"CUSTOM_MOVIE_FOLDER": "Movies", "CUSTOM_SHOW_FOLDER": "Series", "LANGUAGE": "English"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useEnvFile(t, "CUSTOM_MOVIE_FOLDER=Movies\nCUSTOM_SHOW_FOLDER=Shows\nLANGUAGE=English\n")
+
+			w := httptest.NewRecorder()
+			HandlePatchConfig(w, httptest.NewRequest(http.MethodPatch, "/api/config", strings.NewReader(tt.body)))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, value := range tt.want {
+				if !strings.Contains(string(data), key+"="+value+"\n") {
+					t.Errorf("%s is not %q after the patch:\n%s", key, value, data)
+				}
+			}
+		})
+	}
+}
+
+func TestPatchConfigRequiresAdministrator(t *testing.T) {
+	path := useEnvFile(t, "CUSTOM_MOVIE_FOLDER=Movies\n")
+	t.Setenv("CINESYNC_AUTH_ENABLED", "true")
+	t.Setenv("CINESYNC_USERNAME", "admin")
+	if _, err := auth.ReloadUsers(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.CreateUser("viewer", "Correct-Horse-42", auth.RoleUser); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		username string
+		role     string
+		status   int
+	}{
+		{"viewer", auth.RoleUser, http.StatusForbidden},
+		{"admin", auth.RoleAdmin, http.StatusOK},
+	} {
+		token, err := auth.GenerateJWT(tt.username, tt.role)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := httptest.NewRequest(http.MethodPatch, "/api/config", strings.NewReader(`{"CUSTOM_MOVIE_FOLDER":"`+tt.username+`"}`))
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		HandlePatchConfig(w, r)
+		if w.Code != tt.status {
+			t.Errorf("PATCH as %s: status = %d, want %d", tt.username, w.Code, tt.status)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "CUSTOM_MOVIE_FOLDER=viewer") {
+		t.Fatal("a non-administrator patched the configuration")
+	}
+}
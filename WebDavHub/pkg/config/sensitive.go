@@ -0,0 +1,40 @@
+package config
+
+// redactedValue replaces the value of a secret wherever configuration is sent
+// to clients
+const redactedValue = "****"
+
+// sensitiveConfigKeys are the configuration keys that hold secrets. URLs are
+// listed when they commonly carry credentials, such as a Redis password, a
+// webhook token or proxy user info.
+var sensitiveConfigKeys = map[string]bool{
+	"CINESYNC_PASSWORD":     true,
+	"JWT_SECRET":            true,
+	"TMDB_API_KEY":          true,
+	"PLEX_TOKEN":            true,
+	"SPOOFING_API_KEY":      true,
+	"CINESYNC_REDIS_URL":    true,
+	"CINESYNC_WEBHOOK_URLS": true,
+	"HTTP_PROXY":            true,
+	"HTTPS_PROXY":           true,
+}
+
+// isSensitiveConfigKey reports whether key holds a secret
+func isSensitiveConfigKey(key string) bool {
+	return sensitiveConfigKeys[key]
+}
+
+// redactConfigValue returns value, or the redaction mask when key holds a
+// non-empty secret
+func redactConfigValue(key, value string) string {
+	if value != "" && isSensitiveConfigKey(key) {
+		return redactedValue
+	}
+	return value
+}
+
+// isRedactedSecret reports whether an update sends back the redaction mask of
+// a secret. Secrets are write-only, so the mask leaves the stored value as is.
+func isRedactedSecret(key, value string) bool {
+	return value == redactedValue && isSensitiveConfigKey(key)
+}
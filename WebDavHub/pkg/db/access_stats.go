@@ -0,0 +1,236 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"cinesync/pkg/auth"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+)
+
+// FileAccessStat represents how often a file has been served
+type FileAccessStat struct {
+	FilePath        string `json:"filePath"`
+	AccessCount     int64  `json:"accessCount"`
+	BytesServed     int64  `json:"bytesServed"`
+	FirstAccessedAt int64  `json:"firstAccessedAt,omitempty"`
+	LastAccessedAt  int64  `json:"lastAccessedAt,omitempty"`
+}
+
+// accessEvent is a single served file waiting to be flushed
+type accessEvent struct {
+	path  string
+	bytes int64
+	at    int64
+}
+
+const accessFlushInterval = 5 * time.Second
+
+var (
+	accessEvents       chan accessEvent
+	accessRecorderOnce sync.Once
+)
+
+// AccessStatsEnabled reports whether file access collection is turned on
+func AccessStatsEnabled() bool {
+	return env.IsBool("CINESYNC_ACCESS_STATS", true)
+}
+
+// RecordFileAccess queues a served file for the access statistics. It never
+// blocks: events are dropped when the queue is full or collection is disabled.
+func RecordFileAccess(filePath string, bytesServed int64) {
+	if filePath == "" || !AccessStatsEnabled() {
+		return
+	}
+
+	accessRecorderOnce.Do(startAccessRecorder)
+
+	select {
+	case accessEvents <- accessEvent{path: filePath, bytes: bytesServed, at: time.Now().Unix()}:
+	default:
+		logger.Debug("Access stats queue full, dropping event for %s", filePath)
+	}
+}
+
+// startAccessRecorder starts the background goroutine that aggregates access
+// events and writes them in batches
+func startAccessRecorder() {
+	accessEvents = make(chan accessEvent, 1000)
+
+	go func() {
+		ticker := time.NewTicker(accessFlushInterval)
+		defer ticker.Stop()
+
+		pending := make(map[string]*FileAccessStat)
+		for {
+			select {
+			case event := <-accessEvents:
+				stat, ok := pending[event.path]
+				if !ok {
+					stat = &FileAccessStat{FilePath: event.path, FirstAccessedAt: event.at}
+					pending[event.path] = stat
+				}
+				stat.AccessCount++
+				stat.BytesServed += event.bytes
+				stat.LastAccessedAt = event.at
+			case <-ticker.C:
+				if len(pending) == 0 {
+					continue
+				}
+				if err := flushAccessStats(pending); err != nil {
+					logger.Warn("Failed to write access stats: %v", err)
+					continue
+				}
+				pending = make(map[string]*FileAccessStat)
+			}
+		}
+	}()
+}
+
+// flushAccessStats merges aggregated access events into the database
+func flushAccessStats(pending map[string]*FileAccessStat) error {
+	return WithSourceDatabaseTransaction(func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(`INSERT INTO file_access_stats (file_path, access_count, bytes_served, first_accessed_at, last_accessed_at)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT(file_path) DO UPDATE SET
+				access_count = access_count + excluded.access_count,
+				bytes_served = bytes_served + excluded.bytes_served,
+				last_accessed_at = excluded.last_accessed_at`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, stat := range pending {
+			if _, err := stmt.Exec(stat.FilePath, stat.AccessCount, stat.BytesServed, stat.FirstAccessedAt, stat.LastAccessedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetRecentlyAccessedFiles returns the most recently served files
+func GetRecentlyAccessedFiles(limit int) ([]FileAccessStat, error) {
+	var stats []FileAccessStat
+	err := executeReadOperation(func(db *sql.DB) error {
+		rows, err := db.Query(`SELECT file_path, access_count, bytes_served, first_accessed_at, last_accessed_at
+			FROM file_access_stats ORDER BY last_accessed_at DESC LIMIT ?`, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		stats = stats[:0]
+		for rows.Next() {
+			var stat FileAccessStat
+			if err := rows.Scan(&stat.FilePath, &stat.AccessCount, &stat.BytesServed, &stat.FirstAccessedAt, &stat.LastAccessedAt); err != nil {
+				return err
+			}
+			stats = append(stats, stat)
+		}
+		return rows.Err()
+	})
+	return stats, err
+}
+
+// GetNeverAccessedFiles returns processed files that have never been served
+func GetNeverAccessedFiles(limit int) ([]FileAccessStat, error) {
+	accessed := make(map[string]bool)
+	err := executeReadOperation(func(db *sql.DB) error {
+		rows, err := db.Query(`SELECT file_path FROM file_access_stats`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var path string
+			if err := rows.Scan(&path); err != nil {
+				return err
+			}
+			accessed[path] = true
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mediaHubDB, err := GetReadConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mediaHubDB.Query(`SELECT destination_path FROM processed_files
+		WHERE destination_path IS NOT NULL AND destination_path != ''
+		ORDER BY processed_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []FileAccessStat{}
+	for rows.Next() && len(stats) < limit {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			continue
+		}
+		if !accessed[path] {
+			stats = append(stats, FileAccessStat{FilePath: path})
+		}
+	}
+	return stats, rows.Err()
+}
+
+// HandleAccessStats serves file access statistics for the dashboard
+func HandleAccessStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	limit := 50
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 1000 {
+		limit = l
+	}
+
+	var stats []FileAccessStat
+	var err error
+	view := r.URL.Query().Get("view")
+	switch view {
+	case "never":
+		stats, err = GetNeverAccessedFiles(limit)
+	case "", "recent":
+		view = "recent"
+		stats, err = GetRecentlyAccessedFiles(limit)
+	default:
+		http.Error(w, "Invalid view, expected recent or never", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		logger.Error("Failed to get access stats: %v", err)
+		http.Error(w, "Failed to get access stats", http.StatusInternalServerError)
+		return
+	}
+
+	if stats == nil {
+		stats = []FileAccessStat{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled": AccessStatsEnabled(),
+		"view":    view,
+		"files":   stats,
+	})
+}
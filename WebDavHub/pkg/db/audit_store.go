@@ -0,0 +1,188 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"cinesync/pkg/auth"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+	"cinesync/pkg/paging"
+)
+
+const (
+	// defaultAuditRetentionDays is how long audit events are kept unless
+	// CINESYNC_AUDIT_RETENTION_DAYS says otherwise
+	defaultAuditRetentionDays = 90
+	// auditPurgeInterval is how often expired audit events are deleted
+	auditPurgeInterval = time.Hour
+)
+
+// SQLiteAuditStore keeps the audit log in a SQLite database, indexed on the
+// columns it is filtered by. It implements auth.AuditStore.
+type SQLiteAuditStore struct {
+	db *sql.DB
+
+	mutex     sync.Mutex
+	lastPurge time.Time
+}
+
+// NewSQLiteAuditStore opens or creates the audit database at dbPath
+func NewSQLiteAuditStore(dbPath string) (*SQLiteAuditStore, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create audit database directory: %w", err)
+	}
+	db, err := OpenAndConfigureDatabase(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit database: %w", err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS auth_audit (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp INTEGER NOT NULL, -- unix milliseconds
+		event_type TEXT NOT NULL,
+		outcome TEXT NOT NULL,
+		username TEXT NOT NULL DEFAULT '' COLLATE NOCASE,
+		ip TEXT NOT NULL DEFAULT '',
+		method TEXT NOT NULL DEFAULT '',
+		path TEXT NOT NULL DEFAULT '',
+		detail TEXT NOT NULL DEFAULT ''
+	);`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create auth_audit table: %w", err)
+	}
+	for _, index := range []string{
+		`CREATE INDEX IF NOT EXISTS idx_auth_audit_timestamp ON auth_audit(timestamp);`,
+		`CREATE INDEX IF NOT EXISTS idx_auth_audit_type ON auth_audit(event_type, timestamp);`,
+		`CREATE INDEX IF NOT EXISTS idx_auth_audit_username ON auth_audit(username, timestamp);`,
+		`CREATE INDEX IF NOT EXISTS idx_auth_audit_ip ON auth_audit(ip, timestamp);`,
+	} {
+		if _, err := db.Exec(index); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to index auth_audit table: %w", err)
+		}
+	}
+	return &SQLiteAuditStore{db: db}, nil
+}
+
+// auditRetention returns how long events are kept, from
+// CINESYNC_AUDIT_RETENTION_DAYS; 0 keeps them forever
+func auditRetention() time.Duration {
+	days := env.GetInt("CINESYNC_AUDIT_RETENTION_DAYS", defaultAuditRetentionDays)
+	if days <= 0 {
+		return 0
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func (s *SQLiteAuditStore) Append(event auth.AuditEvent) error {
+	_, err := s.db.Exec(`INSERT INTO auth_audit (timestamp, event_type, outcome, username, ip, method, path, detail)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		event.Timestamp.UnixMilli(), event.Type, event.Outcome, event.Username, event.IP, event.Method, event.Path, event.Detail)
+	if err != nil {
+		return err
+	}
+	s.purgeExpired()
+	return nil
+}
+
+// purgeExpired deletes events older than the retention, at most once per
+// auditPurgeInterval
+func (s *SQLiteAuditStore) purgeExpired() {
+	retention := auditRetention()
+	if retention == 0 {
+		return
+	}
+	s.mutex.Lock()
+	if time.Since(s.lastPurge) < auditPurgeInterval {
+		s.mutex.Unlock()
+		return
+	}
+	s.lastPurge = time.Now()
+	s.mutex.Unlock()
+
+	result, err := s.db.Exec(`DELETE FROM auth_audit WHERE timestamp < ?`, time.Now().Add(-retention).UnixMilli())
+	if err != nil {
+		logger.Warn("Failed to purge expired audit events: %v", err)
+		return
+	}
+	if deleted, _ := result.RowsAffected(); deleted > 0 {
+		logger.Info("Purged %d audit events older than %s", deleted, retention)
+	}
+}
+
+func (s *SQLiteAuditStore) Query(filter auth.AuditFilter, window paging.Request) ([]auth.AuditEvent, int, error) {
+	var conditions []string
+	var args []interface{}
+	if len(filter.Types) > 0 {
+		conditions = append(conditions, "event_type IN ("+placeholders(len(filter.Types))+")")
+		for _, t := range filter.Types {
+			args = append(args, t)
+		}
+	}
+	if filter.Username != "" {
+		conditions = append(conditions, "username = ?")
+		args = append(args, filter.Username)
+	}
+	if filter.IP != "" {
+		conditions = append(conditions, "ip = ?")
+		args = append(args, filter.IP)
+	}
+	if filter.Outcome != "" {
+		conditions = append(conditions, "outcome = ?")
+		args = append(args, filter.Outcome)
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, filter.Since.UnixMilli())
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "timestamp < ?")
+		args = append(args, filter.Until.UnixMilli())
+	}
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM auth_audit `+whereClause, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit := window.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := s.db.Query(`SELECT id, timestamp, event_type, outcome, username, ip, method, path, detail
+		FROM auth_audit `+whereClause+`
+		ORDER BY timestamp DESC, id DESC
+		LIMIT ? OFFSET ?`, append(args, limit, window.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := []auth.AuditEvent{}
+	for rows.Next() {
+		var event auth.AuditEvent
+		var timestamp int64
+		if err := rows.Scan(&event.ID, &timestamp, &event.Type, &event.Outcome, &event.Username, &event.IP, &event.Method, &event.Path, &event.Detail); err != nil {
+			return nil, 0, err
+		}
+		event.Timestamp = time.UnixMilli(timestamp).UTC()
+		events = append(events, event)
+	}
+	return events, total, rows.Err()
+}
+
+// Close closes the audit database
+func (s *SQLiteAuditStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"database/sql"
+
+	"cinesync/pkg/env"
+)
+
+// defaultScanBatchSize is the number of writes committed per transaction
+// during a source scan
+const defaultScanBatchSize = 500
+
+// scanBatchSize returns the scan transaction size from CINESYNC_SCAN_BATCH_SIZE
+func scanBatchSize() int {
+	size := env.GetInt("CINESYNC_SCAN_BATCH_SIZE", defaultScanBatchSize)
+	if size < 1 {
+		return defaultScanBatchSize
+	}
+	return size
+}
+
+// sourceBatchWriter accumulates source database writes and commits them in
+// transactions of up to size operations. Commits go through the write queue,
+// so a single goroutine serializes them with every other source database write.
+// Once a commit fails the writer stops: later operations are dropped and Add
+// and Flush keep returning the error, so nothing written after the failed
+// batch, like a checkpoint, can claim it succeeded.
+type sourceBatchWriter struct {
+	size       int
+	operations []func(*sql.Tx) error
+	err        error
+}
+
+// newSourceBatchWriter returns a writer committing size operations at a time
+func newSourceBatchWriter(size int) *sourceBatchWriter {
+	if size < 1 {
+		size = 1
+	}
+	return &sourceBatchWriter{size: size}
+}
+
+// Add queues an operation, committing the batch once it is full
+func (w *sourceBatchWriter) Add(operation func(*sql.Tx) error) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.operations = append(w.operations, operation)
+	if len(w.operations) >= w.size {
+		return w.Flush()
+	}
+	return nil
+}
+
+// Flush commits the queued operations in a single transaction. The batch is
+// discarded whether or not the commit succeeds.
+func (w *sourceBatchWriter) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.operations) == 0 {
+		return nil
+	}
+	operations := w.operations
+	w.operations = nil
+	w.err = BatchUpdateSourceFiles(operations)
+	return w.err
+}
+
+// Err returns the error of the first failed commit, nil while all succeeded
+func (w *sourceBatchWriter) Err() error {
+	return w.err
+}
@@ -0,0 +1,549 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/logger"
+)
+
+// Bulk edit item outcomes
+const (
+	BulkEditStatusUpdated   = "updated"
+	BulkEditStatusUnchanged = "unchanged"
+)
+
+// Bulk edit fields reported in an item's changes
+const (
+	BulkEditFieldLibrary   = "library"
+	BulkEditFieldYear      = "year"
+	BulkEditFieldTags      = "tags"
+	BulkEditFieldMonitored = "monitored"
+)
+
+const (
+	minBulkEditYear = 1870
+	maxBulkEditYear = 2200
+)
+
+var (
+	errBulkEditNoFilter  = errors.New("filter needs filePaths, a query, a type, a library, a pathPrefix or a mediaType")
+	errBulkEditNoUpdates = errors.New("updates must set library, year, addTags, removeTags or monitored")
+)
+
+// BulkEditFilter selects processed_files records. Query and Type work as in
+// GET /api/database/search; every criterion set must match.
+type BulkEditFilter struct {
+	FilePaths  []string `json:"filePaths,omitempty"`
+	Query      string   `json:"query,omitempty"`
+	Type       string   `json:"type,omitempty"`
+	Library    string   `json:"library,omitempty"`
+	PathPrefix string   `json:"pathPrefix,omitempty"`
+	MediaType  string   `json:"mediaType,omitempty"`
+}
+
+// BulkEditUpdates are the field changes applied to every selected record.
+// Library is the base_path a record is filed under and Year overrides the
+// release year MediaHub recognised. Monitored is stored per title, so it
+// changes every file of the titles the selected records belong to.
+type BulkEditUpdates struct {
+	Library    *string  `json:"library,omitempty"`
+	Year       *int     `json:"year,omitempty"`
+	AddTags    []string `json:"addTags,omitempty"`
+	RemoveTags []string `json:"removeTags,omitempty"`
+	Monitored  *bool    `json:"monitored,omitempty"`
+}
+
+// BulkEditRequest is the body of POST /api/database/bulk-edit
+type BulkEditRequest struct {
+	Filter  BulkEditFilter  `json:"filter"`
+	Updates BulkEditUpdates `json:"updates"`
+	DryRun  bool            `json:"dryRun"`
+}
+
+// BulkEditChange is the value of a field before and after the edit
+type BulkEditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// BulkEditItem is the outcome for one selected record. Skipped names updates
+// that do not apply to it, such as monitored on a record without a TMDB id.
+type BulkEditItem struct {
+	SourcePath string                    `json:"sourcePath"`
+	TmdbID     string                    `json:"tmdbId,omitempty"`
+	Status     string                    `json:"status"`
+	Changes    map[string]BulkEditChange `json:"changes,omitempty"`
+	Skipped    map[string]string         `json:"skipped,omitempty"`
+}
+
+// BulkEditResult is the response to a bulk edit or its dry run
+type BulkEditResult struct {
+	DryRun    bool           `json:"dryRun"`
+	Matched   int            `json:"matched"`
+	Updated   int            `json:"updated"`
+	Unchanged int            `json:"unchanged"`
+	Items     []BulkEditItem `json:"items"`
+}
+
+// bulkEditRecord is the editable state of a selected record
+type bulkEditRecord struct {
+	sourcePath   string
+	tmdbID       string
+	mediaType    string
+	seasonNumber string
+	library      string
+	year         string
+	tags         []string
+}
+
+var (
+	recordTagsTableMutex sync.Mutex
+	recordTagsTableReady bool
+)
+
+// ensureRecordTagsTable creates the record_tags table next to processed_files,
+// so tags change in the same transaction as the records they label
+func ensureRecordTagsTable(mediaHubDB *sql.DB) error {
+	recordTagsTableMutex.Lock()
+	defer recordTagsTableMutex.Unlock()
+	if recordTagsTableReady {
+		return nil
+	}
+
+	_, err := mediaHubDB.Exec(`
+		CREATE TABLE IF NOT EXISTS record_tags (
+			file_path TEXT NOT NULL, -- processed_files source path
+			tag TEXT NOT NULL,
+			PRIMARY KEY (file_path, tag)
+		);
+		CREATE INDEX IF NOT EXISTS idx_record_tags_tag ON record_tags(tag);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create record_tags table: %w", err)
+	}
+	recordTagsTableReady = true
+	return nil
+}
+
+// validate normalizes the request and checks that it selects records and
+// changes something
+func (req *BulkEditRequest) validate() error {
+	f := &req.Filter
+	f.Query = strings.TrimSpace(f.Query)
+	f.Library = strings.TrimSpace(f.Library)
+	f.PathPrefix = strings.TrimSpace(f.PathPrefix)
+	f.MediaType = strings.ToLower(strings.TrimSpace(f.MediaType))
+	if f.Type == "all" {
+		f.Type = ""
+	}
+	if f.Type != "" && !pruneTypes[f.Type] {
+		return fmt.Errorf("unknown filter type: %s", f.Type)
+	}
+	if f.MediaType != "" && f.MediaType != MonitoredMediaMovie && f.MediaType != MonitoredMediaTV {
+		return fmt.Errorf("unknown media type: %s", f.MediaType)
+	}
+	// Never let an empty filter edit the whole library
+	if len(f.FilePaths) == 0 && f.Query == "" && f.Type == "" && f.Library == "" && f.PathPrefix == "" && f.MediaType == "" {
+		return errBulkEditNoFilter
+	}
+
+	u := &req.Updates
+	if u.Library != nil {
+		library := strings.TrimSpace(*u.Library)
+		if library == "" {
+			return errors.New("library cannot be empty")
+		}
+		u.Library = &library
+	}
+	if u.Year != nil && (*u.Year < minBulkEditYear || *u.Year > maxBulkEditYear) {
+		return fmt.Errorf("year must be between %d and %d", minBulkEditYear, maxBulkEditYear)
+	}
+	var err error
+	if u.AddTags, err = normalizeTags(u.AddTags); err != nil {
+		return err
+	}
+	if u.RemoveTags, err = normalizeTags(u.RemoveTags); err != nil {
+		return err
+	}
+	for _, tag := range u.AddTags {
+		if containsString(u.RemoveTags, tag) {
+			return fmt.Errorf("tag %q is both added and removed", tag)
+		}
+	}
+	if u.Library == nil && u.Year == nil && len(u.AddTags) == 0 && len(u.RemoveTags) == 0 && u.Monitored == nil {
+		return errBulkEditNoUpdates
+	}
+	return nil
+}
+
+// normalizeTags trims, lowercases and deduplicates tags
+func normalizeTags(tags []string) ([]string, error) {
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			return nil, errors.New("tags cannot be empty")
+		}
+		if !containsString(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// where returns the condition and arguments selecting the records the filter
+// covers
+func (f BulkEditFilter) where() (string, []interface{}) {
+	whereClause, args := buildSearchWhereClause(f.Query, f.Type)
+	conditions := []string{whereClause}
+	if len(f.FilePaths) > 0 {
+		conditions = append(conditions, "file_path IN ("+placeholders(len(f.FilePaths))+")")
+		for _, path := range f.FilePaths {
+			args = append(args, path)
+		}
+	}
+	if f.Library != "" {
+		conditions = append(conditions, "base_path = ?")
+		args = append(args, f.Library)
+	}
+	if f.PathPrefix != "" {
+		prefix := strings.TrimRight(f.PathPrefix, `/\`) + string(filepath.Separator)
+		conditions = append(conditions, "(file_path = ? OR substr(file_path, 1, ?) = ?)")
+		args = append(args, f.PathPrefix, len(prefix), prefix)
+	}
+	switch f.MediaType {
+	case MonitoredMediaMovie:
+		conditions = append(conditions, "LOWER(COALESCE(media_type, '')) = 'movie'")
+	case MonitoredMediaTV:
+		conditions = append(conditions, "LOWER(COALESCE(media_type, '')) IN ('tv', 'show', 'tvshow')")
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// selectBulkEditRecords loads the records a filter selects with their tags
+func selectBulkEditRecords(q queryer, filter BulkEditFilter) ([]*bulkEditRecord, error) {
+	libraryColumn := "''"
+	if checkBasePathColumnExists() {
+		libraryColumn = "COALESCE(base_path, '')"
+	}
+	where, args := filter.where()
+	rows, err := q.Query(`
+		SELECT file_path, COALESCE(tmdb_id, ''), LOWER(COALESCE(media_type, '')), COALESCE(season_number, ''),
+			`+libraryColumn+`, COALESCE(year, '')
+		FROM processed_files `+where+`
+		ORDER BY file_path`, args...)
+	if err != nil {
+		return nil, err
+	}
+	records := []*bulkEditRecord{}
+	byPath := make(map[string]*bulkEditRecord)
+	for rows.Next() {
+		record := &bulkEditRecord{}
+		if err := rows.Scan(&record.sourcePath, &record.tmdbID, &record.mediaType, &record.seasonNumber, &record.library, &record.year); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		records = append(records, record)
+		byPath[record.sourcePath] = record
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return records, nil
+	}
+
+	tagRows, err := q.Query(`SELECT file_path, tag FROM record_tags
+		WHERE file_path IN (SELECT file_path FROM processed_files `+where+`)
+		ORDER BY tag`, args...)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table") {
+			return records, nil
+		}
+		return nil, err
+	}
+	defer tagRows.Close()
+	for tagRows.Next() {
+		var path, tag string
+		if err := tagRows.Scan(&path, &tag); err != nil {
+			return nil, err
+		}
+		if record, ok := byPath[path]; ok {
+			record.tags = append(record.tags, tag)
+		}
+	}
+	return records, tagRows.Err()
+}
+
+// monitoredMediaType maps a record to the media type its title's monitored
+// flag is stored under
+func (r *bulkEditRecord) monitoredMediaType() string {
+	switch r.mediaType {
+	case "movie":
+		return MonitoredMediaMovie
+	case "tv", "show", "tvshow":
+		return MonitoredMediaTV
+	}
+	if r.seasonNumber != "" {
+		return MonitoredMediaTV
+	}
+	return MonitoredMediaMovie
+}
+
+// plan works out the changes updates make to a record
+func (u BulkEditUpdates) plan(record *bulkEditRecord) BulkEditItem {
+	item := BulkEditItem{SourcePath: record.sourcePath, TmdbID: record.tmdbID, Status: BulkEditStatusUnchanged}
+	change := func(field string, from, to interface{}) {
+		if item.Changes == nil {
+			item.Changes = make(map[string]BulkEditChange)
+		}
+		item.Changes[field] = BulkEditChange{From: from, To: to}
+	}
+	skip := func(field, reason string) {
+		if item.Skipped == nil {
+			item.Skipped = make(map[string]string)
+		}
+		item.Skipped[field] = reason
+	}
+
+	if u.Library != nil && *u.Library != record.library {
+		change(BulkEditFieldLibrary, record.library, *u.Library)
+	}
+	if u.Year != nil {
+		if year := strconv.Itoa(*u.Year); year != record.year {
+			change(BulkEditFieldYear, record.year, year)
+		}
+	}
+	if len(u.AddTags) > 0 || len(u.RemoveTags) > 0 {
+		tags := []string{}
+		for _, tag := range record.tags {
+			if !containsString(u.RemoveTags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		for _, tag := range u.AddTags {
+			if !containsString(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		sort.Strings(tags)
+		from := append([]string{}, record.tags...)
+		if strings.Join(from, "\x00") != strings.Join(tags, "\x00") {
+			change(BulkEditFieldTags, from, tags)
+		}
+	}
+	if u.Monitored != nil {
+		tmdbID, _ := strconv.Atoi(record.tmdbID)
+		if tmdbID <= 0 {
+			skip(BulkEditFieldMonitored, "record has no TMDB id")
+		} else if monitored := IsTitleMonitored(tmdbID, record.monitoredMediaType()); monitored != *u.Monitored {
+			change(BulkEditFieldMonitored, monitored, *u.Monitored)
+		}
+	}
+
+	if len(item.Changes) > 0 {
+		item.Status = BulkEditStatusUpdated
+	}
+	return item
+}
+
+// applyBulkEditItem writes the record changes of item in tx
+func applyBulkEditItem(tx *sql.Tx, item BulkEditItem) error {
+	if change, ok := item.Changes[BulkEditFieldLibrary]; ok {
+		if _, err := tx.Exec(`UPDATE processed_files SET base_path = ? WHERE file_path = ?`, change.To, item.SourcePath); err != nil {
+			return fmt.Errorf("failed to update library: %w", err)
+		}
+	}
+	if change, ok := item.Changes[BulkEditFieldYear]; ok {
+		if _, err := tx.Exec(`UPDATE processed_files SET year = ? WHERE file_path = ?`, change.To, item.SourcePath); err != nil {
+			return fmt.Errorf("failed to update year: %w", err)
+		}
+	}
+	if change, ok := item.Changes[BulkEditFieldTags]; ok {
+		if _, err := tx.Exec(`DELETE FROM record_tags WHERE file_path = ?`, item.SourcePath); err != nil {
+			return fmt.Errorf("failed to update tags: %w", err)
+		}
+		for _, tag := range change.To.([]string) {
+			if _, err := tx.Exec(`INSERT INTO record_tags (file_path, tag) VALUES (?, ?)`, item.SourcePath, tag); err != nil {
+				return fmt.Errorf("failed to update tags: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// setTitlesMonitored stores the monitored flag of several titles in one
+// transaction
+func setTitlesMonitored(titles map[titleMonitoringKey]bool) error {
+	if len(titles) == 0 {
+		return nil
+	}
+	loadMonitoredTitles()
+
+	now := time.Now().Unix()
+	err := executeWriteOperationSync(func(db *sql.DB) error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		for key, monitored := range titles {
+			_, err := tx.Exec(`INSERT INTO title_monitoring (tmdb_id, media_type, monitored, updated_at) VALUES (?, ?, ?, ?)
+				ON CONFLICT(tmdb_id, media_type) DO UPDATE SET monitored = excluded.monitored, updated_at = excluded.updated_at`,
+				key.tmdbID, key.mediaType, monitored, now)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save monitored state: %w", err)
+	}
+
+	monitoredTitlesMu.Lock()
+	for key, monitored := range titles {
+		monitoredTitles[key] = monitored
+	}
+	monitoredTitlesMu.Unlock()
+	return nil
+}
+
+// BulkEdit applies req to every record its filter selects. The selection and
+// the record and tag updates happen in one MediaHub database transaction.
+// Monitored flags live in the CineSync database and are written last, inside
+// that transaction, so a failure there rolls the record changes back too. A
+// dry run reports the same items without writing anything.
+func BulkEdit(req *BulkEditRequest) (*BulkEditResult, error) {
+	if req.Updates.Library != nil && !checkBasePathColumnExists() {
+		return nil, errors.New("the MediaHub database has no base_path column to store libraries in")
+	}
+
+	result := &BulkEditResult{DryRun: req.DryRun}
+	plan := func(records []*bulkEditRecord) map[titleMonitoringKey]bool {
+		result.Items = make([]BulkEditItem, 0, len(records))
+		result.Updated, result.Unchanged = 0, 0
+		titles := make(map[titleMonitoringKey]bool)
+		for _, record := range records {
+			item := req.Updates.plan(record)
+			if change, ok := item.Changes[BulkEditFieldMonitored]; ok {
+				tmdbID, _ := strconv.Atoi(record.tmdbID)
+				titles[titleMonitoringKey{tmdbID, record.monitoredMediaType()}] = change.To.(bool)
+			}
+			if item.Status == BulkEditStatusUpdated {
+				result.Updated++
+			} else {
+				result.Unchanged++
+			}
+			result.Items = append(result.Items, item)
+		}
+		result.Matched = len(records)
+		return titles
+	}
+
+	if req.DryRun {
+		mediaHubDB, err := GetReadConnection()
+		if err != nil {
+			return nil, err
+		}
+		records, err := selectBulkEditRecords(mediaHubDB, req.Filter)
+		if err != nil {
+			return nil, err
+		}
+		plan(records)
+		return result, nil
+	}
+
+	if len(req.Updates.AddTags) > 0 || len(req.Updates.RemoveTags) > 0 {
+		mediaHubDB, err := GetDatabaseConnection()
+		if err == nil {
+			err = ensureRecordTagsTable(mediaHubDB)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := WithDatabaseTransaction(func(tx *sql.Tx) error {
+		records, err := selectBulkEditRecords(tx, req.Filter)
+		if err != nil {
+			return err
+		}
+		titles := plan(records)
+		for _, item := range result.Items {
+			if item.Status != BulkEditStatusUpdated {
+				continue
+			}
+			if err := applyBulkEditItem(tx, item); err != nil {
+				return fmt.Errorf("%s: %w", item.SourcePath, err)
+			}
+		}
+		return setTitlesMonitored(titles)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Updated > 0 {
+		NotifyLibraryChanged()
+	}
+	return result, nil
+}
+
+// HandleDatabaseBulkEdit serves POST /api/database/bulk-edit for
+// administrators. The body selects records with a filter and lists the field
+// updates for them; the response has an item per selected record. With
+// dryRun nothing is written.
+func HandleDatabaseBulkEdit(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if !auth.RequireAdmin(w, r) {
+		return
+	}
+
+	var req BulkEditRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if err := req.validate(); err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	result, err := BulkEdit(&req)
+	if err != nil {
+		logger.Error("Failed to bulk edit records: %v", err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to edit records")
+		return
+	}
+	if !req.DryRun {
+		logger.Info("Bulk edit updated %d of %d matched records", result.Updated, result.Matched)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
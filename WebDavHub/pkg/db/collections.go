@@ -0,0 +1,155 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MovieCollection is a TMDB collection, such as a franchise, a movie belongs to
+type MovieCollection struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// CollectionMember is a library movie that belongs to a collection
+type CollectionMember struct {
+	TmdbID int    `json:"tmdbId"`
+	Title  string `json:"title"`
+	Year   string `json:"year"`
+}
+
+// Collection is a collection with the library movies that belong to it
+type Collection struct {
+	MovieCollection
+	Members []CollectionMember `json:"members"`
+}
+
+// SetMovieCollection stores the collection a movie belongs to, as reported by
+// its TMDB details. A nil collection records that the movie belongs to none.
+func SetMovieCollection(tmdbID int, collection *MovieCollection) error {
+	if tmdbID <= 0 {
+		return fmt.Errorf("invalid tmdb id: %d", tmdbID)
+	}
+
+	err := executeWriteOperationSync(func(db *sql.DB) error {
+		if collection == nil || collection.ID <= 0 {
+			_, err := db.Exec(`DELETE FROM movie_collections WHERE tmdb_id = ?`, tmdbID)
+			return err
+		}
+		_, err := db.Exec(`INSERT INTO movie_collections (tmdb_id, collection_id, collection_name, updated_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT(tmdb_id) DO UPDATE SET collection_id = excluded.collection_id,
+				collection_name = excluded.collection_name, updated_at = excluded.updated_at`,
+			tmdbID, collection.ID, collection.Name, time.Now().Unix())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save movie collection: %w", err)
+	}
+	return nil
+}
+
+// GetMovieCollections returns the stored collection of every movie, keyed by
+// the movie's TMDB id
+func GetMovieCollections() (map[int]MovieCollection, error) {
+	collections := make(map[int]MovieCollection)
+	err := executeReadOperation(func(db *sql.DB) error {
+		rows, err := db.Query(`SELECT tmdb_id, collection_id, collection_name FROM movie_collections`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var tmdbID int
+			var collection MovieCollection
+			if err := rows.Scan(&tmdbID, &collection.ID, &collection.Name); err != nil {
+				return err
+			}
+			collections[tmdbID] = collection
+		}
+		return rows.Err()
+	})
+	return collections, err
+}
+
+// GetLibraryMovies returns the processed movies with a TMDB id, keyed by id
+func GetLibraryMovies() (map[int]CollectionMember, error) {
+	mediaHubDB, err := GetReadConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mediaHubDB.Query(`
+		SELECT tmdb_id, MAX(COALESCE(proper_name, '')), MAX(COALESCE(year, ''))
+		FROM processed_files
+		WHERE LOWER(media_type) = 'movie' AND tmdb_id IS NOT NULL AND tmdb_id != ''
+		GROUP BY tmdb_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movies := make(map[int]CollectionMember)
+	for rows.Next() {
+		var id string
+		var member CollectionMember
+		if err := rows.Scan(&id, &member.Title, &member.Year); err != nil {
+			return nil, err
+		}
+		tmdbID, err := strconv.Atoi(strings.TrimSpace(id))
+		if err != nil || tmdbID <= 0 {
+			continue
+		}
+		member.TmdbID = tmdbID
+		movies[tmdbID] = member
+	}
+	return movies, rows.Err()
+}
+
+// ListCollections returns the collections that library movies belong to,
+// ordered by name, with their members ordered by year. A collectionID above
+// zero returns only that collection.
+func ListCollections(collectionID int) ([]Collection, error) {
+	memberships, err := GetMovieCollections()
+	if err != nil {
+		return nil, err
+	}
+	movies, err := GetLibraryMovies()
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int]*Collection)
+	for tmdbID, membership := range memberships {
+		member, inLibrary := movies[tmdbID]
+		if !inLibrary || (collectionID > 0 && membership.ID != collectionID) {
+			continue
+		}
+		collection, ok := byID[membership.ID]
+		if !ok {
+			collection = &Collection{MovieCollection: membership}
+			byID[membership.ID] = collection
+		}
+		collection.Members = append(collection.Members, member)
+	}
+
+	collections := make([]Collection, 0, len(byID))
+	for _, collection := range byID {
+		sort.Slice(collection.Members, func(i, j int) bool {
+			a, b := collection.Members[i], collection.Members[j]
+			if a.Year != b.Year {
+				return a.Year < b.Year
+			}
+			return a.Title < b.Title
+		})
+		collections = append(collections, *collection)
+	}
+	sort.Slice(collections, func(i, j int) bool {
+		return strings.ToLower(collections[i].Name) < strings.ToLower(collections[j].Name)
+	})
+	return collections, nil
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"encoding/csv"
 	"encoding/json"
@@ -17,6 +18,7 @@ import (
 
 	"cinesync/pkg/env"
 	"cinesync/pkg/logger"
+	"cinesync/pkg/paging"
 	_ "modernc.org/sqlite"
 )
 
@@ -108,9 +110,8 @@ type DatabaseStats struct {
 
 // DatabaseSearchResponse represents the response for database search
 type DatabaseSearchResponse struct {
-	Records []DatabaseRecord `json:"records"`
-	Stats   DatabaseStats    `json:"stats"`
-	Total   int              `json:"total"`
+	paging.PagedResponse[DatabaseRecord]
+	Stats DatabaseStats `json:"stats"`
 }
 
 // FolderCache represents a cache for folder structure similar to Jellyfin's approach
@@ -613,23 +614,10 @@ func HandleDatabaseSearch(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("query")
 	filterType := r.URL.Query().Get("type")
 
-	limit := 50
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 1000 {
-			limit = parsedLimit
-		}
-	}
-
-	// Parse offset with bounds checking
-	offset := 0
-	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
-		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
-			offset = parsedOffset
-		}
-	}
+	page := paging.Parse(r, 50, 1000)
 
 	// Get database connection once
-	mediaHubDB, err := GetDatabaseConnection()
+	mediaHubDB, err := GetReadConnection()
 	if err != nil {
 		logger.Error("Failed to get database connection: %v", err)
 		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
@@ -677,7 +665,7 @@ func HandleDatabaseSearch(w http.ResponseWriter, r *http.Request) {
 		FROM processed_files ` + whereClause + `
 		ORDER BY rowid DESC LIMIT ? OFFSET ?`
 
-	dataArgs := append(whereArgs, limit, offset)
+	dataArgs := append(whereArgs, page.Limit, page.Offset)
 
 	// Execute data query
 	rows, err := mediaHubDB.Query(dataQuery, dataArgs...)
@@ -726,9 +714,8 @@ func HandleDatabaseSearch(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := DatabaseSearchResponse{
-		Records: records,
-		Stats:   stats,
-		Total:   totalCount,
+		PagedResponse: paging.NewResponse(records, totalCount, page),
+		Stats:         stats,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -787,7 +774,7 @@ type FolderInfo struct {
 }
 
 func GetFoldersFromDatabasePaginated(basePath string, page, limit int) ([]FolderInfo, int, error) {
-	mediaHubDB, err := GetDatabaseConnection()
+	mediaHubDB, err := GetReadConnection()
 	if err != nil {
 		return nil, 0, err
 	}
@@ -1050,7 +1037,7 @@ func GetFoldersFromDatabaseCached(basePath string, page, limit int) ([]FolderInf
 
 // SearchFoldersFromDatabase searches folders in the database using proper_name and folder_name
 func SearchFoldersFromDatabase(basePath string, searchQuery string, page, limit int) ([]FolderInfo, int, error) {
-	mediaHubDB, err := GetDatabaseConnection()
+	mediaHubDB, err := GetReadConnection()
 	if err != nil {
 		return nil, 0, err
 	}
@@ -1215,7 +1202,7 @@ func searchCategoryFolders(db *sql.DB, category string, searchQuery string, page
 
 // SearchFoldersFromDatabaseWithLetter searches folders in the database with letter filtering
 func SearchFoldersFromDatabaseWithLetter(basePath string, letterFilter string, page, limit int) ([]FolderInfo, int, error) {
-	mediaHubDB, err := GetDatabaseConnection()
+	mediaHubDB, err := GetReadConnection()
 	if err != nil {
 		return nil, 0, err
 	}
@@ -1405,7 +1392,7 @@ func extractCategoryFromPath(destPath, destDir string) string {
 
 // GetFileInfoFromDatabase retrieves comprehensive file information from the processed_files table
 func GetFileInfoFromDatabase(filePath string) (FileInfo, bool) {
-	mediaHubDB, err := GetDatabaseConnection()
+	mediaHubDB, err := GetReadConnection()
 	if err != nil {
 		logger.Debug("Failed to get database connection for file info lookup: %v", err)
 		return FileInfo{}, false
@@ -1473,8 +1460,8 @@ func HandleDatabaseStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Use the database connection pool
-	mediaHubDB, err := GetDatabaseConnection()
+	// Use the read-only pool
+	mediaHubDB, err := GetReadConnection()
 	if err != nil {
 		logger.Error("Failed to get database connection: %v", err)
 		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
@@ -1499,12 +1486,17 @@ func HandleDatabaseExport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if r.URL.Query().Get("format") == "ndjson" {
+		exportNDJSON(w, r)
+		return
+	}
+
 	// Get query parameters
 	query := r.URL.Query().Get("query")
 	filterType := r.URL.Query().Get("type")
 
-	// Use the database connection pool
-	mediaHubDB, err := GetDatabaseConnection()
+	// Use the read-only pool
+	mediaHubDB, err := GetReadConnection()
 	if err != nil {
 		logger.Error("Failed to get database connection: %v", err)
 		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
@@ -1732,10 +1724,16 @@ func runDatabaseUpdate() error {
 	pythonCmd := getPythonCommand()
 
 	// Execute the MediaHub update database command
-	cmd := exec.Command(pythonCmd, "main.py", "--update-database")
+	ctx, cancel := context.WithTimeout(context.Background(), env.BridgeTimeout())
+	defer cancel()
+	cmd := exec.CommandContext(ctx, pythonCmd, "main.py", "--update-database")
 	cmd.Dir = "../MediaHub"
 
 	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		logger.Error("Database update did not finish within %s, output: %s", env.BridgeTimeout(), string(output))
+		return fmt.Errorf("database update timed out after %s", env.BridgeTimeout())
+	}
 	if err != nil {
 		logger.Error("Database update failed: %v, output: %s", err, string(output))
 		return fmt.Errorf("database update failed: %v", err)
@@ -1751,4 +1749,85 @@ func runDatabaseUpdate() error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+// LibraryTitle is a movie or show that is already in the library
+type LibraryTitle struct {
+	Title     string `json:"title"`
+	Year      string `json:"year,omitempty"`
+	TmdbID    string `json:"tmdbId,omitempty"`
+	MediaType string `json:"mediaType,omitempty"`
+	FileCount int    `json:"fileCount"`
+}
+
+// SearchLibraryTitles returns library titles whose name contains query,
+// optionally restricted to a media type ("movie" or "tv")
+func SearchLibraryTitles(query, mediaType string, limit int) ([]LibraryTitle, error) {
+	mediaHubDB, err := GetReadConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	sqlQuery := `
+		SELECT
+			proper_name,
+			COALESCE(year, '') as year,
+			COALESCE(tmdb_id, '') as tmdb_id,
+			COALESCE(media_type, '') as media_type,
+			COUNT(*) as file_count
+		FROM processed_files
+		WHERE proper_name IS NOT NULL
+		AND proper_name != ''
+		AND destination_path IS NOT NULL
+		AND destination_path != ''
+		AND proper_name LIKE ?`
+	args := []interface{}{"%" + query + "%"}
+	if mediaType != "" {
+		sqlQuery += ` AND LOWER(media_type) = ?`
+		args = append(args, strings.ToLower(mediaType))
+	}
+	sqlQuery += `
+		GROUP BY proper_name, year, tmdb_id
+		ORDER BY proper_name, year
+		LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := mediaHubDB.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	titles := []LibraryTitle{}
+	for rows.Next() {
+		var title LibraryTitle
+		if err := rows.Scan(&title.Title, &title.Year, &title.TmdbID, &title.MediaType, &title.FileCount); err != nil {
+			continue
+		}
+		title.MediaType = strings.ToLower(title.MediaType)
+		titles = append(titles, title)
+	}
+	return titles, rows.Err()
+}
+
+// GetTitleMediaType returns "movie" or "tv" for the library title with a TMDB
+// id, from how MediaHub recorded its files, or "" when no file has the id
+func GetTitleMediaType(tmdbID string) string {
+	mediaHubDB, err := GetReadConnection()
+	if err != nil {
+		return ""
+	}
+
+	var mediaType, seasonNumber string
+	err = mediaHubDB.QueryRow(`SELECT LOWER(COALESCE(media_type, '')), COALESCE(season_number, '')
+		FROM processed_files WHERE tmdb_id = ? LIMIT 1`, tmdbID).Scan(&mediaType, &seasonNumber)
+	if err != nil {
+		return ""
+	}
+	switch {
+	case mediaType == "movie":
+		return "movie"
+	case mediaType == "tv" || mediaType == "show" || mediaType == "tvshow" || seasonNumber != "":
+		return "tv"
+	}
+	return "movie"
+}
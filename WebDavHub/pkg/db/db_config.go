@@ -2,6 +2,8 @@ package db
 
 import (
 	"database/sql"
+	"net/url"
+	"strconv"
 	"time"
 
 	"cinesync/pkg/env"
@@ -35,7 +37,7 @@ func GetDefaultDatabaseConfig() DatabaseConfig {
 		MaxOpenConns:    maxConnections, // Allow multiple readers
 		MaxIdleConns:    1, // Keep minimal idle connections
 		ConnMaxLifetime: time.Hour * 2, // Shorter lifetime to prevent stale connections
-		BusyTimeout:     "60000", // 60 seconds - reasonable for concurrent access
+		BusyTimeout:     busyTimeout(), // How long SQLite waits for a lock before SQLITE_BUSY
 		JournalMode:     "WAL", // WAL mode is essential for concurrent readers
 		Synchronous:     "NORMAL", // Balance between safety and performance
 		CacheSize:       "-16000", // 16MB cache (negative means KB)
@@ -44,25 +46,40 @@ func GetDefaultDatabaseConfig() DatabaseConfig {
 	}
 }
 
+// busyTimeout returns the busy_timeout pragma in milliseconds from
+// DB_BUSY_TIMEOUT, 60 seconds by default
+func busyTimeout() string {
+	timeout := env.GetInt("DB_BUSY_TIMEOUT", 60000)
+	if timeout < 0 {
+		timeout = 60000
+	}
+	return strconv.Itoa(timeout)
+}
 
+// BuildConnectionString returns the DSN for dbPath. The modernc driver only
+// applies settings passed as _pragma parameters, and runs them on every new
+// connection in the pool, so busy_timeout and friends hold for all of them.
 func (config DatabaseConfig) BuildConnectionString(dbPath string) string {
-	return dbPath + "?" +
-		"_busy_timeout=" + config.BusyTimeout +
-		"&_journal_mode=" + config.JournalMode +
-		"&_synchronous=" + config.Synchronous +
-		"&_cache_size=" + config.CacheSize +
-		"&_foreign_keys=" + config.ForeignKeys +
-		"&_temp_store=" + config.TempStore +
-		"&_wal_autocheckpoint=1000" + // Checkpoint every 1000 pages to prevent WAL bloat
-		"&_mmap_size=134217728" + // 128MB memory mapping - reasonable size
-		"&_locking_mode=NORMAL" + // Normal locking allows concurrent access
-		"&_read_uncommitted=false" + // Ensure consistency
-		"&_query_only=false" +
-		"&_secure_delete=false" + // Improve write performance
-		"&_auto_vacuum=INCREMENTAL" + // Prevent database file bloat
-		"&_optimize" // Optimize on connection
-}
+	pragmas := []string{
+		"auto_vacuum(INCREMENTAL)", // Prevent database file bloat, set before any table exists
+		"busy_timeout(" + config.BusyTimeout + ")",
+		"journal_mode(" + config.JournalMode + ")",
+		"synchronous(" + config.Synchronous + ")",
+		"cache_size(" + config.CacheSize + ")",
+		"foreign_keys(" + config.ForeignKeys + ")",
+		"temp_store(" + config.TempStore + ")",
+		"wal_autocheckpoint(1000)", // Checkpoint every 1000 pages to prevent WAL bloat
+		"mmap_size(134217728)",     // 128MB memory mapping - reasonable size
+		"locking_mode(NORMAL)",     // Normal locking allows concurrent access
+		"secure_delete(false)",     // Improve write performance
+	}
 
+	params := url.Values{}
+	for _, pragma := range pragmas {
+		params.Add("_pragma", pragma)
+	}
+	return dbPath + "?" + params.Encode()
+}
 
 func (config DatabaseConfig) ConfigureDatabase(db *sql.DB) {
 	db.SetMaxOpenConns(config.MaxOpenConns)
@@ -0,0 +1,30 @@
+package db
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpenAndConfigureDatabaseAppliesPragmas(t *testing.T) {
+	db, err := OpenAndConfigureDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for pragma, want := range map[string]string{
+		"auto_vacuum":  "2", // INCREMENTAL
+		"locking_mode": "normal",
+		"journal_mode": "wal",
+		"foreign_keys": "1",
+	} {
+		var got string
+		if err := db.QueryRow("PRAGMA " + pragma).Scan(&got); err != nil {
+			t.Fatalf("PRAGMA %s: %v", pragma, err)
+		}
+		if !strings.EqualFold(got, want) {
+			t.Errorf("%s = %s, want %s", pragma, got, want)
+		}
+	}
+}
@@ -2,10 +2,7 @@ package db
 
 import (
 	"database/sql"
-	"fmt"
-	"math/rand"
 	"path/filepath"
-	"strings"
 	"sync"
 	"time"
 
@@ -86,7 +83,7 @@ func CloseDatabasePool() {
 // WithDatabaseTransaction executes a function within a database transaction with write queue serialization
 func WithDatabaseTransaction(fn func(*sql.Tx) error) error {
 	return executeMainDBWriteOperationSync(func() error {
-		return executeWithRetry(func() error {
+		return RetryOnLock(func() error {
 			db, err := GetDatabaseConnection()
 			if err != nil {
 				return err
@@ -193,36 +190,9 @@ func executeMainDBWriteOperationSync(operation func() error) error {
 	return <-resultChan
 }
 
-// executeMainDBDeletionOperation executes deletion operations with optimized retry logic
+// executeMainDBDeletionOperation executes deletion operations through the write queue
 func executeMainDBDeletionOperation(operation func() error) error {
 	return executeMainDBWriteOperationSync(func() error {
-		return executeWithRetryOptimized(operation, 15, 25*time.Millisecond)
+		return RetryOnLock(operation)
 	})
 }
-
-// executeWithRetryOptimized provides retry logic for specific operation types
-func executeWithRetryOptimized(operation func() error, maxRetries int, baseDelay time.Duration) error {
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		err := operation()
-		if err == nil {
-			return nil
-		}
-
-		// Check if it's a SQLite busy error
-		if strings.Contains(err.Error(), "database is locked") || strings.Contains(err.Error(), "SQLITE_BUSY") {
-			if attempt < maxRetries-1 {
-				delay := baseDelay * time.Duration(1<<uint(attempt/3))
-				if delay > 1*time.Second {
-					delay = 1*time.Second
-				}
-				jitter := time.Duration(rand.Int63n(int64(delay / 4)))
-				time.Sleep(delay + jitter)
-				logger.Debug("Database busy, retrying deletion operation (attempt %d/%d)", attempt+1, maxRetries)
-				continue
-			}
-		}
-		return err
-	}
-
-	return fmt.Errorf("deletion operation failed after %d retries", maxRetries)
-}
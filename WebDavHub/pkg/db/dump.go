@@ -0,0 +1,416 @@
+package db
+
+import (
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/logger"
+)
+
+const (
+	// dumpFormat identifies CineSync database dumps
+	dumpFormat = "cinesync-dump"
+	// dumpSchemaVersion is bumped whenever the dump layout changes in a way
+	// older importers cannot read
+	dumpSchemaVersion = 1
+	// dumpTable is the table a dump holds
+	dumpTable = "processed_files"
+	// dumpFlushRows is how many rows are written between flushes
+	dumpFlushRows = 500
+	// maxDumpLineSize caps a single NDJSON line, keeping imports in bounded memory
+	maxDumpLineSize = 16 << 20
+	// dumpIOTimeout bounds each chunk of a dump sent or received. The
+	// server's timeouts cover a whole request, which a large dump outlasts.
+	dumpIOTimeout = 60 * time.Second
+)
+
+// DumpHeader is the first line of an NDJSON dump. Every following line is one
+// row as an object keyed by column name.
+type DumpHeader struct {
+	Format        string    `json:"format"`
+	SchemaVersion int       `json:"schemaVersion"`
+	Table         string    `json:"table"`
+	Columns       []string  `json:"columns"`
+	ExportedAt    time.Time `json:"exportedAt"`
+}
+
+// Database import modes
+const (
+	// ImportModeMerge inserts the dumped rows, replacing rows with the same source path
+	ImportModeMerge = "merge"
+	// ImportModeReplace empties the table before inserting the dumped rows
+	ImportModeReplace = "replace"
+)
+
+// errDumpIncompatible is returned for dumps this version cannot import
+var errDumpIncompatible = errors.New("incompatible dump")
+
+// queryer is implemented by *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// tableColumns returns the columns of a table in declaration order
+func tableColumns(q queryer, table string) ([]string, error) {
+	rows, err := q.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
+
+// rawColumnList selects columns as stored. The unary plus drops the declared
+// column type, so the driver does not turn TIMESTAMP text into time.Time and
+// values survive a JSON round trip unchanged.
+func rawColumnList(columns []string) string {
+	selected := make([]string, len(columns))
+	for i, column := range columns {
+		selected[i] = `+"` + column + `"`
+	}
+	return strings.Join(selected, ", ")
+}
+
+// quotedColumnList quotes column names for an INSERT
+func quotedColumnList(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = `"` + column + `"`
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// scanRecord reads the current row into a map keyed by column name
+func scanRecord(rows *sql.Rows, columns []string) (map[string]interface{}, error) {
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := rows.Scan(pointers...); err != nil {
+		return nil, err
+	}
+
+	record := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		if data, ok := values[i].([]byte); ok {
+			values[i] = string(data)
+		}
+		record[column] = values[i]
+	}
+	return record, nil
+}
+
+// recordValue converts a value decoded with json.Decoder.UseNumber back to
+// the integer or real it was exported from
+func recordValue(value interface{}) interface{} {
+	number, ok := value.(json.Number)
+	if !ok {
+		return value
+	}
+	if i, err := number.Int64(); err == nil {
+		return i
+	}
+	if f, err := number.Float64(); err == nil {
+		return f
+	}
+	return string(number)
+}
+
+// ExportDump writes every processed_files row to w as NDJSON, reading them
+// through a cursor so memory use does not grow with the library
+func ExportDump(w io.Writer, flush func()) (int, error) {
+	mediaHubDB, err := GetReadConnection()
+	if err != nil {
+		return 0, err
+	}
+	columns, err := tableColumns(mediaHubDB, dumpTable)
+	if err != nil {
+		return 0, err
+	}
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("table %s does not exist", dumpTable)
+	}
+
+	encoder := json.NewEncoder(w)
+	err = encoder.Encode(DumpHeader{
+		Format:        dumpFormat,
+		SchemaVersion: dumpSchemaVersion,
+		Table:         dumpTable,
+		Columns:       columns,
+		ExportedAt:    time.Now().UTC(),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := mediaHubDB.Query(`SELECT ` + rawColumnList(columns) + ` FROM ` + dumpTable + ` ORDER BY rowid`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	exported := 0
+	for rows.Next() {
+		record, err := scanRecord(rows, columns)
+		if err != nil {
+			return exported, err
+		}
+		if err := encoder.Encode(record); err != nil {
+			return exported, err
+		}
+		exported++
+		if flush != nil && exported%dumpFlushRows == 0 {
+			flush()
+		}
+	}
+	return exported, rows.Err()
+}
+
+// readDumpHeader reads and checks the first line of a dump
+func readDumpHeader(scanner *bufio.Scanner, tableColumnSet map[string]bool) (*DumpHeader, error) {
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: the dump is empty", errDumpIncompatible)
+	}
+
+	var header DumpHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil || header.Format != dumpFormat {
+		return nil, fmt.Errorf("%w: missing %s header line", errDumpIncompatible, dumpFormat)
+	}
+	if header.SchemaVersion != dumpSchemaVersion {
+		return nil, fmt.Errorf("%w: schema version %d, this server reads version %d", errDumpIncompatible, header.SchemaVersion, dumpSchemaVersion)
+	}
+	if header.Table != dumpTable || len(header.Columns) == 0 {
+		return nil, fmt.Errorf("%w: the dump does not hold %s rows", errDumpIncompatible, dumpTable)
+	}
+
+	var unknown []string
+	for _, column := range header.Columns {
+		if !tableColumnSet[column] {
+			unknown = append(unknown, column)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("%w: unknown columns %s", errDumpIncompatible, strings.Join(unknown, ", "))
+	}
+	return &header, nil
+}
+
+// ImportDump reads an NDJSON dump from r and writes its rows in a single
+// transaction, so a dump that fails halfway changes nothing. Rows are
+// inserted as they are read.
+func ImportDump(r io.Reader, mode string) (int, error) {
+	mediaHubDB, err := GetDatabaseConnection()
+	if err != nil {
+		return 0, err
+	}
+	columns, err := tableColumns(mediaHubDB, dumpTable)
+	if err != nil {
+		return 0, err
+	}
+	tableColumnSet := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		tableColumnSet[column] = true
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxDumpLineSize)
+	header, err := readDumpHeader(scanner, tableColumnSet)
+	if err != nil {
+		return 0, err
+	}
+
+	imported := 0
+	err = executeMainDBWriteOperationSync(func() error {
+		tx, err := mediaHubDB.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if mode == ImportModeReplace {
+			if _, err := tx.Exec(`DELETE FROM ` + dumpTable); err != nil {
+				return err
+			}
+		}
+
+		statement, err := tx.Prepare(`INSERT OR REPLACE INTO ` + dumpTable + ` (` + quotedColumnList(header.Columns) +
+			`) VALUES (` + placeholders(len(header.Columns)) + `)`)
+		if err != nil {
+			return err
+		}
+		defer statement.Close()
+
+		args := make([]interface{}, len(header.Columns))
+		for line := 2; scanner.Scan(); line++ {
+			if len(strings.TrimSpace(scanner.Text())) == 0 {
+				continue
+			}
+			var record map[string]interface{}
+			decoder := json.NewDecoder(strings.NewReader(scanner.Text()))
+			decoder.UseNumber()
+			if err := decoder.Decode(&record); err != nil {
+				return fmt.Errorf("%w: line %d is not a JSON object: %v", errDumpIncompatible, line, err)
+			}
+			for i, column := range header.Columns {
+				args[i] = recordValue(record[column])
+			}
+			if _, err := statement.Exec(args...); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			imported++
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return 0, err
+	}
+	return imported, nil
+}
+
+// exportNDJSON serves GET /api/database/export?format=ndjson, gzipped when
+// gzip=true
+func exportNDJSON(w http.ResponseWriter, r *http.Request) {
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	compress, _ := strconv.ParseBool(r.URL.Query().Get("gzip"))
+	fileName := "database_export.ndjson"
+	if compress {
+		fileName += ".gz"
+		w.Header().Set("Content-Type", "application/gzip")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename="+fileName)
+	w.Header().Set("X-CineSync-Dump-Version", strconv.Itoa(dumpSchemaVersion))
+
+	var out io.Writer = w
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(w)
+		out = gz
+	}
+	controller := http.NewResponseController(w)
+	controller.SetWriteDeadline(time.Now().Add(dumpIOTimeout))
+	flush := func() {
+		if gz != nil {
+			gz.Flush()
+		}
+		controller.Flush()
+		controller.SetWriteDeadline(time.Now().Add(dumpIOTimeout))
+	}
+
+	exported, err := ExportDump(out, flush)
+	if gz != nil {
+		if closeErr := gz.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		// The status is already sent, so the truncated body is all a client sees
+		logger.Error("Database export failed after %d rows: %v", exported, err)
+		return
+	}
+	logger.Info("Exported %d database rows", exported)
+}
+
+// deadlineReader extends the read deadline of the connection before each read,
+// so an upload is only cut off when it stalls
+type deadlineReader struct {
+	r          io.Reader
+	controller *http.ResponseController
+}
+
+func (d *deadlineReader) Read(p []byte) (int, error) {
+	d.controller.SetReadDeadline(time.Now().Add(dumpIOTimeout))
+	return d.r.Read(p)
+}
+
+// HandleDatabaseImport serves POST /api/database/import. The body is a dump
+// from GET /api/database/export?format=ndjson, gzipped or not. mode=merge (the
+// default) keeps rows missing from the dump, mode=replace removes them. Only
+// administrators may import, since a dump rewrites the paths other jobs act on.
+func HandleDatabaseImport(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if !auth.RequireAdmin(w, r) {
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = ImportModeMerge
+	}
+	if mode != ImportModeMerge && mode != ImportModeReplace {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "mode must be merge or replace")
+		return
+	}
+
+	// Accept gzipped dumps whether or not the client labels them
+	body := bufio.NewReader(&deadlineReader{r: r.Body, controller: http.NewResponseController(w)})
+	var reader io.Reader = body
+	if magic, err := body.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid gzip body")
+			return
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	imported, err := ImportDump(reader, mode)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.Is(err, errDumpIncompatible):
+			apierror.WriteError(w, http.StatusUnprocessableEntity, apierror.CodeDatabaseDumpIncompatible, err.Error())
+		case errors.As(err, &maxBytesErr):
+			apierror.WriteError(w, http.StatusRequestEntityTooLarge, apierror.CodeInvalidRequest, "Dump is too large")
+		default:
+			logger.Error("Database import failed: %v", err)
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to import database: "+err.Error())
+		}
+		return
+	}
+
+	logger.Info("Imported %d database rows (%s)", imported, mode)
+	InvalidateFolderCache()
+	NotifyLibraryChanged()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"imported":      imported,
+		"mode":          mode,
+		"schemaVersion": dumpSchemaVersion,
+	})
+}
@@ -1,7 +1,11 @@
 package db
 
 import (
+	"cinesync/pkg/apierror"
 	"cinesync/pkg/logger"
+	"cinesync/pkg/middleware"
+	"cinesync/pkg/paging"
+	"cinesync/pkg/sse"
 	"crypto/sha256"
 	"database/sql"
 	"encoding/json"
@@ -22,6 +26,25 @@ func SetFileOperationNotifier(notifier FileOperationNotifier) {
 	fileOperationNotifier = notifier
 }
 
+// LibraryChangeNotifier is called whenever processed files are added, changed
+// or removed, so caches built from them can be dropped
+type LibraryChangeNotifier func()
+
+var libraryChangeNotifier LibraryChangeNotifier
+
+func SetLibraryChangeNotifier(notifier LibraryChangeNotifier) {
+	libraryChangeNotifier = notifier
+}
+
+// NotifyLibraryChanged tells the library change notifier and the dashboard
+// subscribers that processed files changed
+func NotifyLibraryChanged() {
+	if libraryChangeNotifier != nil {
+		libraryChangeNotifier()
+	}
+	NotifyDashboardStatsChanged()
+}
+
 // FileOperation represents a file operation record
 type FileOperation struct {
 	ID              string `json:"id"`
@@ -38,6 +61,13 @@ type FileOperation struct {
 	Operation       string `json:"operation"`
 }
 
+// FileOperationsResponse is the body of GET /api/file-operations
+type FileOperationsResponse struct {
+	paging.PagedResponse[FileOperation]
+	StatusCounts map[string]int `json:"statusCounts"`
+	Status       string         `json:"status"`
+}
+
 // HandleFileOperations handles both GET (retrieve operations) and POST (track operations)
 func HandleFileOperations(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
@@ -52,36 +82,21 @@ func HandleFileOperations(w http.ResponseWriter, r *http.Request) {
 			handleBulkDeleteSkippedFiles(w, r)
 		}
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.MethodNotAllowed(w, http.MethodGet, http.MethodPost, http.MethodDelete)
 	}
 }
 
 // handleGetFileOperations returns file operations data from MediaHub database
 func handleGetFileOperations(w http.ResponseWriter, r *http.Request) {
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
+	page := paging.Parse(r, 50, 0)
 	statusFilter := r.URL.Query().Get("status")
 	searchQuery := strings.TrimSpace(r.URL.Query().Get("search"))
 
-	limit := 50
-	if limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
-			limit = parsedLimit
-		}
-	}
-
-	offset := 0
-	if offsetStr != "" {
-		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
-			offset = parsedOffset
-		}
-	}
-
 	// Get file operations from MediaHub database
-	operations, total, err := getFileOperationsFromMediaHub(limit, offset, statusFilter, searchQuery)
+	operations, total, err := getFileOperationsFromMediaHub(page.Limit, page.Offset, statusFilter, searchQuery)
 	if err != nil {
 		logger.Warn("Failed to get file operations: %v", err)
-		http.Error(w, "Failed to retrieve file operations", http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeFileOpDatabase, "Failed to retrieve file operations")
 		return
 	}
 
@@ -92,14 +107,19 @@ func handleGetFileOperations(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"operations":   operations,
-		"total":        total,
-		"statusCounts": statusCounts,
-		"status":       "success",
+	json.NewEncoder(w).Encode(FileOperationsResponse{
+		PagedResponse: paging.NewResponse(operations, total, page),
+		StatusCounts:  statusCounts,
+		Status:        "success",
 	})
 }
 
+// GetRecentFileOperations returns the most recent file operations from the MediaHub database
+func GetRecentFileOperations(limit int) ([]FileOperation, error) {
+	operations, _, err := getFileOperationsFromMediaHub(limit, 0, "", "")
+	return operations, err
+}
+
 // handleTrackFileOperation tracks file additions and deletions
 func handleTrackFileOperation(w http.ResponseWriter, r *http.Request) {
 	var req struct {
@@ -116,20 +136,21 @@ func handleTrackFileOperation(w http.ResponseWriter, r *http.Request) {
 		OldDestinationPath string `json:"oldDestinationPath"`
 		OldProperName      string `json:"oldProperName"`
 		OldYear            string `json:"oldYear"`
+		BatchID            string `json:"batchId"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid JSON")
 		return
 	}
 
 	if req.SourcePath == "" {
-		http.Error(w, "sourcePath is required", http.StatusBadRequest)
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeFileOpInvalid, "sourcePath is required")
 		return
 	}
 
 	if req.Operation == "" {
-		http.Error(w, "operation is required (add or delete)", http.StatusBadRequest)
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeFileOpInvalid, "operation is required (add or delete)")
 		return
 	}
 
@@ -195,7 +216,7 @@ func handleTrackFileOperation(w http.ResponseWriter, r *http.Request) {
 		err = TrackFileDeletion(req.SourcePath, req.DestinationPath, req.TmdbID, req.SeasonNumber, req.Reason)
 		if err != nil {
 			logger.Warn("Failed to track file deletion: %v", err)
-			http.Error(w, "Failed to track deletion", http.StatusInternalServerError)
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeFileOpDatabase, "Failed to track deletion")
 			return
 		}
 		message = "Deletion tracked successfully"
@@ -209,7 +230,7 @@ func handleTrackFileOperation(w http.ResponseWriter, r *http.Request) {
 		err = TrackFileFailure(req.SourcePath, req.TmdbID, req.SeasonNumber, req.Reason, req.Error)
 		if err != nil {
 			logger.Warn("Failed to track file failure: %v", err)
-			http.Error(w, "Failed to track failure", http.StatusInternalServerError)
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeFileOpDatabase, "Failed to track failure")
 			return
 		}
 		message = "Failure tracked successfully"
@@ -233,12 +254,14 @@ func handleTrackFileOperation(w http.ResponseWriter, r *http.Request) {
 		}
 
 	default:
-		http.Error(w, "Invalid operation. Must be 'add', 'delete', 'failed', or 'force_recreate'", http.StatusBadRequest)
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeFileOpUnknownOperation, "Invalid operation. Must be 'add', 'delete', 'failed', or 'force_recreate'")
 		return
 	}
 
-	// Notify dashboard about stats change
-	NotifyDashboardStatsChanged()
+	recordBatchResult(req.BatchID, req.Operation, req.SourcePath, req.DestinationPath, req.Reason)
+
+	// Notify library caches and the dashboard about the change
+	NotifyLibraryChanged()
 
 	// Notify file operations subscribers about the change
 	NotifyFileOperationChanged()
@@ -256,7 +279,7 @@ func handleBulkDeleteSkippedFiles(w http.ResponseWriter, r *http.Request) {
 	mediaHubDB, err := GetDatabaseConnection()
 	if err != nil {
 		logger.Warn("Failed to get database connection: %v", err)
-		http.Error(w, "Database connection failed", http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeFileOpDatabase, "Database connection failed")
 		return
 	}
 
@@ -272,7 +295,7 @@ func handleBulkDeleteSkippedFiles(w http.ResponseWriter, r *http.Request) {
 	err = mediaHubDB.QueryRow(countQuery).Scan(&skippedCount)
 	if err != nil {
 		logger.Warn("Failed to count skipped files: %v", err)
-		http.Error(w, "Failed to count skipped files", http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeFileOpDatabase, "Failed to count skipped files")
 		return
 	}
 
@@ -298,7 +321,7 @@ func handleBulkDeleteSkippedFiles(w http.ResponseWriter, r *http.Request) {
 	rows, err := mediaHubDB.Query(selectQuery)
 	if err != nil {
 		logger.Warn("Failed to query skipped files for cleanup: %v", err)
-		http.Error(w, "Failed to query skipped files", http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeFileOpDatabase, "Failed to query skipped files")
 		return
 	}
 	defer rows.Close()
@@ -329,7 +352,7 @@ func handleBulkDeleteSkippedFiles(w http.ResponseWriter, r *http.Request) {
 	result, err := mediaHubDB.Exec(deleteQuery)
 	if err != nil {
 		logger.Warn("Failed to delete skipped files: %v", err)
-		http.Error(w, "Failed to delete skipped files", http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeFileOpDatabase, "Failed to delete skipped files")
 		return
 	}
 
@@ -348,8 +371,8 @@ func handleBulkDeleteSkippedFiles(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Notify dashboard about stats change
-	NotifyDashboardStatsChanged()
+	// Notify library caches and the dashboard about the change
+	NotifyLibraryChanged()
 
 	// Notify file operations subscribers about the change
 	NotifyFileOperationChanged()
@@ -367,24 +390,30 @@ type BulkActionRequest struct {
 	FilePaths []string `json:"filePaths"`
 }
 
-// handleBulkDeleteSelectedFiles handles permanent deletion of selected files from the deleted tab
+// handleBulkDeleteSelectedFiles handles permanent deletion of selected files from the deleted tab.
+// A retry carrying the same Idempotency-Key gets the first run's response.
 func handleBulkDeleteSelectedFiles(w http.ResponseWriter, r *http.Request) {
 	var req BulkActionRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
 		return
 	}
 
 	if len(req.FilePaths) == 0 {
-		http.Error(w, "No file paths provided", http.StatusBadRequest)
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeFileOpInvalid, "No file paths provided")
 		return
 	}
+	idempotent, ok := BeginIdempotentRequest(w, r, "bulk_delete", req)
+	if !ok {
+		return
+	}
+	defer idempotent.Release()
 
 	// Get database connection
 	mediaHubDB, err := GetDatabaseConnection()
 	if err != nil {
 		logger.Warn("Failed to get database connection: %v", err)
-		http.Error(w, "Database connection failed", http.StatusInternalServerError)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeFileOpDatabase, "Database connection failed")
 		return
 	}
 	logger.Info("Database connection successful for permanent deletion")
@@ -392,11 +421,26 @@ func handleBulkDeleteSelectedFiles(w http.ResponseWriter, r *http.Request) {
 	deletedFromTrash := 0
 	var errors []string
 
+	batchID, err := StartOperationBatch("bulk_delete")
+	if err != nil {
+		logger.Warn("Failed to start operation batch: %v", err)
+	}
+	recordResult := func(source, destination, status, reason string, retries int) {
+		if batchID == "" {
+			return
+		}
+		if err := RecordOperationResultWithRetries(batchID, source, destination, status, reason, retries); err != nil {
+			logger.Warn("Failed to record result for batch %s: %v", batchID, err)
+		}
+	}
+
+	retryPolicy := GetRetryPolicy()
 	for _, fileIDStr := range req.FilePaths {
 		fileID, err := strconv.Atoi(fileIDStr)
 		if err != nil {
 			logger.Warn("Invalid file ID: %s", fileIDStr)
 			errors = append(errors, fmt.Sprintf("Invalid file ID: %s", fileIDStr))
+			recordResult(fileIDStr, "", OperationResultFailed, "Invalid file ID", 0)
 			continue
 		}
 		logger.Info("Processing permanent deletion for ID: %d", fileID)
@@ -411,38 +455,36 @@ func handleBulkDeleteSelectedFiles(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			logger.Warn("File ID %d not found in deleted files: %v", fileID, err)
 			errors = append(errors, fmt.Sprintf("File ID %d not found in deleted files", fileID))
+			recordResult(fileIDStr, "", OperationResultSkipped, "Not found in deleted files", 0)
 			continue
 		}
 		logger.Info("Found deleted file record: ID=%d, destination=%s, trash_file=%s", fileID, destinationPath, trashFileName)
 
-		// Permanently delete the trash file
-		if trashFileName != "" {
-			trashFilePath := filepath.Join("..", "db", "trash", trashFileName)
-			absTrashPath, _ := filepath.Abs(trashFilePath)
-			if _, err := os.Stat(absTrashPath); err == nil {
-				if err := os.Remove(absTrashPath); err != nil {
-					logger.Warn("Failed to remove trash file %s: %v", trashFileName, err)
-					errors = append(errors, fmt.Sprintf("Failed to remove trash file %s: %v", trashFileName, err))
-				} else {
-					logger.Info("Successfully deleted trash file: %s", absTrashPath)
-					deletedFromTrash++
-				}
-			} else {
-				logger.Warn("Trash file not found at %s: %v", absTrashPath, err)
+		trashRemoved := false
+		retries, err := retryPolicy.Run(r.Context(), func() error {
+			removed, err := deleteTrashEntry(mediaHubDB, fileID, trashFileName)
+			trashRemoved = trashRemoved || removed
+			return err
+		})
+		if err != nil {
+			err = ClassifyPermissionError(err)
+			if retries > 0 {
+				err = fmt.Errorf("%w (after %d retries)", err, retries)
 			}
-		} else {
-			logger.Warn("No trash file name provided for ID %d", fileID)
+			logger.Warn("Failed to permanently delete file ID %d: %v", fileID, err)
+			errors = append(errors, fmt.Sprintf("Failed to permanently delete file ID %d: %v", fileID, err))
+			recordResult(fileIDStr, destinationPath, OperationResultFailed, err.Error(), retries)
+			continue
 		}
+		if trashRemoved {
+			deletedFromTrash++
+		}
+		recordResult(fileIDStr, destinationPath, OperationResultSuccess, "", retries)
+	}
 
-		// Remove from MediaHub's deleted_files table
-		logger.Info("Removing database record for ID: %d", fileID)
-		result, err := mediaHubDB.Exec("DELETE FROM deleted_files WHERE id = ?", fileID)
-		if err != nil {
-			logger.Warn("Failed to remove file ID %d from deleted_files: %v", fileID, err)
-			errors = append(errors, fmt.Sprintf("Failed to remove file ID %d from deleted_files: %v", fileID, err))
-		} else {
-			rowsAffected, _ := result.RowsAffected()
-			logger.Info("Successfully removed database record for ID %d, rows affected: %d", fileID, rowsAffected)
+	if batchID != "" {
+		if err := CompleteOperationBatch(batchID, "completed"); err != nil {
+			logger.Warn("Failed to complete batch %s: %v", batchID, err)
 		}
 	}
 
@@ -458,24 +500,55 @@ func handleBulkDeleteSelectedFiles(w http.ResponseWriter, r *http.Request) {
 		}()
 	}
 
-	// Notify dashboard about stats change
-	NotifyDashboardStatsChanged()
+	// Notify library caches and the dashboard about the change
+	NotifyLibraryChanged()
 
 	// Notify file operations subscribers about the change
 	NotifyFileOperationChanged()
 
-	w.Header().Set("Content-Type", "application/json")
 	response := map[string]interface{}{
 		"success": true,
 		"message": fmt.Sprintf("Permanently deleted %d file(s) from trash", deletedFromTrash),
 		"deletedCount": deletedFromTrash,
 	}
+	if batchID != "" {
+		response["batchId"] = batchID
+	}
 	
 	if len(errors) > 0 {
 		response["errors"] = errors
 	}
 	
-	json.NewEncoder(w).Encode(response)
+	idempotent.WriteJSON(w, http.StatusOK, batchID, response)
+}
+
+// deleteTrashEntry permanently removes a trashed file and then its deleted_files
+// record, reporting whether a trash file was removed. The record is kept while
+// the trash file cannot be removed, so the deletion can be retried.
+func deleteTrashEntry(mediaHubDB *sql.DB, fileID int, trashFileName string) (bool, error) {
+	removed := false
+	if trashFileName != "" {
+		absTrashPath, _ := filepath.Abs(filepath.Join("..", "db", "trash", trashFileName))
+		if err := os.Remove(absTrashPath); err != nil && !os.IsNotExist(err) {
+			return false, fmt.Errorf("failed to remove trash file %s: %w", trashFileName, err)
+		} else if err != nil {
+			logger.Warn("Trash file not found at %s", absTrashPath)
+		} else {
+			logger.Info("Successfully deleted trash file: %s", absTrashPath)
+			removed = true
+		}
+	} else {
+		logger.Warn("No trash file name provided for ID %d", fileID)
+	}
+
+	logger.Info("Removing database record for ID: %d", fileID)
+	result, err := mediaHubDB.Exec("DELETE FROM deleted_files WHERE id = ?", fileID)
+	if err != nil {
+		return removed, fmt.Errorf("failed to remove database record: %w", err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	logger.Info("Successfully removed database record for ID %d, rows affected: %d", fileID, rowsAffected)
+	return removed, nil
 }
 
 // getFileOperationsFromMediaHub reads file operations from MediaHub database
@@ -489,7 +562,7 @@ func getFileOperationsFromMediaHub(limit, offset int, statusFilter, searchQuery
 	}
 
 	// Get database connection from pool
-	mediaHubDB, err := GetDatabaseConnection()
+	mediaHubDB, err := GetReadConnection()
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to get database connection: %w", err)
 	}
@@ -788,7 +861,7 @@ func getStatusCounts(operations []FileOperation, total int) (map[string]int, err
 	}
 
 	// Get database connection from pool
-	mediaHubDB, err := GetDatabaseConnection()
+	mediaHubDB, err := GetReadConnection()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get database connection: %w", err)
 	}
@@ -871,7 +944,7 @@ func getStatusCounts(operations []FileOperation, total int) (map[string]int, err
 
 	// Count deletions using the same filtered logic as display
 	var deletionCount int
-	deletedDB, err := GetDatabaseConnection()
+	deletedDB, err := GetReadConnection()
 	if err == nil {
 		deletionCount, _ = getDeletedFilesCountFromMediaHub(deletedDB, "")
 	} else {
@@ -925,8 +998,7 @@ func unsubscribeFromFileOperationNotifications(ch chan bool) {
 
 // HandleFileOperationEvents provides Server-Sent Events for file operation updates
 func HandleFileOperationEvents(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
 		return
 	}
 
@@ -934,11 +1006,13 @@ func HandleFileOperationEvents(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	middleware.AllowOrigin(w, r)
 
 	// Subscribe to file operation notifications
 	notificationCh := subscribeToFileOperationNotifications()
 	defer unsubscribeFromFileOperationNotifications(notificationCh)
+	session := sse.Open(r, "file-operations")
+	defer session.Close()
 
 	// Send initial connection message
 	fmt.Fprintf(w, "data: {\"type\":\"connected\"}\n\n")
@@ -954,6 +1028,8 @@ func HandleFileOperationEvents(w http.ResponseWriter, r *http.Request) {
 			if flusher, ok := w.(http.Flusher); ok {
 				flusher.Flush()
 			}
+		case <-session.Done():
+			return
 		case <-r.Context().Done():
 			return
 		}
@@ -1442,7 +1518,7 @@ func TrackFileDeletion(sourcePath, destinationPath, tmdbID, seasonNumber, reason
 	}
 
 	err := executeMainDBDeletionOperation(func() error {
-		return executeWithRetry(func() error {
+		return RetryOnLock(func() error {
 			db, err := GetDatabaseConnection()
 			if err != nil {
 				return err
@@ -1527,6 +1603,55 @@ func TrackFileFailure(sourcePath, tmdbID, seasonNumber, reason, errorMessage str
 }
 
 
+// ProcessedFileRecord describes a file that was linked into the library
+// outside of MediaHub, such as a file imported from Sonarr or Radarr
+type ProcessedFileRecord struct {
+	SourcePath      string
+	DestinationPath string
+	BasePath        string
+	TmdbID          string
+	TvdbID          string
+	ImdbID          string
+	MediaType       string
+	ProperName      string
+	Year            string
+	SeasonNumber    string
+	EpisodeNumber   string
+	FileSize        int64
+}
+
+// RecordProcessedFile stores a linked file in the MediaHub database the same
+// way MediaHub does after creating a symlink
+func RecordProcessedFile(record ProcessedFileRecord) error {
+	mediaHubDBPath := filepath.Join("..", "db", "processed_files.db")
+
+	if _, err := os.Stat(mediaHubDBPath); os.IsNotExist(err) {
+		return fmt.Errorf("MediaHub database not found")
+	}
+
+	return WithDatabaseTransaction(func(tx *sql.Tx) error {
+		query := `
+			INSERT OR REPLACE INTO processed_files (
+				file_path, destination_path, base_path, tmdb_id, tvdb_id, imdb_id, media_type,
+				proper_name, year, season_number, episode_number, file_size, processed_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now'))
+		`
+		_, err := tx.Exec(query, record.SourcePath, record.DestinationPath, record.BasePath,
+			nullIfEmpty(record.TmdbID), nullIfEmpty(record.TvdbID), nullIfEmpty(record.ImdbID), record.MediaType,
+			record.ProperName, nullIfEmpty(record.Year), nullIfEmpty(record.SeasonNumber), nullIfEmpty(record.EpisodeNumber),
+			record.FileSize)
+		if err != nil {
+			return fmt.Errorf("failed to record processed file: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// nullIfEmpty stores empty strings as NULL like MediaHub does for missing values
+func nullIfEmpty(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
 
 // Global variables to track dashboard notification subscribers
 var dashboardNotificationChannels = make(map[chan bool]bool)
@@ -1567,8 +1692,7 @@ func unsubscribeFromDashboardNotifications(ch chan bool) {
 
 // HandleDashboardEvents provides Server-Sent Events for dashboard updates
 func HandleDashboardEvents(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
 		return
 	}
 
@@ -1576,11 +1700,13 @@ func HandleDashboardEvents(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	middleware.AllowOrigin(w, r)
 
 	// Subscribe to dashboard notifications
 	notificationCh := subscribeToDashboardNotifications()
 	defer unsubscribeFromDashboardNotifications(notificationCh)
+	session := sse.Open(r, "dashboard")
+	defer session.Close()
 
 	// Send initial connection message
 	fmt.Fprintf(w, "data: {\"type\":\"connected\"}\n\n")
@@ -1596,6 +1722,8 @@ func HandleDashboardEvents(w http.ResponseWriter, r *http.Request) {
 			if flusher, ok := w.(http.Flusher); ok {
 				flusher.Flush()
 			}
+		case <-session.Done():
+			return
 		case <-r.Context().Done():
 			// Client disconnected
 			return
@@ -0,0 +1,178 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+)
+
+const defaultHashWorkers = 2
+
+// HashBackfillResult counts what a hash backfill did with the files missing a hash
+type HashBackfillResult struct {
+	Total  int `json:"total"`
+	Hashed int `json:"hashed"`
+	Failed int `json:"failed"`
+}
+
+// hashTarget is a source file waiting for its hash, with the size and
+// modification time the hash is only valid for
+type hashTarget struct {
+	path    string
+	size    int64
+	modTime int64
+}
+
+// hashWorkers returns the number of files hashed concurrently, from
+// CINESYNC_HASH_WORKERS. Hashing reads whole media files, so it defaults low.
+func hashWorkers() int {
+	workers := env.GetInt("CINESYNC_HASH_WORKERS", defaultHashWorkers)
+	if workers < 1 {
+		return 1
+	}
+	return workers
+}
+
+// contextReader stops a read as soon as its context is cancelled, so a
+// cancelled backfill does not finish hashing a large file first
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
+
+// hashFile returns the hex encoded sha256 of the file at path
+func hashFile(ctx context.Context, path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, contextReader{ctx: ctx, reader: file}); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// sourceFilesMissingHash returns the active source files without a stored hash
+func sourceFilesMissingHash() ([]hashTarget, error) {
+	var targets []hashTarget
+	err := executeReadOperation(func(db *sql.DB) error {
+		rows, err := db.Query(`SELECT file_path, COALESCE(file_size, 0), COALESCE(modified_time, 0)
+			FROM source_files WHERE is_active = TRUE AND (file_hash IS NULL OR file_hash = '')
+			ORDER BY id`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		targets = targets[:0]
+		for rows.Next() {
+			var target hashTarget
+			if err := rows.Scan(&target.path, &target.size, &target.modTime); err != nil {
+				return err
+			}
+			targets = append(targets, target)
+		}
+		return rows.Err()
+	})
+	return targets, err
+}
+
+// BackfillSourceFileHashes computes the hash of every active source file that
+// does not have one yet, hashing up to CINESYNC_HASH_WORKERS files at once.
+// Files that already have a hash are left alone. A hash is only stored while
+// the row still has the size and modification time it was read with, so a
+// file a scan saw change meanwhile is picked up by the next run instead.
+// progress, when set, is called after each file. When ctx is cancelled the
+// hashes computed so far are kept and ctx.Err() is returned.
+func BackfillSourceFileHashes(ctx context.Context, progress func(result HashBackfillResult)) (HashBackfillResult, error) {
+	var result HashBackfillResult
+	targets, err := sourceFilesMissingHash()
+	if err != nil {
+		return result, err
+	}
+	result.Total = len(targets)
+	if len(targets) == 0 {
+		return result, nil
+	}
+
+	writer := newSourceBatchWriter(scanBatchSize())
+	var mutex sync.Mutex
+	record := func(target hashTarget, hash string, err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			result.Failed++
+			logger.Debug("Failed to hash %s: %v", target.path, err)
+		} else {
+			result.Hashed++
+			hashedAt := time.Now().Unix()
+			if err := writer.Add(func(tx *sql.Tx) error {
+				_, err := tx.Exec(`UPDATE source_files SET file_hash = ?, hashed_at = ?
+					WHERE file_path = ? AND COALESCE(file_size, 0) = ? AND COALESCE(modified_time, 0) = ?`,
+					hash, hashedAt, target.path, target.size, target.modTime)
+				return err
+			}); err != nil {
+				logger.Error("Failed to store file hashes: %v", err)
+			}
+		}
+		if progress != nil {
+			progress(result)
+		}
+	}
+
+	workers := min(hashWorkers(), len(targets))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				hash, err := hashFile(ctx, targets[i].path)
+				record(targets[i], hash, err)
+			}
+		}()
+	}
+
+feed:
+	for i := range targets {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if err := writer.Flush(); err != nil {
+		return result, err
+	}
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	logger.Info("Hash backfill finished: %d of %d files hashed, %d failed", result.Hashed, result.Total, result.Failed)
+	return result, nil
+}
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+)
+
+// IdempotencyKeyHeader carries the client supplied key that makes a retried
+// bulk operation return the first run's result instead of running again
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const (
+	defaultIdempotencyTTL   = 24 * time.Hour
+	maxIdempotencyKeyLength = 200
+)
+
+// IdempotencyTTL returns how long a key and its result are kept, from
+// CINESYNC_IDEMPOTENCY_TTL
+func IdempotencyTTL() time.Duration {
+	ttl, err := time.ParseDuration(env.GetString("CINESYNC_IDEMPOTENCY_TTL", defaultIdempotencyTTL.String()))
+	if err != nil || ttl <= 0 {
+		return defaultIdempotencyTTL
+	}
+	return ttl
+}
+
+// IdempotentRequest is a bulk operation running under a claimed idempotency
+// key. A nil *IdempotentRequest is a request without a key; its methods then
+// only write the response.
+type IdempotentRequest struct {
+	key      string
+	finished bool
+}
+
+// BeginIdempotentRequest claims the Idempotency-Key of r for operation. Keys
+// are scoped to the authenticated user and the method and path of r, so
+// clients choosing the same key never see each other's results. The request
+// is fingerprinted so a key reused for a different request is refused rather
+// than answered with an unrelated result. It returns false after
+// writing the response when the key was used before: the stored response is
+// replayed once the first run finished, and 409 is returned while it runs.
+// Requests without the header get a nil *IdempotentRequest and true.
+func BeginIdempotentRequest(w http.ResponseWriter, r *http.Request, operation string, request interface{}) (*IdempotentRequest, bool) {
+	key := strings.TrimSpace(r.Header.Get(IdempotencyKeyHeader))
+	if key == "" {
+		return nil, true
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeIdempotencyKeyInvalid, "Idempotency-Key must be at most 200 characters")
+		return nil, false
+	}
+
+	key = auth.RequestUsername(r) + "\n" + r.Method + " " + r.URL.Path + "\n" + key
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+		return nil, false
+	}
+	sum := sha256.Sum256(body)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	now := time.Now()
+	claimed := false
+	var storedOperation, storedFingerprint string
+	var statusCode sql.NullInt64
+	var response sql.NullString
+	err = executeWriteOperationSync(func(db *sql.DB) error {
+		if _, err := db.Exec(`DELETE FROM idempotency_keys WHERE created_at < ?`, now.Add(-IdempotencyTTL()).Unix()); err != nil {
+			return err
+		}
+		result, err := db.Exec(`INSERT OR IGNORE INTO idempotency_keys (idempotency_key, operation, fingerprint, created_at) VALUES (?, ?, ?, ?)`,
+			key, operation, fingerprint, now.Unix())
+		if err != nil {
+			return err
+		}
+		if rows, _ := result.RowsAffected(); rows == 1 {
+			claimed = true
+			return nil
+		}
+		return db.QueryRow(`SELECT operation, fingerprint, status_code, response FROM idempotency_keys WHERE idempotency_key = ?`, key).Scan(
+			&storedOperation, &storedFingerprint, &statusCode, &response)
+	})
+	if err != nil {
+		logger.Warn("Failed to claim idempotency key for %s: %v", operation, err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeFileOpDatabase, "Failed to check the idempotency key")
+		return nil, false
+	}
+
+	switch {
+	case claimed:
+		return &IdempotentRequest{key: key}, true
+	case storedOperation != operation || storedFingerprint != fingerprint:
+		apierror.WriteError(w, http.StatusUnprocessableEntity, apierror.CodeIdempotencyKeyReused, "Idempotency-Key was already used for a different request")
+	case !response.Valid:
+		w.Header().Set("Retry-After", "5")
+		apierror.WriteError(w, http.StatusConflict, apierror.CodeIdempotencyInProgress, "A request with this Idempotency-Key is still running")
+	default:
+		logger.Info("Replaying %s result for a repeated idempotency key", operation)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(int(statusCode.Int64))
+		w.Write([]byte(response.String))
+	}
+	return nil, false
+}
+
+// WriteJSON writes response and, under a claimed key, stores it with batchID
+// so a repeated request gets the same answer
+func (req *IdempotentRequest) WriteJSON(w http.ResponseWriter, statusCode int, batchID string, response interface{}) {
+	body, err := json.Marshal(response)
+	if err != nil {
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to encode response")
+		return
+	}
+	body = append(body, '\n')
+
+	if req != nil {
+		err := executeWriteOperationSync(func(db *sql.DB) error {
+			_, err := db.Exec(`UPDATE idempotency_keys SET batch_id = ?, status_code = ?, response = ? WHERE idempotency_key = ?`,
+				batchID, statusCode, string(body), req.key)
+			return err
+		})
+		if err != nil {
+			logger.Warn("Failed to store result of batch %s for its idempotency key: %v", batchID, err)
+		}
+		req.finished = true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
+
+// Release frees a claimed key whose operation ended without a stored result,
+// such as on an early error, so the client can retry it. It is a no-op after
+// WriteJSON, which makes it suitable for a defer.
+func (req *IdempotentRequest) Release() {
+	if req == nil || req.finished {
+		return
+	}
+	req.finished = true
+	err := executeWriteOperationSync(func(db *sql.DB) error {
+		_, err := db.Exec(`DELETE FROM idempotency_keys WHERE idempotency_key = ? AND response IS NULL`, req.key)
+		return err
+	})
+	if err != nil {
+		logger.Warn("Failed to release idempotency key: %v", err)
+	}
+}
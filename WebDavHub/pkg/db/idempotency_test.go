@@ -0,0 +1,70 @@
+package db
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cinesync/pkg/auth"
+)
+
+func TestIdempotencyKeysAreScopedToUserAndEndpoint(t *testing.T) {
+	useSourceDB(t)
+	t.Setenv("CINESYNC_USERNAME", "admin")
+	if _, err := auth.ReloadUsers(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.CreateUser("alice", "Correct-Horse-42", auth.RoleUser); err != nil {
+		t.Fatal(err)
+	}
+	tokens := make(map[string]string)
+	for _, username := range []string{"admin", "alice"} {
+		token, err := auth.GenerateJWT(username, auth.RoleUser)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens[username] = token
+	}
+
+	begin := func(username, method, path string) (*httptest.ResponseRecorder, *IdempotentRequest, bool) {
+		r := httptest.NewRequest(method, path, nil)
+		r.Header.Set(IdempotencyKeyHeader, "retry-1")
+		r.Header.Set("Authorization", "Bearer "+tokens[username])
+		w := httptest.NewRecorder()
+		req, ok := BeginIdempotentRequest(w, r, "trash_restore", []int64{1})
+		return w, req, ok
+	}
+
+	_, first, ok := begin("admin", http.MethodPost, "/api/database/trash/restore")
+	if !ok || first == nil {
+		t.Fatal("first request did not claim the key")
+	}
+	first.WriteJSON(httptest.NewRecorder(), http.StatusOK, "batch-1", map[string]string{"batchId": "batch-1"})
+
+	for _, tt := range []struct {
+		name     string
+		username string
+		method   string
+		path     string
+		replayed bool
+	}{
+		{"same user and endpoint", "admin", http.MethodPost, "/api/database/trash/restore", true},
+		{"another user", "alice", http.MethodPost, "/api/database/trash/restore", false},
+		{"another path", "admin", http.MethodPost, "/api/file-operations/bulk", false},
+		{"another method", "admin", http.MethodDelete, "/api/database/trash/restore", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w, req, ok := begin(tt.username, tt.method, tt.path)
+			defer req.Release()
+			if tt.replayed {
+				if ok || w.Header().Get("Idempotent-Replayed") != "true" || w.Code != http.StatusOK {
+					t.Fatalf("request = %d %v, want the stored result replayed", w.Code, ok)
+				}
+				return
+			}
+			if !ok || req == nil {
+				t.Fatalf("request = %d %s, want the key claimed", w.Code, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SQLiteLimitStore keeps login throttling state in a SQLite database, so
+// CineSync replicas pointing at the same file share counters and lockouts.
+// It implements auth.LimitStore.
+type SQLiteLimitStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteLimitStore opens or creates the limit database at dbPath
+func NewSQLiteLimitStore(dbPath string) (*SQLiteLimitStore, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create limit database directory: %w", err)
+	}
+	db, err := OpenAndConfigureDatabase(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open limit database: %w", err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS auth_limits (
+		key TEXT PRIMARY KEY,
+		count INTEGER NOT NULL DEFAULT 0,
+		window_start INTEGER NOT NULL DEFAULT 0, -- unix milliseconds
+		locked_until INTEGER NOT NULL DEFAULT 0  -- unix milliseconds
+	);`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create auth_limits table: %w", err)
+	}
+	return &SQLiteLimitStore{db: db}, nil
+}
+
+// Increment counts a failure in a single statement, so concurrent replicas
+// never lose an increment
+func (s *SQLiteLimitStore) Increment(key string, window time.Duration) (int, error) {
+	now := time.Now().UnixMilli()
+	windowStart := now - window.Milliseconds()
+
+	var count int
+	err := s.db.QueryRow(`INSERT INTO auth_limits (key, count, window_start) VALUES (?, 1, ?)
+		ON CONFLICT(key) DO UPDATE SET
+			count = CASE WHEN window_start <= ? THEN 1 ELSE count + 1 END,
+			window_start = CASE WHEN window_start <= ? THEN ? ELSE window_start END
+		RETURNING count`, key, now, windowStart, windowStart, now).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	// Forget keys that are neither counting nor locked
+	if _, err := s.db.Exec(`DELETE FROM auth_limits WHERE window_start <= ? AND locked_until <= ?`, windowStart, now); err != nil {
+		return count, err
+	}
+	return count, nil
+}
+
+func (s *SQLiteLimitStore) Lock(key string, until time.Time) error {
+	_, err := s.db.Exec(`INSERT INTO auth_limits (key, locked_until) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET locked_until = excluded.locked_until`, key, until.UnixMilli())
+	return err
+}
+
+func (s *SQLiteLimitStore) LockedUntil(key string) (time.Time, error) {
+	var lockedUntil int64
+	err := s.db.QueryRow(`SELECT locked_until FROM auth_limits WHERE key = ?`, key).Scan(&lockedUntil)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	until := time.UnixMilli(lockedUntil)
+	if !time.Now().Before(until) {
+		return time.Time{}, nil
+	}
+	return until, nil
+}
+
+func (s *SQLiteLimitStore) Reset(key string) error {
+	_, err := s.db.Exec(`DELETE FROM auth_limits WHERE key = ?`, key)
+	return err
+}
+
+// Close closes the limit database
+func (s *SQLiteLimitStore) Close() error {
+	return s.db.Close()
+}
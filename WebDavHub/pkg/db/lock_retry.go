@@ -0,0 +1,74 @@
+package db
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"time"
+
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	defaultLockRetries      = 10
+	defaultLockRetryBackoff = 50 * time.Millisecond
+	maxLockRetryBackoff     = 2 * time.Second
+)
+
+// IsLockError reports whether err is SQLITE_BUSY or SQLITE_LOCKED, which
+// clear up once the connection holding the lock finishes
+func IsLockError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		code := sqliteErr.Code() & 0xff
+		return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+	}
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "database is locked") ||
+		strings.Contains(message, "database table is locked") ||
+		strings.Contains(message, "sqlite_busy") ||
+		strings.Contains(message, "sqlite_locked")
+}
+
+// lockRetrySettings returns how often a locked operation is retried and the
+// delay before the first retry, from DB_LOCK_RETRIES and
+// DB_LOCK_RETRY_BACKOFF_MS
+func lockRetrySettings() (int, time.Duration) {
+	retries := env.GetInt("DB_LOCK_RETRIES", defaultLockRetries)
+	if retries < 0 {
+		retries = 0
+	}
+	backoff := defaultLockRetryBackoff
+	if ms := env.GetInt("DB_LOCK_RETRY_BACKOFF_MS", int(defaultLockRetryBackoff/time.Millisecond)); ms >= 0 {
+		backoff = time.Duration(ms) * time.Millisecond
+	}
+	return retries, backoff
+}
+
+// RetryOnLock runs op and runs it again while it fails because the database
+// is locked, backing off exponentially with jitter up to maxLockRetryBackoff.
+// SQLite already waits busy_timeout for a lock; this covers the cases it
+// gives up on, such as a write that upgrades a read transaction. Any other
+// error is returned at once, as is the last error once the retries run out.
+func RetryOnLock(op func() error) error {
+	retries, backoff := lockRetrySettings()
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= retries || !IsLockError(err) {
+			return err
+		}
+
+		delay := min(backoff<<uint(min(attempt, 16)), maxLockRetryBackoff)
+		if delay > 0 {
+			delay += time.Duration(rand.Int63n(int64(delay/2) + 1))
+		}
+		logger.Debug("Database is locked, retrying (attempt %d/%d): %v", attempt+1, retries, err)
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/logger"
+)
+
+// LockedFiles is the set of source files locked against automatic processing
+type LockedFiles map[string]bool
+
+// Contains reports whether path is locked
+func (l LockedFiles) Contains(path string) bool {
+	return l[filepath.Clean(path)]
+}
+
+// GetLockedFiles returns every locked source file
+func GetLockedFiles() (LockedFiles, error) {
+	locked := make(LockedFiles)
+	err := executeReadOperation(func(db *sql.DB) error {
+		rows, err := db.Query(`SELECT file_path FROM locked_files`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var path string
+			if err := rows.Scan(&path); err != nil {
+				return err
+			}
+			locked[path] = true
+		}
+		return rows.Err()
+	})
+	return locked, err
+}
+
+// IsFileLocked reports whether a source file is locked. Lookup failures count
+// as unlocked, so a broken database does not stop processing.
+func IsFileLocked(path string) bool {
+	locked := false
+	err := executeReadOperation(func(db *sql.DB) error {
+		err := db.QueryRow(`SELECT 1 FROM locked_files WHERE file_path = ?`, filepath.Clean(path)).Scan(new(int))
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		locked = err == nil
+		return err
+	})
+	if err != nil {
+		logger.Debug("Failed to check the lock of %s: %v", path, err)
+	}
+	return locked
+}
+
+// SetFileLocked locks or unlocks a source file. A locked file keeps its
+// metadata, name and links: scans, the source watcher, reidentification and
+// MediaHub itself leave it alone until it is unlocked.
+func SetFileLocked(path string, locked bool) error {
+	path = filepath.Clean(path)
+	return executeWriteOperationSync(func(db *sql.DB) error {
+		if !locked {
+			_, err := db.Exec(`DELETE FROM locked_files WHERE file_path = ?`, path)
+			return err
+		}
+		_, err := db.Exec(`INSERT OR IGNORE INTO locked_files (file_path) VALUES (?)`, path)
+		return err
+	})
+}
+
+// sourceFileKnown reports whether path is a file of the source database
+func sourceFileKnown(path string) (bool, error) {
+	known := false
+	err := executeReadOperation(func(db *sql.DB) error {
+		err := db.QueryRow(`SELECT 1 FROM source_files WHERE file_path = ?`, path).Scan(new(int))
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		known = err == nil
+		return err
+	})
+	return known, err
+}
+
+// SourceFileLockRequest locks or unlocks one source file
+type SourceFileLockRequest struct {
+	FilePath string `json:"filePath"`
+	Locked   bool   `json:"locked"`
+}
+
+// HandleSourceFileLock serves /api/database/source-files/lock. GET lists the
+// locked source files and POST {"filePath", "locked"} locks or unlocks one,
+// so hand-tuned entries survive rescans and reidentification.
+func HandleSourceFileLock(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		locked, err := GetLockedFiles()
+		if err != nil {
+			logger.Error("Failed to list locked files: %v", err)
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list locked files")
+			return
+		}
+		paths := make([]string, 0, len(locked))
+		for path := range locked {
+			paths = append(paths, path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"files": paths})
+		return
+	}
+
+	var req SourceFileLockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+	req.FilePath = strings.TrimSpace(req.FilePath)
+	if req.FilePath == "" || !filepath.IsAbs(req.FilePath) {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "filePath must be an absolute source path")
+		return
+	}
+	req.FilePath = filepath.Clean(req.FilePath)
+
+	if req.Locked {
+		known, err := sourceFileKnown(req.FilePath)
+		if err != nil {
+			logger.Error("Failed to look up source file %s: %v", req.FilePath, err)
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to look up the source file")
+			return
+		}
+		if !known {
+			apierror.WriteError(w, http.StatusNotFound, apierror.CodeNotFound, "Source file not found")
+			return
+		}
+	}
+
+	if err := SetFileLocked(req.FilePath, req.Locked); err != nil {
+		logger.Error("Failed to save the lock of %s: %v", req.FilePath, err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save the lock")
+		return
+	}
+	if req.Locked {
+		logger.Info("Locked %s against automatic processing", req.FilePath)
+	} else {
+		logger.Info("Unlocked %s", req.FilePath)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
@@ -0,0 +1,343 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// Per-item outcomes recorded for a bulk operation
+const (
+	OperationResultSuccess = "success"
+	OperationResultSkipped = "skipped"
+	OperationResultFailed  = "failed"
+)
+
+// OperationBatch summarises a single bulk operation. PermissionFailedCount is
+// the part of FailedCount refused by file permissions or a read-only file system.
+type OperationBatch struct {
+	BatchID               string            `json:"batchId"`
+	Operation             string            `json:"operation"`
+	Status                string            `json:"status"`
+	StartedAt             int64             `json:"startedAt"`
+	CompletedAt           *int64            `json:"completedAt,omitempty"`
+	SuccessCount          int               `json:"successCount"`
+	SkippedCount          int               `json:"skippedCount"`
+	FailedCount           int               `json:"failedCount"`
+	PermissionFailedCount int               `json:"permissionFailedCount"`
+	Results               []OperationResult `json:"results"`
+}
+
+// OperationResult is the outcome for one item of a bulk operation
+type OperationResult struct {
+	SourcePath      string `json:"source"`
+	DestinationPath string `json:"destination,omitempty"`
+	Status          string `json:"status"`
+	Reason          string `json:"reason,omitempty"`
+	Retries         int    `json:"retries"`
+	CreatedAt       int64  `json:"createdAt"`
+}
+
+// OperationBatchEnv is the environment variable a MediaHub run started for a
+// batch receives the batch id in. MediaHub sends it back with every file
+// operation it reports, so concurrent runs and the monitor never mix results.
+const OperationBatchEnv = "CINESYNC_OPERATION_BATCH_ID"
+
+// operationResultsRetention returns how long finished batches and their
+// results are kept, from CINESYNC_OPERATION_RESULTS_RETENTION_DAYS; 0 keeps
+// them forever
+func operationResultsRetention() time.Duration {
+	days := env.GetInt("CINESYNC_OPERATION_RESULTS_RETENTION_DAYS", 30)
+	if days <= 0 {
+		return 0
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// StartOperationBatch creates a new batch and returns its id. Batches that
+// finished before the retention are deleted along the way.
+func StartOperationBatch(operation string) (string, error) {
+	batchID := uuid.New().String()
+	err := executeWriteOperationSync(func(db *sql.DB) error {
+		_, err := db.Exec(`INSERT INTO operation_batches (batch_id, operation, started_at, status) VALUES (?, ?, ?, 'running')`,
+			batchID, operation, time.Now().Unix())
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to start operation batch: %w", err)
+	}
+	purgeExpiredOperationBatches()
+	return batchID, nil
+}
+
+// purgeExpiredOperationBatches deletes finished batches older than the
+// retention and their results
+func purgeExpiredOperationBatches() {
+	retention := operationResultsRetention()
+	if retention == 0 {
+		return
+	}
+	cutoff := time.Now().Add(-retention).Unix()
+	var deleted int64
+	err := executeWriteOperationSync(func(db *sql.DB) error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`DELETE FROM operation_results WHERE batch_id IN (
+			SELECT batch_id FROM operation_batches WHERE completed_at IS NOT NULL AND completed_at < ?)`, cutoff); err != nil {
+			return err
+		}
+		result, err := tx.Exec(`DELETE FROM operation_batches WHERE completed_at IS NOT NULL AND completed_at < ?`, cutoff)
+		if err != nil {
+			return err
+		}
+		deleted, _ = result.RowsAffected()
+		return tx.Commit()
+	})
+	if err != nil {
+		logger.Warn("Failed to purge expired operation batches: %v", err)
+		return
+	}
+	if deleted > 0 {
+		logger.Info("Purged %d operation batches older than %s", deleted, retention)
+	}
+}
+
+// RecordOperationResult stores the outcome of one item in a batch
+func RecordOperationResult(batchID, sourcePath, destinationPath, status, reason string) error {
+	return RecordOperationResultWithRetries(batchID, sourcePath, destinationPath, status, reason, 0)
+}
+
+// RecordOperationResultWithRetries stores the outcome of one item in a batch
+// along with the number of times it was retried
+func RecordOperationResultWithRetries(batchID, sourcePath, destinationPath, status, reason string, retries int) error {
+	column := map[string]string{
+		OperationResultSuccess: "success_count",
+		OperationResultSkipped: "skipped_count",
+		OperationResultFailed:  "failed_count",
+	}[status]
+	if column == "" {
+		return fmt.Errorf("invalid operation result status: %s", status)
+	}
+
+	return executeWriteOperationSync(func(db *sql.DB) error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`INSERT INTO operation_results (batch_id, source_path, destination_path, status, reason, retries, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			batchID, sourcePath, destinationPath, status, reason, retries, time.Now().Unix()); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`UPDATE operation_batches SET %s = %s + 1 WHERE batch_id = ?`, column, column), batchID); err != nil {
+			return err
+		}
+		if status == OperationResultFailed && isPermissionReason(reason) {
+			if _, err := tx.Exec(`UPDATE operation_batches SET permission_failed_count = COALESCE(permission_failed_count, 0) + 1 WHERE batch_id = ?`, batchID); err != nil {
+				return err
+			}
+		}
+		return tx.Commit()
+	})
+}
+
+// CompleteOperationBatch marks a batch as finished with the given status and
+// logs a summary of the items that failed on permissions
+func CompleteOperationBatch(batchID, status string) error {
+	err := executeWriteOperationSync(func(db *sql.DB) error {
+		_, err := db.Exec(`UPDATE operation_batches SET status = ?, completed_at = ? WHERE batch_id = ?`,
+			status, time.Now().Unix(), batchID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	logPermissionFailures(batchID)
+	return nil
+}
+
+// maxPermissionFailureExamples bounds the reasons quoted in the summary of a
+// batch's permission failures
+const maxPermissionFailureExamples = 3
+
+// logPermissionFailures warns about the items of a batch refused by
+// permissions, quoting a few distinct reasons, since one unwritable folder
+// usually fails every item below it
+func logPermissionFailures(batchID string) {
+	var operation string
+	var count int
+	var examples []string
+	err := executeReadOperation(func(db *sql.DB) error {
+		err := db.QueryRow(`SELECT operation, COALESCE(permission_failed_count, 0) FROM operation_batches WHERE batch_id = ?`,
+			batchID).Scan(&operation, &count)
+		if err != nil || count == 0 {
+			return err
+		}
+
+		rows, err := db.Query(`SELECT COALESCE(reason, '') FROM operation_results WHERE batch_id = ? AND status = ?
+			GROUP BY reason ORDER BY MIN(id)`,
+			batchID, OperationResultFailed)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		examples = examples[:0]
+		for rows.Next() && len(examples) < maxPermissionFailureExamples {
+			var reason string
+			if err := rows.Scan(&reason); err != nil {
+				return err
+			}
+			if isPermissionReason(reason) {
+				examples = append(examples, reason)
+			}
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		logger.Warn("Failed to summarise permission failures of batch %s: %v", batchID, err)
+		return
+	}
+	if count > 0 {
+		logger.Warn("%s batch %s: %d item(s) failed on permissions, check the ownership and mount options of: %s",
+			operation, batchID, count, strings.Join(examples, "; "))
+	}
+}
+
+// recordBatchResult records a tracked file operation against the batch the
+// reporting MediaHub run belongs to, if any
+func recordBatchResult(batchID, operation, sourcePath, destinationPath, reason string) {
+	if batchID == "" {
+		return
+	}
+
+	status := OperationResultSuccess
+	switch operation {
+	case "add", "force_recreate":
+	case "failed":
+		status = OperationResultFailed
+		if isSkipReason(reason) {
+			status = OperationResultSkipped
+		}
+	default:
+		return
+	}
+
+	if err := RecordOperationResult(batchID, sourcePath, destinationPath, status, reason); err != nil {
+		logger.Warn("Failed to record result for batch %s: %v", batchID, err)
+	}
+}
+
+// isSkipReason matches the reasons MediaHub uses for intentionally skipped files
+func isSkipReason(reason string) bool {
+	reason = strings.ToLower(reason)
+	for _, marker := range []string{"skipped", "extra", "special content", "unsupported", "adult content"} {
+		if strings.Contains(reason, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetOperationBatch returns a batch and its results, optionally filtered by status
+func GetOperationBatch(batchID, status string) (*OperationBatch, error) {
+	var batch *OperationBatch
+	err := executeReadOperation(func(db *sql.DB) error {
+		b := OperationBatch{Results: []OperationResult{}}
+		var completedAt sql.NullInt64
+		err := db.QueryRow(`SELECT batch_id, operation, status, started_at, completed_at, success_count, skipped_count, failed_count,
+			COALESCE(permission_failed_count, 0)
+			FROM operation_batches WHERE batch_id = ?`, batchID).Scan(
+			&b.BatchID, &b.Operation, &b.Status, &b.StartedAt, &completedAt, &b.SuccessCount, &b.SkippedCount, &b.FailedCount,
+			&b.PermissionFailedCount)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if completedAt.Valid {
+			b.CompletedAt = &completedAt.Int64
+		}
+
+		query := `SELECT source_path, COALESCE(destination_path, ''), status, COALESCE(reason, ''), COALESCE(retries, 0), created_at
+			FROM operation_results WHERE batch_id = ?`
+		args := []interface{}{batchID}
+		if status != "" {
+			query += ` AND status = ?`
+			args = append(args, status)
+		}
+		query += ` ORDER BY id`
+
+		rows, err := db.Query(query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var result OperationResult
+			if err := rows.Scan(&result.SourcePath, &result.DestinationPath, &result.Status, &result.Reason, &result.Retries, &result.CreatedAt); err != nil {
+				return err
+			}
+			b.Results = append(b.Results, result)
+		}
+		batch = &b
+		return rows.Err()
+	})
+	return batch, err
+}
+
+// HandleOperationBatch serves GET /api/file-operations/{batchId}, with an
+// optional status query parameter of success, skipped or failed
+func HandleOperationBatch(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	batchID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/file-operations/"), "/")
+	if batchID == "" || strings.Contains(batchID, "/") {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeFileOpInvalid, "Batch id is required")
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", OperationResultSuccess, OperationResultSkipped, OperationResultFailed:
+	default:
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid status. Must be 'success', 'skipped', or 'failed'")
+		return
+	}
+
+	batch, err := GetOperationBatch(batchID, status)
+	if err != nil {
+		logger.Warn("Failed to load operation batch %s: %v", batchID, err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeFileOpDatabase, "Failed to load operation batch")
+		return
+	}
+	if batch == nil {
+		apierror.WriteError(w, http.StatusNotFound, apierror.CodeFileOpNotFound, "Batch not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batch)
+}
@@ -0,0 +1,186 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"cinesync/pkg/apierror"
+)
+
+// Kinds of library operations coordinated by the operation registry
+const (
+	OperationScan       = "scan"
+	OperationPrune      = "prune"
+	OperationReconcile  = "reconcile"
+	OperationReidentify = "reidentify"
+)
+
+// operationConflicts lists the kinds that must not run next to each other on
+// the same library. Jobs of one kind keep their own rules, such as one prune
+// at a time or one scan per library, so only pairs of different kinds are
+// listed.
+var operationConflicts = map[string]map[string]bool{
+	OperationScan:       {OperationPrune: true, OperationReconcile: true},
+	OperationPrune:      {OperationScan: true, OperationReconcile: true, OperationReidentify: true},
+	OperationReconcile:  {OperationScan: true, OperationPrune: true, OperationReidentify: true},
+	OperationReidentify: {OperationPrune: true, OperationReconcile: true},
+}
+
+// ErrOperationConflict matches the errors of operations refused because a
+// conflicting one is running
+var ErrOperationConflict = errors.New("a conflicting operation is running")
+
+// Operation is a running job registered with the operation registry
+type Operation struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	// Paths are the libraries or source subtrees the operation works on;
+	// empty means every library
+	Paths     []string  `json:"paths,omitempty"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// OperationConflictError is returned for an operation refused because of
+// Blocking
+type OperationConflictError struct {
+	Kind     string
+	Blocking Operation
+}
+
+func (e *OperationConflictError) Error() string {
+	return fmt.Sprintf("cannot start %s while %s %s is running on the same library", e.Kind, e.Blocking.Kind, e.Blocking.ID)
+}
+
+// Is makes errors.Is(err, ErrOperationConflict) match
+func (e *OperationConflictError) Is(target error) bool {
+	return target == ErrOperationConflict
+}
+
+// OperationLease keeps an operation registered until it is released
+type OperationLease struct {
+	op *Operation
+}
+
+var (
+	operationsMutex   sync.Mutex
+	runningOperations = make(map[*Operation]bool)
+)
+
+// overlaps reports whether two operation scopes share a library
+func overlaps(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return true
+	}
+	for _, p := range a {
+		for _, q := range b {
+			p, q := filepath.Clean(p), filepath.Clean(q)
+			if isWithinDir(p, q) || isWithinDir(q, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// blockingOperationLocked returns a running operation the operation would
+// conflict with, ignoring parent, the operation that started it
+func blockingOperationLocked(kind string, paths []string, parent *OperationLease) *Operation {
+	var blocking []*Operation
+	for op := range runningOperations {
+		if parent != nil && op == parent.op {
+			continue
+		}
+		if operationConflicts[kind][op.Kind] && overlaps(paths, op.Paths) {
+			blocking = append(blocking, op)
+		}
+	}
+	if len(blocking) == 0 {
+		return nil
+	}
+	// Name the oldest, the one most likely to finish first
+	sort.Slice(blocking, func(i, j int) bool {
+		return blocking[i].StartedAt.Before(blocking[j].StartedAt)
+	})
+	return blocking[0]
+}
+
+// AcquireOperation registers a running operation of kind on paths, empty for
+// every library. It fails with an OperationConflictError when a conflicting
+// operation works on an overlapping library. The lease must be released when
+// the operation ends.
+func AcquireOperation(kind, id string, paths []string) (*OperationLease, error) {
+	return acquireOperation(kind, id, paths, nil)
+}
+
+// acquireOperation registers an operation started by parent, which it may
+// run next to even when their kinds conflict
+func acquireOperation(kind, id string, paths []string, parent *OperationLease) (*OperationLease, error) {
+	operationsMutex.Lock()
+	defer operationsMutex.Unlock()
+
+	if blocking := blockingOperationLocked(kind, paths, parent); blocking != nil {
+		return nil, &OperationConflictError{Kind: kind, Blocking: *blocking}
+	}
+	op := &Operation{ID: id, Kind: kind, Paths: append([]string(nil), paths...), StartedAt: time.Now()}
+	runningOperations[op] = true
+	return &OperationLease{op: op}, nil
+}
+
+// CheckOperation returns the OperationConflictError AcquireOperation would
+// fail with now, for handlers that answer before their job acquires the lease
+func CheckOperation(kind string, paths []string) error {
+	operationsMutex.Lock()
+	defer operationsMutex.Unlock()
+	if blocking := blockingOperationLocked(kind, paths, nil); blocking != nil {
+		return &OperationConflictError{Kind: kind, Blocking: *blocking}
+	}
+	return nil
+}
+
+// SetID replaces the id of an operation whose job id is assigned after it
+// started, such as the record id of a scan
+func (l *OperationLease) SetID(id string) {
+	operationsMutex.Lock()
+	l.op.ID = id
+	operationsMutex.Unlock()
+}
+
+// Release unregisters the operation
+func (l *OperationLease) Release() {
+	operationsMutex.Lock()
+	delete(runningOperations, l.op)
+	operationsMutex.Unlock()
+}
+
+type operationLeaseKey struct{}
+
+// withOperationLease stores the lease of the operation a context belongs to
+func withOperationLease(ctx context.Context, lease *OperationLease) context.Context {
+	return context.WithValue(ctx, operationLeaseKey{}, lease)
+}
+
+// operationLeaseFrom returns the lease stored in ctx, or nil
+func operationLeaseFrom(ctx context.Context) *OperationLease {
+	lease, _ := ctx.Value(operationLeaseKey{}).(*OperationLease)
+	return lease
+}
+
+// WriteOperationConflict answers 409 Conflict naming the job that blocks the
+// request in the error details
+func WriteOperationConflict(w http.ResponseWriter, err error) {
+	var conflict *OperationConflictError
+	if !errors.As(err, &conflict) {
+		apierror.WriteError(w, http.StatusConflict, apierror.CodeOperationConflict, err.Error())
+		return
+	}
+	message := fmt.Sprintf("Cannot start %s while %s %s is running on the same library", conflict.Kind, conflict.Blocking.Kind, conflict.Blocking.ID)
+	apierror.WriteErrorDetails(w, http.StatusConflict, apierror.CodeOperationConflict, message, map[string]interface{}{
+		"blockingJob": conflict.Blocking,
+	})
+}
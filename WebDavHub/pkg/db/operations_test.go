@@ -0,0 +1,152 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// postPrune sends a prune request to the handler, as an administrator with
+// auth disabled
+func postPrune(t *testing.T, req PruneRequest) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	HandleDatabasePrune(w, httptest.NewRequest(http.MethodPost, "/api/database/prune", bytes.NewReader(body)))
+	return w
+}
+
+// waitForPrune waits until the prune job is no longer running
+func waitForPrune(t *testing.T, id string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, exists := prunes.Job(id); !exists || job.Status != PruneStatusRunning {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("prune job %s is still running", id)
+}
+
+func TestPruneIsRejectedDuringAScanOfTheSameLibrary(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		// scan is the library the running scan works on, "" for every library
+		scan   string
+		status int
+	}{
+		{"scan of the same library", "movies", http.StatusConflict},
+		{"scan of every library", "", http.StatusConflict},
+		{"scan of another library", "shows", http.StatusAccepted},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CINESYNC_AUTH_ENABLED", "false")
+			mediaHubDB := useMediaHubDB(t)
+			root, dest := t.TempDir(), t.TempDir()
+			movies, shows := filepath.Join(root, "movies"), filepath.Join(root, "shows")
+			t.Setenv("SOURCE_DIR", movies+","+shows)
+			t.Setenv("DESTINATION_DIR", dest)
+			t.Setenv("CINESYNC_TRASH_RETENTION_DAYS", "0")
+			for _, dir := range []string{movies, shows} {
+				if err := os.MkdirAll(dir, 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			item := addLinkedRecord(t, mediaHubDB, filepath.Join(movies, "Heat.1995.mkv"), filepath.Join(dest, "Heat (1995).mkv"))
+
+			var scanPaths []string
+			if tt.scan != "" {
+				scanPaths = []string{filepath.Join(root, tt.scan)}
+			}
+			scan, err := AcquireOperation(OperationScan, "scan-1", scanPaths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer scan.Release()
+
+			w := postPrune(t, PruneRequest{FilePaths: []string{item.SourcePath}, DryRun: true})
+			var preview PrunePreview
+			if err := json.NewDecoder(w.Body).Decode(&preview); err != nil || preview.ConfirmationToken == "" {
+				t.Fatalf("dry run = %d %+v, %v", w.Code, preview, err)
+			}
+
+			w = postPrune(t, PruneRequest{FilePaths: []string{item.SourcePath}, ConfirmationToken: preview.ConfirmationToken})
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if w.Code == http.StatusAccepted {
+				var job PruneJob
+				if err := json.NewDecoder(w.Body).Decode(&job); err != nil {
+					t.Fatal(err)
+				}
+				waitForPrune(t, job.ID)
+				return
+			}
+
+			var response struct {
+				Details struct {
+					BlockingJob Operation `json:"blockingJob"`
+				}
+			}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Details.BlockingJob.ID != "scan-1" || response.Details.BlockingJob.Kind != OperationScan {
+				t.Fatalf("blocking job = %+v, want scan scan-1", response.Details.BlockingJob)
+			}
+			if recordCount(t, mediaHubDB, item.SourcePath) != 1 {
+				t.Fatal("a refused prune removed the record")
+			}
+		})
+	}
+}
+
+func TestAcquireOperationConflicts(t *testing.T) {
+	movies, shows := []string{"/media/movies"}, []string{"/media/shows"}
+	for _, tt := range []struct {
+		name                string
+		running, kind       string
+		runningPaths, paths []string
+		conflict            bool
+	}{
+		{"prune during a scan of the library", OperationScan, OperationPrune, movies, movies, true},
+		{"scan during a prune of a subtree", OperationPrune, OperationScan, movies, []string{"/media/movies/Heat"}, true},
+		{"reconcile during a scan of every library", OperationScan, OperationReconcile, nil, movies, true},
+		{"prune during a scan of another library", OperationScan, OperationPrune, shows, movies, false},
+		{"reidentify during a scan", OperationScan, OperationReidentify, movies, movies, false},
+		{"two scans of different libraries", OperationScan, OperationScan, shows, movies, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			running, err := AcquireOperation(tt.running, "running-1", tt.runningPaths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer running.Release()
+
+			lease, err := AcquireOperation(tt.kind, "new-1", tt.paths)
+			if lease != nil {
+				lease.Release()
+			}
+			var conflict *OperationConflictError
+			if got := errors.As(err, &conflict); got != tt.conflict {
+				t.Fatalf("AcquireOperation() error = %v, want conflict %v", err, tt.conflict)
+			}
+			if tt.conflict && conflict.Blocking.ID != "running-1" {
+				t.Fatalf("blocking job = %+v, want running-1", conflict.Blocking)
+			}
+			if tt.conflict && !errors.Is(err, ErrOperationConflict) {
+				t.Fatal("conflict does not match ErrOperationConflict")
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// PermissionError is a file operation that failed because the process may not
+// write where the operation has to, with a message saying what to fix
+type PermissionError struct {
+	// Folder is the folder the operation was refused in, empty when unknown
+	Folder string
+	// ReadOnly is set when the file system is mounted read-only
+	ReadOnly bool
+	UID      int
+	GID      int
+	Err      error
+}
+
+func (e *PermissionError) Error() string {
+	folder := "destination"
+	if e.Folder != "" {
+		folder = "folder " + e.Folder
+	}
+	if e.ReadOnly {
+		return fmt.Sprintf("%s is on a read-only file system: %v", folder, e.Err)
+	}
+	return fmt.Sprintf("%s not writable by uid %d (gid %d): %v", folder, e.UID, e.GID, e.Err)
+}
+
+func (e *PermissionError) Unwrap() error { return e.Err }
+
+// IsPermissionError reports whether err failed with EACCES, EPERM or EROFS
+func IsPermissionError(err error) bool {
+	return errors.Is(err, syscall.EACCES) || errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EROFS)
+}
+
+// ClassifyPermissionError turns a permission failure into a *PermissionError
+// naming the folder the process cannot write to. Creating, removing and
+// renaming an entry needs write access to the folder holding it, so that
+// folder is named rather than the entry. Other errors are returned unchanged.
+func ClassifyPermissionError(err error) error {
+	var permissionErr *PermissionError
+	if err == nil || !IsPermissionError(err) || errors.As(err, &permissionErr) {
+		return err
+	}
+
+	folder := ""
+	var linkErr *os.LinkError
+	var pathErr *fs.PathError
+	switch {
+	case errors.As(err, &linkErr):
+		folder = filepath.Dir(linkErr.New)
+	case errors.As(err, &pathErr):
+		folder = filepath.Dir(pathErr.Path)
+	}
+
+	return &PermissionError{
+		Folder:   folder,
+		ReadOnly: errors.Is(err, syscall.EROFS),
+		UID:      os.Getuid(),
+		GID:      os.Getgid(),
+		Err:      err,
+	}
+}
+
+// isPermissionReason matches the failure reasons of permission errors, both
+// the ones ClassifyPermissionError produces and the ones MediaHub reports
+func isPermissionReason(reason string) bool {
+	reason = strings.ToLower(reason)
+	for _, marker := range []string{"permission denied", "operation not permitted", "read-only file system", "not writable by uid"} {
+		if strings.Contains(reason, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,712 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+	"cinesync/pkg/strm"
+)
+
+// Prune job states
+const (
+	PruneStatusRunning   = "running"
+	PruneStatusCompleted = "completed"
+	PruneStatusCancelled = "cancelled"
+)
+
+const (
+	// pruneConfirmationTTL is how long a dry run's confirmation token stays valid
+	pruneConfirmationTTL = 10 * time.Minute
+	// prunePreviewLimit caps the records listed in a dry run response
+	prunePreviewLimit = 200
+	// finishedPruneRetention is how long finished prune jobs can be looked up
+	finishedPruneRetention = time.Hour
+)
+
+// pruneTypes are the /api/database/search type filters a prune filter may use
+var pruneTypes = map[string]bool{"movies": true, "tvshows": true, "processed": true, "skipped": true}
+
+var (
+	errPruneNothingSelected = errors.New("select records with filePaths, tmdbIds or a filter")
+	errPruneAmbiguous       = errors.New("filePaths and tmdbIds cannot be combined with a filter")
+	errPruneFilterTooBroad  = errors.New("filter needs a query or a type other than all")
+)
+
+// PruneFilter selects records the same way GET /api/database/search does
+type PruneFilter struct {
+	Query string `json:"query"`
+	Type  string `json:"type"`
+}
+
+// PruneRequest is the body of POST /api/database/prune. Records are selected
+// by source path, by TMDB id or by a search filter. DeleteSourceFiles also
+// removes the original files and is never implied; unlike symlinks and
+// records, deleted source files cannot be restored from the trash.
+type PruneRequest struct {
+	FilePaths         []string     `json:"filePaths,omitempty"`
+	TmdbIDs           []string     `json:"tmdbIds,omitempty"`
+	Filter            *PruneFilter `json:"filter,omitempty"`
+	DryRun            bool         `json:"dryRun"`
+	DeleteSourceFiles bool         `json:"deleteSourceFiles,omitempty"`
+	ConfirmationToken string       `json:"confirmationToken,omitempty"`
+}
+
+// PruneItem is one processed_files record selected for pruning
+type PruneItem struct {
+	SourcePath      string `json:"sourcePath"`
+	DestinationPath string `json:"destinationPath,omitempty"`
+	TmdbID          string `json:"tmdbId,omitempty"`
+	SeasonNumber    string `json:"seasonNumber,omitempty"`
+}
+
+// PrunePreview is the response to a dry run. Passing ConfirmationToken back
+// deletes exactly the previewed records.
+type PrunePreview struct {
+	Total             int         `json:"total"`
+	Items             []PruneItem `json:"items"`
+	Truncated         bool        `json:"truncated"`
+	DeleteSourceFiles bool        `json:"deleteSourceFiles"`
+	// TrashRetentionDays is how long pruned records stay restorable, 0 when
+	// the prune is permanent
+	TrashRetentionDays int        `json:"trashRetentionDays"`
+	ConfirmationToken  string     `json:"confirmationToken,omitempty"`
+	ExpiresAt          *time.Time `json:"expiresAt,omitempty"`
+}
+
+// PruneJob tracks a confirmed prune
+type PruneJob struct {
+	ID                string     `json:"id"`
+	Status            string     `json:"status"`
+	BatchID           string     `json:"batchId,omitempty"`
+	DeleteSourceFiles bool       `json:"deleteSourceFiles"`
+	Trash             bool       `json:"trash"`
+	Total             int        `json:"total"`
+	Processed         int        `json:"processed"`
+	RecordsRemoved    int        `json:"recordsRemoved"`
+	LinksRemoved      int        `json:"linksRemoved"`
+	SourcesRemoved    int        `json:"sourcesRemoved"`
+	Failed            int        `json:"failed"`
+	Retries           int        `json:"retries"`
+	StartedAt         time.Time  `json:"startedAt"`
+	FinishedAt        *time.Time `json:"finishedAt,omitempty"`
+}
+
+// pruneConfirmation is an outstanding dry run that may be confirmed once
+type pruneConfirmation struct {
+	fingerprint       string
+	deleteSourceFiles bool
+	expiresAt         time.Time
+}
+
+// pruneManager holds confirmation tokens and prune jobs. Only one prune runs
+// at a time.
+type pruneManager struct {
+	mutex         sync.Mutex
+	confirmations map[string]*pruneConfirmation
+	jobs          map[string]*PruneJob
+	running       string
+	cancel        context.CancelFunc
+}
+
+var prunes = &pruneManager{
+	confirmations: make(map[string]*pruneConfirmation),
+	jobs:          make(map[string]*PruneJob),
+}
+
+// validate checks that the request selects records unambiguously
+func (req *PruneRequest) validate() error {
+	hasIDs := len(req.FilePaths) > 0 || len(req.TmdbIDs) > 0
+	if !hasIDs && req.Filter == nil {
+		return errPruneNothingSelected
+	}
+	if hasIDs && req.Filter != nil {
+		return errPruneAmbiguous
+	}
+	if req.Filter != nil {
+		req.Filter.Query = strings.TrimSpace(req.Filter.Query)
+		if req.Filter.Type != "" && req.Filter.Type != "all" && !pruneTypes[req.Filter.Type] {
+			return fmt.Errorf("unknown filter type: %s", req.Filter.Type)
+		}
+		// Never let an empty filter select the whole library
+		if req.Filter.Query == "" && !pruneTypes[req.Filter.Type] {
+			return errPruneFilterTooBroad
+		}
+	}
+	return nil
+}
+
+// fingerprint identifies the selection and options of a request, so a token
+// cannot confirm a different prune than the one previewed
+func (req *PruneRequest) fingerprint(items []PruneItem) string {
+	paths := make([]string, len(items))
+	for i, item := range items {
+		paths[i] = item.SourcePath
+	}
+	sort.Strings(paths)
+
+	data, _ := json.Marshal(struct {
+		Paths             []string `json:"paths"`
+		DeleteSourceFiles bool     `json:"deleteSourceFiles"`
+	}{paths, req.DeleteSourceFiles})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// selectPruneItems loads the records a request selects
+func selectPruneItems(req *PruneRequest) ([]PruneItem, error) {
+	mediaHubDB, err := GetDatabaseConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	var whereClause string
+	var args []interface{}
+	if req.Filter != nil {
+		whereClause, args = buildSearchWhereClause(req.Filter.Query, req.Filter.Type)
+	} else {
+		var conditions []string
+		if len(req.FilePaths) > 0 {
+			conditions = append(conditions, "file_path IN ("+placeholders(len(req.FilePaths))+")")
+			for _, path := range req.FilePaths {
+				args = append(args, path)
+			}
+		}
+		if len(req.TmdbIDs) > 0 {
+			conditions = append(conditions, "tmdb_id IN ("+placeholders(len(req.TmdbIDs))+")")
+			for _, id := range req.TmdbIDs {
+				args = append(args, id)
+			}
+		}
+		whereClause = "WHERE " + strings.Join(conditions, " OR ")
+	}
+
+	rows, err := mediaHubDB.Query(`
+		SELECT file_path, COALESCE(destination_path, ''), COALESCE(tmdb_id, ''), COALESCE(season_number, '')
+		FROM processed_files `+whereClause+`
+		ORDER BY file_path`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []PruneItem{}
+	for rows.Next() {
+		var item PruneItem
+		if err := rows.Scan(&item.SourcePath, &item.DestinationPath, &item.TmdbID, &item.SeasonNumber); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// Preview selects the records of a dry run and issues a confirmation token
+// bound to them
+func (m *pruneManager) Preview(req *PruneRequest) (*PrunePreview, error) {
+	items, err := selectPruneItems(req)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &PrunePreview{
+		Total:              len(items),
+		Items:              items,
+		DeleteSourceFiles:  req.DeleteSourceFiles,
+		TrashRetentionDays: int(TrashRetention() / (24 * time.Hour)),
+	}
+	if len(items) > prunePreviewLimit {
+		preview.Items = items[:prunePreviewLimit]
+		preview.Truncated = true
+	}
+	if len(items) == 0 {
+		return preview, nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.pruneLocked()
+
+	token := uuid.New().String()
+	expiresAt := time.Now().Add(pruneConfirmationTTL)
+	m.confirmations[token] = &pruneConfirmation{
+		fingerprint:       req.fingerprint(items),
+		deleteSourceFiles: req.DeleteSourceFiles,
+		expiresAt:         expiresAt,
+	}
+	preview.ConfirmationToken = token
+	preview.ExpiresAt = &expiresAt
+	return preview, nil
+}
+
+// Start consumes a confirmation token and starts deleting the records it was
+// issued for. The selection is loaded again and must still match the preview.
+func (m *pruneManager) Start(req *PruneRequest) (*PruneJob, apierror.Code, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.pruneLocked()
+
+	confirmation, exists := m.confirmations[req.ConfirmationToken]
+	if !exists {
+		return nil, apierror.CodePruneConfirmationInvalid, errors.New("confirmation token is unknown or expired, run a dry run again")
+	}
+	if m.running != "" {
+		return nil, apierror.CodePruneInProgress, errors.New("another prune job is still running")
+	}
+
+	items, err := selectPruneItems(req)
+	if err != nil {
+		return nil, apierror.CodeInternal, err
+	}
+	if req.fingerprint(items) != confirmation.fingerprint || req.DeleteSourceFiles != confirmation.deleteSourceFiles {
+		return nil, apierror.CodePruneConfirmationInvalid, errors.New("the selection changed since the dry run, run a dry run again")
+	}
+
+	trash := TrashRetention() > 0
+	if trash {
+		mediaHubDB, err := GetDatabaseConnection()
+		if err == nil {
+			err = ensureTrashTable(mediaHubDB)
+		}
+		if err != nil {
+			return nil, apierror.CodeInternal, err
+		}
+	}
+
+	// The token stays valid when the prune is refused, so it can be retried
+	// once the blocking job is done
+	jobID := uuid.New().String()
+	lease, err := AcquireOperation(OperationPrune, jobID, pruneLibraries(items))
+	if err != nil {
+		return nil, apierror.CodeOperationConflict, err
+	}
+	delete(m.confirmations, req.ConfirmationToken)
+
+	job := &PruneJob{
+		ID:                jobID,
+		Status:            PruneStatusRunning,
+		DeleteSourceFiles: confirmation.deleteSourceFiles,
+		Trash:             trash,
+		Total:             len(items),
+		StartedAt:         time.Now(),
+	}
+	batchID, err := StartOperationBatch("prune")
+	if err != nil {
+		logger.Warn("Failed to start operation batch: %v", err)
+	}
+	job.BatchID = batchID
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.jobs[job.ID] = job
+	m.running = job.ID
+	m.cancel = cancel
+
+	go func() {
+		defer lease.Release()
+		m.run(ctx, job, items)
+	}()
+	return job.snapshot(), "", nil
+}
+
+// pruneLibraries returns the source directories holding the selected records,
+// the scope a prune registers with the operation registry. A record outside
+// every source directory adds its own directory.
+func pruneLibraries(items []PruneItem) []string {
+	sourceDirs := SourceDirectories()
+	seen := make(map[string]bool)
+	var libraries []string
+	for _, item := range items {
+		library := filepath.Dir(filepath.Clean(item.SourcePath))
+		for _, dir := range sourceDirs {
+			if isWithinDir(filepath.Clean(dir), item.SourcePath) {
+				library = filepath.Clean(dir)
+				break
+			}
+		}
+		if !seen[library] {
+			seen[library] = true
+			libraries = append(libraries, library)
+		}
+	}
+	return libraries
+}
+
+// Cancel stops a running prune after the current record
+func (m *pruneManager) Cancel(id string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.running == "" || m.running != id {
+		return false
+	}
+	m.cancel()
+	return true
+}
+
+// Job returns a prune job by id
+func (m *pruneManager) Job(id string) (*PruneJob, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	job, exists := m.jobs[id]
+	if !exists {
+		return nil, false
+	}
+	return job.snapshot(), true
+}
+
+// pruneLocked forgets expired tokens and jobs finished more than
+// finishedPruneRetention ago
+func (m *pruneManager) pruneLocked() {
+	now := time.Now()
+	for token, confirmation := range m.confirmations {
+		if now.After(confirmation.expiresAt) {
+			delete(m.confirmations, token)
+		}
+	}
+	for id, job := range m.jobs {
+		if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > finishedPruneRetention {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+func (job *PruneJob) snapshot() *PruneJob {
+	copied := *job
+	return &copied
+}
+
+// run deletes the records one by one until done or cancelled
+func (m *pruneManager) run(ctx context.Context, job *PruneJob, items []PruneItem) {
+	logger.Info("Starting prune %s of %d records (delete source files: %t, trash: %t)", job.ID, len(items), job.DeleteSourceFiles, job.Trash)
+
+	retryPolicy := GetRetryPolicy()
+	status := PruneStatusCompleted
+	for _, item := range items {
+		if ctx.Err() != nil {
+			status = PruneStatusCancelled
+			break
+		}
+
+		// The trash location is picked once, so a retry finds the symlink an
+		// earlier attempt already moved there
+		trashPath := ""
+		if job.Trash {
+			trashPath = newTrashPath(item)
+		}
+
+		// Each attempt skips what earlier attempts already removed, so the
+		// outcomes add up
+		var result pruneResult
+		retries, err := retryPolicy.Run(ctx, func() error {
+			attempt := pruneItem(item, job.DeleteSourceFiles, trashPath)
+			result.linkRemoved = result.linkRemoved || attempt.linkRemoved
+			result.sourceRemoved = result.sourceRemoved || attempt.sourceRemoved
+			result.recordRemoved = result.recordRemoved || attempt.recordRemoved
+			return attempt.err
+		})
+		result.err = ClassifyPermissionError(err)
+		if err != nil && retries > 0 {
+			result.err = fmt.Errorf("%w (after %d retries)", result.err, retries)
+		}
+
+		m.mutex.Lock()
+		job.Processed++
+		job.Retries += retries
+		if result.linkRemoved {
+			job.LinksRemoved++
+		}
+		if result.sourceRemoved {
+			job.SourcesRemoved++
+		}
+		if result.recordRemoved {
+			job.RecordsRemoved++
+		}
+		if result.err != nil {
+			job.Failed++
+		}
+		m.mutex.Unlock()
+
+		if job.BatchID != "" {
+			resultStatus, reason := OperationResultSuccess, ""
+			if result.err != nil {
+				resultStatus, reason = OperationResultFailed, result.err.Error()
+			}
+			if err := RecordOperationResultWithRetries(job.BatchID, item.SourcePath, item.DestinationPath, resultStatus, reason, retries); err != nil {
+				logger.Warn("Failed to record result for batch %s: %v", job.BatchID, err)
+			}
+		}
+	}
+
+	if job.BatchID != "" {
+		if err := CompleteOperationBatch(job.BatchID, status); err != nil {
+			logger.Warn("Failed to complete batch %s: %v", job.BatchID, err)
+		}
+	}
+
+	finishedAt := time.Now()
+	m.mutex.Lock()
+	job.Status = status
+	job.FinishedAt = &finishedAt
+	m.running = ""
+	m.cancel()
+	m.cancel = nil
+	m.mutex.Unlock()
+
+	logger.Info("Prune %s %s: %d records, %d symlinks, %d source files removed, %d failed",
+		job.ID, status, job.RecordsRemoved, job.LinksRemoved, job.SourcesRemoved, job.Failed)
+
+	NotifyLibraryChanged()
+	NotifyFileOperationChanged()
+}
+
+// pruneResult is the outcome of pruning one record
+type pruneResult struct {
+	linkRemoved   bool
+	sourceRemoved bool
+	recordRemoved bool
+	err           error
+}
+
+// destinationRoots returns DESTINATION_DIR and the destinations of the
+// DESTINATION_ROUTES rules ("conditions => destination" separated by
+// semicolons), the directories MediaHub places links in
+func destinationRoots() []string {
+	var roots []string
+	if destDir := env.GetString("DESTINATION_DIR", ""); destDir != "" {
+		roots = append(roots, filepath.Clean(destDir))
+	}
+	for _, rule := range strings.Split(env.GetString("DESTINATION_ROUTES", ""), ";") {
+		if _, destination, ok := strings.Cut(rule, "=>"); ok && strings.TrimSpace(destination) != "" {
+			roots = append(roots, filepath.Clean(strings.TrimSpace(destination)))
+		}
+	}
+	return roots
+}
+
+// destinationRoot returns the destination root holding path, the longest
+// when roots nest, or "" when path lies outside every root
+func destinationRoot(path string) string {
+	root := ""
+	for _, candidate := range destinationRoots() {
+		if isWithinDir(candidate, filepath.Clean(path)) && len(candidate) > len(root) {
+			root = candidate
+		}
+	}
+	return root
+}
+
+// withinSourceDir reports whether path lies inside one of the configured
+// source directories
+func withinSourceDir(path string) bool {
+	for _, dir := range SourceDirectories() {
+		if isWithinDir(filepath.Clean(dir), filepath.Clean(path)) {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneItem removes the destination symlink or .strm file of a record, the
+// source file when asked to, and then the record. Any other destination, or
+// one outside the destination roots, is left alone and keeps its record, since
+// deleting it would destroy real media or files MediaHub does not own. The
+// same holds for a source file outside the source directories.
+// With a trashPath the link is moved there and the record is kept in the
+// trash instead of being deleted outright.
+func pruneItem(item PruneItem, deleteSourceFiles bool, trashPath string) pruneResult {
+	var result pruneResult
+
+	if item.DestinationPath != "" {
+		destRoot := destinationRoot(item.DestinationPath)
+		info, err := os.Lstat(item.DestinationPath)
+		switch {
+		case err == nil && destRoot == "":
+			result.err = Permanent(errors.New("destination is outside DESTINATION_DIR"))
+			return result
+		case err == nil && info.Mode()&os.ModeSymlink == 0 && !strm.IsFile(item.DestinationPath, info):
+			result.err = Permanent(errors.New("destination is not a symlink or .strm file"))
+			return result
+		case err == nil && trashPath != "":
+			if err := moveLink(item.DestinationPath, trashPath); err != nil {
+				result.err = fmt.Errorf("failed to move link to trash: %w", err)
+				return result
+			}
+			result.linkRemoved = true
+			removeEmptyParents(filepath.Dir(item.DestinationPath), destRoot)
+		case err == nil:
+			if err := os.Remove(item.DestinationPath); err != nil {
+				result.err = fmt.Errorf("failed to remove link: %w", err)
+				return result
+			}
+			result.linkRemoved = true
+			removeEmptyParents(filepath.Dir(item.DestinationPath), destRoot)
+		case !os.IsNotExist(err):
+			result.err = fmt.Errorf("failed to inspect destination: %w", err)
+			return result
+		}
+	}
+
+	if deleteSourceFiles {
+		info, err := os.Lstat(item.SourcePath)
+		switch {
+		case err == nil && !withinSourceDir(item.SourcePath):
+			result.err = Permanent(errors.New("source is outside SOURCE_DIR"))
+			return result
+		case err == nil && !info.Mode().IsRegular():
+			result.err = Permanent(errors.New("source is not a regular file"))
+			return result
+		case err == nil:
+			if err := os.Remove(item.SourcePath); err != nil {
+				result.err = fmt.Errorf("failed to remove source file: %w", err)
+				return result
+			}
+			result.sourceRemoved = true
+		case !os.IsNotExist(err):
+			result.err = fmt.Errorf("failed to inspect source file: %w", err)
+			return result
+		}
+	}
+
+	// The cache lookup reads the record, so it has to happen before the delete
+	RemoveFolderFromCacheFromDB(item.DestinationPath, item.TmdbID)
+
+	err := WithDatabaseTransaction(func(tx *sql.Tx) error {
+		if trashPath != "" {
+			if err := trashRecordTx(tx, item, trashPath); err != nil {
+				return fmt.Errorf("failed to move record to trash: %w", err)
+			}
+		}
+		_, err := tx.Exec(`DELETE FROM processed_files WHERE file_path = ?`, item.SourcePath)
+		return err
+	})
+	if err != nil {
+		result.err = fmt.Errorf("failed to remove database record: %w", err)
+		return result
+	}
+	result.recordRemoved = true
+
+	if err := TrackFileDeletion(item.SourcePath, item.DestinationPath, item.TmdbID, item.SeasonNumber, "Pruned from library"); err != nil {
+		logger.Warn("Failed to track pruned file %s: %v", item.SourcePath, err)
+	}
+	return result
+}
+
+// removeEmptyParents removes dir and its parents while they are empty,
+// stopping at the destination directory
+func removeEmptyParents(dir, destDir string) {
+	if destDir == "" {
+		return
+	}
+	destDir = filepath.Clean(destDir)
+	for dir = filepath.Clean(dir); dir != destDir && isWithinDir(destDir, dir); dir = filepath.Dir(dir) {
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) > 0 {
+			return
+		}
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		logger.Debug("Removed empty directory: %s", dir)
+	}
+}
+
+// HandleDatabasePrune serves /api/database/prune. POST with dryRun previews the
+// selected records and returns a confirmation token; POST with that token
+// starts deleting their symlinks and records as a job, answering 202. GET ?id=
+// reports a job and DELETE ?id= cancels it. Only administrators may prune.
+func HandleDatabasePrune(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodPost, http.MethodGet, http.MethodDelete) {
+		return
+	}
+	if !auth.RequireAdmin(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		job, exists := prunes.Job(r.URL.Query().Get("id"))
+		if !exists {
+			apierror.WriteError(w, http.StatusNotFound, apierror.CodeNotFound, "Prune job not found")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job)
+		return
+	case http.MethodDelete:
+		if !prunes.Cancel(r.URL.Query().Get("id")) {
+			apierror.WriteError(w, http.StatusNotFound, apierror.CodeNotFound, "No running prune job with that id")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "cancelling"})
+		return
+	}
+
+	var req PruneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if err := req.validate(); err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	if req.DryRun {
+		preview, err := prunes.Preview(&req)
+		if err != nil {
+			logger.Error("Failed to select records to prune: %v", err)
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to select records")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(preview)
+		return
+	}
+
+	if req.ConfirmationToken == "" {
+		apierror.WriteError(w, http.StatusPreconditionRequired, apierror.CodePruneConfirmationRequired,
+			"Run a dry run first and pass its confirmationToken to delete")
+		return
+	}
+
+	job, code, err := prunes.Start(&req)
+	if err != nil {
+		switch code {
+		case apierror.CodePruneConfirmationInvalid:
+			apierror.WriteError(w, http.StatusPreconditionFailed, code, err.Error())
+		case apierror.CodePruneInProgress:
+			apierror.WriteError(w, http.StatusConflict, code, err.Error())
+		case apierror.CodeOperationConflict:
+			WriteOperationConflict(w, err)
+		default:
+			logger.Error("Failed to start prune: %v", err)
+			apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to start prune")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
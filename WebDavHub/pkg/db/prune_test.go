@@ -0,0 +1,26 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPruneKeepsSourceFilesOutsideSourceDir(t *testing.T) {
+	t.Setenv("SOURCE_DIR", t.TempDir())
+	outside := filepath.Join(t.TempDir(), "important.conf")
+	if err := os.WriteFile(outside, []byte("keep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result := pruneItem(PruneItem{SourcePath: outside}, true, "")
+	if result.err == nil || IsRetryable(result.err) {
+		t.Fatalf("pruneItem error = %v, want a permanent refusal", result.err)
+	}
+	if result.sourceRemoved || result.recordRemoved {
+		t.Fatalf("result = %+v, want nothing removed", result)
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Fatalf("file outside SOURCE_DIR was deleted: %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sync"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+)
+
+const defaultReadPoolSize = 4
+
+var (
+	readPool     *sql.DB
+	readPoolOnce sync.Once
+	readPoolMux  sync.RWMutex
+)
+
+// GetReadConnection returns the read-only pool on the MediaHub database. In
+// WAL mode its connections read from their own snapshot, so dashboard queries
+// do not wait for the write queue or an open write transaction. Only use it
+// for queries; it falls back to the main pool when the read pool cannot be
+// opened.
+func GetReadConnection() (*sql.DB, error) {
+	readPoolOnce.Do(func() {
+		// The main pool creates the database and switches it to WAL, which a
+		// read-only connection cannot do itself
+		if _, err := GetDatabaseConnection(); err != nil {
+			return
+		}
+
+		mediaHubDBPath, err := filepath.Abs(filepath.Join("..", "db", "processed_files.db"))
+		if err != nil {
+			logger.Warn("Read pool disabled, cannot resolve database path: %v", err)
+			return
+		}
+
+		db, err := sql.Open("sqlite", buildReadOnlyConnectionString(mediaHubDBPath))
+		if err == nil {
+			err = db.Ping()
+		}
+		if err != nil {
+			logger.Warn("Read pool disabled, falling back to the main pool: %v", err)
+			if db != nil {
+				db.Close()
+			}
+			return
+		}
+
+		size := readPoolSize()
+		config := GetDefaultDatabaseConfig()
+		db.SetMaxOpenConns(size)
+		db.SetMaxIdleConns(size)
+		db.SetConnMaxLifetime(config.ConnMaxLifetime)
+
+		readPoolMux.Lock()
+		readPool = db
+		readPoolMux.Unlock()
+		logger.Info("MediaHub read pool initialized with %d connections", size)
+	})
+
+	readPoolMux.RLock()
+	pool := readPool
+	readPoolMux.RUnlock()
+
+	if pool == nil {
+		return GetDatabaseConnection()
+	}
+	return pool, nil
+}
+
+// buildReadOnlyConnectionString opens the database with mode=ro and
+// query_only, so a write sent to the read pool fails instead of competing
+// with the writer
+func buildReadOnlyConnectionString(dbPath string) string {
+	config := GetDefaultDatabaseConfig()
+	params := url.Values{}
+	params.Set("mode", "ro")
+	for _, pragma := range []string{
+		"busy_timeout(" + config.BusyTimeout + ")",
+		"query_only(1)",
+		"cache_size(" + config.CacheSize + ")",
+		"temp_store(" + config.TempStore + ")",
+		"mmap_size(134217728)",
+	} {
+		params.Add("_pragma", pragma)
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(dbPath), RawQuery: params.Encode()}).String()
+}
+
+// readPoolSize returns the number of read connections from DB_READ_POOL_SIZE
+func readPoolSize() int {
+	size := env.GetInt("DB_READ_POOL_SIZE", defaultReadPoolSize)
+	if size < 1 {
+		logger.Warn("Invalid DB_READ_POOL_SIZE %d, using %d", size, defaultReadPoolSize)
+		size = defaultReadPoolSize
+	}
+	return size
+}
+
+// CloseReadPool closes the read-only pool
+func CloseReadPool() {
+	readPoolMux.Lock()
+	defer readPoolMux.Unlock()
+
+	if readPool != nil {
+		readPool.Close()
+		readPool = nil
+		logger.Info("Database read pool closed")
+	}
+}
+
+// PoolStats describes one connection pool
+type PoolStats struct {
+	MaxOpenConnections int   `json:"maxOpenConnections"`
+	OpenConnections    int   `json:"openConnections"`
+	InUse              int   `json:"inUse"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"waitCount"`
+	WaitDurationMs     int64 `json:"waitDurationMs"`
+}
+
+// DatabasePoolStats is the response of GET /api/database/pool-stats
+type DatabasePoolStats struct {
+	Writer          *PoolStats `json:"writer"`
+	Reader          *PoolStats `json:"reader"`
+	WriteQueueDepth int        `json:"writeQueueDepth"`
+}
+
+func newPoolStats(db *sql.DB) *PoolStats {
+	stats := db.Stats()
+	return &PoolStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDurationMs:     stats.WaitDuration.Milliseconds(),
+	}
+}
+
+// GetDatabasePoolStats returns the state of the main and read pools. A pool
+// that is not open is left out.
+func GetDatabasePoolStats() DatabasePoolStats {
+	var stats DatabasePoolStats
+
+	dbPoolMux.RLock()
+	if dbPool != nil {
+		stats.Writer = newPoolStats(dbPool)
+	}
+	dbPoolMux.RUnlock()
+
+	readPoolMux.RLock()
+	if readPool != nil {
+		stats.Reader = newPoolStats(readPool)
+	}
+	readPoolMux.RUnlock()
+
+	if dbWriteQueue != nil {
+		stats.WriteQueueDepth = len(dbWriteQueue)
+	}
+	return stats
+}
+
+// HandleDatabasePoolStats serves GET /api/database/pool-stats
+func HandleDatabasePoolStats(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(GetDatabasePoolStats())
+}
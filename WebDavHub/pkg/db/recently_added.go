@@ -0,0 +1,104 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+// processedAtLayout is how MediaHub stores processed_at, datetime('now') in UTC
+const processedAtLayout = "2006-01-02 15:04:05"
+
+// RecentlyAddedFilter narrows the recently added feed to one library, the
+// base_path MediaHub files a title under, and to "movie" or "tv"
+type RecentlyAddedFilter struct {
+	Library   string
+	MediaType string
+}
+
+// RecentlyAddedTitle is a title with the time its newest file was processed
+type RecentlyAddedTitle struct {
+	TmdbID    string    `json:"tmdbId,omitempty"`
+	Title     string    `json:"title"`
+	Year      string    `json:"year,omitempty"`
+	MediaType string    `json:"mediaType"`
+	Library   string    `json:"library,omitempty"`
+	AddedAt   time.Time `json:"addedAt"`
+}
+
+// where returns the condition and arguments selecting the processed files
+// the filter covers
+func (f RecentlyAddedFilter) where() (string, []interface{}) {
+	conditions := []string{"processed_at IS NOT NULL", "COALESCE(destination_path, '') != ''"}
+	var args []interface{}
+	if f.Library != "" {
+		conditions = append(conditions, "base_path = ?")
+		args = append(args, f.Library)
+	}
+	switch strings.ToLower(f.MediaType) {
+	case "movie":
+		conditions = append(conditions, "LOWER(media_type) = 'movie'")
+	case "tv":
+		conditions = append(conditions, "LOWER(media_type) IN ('tv', 'show', 'tvshow')")
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// recentlyAddedKey identifies the title of a processed file: its TMDB id when
+// it has one, else its name and year
+const recentlyAddedKey = `CASE WHEN COALESCE(tmdb_id, '') != '' THEN LOWER(COALESCE(media_type, '')) || ':' || tmdb_id
+	ELSE LOWER(COALESCE(proper_name, '')) || ':' || COALESCE(year, '') END`
+
+// ListRecentlyAdded returns one window of the titles covered by filter, most
+// recently processed first, and the number of such titles. The files are read
+// newest first along the processed_at index and stop once the window is
+// filled, so the newest pages stay cheap on large libraries.
+func ListRecentlyAdded(filter RecentlyAddedFilter, limit, offset int) ([]RecentlyAddedTitle, int, error) {
+	mediaHubDB, err := GetReadConnection()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	where, args := filter.where()
+	var total int
+	if err := mediaHubDB.QueryRow(`SELECT COUNT(DISTINCT `+recentlyAddedKey+`) FROM processed_files WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	if offset >= total {
+		return []RecentlyAddedTitle{}, total, nil
+	}
+
+	rows, err := mediaHubDB.Query(`
+		SELECT `+recentlyAddedKey+`, COALESCE(tmdb_id, ''), COALESCE(proper_name, ''), COALESCE(year, ''),
+			LOWER(COALESCE(media_type, '')), COALESCE(base_path, ''), processed_at
+		FROM processed_files
+		WHERE `+where+`
+		ORDER BY processed_at DESC`, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	titles := make([]RecentlyAddedTitle, 0, limit)
+	for rows.Next() && len(titles) < limit {
+		var key, processedAt string
+		var title RecentlyAddedTitle
+		if err := rows.Scan(&key, &title.TmdbID, &title.Title, &title.Year, &title.MediaType, &title.Library, &processedAt); err != nil {
+			return nil, 0, err
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if len(seen) <= offset {
+			continue
+		}
+
+		if title.MediaType == "show" || title.MediaType == "tvshow" {
+			title.MediaType = "tv"
+		}
+		title.AddedAt, _ = time.Parse(processedAtLayout, processedAt)
+		titles = append(titles, title)
+	}
+	return titles, total, rows.Err()
+}
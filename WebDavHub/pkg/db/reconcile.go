@@ -0,0 +1,532 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/logger"
+	"cinesync/pkg/strm"
+)
+
+// Discrepancy kinds reported by a reconciliation, also the names of the fixes
+// POST /api/maintenance/reconcile accepts
+const (
+	// ReconcileStaleRecord is a processed_files record whose source file is gone
+	ReconcileStaleRecord = "stale_record"
+	// ReconcileMissingLink is a record whose source exists but whose
+	// destination symlink or .strm file does not
+	ReconcileMissingLink = "missing_link"
+	// ReconcileUntrackedFile is a media file in a source directory that is in
+	// neither source_files nor processed_files
+	ReconcileUntrackedFile = "untracked_file"
+)
+
+// Outcomes of fixing a discrepancy
+const (
+	ReconcileFixed  = "fixed"
+	ReconcileFailed = "failed"
+)
+
+// reconcileListLimit caps the discrepancies of each kind listed in a status;
+// the counts always cover all of them
+const reconcileListLimit = 500
+
+var reconcileKinds = map[string]bool{
+	ReconcileStaleRecord:   true,
+	ReconcileMissingLink:   true,
+	ReconcileUntrackedFile: true,
+}
+
+// ReconcileDiscrepancy is one difference between the database and the disk
+type ReconcileDiscrepancy struct {
+	Kind            string `json:"kind"`
+	SourcePath      string `json:"sourcePath"`
+	DestinationPath string `json:"destinationPath,omitempty"`
+	// Status is ReconcileFixed or ReconcileFailed once a fix was attempted
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+
+	tmdbID       string
+	seasonNumber string
+}
+
+// ReconcileStatus reports the last reconciliation. Counts has the number of
+// discrepancies of each kind; Discrepancies lists at most reconcileListLimit
+// of each.
+type ReconcileStatus struct {
+	ID            string                 `json:"id,omitempty"`
+	Running       bool                   `json:"running"`
+	Phase         string                 `json:"phase,omitempty"`
+	Fix           []string               `json:"fix"`
+	Checked       int                    `json:"checked"`
+	Counts        map[string]int         `json:"counts"`
+	Discrepancies []ReconcileDiscrepancy `json:"discrepancies"`
+	Truncated     bool                   `json:"truncated"`
+	// UnavailableSources are source directories that could not be read, whose
+	// records are not reported as stale
+	UnavailableSources []string   `json:"unavailableSources,omitempty"`
+	Fixed              int        `json:"fixed"`
+	Failed             int        `json:"failed"`
+	Error              string     `json:"error,omitempty"`
+	StartedAt          *time.Time `json:"startedAt,omitempty"`
+	FinishedAt         *time.Time `json:"finishedAt,omitempty"`
+	Cancelled          bool       `json:"cancelled,omitempty"`
+}
+
+// ReconcileRequest is the body of POST /api/maintenance/reconcile. Fix names
+// the discrepancy kinds to repair; without it the run only reports.
+type ReconcileRequest struct {
+	Fix []string `json:"fix"`
+}
+
+var (
+	reconcileMu     sync.Mutex
+	reconcileStatus = ReconcileStatus{Fix: []string{}, Counts: map[string]int{}, Discrepancies: []ReconcileDiscrepancy{}}
+	reconcileCancel context.CancelFunc
+)
+
+// reconcileRecord is the part of a processed_files record reconciliation checks
+type reconcileRecord struct {
+	sourcePath      string
+	destinationPath string
+	tmdbID          string
+	seasonNumber    string
+}
+
+// snapshotReconcileStatus copies the status so it can be encoded without the lock
+func snapshotReconcileStatus() ReconcileStatus {
+	reconcileMu.Lock()
+	defer reconcileMu.Unlock()
+	status := reconcileStatus
+	status.Fix = append([]string{}, reconcileStatus.Fix...)
+	status.Discrepancies = append([]ReconcileDiscrepancy{}, reconcileStatus.Discrepancies...)
+	status.UnavailableSources = append([]string(nil), reconcileStatus.UnavailableSources...)
+	status.Counts = make(map[string]int, len(reconcileStatus.Counts))
+	for kind, count := range reconcileStatus.Counts {
+		status.Counts[kind] = count
+	}
+	return status
+}
+
+// addReconcileDiscrepancy counts a discrepancy and lists it while its kind is
+// below the list limit
+func addReconcileDiscrepancy(d ReconcileDiscrepancy) {
+	reconcileMu.Lock()
+	defer reconcileMu.Unlock()
+	reconcileStatus.Counts[d.Kind]++
+	if reconcileStatus.Counts[d.Kind] > reconcileListLimit {
+		reconcileStatus.Truncated = true
+		return
+	}
+	reconcileStatus.Discrepancies = append(reconcileStatus.Discrepancies, d)
+}
+
+// recordReconcileFix stores the outcome of fixing a discrepancy
+func recordReconcileFix(d ReconcileDiscrepancy, err error) {
+	reconcileMu.Lock()
+	defer reconcileMu.Unlock()
+	status, message := ReconcileFixed, ""
+	if err != nil {
+		status, message = ReconcileFailed, ClassifyPermissionError(err).Error()
+		reconcileStatus.Failed++
+	} else {
+		reconcileStatus.Fixed++
+	}
+	for i := range reconcileStatus.Discrepancies {
+		listed := &reconcileStatus.Discrepancies[i]
+		if listed.Kind == d.Kind && listed.SourcePath == d.SourcePath {
+			listed.Status, listed.Error = status, message
+			return
+		}
+	}
+}
+
+// errReconcileRunning is returned when a reconciliation is already running
+var errReconcileRunning = errors.New("a reconciliation is already running")
+
+// StartReconcile starts a reconciliation repairing the given kinds of
+// discrepancies. It fails when one is already running, or with an
+// OperationConflictError when a scan, prune or reidentification is.
+func StartReconcile(fix []string) error {
+	reconcileMu.Lock()
+	defer reconcileMu.Unlock()
+	if reconcileStatus.Running {
+		return errReconcileRunning
+	}
+	id := uuid.New().String()
+	lease, err := AcquireOperation(OperationReconcile, id, nil)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(withOperationLease(context.Background(), lease))
+	now := time.Now()
+	reconcileStatus = ReconcileStatus{
+		ID:            id,
+		Running:       true,
+		Phase:         "detecting",
+		Fix:           append([]string{}, fix...),
+		Counts:        map[string]int{},
+		Discrepancies: []ReconcileDiscrepancy{},
+		StartedAt:     &now,
+	}
+	reconcileCancel = cancel
+
+	go func() {
+		defer lease.Release()
+		runReconcile(ctx, fix)
+	}()
+	return nil
+}
+
+// runReconcile detects the discrepancies and then fixes the requested kinds
+func runReconcile(ctx context.Context, fix []string) {
+	logger.Info("Starting reconciliation (fix: %v)", fix)
+
+	discrepancies, err := detectDiscrepancies(ctx)
+	if err == nil && len(fix) > 0 {
+		reconcileMu.Lock()
+		reconcileStatus.Phase = "fixing"
+		reconcileMu.Unlock()
+		err = fixDiscrepancies(ctx, discrepancies, fix)
+	}
+
+	finishedAt := time.Now()
+	reconcileMu.Lock()
+	reconcileStatus.Running = false
+	reconcileStatus.Phase = ""
+	reconcileStatus.FinishedAt = &finishedAt
+	if errors.Is(err, context.Canceled) {
+		reconcileStatus.Cancelled = true
+	} else if err != nil {
+		reconcileStatus.Error = err.Error()
+	}
+	reconcileCancel()
+	reconcileCancel = nil
+	status := reconcileStatus
+	reconcileMu.Unlock()
+
+	if err != nil && !errors.Is(err, context.Canceled) {
+		logger.Error("Reconciliation failed: %v", err)
+		return
+	}
+	outcome := "completed"
+	if status.Cancelled {
+		outcome = "cancelled"
+	}
+	logger.Info("Reconciliation %s: %d records checked, discrepancies %v, %d fixed, %d failed",
+		outcome, status.Checked, status.Counts, status.Fixed, status.Failed)
+	if status.Fixed > 0 {
+		NotifyLibraryChanged()
+		NotifyFileOperationChanged()
+	}
+}
+
+// detectDiscrepancies compares the processed_files records with their source
+// and destination, then walks the source directories for media files neither
+// database knows. Records under a source directory that cannot be read or is
+// empty are skipped, so an unmounted drive does not make its library look
+// stale.
+func detectDiscrepancies(ctx context.Context) ([]ReconcileDiscrepancy, error) {
+	records, err := loadReconcileRecords()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load processed files: %w", err)
+	}
+
+	sourceDirs := SourceDirectories()
+	available := make(map[string]bool, len(sourceDirs))
+	var unavailable []string
+	for _, dir := range sourceDirs {
+		if entries, err := readDirNames(dir); err == nil && len(entries) > 0 {
+			available[dir] = true
+		} else {
+			unavailable = append(unavailable, dir)
+		}
+	}
+	reconcileMu.Lock()
+	reconcileStatus.UnavailableSources = unavailable
+	reconcileMu.Unlock()
+
+	sourceAvailable := func(path string) bool {
+		for _, dir := range sourceDirs {
+			if isWithinDir(filepath.Clean(dir), path) {
+				return available[dir]
+			}
+		}
+		return true
+	}
+
+	var discrepancies []ReconcileDiscrepancy
+	add := func(d ReconcileDiscrepancy) {
+		discrepancies = append(discrepancies, d)
+		addReconcileDiscrepancy(d)
+	}
+
+	tracked := make(map[string]bool, len(records))
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		tracked[record.sourcePath] = true
+
+		if _, err := os.Stat(record.sourcePath); os.IsNotExist(err) {
+			if sourceAvailable(record.sourcePath) {
+				add(ReconcileDiscrepancy{
+					Kind:            ReconcileStaleRecord,
+					SourcePath:      record.sourcePath,
+					DestinationPath: record.destinationPath,
+					tmdbID:          record.tmdbID,
+					seasonNumber:    record.seasonNumber,
+				})
+			}
+		} else if err == nil && record.destinationPath != "" {
+			if _, err := os.Lstat(record.destinationPath); os.IsNotExist(err) {
+				add(ReconcileDiscrepancy{Kind: ReconcileMissingLink, SourcePath: record.sourcePath, DestinationPath: record.destinationPath})
+			}
+		}
+
+		reconcileMu.Lock()
+		reconcileStatus.Checked++
+		reconcileMu.Unlock()
+	}
+
+	err = executeReadOperation(func(sourceDB *sql.DB) error {
+		rows, err := sourceDB.Query(`SELECT file_path FROM source_files WHERE is_active = TRUE`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var path string
+			if err := rows.Scan(&path); err != nil {
+				return err
+			}
+			tracked[path] = true
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load source files: %w", err)
+	}
+
+	filter := loadScanFilter()
+	walker := newSourceWalker()
+	for _, dir := range sourceDirs {
+		if !available[dir] {
+			continue
+		}
+		err := walker.walk(dir, dir, func(path string, info os.FileInfo, err error) error {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if err != nil || info.IsDir() || !isMediaFile(path) {
+				return nil
+			}
+			if filter.exclusionReason(path, info) != "" || tracked[path] {
+				return nil
+			}
+			add(ReconcileDiscrepancy{Kind: ReconcileUntrackedFile, SourcePath: path})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return discrepancies, nil
+}
+
+// loadReconcileRecords reads the source and destination of every record
+func loadReconcileRecords() ([]reconcileRecord, error) {
+	mediaHubDB, err := GetDatabaseConnection()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := mediaHubDB.Query(`
+		SELECT file_path, COALESCE(destination_path, ''), COALESCE(tmdb_id, ''), COALESCE(season_number, '')
+		FROM processed_files
+		ORDER BY file_path`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []reconcileRecord
+	for rows.Next() {
+		var record reconcileRecord
+		if err := rows.Scan(&record.sourcePath, &record.destinationPath, &record.tmdbID, &record.seasonNumber); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// fixDiscrepancies repairs the discrepancies of the kinds in fix: stale
+// records are pruned like POST /api/database/prune does, keeping them in the
+// trash when it is enabled, missing links are recreated, and the directories
+// holding untracked files are scanned in.
+func fixDiscrepancies(ctx context.Context, discrepancies []ReconcileDiscrepancy, fix []string) error {
+	fixKinds := make(map[string]bool, len(fix))
+	for _, kind := range fix {
+		fixKinds[kind] = true
+	}
+
+	trash := TrashRetention() > 0
+	if trash && fixKinds[ReconcileStaleRecord] {
+		mediaHubDB, err := GetDatabaseConnection()
+		if err == nil {
+			err = ensureTrashTable(mediaHubDB)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to prepare trash: %w", err)
+		}
+	}
+
+	// Untracked files are scanned in per directory, after the other fixes
+	untrackedDirs := make(map[string][]ReconcileDiscrepancy)
+	for _, d := range discrepancies {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !fixKinds[d.Kind] {
+			continue
+		}
+		switch d.Kind {
+		case ReconcileStaleRecord:
+			item := PruneItem{SourcePath: d.SourcePath, DestinationPath: d.DestinationPath, TmdbID: d.tmdbID, SeasonNumber: d.seasonNumber}
+			trashPath := ""
+			if trash {
+				trashPath = newTrashPath(item)
+			}
+			recordReconcileFix(d, pruneItem(item, false, trashPath).err)
+		case ReconcileMissingLink:
+			recordReconcileFix(d, recreateLink(d.SourcePath, d.DestinationPath))
+		case ReconcileUntrackedFile:
+			dir := filepath.Dir(d.SourcePath)
+			untrackedDirs[dir] = append(untrackedDirs[dir], d)
+		}
+	}
+
+	dirs := make([]string, 0, len(untrackedDirs))
+	for dir := range untrackedDirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// The scans run under the reconciliation's lease, which they would
+		// otherwise conflict with
+		err := runSourcePathScan(dir, operationLeaseFrom(ctx))
+		for _, d := range untrackedDirs[dir] {
+			recordReconcileFix(d, err)
+		}
+	}
+	return nil
+}
+
+// recreateLink links destination back to source, writing a .strm file for a
+// .strm destination the way MediaHub does. Only a destination inside
+// DESTINATION_DIR is created, and one that appeared since detection is left
+// alone.
+func recreateLink(source, destination string) error {
+	if destinationRoot(destination) == "" {
+		return errors.New("destination is outside DESTINATION_DIR")
+	}
+	if _, err := os.Lstat(destination); err == nil {
+		return errors.New("destination exists")
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to inspect destination: %w", err)
+	}
+	if _, err := os.Stat(source); err != nil {
+		return fmt.Errorf("source is not accessible: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(destination), 0755); err != nil {
+		return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+	if strings.EqualFold(filepath.Ext(destination), strm.Extension) {
+		if err := strm.Write(destination, strm.Content(source)); err != nil {
+			return fmt.Errorf("failed to write .strm file: %w", err)
+		}
+		logger.Info("Recreated missing .strm file %s for %s", destination, source)
+		return nil
+	}
+	if err := os.Symlink(source, destination); err != nil {
+		return fmt.Errorf("failed to create symlink: %w", err)
+	}
+	logger.Info("Recreated missing symlink %s -> %s", destination, source)
+	return nil
+}
+
+// HandleReconcile serves /api/maintenance/reconcile. GET reports the last
+// reconciliation and its discrepancies, POST starts one as a background job,
+// repairing the kinds listed in fix, and DELETE cancels the running one.
+func HandleReconcile(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet, http.MethodPost, http.MethodDelete) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(snapshotReconcileStatus())
+		return
+	case http.MethodDelete:
+		reconcileMu.Lock()
+		cancel := reconcileCancel
+		reconcileMu.Unlock()
+		if cancel == nil {
+			apierror.WriteError(w, http.StatusNotFound, apierror.CodeNotFound, "No reconciliation is running")
+			return
+		}
+		cancel()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var req ReconcileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+	for _, kind := range req.Fix {
+		if !reconcileKinds[kind] {
+			apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Unknown fix: "+kind)
+			return
+		}
+	}
+	if len(req.Fix) > 0 && !auth.RequireAdmin(w, r) {
+		return
+	}
+
+	if err := StartReconcile(req.Fix); errors.Is(err, ErrOperationConflict) {
+		WriteOperationConflict(w, err)
+		return
+	} else if err != nil {
+		apierror.WriteError(w, http.StatusConflict, apierror.CodeReconcileInProgress, "A reconciliation is already running")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(snapshotReconcileStatus())
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReconcileDetectsAndFixesEachDiscrepancy(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		kind string
+		// setup leaves one discrepancy of kind and returns its source path
+		setup func(t *testing.T, mediaHubDB *sql.DB, source, dest string) string
+		// fixed checks the discrepancy is gone once fixed
+		fixed func(t *testing.T, mediaHubDB *sql.DB, path string)
+	}{
+		{
+			name: "stale record",
+			kind: ReconcileStaleRecord,
+			setup: func(t *testing.T, mediaHubDB *sql.DB, source, dest string) string {
+				item := addLinkedRecord(t, mediaHubDB, filepath.Join(source, "Heat.1995.mkv"), filepath.Join(dest, "Heat (1995).mkv"))
+				if err := os.Remove(item.SourcePath); err != nil {
+					t.Fatal(err)
+				}
+				return item.SourcePath
+			},
+			fixed: func(t *testing.T, mediaHubDB *sql.DB, path string) {
+				if recordCount(t, mediaHubDB, path) != 0 {
+					t.Fatal("stale record is still in processed_files")
+				}
+			},
+		},
+		{
+			name: "missing link",
+			kind: ReconcileMissingLink,
+			setup: func(t *testing.T, mediaHubDB *sql.DB, source, dest string) string {
+				item := addLinkedRecord(t, mediaHubDB, filepath.Join(source, "Heat.1995.mkv"), filepath.Join(dest, "Heat (1995).mkv"))
+				if err := os.Remove(item.DestinationPath); err != nil {
+					t.Fatal(err)
+				}
+				return item.SourcePath
+			},
+			fixed: func(t *testing.T, mediaHubDB *sql.DB, path string) {
+				link := filepath.Join(os.Getenv("DESTINATION_DIR"), "Heat (1995).mkv")
+				if target, err := os.Readlink(link); err != nil || target != path {
+					t.Fatalf("recreated link = %q, %v, want %q", target, err, path)
+				}
+			},
+		},
+		{
+			name: "untracked file",
+			kind: ReconcileUntrackedFile,
+			setup: func(t *testing.T, mediaHubDB *sql.DB, source, dest string) string {
+				path := filepath.Join(source, "Heat.1995.mkv")
+				if err := os.WriteFile(path, []byte("media"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return path
+			},
+			fixed: func(t *testing.T, mediaHubDB *sql.DB, path string) {
+				var count int
+				err := executeReadOperation(func(sourceDB *sql.DB) error {
+					return sourceDB.QueryRow(`SELECT COUNT(*) FROM source_files WHERE file_path = ? AND is_active = TRUE`, path).Scan(&count)
+				})
+				if err != nil || count != 1 {
+					t.Fatalf("untracked file is in source_files %d times (%v), want 1", count, err)
+				}
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mediaHubDB := useMediaHubDB(t)
+			source, dest := t.TempDir(), t.TempDir()
+			t.Setenv("SOURCE_DIR", source)
+			t.Setenv("DESTINATION_DIR", dest)
+			t.Setenv("CINESYNC_TRASH_DIR", t.TempDir())
+			t.Setenv("CINESYNC_TRASH_RETENTION_DAYS", "30")
+			// A tracked file keeps the source directory available and
+			// consistent, so the only discrepancy is the one set up
+			addLinkedRecord(t, mediaHubDB, filepath.Join(source, "Ronin.1998.mkv"), filepath.Join(dest, "Ronin (1998).mkv"))
+			path := tt.setup(t, mediaHubDB, source, dest)
+
+			ctx := context.Background()
+			discrepancies, err := detectDiscrepancies(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(discrepancies) != 1 || discrepancies[0].Kind != tt.kind || discrepancies[0].SourcePath != path {
+				t.Fatalf("discrepancies = %+v, want one %s for %s", discrepancies, tt.kind, path)
+			}
+
+			// Fixing other kinds leaves it alone
+			if err := fixDiscrepancies(ctx, discrepancies, nil); err != nil {
+				t.Fatal(err)
+			}
+			if again, err := detectDiscrepancies(ctx); err != nil || len(again) != 1 {
+				t.Fatalf("discrepancies after fixing nothing = %+v, %v", again, err)
+			}
+
+			if err := fixDiscrepancies(ctx, discrepancies, []string{tt.kind}); err != nil {
+				t.Fatal(err)
+			}
+			tt.fixed(t, mediaHubDB, path)
+			if again, err := detectDiscrepancies(ctx); err != nil || len(again) != 0 {
+				t.Fatalf("discrepancies after the fix = %+v, %v", again, err)
+			}
+		})
+	}
+}
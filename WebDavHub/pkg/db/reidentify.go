@@ -0,0 +1,110 @@
+package db
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// IdentifiedFile is a processed file MediaHub linked to a TMDB entry
+type IdentifiedFile struct {
+	FilePath        string
+	DestinationPath string
+	TmdbID          int
+	MediaType       string // "movie" or "tv"
+	ProperName      string
+	Year            string
+}
+
+// ListIdentifiedFiles returns the processed files that have a symlink and a
+// TMDB id. Files whose media type cannot be told apart are left out.
+func ListIdentifiedFiles() ([]IdentifiedFile, error) {
+	mediaHubDB, err := GetReadConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mediaHubDB.Query(`
+		SELECT file_path, destination_path, tmdb_id, LOWER(COALESCE(media_type, '')),
+			COALESCE(season_number, ''), COALESCE(proper_name, ''), COALESCE(year, '')
+		FROM processed_files
+		WHERE tmdb_id IS NOT NULL AND tmdb_id != '' AND destination_path IS NOT NULL AND destination_path != ''`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []IdentifiedFile
+	for rows.Next() {
+		var file IdentifiedFile
+		var tmdbID, mediaType, season string
+		if err := rows.Scan(&file.FilePath, &file.DestinationPath, &tmdbID, &mediaType, &season, &file.ProperName, &file.Year); err != nil {
+			return nil, err
+		}
+		file.TmdbID, err = strconv.Atoi(strings.TrimSpace(tmdbID))
+		if err != nil || file.TmdbID <= 0 {
+			continue
+		}
+		switch {
+		case mediaType == "movie":
+			file.MediaType = "movie"
+		case mediaType == "tv" || mediaType == "show" || (season != "" && season != "NULL"):
+			file.MediaType = "tv"
+		default:
+			continue
+		}
+		files = append(files, file)
+	}
+	return files, rows.Err()
+}
+
+// RecordIdentificationOverride remembers that path, a file or a folder, was
+// matched to tmdbID by hand, so library-wide reidentification leaves it alone
+func RecordIdentificationOverride(path, tmdbID string) error {
+	return executeWriteOperationSync(func(db *sql.DB) error {
+		_, err := db.Exec(`INSERT INTO identification_overrides (path, tmdb_id) VALUES (?, ?)
+			ON CONFLICT(path) DO UPDATE SET tmdb_id = excluded.tmdb_id, created_at = strftime('%s', 'now')`,
+			filepath.Clean(path), tmdbID)
+		return err
+	})
+}
+
+// IdentificationOverrides is the set of paths matched by hand
+type IdentificationOverrides map[string]bool
+
+// GetIdentificationOverrides returns every path matched by hand
+func GetIdentificationOverrides() (IdentificationOverrides, error) {
+	overrides := make(IdentificationOverrides)
+	err := executeReadOperation(func(db *sql.DB) error {
+		rows, err := db.Query(`SELECT path FROM identification_overrides`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var path string
+			if err := rows.Scan(&path); err != nil {
+				return err
+			}
+			overrides[path] = true
+		}
+		return rows.Err()
+	})
+	return overrides, err
+}
+
+// Covers reports whether path or one of its parent folders was matched by hand
+func (o IdentificationOverrides) Covers(path string) bool {
+	path = filepath.Clean(path)
+	for {
+		if o[path] {
+			return true
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return false
+		}
+		path = parent
+	}
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"syscall"
+	"time"
+
+	"cinesync/pkg/env"
+)
+
+// maxRetryBackoff caps the delay between two attempts of a file operation
+const maxRetryBackoff = 30 * time.Second
+
+// RetryPolicy controls how a failing item of a bulk file operation is retried.
+// Only retryable errors are retried, see IsRetryable.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, 1 disables retries
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry, doubling after each one
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts
+	MaxBackoff time.Duration
+}
+
+// GetRetryPolicy returns the file operation retry policy configured through
+// CINESYNC_FILEOP_RETRY_ATTEMPTS and CINESYNC_FILEOP_RETRY_BACKOFF_MS
+func GetRetryPolicy() RetryPolicy {
+	attempts := env.GetInt("CINESYNC_FILEOP_RETRY_ATTEMPTS", 3)
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := env.GetInt("CINESYNC_FILEOP_RETRY_BACKOFF_MS", 500)
+	if backoff < 0 {
+		backoff = 0
+	}
+	return RetryPolicy{
+		MaxAttempts:    attempts,
+		InitialBackoff: time.Duration(backoff) * time.Millisecond,
+		MaxBackoff:     maxRetryBackoff,
+	}
+}
+
+// Run calls op until it succeeds, fails with an error that is not retryable,
+// runs out of attempts or ctx is done. It returns the number of retries made
+// and the last error.
+func (p RetryPolicy) Run(ctx context.Context, op func() error) (int, error) {
+	backoff := p.InitialBackoff
+	retries := 0
+	for {
+		err := op()
+		if err == nil || !IsRetryable(err) || retries+1 >= p.MaxAttempts {
+			return retries, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return retries, err
+		case <-time.After(backoff):
+		}
+		retries++
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
+
+// permanentError marks a failure that retrying cannot fix, such as a
+// validation error
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not retryable
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// retryableErrnos are I/O failures that commonly clear up on their own:
+// network file system hiccups, locked or busy files and interrupted calls
+var retryableErrnos = []syscall.Errno{
+	syscall.EAGAIN, syscall.EBUSY, syscall.EINTR, syscall.EIO,
+	syscall.ETIMEDOUT, syscall.ETXTBSY, syscall.ESTALE,
+}
+
+// IsRetryable reports whether err is a transient I/O or database failure.
+// Errors marked Permanent, missing files and permission problems are not.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) ||
+		errors.Is(err, fs.ErrExist) || errors.Is(err, fs.ErrInvalid) {
+		return false
+	}
+
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		for _, retryable := range retryableErrnos {
+			if errno == retryable {
+				return true
+			}
+		}
+		return false
+	}
+
+	return IsLockError(err)
+}
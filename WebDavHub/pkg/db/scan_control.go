@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Source scan modes
+const (
+	ScanModeFull   = "full"
+	ScanModeResume = "resume"
+)
+
+// Scan types recorded for scans that only cover part of the sources
+const (
+	ScanTypeLibrary = "library"
+	ScanTypePath    = "path"
+)
+
+// ErrScanInProgress is returned when a scan is requested while one is running
+var ErrScanInProgress = errors.New("a source scan is already running")
+
+// ErrLibraryScanInProgress is returned when a library is already being scanned
+var ErrLibraryScanInProgress = errors.New("a scan of this library is already running")
+
+// ErrInvalidScanPath is returned for a scan path outside every source directory
+var ErrInvalidScanPath = errors.New("scan path must be inside a configured source directory")
+
+// ErrUnknownLibrary is returned for a library that is not a configured source directory
+var ErrUnknownLibrary = errors.New("library is not a configured source directory")
+
+var (
+	activeScanMutex  sync.Mutex
+	activeScanCancel context.CancelFunc
+	// Running single library scans by source index
+	libraryScanCancels = make(map[int]context.CancelFunc)
+)
+
+// beginSourceScan registers a new running scan and returns its context.
+// The returned finish function must be called when the scan ends.
+func beginSourceScan() (context.Context, func(), error) {
+	activeScanMutex.Lock()
+	defer activeScanMutex.Unlock()
+
+	// A full scan covers every library, so it cannot overlap a library scan
+	if activeScanCancel != nil || len(libraryScanCancels) > 0 {
+		return nil, nil, ErrScanInProgress
+	}
+	lease, err := AcquireOperation(OperationScan, uuid.New().String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	activeScanCancel = cancel
+
+	finish := func() {
+		activeScanMutex.Lock()
+		activeScanCancel = nil
+		activeScanMutex.Unlock()
+		lease.Release()
+		cancel()
+	}
+	return withOperationLease(ctx, lease), finish, nil
+}
+
+// beginLibraryScan registers a running scan of path in one source directory.
+// Scans of different libraries may run side by side, but not next to a full
+// scan. A scan started by another operation passes its lease as parent.
+func beginLibraryScan(sourceIndex int, path string, parent *OperationLease) (context.Context, func(), error) {
+	activeScanMutex.Lock()
+	defer activeScanMutex.Unlock()
+
+	if activeScanCancel != nil {
+		return nil, nil, ErrScanInProgress
+	}
+	if _, running := libraryScanCancels[sourceIndex]; running {
+		return nil, nil, ErrLibraryScanInProgress
+	}
+	lease, err := acquireOperation(OperationScan, uuid.New().String(), []string{path}, parent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	libraryScanCancels[sourceIndex] = cancel
+
+	finish := func() {
+		activeScanMutex.Lock()
+		delete(libraryScanCancels, sourceIndex)
+		activeScanMutex.Unlock()
+		lease.Release()
+		cancel()
+	}
+	return withOperationLease(ctx, lease), finish, nil
+}
+
+// CancelSourceScan stops the running scans after their current batch. Progress
+// up to the last checkpoint is kept so a full scan can be resumed later.
+func CancelSourceScan() bool {
+	activeScanMutex.Lock()
+	defer activeScanMutex.Unlock()
+
+	if activeScanCancel == nil && len(libraryScanCancels) == 0 {
+		return false
+	}
+	if activeScanCancel != nil {
+		activeScanCancel()
+	}
+	for _, cancel := range libraryScanCancels {
+		cancel()
+	}
+	return true
+}
+
+// IsSourceScanRunning reports whether a source scan is in progress
+func IsSourceScanRunning() bool {
+	activeScanMutex.Lock()
+	defer activeScanMutex.Unlock()
+	return activeScanCancel != nil || len(libraryScanCancels) > 0
+}
+
+// IsLibraryScanBlocked reports whether a scan of the source directory at
+// sourceIndex would be refused because it or a full scan is running
+func IsLibraryScanBlocked(sourceIndex int) bool {
+	activeScanMutex.Lock()
+	defer activeScanMutex.Unlock()
+	_, running := libraryScanCancels[sourceIndex]
+	return activeScanCancel != nil || running
+}
@@ -0,0 +1,193 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+	"cinesync/pkg/paging"
+)
+
+// Kinds of change a scan records for a file
+const (
+	ScanChangeAdded   = "added"
+	ScanChangeChanged = "changed"
+	ScanChangeRemoved = "removed"
+)
+
+const defaultScanDiffRetention = 20
+
+// SourceScanChange is one file a scan added, changed or removed
+type SourceScanChange struct {
+	ChangeType string `json:"changeType"`
+	FilePath   string `json:"filePath"`
+}
+
+// SourceScanDiffResponse is the body of GET /api/database/source-scans/{id}/diff.
+// The counts cover the whole scan, whichever change type is listed.
+type SourceScanDiffResponse struct {
+	paging.PagedResponse[SourceScanChange]
+	ScanID  int64 `json:"scanId"`
+	Added   int   `json:"added"`
+	Changed int   `json:"changed"`
+	Removed int   `json:"removed"`
+}
+
+// scanDiffRetention returns how many recent scans keep their diff, from
+// CINESYNC_SCAN_DIFF_RETENTION. Zero stops recording diffs.
+func scanDiffRetention() int {
+	return max(env.GetInt("CINESYNC_SCAN_DIFF_RETENTION", defaultScanDiffRetention), 0)
+}
+
+// scanChangeOperation records a change as part of a scan batch, so it commits
+// together with the file row it describes
+func scanChangeOperation(scanID int64, changeType, filePath string) func(*sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO source_scan_changes (scan_id, change_type, file_path) VALUES (?, ?, ?)`,
+			scanID, changeType, filePath)
+		return err
+	}
+}
+
+// recordRemovedSourceFiles records the files matching condition as removed by
+// scanID, before they are deleted in the same transaction
+func recordRemovedSourceFiles(tx *sql.Tx, scanID int64, condition string, args ...interface{}) error {
+	if scanID <= 0 || scanDiffRetention() == 0 {
+		return nil
+	}
+	query := `INSERT INTO source_scan_changes (scan_id, change_type, file_path)
+		SELECT ?, ?, file_path FROM source_files WHERE ` + condition
+	_, err := tx.Exec(query, append([]interface{}{scanID, ScanChangeRemoved}, args...)...)
+	return err
+}
+
+// pruneSourceScanChanges drops the diffs of all but the most recent scans
+func pruneSourceScanChanges() error {
+	return executeWriteOperationSync(func(db *sql.DB) error {
+		_, err := db.Exec(`DELETE FROM source_scan_changes WHERE scan_id NOT IN
+			(SELECT id FROM source_scans ORDER BY started_at DESC, id DESC LIMIT ?)`, scanDiffRetention())
+		return err
+	})
+}
+
+// GetSourceScanDiff returns a page of the changes recorded by a scan, oldest
+// first, optionally only those of one change type. ok is false when the scan
+// does not exist.
+func GetSourceScanDiff(scanID int64, changeType string, page paging.Request) (response SourceScanDiffResponse, ok bool, err error) {
+	err = executeReadOperation(func(db *sql.DB) error {
+		var exists int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM source_scans WHERE id = ?`, scanID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists == 0 {
+			return nil
+		}
+		ok = true
+
+		rows, err := db.Query(`SELECT change_type, COUNT(*) FROM source_scan_changes WHERE scan_id = ? GROUP BY change_type`, scanID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var kind string
+			var count int
+			if err := rows.Scan(&kind, &count); err != nil {
+				return err
+			}
+			switch kind {
+			case ScanChangeAdded:
+				response.Added = count
+			case ScanChangeChanged:
+				response.Changed = count
+			case ScanChangeRemoved:
+				response.Removed = count
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		total := response.Added + response.Changed + response.Removed
+		condition, args := `scan_id = ?`, []interface{}{scanID}
+		switch changeType {
+		case ScanChangeAdded:
+			total = response.Added
+		case ScanChangeChanged:
+			total = response.Changed
+		case ScanChangeRemoved:
+			total = response.Removed
+		}
+		if changeType != "" {
+			condition += ` AND change_type = ?`
+			args = append(args, changeType)
+		}
+
+		changeRows, err := db.Query(`SELECT change_type, file_path FROM source_scan_changes WHERE `+condition+`
+			ORDER BY id LIMIT ? OFFSET ?`, append(args, page.Limit, page.Offset)...)
+		if err != nil {
+			return err
+		}
+		defer changeRows.Close()
+
+		var changes []SourceScanChange
+		for changeRows.Next() {
+			var change SourceScanChange
+			if err := changeRows.Scan(&change.ChangeType, &change.FilePath); err != nil {
+				return err
+			}
+			changes = append(changes, change)
+		}
+		response.PagedResponse = paging.NewResponse(changes, total, page)
+		return changeRows.Err()
+	})
+	response.ScanID = scanID
+	return response, ok, err
+}
+
+// HandleSourceScanDiff serves GET /api/database/source-scans/{id}/diff, the
+// files a scan added, changed or removed. ?type= limits the list to one kind.
+func HandleSourceScanDiff(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	rest := strings.TrimPrefix(r.URL.Path, "/api/database/source-scans/")
+	idPart, action, _ := strings.Cut(strings.Trim(rest, "/"), "/")
+	scanID, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil || scanID <= 0 || action != "diff" {
+		apierror.WriteError(w, http.StatusNotFound, apierror.CodeNotFound, "Not found")
+		return
+	}
+
+	changeType := r.URL.Query().Get("type")
+	if changeType != "" && changeType != ScanChangeAdded && changeType != ScanChangeChanged && changeType != ScanChangeRemoved {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest,
+			fmt.Sprintf("type must be %s, %s or %s", ScanChangeAdded, ScanChangeChanged, ScanChangeRemoved))
+		return
+	}
+
+	response, ok, err := GetSourceScanDiff(scanID, changeType, paging.Parse(r, 100, 1000))
+	if err != nil {
+		logger.Error("Failed to read diff of source scan %d: %v", scanID, err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read scan diff")
+		return
+	}
+	if !ok {
+		apierror.WriteError(w, http.StatusNotFound, apierror.CodeNotFound, "Scan not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+)
+
+// Exclusion reasons reported by the source scanner
+const (
+	ExcludedBySize      = "min_size"
+	ExcludedByPattern   = "pattern"
+	ExcludedByExtension = "extension"
+)
+
+// scanFilter decides which files the source scanner keeps out of the database
+type scanFilter struct {
+	minFileSize       int64
+	includeExtensions map[string]bool
+	excludeExtensions map[string]bool
+	excludePatterns   []*regexp.Regexp
+}
+
+// loadScanFilter builds the scanner filter from the environment:
+// CINESYNC_MIN_FILE_SIZE applies to media files only so subtitles and other
+// small companions are kept, CINESYNC_EXCLUDE_PATTERNS is a comma separated
+// list of case-insensitive regular expressions matched against the file name,
+// and CINESYNC_INCLUDE_EXTENSIONS / CINESYNC_EXCLUDE_EXTENSIONS restrict
+// extensions.
+func loadScanFilter() *scanFilter {
+	filter := &scanFilter{
+		minFileSize:       env.GetSize("CINESYNC_MIN_FILE_SIZE", 0),
+		includeExtensions: parseExtensionList(env.GetString("CINESYNC_INCLUDE_EXTENSIONS", "")),
+		excludeExtensions: parseExtensionList(env.GetString("CINESYNC_EXCLUDE_EXTENSIONS", "")),
+	}
+
+	for _, pattern := range strings.Split(env.GetString("CINESYNC_EXCLUDE_PATTERNS", ""), ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			logger.Warn("Ignoring invalid exclude pattern %q: %v", pattern, err)
+			continue
+		}
+		filter.excludePatterns = append(filter.excludePatterns, re)
+	}
+
+	return filter
+}
+
+// exclusionReason returns why a file should be skipped, or "" to keep it
+func (f *scanFilter) exclusionReason(path string, info os.FileInfo) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if len(f.includeExtensions) > 0 && !f.includeExtensions[ext] {
+		return ExcludedByExtension
+	}
+	if f.excludeExtensions[ext] {
+		return ExcludedByExtension
+	}
+
+	for _, re := range f.excludePatterns {
+		if re.MatchString(info.Name()) {
+			return ExcludedByPattern
+		}
+	}
+
+	if f.minFileSize > 0 && isMediaFile(path) && info.Size() < f.minFileSize {
+		return ExcludedBySize
+	}
+
+	return ""
+}
+
+// parseExtensionList parses a comma separated extension list into a set
+func parseExtensionList(value string) map[string]bool {
+	extensions := make(map[string]bool)
+	for _, ext := range strings.Split(value, ",") {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		extensions[ext] = true
+	}
+	return extensions
+}
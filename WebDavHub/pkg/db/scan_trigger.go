@@ -0,0 +1,288 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+)
+
+// ScanTypeTriggered is the scan type recorded for full scans started by a trigger
+const ScanTypeTriggered = "triggered"
+
+// Scan trigger job states
+const (
+	TriggerStatusPending   = "pending"
+	TriggerStatusRunning   = "running"
+	TriggerStatusCompleted = "completed"
+	TriggerStatusFailed    = "failed"
+)
+
+const (
+	// maxTriggerDebounce caps the debounce window a trigger may ask for
+	maxTriggerDebounce = 5 * time.Minute
+	// finishedTriggerRetention is how long finished trigger jobs can be looked up
+	finishedTriggerRetention = time.Hour
+)
+
+// ScanTriggerJob is a scan queued by POST /api/scan/trigger. Triggers for the
+// same path that arrive while the job is pending are folded into it.
+type ScanTriggerJob struct {
+	ID           string     `json:"id"`
+	Path         string     `json:"path,omitempty"`
+	Status       string     `json:"status"`
+	Triggers     int        `json:"triggers"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	ScheduledFor time.Time  `json:"scheduledFor"`
+	StartedAt    *time.Time `json:"startedAt,omitempty"`
+	FinishedAt   *time.Time `json:"finishedAt,omitempty"`
+	Error        string     `json:"error,omitempty"`
+}
+
+// scanTriggerQueue debounces scan triggers per path. Each path has at most one
+// pending job whose timer is pushed back by every new trigger, so a burst of
+// file events becomes a single scan once the events stop.
+type scanTriggerQueue struct {
+	mutex   sync.Mutex
+	pending map[string]*ScanTriggerJob
+	timers  map[string]*time.Timer
+	jobs    map[string]*ScanTriggerJob
+	// run performs the scan for a path, "" meaning every source directory
+	run func(path string) error
+}
+
+var scanTriggers = newScanTriggerQueue(func(path string) error {
+	if path == "" {
+		return RunSourceScan(ScanTypeTriggered, ScanModeFull)
+	}
+	return RunSourcePathScan(path)
+})
+
+func newScanTriggerQueue(run func(path string) error) *scanTriggerQueue {
+	return &scanTriggerQueue{
+		pending: make(map[string]*ScanTriggerJob),
+		timers:  make(map[string]*time.Timer),
+		jobs:    make(map[string]*ScanTriggerJob),
+		run:     run,
+	}
+}
+
+// scanTriggerDebounce returns the default debounce window, from
+// CINESYNC_SCAN_TRIGGER_DEBOUNCE in seconds
+func scanTriggerDebounce() time.Duration {
+	seconds := env.GetInt("CINESYNC_SCAN_TRIGGER_DEBOUNCE", 10)
+	if seconds < 0 {
+		seconds = 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Trigger queues a scan of path after debounce and returns its job. A pending
+// full scan absorbs path triggers, since it covers them.
+func (q *scanTriggerQueue) Trigger(path string, debounce time.Duration) ScanTriggerJob {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.pruneLocked()
+
+	key := path
+	if _, fullPending := q.pending[""]; fullPending {
+		key = ""
+	}
+
+	now := time.Now()
+	job, exists := q.pending[key]
+	if !exists {
+		job = &ScanTriggerJob{
+			ID:        uuid.New().String(),
+			Path:      key,
+			Status:    TriggerStatusPending,
+			CreatedAt: now,
+		}
+		q.pending[key] = job
+		q.jobs[job.ID] = job
+	}
+	job.Triggers++
+
+	// Only push the job back; a shorter window in a later trigger does not
+	// pull an already scheduled scan forward
+	scheduledFor := now.Add(debounce)
+	if exists && scheduledFor.Before(job.ScheduledFor) {
+		return *job
+	}
+	job.ScheduledFor = scheduledFor
+
+	if timer, hasTimer := q.timers[key]; hasTimer {
+		timer.Stop()
+	}
+	q.timers[key] = time.AfterFunc(debounce, func() {
+		q.fire(key, job)
+	})
+
+	return *job
+}
+
+// fire starts the scan of a pending job. A scan that cannot start because
+// another scan holds the library is retried once that one had time to finish.
+func (q *scanTriggerQueue) fire(key string, job *ScanTriggerJob) {
+	q.mutex.Lock()
+	if q.pending[key] != job || time.Now().Before(job.ScheduledFor) {
+		q.mutex.Unlock()
+		return
+	}
+	delete(q.pending, key)
+	delete(q.timers, key)
+	startedAt := time.Now()
+	job.Status = TriggerStatusRunning
+	job.StartedAt = &startedAt
+	q.mutex.Unlock()
+
+	logger.Info("Starting triggered scan %s (path: %q, %d triggers)", job.ID, job.Path, job.Triggers)
+	err := q.run(job.Path)
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if errors.Is(err, ErrScanInProgress) || errors.Is(err, ErrLibraryScanInProgress) || errors.Is(err, ErrOperationConflict) {
+		// Requeue, unless new triggers already queued another job for the path
+		if _, queued := q.pending[key]; !queued {
+			logger.Info("Scan busy, retrying triggered scan %s in %s", job.ID, time.Minute)
+			job.Status = TriggerStatusPending
+			job.StartedAt = nil
+			job.ScheduledFor = time.Now().Add(time.Minute)
+			q.pending[key] = job
+			q.timers[key] = time.AfterFunc(time.Minute, func() {
+				q.fire(key, job)
+			})
+			return
+		}
+	}
+
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	job.Status = TriggerStatusCompleted
+	if err != nil {
+		logger.Error("Triggered scan %s failed: %v", job.ID, err)
+		job.Status = TriggerStatusFailed
+		job.Error = err.Error()
+	}
+}
+
+// Job returns a trigger job by id
+func (q *scanTriggerQueue) Job(id string) (ScanTriggerJob, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	job, exists := q.jobs[id]
+	if !exists {
+		return ScanTriggerJob{}, false
+	}
+	return *job, true
+}
+
+// pruneLocked forgets jobs that finished more than finishedTriggerRetention ago
+func (q *scanTriggerQueue) pruneLocked() {
+	cutoff := time.Now().Add(-finishedTriggerRetention)
+	for id, job := range q.jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(cutoff) {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// resolveTriggerPath maps the path of a file event to the subtree to scan.
+// Deleted files no longer exist, so the nearest existing parent is scanned
+// instead, which still has to lie inside a source directory.
+func resolveTriggerPath(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return "", ErrInvalidScanPath
+	}
+	path = filepath.Clean(path)
+	for {
+		if _, err := os.Lstat(path); err == nil {
+			break
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", ErrInvalidScanPath
+		}
+		path = parent
+	}
+
+	_, subtree, err := ResolveSourceScanPath(path)
+	return subtree, err
+}
+
+// HandleScanTrigger serves /api/scan/trigger for external file watchers. POST
+// queues a scan from an optional {"path", "debounceSeconds"} body and returns
+// the job; triggers for the same path within the debounce window share one
+// job. GET ?id= reports the state of a job.
+func HandleScanTrigger(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodPost, http.MethodGet) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		job, exists := scanTriggers.Job(r.URL.Query().Get("id"))
+		if !exists {
+			apierror.WriteError(w, http.StatusNotFound, apierror.CodeNotFound, "Scan job not found")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job)
+		return
+	}
+
+	var req struct {
+		Path            string `json:"path"`
+		DebounceSeconds *int   `json:"debounceSeconds"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+			return
+		}
+	}
+
+	debounce := scanTriggerDebounce()
+	if req.DebounceSeconds != nil {
+		if *req.DebounceSeconds < 0 {
+			apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "debounceSeconds cannot be negative")
+			return
+		}
+		debounce = time.Duration(*req.DebounceSeconds) * time.Second
+	}
+	if debounce > maxTriggerDebounce {
+		debounce = maxTriggerDebounce
+	}
+
+	path := ""
+	if strings.TrimSpace(req.Path) != "" {
+		subtree, err := resolveTriggerPath(strings.TrimSpace(req.Path))
+		if err != nil {
+			apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+			return
+		}
+		path = subtree
+	}
+
+	job := scanTriggers.Trigger(path, debounce)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
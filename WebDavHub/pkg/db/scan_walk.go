@@ -0,0 +1,194 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+)
+
+// Reasons a directory is left out of a scan
+const (
+	SkippedTooDeep   = "too_deep"
+	SkippedLoop      = "loop"
+	SkippedDuplicate = "duplicate"
+)
+
+// maxRecordedSkippedDirs bounds how many skipped directories a scan keeps;
+// the count covers all of them
+const maxRecordedSkippedDirs = 100
+
+// untrackedMaxDepth stops a walk that follows symlinks where directories have
+// no identity to detect loops with
+const untrackedMaxDepth = 64
+
+// SkippedDir is a directory a scan did not descend into
+type SkippedDir struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// fileID identifies a directory independently of the path it was reached by
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// sourceWalker walks a source directory like filepath.Walk, in lexical order,
+// while bounding the depth it descends to and refusing to enter a directory
+// twice. Symlinked directories are followed when followSymlinks is set; the
+// (device, inode) pairs of the directories entered are tracked so a link
+// pointing back up the tree or a bind mount cannot make the walk endless.
+type sourceWalker struct {
+	maxDepth       int
+	followSymlinks bool
+	visited        map[fileID]bool
+	ancestors      map[fileID]bool
+	skippedCount   int
+	skipped        []SkippedDir
+}
+
+// newSourceWalker returns a walker limited by CINESYNC_SCAN_MAX_DEPTH, where 0
+// means unlimited, following symlinked directories when
+// CINESYNC_SCAN_FOLLOW_SYMLINKS is set
+func newSourceWalker() *sourceWalker {
+	maxDepth := env.GetInt("CINESYNC_SCAN_MAX_DEPTH", 0)
+	if maxDepth < 0 {
+		maxDepth = 0
+	}
+	return &sourceWalker{
+		maxDepth:       maxDepth,
+		followSymlinks: env.IsBool("CINESYNC_SCAN_FOLLOW_SYMLINKS", false),
+		visited:        make(map[fileID]bool),
+		ancestors:      make(map[fileID]bool),
+	}
+}
+
+// walk calls fn for every file and directory under root, as filepath.Walk
+// does. Depth is counted from sourceDir, so a subtree scan stops at the same
+// directories as a scan of the whole library.
+func (w *sourceWalker) walk(sourceDir, root string, fn filepath.WalkFunc) error {
+	depth := 0
+	if rel, err := filepath.Rel(sourceDir, root); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		depth = len(strings.Split(rel, string(filepath.Separator)))
+	}
+
+	info, err := os.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = w.walkEntry(root, info, depth, fn)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func (w *sourceWalker) walkEntry(path string, info os.FileInfo, depth int, fn filepath.WalkFunc) error {
+	if w.followSymlinks && info.Mode()&os.ModeSymlink != 0 {
+		// A dangling link is reported as the link itself
+		if target, err := os.Stat(path); err == nil {
+			info = target
+		}
+	}
+	if !info.IsDir() {
+		return fn(path, info, nil)
+	}
+
+	if w.maxDepth > 0 && depth > w.maxDepth {
+		w.skip(path, SkippedTooDeep)
+		return nil
+	}
+	id, ok := fileIdentity(info)
+	if ok {
+		switch {
+		case w.ancestors[id]:
+			w.skip(path, SkippedLoop)
+			return nil
+		case w.visited[id]:
+			w.skip(path, SkippedDuplicate)
+			return nil
+		}
+		w.visited[id] = true
+		w.ancestors[id] = true
+		defer delete(w.ancestors, id)
+	} else if w.followSymlinks && depth > untrackedMaxDepth {
+		w.skip(path, SkippedLoop)
+		return nil
+	}
+
+	if err := fn(path, info, nil); err != nil {
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+
+	names, err := readDirNames(path)
+	if err != nil {
+		if err := fn(path, info, err); err != nil && err != filepath.SkipDir {
+			return err
+		}
+		return nil
+	}
+
+	for _, name := range names {
+		child := filepath.Join(path, name)
+		childInfo, err := os.Lstat(child)
+		if err != nil {
+			err = fn(child, childInfo, err)
+		} else {
+			err = w.walkEntry(child, childInfo, depth+1, fn)
+		}
+		if err != nil {
+			// A file returning SkipDir skips the rest of its directory
+			if err == filepath.SkipDir {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// skip records a directory the walk did not enter
+func (w *sourceWalker) skip(path, reason string) {
+	w.skippedCount++
+	w.skipped = appendSkippedDirs(w.skipped, SkippedDir{Path: path, Reason: reason})
+	switch reason {
+	case SkippedTooDeep:
+		logger.Warn("Source scan skipped %s: deeper than CINESYNC_SCAN_MAX_DEPTH=%d", path, w.maxDepth)
+	case SkippedLoop:
+		logger.Warn("Source scan skipped %s: it links back to a directory above it", path)
+	default:
+		logger.Debug("Source scan skipped %s: directory already scanned through another path", path)
+	}
+}
+
+// appendSkippedDirs appends dirs to skipped up to maxRecordedSkippedDirs
+func appendSkippedDirs(skipped []SkippedDir, dirs ...SkippedDir) []SkippedDir {
+	if room := maxRecordedSkippedDirs - len(skipped); len(dirs) > room {
+		dirs = dirs[:max(room, 0)]
+	}
+	return append(skipped, dirs...)
+}
+
+// readDirNames returns the sorted entry names of dir
+func readDirNames(dir string) ([]string, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
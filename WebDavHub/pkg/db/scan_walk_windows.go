@@ -0,0 +1,12 @@
+//go:build windows
+// +build windows
+
+package db
+
+import "os"
+
+// fileIdentity is not available from os.FileInfo on Windows, so loops there
+// are only bounded by CINESYNC_SCAN_MAX_DEPTH
+func fileIdentity(info os.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
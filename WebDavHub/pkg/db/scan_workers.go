@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"sync"
+
+	"cinesync/pkg/env"
+)
+
+// scanJob is a file found by the source walk, waiting to be checked
+type scanJob struct {
+	path    string
+	relPath string
+	info    os.FileInfo
+}
+
+// scanLookup is the MediaHub state of a scanned file
+type scanLookup struct {
+	status       string
+	tmdbID       string
+	seasonNumber *int
+}
+
+// scanWorkers returns the number of files looked up concurrently during a
+// source scan, from CINESYNC_SCAN_WORKERS
+func scanWorkers() int {
+	workers := env.GetInt("CINESYNC_SCAN_WORKERS", runtime.NumCPU())
+	if workers < 1 {
+		return 1
+	}
+	return workers
+}
+
+// lookupScanJobs checks every job against the MediaHub database using up to
+// workers goroutines. Results are stored by index so callers see them in
+// walk order regardless of which worker finished first, and each job is
+// handed to exactly one worker. Jobs not reached before ctx is cancelled are
+// left unprocessed and ctx.Err() is returned.
+func lookupScanJobs(ctx context.Context, jobs []scanJob, workers int) ([]scanLookup, error) {
+	results := make([]scanLookup, len(jobs))
+	for i := range results {
+		results[i].status = "unprocessed"
+	}
+
+	mediaHubDB, err := GetDatabaseConnection()
+	if err != nil {
+		return results, ctx.Err()
+	}
+
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				status, tmdbID, seasonNumber := checkFileInMediaHub(mediaHubDB, jobs[i].path)
+				results[i] = scanLookup{status: status, tmdbID: tmdbID, seasonNumber: seasonNumber}
+			}
+		}()
+	}
+
+feed:
+	for i := range jobs {
+		select {
+		case <-ctx.Done():
+			break feed
+		case indexes <- i:
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results, ctx.Err()
+}
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+// useScanLibrary creates a source directory of files media files, every third
+// one already processed by MediaHub, and points SOURCE_DIR at it
+func useScanLibrary(t testing.TB, files int) {
+	t.Helper()
+	mediaHubDB := useMediaHubDB(t)
+	source, dest := t.TempDir(), t.TempDir()
+	t.Setenv("SOURCE_DIR", source)
+	t.Setenv("DESTINATION_DIR", dest)
+	for i := 0; i < files; i++ {
+		dir := filepath.Join(source, fmt.Sprintf("Show %02d", i%10))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, fmt.Sprintf("Episode.%04d.mkv", i))
+		if i%3 == 0 {
+			addLinkedRecord(t, mediaHubDB, path, filepath.Join(dest, filepath.Base(dir), filepath.Base(path)))
+		} else if err := os.WriteFile(path, []byte("media"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// scannedFile is the part of a source_files row a scan decides
+type scannedFile struct {
+	path, status, tmdbID string
+}
+
+func scannedFiles(t testing.TB) []scannedFile {
+	t.Helper()
+	var files []scannedFile
+	err := executeReadOperation(func(sourceDB *sql.DB) error {
+		rows, err := sourceDB.Query(`SELECT relative_path, processing_status, COALESCE(tmdb_id, '') FROM source_files ORDER BY relative_path`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var file scannedFile
+			if err := rows.Scan(&file.path, &file.status, &file.tmdbID); err != nil {
+				return err
+			}
+			files = append(files, file)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestConcurrentScanMatchesSequentialScan(t *testing.T) {
+	results := make(map[int][]scannedFile)
+	for _, workers := range []int{1, 8} {
+		t.Run(strconv.Itoa(workers)+" workers", func(t *testing.T) {
+			t.Setenv("CINESYNC_SCAN_WORKERS", strconv.Itoa(workers))
+			t.Setenv("CINESYNC_SCAN_BATCH_SIZE", "7")
+			useScanLibrary(t, 60)
+
+			if err := RunSourceScan("manual", ScanModeFull); err != nil {
+				t.Fatal(err)
+			}
+			results[workers] = scannedFiles(t)
+			if len(results[workers]) != 60 {
+				t.Fatalf("scan stored %d files, want 60", len(results[workers]))
+			}
+		})
+	}
+	if !reflect.DeepEqual(results[1], results[8]) {
+		t.Fatalf("concurrent scan stored\n%v\nsequential scan stored\n%v", results[8], results[1])
+	}
+}
+
+func BenchmarkSourceScan(b *testing.B) {
+	for _, workers := range []int{1, runtime.NumCPU()} {
+		b.Run(strconv.Itoa(workers)+" workers", func(b *testing.B) {
+			b.Setenv("CINESYNC_SCAN_WORKERS", strconv.Itoa(workers))
+			useScanLibrary(b, 500)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := RunSourceScan("manual", ScanModeFull); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -2,13 +2,14 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
-	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 	"cinesync/pkg/logger"
 	"cinesync/pkg/env"
 	_ "modernc.org/sqlite"
@@ -131,30 +132,9 @@ func executeReadOperation(operation func(*sql.DB) error) error {
 		return fmt.Errorf("failed to get source database connection: %w", err)
 	}
 
-	maxRetries := 10
-	baseDelay := 50 * time.Millisecond
-
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		err := operation(db)
-		if err == nil {
-			return nil
-		}
-
-		if strings.Contains(err.Error(), "database is locked") || strings.Contains(err.Error(), "SQLITE_BUSY") {
-			if attempt < maxRetries-1 {
-				delay := baseDelay * time.Duration(1<<uint(attempt))
-				if delay > 2*time.Second {
-					delay = 2*time.Second
-				}
-				jitter := time.Duration(rand.Int63n(int64(delay / 2)))
-				time.Sleep(delay + jitter)
-				continue
-			}
-		}
-		return err
-	}
-
-	return fmt.Errorf("read operation failed after %d retries", maxRetries)
+	return RetryOnLock(func() error {
+		return operation(db)
+	})
 }
 
 // executeWriteOperationSync executes a write operation through the write queue (serialized)
@@ -168,32 +148,9 @@ func executeWriteOperationSync(operation func(*sql.DB) error) error {
 			return
 		}
 
-		maxRetries := 10
-		baseDelay := 50 * time.Millisecond
-
-		for attempt := 0; attempt < maxRetries; attempt++ {
-			err := operation(db)
-			if err == nil {
-				resultChan <- nil
-				return
-			}
-
-			if strings.Contains(err.Error(), "database is locked") || strings.Contains(err.Error(), "SQLITE_BUSY") {
-				if attempt < maxRetries-1 {
-					delay := baseDelay * time.Duration(1<<uint(attempt))
-					if delay > 2*time.Second {
-						delay = 2*time.Second
-					}
-					jitter := time.Duration(rand.Int63n(int64(delay / 2)))
-					time.Sleep(delay + jitter)
-					continue
-				}
-			}
-			resultChan <- err
-			return
-		}
-
-		resultChan <- fmt.Errorf("operation failed after %d retries", maxRetries)
+		resultChan <- RetryOnLock(func() error {
+			return operation(db)
+		})
 	})
 
 	return <-resultChan
@@ -245,12 +202,22 @@ func createSourceTables(db *sql.DB) error {
 		last_processed_at INTEGER,
 		tmdb_id TEXT,
 		season_number INTEGER,
-		episode_number INTEGER
+		episode_number INTEGER,
+		file_hash TEXT, -- sha256 of the contents, NULL until computed
+		hashed_at INTEGER
 	);`
 	if _, err := db.Exec(querySourceFiles); err != nil {
 		return fmt.Errorf("failed to create source_files table: %w", err)
 	}
 
+	// Add hash columns to databases created before checksums were stored
+	if err := ensureTableColumns(db, "source_files", map[string]string{
+		"file_hash": "TEXT",
+		"hashed_at": "INTEGER",
+	}); err != nil {
+		return fmt.Errorf("failed to migrate source_files table: %w", err)
+	}
+
 	// Create indexes for source_files table
 	sourceFileIndexes := []string{
 		`CREATE INDEX IF NOT EXISTS idx_source_files_path ON source_files(file_path);`,
@@ -280,20 +247,194 @@ func createSourceTables(db *sql.DB) error {
 		files_removed INTEGER DEFAULT 0,
 		total_files INTEGER DEFAULT 0,
 		error_message TEXT,
-		scan_duration_ms INTEGER
+		scan_duration_ms INTEGER,
+		checkpoint_source_index INTEGER, -- source directory the scan last completed a batch in
+		checkpoint_path TEXT, -- last file path persisted, used to resume interrupted scans
+		files_excluded INTEGER DEFAULT 0, -- files skipped by the scanner filters
+		dirs_skipped INTEGER DEFAULT 0, -- directories too deep or reached again through a symlink
+		skipped_dirs TEXT -- JSON list of the first skipped directories and why
 	);`
 	if _, err := db.Exec(querySourceScans); err != nil {
 		return fmt.Errorf("failed to create source_scans table: %w", err)
 	}
 
+	// Add checkpoint columns to databases created before resumable scans
+	if err := ensureTableColumns(db, "source_scans", map[string]string{
+		"checkpoint_source_index": "INTEGER",
+		"checkpoint_path":         "TEXT",
+		"files_excluded":          "INTEGER DEFAULT 0",
+		"dirs_skipped":            "INTEGER DEFAULT 0",
+		"skipped_dirs":            "TEXT",
+	}); err != nil {
+		return fmt.Errorf("failed to migrate source_scans table: %w", err)
+	}
+
 	// Create index for source_scans table
 	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_source_scans_started ON source_scans(started_at);`)
 	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_source_scans_status ON source_scans(status);`)
 
+	// Create source_scan_changes table for the files each scan added, changed or removed
+	queryScanChanges := `CREATE TABLE IF NOT EXISTS source_scan_changes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		scan_id INTEGER NOT NULL,
+		change_type TEXT NOT NULL, -- 'added', 'changed', 'removed'
+		file_path TEXT NOT NULL
+	);`
+	if _, err := db.Exec(queryScanChanges); err != nil {
+		return fmt.Errorf("failed to create source_scan_changes table: %w", err)
+	}
+	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_source_scan_changes_scan ON source_scan_changes(scan_id, change_type);`)
+
+	// Create identification_overrides table for files or folders matched by hand
+	queryOverrides := `CREATE TABLE IF NOT EXISTS identification_overrides (
+		path TEXT PRIMARY KEY,
+		tmdb_id TEXT,
+		created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+	);`
+	if _, err := db.Exec(queryOverrides); err != nil {
+		return fmt.Errorf("failed to create identification_overrides table: %w", err)
+	}
+
+	// Create file_access_stats table for tracking files served via download and WebDAV
+	queryAccessStats := `CREATE TABLE IF NOT EXISTS file_access_stats (
+		file_path TEXT PRIMARY KEY,
+		access_count INTEGER NOT NULL DEFAULT 0,
+		bytes_served INTEGER NOT NULL DEFAULT 0,
+		first_accessed_at INTEGER,
+		last_accessed_at INTEGER
+	);`
+	if _, err := db.Exec(queryAccessStats); err != nil {
+		return fmt.Errorf("failed to create file_access_stats table: %w", err)
+	}
+	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_file_access_last ON file_access_stats(last_accessed_at);`)
+
+	// Create operation_batches and operation_results tables for per-item bulk operation outcomes
+	queryOperationBatches := `CREATE TABLE IF NOT EXISTS operation_batches (
+		batch_id TEXT PRIMARY KEY,
+		operation TEXT NOT NULL,
+		started_at INTEGER NOT NULL,
+		completed_at INTEGER,
+		status TEXT NOT NULL DEFAULT 'running', -- 'running', 'completed', 'failed'
+		success_count INTEGER DEFAULT 0,
+		skipped_count INTEGER DEFAULT 0,
+		failed_count INTEGER DEFAULT 0
+	);`
+	if _, err := db.Exec(queryOperationBatches); err != nil {
+		return fmt.Errorf("failed to create operation_batches table: %w", err)
+	}
+	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_operation_batches_completed ON operation_batches(completed_at);`)
+
+	queryOperationResults := `CREATE TABLE IF NOT EXISTS operation_results (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		batch_id TEXT NOT NULL,
+		source_path TEXT NOT NULL,
+		destination_path TEXT,
+		status TEXT NOT NULL, -- 'success', 'skipped', 'failed'
+		reason TEXT,
+		created_at INTEGER NOT NULL
+	);`
+	if _, err := db.Exec(queryOperationResults); err != nil {
+		return fmt.Errorf("failed to create operation_results table: %w", err)
+	}
+	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_operation_results_batch ON operation_results(batch_id, status);`)
+
+	// Add the retry count to databases created before file operations were retried
+	if err := ensureTableColumns(db, "operation_results", map[string]string{
+		"retries": "INTEGER DEFAULT 0",
+	}); err != nil {
+		return fmt.Errorf("failed to migrate operation_results table: %w", err)
+	}
+	if err := ensureTableColumns(db, "operation_batches", map[string]string{
+		"permission_failed_count": "INTEGER DEFAULT 0",
+	}); err != nil {
+		return fmt.Errorf("failed to migrate operation_batches table: %w", err)
+	}
+
+	// Create locked_files table for source files locked against automatic processing. It is kept apart
+	// from source_files, whose rows are replaced when a file is added again.
+	queryLockedFiles := `CREATE TABLE IF NOT EXISTS locked_files (
+		file_path TEXT PRIMARY KEY,
+		locked_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+	);`
+	if _, err := db.Exec(queryLockedFiles); err != nil {
+		return fmt.Errorf("failed to create locked_files table: %w", err)
+	}
+
+	// Create idempotency_keys table for the results of bulk operations retried with the same Idempotency-Key
+	queryIdempotencyKeys := `CREATE TABLE IF NOT EXISTS idempotency_keys (
+		idempotency_key TEXT PRIMARY KEY, -- the user, method and path, then the client key
+		operation TEXT NOT NULL,
+		fingerprint TEXT NOT NULL,
+		batch_id TEXT,
+		status_code INTEGER, -- NULL while the operation runs
+		response TEXT,
+		created_at INTEGER NOT NULL
+	);`
+	if _, err := db.Exec(queryIdempotencyKeys); err != nil {
+		return fmt.Errorf("failed to create idempotency_keys table: %w", err)
+	}
+	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created ON idempotency_keys(created_at);`)
+
+	// Create title_monitoring table for the monitored flag of movies and series. Titles without a row are monitored.
+	queryTitleMonitoring := `CREATE TABLE IF NOT EXISTS title_monitoring (
+		tmdb_id INTEGER NOT NULL,
+		media_type TEXT NOT NULL, -- 'movie' or 'tv'
+		monitored BOOLEAN NOT NULL DEFAULT TRUE,
+		updated_at INTEGER NOT NULL,
+		PRIMARY KEY (tmdb_id, media_type)
+	);`
+	if _, err := db.Exec(queryTitleMonitoring); err != nil {
+		return fmt.Errorf("failed to create title_monitoring table: %w", err)
+	}
+
+	// Create movie_collections table for the TMDB collection each movie belongs to
+	queryMovieCollections := `CREATE TABLE IF NOT EXISTS movie_collections (
+		tmdb_id INTEGER PRIMARY KEY,
+		collection_id INTEGER NOT NULL,
+		collection_name TEXT NOT NULL,
+		updated_at INTEGER NOT NULL
+	);`
+	if _, err := db.Exec(queryMovieCollections); err != nil {
+		return fmt.Errorf("failed to create movie_collections table: %w", err)
+	}
+	_, _ = db.Exec(`CREATE INDEX IF NOT EXISTS idx_movie_collections_collection ON movie_collections(collection_id);`)
+
 	logger.Info("Source database tables created successfully")
 	return nil
 }
 
+// ensureTableColumns adds any missing columns to an existing table
+func ensureTableColumns(db *sql.DB, table string, columns map[string]string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+
+	for name, colType := range columns {
+		if existing[name] {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, name, colType)); err != nil {
+			return err
+		}
+		logger.Info("Added column %s to %s", name, table)
+	}
+	return nil
+}
+
 // verifySourceTables verifies that the source tables exist and are accessible
 func verifySourceTables(db *sql.DB) error {
 	var count int
@@ -422,6 +563,30 @@ func MarkAllSourceFilesInactive() error {
 	})
 }
 
+// MarkLibrarySourceFilesInactive marks the files of one source directory as
+// inactive, for a scan that only covers that directory. When subtree is set
+// only the files at or below it are marked.
+func MarkLibrarySourceFilesInactive(sourceIndex int, subtree string) error {
+	return executeWriteOperationSync(func(db *sql.DB) error {
+		condition, args := librarySubtreeCondition(sourceIndex, subtree)
+		query := `UPDATE source_files SET is_active = FALSE WHERE ` + condition
+		_, err := db.Exec(query, args...)
+		return err
+	})
+}
+
+// librarySubtreeCondition matches the files of a source directory, or of a
+// subtree of it when subtree is set. A prefix comparison is used instead of
+// LIKE so wildcard characters in folder names need no escaping.
+func librarySubtreeCondition(sourceIndex int, subtree string) (string, []interface{}) {
+	if subtree == "" {
+		return `source_index = ?`, []interface{}{sourceIndex}
+	}
+	prefix := strings.TrimRight(subtree, string(filepath.Separator)) + string(filepath.Separator)
+	return `source_index = ? AND (file_path = ? OR substr(file_path, 1, ?) = ?)`,
+		[]interface{}{sourceIndex, subtree, utf8.RuneCountInString(prefix), prefix}
+}
+
 // BatchUpdateSourceFiles performs batch operations within a transaction using write queue
 func BatchUpdateSourceFiles(operations []func(*sql.Tx) error) error {
 	return executeWriteOperationSync(func(db *sql.DB) error {
@@ -442,12 +607,44 @@ func BatchUpdateSourceFiles(operations []func(*sql.Tx) error) error {
 }
 
 // RemoveInactiveSourceFiles removes source files that are no longer present
-func RemoveInactiveSourceFiles() (int, error) {
+// and records them in the diff of scanID
+func RemoveInactiveSourceFiles(scanID int64) (int, error) {
 	var rowsAffected int64
 
-	err := executeWriteOperationSync(func(db *sql.DB) error {
+	err := WithSourceDatabaseTransaction(func(tx *sql.Tx) error {
+		if err := recordRemovedSourceFiles(tx, scanID, `is_active = FALSE`); err != nil {
+			return err
+		}
 		query := `DELETE FROM source_files WHERE is_active = FALSE`
-		result, err := db.Exec(query)
+		result, err := tx.Exec(query)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, _ = result.RowsAffected()
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return int(rowsAffected), nil
+}
+
+// RemoveInactiveLibrarySourceFiles removes the files of one source directory,
+// or of a subtree of it, that are no longer present and records them in the
+// diff of scanID
+func RemoveInactiveLibrarySourceFiles(scanID int64, sourceIndex int, subtree string) (int, error) {
+	var rowsAffected int64
+
+	err := WithSourceDatabaseTransaction(func(tx *sql.Tx) error {
+		condition, args := librarySubtreeCondition(sourceIndex, subtree)
+		if err := recordRemovedSourceFiles(tx, scanID, `is_active = FALSE AND `+condition, args...); err != nil {
+			return err
+		}
+		query := `DELETE FROM source_files WHERE is_active = FALSE AND ` + condition
+		result, err := tx.Exec(query, args...)
 		if err != nil {
 			return err
 		}
@@ -482,6 +679,28 @@ func InsertSourceScan(scanType string) (int64, error) {
 	return scanID, nil
 }
 
+// InsertResumedSourceScan inserts a scan record for a scan resuming from a
+// checkpoint. The record starts out with that checkpoint, so the scan can
+// still be resumed from it when it stops before saving one of its own.
+func InsertResumedSourceScan(scanType string, sourceIndex int, filePath string) (int64, error) {
+	var scanID int64
+	err := executeWriteOperationSync(func(db *sql.DB) error {
+		query := `INSERT INTO source_scans (scan_type, started_at, status, checkpoint_source_index, checkpoint_path)
+			VALUES (?, ?, 'running', ?, ?)`
+		result, err := db.Exec(query, scanType, getCurrentTimestamp(), sourceIndex, filePath)
+		if err != nil {
+			return err
+		}
+		scanID, err = result.LastInsertId()
+		return err
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert source scan record: %w", err)
+	}
+	return scanID, nil
+}
+
 // UpdateSourceScan updates a source scan record with completion details
 func UpdateSourceScan(scanID int64, status string, totalFiles, discovered, updated, removed int, durationMs int64, scanError error) error {
 	return executeWriteOperationSync(func(db *sql.DB) error {
@@ -505,39 +724,64 @@ func UpdateSourceScan(scanID int64, status string, totalFiles, discovered, updat
 	})
 }
 
-// getCurrentTimestamp returns the current Unix timestamp
-func getCurrentTimestamp() int64 {
-	return time.Now().Unix()
+// SetSourceScanExclusions records how many files the scanner filters skipped
+func SetSourceScanExclusions(scanID int64, excluded int) error {
+	return executeWriteOperationSync(func(db *sql.DB) error {
+		_, err := db.Exec(`UPDATE source_scans SET files_excluded = ? WHERE id = ?`, excluded, scanID)
+		return err
+	})
+}
+
+// SetSourceScanSkippedDirs records how many directories a scan left out and
+// the first of them
+func SetSourceScanSkippedDirs(scanID int64, count int, dirs []SkippedDir) error {
+	encoded, err := json.Marshal(dirs)
+	if err != nil {
+		return err
+	}
+	return executeWriteOperationSync(func(db *sql.DB) error {
+		_, err := db.Exec(`UPDATE source_scans SET dirs_skipped = ?, skipped_dirs = ? WHERE id = ?`, count, string(encoded), scanID)
+		return err
+	})
 }
 
-// executeWithRetry executes a database operation with retry logic for SQLITE_BUSY errors
-func executeWithRetry(operation func() error) error {
-	maxRetries := 10
-	baseDelay := 50 * time.Millisecond
+// SaveSourceScanCheckpoint records the last file a scan has persisted. Call it
+// only once the writes of the files it covers have been committed.
+func SaveSourceScanCheckpoint(scanID int64, sourceIndex int, filePath string) error {
+	return executeWriteOperationSync(func(db *sql.DB) error {
+		_, err := db.Exec(`UPDATE source_scans SET checkpoint_source_index = ?, checkpoint_path = ? WHERE id = ?`,
+			sourceIndex, filePath, scanID)
+		return err
+	})
+}
 
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		err := operation()
-		if err == nil {
+// GetResumableSourceScan returns the checkpoint of the most recent scan that did
+// not complete. ok is false when there is nothing to resume.
+func GetResumableSourceScan() (scanID int64, sourceIndex int, filePath string, ok bool, err error) {
+	err = executeReadOperation(func(db *sql.DB) error {
+		var status string
+		var index sql.NullInt64
+		var path sql.NullString
+		// Library and path scans cover part of the sources and are never resumed
+		err := db.QueryRow(`SELECT id, status, checkpoint_source_index, checkpoint_path FROM source_scans
+			WHERE scan_type NOT IN (?, ?) ORDER BY started_at DESC, id DESC LIMIT 1`, ScanTypeLibrary, ScanTypePath).Scan(&scanID, &status, &index, &path)
+		if err == sql.ErrNoRows {
 			return nil
 		}
-
-		// Check if it's a SQLite busy error
-		if strings.Contains(err.Error(), "database is locked") || strings.Contains(err.Error(), "SQLITE_BUSY") {
-			if attempt < maxRetries-1 {
-				delay := baseDelay * time.Duration(1<<uint(attempt))
-				if delay > 2*time.Second {
-					delay = 2*time.Second
-				}
-				jitter := time.Duration(rand.Int63n(int64(delay / 2)))
-				time.Sleep(delay + jitter)
-				logger.Debug("Database busy, retrying operation (attempt %d/%d)", attempt+1, maxRetries)
-				continue
-			}
+		if err != nil {
+			return err
 		}
-		return err
-	}
+		if status != "completed" && index.Valid && path.Valid {
+			sourceIndex, filePath, ok = int(index.Int64), path.String, true
+		}
+		return nil
+	})
+	return scanID, sourceIndex, filePath, ok, err
+}
 
-	return fmt.Errorf("operation failed after %d retries", maxRetries)
+// getCurrentTimestamp returns the current Unix timestamp
+func getCurrentTimestamp() int64 {
+	return time.Now().Unix()
 }
 
 // WithSourceDatabaseTransaction executes a function within a database transaction with retry logic
@@ -564,6 +808,26 @@ func WithSourceDatabaseTransaction(fn func(*sql.Tx) error) error {
 	})
 }
 
+// CheckSourceDatabaseWritable verifies the source database accepts writes by
+// creating and dropping a scratch table inside a committed transaction
+func CheckSourceDatabaseWritable() error {
+	return executeWriteOperationSync(func(db *sql.DB) error {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		if _, err := tx.Exec("CREATE TABLE IF NOT EXISTS diagnostics_write_probe (id INTEGER)"); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := tx.Exec("DROP TABLE diagnostics_write_probe"); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
 // IsNewDatabase checks if this is a new database that needs initial scanning
 func IsNewDatabase() bool {
 	db, err := GetSourceDatabaseConnection()
@@ -2,8 +2,10 @@ package db
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -15,6 +17,7 @@ import (
 
 	"cinesync/pkg/env"
 	"cinesync/pkg/logger"
+	"cinesync/pkg/paging"
 )
 
 // Callback function for broadcasting events - set by api package to avoid circular dependency
@@ -42,21 +45,25 @@ type SourceFile struct {
 	TmdbID              string `json:"tmdbId,omitempty"`
 	SeasonNumber        *int   `json:"seasonNumber,omitempty"`
 	EpisodeNumber       *int   `json:"episodeNumber,omitempty"`
+	Locked              bool   `json:"locked"`
 }
 
 // SourceScan represents a source directory scan operation
 type SourceScan struct {
-	ID              int    `json:"id"`
-	ScanType        string `json:"scanType"`
-	StartedAt       int64  `json:"startedAt"`
-	CompletedAt     *int64 `json:"completedAt,omitempty"`
-	Status          string `json:"status"`
-	FilesDiscovered int    `json:"filesDiscovered"`
-	FilesUpdated    int    `json:"filesUpdated"`
-	FilesRemoved    int    `json:"filesRemoved"`
-	TotalFiles      int    `json:"totalFiles"`
-	ErrorMessage    string `json:"errorMessage,omitempty"`
-	ScanDurationMs  *int64 `json:"scanDurationMs,omitempty"`
+	ID              int          `json:"id"`
+	ScanType        string       `json:"scanType"`
+	StartedAt       int64        `json:"startedAt"`
+	CompletedAt     *int64       `json:"completedAt,omitempty"`
+	Status          string       `json:"status"`
+	FilesDiscovered int          `json:"filesDiscovered"`
+	FilesUpdated    int          `json:"filesUpdated"`
+	FilesRemoved    int          `json:"filesRemoved"`
+	TotalFiles      int          `json:"totalFiles"`
+	FilesExcluded   int          `json:"filesExcluded"`
+	DirsSkipped     int          `json:"dirsSkipped"`
+	SkippedDirs     []SkippedDir `json:"skippedDirs,omitempty"`
+	ErrorMessage    string       `json:"errorMessage,omitempty"`
+	ScanDurationMs  *int64       `json:"scanDurationMs,omitempty"`
 }
 
 // HandleSourceFiles handles source file API requests
@@ -132,8 +139,7 @@ func handleAddSourceFile(w http.ResponseWriter, r *http.Request) {
 // handleGetSourceFiles retrieves source files with pagination and filtering
 func handleGetSourceFiles(w http.ResponseWriter, r *http.Request) {
 	// Parse query parameters
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
+	page := paging.Parse(r, 50, 0)
 	sourceIndexStr := r.URL.Query().Get("sourceIndex")
 	statusFilter := r.URL.Query().Get("status")
 	mediaOnly := r.URL.Query().Get("mediaOnly") == "true"
@@ -147,20 +153,6 @@ func handleGetSourceFiles(w http.ResponseWriter, r *http.Request) {
 		statusFilter = ""
 	}
 
-	limit := 50
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-		}
-	}
-
-	offset := 0
-	if offsetStr != "" {
-		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-			offset = o
-		}
-	}
-
 	// Build WHERE clause
 	whereClause := "WHERE 1=1"
 	var args []interface{}
@@ -187,6 +179,10 @@ func handleGetSourceFiles(w http.ResponseWriter, r *http.Request) {
 		args = append(args, true)
 	}
 
+	if r.URL.Query().Get("locked") == "true" {
+		whereClause += " AND file_path IN (SELECT file_path FROM locked_files)"
+	}
+
 	// Add search filtering if search query is provided
 	if searchQuery != "" {
 		searchPattern := "%" + searchQuery + "%"
@@ -214,9 +210,10 @@ func handleGetSourceFiles(w http.ResponseWriter, r *http.Request) {
 		query := `SELECT id, file_path, file_name, file_size, file_size_formatted,
 				  modified_time, is_media_file, media_type, source_index, source_directory,
 				  relative_path, file_extension, discovered_at, last_seen_at, is_active,
-				  processing_status, last_processed_at, tmdb_id, season_number, episode_number
+				  processing_status, last_processed_at, tmdb_id, season_number, episode_number,
+				  EXISTS(SELECT 1 FROM locked_files WHERE locked_files.file_path = source_files.file_path)
 				  FROM source_files ` + whereClause + " ORDER BY last_seen_at DESC, file_name ASC LIMIT ? OFFSET ?"
-		queryArgs := append(args, limit, offset)
+		queryArgs := append(args, page.Limit, page.Offset)
 
 		rows, err := sourceDB.Query(query, queryArgs...)
 		if err != nil {
@@ -239,6 +236,7 @@ func handleGetSourceFiles(w http.ResponseWriter, r *http.Request) {
 				&file.ModifiedTime, &file.IsMediaFile, &mediaType, &file.SourceIndex, &file.SourceDirectory,
 				&file.RelativePath, &file.FileExtension, &file.DiscoveredAt, &file.LastSeenAt, &file.IsActive,
 				&file.ProcessingStatus, &lastProcessedAt, &tmdbID, &seasonNumber, &episodeNumber,
+				&file.Locked,
 			)
 			if err != nil {
 				logger.Error("Failed to scan source file row: %v", err)
@@ -275,22 +273,8 @@ func handleGetSourceFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Calculate pagination info
-	totalPages := (total + limit - 1) / limit
-	currentPage := (offset / limit) + 1
-
-	response := map[string]interface{}{
-		"files":       files,
-		"total":       total,
-		"page":        currentPage,
-		"limit":       limit,
-		"totalPages":  totalPages,
-		"hasNext":     currentPage < totalPages,
-		"hasPrev":     currentPage > 1,
-	}
-
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(paging.NewResponse(files, total, page))
 }
 
 // handleUpdateSourceFiles handles bulk updates to source files
@@ -325,6 +309,8 @@ func handleUpdateSourceFiles(w http.ResponseWriter, r *http.Request) {
 			SeasonNumber     *int   `json:"seasonNumber,omitempty"`
 		} `json:"files,omitempty"`
 		ScanType string `json:"scanType,omitempty"`
+		Mode     string `json:"mode,omitempty"`
+		Path     string `json:"path,omitempty"`
 	}
 
 	if err := json.Unmarshal(body, &req); err != nil {
@@ -332,9 +318,22 @@ func handleUpdateSourceFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Mode == "" {
+		req.Mode = r.URL.Query().Get("mode")
+	}
+	if req.Path == "" {
+		req.Path = r.URL.Query().Get("path")
+	}
+
 	switch req.Action {
 	case "scan":
-		handleSourceScan(w, req.ScanType)
+		if req.Path != "" {
+			handleSourcePathScan(w, req.Path, req.Mode)
+			return
+		}
+		handleSourceScan(w, req.ScanType, req.Mode)
+	case "cancel_scan":
+		handleCancelSourceScan(w)
 	case "update_status":
 		handleUpdateFileStatuses(w, req.Files)
 	default:
@@ -343,14 +342,29 @@ func handleUpdateSourceFiles(w http.ResponseWriter, r *http.Request) {
 }
 
 // handleSourceScan triggers a source directory scan
-func handleSourceScan(w http.ResponseWriter, scanType string) {
+func handleSourceScan(w http.ResponseWriter, scanType, mode string) {
 	if scanType == "" {
 		scanType = "manual"
 	}
+	if mode == "" {
+		mode = ScanModeFull
+	}
+	if mode != ScanModeFull && mode != ScanModeResume {
+		http.Error(w, "Invalid scan mode, expected full or resume", http.StatusBadRequest)
+		return
+	}
+	if IsSourceScanRunning() {
+		http.Error(w, ErrScanInProgress.Error(), http.StatusConflict)
+		return
+	}
+	if err := CheckOperation(OperationScan, nil); err != nil {
+		WriteOperationConflict(w, err)
+		return
+	}
 
 	// Start scan in background
 	go func() {
-		if err := ScanSourceDirectories(scanType); err != nil {
+		if err := RunSourceScan(scanType, mode); err != nil {
 			logger.Error("Source scan failed: %v", err)
 		}
 	}()
@@ -359,6 +373,57 @@ func handleSourceScan(w http.ResponseWriter, scanType string) {
 	json.NewEncoder(w).Encode(map[string]string{
 		"message": "Source scan started",
 		"type":    scanType,
+		"mode":    mode,
+	})
+}
+
+// handleSourcePathScan triggers a scan of a single subtree of a source directory
+func handleSourcePathScan(w http.ResponseWriter, path, mode string) {
+	if mode != "" && mode != ScanModeFull {
+		http.Error(w, "Path scans cannot be resumed, use mode full", http.StatusBadRequest)
+		return
+	}
+
+	sourceIndex, subtree, err := ResolveSourceScanPath(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if IsLibraryScanBlocked(sourceIndex) {
+		http.Error(w, ErrScanInProgress.Error(), http.StatusConflict)
+		return
+	}
+	if err := CheckOperation(OperationScan, []string{subtree}); err != nil {
+		WriteOperationConflict(w, err)
+		return
+	}
+
+	// Start scan in background
+	go func() {
+		if err := RunSourcePathScan(subtree); err != nil {
+			logger.Error("Source scan of %s failed: %v", subtree, err)
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Source scan started",
+		"type":    ScanTypePath,
+		"mode":    ScanModeFull,
+		"path":    subtree,
+	})
+}
+
+// handleCancelSourceScan cancels the running source scan
+func handleCancelSourceScan(w http.ResponseWriter) {
+	if !CancelSourceScan() {
+		http.Error(w, "No source scan is running", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Source scan cancellation requested",
 	})
 }
 
@@ -423,104 +488,312 @@ func handleUpdateFileStatuses(w http.ResponseWriter, files []struct {
 
 // ScanSourceDirectories scans all configured source directories and updates the database
 func ScanSourceDirectories(scanType string) error {
-	logger.Info("Starting source directory scan (type: %s)", scanType)
+	return RunSourceScan(scanType, ScanModeFull)
+}
+
+// RunSourceScan scans all configured source directories. In resume mode the
+// scan continues after the checkpoint of the last interrupted scan instead of
+// starting over; files before the checkpoint are not reprocessed.
+func RunSourceScan(scanType, mode string) error {
+	ctx, finish, err := beginSourceScan()
+	if err != nil {
+		return err
+	}
+	defer finish()
+
+	return runSourceScan(ctx, scanType, mode, -1, "")
+}
+
+// RunLibraryScan scans a single source directory, leaving the files of the
+// other directories untouched. Each library can only be scanned once at a time.
+func RunLibraryScan(library string) error {
+	sourceIndex := -1
+	for index, dir := range SourceDirectories() {
+		if dir == library {
+			sourceIndex = index
+			break
+		}
+	}
+	if sourceIndex < 0 {
+		return ErrUnknownLibrary
+	}
+
+	ctx, finish, err := beginLibraryScan(sourceIndex, library, nil)
+	if err != nil {
+		return err
+	}
+	defer finish()
+
+	return runSourceScan(ctx, ScanTypeLibrary, ScanModeFull, sourceIndex, "")
+}
+
+// RunSourcePathScan scans only the files at or below path, which must lie
+// within a source directory. Entries outside the subtree are left untouched.
+// The scan holds the lock of the library the path belongs to.
+func RunSourcePathScan(path string) error {
+	return runSourcePathScan(path, nil)
+}
+
+// runSourcePathScan scans path for the operation holding parent, which the
+// scan does not conflict with
+func runSourcePathScan(path string, parent *OperationLease) error {
+	sourceIndex, subtree, err := ResolveSourceScanPath(path)
+	if err != nil {
+		return err
+	}
+
+	ctx, finish, err := beginLibraryScan(sourceIndex, subtree, parent)
+	if err != nil {
+		return err
+	}
+	defer finish()
+
+	return runSourceScan(ctx, ScanTypePath, ScanModeFull, sourceIndex, subtree)
+}
+
+// ResolveSourceScanPath finds the source directory containing path and returns
+// its index with the cleaned path. The path must exist, and symlinks are
+// resolved for the check so a link cannot point the scan outside the sources.
+func ResolveSourceScanPath(path string) (int, string, error) {
+	if path == "" || !filepath.IsAbs(path) {
+		return -1, "", fmt.Errorf("%w: an absolute path is required", ErrInvalidScanPath)
+	}
+	path = filepath.Clean(path)
+
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return -1, "", fmt.Errorf("%w: %v", ErrInvalidScanPath, err)
+	}
+
+	for index, sourceDir := range SourceDirectories() {
+		sourceDir = filepath.Clean(sourceDir)
+		if !isWithinDir(sourceDir, path) {
+			continue
+		}
+		realSourceDir, err := filepath.EvalSymlinks(sourceDir)
+		if err != nil || !isWithinDir(realSourceDir, realPath) {
+			continue
+		}
+		return index, path, nil
+	}
+	return -1, "", ErrInvalidScanPath
+}
+
+// isWithinDir reports whether path is dir or lies below it
+func isWithinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// runSourceScan performs a scan of every source directory, or only of the
+// directory at onlyIndex when it is not negative. A subtree limits the scan of
+// that directory to the files at or below it.
+func runSourceScan(ctx context.Context, scanType, mode string, onlyIndex int, subtree string) error {
+	resumeIndex, resumePath := 0, ""
+	resuming := false
+	if mode == ScanModeResume {
+		previousID, index, path, ok, err := GetResumableSourceScan()
+		if err != nil {
+			return fmt.Errorf("failed to load scan checkpoint: %w", err)
+		}
+		if ok {
+			resuming = true
+			resumeIndex, resumePath = index, path
+			logger.Info("Resuming source scan %d from %s (source %d)", previousID, resumePath, resumeIndex)
+		} else {
+			logger.Info("No interrupted scan to resume, starting a full scan")
+		}
+	}
+
+	// Get source directories from config
+	sourceDirectories, err := getSourceDirectories()
+	if err != nil {
+		return fmt.Errorf("failed to get source directories: %w", err)
+	}
+	library := ""
+	if onlyIndex >= 0 {
+		if onlyIndex >= len(sourceDirectories) {
+			return ErrUnknownLibrary
+		}
+		library = sourceDirectories[onlyIndex]
+		if subtree != "" {
+			logger.Info("Starting source directory scan of %s (type: %s)", subtree, scanType)
+		} else {
+			logger.Info("Starting source directory scan of %s (type: %s)", library, scanType)
+		}
+	} else {
+		logger.Info("Starting source directory scan (type: %s, mode: %s)", scanType, mode)
+	}
+
+	// eventData adds the scanned library to single library scan events
+	eventData := func(data map[string]interface{}) map[string]interface{} {
+		if library != "" {
+			data["library"] = library
+		}
+		if subtree != "" {
+			data["path"] = subtree
+		}
+		return data
+	}
 
 	// Broadcast scan started event
-	broadcastScanEvent("scan_started", map[string]interface{}{
+	broadcastScanEvent("scan_started", eventData(map[string]interface{}{
 		"scanType": scanType,
-	})
+		"mode":     mode,
+	}))
 
 	// Create scan record
-	scanID, err := createScanRecord(scanType)
+	var scanID int64
+	if resuming {
+		scanID, err = InsertResumedSourceScan(scanType, resumeIndex, resumePath)
+	} else {
+		scanID, err = createScanRecord(scanType)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create scan record: %w", err)
 	}
+	if lease := operationLeaseFrom(ctx); lease != nil {
+		lease.SetID(strconv.FormatInt(scanID, 10))
+	}
 
 	startTime := time.Now()
 	var totalFiles, discovered, updated, removed int
 	var scanError error
+	filter := loadScanFilter()
+	exclusions := make(map[string]int)
+	skippedCount := 0
+	var skippedDirs []SkippedDir
 
 	defer func() {
 		duration := time.Since(startTime).Milliseconds()
+		excluded := 0
+		for _, count := range exclusions {
+			excluded += count
+		}
+		if excluded > 0 {
+			logger.Info("Source scan excluded %d files (%v)", excluded, exclusions)
+			if err := SetSourceScanExclusions(scanID, excluded); err != nil {
+				logger.Warn("Failed to record scan exclusions: %v", err)
+			}
+		}
+		if skippedCount > 0 {
+			logger.Warn("Source scan skipped %d directories that were too deep or already visited", skippedCount)
+			if err := SetSourceScanSkippedDirs(scanID, skippedCount, skippedDirs); err != nil {
+				logger.Warn("Failed to record skipped scan directories: %v", err)
+			}
+		}
 		status := "completed"
-		if scanError != nil {
+		if errors.Is(scanError, context.Canceled) {
+			status = "cancelled"
+		} else if scanError != nil {
 			status = "failed"
 		}
 
 		updateScanRecord(scanID, status, totalFiles, discovered, updated, removed, duration, scanError)
 
-		if scanError != nil {
+		if status == "cancelled" {
+			logger.Info("Source scan cancelled after %d files, resume with mode=resume", totalFiles)
+			broadcastScanEvent("scan_cancelled", eventData(map[string]interface{}{
+				"scanType":   scanType,
+				"totalFiles": totalFiles,
+			}))
+		} else if scanError != nil {
 			logger.Error("Source scan failed: %v", scanError)
 			// Broadcast scan failed event
-			broadcastScanEvent("scan_failed", map[string]interface{}{
+			broadcastScanEvent("scan_failed", eventData(map[string]interface{}{
 				"scanType": scanType,
 				"error":    scanError.Error(),
-			})
+			}))
 		} else {
 			logger.Info("Source scan completed: %d total, %d discovered, %d updated, %d removed",
 				totalFiles, discovered, updated, removed)
 			// Broadcast scan completed event
-			broadcastScanEvent("scan_completed", map[string]interface{}{
+			broadcastScanEvent("scan_completed", eventData(map[string]interface{}{
 				"scanType":        scanType,
 				"totalFiles":      totalFiles,
 				"filesDiscovered": discovered,
 				"filesUpdated":    updated,
 				"filesRemoved":    removed,
+				"filesExcluded":   excluded,
+				"exclusions":      exclusions,
+				"dirsSkipped":     skippedCount,
+				"skippedDirs":     skippedDirs,
 				"duration":        duration,
-			})
+			}))
 		}
 	}()
 
-	// Get source directories from config
-	sourceDirectories, err := getSourceDirectories()
-	if err != nil {
-		scanError = fmt.Errorf("failed to get source directories: %w", err)
-		return scanError
-	}
-
 	if len(sourceDirectories) == 0 {
 		scanError = fmt.Errorf("no source directories configured")
 		return scanError
 	}
 
-	// Mark all files as potentially inactive
-	if err := MarkAllSourceFilesInactive(); err != nil {
-		scanError = fmt.Errorf("failed to mark files inactive: %w", err)
-		return scanError
+	// Mark all files as potentially inactive. A resumed scan keeps the marks
+	// from the interrupted run so files it already saw stay active.
+	if onlyIndex >= 0 {
+		if err := MarkLibrarySourceFilesInactive(onlyIndex, subtree); err != nil {
+			scanError = fmt.Errorf("failed to mark files inactive: %w", err)
+			return scanError
+		}
+	} else if !resuming {
+		if err := MarkAllSourceFilesInactive(); err != nil {
+			scanError = fmt.Errorf("failed to mark files inactive: %w", err)
+			return scanError
+		}
 	}
 
 	// Scan each source directory
 	for sourceIndex, sourceDir := range sourceDirectories {
-		dirFiles, dirDiscovered, dirUpdated, err := scanSourceDirectory(sourceDir, sourceIndex)
-		if err != nil {
-			logger.Error("Failed to scan source directory %s: %v", sourceDir, err)
+		if onlyIndex >= 0 && sourceIndex != onlyIndex {
 			continue
 		}
 
+		resumeAfter := ""
+		if resuming {
+			if sourceIndex < resumeIndex {
+				continue
+			}
+			if sourceIndex == resumeIndex {
+				resumeAfter = resumePath
+			}
+		}
+
+		walker := newSourceWalker()
+		dirFiles, dirDiscovered, dirUpdated, err := scanSourceDirectory(ctx, scanID, sourceDir, subtree, sourceIndex, resumeAfter, filter, exclusions, walker)
+		skippedCount += walker.skippedCount
+		skippedDirs = appendSkippedDirs(skippedDirs, walker.skipped...)
 		totalFiles += dirFiles
 		discovered += dirDiscovered
 		updated += dirUpdated
-	}
 
-	// Remove files that are no longer present
-	maxRetries := 3
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		removed, err = RemoveInactiveSourceFiles()
-		if err == nil {
-			break
+		if errors.Is(err, context.Canceled) {
+			scanError = err
+			return scanError
 		}
-
-		if strings.Contains(err.Error(), "database is locked") || strings.Contains(err.Error(), "SQLITE_BUSY") {
-			if attempt < maxRetries-1 {
-				delay := time.Duration(100*(1<<uint(attempt))) * time.Millisecond
-				time.Sleep(delay)
-				continue
-			}
+		if err != nil {
+			logger.Error("Failed to scan source directory %s: %v", sourceDir, err)
+			continue
 		}
-		logger.Error("Failed to remove inactive files (attempt %d/%d): %v", attempt+1, maxRetries, err)
-		if attempt == maxRetries-1 {
-			logger.Error("Failed to remove inactive files after %d attempts", maxRetries)
+	}
+
+	// Remove files that are no longer present
+	err = RetryOnLock(func() error {
+		var err error
+		if onlyIndex >= 0 {
+			removed, err = RemoveInactiveLibrarySourceFiles(scanID, onlyIndex, subtree)
+		} else {
+			removed, err = RemoveInactiveSourceFiles(scanID)
 		}
+		return err
+	})
+	if err != nil {
+		logger.Error("Failed to remove inactive files: %v", err)
+	}
+	if err := pruneSourceScanChanges(); err != nil {
+		logger.Warn("Failed to prune old scan diffs: %v", err)
 	}
 
 	// Update processing status based on MediaHub database
@@ -541,6 +814,13 @@ func updateScanRecord(scanID int64, status string, totalFiles, discovered, updat
 	UpdateSourceScan(scanID, status, totalFiles, discovered, updated, removed, durationMs, scanError)
 }
 
+// SourceDirectories returns the configured source directories, the libraries
+// that can be scanned on their own
+func SourceDirectories() []string {
+	dirs, _ := getSourceDirectories()
+	return dirs
+}
+
 // getSourceDirectories retrieves source directories from config
 func getSourceDirectories() ([]string, error) {
 	sourceDir := env.GetString("SOURCE_DIR", "")
@@ -564,13 +844,27 @@ func getSourceDirectories() ([]string, error) {
 	return validDirs, nil
 }
 
-// scanSourceDirectory scans a single source directory
-func scanSourceDirectory(sourceDir string, sourceIndex int) (totalFiles, discovered, updated int, err error) {
-	var insertOperations []func(*sql.Tx) error
-	var updateOperations []func(*sql.Tx) error
-	var existingFiles []string
+// scanSourceDirectory scans a single source directory. Files are looked up in
+// MediaHub by a pool of CINESYNC_SCAN_WORKERS workers, CINESYNC_SCAN_BATCH_SIZE
+// files at a time; each batch is committed in transactions of the same size
+// and a checkpoint is saved once it is. Files that sort at or
+// before resumeAfter were handled by an earlier run and are skipped. Files
+// rejected by the filter are counted per reason in exclusions. Only walkRoot,
+// the source directory itself when empty, is walked, by walker, which records
+// the directories it leaves out.
+func scanSourceDirectory(ctx context.Context, scanID int64, sourceDir, walkRoot string, sourceIndex int, resumeAfter string, filter *scanFilter, exclusions map[string]int, walker *sourceWalker) (totalFiles, discovered, updated int, err error) {
+	if walkRoot == "" {
+		walkRoot = sourceDir
+	}
+
+	// Size and modification time of the known files, to tell which changed
+	type knownFile struct {
+		size    int64
+		modTime int64
+	}
+	existingFiles := make(map[string]knownFile)
 	err = executeReadOperation(func(sourceDB *sql.DB) error {
-		query := `SELECT file_path FROM source_files WHERE source_index = ?`
+		query := `SELECT file_path, COALESCE(file_size, 0), COALESCE(modified_time, 0) FROM source_files WHERE source_index = ?`
 		rows, err := sourceDB.Query(query, sourceIndex)
 		if err != nil {
 			return fmt.Errorf("failed to query existing files: %w", err)
@@ -579,10 +873,11 @@ func scanSourceDirectory(sourceDir string, sourceIndex int) (totalFiles, discove
 
 		for rows.Next() {
 			var filePath string
-			if err := rows.Scan(&filePath); err != nil {
+			var file knownFile
+			if err := rows.Scan(&filePath, &file.size, &file.modTime); err != nil {
 				continue
 			}
-			existingFiles = append(existingFiles, filePath)
+			existingFiles[filePath] = file
 		}
 		return nil
 	})
@@ -592,11 +887,149 @@ func scanSourceDirectory(sourceDir string, sourceIndex int) (totalFiles, discove
 	}
 
 	existingFileMap := make(map[string]bool)
-	for _, filePath := range existingFiles {
+	for filePath := range existingFiles {
 		existingFileMap[filePath] = true
 	}
+	recordDiff := scanDiffRetention() > 0
+
+	workers := scanWorkers()
+	batchSize := scanBatchSize()
+	writer := newSourceBatchWriter(batchSize)
+	// A failed commit stops the writer; processChunk reports it once the
+	// chunk's operations are queued
+	addOperation := func(operation func(*sql.Tx) error) {
+		writer.Add(operation)
+	}
+
+	// processChunk looks up a chunk of walked files concurrently, then persists
+	// the resulting changes in walk order and then records the checkpoint. A chunk
+	// interrupted by cancellation is dropped, and one whose writes fail fails
+	// the scan of the directory, so the checkpoint only ever covers files that
+	// were actually written.
+	var pending []scanJob
+	processChunk := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		jobs := pending
+		pending = nil
+
+		lookups, err := lookupScanJobs(ctx, jobs, workers)
+		if err != nil {
+			return err
+		}
+
+		for i, job := range jobs {
+			path, info, relPath := job.path, job.info, job.relPath
+			processingStatus, tmdbID, seasonNum := lookups[i].status, lookups[i].tmdbID, lookups[i].seasonNumber
+			totalFiles++
+
+			// Check if file is a media file
+			isMedia := isMediaFile(path)
+			mediaType := ""
+			if isMedia {
+				mediaType = detectMediaType(info.Name())
+			}
+
+			// Format file size
+			sizeFormatted := formatFileSize(info.Size())
+
+			if !existingFileMap[path] {
+				discovered++
+				filePath, fileName, fileSize, fileSizeFormatted := path, info.Name(), info.Size(), sizeFormatted
+				modTime, relativePathCopy, fileExt := info.ModTime().Unix(), relPath, filepath.Ext(path)
+				currentTime := time.Now().Unix()
+
+				addOperation(func(tx *sql.Tx) error {
+					query := `INSERT INTO source_files
+						(file_path, file_name, file_size, file_size_formatted, modified_time,
+						 is_media_file, media_type, source_index, source_directory, relative_path,
+						 file_extension, discovered_at, last_seen_at, is_active, processing_status)
+						VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+					_, err := tx.Exec(query,
+						filePath, fileName, fileSize, fileSizeFormatted, modTime,
+						isMedia, mediaType, sourceIndex, sourceDir, relativePathCopy,
+						fileExt, currentTime, currentTime, true, processingStatus)
+					return err
+				})
+				if recordDiff {
+					addOperation(scanChangeOperation(scanID, ScanChangeAdded, filePath))
+				}
+
+				if tmdbID != "" {
+					tmdbIDCopy, seasonNumCopy := tmdbID, seasonNum
+					addOperation(func(tx *sql.Tx) error {
+						query := `UPDATE source_files SET tmdb_id = ?, season_number = ? WHERE file_path = ?`
+						var seasonNumberVal sql.NullInt64
+						if seasonNumCopy != nil {
+							seasonNumberVal.Int64 = int64(*seasonNumCopy)
+							seasonNumberVal.Valid = true
+						}
+						_, err := tx.Exec(query, tmdbIDCopy, seasonNumberVal, filePath)
+						return err
+					})
+				}
+			} else {
+				updated++
+				filePath, fileSize, fileSizeFormatted := path, info.Size(), sizeFormatted
+				modTime, currentTime := info.ModTime().Unix(), time.Now().Unix()
+
+				// A stored hash only survives while the size and modification time match
+				addOperation(func(tx *sql.Tx) error {
+					query := `UPDATE source_files SET
+						file_hash = CASE WHEN file_size = ? AND modified_time = ? THEN file_hash END,
+						file_size = ?, file_size_formatted = ?, modified_time = ?,
+						is_media_file = ?, media_type = ?, last_seen_at = ?, is_active = ?
+						WHERE file_path = ?`
+
+					_, err := tx.Exec(query,
+						fileSize, modTime,
+						fileSize, fileSizeFormatted, modTime,
+						isMedia, mediaType, currentTime, true,
+						filePath)
+					return err
+				})
+				if known, ok := existingFiles[path]; recordDiff && ok && (known.size != fileSize || known.modTime != modTime) {
+					addOperation(scanChangeOperation(scanID, ScanChangeChanged, filePath))
+				}
+
+				if tmdbID != "" && processingStatus != "unprocessed" {
+					tmdbIDCopy, seasonNumCopy := tmdbID, seasonNum
+					addOperation(func(tx *sql.Tx) error {
+						query := `UPDATE source_files SET processing_status = ?, last_processed_at = ?, tmdb_id = ?, season_number = ?
+								  WHERE file_path = ?`
+						var seasonNumberVal sql.NullInt64
+						if seasonNumCopy != nil {
+							seasonNumberVal.Int64 = int64(*seasonNumCopy)
+							seasonNumberVal.Valid = true
+						}
+						_, err := tx.Exec(query, processingStatus, currentTime, tmdbIDCopy, seasonNumberVal, filePath)
+						return err
+					})
+				}
+			}
+
+			// A path is only inserted once even if it shows up again later in the scan
+			existingFileMap[path] = true
+		}
+
+		// The checkpoint only advances once every write of the chunk has been
+		// committed; until then the previous one stays in place
+		if err := writer.Flush(); err != nil {
+			return fmt.Errorf("failed to write scan batch: %w", err)
+		}
+		if err := SaveSourceScanCheckpoint(scanID, sourceIndex, jobs[len(jobs)-1].path); err != nil {
+			return fmt.Errorf("failed to save scan checkpoint: %w", err)
+		}
+		return nil
+	}
+
+	err = walker.walk(sourceDir, walkRoot, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 
-	err = filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			logger.Warn("Error accessing path %s: %v", path, err)
 			return nil
@@ -604,10 +1037,22 @@ func scanSourceDirectory(sourceDir string, sourceIndex int) (totalFiles, discove
 
 		// Skip directories
 		if info.IsDir() {
+			// Walk visits entries in lexical order, so whole directories that
+			// sort before the checkpoint were already finished
+			if resumeAfter != "" && path != walkRoot && walkOrderKey(path) < walkOrderKey(resumeAfter) && !strings.HasPrefix(resumeAfter, path+string(filepath.Separator)) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
-		totalFiles++
+		if resumeAfter != "" && walkOrderKey(path) <= walkOrderKey(resumeAfter) {
+			return nil
+		}
+
+		if reason := filter.exclusionReason(path, info); reason != "" {
+			exclusions[reason]++
+			return nil
+		}
 
 		// Get relative path
 		relPath, err := filepath.Rel(sourceDir, path)
@@ -615,117 +1060,29 @@ func scanSourceDirectory(sourceDir string, sourceIndex int) (totalFiles, discove
 			relPath = path
 		}
 
-		// Check if file is a media file
-		isMedia := isMediaFile(path)
-		mediaType := ""
-		if isMedia {
-			mediaType = detectMediaType(info.Name())
-		}
-
-		// Format file size
-		sizeFormatted := formatFileSize(info.Size())
-
-		exists := existingFileMap[path]
-		processingStatus := "unprocessed"
-		var tmdbID string
-		var seasonNum *int
-
-		if mediaHubDB, err := GetDatabaseConnection(); err == nil {
-			status, tmdbIDVal, seasonNumber := checkFileInMediaHub(mediaHubDB, path)
-			processingStatus = status
-			tmdbID = tmdbIDVal
-			seasonNum = seasonNumber
-		}
-
-		if !exists {
-			discovered++
-			filePath, fileName, fileSize, fileSizeFormatted := path, info.Name(), info.Size(), sizeFormatted
-			modTime, relativePathCopy, fileExt := info.ModTime().Unix(), relPath, filepath.Ext(path)
-			currentTime := time.Now().Unix()
-
-			insertOperations = append(insertOperations, func(tx *sql.Tx) error {
-				query := `INSERT INTO source_files
-					(file_path, file_name, file_size, file_size_formatted, modified_time,
-					 is_media_file, media_type, source_index, source_directory, relative_path,
-					 file_extension, discovered_at, last_seen_at, is_active, processing_status)
-					VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-
-				_, err := tx.Exec(query,
-					filePath, fileName, fileSize, fileSizeFormatted, modTime,
-					isMedia, mediaType, sourceIndex, sourceDir, relativePathCopy,
-					fileExt, currentTime, currentTime, true, processingStatus)
-				return err
-			})
-
-			if tmdbID != "" {
-				tmdbIDCopy, seasonNumCopy := tmdbID, seasonNum
-				insertOperations = append(insertOperations, func(tx *sql.Tx) error {
-					query := `UPDATE source_files SET tmdb_id = ?, season_number = ? WHERE file_path = ?`
-					var seasonNumberVal sql.NullInt64
-					if seasonNumCopy != nil {
-						seasonNumberVal.Int64 = int64(*seasonNumCopy)
-						seasonNumberVal.Valid = true
-					}
-					_, err := tx.Exec(query, tmdbIDCopy, seasonNumberVal, filePath)
-					return err
-				})
-			}
-		} else {
-			updated++
-			filePath, fileSize, fileSizeFormatted := path, info.Size(), sizeFormatted
-			modTime, currentTime := info.ModTime().Unix(), time.Now().Unix()
-
-			updateOperations = append(updateOperations, func(tx *sql.Tx) error {
-				query := `UPDATE source_files SET
-					file_size = ?, file_size_formatted = ?, modified_time = ?,
-					is_media_file = ?, media_type = ?, last_seen_at = ?, is_active = ?
-					WHERE file_path = ?`
-
-				_, err := tx.Exec(query,
-					fileSize, fileSizeFormatted, modTime,
-					isMedia, mediaType, currentTime, true,
-					filePath)
-				return err
-			})
-
-			if tmdbID != "" && processingStatus != "unprocessed" {
-				tmdbIDCopy, seasonNumCopy := tmdbID, seasonNum
-				updateOperations = append(updateOperations, func(tx *sql.Tx) error {
-					query := `UPDATE source_files SET processing_status = ?, last_processed_at = ?, tmdb_id = ?, season_number = ?
-							  WHERE file_path = ?`
-					var seasonNumberVal sql.NullInt64
-					if seasonNumCopy != nil {
-						seasonNumberVal.Int64 = int64(*seasonNumCopy)
-						seasonNumberVal.Valid = true
-					}
-					_, err := tx.Exec(query, processingStatus, currentTime, tmdbIDCopy, seasonNumberVal, filePath)
-					return err
-				})
-			}
+		pending = append(pending, scanJob{path: path, relPath: relPath, info: info})
+		if len(pending) >= batchSize {
+			return processChunk()
 		}
-
 		return nil
 	})
 
+	if err == nil {
+		err = processChunk()
+	}
 	if err != nil {
 		return totalFiles, discovered, updated, err
 	}
 
-	if len(insertOperations) > 0 {
-		if err := BatchUpdateSourceFiles(insertOperations); err != nil {
-			logger.Error("Failed to execute batch insert operations: %v", err)
-		}
-	}
-
-	if len(updateOperations) > 0 {
-		if err := BatchUpdateSourceFiles(updateOperations); err != nil {
-			logger.Error("Failed to execute batch update operations: %v", err)
-		}
-	}
-
 	return totalFiles, discovered, updated, nil
 }
 
+// walkOrderKey maps a path to a string that sorts in the order the source
+// walker visits files, by ranking the separator below every other character
+func walkOrderKey(path string) string {
+	return strings.ReplaceAll(path, string(filepath.Separator), "\x00")
+}
+
 // isMediaFile checks if a file is a media file based on extension
 func isMediaFile(filePath string) bool {
 	ext := strings.ToLower(filepath.Ext(filePath))
@@ -813,7 +1170,7 @@ func updateProcessingStatusFromMediaHub() error {
 	}
 
 	// Check each file against MediaHub database and batch updates
-	var batchOperations []func(*sql.Tx) error
+	writer := newSourceBatchWriter(scanBatchSize())
 	updated := 0
 
 	for _, fileInfo := range fileInfos {
@@ -822,7 +1179,7 @@ func updateProcessingStatusFromMediaHub() error {
 		// Only update if the status has actually changed
 		if newStatus != fileInfo.status {
 			fp, st, tid, sn := fileInfo.path, newStatus, tmdbID, seasonNumber
-			batchOperations = append(batchOperations, func(tx *sql.Tx) error {
+			operation := func(tx *sql.Tx) error {
 				query := `UPDATE source_files SET processing_status = ?, last_processed_at = ?, tmdb_id = ?, season_number = ?
 						  WHERE file_path = ?`
 
@@ -848,16 +1205,15 @@ func updateProcessingStatusFromMediaHub() error {
 					updated++
 				}
 				return nil
-			})
+			}
+			if err := writer.Add(operation); err != nil {
+				return fmt.Errorf("failed to batch update processing status: %w", err)
+			}
 		}
 	}
 
-	// Execute batch updates if any
-	if len(batchOperations) > 0 {
-		err := BatchUpdateSourceFiles(batchOperations)
-		if err != nil {
-			logger.Error("Failed to batch update processing status: %v", err)
-		}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to batch update processing status: %w", err)
 	}
 
 	if updated > 0 {
@@ -949,66 +1305,16 @@ func HandleSourceScans(w http.ResponseWriter, r *http.Request) {
 
 // handleGetSourceScans retrieves source scan history
 func handleGetSourceScans(w http.ResponseWriter, r *http.Request) {
-	// Parse query parameters
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
-
-	limit := 20
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-		}
-	}
-
-	offset := 0
-	if offsetStr != "" {
-		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-			offset = o
-		}
-	}
+	page := paging.Parse(r, 20, 0)
 
 	var scans []SourceScan
 	var total int
 
 	err := executeReadOperation(func(sourceDB *sql.DB) error {
-		// Query scans
-		query := `SELECT id, scan_type, started_at, completed_at, status, files_discovered,
-				  files_updated, files_removed, total_files, error_message, scan_duration_ms
-				  FROM source_scans ORDER BY started_at DESC LIMIT ? OFFSET ?`
-
-		rows, err := sourceDB.Query(query, limit, offset)
+		var err error
+		scans, err = querySourceScans(sourceDB, page.Limit, page.Offset)
 		if err != nil {
-			return fmt.Errorf("failed to query source scans: %w", err)
-		}
-		defer rows.Close()
-
-		for rows.Next() {
-			var scan SourceScan
-			var completedAt sql.NullInt64
-			var errorMessage sql.NullString
-			var scanDurationMs sql.NullInt64
-
-			err := rows.Scan(
-				&scan.ID, &scan.ScanType, &scan.StartedAt, &completedAt, &scan.Status,
-				&scan.FilesDiscovered, &scan.FilesUpdated, &scan.FilesRemoved, &scan.TotalFiles,
-				&errorMessage, &scanDurationMs,
-			)
-			if err != nil {
-				logger.Error("Failed to scan source scan row: %v", err)
-				continue
-			}
-
-			if completedAt.Valid {
-				scan.CompletedAt = &completedAt.Int64
-			}
-			if errorMessage.Valid {
-				scan.ErrorMessage = errorMessage.String
-			}
-			if scanDurationMs.Valid {
-				scan.ScanDurationMs = &scanDurationMs.Int64
-			}
-
-			scans = append(scans, scan)
+			return err
 		}
 
 		// Count total records
@@ -1030,16 +1336,67 @@ func handleGetSourceScans(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response := map[string]interface{}{
-		"scans":      scans,
-		"total":      total,
-		"page":       (offset / limit) + 1,
-		"limit":      limit,
-		"totalPages": (total + limit - 1) / limit,
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(paging.NewResponse(scans, total, page))
+}
+
+// querySourceScans reads a page of scans, newest first
+func querySourceScans(sourceDB *sql.DB, limit, offset int) ([]SourceScan, error) {
+	query := `SELECT id, scan_type, started_at, completed_at, status, files_discovered,
+			  files_updated, files_removed, total_files, COALESCE(files_excluded, 0), COALESCE(dirs_skipped, 0), skipped_dirs, error_message, scan_duration_ms
+			  FROM source_scans ORDER BY started_at DESC LIMIT ? OFFSET ?`
+
+	rows, err := sourceDB.Query(query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query source scans: %w", err)
 	}
+	defer rows.Close()
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	var scans []SourceScan
+	for rows.Next() {
+		var scan SourceScan
+		var completedAt sql.NullInt64
+		var errorMessage sql.NullString
+		var skippedDirs sql.NullString
+		var scanDurationMs sql.NullInt64
+
+		err := rows.Scan(
+			&scan.ID, &scan.ScanType, &scan.StartedAt, &completedAt, &scan.Status,
+			&scan.FilesDiscovered, &scan.FilesUpdated, &scan.FilesRemoved, &scan.TotalFiles,
+			&scan.FilesExcluded, &scan.DirsSkipped, &skippedDirs, &errorMessage, &scanDurationMs,
+		)
+		if err != nil {
+			logger.Error("Failed to scan source scan row: %v", err)
+			continue
+		}
+
+		if completedAt.Valid {
+			scan.CompletedAt = &completedAt.Int64
+		}
+		if errorMessage.Valid {
+			scan.ErrorMessage = errorMessage.String
+		}
+		if skippedDirs.Valid {
+			json.Unmarshal([]byte(skippedDirs.String), &scan.SkippedDirs)
+		}
+		if scanDurationMs.Valid {
+			scan.ScanDurationMs = &scanDurationMs.Int64
+		}
+
+		scans = append(scans, scan)
+	}
+	return scans, rows.Err()
+}
+
+// GetRecentSourceScans returns the most recent source scans, newest first
+func GetRecentSourceScans(limit int) ([]SourceScan, error) {
+	var scans []SourceScan
+	err := executeReadOperation(func(sourceDB *sql.DB) error {
+		var err error
+		scans, err = querySourceScans(sourceDB, limit, 0)
+		return err
+	})
+	return scans, err
 }
 
 // handleGetLatestScan retrieves the most recent scan
@@ -1049,17 +1406,18 @@ func handleGetLatestScan(w http.ResponseWriter, r *http.Request) {
 	var scan SourceScan
 	var completedAt sql.NullInt64
 	var errorMessage sql.NullString
+	var skippedDirs sql.NullString
 	var scanDurationMs sql.NullInt64
 
 	err := executeReadOperation(func(sourceDB *sql.DB) error {
 		query := `SELECT id, scan_type, started_at, completed_at, status, files_discovered,
-				  files_updated, files_removed, total_files, error_message, scan_duration_ms
+				  files_updated, files_removed, total_files, COALESCE(files_excluded, 0), COALESCE(dirs_skipped, 0), skipped_dirs, error_message, scan_duration_ms
 				  FROM source_scans ORDER BY started_at DESC LIMIT 1`
 
 		return sourceDB.QueryRow(query).Scan(
 			&scan.ID, &scan.ScanType, &scan.StartedAt, &completedAt, &scan.Status,
 			&scan.FilesDiscovered, &scan.FilesUpdated, &scan.FilesRemoved, &scan.TotalFiles,
-			&errorMessage, &scanDurationMs,
+			&scan.FilesExcluded, &scan.DirsSkipped, &skippedDirs, &errorMessage, &scanDurationMs,
 		)
 	})
 
@@ -1078,6 +1436,9 @@ func handleGetLatestScan(w http.ResponseWriter, r *http.Request) {
 	if errorMessage.Valid {
 		scan.ErrorMessage = errorMessage.String
 	}
+	if skippedDirs.Valid {
+		json.Unmarshal([]byte(skippedDirs.String), &scan.SkippedDirs)
+	}
 	if scanDurationMs.Valid {
 		scan.ScanDurationMs = &scanDurationMs.Int64
 	}
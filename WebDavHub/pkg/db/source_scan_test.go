@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// useSourceDB opens a fresh source database in a temporary directory,
+// reopening the package's shared connection for it
+func useSourceDB(t testing.TB) {
+	t.Helper()
+	root := t.TempDir()
+	work := filepath.Join(root, "work")
+	if err := os.Mkdir(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+
+	resetPool := func() {
+		CloseSourceDB()
+		sourceDBPoolOnce = sync.Once{}
+	}
+	resetPool()
+	t.Cleanup(resetPool)
+
+	if err := InitSourceDB(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCancelledResumeKeepsTheCheckpoint(t *testing.T) {
+	useSourceDB(t)
+	source := t.TempDir()
+	t.Setenv("SOURCE_DIR", source)
+	for _, name := range []string{"a.mkv", "b.mkv", "c.mkv"} {
+		if err := os.WriteFile(filepath.Join(source, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkpoint := filepath.Join(source, "a.mkv")
+
+	interrupted, err := InsertSourceScan("manual")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveSourceScanCheckpoint(interrupted, 0, checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateSourceScan(interrupted, "cancelled", 1, 1, 0, 0, 0, context.Canceled); err != nil {
+		t.Fatal(err)
+	}
+
+	// Cancelled before the resumed scan commits a chunk of its own
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := runSourceScan(ctx, "manual", ScanModeResume, -1, ""); err == nil {
+		t.Fatal("cancelled scan reported success")
+	}
+
+	scanID, index, path, ok, err := GetResumableSourceScan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || index != 0 || path != checkpoint {
+		t.Fatalf("resumable checkpoint = %d %q (ok %v), want 0 %q", index, path, ok, checkpoint)
+	}
+	if scanID == interrupted {
+		t.Fatal("the resumed scan did not record the checkpoint it started from")
+	}
+}
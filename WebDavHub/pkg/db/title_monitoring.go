@@ -0,0 +1,152 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"cinesync/pkg/logger"
+)
+
+// Media types a monitored flag can be stored for
+const (
+	MonitoredMediaMovie = "movie"
+	MonitoredMediaTV    = "tv"
+)
+
+// TitleMonitoring is the stored monitored flag of a movie or series
+type TitleMonitoring struct {
+	TmdbID    int    `json:"tmdbId"`
+	MediaType string `json:"mediaType"`
+	Monitored bool   `json:"monitored"`
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+// titleMonitoringKey identifies a title in the in-memory copy of title_monitoring
+type titleMonitoringKey struct {
+	tmdbID    int
+	mediaType string
+}
+
+// monitoredTitles mirrors title_monitoring so spoofed list responses can look
+// up every title without a query each
+var (
+	monitoredTitles     map[titleMonitoringKey]bool
+	monitoredTitlesOnce sync.Once
+	monitoredTitlesMu   sync.RWMutex
+)
+
+// loadMonitoredTitles reads title_monitoring into memory on first use
+func loadMonitoredTitles() {
+	monitoredTitlesOnce.Do(func() {
+		titles := make(map[titleMonitoringKey]bool)
+		err := executeReadOperation(func(db *sql.DB) error {
+			rows, err := db.Query(`SELECT tmdb_id, media_type, monitored FROM title_monitoring`)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+				var key titleMonitoringKey
+				var monitored bool
+				if err := rows.Scan(&key.tmdbID, &key.mediaType, &monitored); err != nil {
+					continue
+				}
+				titles[key] = monitored
+			}
+			return rows.Err()
+		})
+		if err != nil {
+			logger.Warn("Failed to load monitored titles: %v", err)
+		}
+
+		monitoredTitlesMu.Lock()
+		monitoredTitles = titles
+		monitoredTitlesMu.Unlock()
+	})
+}
+
+// IsTitleMonitored reports whether a movie or series is monitored. Titles
+// default to monitored until they are explicitly unmonitored.
+func IsTitleMonitored(tmdbID int, mediaType string) bool {
+	loadMonitoredTitles()
+
+	monitoredTitlesMu.RLock()
+	defer monitoredTitlesMu.RUnlock()
+	monitored, ok := monitoredTitles[titleMonitoringKey{tmdbID, mediaType}]
+	return !ok || monitored
+}
+
+// UnmonitoredTitleIDs returns the TMDB ids of the titles of a media type that
+// were explicitly unmonitored; every other title is monitored
+func UnmonitoredTitleIDs(mediaType string) []int {
+	loadMonitoredTitles()
+
+	monitoredTitlesMu.RLock()
+	defer monitoredTitlesMu.RUnlock()
+	var ids []int
+	for key, monitored := range monitoredTitles {
+		if key.mediaType == mediaType && !monitored {
+			ids = append(ids, key.tmdbID)
+		}
+	}
+	return ids
+}
+
+// SetTitleMonitored persists the monitored flag of a movie or series
+func SetTitleMonitored(tmdbID int, mediaType string, monitored bool) error {
+	if tmdbID <= 0 {
+		return fmt.Errorf("invalid tmdb id: %d", tmdbID)
+	}
+	if mediaType != MonitoredMediaMovie && mediaType != MonitoredMediaTV {
+		return fmt.Errorf("invalid media type: %s", mediaType)
+	}
+	loadMonitoredTitles()
+
+	err := executeWriteOperationSync(func(db *sql.DB) error {
+		_, err := db.Exec(`INSERT INTO title_monitoring (tmdb_id, media_type, monitored, updated_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT(tmdb_id, media_type) DO UPDATE SET monitored = excluded.monitored, updated_at = excluded.updated_at`,
+			tmdbID, mediaType, monitored, time.Now().Unix())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save monitored state: %w", err)
+	}
+
+	monitoredTitlesMu.Lock()
+	monitoredTitles[titleMonitoringKey{tmdbID, mediaType}] = monitored
+	monitoredTitlesMu.Unlock()
+	return nil
+}
+
+// GetTitleMonitoring returns the stored monitored flags, optionally for one media type
+func GetTitleMonitoring(mediaType string) ([]TitleMonitoring, error) {
+	titles := []TitleMonitoring{}
+	err := executeReadOperation(func(db *sql.DB) error {
+		query := `SELECT tmdb_id, media_type, monitored, updated_at FROM title_monitoring`
+		var args []interface{}
+		if mediaType != "" {
+			query += ` WHERE media_type = ?`
+			args = append(args, mediaType)
+		}
+		query += ` ORDER BY media_type, tmdb_id`
+
+		rows, err := db.Query(query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var title TitleMonitoring
+			if err := rows.Scan(&title.TmdbID, &title.MediaType, &title.Monitored, &title.UpdatedAt); err != nil {
+				return err
+			}
+			titles = append(titles, title)
+		}
+		return rows.Err()
+	})
+	return titles, err
+}
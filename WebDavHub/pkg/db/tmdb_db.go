@@ -4,13 +4,11 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"math/rand"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
-	"time"
 	_ "modernc.org/sqlite"
 	"cinesync/pkg/logger"
 	"cinesync/pkg/env"
@@ -32,19 +30,11 @@ func StartTmdbCacheWriter() {
 	tmdbCacheWriteQueue = make(chan tmdbCacheWriteReq, 100)
 	go func() {
 		for req := range tmdbCacheWriteQueue {
-			for i := 0; i < 5; i++ { // retry up to 5 times
-				err := upsertTmdbCacheDirect(req.query, req.result)
-				if err == nil {
-					break
-				}
-				if strings.Contains(err.Error(), "database is locked") || strings.Contains(err.Error(), "SQLITE_BUSY") {
-					// Exponential backoff with jitter
-					delay := time.Duration(100*(1<<uint(i))) * time.Millisecond
-					time.Sleep(delay)
-					continue
-				}
+			err := RetryOnLock(func() error {
+				return upsertTmdbCacheDirect(req.query, req.result)
+			})
+			if err != nil {
 				fmt.Printf("TMDB cache write error: %v\n", err)
-				break
 			}
 		}
 	}()
@@ -267,27 +257,11 @@ func GetTmdbCache(cacheKey string) (string, error) {
 	defer func() { tmdbCacheSemaphore <- struct{}{} }()
 
 	var result string
-	var err error
-
-	// Retry logic for database busy errors
-	for attempt := 0; attempt < 5; attempt++ {
+	err := RetryOnLock(func() error {
+		var err error
 		result, err = getTmdbCacheWithoutRetry(cacheKey)
-		if err == nil {
-			return result, nil
-		}
-
-		// Check if it's a SQLite busy error
-		if strings.Contains(err.Error(), "database is locked") || strings.Contains(err.Error(), "SQLITE_BUSY") {
-			if attempt < 4 {
-				// Exponential backoff with jitter
-				delay := time.Duration(50*(1<<uint(attempt))) * time.Millisecond
-				time.Sleep(delay)
-				continue
-			}
-		}
-		return "", err
-	}
-
+		return err
+	})
 	return result, err
 }
 
@@ -470,26 +444,11 @@ func GetTmdbCacheByTmdbIdAndType(tmdbIDStr, mediaType string) (string, error) {
 	}
 
 	var result string
-
-	// Retry logic for database busy errors
-	for attempt := 0; attempt < 5; attempt++ {
+	err = RetryOnLock(func() error {
+		var err error
 		result, err = getTmdbCacheByTmdbIdAndTypeWithoutRetry(tmdbID, mediaType)
-		if err == nil {
-			return result, nil
-		}
-
-		// Check if it's a SQLite busy error
-		if strings.Contains(err.Error(), "database is locked") || strings.Contains(err.Error(), "SQLITE_BUSY") {
-			if attempt < 4 {
-				// Exponential backoff with jitter
-				delay := time.Duration(50*(1<<uint(attempt))) * time.Millisecond
-				time.Sleep(delay)
-				continue
-			}
-		}
-		return "", err
-	}
-
+		return err
+	})
 	return result, err
 }
 
@@ -627,32 +586,9 @@ type RecentMedia struct {
 
 // AddRecentMedia adds a new recent media item to the database, replacing duplicates
 func AddRecentMedia(media RecentMedia) error {
-	// Retry logic for database busy errors
-	maxRetries := 10
-	baseDelay := 50 * time.Millisecond
-
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		err := addRecentMediaWithoutRetry(media)
-		if err == nil {
-			return nil
-		}
-
-		// Check if it's a SQLite busy error
-		if strings.Contains(err.Error(), "database is locked") || strings.Contains(err.Error(), "SQLITE_BUSY") {
-			if attempt < maxRetries-1 {
-				delay := baseDelay * time.Duration(1<<uint(attempt))
-				if delay > 2*time.Second {
-					delay = 2*time.Second
-				}
-				jitter := time.Duration(rand.Int63n(int64(delay / 2)))
-				time.Sleep(delay + jitter)
-				continue
-			}
-		}
-		return err
-	}
-
-	return fmt.Errorf("operation failed after %d retries", maxRetries)
+	return RetryOnLock(func() error {
+		return addRecentMediaWithoutRetry(media)
+	})
 }
 
 // addRecentMediaWithoutRetry performs the actual database operation without retry logic
@@ -718,33 +654,16 @@ func GetMediaCounts(rootDir string) (movieCount int, showCount int, err error) {
 
 // GetAllStatsFromDB returns all stats from MediaHub database - no file system scanning
 func GetAllStatsFromDB() (totalFiles int, totalFolders int, totalSize int64, movieCount int, showCount int, err error) {
-	// Retry logic for database busy errors
-	maxRetries := 5
-	baseDelay := 100 * time.Millisecond
-
-	for attempt := 0; attempt < maxRetries; attempt++ {
+	err = RetryOnLock(func() error {
+		var err error
 		totalFiles, totalFolders, totalSize, movieCount, showCount, err = getAllStatsFromDBWithoutRetry()
-		if err == nil {
-			return totalFiles, totalFolders, totalSize, movieCount, showCount, nil
-		}
-
-		// Check if it's a SQLite busy error
-		if strings.Contains(err.Error(), "database is locked") || strings.Contains(err.Error(), "SQLITE_BUSY") {
-			if attempt < maxRetries-1 {
-				// Exponential backoff with jitter
-				delay := baseDelay * time.Duration(1<<uint(attempt))
-				jitter := time.Duration(rand.Int63n(int64(delay / 2)))
-				time.Sleep(delay + jitter)
-				continue
-			}
-		}
-		// For non-busy errors, return immediately
+		return err
+	})
+	if err != nil {
 		logger.Warn("Failed to get database stats: %v", err)
 		return 0, 0, 0, 0, 0, nil
 	}
-
-	logger.Warn("Failed to get database stats after %d retries: %v", maxRetries, err)
-	return 0, 0, 0, 0, 0, nil
+	return totalFiles, totalFolders, totalSize, movieCount, showCount, nil
 }
 
 // getAllStatsFromDBWithoutRetry performs the actual database queries without retry logic
@@ -805,33 +724,11 @@ func GetRecentMedia(limit int) ([]RecentMedia, error) {
 	}
 
 	var results []RecentMedia
-	var err error
-
-	// Retry logic for database busy errors
-	maxRetries := 10
-	baseDelay := 50 * time.Millisecond
-
-	for attempt := 0; attempt < maxRetries; attempt++ {
+	err := RetryOnLock(func() error {
+		var err error
 		results, err = getRecentMediaWithoutRetry()
-		if err == nil {
-			break
-		}
-
-		// Check if it's a SQLite busy error
-		if strings.Contains(err.Error(), "database is locked") || strings.Contains(err.Error(), "SQLITE_BUSY") {
-			if attempt < maxRetries-1 {
-				delay := baseDelay * time.Duration(1<<uint(attempt))
-				if delay > 2*time.Second {
-					delay = 2*time.Second
-				}
-				jitter := time.Duration(rand.Int63n(int64(delay / 2)))
-				time.Sleep(delay + jitter)
-				continue
-			}
-		}
-		return nil, err
-	}
-
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,516 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+	"cinesync/pkg/paging"
+	"cinesync/pkg/strm"
+)
+
+// trashPurgeInterval is how often trash past its retention is purged
+const trashPurgeInterval = time.Hour
+
+var (
+	errTrashDestinationExists = errors.New("destination already exists")
+	errTrashReprocessed       = errors.New("source file was processed again since it was pruned")
+	errTrashLinkMissing       = errors.New("trashed symlink no longer exists")
+	errTrashOutsideDest       = errors.New("destination is outside DESTINATION_DIR")
+)
+
+// TrashEntry is a pruned record kept in the trash until it is restored or
+// its retention ends
+type TrashEntry struct {
+	ID              string    `json:"id"`
+	SourcePath      string    `json:"sourcePath"`
+	DestinationPath string    `json:"destinationPath,omitempty"`
+	TmdbID          string    `json:"tmdbId,omitempty"`
+	SeasonNumber    string    `json:"seasonNumber,omitempty"`
+	HasLink         bool      `json:"hasLink"`
+	TrashedAt       time.Time `json:"trashedAt"`
+	ExpiresAt       time.Time `json:"expiresAt"`
+}
+
+// TrashListResponse is the body of GET /api/database/trash
+type TrashListResponse struct {
+	paging.PagedResponse[TrashEntry]
+	RetentionDays int `json:"retentionDays"`
+}
+
+// TrashRestoreRequest is the body of POST /api/database/trash/restore
+type TrashRestoreRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// TrashRestoreResult is the outcome of restoring one trash entry
+type TrashRestoreResult struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+var (
+	trashTableReady bool
+	trashTableMutex sync.Mutex
+	trashPurgerOnce sync.Once
+)
+
+// TrashRetention returns how long pruned records stay restorable, configured
+// through CINESYNC_TRASH_RETENTION_DAYS. Zero disables the trash, so prunes
+// delete permanently.
+func TrashRetention() time.Duration {
+	days := env.GetInt("CINESYNC_TRASH_RETENTION_DAYS", 30)
+	if days < 0 {
+		days = 0
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// trashDir returns the directory pruned symlinks are moved to. It lives next
+// to the databases rather than in the library, so media servers and MediaHub's
+// destination scans never pick up trashed links.
+func trashDir() string {
+	return env.GetString("CINESYNC_TRASH_DIR", filepath.Join("..", "db", ".cinesync-trash"))
+}
+
+// newTrashPath picks a unique trash location for the symlink of item
+func newTrashPath(item PruneItem) string {
+	name := "record"
+	if item.DestinationPath != "" {
+		name = filepath.Base(item.DestinationPath)
+	}
+	return filepath.Join(trashDir(), uuid.New().String()+"-"+name)
+}
+
+// ensureTrashTable creates the prune_trash table next to processed_files, so a
+// record and its trash entry change in one transaction
+func ensureTrashTable(mediaHubDB *sql.DB) error {
+	trashTableMutex.Lock()
+	defer trashTableMutex.Unlock()
+	if trashTableReady {
+		return nil
+	}
+
+	_, err := mediaHubDB.Exec(`
+		CREATE TABLE IF NOT EXISTS prune_trash (
+			id TEXT PRIMARY KEY,
+			source_path TEXT NOT NULL,
+			destination_path TEXT,
+			tmdb_id TEXT,
+			season_number TEXT,
+			trash_path TEXT,
+			record TEXT NOT NULL, -- processed_files row as JSON
+			trashed_at INTEGER NOT NULL,
+			expires_at INTEGER NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_prune_trash_expires_at ON prune_trash(expires_at);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create prune_trash table: %w", err)
+	}
+	trashTableReady = true
+	return nil
+}
+
+// moveLink recreates the symlink or .strm file at from under to and removes
+// the original. Unlike a rename this also works across file systems.
+func moveLink(from, to string) error {
+	info, err := os.Lstat(from)
+	if err != nil {
+		return fmt.Errorf("failed to inspect link: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if strm.IsFile(from, info) {
+		content, err := strm.Read(from)
+		if err != nil {
+			return fmt.Errorf("failed to read .strm file: %w", err)
+		}
+		if err := strm.Write(to, content); err != nil {
+			return fmt.Errorf("failed to write .strm file: %w", err)
+		}
+	} else {
+		target, err := os.Readlink(from)
+		if err != nil {
+			return fmt.Errorf("failed to read symlink: %w", err)
+		}
+		if err := os.Symlink(target, to); err != nil {
+			return fmt.Errorf("failed to create symlink: %w", err)
+		}
+	}
+	if err := os.Remove(from); err != nil {
+		os.Remove(to)
+		return fmt.Errorf("failed to remove link: %w", err)
+	}
+	return nil
+}
+
+// trashRecordTx copies the processed_files row of item into the trash. The
+// whole row is kept as JSON, so columns MediaHub adds later survive a restore.
+func trashRecordTx(tx *sql.Tx, item PruneItem, trashPath string) error {
+	columns, err := tableColumns(tx, "processed_files")
+	if err != nil {
+		return err
+	}
+	rows, err := tx.Query(`SELECT `+rawColumnList(columns)+` FROM processed_files WHERE file_path = ?`, item.SourcePath)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		// Already gone, nothing to keep
+		return nil
+	}
+	record, err := scanRecord(rows, columns)
+	if err != nil {
+		return err
+	}
+	rows.Close()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(trashPath); err != nil {
+		trashPath = ""
+	}
+	now := time.Now()
+	_, err = tx.Exec(`INSERT INTO prune_trash (id, source_path, destination_path, tmdb_id, season_number, trash_path, record, trashed_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		uuid.New().String(), item.SourcePath, item.DestinationPath, item.TmdbID, item.SeasonNumber,
+		trashPath, string(data), now.Unix(), now.Add(TrashRetention()).Unix())
+	return err
+}
+
+// ListTrash returns the trash entries, most recently pruned first
+func ListTrash(limit, offset int) ([]TrashEntry, int, error) {
+	mediaHubDB, err := GetDatabaseConnection()
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := ensureTrashTable(mediaHubDB); err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	if err := mediaHubDB.QueryRow(`SELECT COUNT(*) FROM prune_trash`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := mediaHubDB.Query(`
+		SELECT id, source_path, COALESCE(destination_path, ''), COALESCE(tmdb_id, ''), COALESCE(season_number, ''),
+			COALESCE(trash_path, ''), trashed_at, expires_at
+		FROM prune_trash
+		ORDER BY trashed_at DESC, id
+		LIMIT ? OFFSET ?`, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []TrashEntry{}
+	for rows.Next() {
+		var entry TrashEntry
+		var trashPath string
+		var trashedAt, expiresAt int64
+		if err := rows.Scan(&entry.ID, &entry.SourcePath, &entry.DestinationPath, &entry.TmdbID, &entry.SeasonNumber,
+			&trashPath, &trashedAt, &expiresAt); err != nil {
+			return nil, 0, err
+		}
+		entry.HasLink = trashPath != ""
+		entry.TrashedAt = time.Unix(trashedAt, 0)
+		entry.ExpiresAt = time.Unix(expiresAt, 0)
+		entries = append(entries, entry)
+	}
+	return entries, total, rows.Err()
+}
+
+// RestoreTrashEntry moves a trashed symlink back to its destination and puts
+// its record back into processed_files. Nothing is overwritten: the restore
+// fails when the destination exists or the source was processed again.
+func RestoreTrashEntry(id string) error {
+	mediaHubDB, err := GetDatabaseConnection()
+	if err != nil {
+		return err
+	}
+	if err := ensureTrashTable(mediaHubDB); err != nil {
+		return err
+	}
+
+	var sourcePath, destinationPath, trashPath, recordJSON string
+	err = mediaHubDB.QueryRow(`SELECT source_path, COALESCE(destination_path, ''), COALESCE(trash_path, ''), record
+		FROM prune_trash WHERE id = ?`, id).Scan(&sourcePath, &destinationPath, &trashPath, &recordJSON)
+	if err == sql.ErrNoRows {
+		return Permanent(errors.New("trash entry not found"))
+	}
+	if err != nil {
+		return err
+	}
+
+	var record map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(recordJSON))
+	decoder.UseNumber()
+	if err := decoder.Decode(&record); err != nil {
+		return Permanent(fmt.Errorf("invalid trashed record: %w", err))
+	}
+
+	var exists int
+	if err := mediaHubDB.QueryRow(`SELECT COUNT(*) FROM processed_files WHERE file_path = ?`, sourcePath).Scan(&exists); err != nil {
+		return err
+	}
+	if exists > 0 {
+		return Permanent(errTrashReprocessed)
+	}
+
+	linkRestored := false
+	if trashPath != "" && destinationPath != "" {
+		if destinationRoot(destinationPath) == "" {
+			return Permanent(errTrashOutsideDest)
+		}
+		if _, err := os.Lstat(destinationPath); err == nil {
+			return Permanent(errTrashDestinationExists)
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to inspect destination: %w", err)
+		}
+		if _, err := os.Lstat(trashPath); os.IsNotExist(err) {
+			return Permanent(errTrashLinkMissing)
+		}
+		if err := moveLink(trashPath, destinationPath); err != nil {
+			return err
+		}
+		linkRestored = true
+	}
+
+	err = WithDatabaseTransaction(func(tx *sql.Tx) error {
+		if err := insertRecordTx(tx, record); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM prune_trash WHERE id = ?`, id)
+		return err
+	})
+	if err != nil {
+		if linkRestored {
+			if moveErr := moveLink(destinationPath, trashPath); moveErr != nil {
+				logger.Warn("Failed to move %s back to the trash: %v", destinationPath, moveErr)
+			}
+		}
+		return fmt.Errorf("failed to restore database record: %w", err)
+	}
+
+	logger.Info("Restored pruned file from trash: %s", sourcePath)
+	return nil
+}
+
+// insertRecordTx inserts a trashed processed_files row. Columns the table no
+// longer has are dropped.
+func insertRecordTx(tx *sql.Tx, record map[string]interface{}) error {
+	tableColumnList, err := tableColumns(tx, "processed_files")
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(tableColumnList))
+	for _, column := range tableColumnList {
+		known[column] = true
+	}
+
+	var columns []string
+	var args []interface{}
+	for column, value := range record {
+		if !known[column] {
+			continue
+		}
+		columns = append(columns, column)
+		args = append(args, recordValue(value))
+	}
+	if len(columns) == 0 {
+		return errors.New("trashed record has no known columns")
+	}
+
+	_, err = tx.Exec(`INSERT INTO processed_files (`+quotedColumnList(columns)+`) VALUES (`+placeholders(len(columns))+`)`, args...)
+	return err
+}
+
+// PurgeExpiredTrash permanently removes trash entries past their retention
+// and returns how many were removed
+func PurgeExpiredTrash() (int, error) {
+	mediaHubDB, err := GetDatabaseConnection()
+	if err != nil {
+		return 0, err
+	}
+	if err := ensureTrashTable(mediaHubDB); err != nil {
+		return 0, err
+	}
+
+	rows, err := mediaHubDB.Query(`SELECT id, COALESCE(trash_path, '') FROM prune_trash WHERE expires_at <= ?`, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	type expiredEntry struct{ id, trashPath string }
+	var expired []expiredEntry
+	for rows.Next() {
+		var entry expiredEntry
+		if err := rows.Scan(&entry.id, &entry.trashPath); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		expired = append(expired, entry)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, entry := range expired {
+		if entry.trashPath != "" {
+			if err := os.Remove(entry.trashPath); err != nil && !os.IsNotExist(err) {
+				logger.Warn("Failed to purge trashed symlink %s: %v", entry.trashPath, err)
+				continue
+			}
+		}
+		if _, err := mediaHubDB.Exec(`DELETE FROM prune_trash WHERE id = ?`, entry.id); err != nil {
+			logger.Warn("Failed to purge trash entry %s: %v", entry.id, err)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// StartTrashPurger purges expired trash now and then every trashPurgeInterval
+func StartTrashPurger() {
+	trashPurgerOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(trashPurgeInterval)
+			defer ticker.Stop()
+
+			for {
+				if purged, err := PurgeExpiredTrash(); err != nil {
+					logger.Warn("Failed to purge trash: %v", err)
+				} else if purged > 0 {
+					logger.Info("Purged %d trash entries past retention", purged)
+				}
+				<-ticker.C
+			}
+		}()
+	})
+}
+
+// HandleTrash serves GET /api/database/trash, listing pruned records that can
+// still be restored
+func HandleTrash(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	page := paging.Parse(r, 100, 1000)
+	entries, total, err := ListTrash(page.Limit, page.Offset)
+	if err != nil {
+		logger.Error("Failed to list trash: %v", err)
+		apierror.WriteError(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list trash")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TrashListResponse{
+		PagedResponse: paging.NewResponse(entries, total, page),
+		RetentionDays: int(TrashRetention() / (24 * time.Hour)),
+	})
+}
+
+// HandleTrashRestore serves POST /api/database/trash/restore, restoring the
+// trash entries with the given ids. Each entry succeeds or fails on its own.
+// A retry carrying the same Idempotency-Key gets the first run's response.
+func HandleTrashRestore(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if !auth.RequireAuthenticated(w, r) {
+		return
+	}
+
+	var req TrashRestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if len(req.IDs) == 0 {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "ids is required")
+		return
+	}
+	idempotent, ok := BeginIdempotentRequest(w, r, "trash_restore", req)
+	if !ok {
+		return
+	}
+	defer idempotent.Release()
+
+	batchID, err := StartOperationBatch("trash_restore")
+	if err != nil {
+		logger.Warn("Failed to start operation batch: %v", err)
+	}
+
+	retryPolicy := GetRetryPolicy()
+	results := make([]TrashRestoreResult, 0, len(req.IDs))
+	restored := 0
+	for _, id := range req.IDs {
+		retries, err := retryPolicy.Run(r.Context(), func() error {
+			return RestoreTrashEntry(id)
+		})
+
+		result := TrashRestoreResult{ID: id, Status: OperationResultSuccess}
+		if err != nil {
+			result.Status, result.Error = OperationResultFailed, ClassifyPermissionError(err).Error()
+		} else {
+			restored++
+		}
+		results = append(results, result)
+
+		if batchID != "" {
+			if err := RecordOperationResultWithRetries(batchID, id, "", result.Status, result.Error, retries); err != nil {
+				logger.Warn("Failed to record result for batch %s: %v", batchID, err)
+			}
+		}
+	}
+
+	if batchID != "" {
+		if err := CompleteOperationBatch(batchID, "completed"); err != nil {
+			logger.Warn("Failed to complete batch %s: %v", batchID, err)
+		}
+	}
+
+	if restored > 0 {
+		InvalidateFolderCache()
+		NotifyLibraryChanged()
+		NotifyFileOperationChanged()
+	}
+
+	idempotent.WriteJSON(w, http.StatusOK, batchID, map[string]interface{}{
+		"batchId":  batchID,
+		"restored": restored,
+		"failed":   len(req.IDs) - restored,
+		"results":  results,
+	})
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// useMediaHubDB runs the test against fresh databases, the MediaHub one
+// holding a minimal processed_files table and the trash
+func useMediaHubDB(t testing.TB) *sql.DB {
+	t.Helper()
+	useSourceDB(t)
+	resetPool := func() {
+		CloseDatabasePool()
+		dbPoolOnce = sync.Once{}
+		trashTableMutex.Lock()
+		trashTableReady = false
+		trashTableMutex.Unlock()
+	}
+	resetPool()
+	t.Cleanup(resetPool)
+
+	// Pruning also clears the file from the recent media list
+	if err := InitDB(""); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		db = nil
+	})
+	mediaHubDB, err := GetDatabaseConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mediaHubDB.Exec(`CREATE TABLE processed_files (
+		file_path TEXT PRIMARY KEY,
+		destination_path TEXT,
+		tmdb_id TEXT,
+		season_number TEXT,
+		reason TEXT
+	)`); err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureTrashTable(mediaHubDB); err != nil {
+		t.Fatal(err)
+	}
+	return mediaHubDB
+}
+
+// addLinkedRecord creates a source file, its destination symlink and the
+// processed_files row linking them
+func addLinkedRecord(t testing.TB, mediaHubDB *sql.DB, source, destination string) PruneItem {
+	t.Helper()
+	if err := os.WriteFile(source, []byte("media"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(destination), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(source, destination); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mediaHubDB.Exec(`INSERT INTO processed_files (file_path, destination_path, tmdb_id) VALUES (?, ?, ?)`,
+		source, destination, "603"); err != nil {
+		t.Fatal(err)
+	}
+	return PruneItem{SourcePath: source, DestinationPath: destination, TmdbID: "603"}
+}
+
+func recordCount(t *testing.T, mediaHubDB *sql.DB, source string) int {
+	t.Helper()
+	var count int
+	if err := mediaHubDB.QueryRow(`SELECT COUNT(*) FROM processed_files WHERE file_path = ?`, source).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestPruneToTrashAndRestore(t *testing.T) {
+	mediaHubDB := useMediaHubDB(t)
+	source, dest := t.TempDir(), t.TempDir()
+	t.Setenv("SOURCE_DIR", source)
+	t.Setenv("DESTINATION_DIR", dest)
+	t.Setenv("CINESYNC_TRASH_DIR", t.TempDir())
+	t.Setenv("CINESYNC_TRASH_RETENTION_DAYS", "30")
+
+	item := addLinkedRecord(t, mediaHubDB, filepath.Join(source, "The.Matrix.1999.mkv"),
+		filepath.Join(dest, "Movies", "The Matrix (1999)", "The Matrix (1999).mkv"))
+
+	if result := pruneItem(item, false, newTrashPath(item)); result.err != nil {
+		t.Fatalf("pruneItem: %v", result.err)
+	}
+	if _, err := os.Lstat(item.DestinationPath); !os.IsNotExist(err) {
+		t.Fatalf("pruned link still exists: %v", err)
+	}
+	if recordCount(t, mediaHubDB, item.SourcePath) != 0 {
+		t.Fatal("pruned record is still in processed_files")
+	}
+
+	entries, total, err := ListTrash(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || entries[0].SourcePath != item.SourcePath || !entries[0].HasLink {
+		t.Fatalf("trash = %+v (total %d), want the pruned record with its link", entries, total)
+	}
+
+	if err := RestoreTrashEntry(entries[0].ID); err != nil {
+		t.Fatalf("RestoreTrashEntry: %v", err)
+	}
+	if target, err := os.Readlink(item.DestinationPath); err != nil || target != item.SourcePath {
+		t.Fatalf("restored link = %q, %v, want %q", target, err, item.SourcePath)
+	}
+	if recordCount(t, mediaHubDB, item.SourcePath) != 1 {
+		t.Fatal("restored record is missing from processed_files")
+	}
+	if _, total, _ := ListTrash(10, 0); total != 0 {
+		t.Fatalf("trash holds %d entries after the restore, want 0", total)
+	}
+}
+
+func TestPurgeExpiredTrashKeepsEntriesWithinRetention(t *testing.T) {
+	mediaHubDB := useMediaHubDB(t)
+	source, dest, trash := t.TempDir(), t.TempDir(), t.TempDir()
+	t.Setenv("SOURCE_DIR", source)
+	t.Setenv("DESTINATION_DIR", dest)
+	t.Setenv("CINESYNC_TRASH_DIR", trash)
+
+	kept := addLinkedRecord(t, mediaHubDB, filepath.Join(source, "kept.mkv"), filepath.Join(dest, "kept.mkv"))
+	expired := addLinkedRecord(t, mediaHubDB, filepath.Join(source, "expired.mkv"), filepath.Join(dest, "expired.mkv"))
+
+	t.Setenv("CINESYNC_TRASH_RETENTION_DAYS", "30")
+	if result := pruneItem(kept, false, newTrashPath(kept)); result.err != nil {
+		t.Fatal(result.err)
+	}
+	// No retention, so the entry expires as it is trashed
+	t.Setenv("CINESYNC_TRASH_RETENTION_DAYS", "0")
+	expiredTrashPath := newTrashPath(expired)
+	if result := pruneItem(expired, false, expiredTrashPath); result.err != nil {
+		t.Fatal(result.err)
+	}
+
+	purged, err := PurgeExpiredTrash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 1 {
+		t.Fatalf("purged %d entries, want 1", purged)
+	}
+	if _, err := os.Lstat(expiredTrashPath); !os.IsNotExist(err) {
+		t.Fatalf("purged symlink still exists: %v", err)
+	}
+	entries, total, err := ListTrash(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || entries[0].SourcePath != kept.SourcePath {
+		t.Fatalf("trash = %+v, want only %s", entries, kept.SourcePath)
+	}
+}
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"cinesync/pkg/paging"
+)
+
+// unmatchedReasons are the failure reasons MediaHub records when it could not
+// identify a file. Other failures, such as symlink errors, are not fixed by
+// choosing a title and stay out of the unmatched queue.
+var unmatchedReasons = []string{
+	"TMDB search failed",
+	"TMDb invalid show name",
+	"Name extraction failed",
+	"Anime info extraction failed",
+	"Sports parsing failed",
+}
+
+// IgnoredUnmatchedReason is recorded for unmatched files a user chose to
+// ignore. It is the reason `main.py --skip` writes, so MediaHub leaves the
+// file alone on later scans.
+const IgnoredUnmatchedReason = "Skipped by user"
+
+// ErrUnmatchedNotFound is returned when a path is not in the unmatched queue
+var ErrUnmatchedNotFound = errors.New("file is not in the unmatched queue")
+
+// failureGuessPattern pulls the parsed title out of MediaHub's failure
+// messages, e.g. "No TMDB results found for movie: Heat (1995)"
+var failureGuessPattern = regexp.MustCompile(`for (movie|show|anime show): (.+?) \(([^()]*)\)$`)
+
+// UnmatchedGuess is what MediaHub parsed from a file before the lookup failed
+type UnmatchedGuess struct {
+	Title     string `json:"title"`
+	Year      string `json:"year,omitempty"`
+	MediaType string `json:"mediaType,omitempty"`
+}
+
+// UnmatchedFile is a file MediaHub could not identify
+type UnmatchedFile struct {
+	ID       string          `json:"id"`
+	FilePath string          `json:"filePath"`
+	FileName string          `json:"fileName"`
+	Reason   string          `json:"reason"`
+	Error    string          `json:"error,omitempty"`
+	FailedAt string          `json:"failedAt,omitempty"`
+	Guess    *UnmatchedGuess `json:"guess,omitempty"`
+}
+
+// unmatchedWhere selects unmatched records, optionally narrowed by a search
+func unmatchedWhere(search string) (string, []interface{}) {
+	where := "reason IN (" + placeholders(len(unmatchedReasons)) + ")"
+	args := make([]interface{}, 0, len(unmatchedReasons)+2)
+	for _, reason := range unmatchedReasons {
+		args = append(args, reason)
+	}
+	if search != "" {
+		pattern := "%" + search + "%"
+		where += " AND (file_path LIKE ? OR error_message LIKE ?)"
+		args = append(args, pattern, pattern)
+	}
+	return where, args
+}
+
+// ListUnmatched returns one page of unmatched files, newest first, and the
+// number of unmatched files matching search
+func ListUnmatched(search string, req paging.Request) ([]UnmatchedFile, int, error) {
+	mediaHubDB, err := GetReadConnection()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get database connection: %w", err)
+	}
+
+	where, args := unmatchedWhere(search)
+	var total int
+	if err := mediaHubDB.QueryRow("SELECT COUNT(*) FROM processed_files WHERE "+where, args...).Scan(&total); err != nil {
+		if strings.Contains(err.Error(), "no such table") {
+			return []UnmatchedFile{}, 0, nil
+		}
+		return nil, 0, fmt.Errorf("failed to count unmatched files: %w", err)
+	}
+
+	rows, err := mediaHubDB.Query(`
+		SELECT file_path, reason, COALESCE(error_message, ''), COALESCE(processed_at, '')
+		FROM processed_files WHERE `+where+`
+		ORDER BY rowid DESC
+		LIMIT ? OFFSET ?`, append(args, req.Limit, req.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query unmatched files: %w", err)
+	}
+	defer rows.Close()
+
+	files := make([]UnmatchedFile, 0)
+	for rows.Next() {
+		file, err := scanUnmatched(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan unmatched file: %w", err)
+		}
+		files = append(files, file)
+	}
+	return files, total, rows.Err()
+}
+
+// GetUnmatched returns the unmatched record for a source path
+func GetUnmatched(filePath string) (*UnmatchedFile, error) {
+	mediaHubDB, err := GetReadConnection()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %w", err)
+	}
+
+	where, args := unmatchedWhere("")
+	row := mediaHubDB.QueryRow(`
+		SELECT file_path, reason, COALESCE(error_message, ''), COALESCE(processed_at, '')
+		FROM processed_files WHERE file_path = ? AND `+where, append([]interface{}{filePath}, args...)...)
+	file, err := scanUnmatched(row)
+	if err == sql.ErrNoRows || (err != nil && strings.Contains(err.Error(), "no such table")) {
+		return nil, ErrUnmatchedNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read unmatched file: %w", err)
+	}
+	return &file, nil
+}
+
+// IgnoreUnmatched takes a file out of the unmatched queue for good
+func IgnoreUnmatched(filePath string) error {
+	return WithDatabaseTransaction(func(tx *sql.Tx) error {
+		where, args := unmatchedWhere("")
+		result, err := tx.Exec(`UPDATE processed_files SET reason = ?, error_message = NULL WHERE file_path = ? AND `+where,
+			append([]interface{}{IgnoredUnmatchedReason, filePath}, args...)...)
+		if err != nil {
+			return fmt.Errorf("failed to ignore unmatched file: %w", err)
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return ErrUnmatchedNotFound
+		}
+		return nil
+	})
+}
+
+func scanUnmatched(row interface{ Scan(...interface{}) error }) (UnmatchedFile, error) {
+	var file UnmatchedFile
+	if err := row.Scan(&file.FilePath, &file.Reason, &file.Error, &file.FailedAt); err != nil {
+		return file, err
+	}
+	file.ID = fmt.Sprintf("%x", sha256.Sum256([]byte(file.FilePath)))
+	file.FileName = filepath.Base(file.FilePath)
+	file.Guess = guessFromFailure(file.Error)
+	return file, nil
+}
+
+// guessFromFailure recovers the title, year and type MediaHub searched for
+func guessFromFailure(message string) *UnmatchedGuess {
+	match := failureGuessPattern.FindStringSubmatch(strings.TrimSpace(message))
+	if match == nil {
+		return nil
+	}
+
+	guess := &UnmatchedGuess{Title: strings.TrimSpace(match[2]), MediaType: "tv"}
+	if match[1] == "movie" {
+		guess.MediaType = "movie"
+	}
+	if year := strings.TrimSpace(match[3]); year != "" && year != "None" {
+		guess.Year = year
+	}
+	return guess
+}
@@ -0,0 +1,135 @@
+// Package diskspace keeps batches from filling the destination disk. A batch
+// is refused before it starts when the space its link mode consumes would
+// leave less than the configured reserve free, and a running batch is stopped
+// once the reserve is breached.
+package diskspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/dashboard"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+	"cinesync/pkg/strm"
+)
+
+// strmFileBytes is what one .strm file takes on disk: a few bytes of content
+// rounded up to a filesystem block
+const strmFileBytes = 4096
+
+// CheckInterval is how often Watch looks at the destination
+const CheckInterval = 30 * time.Second
+
+// ErrInsufficientSpace is returned when an operation would leave the
+// destination below the configured free space threshold
+var ErrInsufficientSpace = errors.New("insufficient free space on destination")
+
+// minFreeBytes returns the free space that must remain on a disk of the given
+// size, the larger of CINESYNC_MIN_FREE_BYTES and CINESYNC_MIN_FREE_PERCENT
+func minFreeBytes(total int64) int64 {
+	minFree := env.GetSize("CINESYNC_MIN_FREE_BYTES", 0)
+	if percent := env.GetInt("CINESYNC_MIN_FREE_PERCENT", 0); percent > 0 && total > 0 {
+		if byPercent := total / 100 * int64(percent); byPercent > minFree {
+			minFree = byPercent
+		}
+	}
+	return minFree
+}
+
+// EstimateRequired returns the destination space linking the files below
+// sources will consume, by the link mode of each file's library: symlinks only
+// add directory entries and are treated as free, .strm files take a block each.
+func EstimateRequired(sources []string) int64 {
+	if !strm.Enabled() {
+		return 0
+	}
+
+	var required int64
+	for _, source := range sources {
+		filepath.WalkDir(source, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			if d.Type().IsRegular() && strm.LibraryMode(path) == strm.ModeStrm {
+				required += strmFileBytes
+			}
+			return nil
+		})
+	}
+	return required
+}
+
+// Check refuses an operation needing requiredBytes when it would leave less
+// than the configured minimum free on the destination. Operations that need
+// no space are never refused.
+func Check(destDir string, requiredBytes int64) error {
+	if requiredBytes <= 0 {
+		return nil
+	}
+	return checkFree(destDir, requiredBytes)
+}
+
+// checkFree fails when less than requiredBytes plus the reserve is free on
+// the destination
+func checkFree(destDir string, requiredBytes int64) error {
+	total, used, err := Usage(destDir)
+	if err != nil || total == 0 {
+		// Don't block operations when free space can't be determined
+		logger.Warn("Unable to determine free space for %s: %v", destDir, err)
+		return nil
+	}
+
+	available := total - used
+	reserved := minFreeBytes(total)
+	if available-requiredBytes < reserved {
+		return fmt.Errorf("%w: %s required plus %s reserved, %s available on %s",
+			ErrInsufficientSpace, dashboard.FormatSize(requiredBytes), dashboard.FormatSize(reserved), dashboard.FormatSize(available), destDir)
+	}
+	return nil
+}
+
+// Watch checks the destination every CheckInterval until ctx ends and calls
+// stop once, with the error, when less than the reserve remains free. Batches
+// that consume space run it next to their work, so they stop instead of
+// filling the disk when something else writes to it too.
+func Watch(ctx context.Context, destDir string, stop func(error)) {
+	ticker := time.NewTicker(CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := checkFree(destDir, 0); err != nil {
+				stop(err)
+				return
+			}
+		}
+	}
+}
+
+// Guard refuses requests that add to the destination, any method but GET,
+// HEAD, OPTIONS and DELETE, with 507 Insufficient Storage while less than the
+// reserve is free on DESTINATION_DIR. Deletes pass, they are how space is freed.
+func Guard(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodDelete:
+		default:
+			if destDir := env.GetString("DESTINATION_DIR", ""); destDir != "" {
+				if err := checkFree(destDir, 0); err != nil {
+					apierror.WriteError(w, http.StatusInsufficientStorage, apierror.CodeInsufficientSpace, err.Error())
+					return
+				}
+			}
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,87 @@
+package diskspace
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSources creates count media files in a new source directory
+func writeSources(t *testing.T, count int) string {
+	t.Helper()
+	dir := t.TempDir()
+	for i := 0; i < count; i++ {
+		if err := os.WriteFile(filepath.Join(dir, string(rune('a'+i))+".mkv"), []byte("media"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestEstimateRequiredSymlinkLibraryIsFree(t *testing.T) {
+	source := writeSources(t, 3)
+	t.Setenv("CINESYNC_LIBRARY_LINK_MODES", "")
+
+	if required := EstimateRequired([]string{source}); required != 0 {
+		t.Fatalf("symlink library needs %d bytes, want 0", required)
+	}
+}
+
+func TestEstimateRequiredStrmLibrary(t *testing.T) {
+	source := writeSources(t, 3)
+	other := writeSources(t, 2)
+	t.Setenv("CINESYNC_LIBRARY_LINK_MODES", source+"=strm;"+other+"=symlink")
+
+	if required := EstimateRequired([]string{source, other}); required != 3*strmFileBytes {
+		t.Fatalf("required = %d, want %d", required, 3*strmFileBytes)
+	}
+}
+
+func TestCheckRefusesBelowReserve(t *testing.T) {
+	t.Setenv("CINESYNC_MIN_FREE_PERCENT", "100")
+
+	err := Check(t.TempDir(), strmFileBytes)
+	if !errors.Is(err, ErrInsufficientSpace) {
+		t.Fatalf("Check = %v, want ErrInsufficientSpace", err)
+	}
+}
+
+func TestCheckNeverRefusesSymlinkBatches(t *testing.T) {
+	t.Setenv("CINESYNC_MIN_FREE_PERCENT", "100")
+
+	if err := Check(t.TempDir(), 0); err != nil {
+		t.Fatalf("Check of a batch needing no space = %v, want nil", err)
+	}
+}
+
+func TestCheckPassesWithinReserve(t *testing.T) {
+	t.Setenv("CINESYNC_MIN_FREE_BYTES", "")
+	t.Setenv("CINESYNC_MIN_FREE_PERCENT", "0")
+
+	if err := Check(t.TempDir(), strmFileBytes); err != nil {
+		t.Fatalf("Check = %v, want nil", err)
+	}
+}
+
+func TestGuard(t *testing.T) {
+	t.Setenv("DESTINATION_DIR", t.TempDir())
+	t.Setenv("CINESYNC_MIN_FREE_PERCENT", "100")
+	handler := Guard(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	for method, want := range map[string]int{
+		http.MethodPost:   http.StatusInsufficientStorage,
+		http.MethodDelete: http.StatusNoContent,
+		http.MethodGet:    http.StatusNoContent,
+	} {
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest(method, "/api/file-operations/bulk", nil))
+		if recorder.Code != want {
+			t.Errorf("%s answered %d, want %d", method, recorder.Code, want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package diskspace
+
+import "syscall"
+
+// Usage returns the size of the filesystem holding path and the bytes used on it
+func Usage(path string) (total, used int64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+
+	blockSize := int64(stat.Bsize)
+	total = int64(stat.Blocks) * blockSize
+	available := int64(stat.Bavail) * blockSize
+
+	return total, total - available, nil
+}
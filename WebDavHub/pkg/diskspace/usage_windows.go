@@ -1,7 +1,7 @@
 //go:build windows
 // +build windows
 
-package api
+package diskspace
 
 import (
 	"fmt"
@@ -10,7 +10,8 @@ import (
 	"golang.org/x/sys/windows"
 )
 
-func getDiskUsage(path string) (total, used int64, err error) {
+// Usage returns the size of the filesystem holding path and the bytes used on it
+func Usage(path string) (total, used int64, err error) {
 	drive := filepath.VolumeName(path)
 	if drive == "" {
 		return 0, 0, fmt.Errorf("invalid path: %s", path)
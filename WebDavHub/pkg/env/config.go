@@ -1,16 +1,47 @@
 package env
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
 	"cinesync/pkg/logger"
 )
 
+var (
+	processEnvOnce sync.Once
+	processEnv     map[string]string
+)
+
+// captureProcessEnv remembers the variables the process was started with,
+// before .env and the profile add theirs
+func captureProcessEnv() {
+	processEnvOnce.Do(func() {
+		processEnv = make(map[string]string)
+		for _, entry := range os.Environ() {
+			if key, value, ok := strings.Cut(entry, "="); ok {
+				processEnv[key] = value
+			}
+		}
+	})
+}
+
+// ProcessEnv returns the value key had in the environment the process was
+// started with, as opposed to one loaded from .env later
+func ProcessEnv(key string) (string, bool) {
+	captureProcessEnv()
+	value, ok := processEnv[key]
+	return value, ok
+}
+
 // LoadEnv loads environment variables from .env file
 func LoadEnv() {
+    captureProcessEnv()
     cwd, err := os.Getwd()
     if err != nil {
         logger.Warn("Could not determine current working directory.")
@@ -26,6 +57,7 @@ func LoadEnv() {
     } else {
         logger.Debug("Environment variables loaded from %s", envPath)
     }
+    ApplyProfile()
 }
 
 
@@ -48,6 +80,7 @@ func ReloadEnv() error {
         return err
     }
 
+    ApplyProfile()
     logger.Info("Environment variables reloaded successfully from %s", envPath)
     return nil
 }
@@ -91,6 +124,63 @@ func IsBool(key string, defaultValue bool) bool {
 	return enabled
 }
 
+// ParseSize parses sizes such as "50MB", "1.5GB" or a plain byte count
+func ParseSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+
+	units := []struct {
+		suffix     string
+		multiplier float64
+	}{
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1},
+	}
+
+	multiplier := 1.0
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			multiplier = unit.multiplier
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			break
+		}
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size: %s", value)
+	}
+	return int64(number * multiplier), nil
+}
+
+// GetSize returns the environment variable as a byte count or a default if not set
+func GetSize(key string, defaultValue int64) int64 {
+	valueStr, exists := os.LookupEnv(key)
+	if !exists || strings.TrimSpace(valueStr) == "" {
+		return defaultValue
+	}
+
+	value, err := ParseSize(valueStr)
+	if err != nil {
+		logger.Warn("Environment variable %s is not a valid size, using default value %d instead", key, defaultValue)
+		return defaultValue
+	}
+
+	return value
+}
+
+// DefaultBridgeTimeout is the deadline of one-shot MediaHub commands when
+// CINESYNC_BRIDGE_TIMEOUT is unset or invalid
+const DefaultBridgeTimeout = 5 * time.Minute
+
+// BridgeTimeout returns the deadline for one-shot MediaHub commands, from
+// CINESYNC_BRIDGE_TIMEOUT
+func BridgeTimeout() time.Duration {
+	timeout, err := time.ParseDuration(GetString("CINESYNC_BRIDGE_TIMEOUT", DefaultBridgeTimeout.String()))
+	if err != nil || timeout <= 0 {
+		return DefaultBridgeTimeout
+	}
+	return timeout
+}
+
 // SetEnvVar sets an environment variable
 func SetEnvVar(key, value string) {
 	os.Setenv(key, value)
@@ -0,0 +1,104 @@
+package env
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"cinesync/pkg/logger"
+	"github.com/joho/godotenv"
+)
+
+// profileDefaults holds the settings each CINESYNC_PROFILE implies. prod
+// keeps authentication on, logs at INFO and only serves the web UI's own
+// origin; dev turns authentication off, logs at DEBUG and allows any origin.
+var profileDefaults = map[string]map[string]string{
+	"prod": {
+		"CINESYNC_AUTH_ENABLED": "true",
+		"LOG_LEVEL":             "INFO",
+		"CINESYNC_CORS_ORIGINS": "",
+	},
+	"dev": {
+		"CINESYNC_AUTH_ENABLED": "false",
+		"LOG_LEVEL":             "DEBUG",
+		"CINESYNC_CORS_ORIGINS": "*",
+	},
+}
+
+var (
+	profileMu      sync.Mutex
+	profileApplied = make(map[string]string)
+)
+
+// Profile returns the active CINESYNC_PROFILE, "dev" or "prod", or "" when
+// no valid profile is set
+func Profile() string {
+	profile := strings.ToLower(strings.TrimSpace(os.Getenv("CINESYNC_PROFILE")))
+	if _, ok := profileDefaults[profile]; !ok {
+		return ""
+	}
+	return profile
+}
+
+// ProfileDefaults returns the settings the active profile implies
+func ProfileDefaults() map[string]string {
+	defaults := make(map[string]string)
+	for key, value := range profileDefaults[Profile()] {
+		defaults[key] = value
+	}
+	return defaults
+}
+
+// ApplyProfile sets the defaults of the active profile for every variable
+// that is neither in the environment nor in .env, so explicit settings always
+// win. Defaults applied by an earlier call are dropped first, which lets it
+// run again after .env or CINESYNC_PROFILE change.
+func ApplyProfile() {
+	profileMu.Lock()
+	defer profileMu.Unlock()
+
+	explicit, _ := godotenv.Read(envFilePath())
+	for key, value := range profileApplied {
+		if _, inFile := explicit[key]; !inFile && os.Getenv(key) == value {
+			os.Unsetenv(key)
+		}
+	}
+	profileApplied = make(map[string]string)
+
+	name := strings.TrimSpace(os.Getenv("CINESYNC_PROFILE"))
+	profile := Profile()
+	if name != "" && profile == "" {
+		logger.Warn("Unknown CINESYNC_PROFILE '%s', expected 'dev' or 'prod'", name)
+		return
+	}
+
+	for key, value := range profileDefaults[profile] {
+		if _, exists := os.LookupEnv(key); exists {
+			continue
+		}
+		os.Setenv(key, value)
+		profileApplied[key] = value
+	}
+	if profile != "" {
+		logger.Debug("Applied %d defaults of the %s profile", len(profileApplied), profile)
+	}
+}
+
+// IsProfileDefault reports whether the current value of key was set by the
+// active profile rather than by the environment or .env
+func IsProfileDefault(key string) bool {
+	profileMu.Lock()
+	defer profileMu.Unlock()
+
+	value, ok := profileApplied[key]
+	return ok && os.Getenv(key) == value
+}
+
+func envFilePath() string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return filepath.Join("..", ".env")
+	}
+	return filepath.Join(filepath.Dir(cwd), ".env")
+}
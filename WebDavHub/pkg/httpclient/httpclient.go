@@ -0,0 +1,139 @@
+// Package httpclient builds the HTTP clients used for outbound calls to TMDB,
+// image hosts, Sonarr/Radarr and SAML identity providers. They honor
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY, trust the extra certificates in
+// CINESYNC_CA_BUNDLE and, for local development only, can skip certificate
+// verification with CINESYNC_TLS_INSECURE_SKIP_VERIFY.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// settings is the outbound configuration a transport was built from
+type settings struct {
+	httpProxy          string
+	httpsProxy         string
+	noProxy            string
+	caBundle           string
+	insecureSkipVerify bool
+}
+
+var (
+	transportMu      sync.Mutex
+	currentTransport *http.Transport
+	currentSettings  settings
+)
+
+// New returns a client with the given overall timeout, zero for none, that
+// sends its requests through the shared outbound transport
+func New(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: Transport()}
+}
+
+// Transport returns a RoundTripper that follows the outbound settings. It is
+// safe to keep in a package variable: a change to the settings, such as a
+// reload of .env, rebuilds the underlying transport on the next request.
+func Transport() http.RoundTripper {
+	return roundTripper{}
+}
+
+type roundTripper struct{}
+
+func (roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return sharedTransport().RoundTrip(req)
+}
+
+// lookupEnv reads the upper or lower case form of a proxy variable, as curl
+// and the Go standard library do
+func lookupEnv(name string) string {
+	if value := env.GetString(name, ""); value != "" {
+		return value
+	}
+	return env.GetString(strings.ToLower(name), "")
+}
+
+func loadSettings() settings {
+	return settings{
+		httpProxy:          lookupEnv("HTTP_PROXY"),
+		httpsProxy:         lookupEnv("HTTPS_PROXY"),
+		noProxy:            lookupEnv("NO_PROXY"),
+		caBundle:           strings.TrimSpace(env.GetString("CINESYNC_CA_BUNDLE", "")),
+		insecureSkipVerify: env.IsBool("CINESYNC_TLS_INSECURE_SKIP_VERIFY", false),
+	}
+}
+
+func sharedTransport() *http.Transport {
+	current := loadSettings()
+
+	transportMu.Lock()
+	defer transportMu.Unlock()
+	if currentTransport != nil && current == currentSettings {
+		return currentTransport
+	}
+
+	if currentTransport != nil {
+		currentTransport.CloseIdleConnections()
+	}
+	currentTransport = buildTransport(current)
+	currentSettings = current
+	return currentTransport
+}
+
+// buildTransport clones the default transport and applies the settings to it
+func buildTransport(s settings) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	proxy := (&httpproxy.Config{HTTPProxy: s.httpProxy, HTTPSProxy: s.httpsProxy, NoProxy: s.noProxy}).ProxyFunc()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+	if s.httpProxy != "" || s.httpsProxy != "" {
+		logger.Info("Outbound requests use proxy http=%q https=%q (no_proxy=%q)", s.httpProxy, s.httpsProxy, s.noProxy)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if s.caBundle != "" {
+		pool, err := loadCABundle(s.caBundle)
+		if err != nil {
+			logger.Error("Failed to load CINESYNC_CA_BUNDLE, using the system certificates only: %v", err)
+		} else {
+			tlsConfig.RootCAs = pool
+			logger.Info("Outbound requests trust the certificates in %s", s.caBundle)
+		}
+	}
+	if s.insecureSkipVerify {
+		logger.Warn("CINESYNC_TLS_INSECURE_SKIP_VERIFY is on: TLS certificates of outbound requests are NOT verified. " +
+			"Anyone on the network path can read and alter TMDB and Sonarr/Radarr traffic. Only use this for local development.")
+		tlsConfig.InsecureSkipVerify = true
+	}
+	transport.TLSClientConfig = tlsConfig
+	return transport
+}
+
+// loadCABundle returns the system certificate pool with the PEM certificates
+// of path added
+func loadCABundle(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", path)
+	}
+	return pool, nil
+}
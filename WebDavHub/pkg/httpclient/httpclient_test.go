@@ -0,0 +1,62 @@
+package httpclient
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useSettings clears every outbound setting so the test starts from none
+func useSettings(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy",
+		"CINESYNC_CA_BUNDLE", "CINESYNC_TLS_INSECURE_SKIP_VERIFY"} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestTransportFollowsProxySettings(t *testing.T) {
+	useSettings(t)
+	t.Setenv("https_proxy", "http://proxy.example.com:3128")
+	t.Setenv("NO_PROXY", "internal.example.com")
+
+	for target, want := range map[string]string{
+		"https://api.themoviedb.org/3": "http://proxy.example.com:3128",
+		"https://internal.example.com": "",
+		"http://api.themoviedb.org/3":  "",
+	} {
+		proxy, err := sharedTransport().Proxy(httptest.NewRequest("GET", target, nil))
+		got := ""
+		if proxy != nil {
+			got = proxy.String()
+		}
+		if err != nil || got != want {
+			t.Errorf("proxy for %s = %q, %v, want %q", target, got, err, want)
+		}
+	}
+}
+
+func TestTransportIsRebuiltWhenSettingsChange(t *testing.T) {
+	useSettings(t)
+	first := sharedTransport()
+	if sharedTransport() != first {
+		t.Fatal("transport was rebuilt without a settings change")
+	}
+
+	t.Setenv("CINESYNC_TLS_INSECURE_SKIP_VERIFY", "true")
+	second := sharedTransport()
+	if second == first || !second.TLSClientConfig.InsecureSkipVerify {
+		t.Fatal("transport did not pick up the changed settings")
+	}
+}
+
+func TestLoadCABundleRejectsFilesWithoutCertificates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundle.pem")
+	if err := os.WriteFile(path, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadCABundle(path); err == nil {
+		t.Fatal("bundle without certificates was accepted")
+	}
+}
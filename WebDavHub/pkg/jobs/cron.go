@@ -0,0 +1,168 @@
+package jobs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronDescriptors maps the @ shorthands to their five field expressions
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSchedule is a parsed five field cron expression:
+// minute hour day-of-month month day-of-week
+type cronSchedule struct {
+	minute  [60]bool
+	hour    [24]bool
+	day     [32]bool
+	month   [13]bool
+	weekday [7]bool
+	// A day matches either day field when both are restricted, as in cron
+	dayAny     bool
+	weekdayAny bool
+}
+
+// parseCronExpression parses a standard five field cron expression. Fields
+// accept *, single values, ranges, lists and steps (e.g. "*/15", "1-5",
+// "0,30"); the @hourly, @daily, @weekly, @monthly and @yearly shorthands are
+// also understood. Day-of-week 7 is Sunday, like 0.
+func parseCronExpression(expr string) (*cronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if descriptor, ok := cronDescriptors[strings.ToLower(expr)]; ok {
+		expr = descriptor
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression must have 5 fields, got %d", len(fields))
+	}
+
+	schedule := &cronSchedule{
+		dayAny:     fields[2] == "*" || fields[2] == "?",
+		weekdayAny: fields[4] == "*" || fields[4] == "?",
+	}
+
+	if err := parseCronField(fields[0], 0, 59, schedule.minute[:]); err != nil {
+		return nil, fmt.Errorf("invalid minute field: %v", err)
+	}
+	if err := parseCronField(fields[1], 0, 23, schedule.hour[:]); err != nil {
+		return nil, fmt.Errorf("invalid hour field: %v", err)
+	}
+	if err := parseCronField(fields[2], 1, 31, schedule.day[:]); err != nil {
+		return nil, fmt.Errorf("invalid day of month field: %v", err)
+	}
+	if err := parseCronField(fields[3], 1, 12, schedule.month[:]); err != nil {
+		return nil, fmt.Errorf("invalid month field: %v", err)
+	}
+
+	var weekdays [8]bool
+	if err := parseCronField(fields[4], 0, 7, weekdays[:]); err != nil {
+		return nil, fmt.Errorf("invalid day of week field: %v", err)
+	}
+	copy(schedule.weekday[:], weekdays[:7])
+	if weekdays[7] {
+		schedule.weekday[0] = true
+	}
+
+	return schedule, nil
+}
+
+// parseCronField sets the values matched by one comma separated field
+func parseCronField(field string, min, max int, values []bool) error {
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if slash := strings.Index(part, "/"); slash != -1 {
+			var err error
+			step, err = strconv.Atoi(part[slash+1:])
+			if err != nil || step <= 0 {
+				return fmt.Errorf("invalid step in %q", part)
+			}
+			part = part[:slash]
+		}
+
+		start, end := min, max
+		switch {
+		case part == "*" || part == "?":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err1, err2 error
+			start, err1 = strconv.Atoi(bounds[0])
+			end, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			value, err := strconv.Atoi(part)
+			if err != nil {
+				return fmt.Errorf("invalid value %q", part)
+			}
+			start = value
+			// "5/10" means every 10 starting at 5
+			if step == 1 {
+				end = value
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+		for value := start; value <= end; value += step {
+			values[value] = true
+		}
+	}
+	return nil
+}
+
+// dayMatches reports whether the day of t matches the day fields
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dayMatch := s.day[t.Day()]
+	weekdayMatch := s.weekday[int(t.Weekday())]
+	switch {
+	case s.dayAny && s.weekdayAny:
+		return true
+	case s.dayAny:
+		return weekdayMatch
+	case s.weekdayAny:
+		return dayMatch
+	default:
+		return dayMatch || weekdayMatch
+	}
+}
+
+// Next returns the first matching minute after t, or the zero time when the
+// expression never matches (e.g. February 30th)
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	limit := next.AddDate(5, 0, 0)
+
+	for next.Before(limit) {
+		if !s.month[int(next.Month())] {
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(next) {
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.hour[next.Hour()] {
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if !s.minute[next.Minute()] {
+			next = next.Add(time.Minute)
+			continue
+		}
+		return next
+	}
+	return time.Time{}
+}
@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JobEvent names what a JobStatusUpdate reports
+type JobEvent string
+
+const (
+	JobEventStarted   JobEvent = "started"
+	JobEventProgress  JobEvent = "progress"
+	JobEventCompleted JobEvent = "completed"
+	JobEventFailed    JobEvent = "failed"
+	JobEventCancelled JobEvent = "cancelled"
+	JobEventUpdated   JobEvent = "updated"
+)
+
+// progressInterval is the shortest time between two progress events of one
+// execution, so a chatty command cannot flood subscribers
+const progressInterval = time.Second
+
+const maxProgressMessage = 200
+
+// progressWriter collects the output of a job and reports a progress tick
+// for completed output lines, at most once per progressInterval
+type progressWriter struct {
+	mutex    sync.Mutex
+	output   bytes.Buffer
+	lines    int
+	lastTick time.Time
+	tick     func(lines int, lastLine string)
+}
+
+func (p *progressWriter) Write(data []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.output.Write(data)
+	completed := bytes.Count(data, []byte{'\n'})
+	if completed == 0 {
+		return len(data), nil
+	}
+	p.lines += completed
+
+	if now := time.Now(); now.Sub(p.lastTick) >= progressInterval {
+		p.lastTick = now
+		p.tick(p.lines, lastOutputLine(p.output.Bytes()))
+	}
+	return len(data), nil
+}
+
+// Output returns everything the job wrote so far
+func (p *progressWriter) Output() string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.output.String()
+}
+
+// lastOutputLine returns the last non-empty completed line, shortened for an
+// event message
+func lastOutputLine(output []byte) string {
+	end := bytes.LastIndexByte(output, '\n')
+	for end >= 0 {
+		start := bytes.LastIndexByte(output[:end], '\n') + 1
+		if line := strings.TrimSpace(string(output[start:end])); line != "" {
+			if len(line) > maxProgressMessage {
+				line = line[:maxProgressMessage] + "..."
+			}
+			return line
+		}
+		end = start - 1
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"cinesync/pkg/db"
+)
+
+// FunctionProgress reports how many of total items a built-in job processed
+type FunctionProgress func(processed, total int, message string)
+
+// JobFunction is a built-in job run inside the server rather than as a
+// command. It stops when ctx is cancelled and returns a summary that is kept
+// as the output of the execution.
+type JobFunction func(ctx context.Context, progress FunctionProgress) (string, error)
+
+// functionJobs are the built-in jobs a JobTypeFunction job can name as its command
+var functionJobs = map[string]JobFunction{
+	"backfill-file-hashes": backfillFileHashes,
+}
+
+// backfillFileHashes computes the hashes of source files that were indexed
+// before checksums were stored
+func backfillFileHashes(ctx context.Context, progress FunctionProgress) (string, error) {
+	result, err := db.BackfillSourceFileHashes(ctx, func(result db.HashBackfillResult) {
+		done := result.Hashed + result.Failed
+		progress(done, result.Total, fmt.Sprintf("Hashed %d of %d files, %d failed", result.Hashed, result.Total, result.Failed))
+	})
+	summary := fmt.Sprintf("Hashed %d of %d files missing a hash, %d failed", result.Hashed, result.Total, result.Failed)
+	return summary, err
+}
+
+// throttledProgress passes progress on at most once per progressInterval,
+// always letting the last item through
+func throttledProgress(report FunctionProgress) FunctionProgress {
+	var mutex sync.Mutex
+	var lastTick time.Time
+	return func(processed, total int, message string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if now := time.Now(); now.Sub(lastTick) >= progressInterval || processed == total {
+			lastTick = now
+			report(processed, total, message)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"cinesync/pkg/db"
+	"cinesync/pkg/logger"
+)
+
+// minLibraryScanInterval is the shortest interval, in seconds, a library can be scanned at
+const minLibraryScanInterval = 60
+
+// ErrLibraryNotFound is returned for a library that is not a configured source directory
+var ErrLibraryNotFound = errors.New("library not found")
+
+// LibraryScheduler scans every library on its own interval or cron schedule.
+// Each library has its own timer and can be paused without affecting the
+// others, and a library is never scanned twice at once: a run that comes due
+// while the previous one is still going is skipped.
+type LibraryScheduler struct {
+	schedules map[string]*LibrarySchedule
+	timers    map[string]*time.Timer
+	mutex     sync.Mutex
+	libraries func() []string
+	scan      func(library string) error
+	stopped   bool
+}
+
+// NewLibraryScheduler creates a scheduler for the libraries returned by
+// libraries, scanning them with scan, and starts the stored schedules
+func NewLibraryScheduler(libraries func() []string, scan func(library string) error) *LibraryScheduler {
+	scheduler := &LibraryScheduler{
+		schedules: make(map[string]*LibrarySchedule),
+		timers:    make(map[string]*time.Timer),
+		libraries: libraries,
+		scan:      scan,
+	}
+
+	if err := initLibrarySchedulesTable(); err != nil {
+		logger.Error("Failed to initialize library schedules table: %v", err)
+	}
+
+	savedSchedules, err := loadLibrarySchedulesFromDB()
+	if err != nil {
+		logger.Error("Failed to load library schedules from database: %v", err)
+	}
+	for _, schedule := range savedSchedules {
+		scheduler.schedules[schedule.Library] = schedule
+	}
+
+	scheduler.mutex.Lock()
+	scheduler.syncLibrariesLocked()
+	scheduler.mutex.Unlock()
+
+	return scheduler
+}
+
+// syncLibrariesLocked follows changes to the configured libraries: new ones
+// get a manual schedule and removed ones stop firing. Stored schedules of
+// removed libraries are kept in case the directory comes back.
+func (s *LibraryScheduler) syncLibrariesLocked() {
+	configured := make(map[string]bool)
+	for _, library := range s.libraries() {
+		configured[library] = true
+		schedule, exists := s.schedules[library]
+		if !exists {
+			schedule = &LibrarySchedule{
+				Library:      library,
+				ScheduleType: ScheduleTypeManual,
+				Enabled:      true,
+				UpdatedAt:    time.Now(),
+			}
+			s.schedules[library] = schedule
+		}
+		if _, hasTimer := s.timers[library]; !hasTimer && !schedule.Running {
+			s.scheduleNextLocked(schedule)
+		}
+	}
+
+	for library, timer := range s.timers {
+		if !configured[library] {
+			timer.Stop()
+			delete(s.timers, library)
+			s.schedules[library].NextRun = nil
+		}
+	}
+}
+
+// scheduleNextLocked (re)arms the timer of a library for its next run
+func (s *LibraryScheduler) scheduleNextLocked(schedule *LibrarySchedule) {
+	library := schedule.Library
+	if timer, exists := s.timers[library]; exists {
+		timer.Stop()
+		delete(s.timers, library)
+	}
+	schedule.NextRun = nil
+
+	if s.stopped || !schedule.Enabled {
+		return
+	}
+
+	now := time.Now()
+	var next time.Time
+	switch schedule.ScheduleType {
+	case ScheduleTypeInterval:
+		next = now.Add(time.Duration(schedule.IntervalSeconds) * time.Second)
+	case ScheduleTypeCron:
+		cron, err := parseCronExpression(schedule.CronExpression)
+		if err != nil {
+			logger.Warn("Invalid cron expression for library %s: %v", library, err)
+			return
+		}
+		next = cron.Next(now)
+		if next.IsZero() {
+			logger.Warn("Cron expression %q for library %s never matches", schedule.CronExpression, library)
+			return
+		}
+	default:
+		return
+	}
+
+	// The timer only counts while it is still the library's current timer, so
+	// one that fired during an update cannot start a second chain of runs.
+	// The callback takes the mutex before reading timer, which is assigned
+	// while the mutex is held.
+	var timer *time.Timer
+	timer = time.AfterFunc(next.Sub(now), func() {
+		s.runScheduled(library, timer)
+	})
+	s.timers[library] = timer
+	schedule.NextRun = &next
+}
+
+// runScheduled performs a due scan of a library and schedules the next one
+func (s *LibraryScheduler) runScheduled(library string, timer *time.Timer) {
+	s.mutex.Lock()
+	schedule, exists := s.schedules[library]
+	if !exists || s.stopped || s.timers[library] != timer {
+		s.mutex.Unlock()
+		return
+	}
+	delete(s.timers, library)
+
+	if !schedule.Enabled {
+		schedule.NextRun = nil
+		s.mutex.Unlock()
+		return
+	}
+	if schedule.Running {
+		logger.Warn("Scan of library %s is still running, skipping scheduled run", library)
+		s.scheduleNextLocked(schedule)
+		s.mutex.Unlock()
+		return
+	}
+
+	schedule.Running = true
+	schedule.NextRun = nil
+	s.mutex.Unlock()
+
+	logger.Info("Starting scheduled scan of library %s", library)
+	err := s.scan(library)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	schedule.Running = false
+	if errors.Is(err, db.ErrScanInProgress) || errors.Is(err, db.ErrLibraryScanInProgress) || errors.Is(err, db.ErrOperationConflict) {
+		logger.Info("Skipped scheduled scan of library %s: %v", library, err)
+	} else {
+		finishedAt := time.Now()
+		schedule.LastRun = &finishedAt
+		schedule.LastStatus = JobStatusCompleted
+		schedule.LastError = ""
+		if err != nil {
+			logger.Error("Scheduled scan of library %s failed: %v", library, err)
+			schedule.LastStatus = JobStatusFailed
+			schedule.LastError = err.Error()
+		}
+		if saveErr := saveLibraryScheduleToDB(schedule); saveErr != nil {
+			logger.Error("Failed to save library schedule %s: %v", library, saveErr)
+		}
+	}
+
+	s.scheduleNextLocked(schedule)
+}
+
+// GetSchedules returns the schedules of the configured libraries in
+// SOURCE_DIR order
+func (s *LibraryScheduler) GetSchedules() []LibrarySchedule {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.syncLibrariesLocked()
+
+	schedules := make([]LibrarySchedule, 0, len(s.schedules))
+	for _, library := range s.libraries() {
+		if schedule, exists := s.schedules[library]; exists {
+			schedules = append(schedules, *schedule)
+		}
+	}
+	return schedules
+}
+
+// UpdateSchedule changes the schedule of a library and rearms its timer.
+// Other libraries keep their timers, so pausing one leaves the rest running.
+func (s *LibraryScheduler) UpdateSchedule(updateReq UpdateLibraryScheduleRequest) (*LibrarySchedule, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.syncLibrariesLocked()
+
+	configured := false
+	for _, library := range s.libraries() {
+		if library == updateReq.Library {
+			configured = true
+			break
+		}
+	}
+	schedule, exists := s.schedules[updateReq.Library]
+	if !configured || !exists {
+		return nil, ErrLibraryNotFound
+	}
+
+	updated := *schedule
+	if updateReq.ScheduleType != nil {
+		updated.ScheduleType = *updateReq.ScheduleType
+	}
+	if updateReq.IntervalSeconds != nil {
+		updated.IntervalSeconds = *updateReq.IntervalSeconds
+	}
+	if updateReq.CronExpression != nil {
+		updated.CronExpression = *updateReq.CronExpression
+	}
+	if updateReq.Enabled != nil {
+		updated.Enabled = *updateReq.Enabled
+	}
+
+	if err := updated.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid library schedule: %v", err)
+	}
+
+	schedule.ScheduleType = updated.ScheduleType
+	schedule.IntervalSeconds = updated.IntervalSeconds
+	schedule.CronExpression = updated.CronExpression
+	schedule.Enabled = updated.Enabled
+	schedule.UpdatedAt = time.Now()
+
+	if err := saveLibraryScheduleToDB(schedule); err != nil {
+		logger.Error("Failed to save library schedule %s: %v", schedule.Library, err)
+	}
+
+	// A running scan reschedules itself when it finishes
+	if !schedule.Running {
+		s.scheduleNextLocked(schedule)
+	}
+
+	logger.Info("Library schedule updated: %s (%s, enabled: %t)", schedule.Library, schedule.ScheduleType, schedule.Enabled)
+
+	result := *schedule
+	return &result, nil
+}
+
+// Stop stops all library timers
+func (s *LibraryScheduler) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.stopped = true
+	for library, timer := range s.timers {
+		timer.Stop()
+		delete(s.timers, library)
+	}
+}
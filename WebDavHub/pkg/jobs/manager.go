@@ -7,12 +7,14 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"cinesync/pkg/logger"
 	"cinesync/pkg/env"
+	"cinesync/pkg/notify"
 )
 
 // fileExists checks if a file or directory exists
@@ -34,12 +36,21 @@ func getPythonCommand() string {
 	return "python3"
 }
 
-// JobStatusUpdate represents a job status change event
+// JobStatusUpdate represents a job status change or a progress tick of a
+// running execution
 type JobStatusUpdate struct {
-	JobID     string    `json:"jobId"`
-	Status    JobStatus `json:"status"`
-	Message   string    `json:"message"`
-	Timestamp time.Time `json:"timestamp"`
+	JobID       string    `json:"jobId"`
+	Event       JobEvent  `json:"event"`
+	Status      JobStatus `json:"status"`
+	Message     string    `json:"message"`
+	Timestamp   time.Time `json:"timestamp"`
+	ExecutionID string    `json:"executionId,omitempty"`
+	// OutputLines and Elapsed describe the progress of a running execution
+	OutputLines int           `json:"outputLines,omitempty"`
+	Elapsed     time.Duration `json:"elapsed,omitempty"`
+	// Processed and Total count the items a built-in job worked through
+	Processed int `json:"processed,omitempty"`
+	Total     int `json:"total,omitempty"`
 }
 
 // Manager handles job scheduling and execution
@@ -47,6 +58,7 @@ type Manager struct {
 	jobs        map[string]*Job
 	executions  map[string]*JobExecution
 	running     map[string]*exec.Cmd
+	cancels     map[string]context.CancelFunc // running built-in jobs
 	timers      map[string]*time.Timer
 	mutex       sync.RWMutex
 	ctx         context.Context
@@ -74,6 +86,7 @@ func NewManager() *Manager {
 		jobs:          make(map[string]*Job),
 		executions:    make(map[string]*JobExecution),
 		running:       make(map[string]*exec.Cmd),
+		cancels:       make(map[string]context.CancelFunc),
 		timers:        make(map[string]*time.Timer),
 		ctx:           ctx,
 		cancel:        cancel,
@@ -199,6 +212,23 @@ func (m *Manager) initializeDefaultJobs() {
 			CreatedAt:    time.Now(),
 			UpdatedAt:    time.Now(),
 		},
+		{
+			ID:           "file-hash-backfill",
+			Name:         "File Hash Backfill",
+			Description:  "Compute the checksums of indexed source files that do not have one yet",
+			Type:         JobTypeFunction,
+			Status:       JobStatusIdle,
+			ScheduleType: ScheduleTypeManual,
+			Command:      "backfill-file-hashes",
+			Arguments:    []string{},
+			Enabled:      true,
+			Category:     "Maintenance",
+			Tags:         []string{"files", "checksum", "database"},
+			MaxRetries:   0,
+			LogOutput:    true,
+			CreatedAt:    time.Now(),
+			UpdatedAt:    time.Now(),
+		},
 	}
 
 	for _, job := range defaultJobs {
@@ -212,6 +242,10 @@ func (m *Manager) initializeDefaultJobs() {
 	logger.Info("Initialized %d default jobs", len(defaultJobs))
 }
 
+// retiredDefaultJobs are default jobs that no longer exist. MediaHub now
+// writes .strm files itself when it links a file, so the strm sync is gone.
+var retiredDefaultJobs = []string{"strm-files-sync"}
+
 // addMissingDefaultJobs checks for missing default jobs and adds them
 func (m *Manager) addMissingDefaultJobs() {
 	// Get symlink cleanup interval from environment variable
@@ -293,6 +327,36 @@ func (m *Manager) addMissingDefaultJobs() {
 			CreatedAt:    time.Now(),
 			UpdatedAt:    time.Now(),
 		},
+		"file-hash-backfill": {
+			ID:           "file-hash-backfill",
+			Name:         "File Hash Backfill",
+			Description:  "Compute the checksums of indexed source files that do not have one yet",
+			Type:         JobTypeFunction,
+			Status:       JobStatusIdle,
+			ScheduleType: ScheduleTypeManual,
+			Command:      "backfill-file-hashes",
+			Arguments:    []string{},
+			Enabled:      true,
+			Category:     "Maintenance",
+			Tags:         []string{"files", "checksum", "database"},
+			MaxRetries:   0,
+			LogOutput:    true,
+			CreatedAt:    time.Now(),
+			UpdatedAt:    time.Now(),
+		},
+	}
+
+	// Default jobs that were dropped go away with the built-in they ran
+	for _, jobID := range retiredDefaultJobs {
+		if _, exists := m.jobs[jobID]; !exists {
+			continue
+		}
+		delete(m.jobs, jobID)
+		if err := deleteJobFromDB(jobID); err != nil {
+			logger.Warn("Failed to delete retired default job %s: %v", jobID, err)
+		} else {
+			logger.Info("Removed retired default job: %s", jobID)
+		}
 	}
 
 	// Check which jobs are missing and add them
@@ -411,23 +475,32 @@ func (m *Manager) Unsubscribe(subscriber chan JobStatusUpdate) {
 }
 
 // broadcastStatusUpdate sends a status update to all subscribers
-func (m *Manager) broadcastStatusUpdate(jobID string, status JobStatus, message string) {
-	update := JobStatusUpdate{
-		JobID:     jobID,
-		Status:    status,
-		Message:   message,
-		Timestamp: time.Now(),
-	}
+func (m *Manager) broadcastStatusUpdate(jobID string, event JobEvent, status JobStatus, message string) {
+	m.broadcast(JobStatusUpdate{
+		JobID:   jobID,
+		Event:   event,
+		Status:  status,
+		Message: message,
+	})
+}
 
+// broadcast queues an update for the subscribers, stamping its time
+func (m *Manager) broadcast(update JobStatusUpdate) {
+	update.Timestamp = time.Now()
 	select {
 	case m.statusUpdates <- update:
 	default:
-		logger.Warn("Status update channel is full, skipping update for job %s", jobID)
+		logger.Warn("Status update channel is full, skipping update for job %s", update.JobID)
 	}
 }
 
 // GetJobs returns all jobs
 func (m *Manager) GetJobs() []Job {
+	return m.FilterJobs(JobFilter{})
+}
+
+// FilterJobs returns the jobs the filter matches
+func (m *Manager) FilterJobs(filter JobFilter) []Job {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
@@ -436,7 +509,9 @@ func (m *Manager) GetJobs() []Job {
 		// Update next execution time
 		nextExec := job.GetNextExecutionTime()
 		job.NextExecution = nextExec
-		jobs = append(jobs, *job)
+		if filter.Matches(job) {
+			jobs = append(jobs, *job)
+		}
 	}
 
 	return jobs
@@ -504,26 +579,22 @@ func (m *Manager) executeJob(jobID string) {
 	m.mutex.Unlock()
 
 	logger.Debug("Starting job execution: %s (%s)", job.Name, jobID)
-	m.broadcastStatusUpdate(jobID, JobStatusRunning, fmt.Sprintf("Job %s started", job.Name))
-
-	// Create command
-	cmd := exec.CommandContext(m.ctx, job.Command, job.Arguments...)
-	if job.WorkingDir != "" {
-		cmd.Dir = job.WorkingDir
-	}
-
-	// Set environment variables for the command
-	cmd.Env = os.Environ()
-
-	// Store running command
-	m.mutex.Lock()
-	m.running[jobID] = cmd
-	m.mutex.Unlock()
+	m.broadcast(JobStatusUpdate{
+		JobID:       jobID,
+		Event:       JobEventStarted,
+		Status:      JobStatusRunning,
+		Message:     fmt.Sprintf("Job %s started", job.Name),
+		ExecutionID: execution.ID,
+	})
 
-	// Execute command
 	startTime := time.Now()
-
-	output, err := cmd.CombinedOutput()
+	var output string
+	var err error
+	if job.Type == JobTypeFunction {
+		output, err = m.runFunctionJob(job, execution.ID, startTime)
+	} else {
+		output, err = m.runCommandJob(job, execution.ID, startTime)
+	}
 	endTime := time.Now()
 	duration := endTime.Sub(startTime)
 
@@ -531,9 +602,17 @@ func (m *Manager) executeJob(jobID string) {
 	m.mutex.Lock()
 	execution.EndTime = &endTime
 	execution.Duration = duration
-	execution.Output = string(output)
-
-	if err != nil {
+	execution.Output = output
+
+	finished := JobStatusUpdate{JobID: jobID, ExecutionID: execution.ID, Elapsed: duration}
+	if job.Status == JobStatusCancelled {
+		// CancelJob killed the command, which is not a failure
+		execution.Status = JobStatusCancelled
+		execution.ExitCode = -1
+		finished.Event, finished.Status = JobEventCancelled, JobStatusCancelled
+		finished.Message = fmt.Sprintf("Job %s cancelled", job.Name)
+		m.broadcast(finished)
+	} else if err != nil {
 		execution.Status = JobStatusFailed
 		execution.Error = err.Error()
 		if exitError, ok := err.(*exec.ExitError); ok {
@@ -543,19 +622,32 @@ func (m *Manager) executeJob(jobID string) {
 		}
 		job.UpdateStatus(JobStatusFailed, err)
 		// Broadcast job failed
-		m.broadcastStatusUpdate(jobID, JobStatusFailed, fmt.Sprintf("Job %s failed: %v", job.Name, err))
+		finished.Event, finished.Status = JobEventFailed, JobStatusFailed
+		finished.Message = fmt.Sprintf("Job %s failed: %v", job.Name, err)
+		m.broadcast(finished)
+		if job.NotifyOnFailure {
+			go notify.Send(notify.Event{
+				Event:   "job_failed",
+				Message: finished.Message,
+				Time:    endTime.UTC(),
+				Data:    map[string]interface{}{"jobId": jobID, "executionId": execution.ID},
+			})
+		}
 	} else {
 		execution.Status = JobStatusCompleted
 		execution.ExitCode = 0
 		job.UpdateStatus(JobStatusCompleted, nil)
 		// Broadcast job completed
-		m.broadcastStatusUpdate(jobID, JobStatusCompleted, fmt.Sprintf("Job %s completed successfully", job.Name))
+		finished.Event, finished.Status = JobEventCompleted, JobStatusCompleted
+		finished.Message = fmt.Sprintf("Job %s completed successfully", job.Name)
+		m.broadcast(finished)
 	}
 
 	// Set LastExecution to completion time for proper interval scheduling
 	job.LastExecution = &endTime
 	job.LastDuration = &duration
 	delete(m.running, jobID)
+	delete(m.cancels, jobID)
 
 	m.mutex.Unlock()
 
@@ -568,6 +660,67 @@ func (m *Manager) executeJob(jobID string) {
 	}
 }
 
+// runCommandJob runs the command of job, reporting progress as output lines arrive
+func (m *Manager) runCommandJob(job *Job, executionID string, startTime time.Time) (string, error) {
+	cmd := exec.CommandContext(m.ctx, job.Command, job.Arguments...)
+	if job.WorkingDir != "" {
+		cmd.Dir = job.WorkingDir
+	}
+
+	// Set environment variables for the command
+	cmd.Env = os.Environ()
+
+	// Store running command
+	m.mutex.Lock()
+	m.running[job.ID] = cmd
+	m.mutex.Unlock()
+
+	progress := &progressWriter{tick: func(lines int, lastLine string) {
+		m.broadcast(JobStatusUpdate{
+			JobID:       job.ID,
+			Event:       JobEventProgress,
+			Status:      JobStatusRunning,
+			Message:     lastLine,
+			ExecutionID: executionID,
+			OutputLines: lines,
+			Elapsed:     time.Since(startTime),
+		})
+	}}
+	cmd.Stdout = progress
+	cmd.Stderr = progress
+
+	err := cmd.Run()
+	return progress.Output(), err
+}
+
+// runFunctionJob runs the built-in job named by the command of job, reporting
+// how many items it processed
+func (m *Manager) runFunctionJob(job *Job, executionID string, startTime time.Time) (string, error) {
+	function, ok := functionJobs[job.Command]
+	if !ok {
+		return "", fmt.Errorf("unknown built-in job: %s", job.Command)
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	defer cancel()
+	m.mutex.Lock()
+	m.cancels[job.ID] = cancel
+	m.mutex.Unlock()
+
+	return function(ctx, throttledProgress(func(processed, total int, message string) {
+		m.broadcast(JobStatusUpdate{
+			JobID:       job.ID,
+			Event:       JobEventProgress,
+			Status:      JobStatusRunning,
+			Message:     message,
+			ExecutionID: executionID,
+			Elapsed:     time.Since(startTime),
+			Processed:   processed,
+			Total:       total,
+		})
+	}))
+}
+
 // CancelJob cancels a running job
 func (m *Manager) CancelJob(id string) error {
 	m.mutex.Lock()
@@ -578,6 +731,14 @@ func (m *Manager) CancelJob(id string) error {
 		return fmt.Errorf("job not found: %s", id)
 	}
 
+	if cancel, isRunning := m.cancels[id]; isRunning {
+		cancel()
+		job.UpdateStatus(JobStatusCancelled, nil)
+		delete(m.cancels, id)
+		logger.Info("Job cancelled: %s (%s)", job.Name, id)
+		return nil
+	}
+
 	cmd, isRunning := m.running[id]
 	if !isRunning {
 		return fmt.Errorf("job is not running: %s", id)
@@ -669,7 +830,7 @@ func (m *Manager) UpdateJob(id string, updateReq UpdateJobRequest) error {
 	needsTimer := job.Enabled && (job.ScheduleType == ScheduleTypeInterval || job.ScheduleType == ScheduleTypeCron)
 
 	logger.Info("Job updated: %s (%s)", job.Name, id)
-	m.broadcastStatusUpdate(id, job.Status, fmt.Sprintf("Job %s configuration updated", job.Name))
+	m.broadcastStatusUpdate(id, JobEventUpdated, job.Status, fmt.Sprintf("Job %s configuration updated", job.Name))
 
 	if err := saveJobToDB(job); err != nil {
 		logger.Error("Failed to save updated job %s to database: %v", id, err)
@@ -697,7 +858,10 @@ func (m *Manager) GetJobExecutions(jobID string, limit int) []JobExecution {
 		}
 	}
 
-	// Sort by start time
+	// Newest first
+	sort.Slice(executions, func(i, j int) bool {
+		return executions[i].StartTime.After(executions[j].StartTime)
+	})
 	if len(executions) > limit && limit > 0 {
 		executions = executions[:limit]
 	}
@@ -729,4 +893,10 @@ func (m *Manager) Stop() {
 			job.UpdateStatus(JobStatusCancelled, nil)
 		}
 	}
+	for jobID, cancel := range m.cancels {
+		cancel()
+		if job, exists := m.jobs[jobID]; exists {
+			job.UpdateStatus(JobStatusCancelled, nil)
+		}
+	}
 }
@@ -3,6 +3,8 @@ package jobs
 import (
 	"fmt"
 	"time"
+
+	"cinesync/pkg/paging"
 )
 
 // JobType represents the type of job
@@ -12,6 +14,8 @@ const (
 	JobTypeProcess JobType = "process"
 	JobTypeService JobType = "service"
 	JobTypeCommand JobType = "command"
+	// JobTypeFunction runs the built-in job named by Command inside the server
+	JobTypeFunction JobType = "function"
 )
 
 // JobStatus represents the current status of a job
@@ -106,7 +110,7 @@ func (j *Job) IsRunning() bool {
 
 // CanRun returns true if the job can be executed
 func (j *Job) CanRun() bool {
-	return j.Enabled && (j.Status == JobStatusIdle || j.Status == JobStatusCompleted || j.Status == JobStatusFailed)
+	return j.Enabled && (j.Status == JobStatusIdle || j.Status == JobStatusCompleted || j.Status == JobStatusFailed || j.Status == JobStatusCancelled)
 }
 
 // UpdateStatus updates the job status and last error
@@ -152,6 +156,11 @@ func (j *Job) Validate() error {
 	if j.Command == "" {
 		return fmt.Errorf("job command is required")
 	}
+	if j.Type == JobTypeFunction {
+		if _, ok := functionJobs[j.Command]; !ok {
+			return fmt.Errorf("unknown built-in job: %s", j.Command)
+		}
+	}
 	if j.ScheduleType == ScheduleTypeInterval && j.IntervalSeconds <= 0 {
 		return fmt.Errorf("interval seconds must be greater than 0 for interval jobs")
 	}
@@ -164,6 +173,101 @@ func (j *Job) Validate() error {
 	return nil
 }
 
+// LibrarySchedule is the scan schedule of one library, a source directory.
+// Libraries without a stored schedule are only scanned manually and by the
+// source files scan job.
+type LibrarySchedule struct {
+	Library         string       `json:"library"`
+	ScheduleType    ScheduleType `json:"scheduleType"`
+	IntervalSeconds int          `json:"intervalSeconds,omitempty"`
+	CronExpression  string       `json:"cronExpression,omitempty"`
+	Enabled         bool         `json:"enabled"`
+	Running         bool         `json:"running"`
+	LastRun         *time.Time   `json:"lastRun,omitempty"`
+	LastStatus      JobStatus    `json:"lastStatus,omitempty"`
+	LastError       string       `json:"lastError,omitempty"`
+	NextRun         *time.Time   `json:"nextRun,omitempty"`
+	UpdatedAt       time.Time    `json:"updatedAt"`
+}
+
+// UpdateLibraryScheduleRequest represents a request to change the schedule of
+// a library. Fields left out keep their current value.
+type UpdateLibraryScheduleRequest struct {
+	Library         string        `json:"library"`
+	ScheduleType    *ScheduleType `json:"scheduleType,omitempty"`
+	IntervalSeconds *int          `json:"intervalSeconds,omitempty"`
+	CronExpression  *string       `json:"cronExpression,omitempty"`
+	Enabled         *bool         `json:"enabled,omitempty"`
+}
+
+// Validate validates the library schedule
+func (s *LibrarySchedule) Validate() error {
+	switch s.ScheduleType {
+	case ScheduleTypeManual:
+	case ScheduleTypeInterval:
+		if s.IntervalSeconds < minLibraryScanInterval {
+			return fmt.Errorf("interval seconds must be at least %d for interval schedules", minLibraryScanInterval)
+		}
+	case ScheduleTypeCron:
+		if s.CronExpression == "" {
+			return fmt.Errorf("cron expression is required for cron schedules")
+		}
+		if _, err := parseCronExpression(s.CronExpression); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("schedule type must be manual, interval or cron")
+	}
+	return nil
+}
+
+// LibrarySchedulesResponse represents the response for listing library schedules
+type LibrarySchedulesResponse struct {
+	Schedules []LibrarySchedule `json:"schedules"`
+	Status    string            `json:"status"`
+}
+
+// JobFilter selects jobs for GET /api/jobs. Empty fields match every job;
+// Since and Until bound the last execution, so a date range leaves out jobs
+// that never ran.
+type JobFilter struct {
+	Statuses []JobStatus
+	Types    []JobType
+	Since    *time.Time
+	Until    *time.Time
+}
+
+// Matches reports whether a job passes the filter
+func (f JobFilter) Matches(job *Job) bool {
+	if len(f.Statuses) > 0 && !containsValue(f.Statuses, job.Status) {
+		return false
+	}
+	if len(f.Types) > 0 && !containsValue(f.Types, job.Type) {
+		return false
+	}
+	if f.Since != nil || f.Until != nil {
+		if job.LastExecution == nil {
+			return false
+		}
+		if f.Since != nil && job.LastExecution.Before(*f.Since) {
+			return false
+		}
+		if f.Until != nil && job.LastExecution.After(*f.Until) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsValue[T comparable](values []T, value T) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 // JobsResponse represents the response for listing jobs
 type JobsResponse struct {
 	Jobs   []Job  `json:"jobs"`
@@ -172,6 +276,6 @@ type JobsResponse struct {
 
 // JobExecutionResponse represents the response for job executions
 type JobExecutionResponse struct {
-	Executions []JobExecution `json:"executions"`
-	Status     string         `json:"status"`
+	paging.PagedResponse[JobExecution]
+	Status string `json:"status"`
 }
@@ -205,3 +205,120 @@ func deleteJobFromDB(jobID string) error {
 
 	return nil
 }
+
+// initLibrarySchedulesTable creates the library_schedules table if it doesn't exist
+func initLibrarySchedulesTable() error {
+	database, err := db.GetDatabaseConnection()
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %v", err)
+	}
+
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS library_schedules (
+		library TEXT PRIMARY KEY,
+		schedule_type TEXT NOT NULL,
+		interval_seconds INTEGER,
+		cron_expression TEXT,
+		enabled BOOLEAN NOT NULL DEFAULT 1,
+		last_run DATETIME,
+		last_status TEXT,
+		last_error TEXT,
+		updated_at DATETIME NOT NULL
+	);`
+
+	if _, err := database.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("failed to create library_schedules table: %v", err)
+	}
+	return nil
+}
+
+// saveLibraryScheduleToDB saves a library schedule to the database
+func saveLibraryScheduleToDB(schedule *LibrarySchedule) error {
+	database, err := db.GetDatabaseConnection()
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %v", err)
+	}
+
+	var lastRun *string
+	if schedule.LastRun != nil {
+		lastRunStr := schedule.LastRun.Format(time.RFC3339)
+		lastRun = &lastRunStr
+	}
+
+	insertSQL := `
+	INSERT OR REPLACE INTO library_schedules (
+		library, schedule_type, interval_seconds, cron_expression, enabled,
+		last_run, last_status, last_error, updated_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err = database.Exec(insertSQL,
+		schedule.Library, schedule.ScheduleType, schedule.IntervalSeconds, schedule.CronExpression,
+		schedule.Enabled, lastRun, schedule.LastStatus, schedule.LastError,
+		schedule.UpdatedAt.Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save library schedule to database: %v", err)
+	}
+	return nil
+}
+
+// loadLibrarySchedulesFromDB loads all library schedules from the database
+func loadLibrarySchedulesFromDB() ([]*LibrarySchedule, error) {
+	database, err := db.GetDatabaseConnection()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %v", err)
+	}
+
+	selectSQL := `
+	SELECT library, schedule_type, interval_seconds, cron_expression, enabled,
+		   last_run, last_status, last_error, updated_at
+	FROM library_schedules`
+
+	rows, err := database.Query(selectSQL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query library schedules: %v", err)
+	}
+	defer rows.Close()
+
+	var schedules []*LibrarySchedule
+	for rows.Next() {
+		schedule := &LibrarySchedule{}
+		var intervalSeconds *int
+		var cronExpression, lastRun, lastStatus, lastError *string
+		var updatedAtStr string
+
+		err := rows.Scan(
+			&schedule.Library, &schedule.ScheduleType, &intervalSeconds, &cronExpression, &schedule.Enabled,
+			&lastRun, &lastStatus, &lastError, &updatedAtStr,
+		)
+		if err != nil {
+			logger.Error("Failed to scan library schedule row: %v", err)
+			continue
+		}
+
+		if intervalSeconds != nil {
+			schedule.IntervalSeconds = *intervalSeconds
+		}
+		if cronExpression != nil {
+			schedule.CronExpression = *cronExpression
+		}
+		if lastStatus != nil {
+			schedule.LastStatus = JobStatus(*lastStatus)
+		}
+		if lastError != nil {
+			schedule.LastError = *lastError
+		}
+		if lastRun != nil {
+			if parsed, err := time.Parse(time.RFC3339, *lastRun); err == nil {
+				schedule.LastRun = &parsed
+			}
+		}
+		if updatedAt, err := time.Parse(time.RFC3339, updatedAtStr); err == nil {
+			schedule.UpdatedAt = updatedAt
+		}
+
+		schedules = append(schedules, schedule)
+	}
+
+	return schedules, nil
+}
@@ -0,0 +1,232 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+)
+
+const defaultAccessLogExclude = "/api/health,/api/events/ping,*/events"
+
+// redactedQueryParams are query parameters that carry credentials, such as
+// the token event streams authenticate with. A parameter renamed through
+// CINESYNC_AUTH_QUERY_PARAM is redacted as well.
+var redactedQueryParams = []string{"token", "access_token", "apikey", "api_key"}
+
+// accessLog writes one line per request in combined, common or json format
+type accessLog struct {
+	mutex   sync.Mutex
+	out     io.Writer
+	format  string
+	exclude []string
+}
+
+// AccessLog returns middleware that writes an HTTP access log, separate from
+// the application log, to the destination in CINESYNC_ACCESS_LOG: stdout,
+// stderr or a file that is appended to so it can be tailed. Every handler it
+// wraps shares the destination. CINESYNC_ACCESS_LOG_FORMAT picks combined (the
+// default), common or json. Paths in CINESYNC_ACCESS_LOG_EXCLUDE are not
+// logged; entries are path prefixes, "*suffix" to match the end of a path,
+// or other path.Match patterns.
+func AccessLog() func(http.Handler) http.Handler {
+	passThrough := func(next http.Handler) http.Handler { return next }
+
+	destination := strings.TrimSpace(env.GetString("CINESYNC_ACCESS_LOG", ""))
+	if destination == "" {
+		return passThrough
+	}
+
+	var out io.Writer
+	switch destination {
+	case "stdout", "-":
+		out = os.Stdout
+	case "stderr":
+		out = os.Stderr
+	default:
+		file, err := os.OpenFile(destination, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			logger.Error("Access log disabled, cannot open %s: %v", destination, err)
+			return passThrough
+		}
+		out = file
+	}
+
+	format := strings.ToLower(strings.TrimSpace(env.GetString("CINESYNC_ACCESS_LOG_FORMAT", "combined")))
+	if format != "combined" && format != "common" && format != "json" {
+		logger.Warn("Unknown CINESYNC_ACCESS_LOG_FORMAT %q, using combined", format)
+		format = "combined"
+	}
+
+	log := &accessLog{out: out, format: format}
+	for _, entry := range strings.Split(env.GetString("CINESYNC_ACCESS_LOG_EXCLUDE", defaultAccessLogExclude), ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			log.exclude = append(log.exclude, entry)
+		}
+	}
+	logger.Info("Writing %s access log to %s", format, destination)
+
+	return log.wrap
+}
+
+func (l *accessLog) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == LivenessPath || l.excluded(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		aw := &accessLogResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			// Recover answers a panicking handler with a 500 further out
+			if p := recover(); p != nil {
+				status := http.StatusInternalServerError
+				if aw.wroteHeader {
+					status = aw.status
+				}
+				l.write(r, status, aw.written, start)
+				panic(p)
+			}
+		}()
+		next.ServeHTTP(aw, r)
+		l.write(r, aw.status, aw.written, start)
+	})
+}
+
+// excluded reports whether a path matches one of the excluded entries
+func (l *accessLog) excluded(requestPath string) bool {
+	for _, entry := range l.exclude {
+		if suffix, ok := strings.CutPrefix(entry, "*"); ok && !strings.ContainsAny(suffix, "*?[") {
+			if strings.HasSuffix(requestPath, suffix) {
+				return true
+			}
+		} else if strings.ContainsAny(entry, "*?[") {
+			if matched, _ := path.Match(entry, requestPath); matched {
+				return true
+			}
+		} else if requestPath == entry || strings.HasPrefix(requestPath, strings.TrimSuffix(entry, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *accessLog) write(r *http.Request, status int, written int64, start time.Time) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	user, _, _ := r.BasicAuth()
+	uri := redactRequestURI(r.URL)
+
+	var line string
+	if l.format == "json" {
+		entry, _ := json.Marshal(map[string]interface{}{
+			"time":       start.UTC().Format(time.RFC3339Nano),
+			"remoteAddr": host,
+			"user":       user,
+			"method":     r.Method,
+			"uri":        uri,
+			"proto":      r.Proto,
+			"status":     status,
+			"bytes":      written,
+			"durationMs": float64(time.Since(start).Microseconds()) / 1000,
+			"referer":    r.Referer(),
+			"userAgent":  r.UserAgent(),
+		})
+		line = string(entry)
+	} else {
+		line = fmt.Sprintf("%s - %s [%s] %q %d %s",
+			host, dashIfEmpty(user), start.Format("02/Jan/2006:15:04:05 -0700"),
+			r.Method+" "+uri+" "+r.Proto, status, bytesField(written))
+		if l.format == "combined" {
+			line += fmt.Sprintf(" %q %q", dashIfEmpty(r.Referer()), dashIfEmpty(r.UserAgent()))
+		}
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	fmt.Fprintln(l.out, line)
+}
+
+// redactRequestURI returns the request URI with credential parameters masked
+func redactRequestURI(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.RequestURI()
+	}
+	query := u.Query()
+	redacted := false
+	for key := range query {
+		for _, name := range append(redactedQueryParams, env.GetString("CINESYNC_AUTH_QUERY_PARAM", "")) {
+			if name != "" && strings.EqualFold(key, name) {
+				query.Set(key, "REDACTED")
+				redacted = true
+			}
+		}
+	}
+	if !redacted {
+		return u.RequestURI()
+	}
+	return u.EscapedPath() + "?" + query.Encode()
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func bytesField(n int64) string {
+	if n == 0 {
+		return "-"
+	}
+	return fmt.Sprint(n)
+}
+
+// accessLogResponseWriter records the status and number of body bytes written
+type accessLogResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	written     int64
+	wroteHeader bool
+}
+
+func (w *accessLogResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *accessLogResponseWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// Flush keeps streaming handlers working behind the middleware
+func (w *accessLogResponseWriter) Flush() {
+	w.wroteHeader = true
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *accessLogResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+)
+
+const defaultMaxBodySize = 1 << 20
+
+// defaultBodyLimitOverrides are per-endpoint limits applied unless replaced
+// through CINESYNC_MAX_BODY_SIZE_OVERRIDES
+var defaultBodyLimitOverrides = map[string]int64{
+	"/api/auth/login":           16 << 10,
+	"/api/auth/register":        16 << 10,
+	"/api/auth/change-password": 16 << 10,
+	"/api/file-operations/bulk": 8 << 20,
+	// Dumps are streamed into the database, so their size does not matter
+	"/api/database/import": 0,
+	// A symlink manifest holds an entry for every link in the library
+	"/api/maintenance/symlink-manifest/restore": 256 << 20,
+}
+
+// maxBodySizeFor returns the body size limit for a request path. Overrides use
+// the form "/api/path=size,/api/other=size" and match exact paths.
+func maxBodySizeFor(path string) int64 {
+	limit := env.GetSize("CINESYNC_MAX_BODY_SIZE", defaultMaxBodySize)
+	if override, ok := defaultBodyLimitOverrides[path]; ok {
+		limit = override
+	}
+
+	for _, entry := range strings.Split(env.GetString("CINESYNC_MAX_BODY_SIZE_OVERRIDES", ""), ",") {
+		endpoint, size, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || strings.TrimSpace(endpoint) != path {
+			continue
+		}
+		if parsed, err := env.ParseSize(size); err == nil {
+			limit = parsed
+		} else {
+			logger.Warn("Invalid body size override for %s: %s", path, size)
+		}
+	}
+	return limit
+}
+
+// LimitRequestBody caps request bodies with http.MaxBytesReader and answers
+// 413 Payload Too Large when a client sends more than the endpoint allows
+func LimitRequestBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		limit := maxBodySizeFor(r.URL.Path)
+		if limit <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.ContentLength > limit {
+			logger.Warn("Rejected %d byte request body for %s (limit %d)", r.ContentLength, r.URL.Path, limit)
+			http.Error(w, "Payload Too Large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		// Bodies without a Content-Length are only caught while being read, so
+		// the handler's error response is rewritten into a 413
+		lw := &limitedResponseWriter{ResponseWriter: w}
+		r.Body = &limitedBody{ReadCloser: http.MaxBytesReader(lw, r.Body, limit), exceeded: &lw.exceeded}
+		next.ServeHTTP(lw, r)
+	})
+}
+
+// limitedBody records when the wrapped MaxBytesReader hits its limit
+type limitedBody struct {
+	io.ReadCloser
+	exceeded *atomic.Bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		b.exceeded.Store(true)
+	}
+	return n, err
+}
+
+// limitedResponseWriter turns error responses into 413 once the body limit
+// has been exceeded
+type limitedResponseWriter struct {
+	http.ResponseWriter
+	exceeded atomic.Bool
+}
+
+func (w *limitedResponseWriter) WriteHeader(code int) {
+	if code >= http.StatusBadRequest && w.exceeded.Load() {
+		code = http.StatusRequestEntityTooLarge
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Flush keeps streaming handlers working behind the middleware
+func (w *limitedResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *limitedResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cinesync/pkg/env"
+)
+
+const defaultCompressionMinSize = 1024
+
+// compressibleTypes are the content types worth compressing. Images, video
+// and archives are already compressed and are passed through untouched.
+var compressibleTypes = []string{
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"text/",
+	"image/svg+xml",
+}
+
+// Compress gzip or deflate encodes responses according to the client's
+// Accept-Encoding. Responses smaller than CINESYNC_COMPRESSION_MIN_SIZE are
+// sent as-is unless the handler flushes, which marks them as streaming.
+func Compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !env.IsBool("CINESYNC_COMPRESSION", true) || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Whether or not this response ends up compressed, another client's
+		// might be, so caches must key on the header
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		cw := &compressWriter{
+			ResponseWriter: w,
+			encoding:       encoding,
+			minSize:        int(env.GetSize("CINESYNC_COMPRESSION_MIN_SIZE", defaultCompressionMinSize)),
+			status:         http.StatusOK,
+		}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding picks gzip or deflate from an Accept-Encoding header,
+// honouring q=0 exclusions
+func negotiateEncoding(header string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if value, err := strconv.ParseFloat(q, 64); err == nil && value == 0 {
+				continue
+			}
+		}
+		accepted[name] = true
+	}
+
+	switch {
+	case accepted["gzip"] || accepted["*"]:
+		return "gzip"
+	case accepted["deflate"]:
+		return "deflate"
+	}
+	return ""
+}
+
+// isCompressible reports whether a content type benefits from compression
+func isCompressible(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	if strings.HasPrefix(contentType, "text/event-stream") {
+		return false
+	}
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter buffers the start of a response until it knows whether the
+// body is large enough and of a type worth compressing
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+	status   int
+
+	buf         []byte
+	decided     bool
+	passthrough bool
+	encoder     io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.decided {
+		return
+	}
+	cw.status = code
+	// Bodiless and informational responses are never compressed
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		cw.startPassthrough()
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.decided {
+		if cw.passthrough {
+			return cw.ResponseWriter.Write(p)
+		}
+		return cw.encoder.Write(p)
+	}
+
+	cw.buf = append(cw.buf, p...)
+	if !cw.compressible() {
+		if err := cw.startPassthrough(); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if len(cw.buf) >= cw.minSize {
+		if err := cw.startCompression(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush marks the response as streaming, so compression starts right away
+// instead of waiting for the size threshold
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if cw.compressible() {
+			cw.startCompression()
+		} else {
+			cw.startPassthrough()
+		}
+	}
+	if flusher, ok := cw.encoder.(interface{ Flush() error }); ok && !cw.passthrough {
+		flusher.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Close sends any buffered body and finishes the compressed stream
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		// The whole response stayed under the threshold
+		return cw.startPassthrough()
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Close()
+	}
+	return nil
+}
+
+// compressible checks the declared, or sniffed, content type and encoding
+func (cw *compressWriter) compressible() bool {
+	header := cw.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(cw.buf)
+	}
+	return isCompressible(contentType)
+}
+
+func (cw *compressWriter) startPassthrough() error {
+	cw.decided = true
+	cw.passthrough = true
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	_, err := cw.ResponseWriter.Write(cw.buf)
+	cw.buf = nil
+	return err
+}
+
+func (cw *compressWriter) startCompression() error {
+	cw.decided = true
+
+	header := cw.Header()
+	header.Del("Content-Length")
+	header.Set("Content-Encoding", cw.encoding)
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	// HTTP's deflate is the zlib format, not a raw deflate stream
+	if cw.encoding == "deflate" {
+		cw.encoder = zlib.NewWriter(cw.ResponseWriter)
+	} else {
+		cw.encoder = gzip.NewWriter(cw.ResponseWriter)
+	}
+
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	_, err := cw.encoder.Write(cw.buf)
+	cw.buf = nil
+	return err
+}
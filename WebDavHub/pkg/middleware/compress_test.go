@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveCompressed(acceptEncoding, body string) *httptest.ResponseRecorder {
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/api/files", nil)
+	r.Header.Set("Accept-Encoding", acceptEncoding)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, r)
+	return recorder
+}
+
+func TestCompressDeflateIsZlib(t *testing.T) {
+	body := strings.Repeat(`{"name":"value"}`, 200)
+	recorder := serveCompressed("deflate", body)
+	if recorder.Header().Get("Content-Encoding") != "deflate" {
+		t.Fatalf("Content-Encoding = %q, want deflate", recorder.Header().Get("Content-Encoding"))
+	}
+
+	reader, err := zlib.NewReader(recorder.Body)
+	if err != nil {
+		t.Fatalf("deflate body is not zlib: %v", err)
+	}
+	decoded, err := io.ReadAll(reader)
+	if err != nil || string(decoded) != body {
+		t.Fatalf("decoded body differs: %v", err)
+	}
+}
+
+func TestCompressGzip(t *testing.T) {
+	body := strings.Repeat(`{"name":"value"}`, 200)
+	recorder := serveCompressed("gzip, deflate", body)
+
+	reader, err := gzip.NewReader(recorder.Body)
+	if err != nil {
+		t.Fatalf("gzip body: %v", err)
+	}
+	decoded, _ := io.ReadAll(reader)
+	if string(decoded) != body {
+		t.Fatal("decoded body differs")
+	}
+}
+
+func TestCompressVaryOnNegotiatedResponses(t *testing.T) {
+	for name, recorder := range map[string]*httptest.ResponseRecorder{
+		"compressed":     serveCompressed("gzip", strings.Repeat("x", 4096)),
+		"under the size": serveCompressed("gzip", `{}`),
+	} {
+		if vary := recorder.Header().Values("Vary"); len(vary) != 1 || vary[0] != "Accept-Encoding" {
+			t.Errorf("%s: Vary = %v, want Accept-Encoding once", name, vary)
+		}
+	}
+	if recorder := serveCompressed("identity", strings.Repeat("x", 4096)); recorder.Header().Get("Content-Encoding") != "" {
+		t.Fatal("response compressed for a client that accepts no encoding")
+	}
+}
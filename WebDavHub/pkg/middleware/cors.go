@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"cinesync/pkg/env"
+)
+
+// AllowOrigin sets Access-Control-Allow-Origin following
+// CINESYNC_CORS_ORIGINS, a comma separated list of origins allowed to read
+// responses from another site, or "*" for any. It allows any origin when the
+// variable is unset; when it is empty only the web UI's own origin is served.
+func AllowOrigin(w http.ResponseWriter, r *http.Request) {
+	allowed := env.GetString("CINESYNC_CORS_ORIGINS", "*")
+	origin := r.Header.Get("Origin")
+	for _, entry := range strings.Split(allowed, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "*" {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			return
+		}
+		if origin != "" && strings.EqualFold(strings.TrimSuffix(entry, "/"), origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+			return
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWrappedWritersReachTheConnectionDeadlines(t *testing.T) {
+	var writeErr, readErr error
+	handler := Recover(ServerTiming(LimitRequestBody(Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		controller := http.NewResponseController(w)
+		writeErr = controller.SetWriteDeadline(time.Now().Add(time.Minute))
+		readErr = controller.SetReadDeadline(time.Now().Add(time.Minute))
+	})))))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if writeErr != nil || readErr != nil {
+		t.Fatalf("SetWriteDeadline = %v, SetReadDeadline = %v, want both to reach the connection", writeErr, readErr)
+	}
+}
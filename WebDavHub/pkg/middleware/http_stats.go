@@ -0,0 +1,217 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyGrowth is the ratio between the upper bounds of two neighbouring
+// latency buckets, so a reported percentile is at most 5% above the real one
+const latencyGrowth = 1.05
+
+// maxLatencyBuckets covers 1µs up to about an hour
+const maxLatencyBuckets = 450
+
+// maxHTTPStatsRoutes bounds the number of routes tracked, so paths carrying
+// file names cannot grow the table without limit. Later routes count as
+// otherHTTPStatsRoute.
+const maxHTTPStatsRoutes = 500
+
+const otherHTTPStatsRoute = "other"
+
+// idSegment matches path segments that identify a record rather than a route:
+// numbers, UUIDs and long hex strings
+var idSegment = regexp.MustCompile(`^([0-9]+|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9a-fA-F]{16,})$`)
+
+// latencyHistogram counts durations in buckets growing by latencyGrowth, in
+// the spirit of an HDR histogram: fixed memory with a bounded relative error
+type latencyHistogram struct {
+	counts [maxLatencyBuckets]uint64
+	total  uint64
+	max    time.Duration
+}
+
+func latencyBucket(d time.Duration) int {
+	us := float64(d.Microseconds())
+	if us <= 1 {
+		return 0
+	}
+	bucket := int(math.Ceil(math.Log(us) / math.Log(latencyGrowth)))
+	return min(bucket, maxLatencyBuckets-1)
+}
+
+// bucketUpperBound is the largest duration counted in bucket
+func bucketUpperBound(bucket int) time.Duration {
+	return time.Duration(math.Pow(latencyGrowth, float64(bucket))) * time.Microsecond
+}
+
+func (h *latencyHistogram) record(d time.Duration) {
+	h.counts[latencyBucket(d)]++
+	h.total++
+	h.max = max(h.max, d)
+}
+
+// percentile returns the upper bound of the bucket holding the p-th
+// percentile, never more than the slowest request seen
+func (h *latencyHistogram) percentile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(p / 100 * float64(h.total)))
+	rank = max(rank, 1)
+	var seen uint64
+	for bucket, count := range h.counts {
+		seen += count
+		if seen >= rank {
+			return min(bucketUpperBound(bucket), h.max)
+		}
+	}
+	return h.max
+}
+
+// routeStats are the counters of one method and route
+type routeStats struct {
+	requests     uint64
+	clientErrors uint64
+	serverErrors uint64
+	latency      latencyHistogram
+}
+
+// HTTPRouteStats is the summary of one method and route since startup.
+// ErrorRate is the share of responses with a 5xx status.
+type HTTPRouteStats struct {
+	Method       string  `json:"method"`
+	Path         string  `json:"path"`
+	Requests     uint64  `json:"requests"`
+	ClientErrors uint64  `json:"clientErrors"`
+	ServerErrors uint64  `json:"serverErrors"`
+	ErrorRate    float64 `json:"errorRate"`
+	P50Ms        float64 `json:"p50Ms"`
+	P95Ms        float64 `json:"p95Ms"`
+	P99Ms        float64 `json:"p99Ms"`
+	MaxMs        float64 `json:"maxMs"`
+}
+
+// HTTPStatsSnapshot is the body of GET /api/stats/http
+type HTTPStatsSnapshot struct {
+	Since  time.Time        `json:"since"`
+	Routes []HTTPRouteStats `json:"routes"`
+}
+
+// httpStatsRecorder holds the counters of every route
+type httpStatsRecorder struct {
+	mutex  sync.Mutex
+	since  time.Time
+	routes map[string]*routeStats
+}
+
+var httpStats = newHTTPStatsRecorder()
+
+func newHTTPStatsRecorder() *httpStatsRecorder {
+	return &httpStatsRecorder{since: time.Now(), routes: make(map[string]*routeStats)}
+}
+
+// statsRoute reduces a request path to its route by replacing identifiers
+// with :id
+func statsRoute(requestPath string) string {
+	segments := strings.Split(requestPath, "/")
+	for i, segment := range segments {
+		if idSegment.MatchString(segment) {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func (s *httpStatsRecorder) record(method, requestPath string, status int, d time.Duration) {
+	key := method + " " + statsRoute(requestPath)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stats, ok := s.routes[key]
+	if !ok {
+		if len(s.routes) >= maxHTTPStatsRoutes {
+			key = method + " " + otherHTTPStatsRoute
+			stats = s.routes[key]
+		}
+		if stats == nil {
+			stats = &routeStats{}
+			s.routes[key] = stats
+		}
+	}
+
+	stats.requests++
+	switch {
+	case status >= 500:
+		stats.serverErrors++
+	case status >= 400:
+		stats.clientErrors++
+	}
+	stats.latency.record(d)
+}
+
+func (s *httpStatsRecorder) snapshot() HTTPStatsSnapshot {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	toMs := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	snapshot := HTTPStatsSnapshot{Since: s.since, Routes: make([]HTTPRouteStats, 0, len(s.routes))}
+	for key, stats := range s.routes {
+		method, route, _ := strings.Cut(key, " ")
+		snapshot.Routes = append(snapshot.Routes, HTTPRouteStats{
+			Method:       method,
+			Path:         route,
+			Requests:     stats.requests,
+			ClientErrors: stats.clientErrors,
+			ServerErrors: stats.serverErrors,
+			ErrorRate:    float64(stats.serverErrors) / float64(stats.requests),
+			P50Ms:        toMs(stats.latency.percentile(50)),
+			P95Ms:        toMs(stats.latency.percentile(95)),
+			P99Ms:        toMs(stats.latency.percentile(99)),
+			MaxMs:        toMs(stats.latency.max),
+		})
+	}
+	sort.Slice(snapshot.Routes, func(i, j int) bool {
+		if snapshot.Routes[i].Path != snapshot.Routes[j].Path {
+			return snapshot.Routes[i].Path < snapshot.Routes[j].Path
+		}
+		return snapshot.Routes[i].Method < snapshot.Routes[j].Method
+	})
+	return snapshot
+}
+
+// HTTPStats records the count, status and latency of every request by method
+// and route, for GET /api/stats/http. Event streams and upgraded connections
+// stay open for as long as a client watches, so they are left out.
+func HTTPStats(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/events") || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The access log's writer already tracks the response status
+		start := time.Now()
+		sw := &accessLogResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			// Recover answers a panicking handler with a 500 further out
+			if p := recover(); p != nil {
+				httpStats.record(r.Method, r.URL.Path, http.StatusInternalServerError, time.Since(start))
+				panic(p)
+			}
+		}()
+		next.ServeHTTP(sw, r)
+		httpStats.record(r.Method, r.URL.Path, sw.status, time.Since(start))
+	})
+}
+
+// CollectedHTTPStats returns the request statistics collected since startup
+func CollectedHTTPStats() HTTPStatsSnapshot {
+	return httpStats.snapshot()
+}
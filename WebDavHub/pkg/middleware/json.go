@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DecodeStrictJSON decodes a single JSON value from body into v, rejecting
+// unknown fields and any data after the value. The returned error is suitable
+// for showing to the client and names the offending field where possible.
+func DecodeStrictJSON(body io.Reader, v interface{}) error {
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		return describeJSONError(err)
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("request body must contain a single JSON object")
+	}
+	return nil
+}
+
+// describeJSONError turns decoder errors into short client-facing messages
+func describeJSONError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return errors.New("request body is empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return errors.New("request body contains incomplete JSON")
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("request body contains invalid JSON at position %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		if typeErr.Field != "" {
+			return fmt.Errorf("field %q must be of type %s", typeErr.Field, typeErr.Type)
+		}
+		return fmt.Errorf("request body must be of type %s", typeErr.Type)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return fmt.Errorf("unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	default:
+		return err
+	}
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import "net/http"
+
+// LivenessPath is the liveness probe. It is registered outside the /api/
+// chain and left out of the access log, so probes stay cheap and quiet.
+const LivenessPath = "/api/livez"
+
+// HandleLiveness answers 200 for as long as the process can serve requests.
+// Unlike /api/health it checks no database, scan or MediaHub state, so a slow
+// dependency never gets a healthy process restarted by an orchestrator.
+func HandleLiveness(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		w.Write([]byte("ok\n"))
+	}
+}
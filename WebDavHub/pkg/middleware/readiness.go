@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"cinesync/pkg/logger"
+)
+
+const readinessRetryAfter = 5 * time.Second
+
+var ready atomic.Bool
+
+// readinessExemptPaths stay reachable before the server is ready so health
+// checks, setup and login keep working during a cold start
+var readinessExemptPaths = []string{
+	"/api/health",
+	"/api/version",
+	"/api/config-status",
+	"/api/auth/",
+}
+
+// MarkReady flags the server as ready to serve requests
+func MarkReady() {
+	if !ready.Swap(true) {
+		logger.Info("Server is ready to serve requests")
+	}
+}
+
+// IsReady reports whether the database and initial load have completed
+func IsReady() bool {
+	return ready.Load()
+}
+
+// MarkReadyAfter marks the server ready once timeout has passed, so a slow or
+// failed initial load can never keep the API unavailable indefinitely
+func MarkReadyAfter(timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	time.AfterFunc(timeout, func() {
+		if !IsReady() {
+			logger.Warn("Initial load did not finish within %s, serving requests anyway", timeout)
+			MarkReady()
+		}
+	})
+}
+
+// RequireReady answers 503 Service Unavailable with a Retry-After header until
+// MarkReady has been called
+func RequireReady(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsReady() || isReadinessExempt(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", strconv.Itoa(int(readinessRetryAfter.Seconds())))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   "Service Unavailable",
+			"message": "Server is starting up, please retry shortly",
+			"status":  http.StatusServiceUnavailable,
+		})
+	})
+}
+
+func isReadinessExempt(path string) bool {
+	for _, exempt := range readinessExemptPaths {
+		if path == exempt || (strings.HasSuffix(exempt, "/") && strings.HasPrefix(path, exempt)) {
+			return true
+		}
+	}
+	return false
+}
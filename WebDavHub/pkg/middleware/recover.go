@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/logger"
+)
+
+// RequestIDHeader carries the id of a request in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDPattern accepts ids forwarded by a proxy only when they are short
+// and safe to copy into logs and headers
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type requestIDKey struct{}
+
+// RequestID returns the id Recover assigned to the request, or ""
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Recover is the outermost middleware of both servers. It gives every request
+// an id, taken from X-Request-ID when a proxy sent one, and echoes it in the
+// response. A panicking handler is logged with the id and its stack trace and
+// answered with a structured 500, so one bad request neither drops the
+// connection without a response nor takes the server down. If the handler
+// had already started its response, the connection is closed instead.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+
+		rw := &recoverResponseWriter{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			// Handlers abort responses on purpose with http.ErrAbortHandler,
+			// which net/http handles quietly
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			logger.Error("Panic serving %s %s (request %s): %v\n%s", r.Method, r.URL.Path, id, p, debug.Stack())
+			if rw.wroteHeader {
+				panic(http.ErrAbortHandler)
+			}
+			apierror.WriteErrorDetails(rw, http.StatusInternalServerError, apierror.CodeInternal, "Internal server error", map[string]string{
+				"requestId": id,
+			})
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// recoverResponseWriter records whether the response has been started
+type recoverResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *recoverResponseWriter) WriteHeader(code int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recoverResponseWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush keeps streaming handlers working behind the middleware
+func (w *recoverResponseWriter) Flush() {
+	w.wroteHeader = true
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *recoverResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
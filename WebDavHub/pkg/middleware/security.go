@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"cinesync/pkg/env"
+)
+
+// defaultTrustedProxies are the proxies trusted when CINESYNC_TRUSTED_PROXIES
+// is unset: a reverse proxy on the same host
+const defaultTrustedProxies = "127.0.0.0/8,::1/128"
+
+// IsSecureRequest reports whether a request arrived over TLS, directly or via
+// a trusted proxy that sets X-Forwarded-Proto. Cookies set by the server
+// should carry the Secure flag whenever this is true.
+func IsSecureRequest(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	return strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") && IsTrustedProxy(r)
+}
+
+// IsTrustedProxy reports whether the request comes straight from a proxy in
+// CINESYNC_TRUSTED_PROXIES, a comma separated list of addresses and CIDR
+// ranges. Only such proxies may speak for the client through forwarding
+// headers; anyone else could set them.
+func IsTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, entry := range strings.Split(env.GetString("CINESYNC_TRUSTED_PROXIES", defaultTrustedProxies), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			if prefix.Contains(addr) {
+				return true
+			}
+		} else if proxy, err := netip.ParseAddr(entry); err == nil && proxy.Unmap() == addr {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	defaultHSTSMaxAge = 31536000
+	defaultCSP        = "default-src 'self'; img-src 'self' data: blob: https:; style-src 'self' 'unsafe-inline'; font-src 'self' data:; connect-src 'self' ws: wss:; frame-ancestors 'self'"
+)
+
+// SecurityHeaders applies standard security headers to every response. Each
+// header can be turned off or overridden through the environment:
+// CINESYNC_HSTS, CINESYNC_HSTS_MAX_AGE, CINESYNC_NOSNIFF, CINESYNC_FRAME_OPTIONS
+// and CINESYNC_CSP. Strict-Transport-Security is only sent over TLS, as seen
+// by IsSecureRequest.
+func SecurityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !env.IsBool("CINESYNC_SECURITY_HEADERS", true) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		if IsSecureRequest(r) && env.IsBool("CINESYNC_HSTS", true) {
+			maxAge := env.GetInt("CINESYNC_HSTS_MAX_AGE", defaultHSTSMaxAge)
+			header.Set("Strict-Transport-Security", fmt.Sprintf("max-age=%d; includeSubDomains", maxAge))
+		}
+		if env.IsBool("CINESYNC_NOSNIFF", true) {
+			header.Set("X-Content-Type-Options", "nosniff")
+		}
+		if frameOptions := env.GetString("CINESYNC_FRAME_OPTIONS", "SAMEORIGIN"); frameOptions != "" {
+			header.Set("X-Frame-Options", frameOptions)
+		}
+		if csp := env.GetString("CINESYNC_CSP", defaultCSP); csp != "" {
+			header.Set("Content-Security-Policy", csp)
+		}
+		header.Set("Referrer-Policy", "same-origin")
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serveSecurityHeaders(r *http.Request) http.Header {
+	handler := SecurityHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, r)
+	return recorder.Header()
+}
+
+func TestHSTSOnlyTrustsForwardedProtoFromProxies(t *testing.T) {
+	t.Setenv("CINESYNC_TRUSTED_PROXIES", "10.0.0.0/8, 192.0.2.1")
+
+	for remoteAddr, want := range map[string]bool{
+		"10.1.2.3:5000":    true,
+		"192.0.2.1:5000":   true,
+		"203.0.113.9:5000": false,
+		"127.0.0.1:5000":   false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("X-Forwarded-Proto", "https")
+		if got := serveSecurityHeaders(r).Get("Strict-Transport-Security") != ""; got != want {
+			t.Errorf("HSTS for X-Forwarded-Proto from %s = %v, want %v", remoteAddr, got, want)
+		}
+	}
+}
+
+func TestHSTSOverTLS(t *testing.T) {
+	t.Setenv("CINESYNC_TRUSTED_PROXIES", "")
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.TLS = &tls.ConnectionState{}
+	if serveSecurityHeaders(r).Get("Strict-Transport-Security") == "" {
+		t.Fatal("no HSTS over TLS")
+	}
+
+	plain := httptest.NewRequest(http.MethodGet, "/", nil)
+	if serveSecurityHeaders(plain).Get("Strict-Transport-Security") != "" {
+		t.Fatal("HSTS sent over plain HTTP")
+	}
+}
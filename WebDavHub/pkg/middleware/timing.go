@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"cinesync/pkg/env"
+)
+
+type timingsKey struct{}
+
+// serverTimings collects the durations of the phases of one request
+type serverTimings struct {
+	mutex  sync.Mutex
+	start  time.Time
+	order  []string
+	phases map[string]time.Duration
+}
+
+func (t *serverTimings) add(name string, d time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if _, exists := t.phases[name]; !exists {
+		t.order = append(t.order, name)
+	}
+	t.phases[name] += d
+}
+
+// header formats the phases and the total request time as a Server-Timing value
+func (t *serverTimings) header() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	metrics := make([]string, 0, len(t.order)+1)
+	for _, name := range t.order {
+		metrics = append(metrics, formatTimingMetric(name, t.phases[name]))
+	}
+	metrics = append(metrics, formatTimingMetric("total", time.Since(t.start)))
+	return strings.Join(metrics, ", ")
+}
+
+// formatTimingMetric formats one metric, replacing characters that are not
+// allowed in a header token
+func formatTimingMetric(name string, d time.Duration) string {
+	name = strings.Map(func(r rune) rune {
+		if r > ' ' && r < 0x7f && !strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return r
+		}
+		return '_'
+	}, name)
+	return fmt.Sprintf("%s;dur=%.1f", name, float64(d.Microseconds())/1000)
+}
+
+// ServerTiming adds a Server-Timing header to responses, breaking the request
+// down into the phases handlers report through StartTiming. It is meant for
+// debugging and only active when CINESYNC_SERVER_TIMING is true.
+func ServerTiming(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !env.IsBool("CINESYNC_SERVER_TIMING", false) || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		timings := &serverTimings{start: time.Now(), phases: make(map[string]time.Duration)}
+		tw := &timingResponseWriter{ResponseWriter: w, timings: timings}
+		next.ServeHTTP(tw, r.WithContext(context.WithValue(r.Context(), timingsKey{}, timings)))
+	})
+}
+
+// StartTiming starts timing a phase of the request and returns the function
+// that ends it. Phases with the same name add up. Only phases that end before
+// the response is written appear in the header.
+func StartTiming(ctx context.Context, name string) func() {
+	timings, ok := ctx.Value(timingsKey{}).(*serverTimings)
+	if !ok {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		timings.add(name, time.Since(start))
+	}
+}
+
+// WriteJSON writes v as a JSON response, timing the encoding as the
+// serialize phase
+func WriteJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	stop := StartTiming(r.Context(), "serialize")
+	body, err := json.Marshal(v)
+	stop()
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(append(body, '\n'))
+	return err
+}
+
+// timingResponseWriter sets the Server-Timing header when the response starts
+type timingResponseWriter struct {
+	http.ResponseWriter
+	timings     *serverTimings
+	wroteHeader bool
+}
+
+func (w *timingResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.Header().Set("Server-Timing", w.timings.header())
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timingResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush keeps streaming handlers working behind the middleware
+func (w *timingResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *timingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
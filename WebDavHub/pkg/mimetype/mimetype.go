@@ -0,0 +1,119 @@
+package mimetype
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+)
+
+// Fallback is served when nothing better is known about a file
+const Fallback = "application/octet-stream"
+
+// sniffLength is how much of a file http.DetectContentType looks at
+const sniffLength = 512
+
+// mediaTypes covers the files a library holds. The system MIME table often
+// lacks them or, as for .ts, maps them to something unrelated.
+var mediaTypes = map[string]string{
+	".mkv":  "video/x-matroska",
+	".mk3d": "video/x-matroska",
+	".mka":  "audio/x-matroska",
+	".mp4":  "video/mp4",
+	".m4v":  "video/x-m4v",
+	".webm": "video/webm",
+	".avi":  "video/x-msvideo",
+	".mov":  "video/quicktime",
+	".wmv":  "video/x-ms-wmv",
+	".flv":  "video/x-flv",
+	".ts":   "video/mp2t",
+	".m2ts": "video/mp2t",
+	".mts":  "video/mp2t",
+	".mpg":  "video/mpeg",
+	".mpeg": "video/mpeg",
+	".ogv":  "video/ogg",
+	".3gp":  "video/3gpp",
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".flac": "audio/flac",
+	".srt":  "application/x-subrip",
+	".vtt":  "text/vtt",
+	".ass":  "text/x-ssa",
+	".ssa":  "text/x-ssa",
+	".nfo":  "text/plain; charset=utf-8",
+}
+
+// TypeByExtension returns the media type for a file name, looking at the
+// CINESYNC_MIME_TYPES overrides, the built-in media types and the system
+// MIME table in that order. It returns "" for an unknown extension.
+func TypeByExtension(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext == "" {
+		return ""
+	}
+	if contentType, ok := overrides()[ext]; ok {
+		return contentType
+	}
+	if contentType, ok := mediaTypes[ext]; ok {
+		return contentType
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// SniffEnabled reports whether files of unknown type are identified by their
+// content, from CINESYNC_MIME_SNIFF
+func SniffEnabled() bool {
+	return env.IsBool("CINESYNC_MIME_SNIFF", true)
+}
+
+// Detect returns the Content-Type for a file: by extension, else by sniffing
+// the start of content when enabled, else Fallback. content is rewound.
+func Detect(name string, content io.ReadSeeker) string {
+	if contentType := TypeByExtension(name); contentType != "" {
+		return contentType
+	}
+	if content == nil || !SniffEnabled() {
+		return Fallback
+	}
+
+	buf := make([]byte, sniffLength)
+	n, err := io.ReadFull(content, buf)
+	if _, seekErr := content.Seek(0, io.SeekStart); seekErr != nil {
+		logger.Warn("Failed to rewind %s after sniffing its type: %v", name, seekErr)
+	}
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return Fallback
+	}
+	return http.DetectContentType(buf[:n])
+}
+
+// overrides parses CINESYNC_MIME_TYPES, a comma separated list of
+// extension=type pairs such as ".mkv=video/webm,.sup=application/octet-stream"
+func overrides() map[string]string {
+	value := env.GetString("CINESYNC_MIME_TYPES", "")
+	if value == "" {
+		return nil
+	}
+
+	types := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		ext, contentType, ok := strings.Cut(entry, "=")
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		contentType = strings.TrimSpace(contentType)
+		if !ok || ext == "" || contentType == "" {
+			if strings.TrimSpace(entry) != "" {
+				logger.Warn("Ignoring invalid CINESYNC_MIME_TYPES entry %q", entry)
+			}
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		types[ext] = contentType
+	}
+	return types
+}
@@ -0,0 +1,41 @@
+package mimetype
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestTypeByExtensionPrefersOverrides(t *testing.T) {
+	t.Setenv("CINESYNC_MIME_TYPES", "sup=application/x-pgs, .MKV=video/webm,broken")
+	for name, want := range map[string]string{
+		"Movie.mkv":    "video/webm",
+		"Movie.sup":    "application/x-pgs",
+		"Episode.ts":   "video/mp2t",
+		"Movie.M2TS":   "video/mp2t",
+		"Movie":        "",
+		"Movie.nosuch": "",
+	} {
+		if got := TypeByExtension(name); got != want {
+			t.Errorf("TypeByExtension(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestDetectSniffsUnknownFilesAndRewinds(t *testing.T) {
+	t.Setenv("CINESYNC_MIME_TYPES", "")
+	t.Setenv("CINESYNC_MIME_SNIFF", "true")
+	content := strings.NewReader("%PDF-1.7 rest of the document")
+
+	if got := Detect("document", content); got != "application/pdf" {
+		t.Fatalf("Detect = %q, want application/pdf", got)
+	}
+	if rest, _ := io.ReadAll(content); !strings.HasPrefix(string(rest), "%PDF") {
+		t.Fatal("content was not rewound after sniffing")
+	}
+
+	t.Setenv("CINESYNC_MIME_SNIFF", "false")
+	if got := Detect("document", content); got != Fallback {
+		t.Fatalf("Detect without sniffing = %q, want %q", got, Fallback)
+	}
+}
@@ -0,0 +1,87 @@
+package naming
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// useRuleset selects a path ruleset without any of the options on top of it
+func useRuleset(t *testing.T, name string) {
+	t.Helper()
+	t.Setenv("CINESYNC_PATH_RULESET", name)
+	t.Setenv("CINESYNC_PATH_REPLACEMENT", "")
+	t.Setenv("CINESYNC_PATH_LOWERCASE", "false")
+	t.Setenv("CINESYNC_PATH_MAX_LENGTH", "0")
+}
+
+func TestRenderPadsAndNormalizesTokens(t *testing.T) {
+	got := Render("{Series Title} S{season:00}E{EPISODE:00} {missing}", map[string]string{
+		"series_title": "Dark",
+		"season":       "1",
+		"episode":      "12",
+	})
+	if want := "Dark S01E12 "; got != want {
+		t.Fatalf("Render = %q, want %q", got, want)
+	}
+}
+
+func TestRenderPathKeepsFieldsInTheirSegment(t *testing.T) {
+	useRuleset(t, DefaultRuleset)
+	got := RenderPath("Music/{artist}/{album} ({year})", map[string]string{"artist": "AC/DC", "album": "Back in Black"})
+	if want := "Music/ACDC/Back in Black"; got != want {
+		t.Fatalf("RenderPath = %q, want %q", got, want)
+	}
+}
+
+func TestRulesetsReplaceWhatTheFileSystemForbids(t *testing.T) {
+	fields := map[string]string{"title": "What If...?: CON"}
+
+	useRuleset(t, "posix")
+	if got := RenderPath("{title}", fields); got != "What If...?: CON" {
+		t.Errorf("posix = %q, want the title unchanged", got)
+	}
+
+	useRuleset(t, "windows")
+	if got := RenderPath("{title}", fields); got != "What If... CON" {
+		t.Errorf("windows = %q, want illegal characters stripped", got)
+	}
+	if got := RenderFile("{title}", map[string]string{"title": "con"}, ".mkv", "file"); got != "con_.mkv" {
+		t.Errorf("windows reserved name = %q, want con_.mkv", got)
+	}
+
+	t.Setenv("CINESYNC_PATH_REPLACEMENT", "-")
+	if got := RenderPath("{title}", map[string]string{"title": "A:B"}); got != "A-B" {
+		t.Errorf("replacement = %q, want A-B", got)
+	}
+	t.Setenv("CINESYNC_PATH_REPLACEMENT", ":")
+	if got := RenderPath("{title}", map[string]string{"title": "A:B"}); got != "AB" {
+		t.Errorf("illegal replacement = %q, want the character stripped", got)
+	}
+}
+
+func TestRenderFileKeepsExtensionWithinLimits(t *testing.T) {
+	useRuleset(t, DefaultRuleset)
+	long := strings.Repeat("é", 200)
+
+	got := RenderFile("{title}", map[string]string{"title": long}, ".mkv", "file")
+	if len(got) > 255 || !strings.HasSuffix(got, ".mkv") || !utf8.ValidString(got) {
+		t.Fatalf("RenderFile = %d bytes %q, want at most 255 valid bytes ending in .mkv", len(got), got)
+	}
+
+	t.Setenv("CINESYNC_PATH_MAX_LENGTH", "40")
+	got = RenderFile("{title}/{title}", map[string]string{"title": long}, ".mkv", "file")
+	if len(got) > 40 || !strings.HasSuffix(got, ".mkv") || strings.Count(got, "/") != 1 || !utf8.ValidString(got) {
+		t.Fatalf("RenderFile = %d bytes %q, want at most 40 valid bytes in two segments", len(got), got)
+	}
+
+	if got := RenderFile("{title}", nil, ".mkv", "Fallback"); got != "Fallback.mkv" {
+		t.Fatalf("empty name = %q, want the fallback", got)
+	}
+}
+
+func TestIsValidRuleset(t *testing.T) {
+	if !IsValidRuleset(" SMB ") || IsValidRuleset("ntfs3") {
+		t.Fatal("ruleset names are not matched case-insensitively against the known sets")
+	}
+}
@@ -0,0 +1,222 @@
+package naming
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"cinesync/pkg/env"
+)
+
+// DefaultRuleset strips the characters Windows forbids but otherwise keeps
+// names as rendered
+const DefaultRuleset = "default"
+
+// ruleset is how rendered path segments are sanitized for a target file system
+type ruleset struct {
+	// illegal lists the characters replaced in a segment, besides "/" and
+	// control characters, which are replaced under every ruleset
+	illegal string
+	// reservedNames renames the device names Windows reserves, such as CON
+	reservedNames bool
+	// maxSegment bounds a segment in bytes, or in characters when countRunes
+	// is set, as on file systems that store names in UTF-16
+	maxSegment int
+	countRunes bool
+}
+
+var windowsRuleset = ruleset{illegal: `<>:"|?*\`, reservedNames: true, maxSegment: 255, countRunes: true}
+
+// rulesets are the rule sets CINESYNC_PATH_RULESET selects from, named after
+// the file system the destination lives on
+var rulesets = map[string]ruleset{
+	DefaultRuleset: {illegal: `<>:"|?*\`, maxSegment: 255},
+	"posix":        {maxSegment: 255},
+	"windows":      windowsRuleset,
+	"smb":          windowsRuleset,
+	"exfat":        windowsRuleset,
+}
+
+// IsValidRuleset reports whether name is a known path ruleset
+func IsValidRuleset(name string) bool {
+	_, ok := rulesets[strings.ToLower(strings.TrimSpace(name))]
+	return ok
+}
+
+// pathRules is a ruleset combined with the options that apply on top of it
+type pathRules struct {
+	ruleset
+	replacement string
+	lowercase   bool
+	maxPath     int
+}
+
+// currentRules returns the ruleset selected by CINESYNC_PATH_RULESET with
+// CINESYNC_PATH_REPLACEMENT, CINESYNC_PATH_LOWERCASE and
+// CINESYNC_PATH_MAX_LENGTH applied. A replacement that is itself illegal
+// under the ruleset is ignored, so characters are stripped instead.
+func currentRules() pathRules {
+	set, ok := rulesets[strings.ToLower(strings.TrimSpace(env.GetString("CINESYNC_PATH_RULESET", DefaultRuleset)))]
+	if !ok {
+		set = rulesets[DefaultRuleset]
+	}
+	rules := pathRules{
+		ruleset:     set,
+		replacement: env.GetString("CINESYNC_PATH_REPLACEMENT", ""),
+		lowercase:   env.IsBool("CINESYNC_PATH_LOWERCASE", false),
+		maxPath:     env.GetInt("CINESYNC_PATH_MAX_LENGTH", 0),
+	}
+	if strings.IndexFunc(rules.replacement, rules.isIllegal) >= 0 {
+		rules.replacement = ""
+	}
+	return rules
+}
+
+// escapeFields returns fields with "/" replaced, so a value such as "AC/DC"
+// stays within its segment instead of adding folders
+func (r pathRules) escapeFields(fields map[string]string) map[string]string {
+	escaped := make(map[string]string, len(fields))
+	for k, v := range fields {
+		escaped[k] = strings.ReplaceAll(v, "/", r.replacement)
+	}
+	return escaped
+}
+
+func (r pathRules) isIllegal(c rune) bool {
+	return c == '/' || c < 0x20 || c == 0x7f || strings.ContainsRune(r.illegal, c)
+}
+
+// length measures s the way the ruleset bounds segments
+func (r pathRules) length(s string) int {
+	if r.countRunes {
+		return utf8.RuneCountInString(s)
+	}
+	return len(s)
+}
+
+// truncate shortens s to at most max by the ruleset's measure, never
+// splitting a character
+func (r pathRules) truncate(s string, max int) string {
+	if max <= 0 {
+		return ""
+	}
+	if r.length(s) <= max {
+		return s
+	}
+	if r.countRunes {
+		return string([]rune(s)[:max])
+	}
+	return truncateBytes(s, max)
+}
+
+// windowsReservedNames are device names Windows refuses as file names, with or
+// without an extension
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// clean sanitizes one segment, leaving room for a suffix of reserve bytes or
+// characters, such as the extension added after it
+func (r pathRules) clean(segment string, reserve int) string {
+	segment = strings.Map(func(c rune) rune {
+		if r.isIllegal(c) {
+			return -1
+		}
+		return c
+	}, strings.NewReplacer(r.illegalPairs()...).Replace(segment))
+	segment = strings.ReplaceAll(segment, "()", "")
+	segment = strings.ReplaceAll(segment, "[]", "")
+	segment = strings.Join(strings.Fields(segment), " ")
+	segment = strings.Trim(segment, " .-")
+	if r.lowercase {
+		segment = strings.ToLower(segment)
+	}
+	if r.reservedNames {
+		base, ext, _ := strings.Cut(segment, ".")
+		if windowsReservedNames[strings.ToUpper(strings.TrimSpace(base))] {
+			segment = strings.TrimSpace(base) + "_"
+			if ext != "" {
+				segment += "." + ext
+			}
+		}
+	}
+	if r.maxSegment > 0 {
+		segment = strings.Trim(r.truncate(segment, r.maxSegment-reserve), " .-")
+	}
+	if segment == "." || segment == ".." {
+		return ""
+	}
+	return segment
+}
+
+// illegalPairs returns the replacer pairs turning each illegal character into
+// the replacement. Characters without a pair are stripped by clean.
+func (r pathRules) illegalPairs() []string {
+	if r.replacement == "" {
+		return nil
+	}
+	pairs := []string{"/", r.replacement}
+	for _, c := range r.illegal {
+		pairs = append(pairs, string(c), r.replacement)
+	}
+	return pairs
+}
+
+// fitPath shortens the longest segments, evening them out, until the joined
+// path is at most maxPath bytes long, keeping at least one character of each.
+// The last segment keeps room for a suffix of reserve bytes.
+func (r pathRules) fitPath(segments []string, reserve int) []string {
+	if r.maxPath <= 0 {
+		return segments
+	}
+	for {
+		total := reserve + len(segments) - 1
+		longest, next := -1, 0
+		for i, segment := range segments {
+			total += len(segment)
+			switch {
+			case longest < 0 || len(segment) > len(segments[longest]):
+				if longest >= 0 {
+					next = len(segments[longest])
+				}
+				longest = i
+			case len(segment) > next:
+				next = len(segment)
+			}
+		}
+		if total <= r.maxPath || longest < 0 || utf8.RuneCountInString(segments[longest]) <= 1 {
+			return segments
+		}
+
+		// Shorten the longest segment no further than the next longest, so
+		// every long name keeps a readable part
+		target := len(segments[longest]) - (total - r.maxPath)
+		if target < next {
+			target = next
+		}
+		if target >= len(segments[longest]) {
+			target = len(segments[longest]) - 1
+		}
+		shortened := strings.TrimRight(truncateBytes(segments[longest], target), " .-")
+		if shortened == "" {
+			_, size := utf8.DecodeRuneInString(segments[longest])
+			shortened = segments[longest][:size]
+		}
+		segments[longest] = shortened
+	}
+}
+
+// truncateBytes shortens s to at most max bytes without splitting a character
+func truncateBytes(s string, max int) string {
+	if max <= 0 {
+		return ""
+	}
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
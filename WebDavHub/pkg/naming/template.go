@@ -0,0 +1,90 @@
+package naming
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// tokenPattern matches {token} and {token:00} placeholders
+var tokenPattern = regexp.MustCompile(`\{([A-Za-z_ ]+)(?::(0+))?\}`)
+
+// Render expands a naming template such as "Movies/{year}/{title} ({year})".
+// Token names are case-insensitive and spaces are treated as underscores, so
+// "{Series Title}" resolves the "series_title" field. A ":00" suffix zero-pads
+// numeric values to the given width. Unknown tokens render as empty strings.
+func Render(template string, fields map[string]string) string {
+	normalized := make(map[string]string, len(fields))
+	for k, v := range fields {
+		normalized[normalizeKey(k)] = v
+	}
+
+	return tokenPattern.ReplaceAllStringFunc(template, func(match string) string {
+		parts := tokenPattern.FindStringSubmatch(match)
+		value := normalized[normalizeKey(parts[1])]
+		if parts[2] != "" {
+			if n, err := strconv.Atoi(value); err == nil {
+				value = padNumber(n, len(parts[2]))
+			}
+		}
+		return value
+	})
+}
+
+// RenderPath renders a template and cleans each "/"-separated segment so the
+// result is safe to use as a relative path under the configured path ruleset.
+// Empty segments are dropped.
+func RenderPath(template string, fields map[string]string) string {
+	rules := currentRules()
+	return strings.Join(rules.fitPath(renderSegments(rules, template, rules.escapeFields(fields)), 0), "/")
+}
+
+// RenderFile renders a template whose last segment names a file, adds ext to
+// that name and returns the relative path. fallback names the file when the
+// last segment renders empty. The name keeps room for ext when the ruleset
+// truncates it.
+func RenderFile(template string, fields map[string]string, ext, fallback string) string {
+	rules := currentRules()
+	dir, name := "", template
+	if i := strings.LastIndex(template, "/"); i >= 0 {
+		dir, name = template[:i], template[i+1:]
+	}
+
+	fields = rules.escapeFields(fields)
+	segments := renderSegments(rules, dir, fields)
+	file := rules.clean(Render(name, fields), rules.length(ext))
+	if file == "" {
+		file = rules.clean(fallback, rules.length(ext))
+	}
+	segments = rules.fitPath(append(segments, file), len(ext))
+	return strings.Join(segments, "/") + ext
+}
+
+func renderSegments(rules pathRules, template string, fields map[string]string) []string {
+	var segments []string
+	for _, segment := range strings.Split(Render(template, fields), "/") {
+		if segment = rules.clean(segment, 0); segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// CleanSegment removes characters that are invalid in file names under the
+// configured path ruleset and tidies leftovers such as empty parentheses from
+// missing tokens
+func CleanSegment(segment string) string {
+	return currentRules().clean(segment, 0)
+}
+
+func normalizeKey(key string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(key)), " ", "_")
+}
+
+func padNumber(n, width int) string {
+	s := strconv.Itoa(n)
+	for len(s) < width {
+		s = "0" + s
+	}
+	return s
+}
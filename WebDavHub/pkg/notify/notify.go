@@ -0,0 +1,201 @@
+// Package notify delivers events as JSON to the webhooks listed in
+// CINESYNC_WEBHOOK_URLS. Each delivery is bounded by CINESYNC_WEBHOOK_TIMEOUT
+// and retried CINESYNC_WEBHOOK_RETRIES times on network errors and 5xx
+// responses.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"cinesync/pkg/env"
+	"cinesync/pkg/httpclient"
+	"cinesync/pkg/logger"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	defaultRetries = 2
+	maxRetries     = 10
+)
+
+// retryBackoff is the wait before the first retry, doubled for each further one
+var retryBackoff = time.Second
+
+// webhookClient sends the deliveries; timeouts are set per request by Policy
+var webhookClient = httpclient.New(0)
+
+// Event is the JSON body posted to every webhook
+type Event struct {
+	Event   string                 `json:"event"`
+	Message string                 `json:"message"`
+	Time    time.Time              `json:"time"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+}
+
+// Policy bounds one delivery: the timeout of each attempt and how many times a
+// failed attempt is retried
+type Policy struct {
+	Timeout time.Duration
+	Retries int
+}
+
+// CurrentPolicy returns the delivery policy from CINESYNC_WEBHOOK_TIMEOUT and
+// CINESYNC_WEBHOOK_RETRIES
+func CurrentPolicy() Policy {
+	timeout, err := time.ParseDuration(env.GetString("CINESYNC_WEBHOOK_TIMEOUT", defaultTimeout.String()))
+	if err != nil || timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	retries := env.GetInt("CINESYNC_WEBHOOK_RETRIES", defaultRetries)
+	if retries < 0 || retries > maxRetries {
+		retries = defaultRetries
+	}
+	return Policy{Timeout: timeout, Retries: retries}
+}
+
+// Result is the outcome of delivering an event to one webhook
+type Result struct {
+	Target     string `json:"target"`
+	Success    bool   `json:"success"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	LatencyMs  int64  `json:"latencyMs"`
+	Attempts   int    `json:"attempts"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Targets returns the webhook URLs from the comma separated
+// CINESYNC_WEBHOOK_URLS
+func Targets() []string {
+	var targets []string
+	for _, target := range strings.Split(env.GetString("CINESYNC_WEBHOOK_URLS", ""), ",") {
+		if target = strings.TrimSpace(target); target != "" {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// Enabled reports whether any webhook is configured
+func Enabled() bool {
+	return len(Targets()) > 0
+}
+
+// redactTarget returns the scheme and host of target only. Chat webhooks
+// carry their secret in the path or query, so both are masked; results keep
+// the order the webhooks are listed in to tell them apart.
+func redactTarget(target string) string {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return "invalid webhook URL"
+	}
+	redacted := u.Scheme + "://" + u.Host
+	if strings.Trim(u.Path, "/") != "" || u.RawQuery != "" {
+		redacted += "/…"
+	}
+	return redacted
+}
+
+// Send delivers event to every configured webhook under the current policy
+// and returns the result of each, in the order the webhooks are listed.
+// Failed deliveries are logged.
+func Send(event Event) []Result {
+	return deliverAll(Targets(), event, CurrentPolicy())
+}
+
+// Test delivers a sample event to every configured webhook under the current
+// timeout but without retries, so a broken webhook is reported at once
+func Test() []Result {
+	policy := CurrentPolicy()
+	policy.Retries = 0
+	return deliverAll(Targets(), Event{
+		Event:   "test",
+		Message: "Test notification from CineSync",
+		Time:    time.Now().UTC(),
+	}, policy)
+}
+
+func deliverAll(targets []string, event Event, policy Policy) []Result {
+	results := make([]Result, len(targets))
+	body, err := json.Marshal(event)
+	if err != nil {
+		for i, target := range targets {
+			results[i] = Result{Target: redactTarget(target), Error: err.Error()}
+		}
+		return results
+	}
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			results[i] = deliver(target, body, policy)
+			if !results[i].Success {
+				logger.Warn("Webhook %s failed for %s event after %d attempts: %s", results[i].Target, event.Event, results[i].Attempts, results[i].Error)
+			}
+		}(i, target)
+	}
+	wg.Wait()
+	return results
+}
+
+// deliver posts body to target, retrying network errors and 5xx responses as
+// policy allows. Latency is that of the last attempt.
+func deliver(target string, body []byte, policy Policy) Result {
+	result := Result{Target: redactTarget(target)}
+	backoff := retryBackoff
+	for attempt := 0; attempt <= policy.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		result.Attempts = attempt + 1
+
+		start := time.Now()
+		statusCode, err := post(target, body, policy.Timeout)
+		result.LatencyMs = time.Since(start).Milliseconds()
+		result.StatusCode = statusCode
+		switch {
+		case err != nil:
+			result.Error = err.Error()
+		case statusCode >= 200 && statusCode < 300:
+			result.Success, result.Error = true, ""
+			return result
+		default:
+			result.Error = fmt.Sprintf("webhook returned %d", statusCode)
+			if statusCode < 500 {
+				return result
+			}
+		}
+	}
+	return result
+}
+
+func post(target string, body []byte, timeout time.Duration) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("invalid webhook URL")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "CineSync")
+
+	client := *webhookClient
+	client.Timeout = timeout
+	resp, err := client.Do(req)
+	if urlErr, ok := err.(*url.Error); ok {
+		// url.Error repeats the URL, including any secret it carries
+		return 0, fmt.Errorf("%s %s: %v", urlErr.Op, redactTarget(target), urlErr.Err)
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func useNoBackoff(t *testing.T) {
+	t.Helper()
+	previous := retryBackoff
+	retryBackoff = time.Millisecond
+	t.Cleanup(func() { retryBackoff = previous })
+}
+
+func TestDeliverRetriesServerErrorsOnly(t *testing.T) {
+	useNoBackoff(t)
+	for status, wantAttempts := range map[int]int{
+		http.StatusServiceUnavailable: 3,
+		http.StatusBadRequest:         1,
+		http.StatusNoContent:          1,
+	} {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(status)
+		}))
+		result := deliver(server.URL, []byte(`{}`), Policy{Timeout: time.Second, Retries: 2})
+		server.Close()
+
+		if result.Attempts != wantAttempts || int(calls.Load()) != wantAttempts {
+			t.Errorf("status %d: %d attempts, %d calls, want %d", status, result.Attempts, calls.Load(), wantAttempts)
+		}
+		if result.Success != (status < 300) || result.StatusCode != status {
+			t.Errorf("status %d: result = %+v", status, result)
+		}
+	}
+}
+
+func TestResultsDoNotLeakWebhookSecrets(t *testing.T) {
+	useNoBackoff(t)
+	target := "http://127.0.0.1:1/hooks/secret-token?key=secret"
+
+	result := deliver(target, []byte(`{}`), Policy{Timeout: time.Second})
+	if result.Success || strings.Contains(result.Target+result.Error, "secret") {
+		t.Fatalf("result = %+v, want a failure without the secret", result)
+	}
+	if result.Target != "http://127.0.0.1:1/…" {
+		t.Fatalf("target = %q, want scheme and host only", result.Target)
+	}
+}
+
+func TestCurrentPolicyFallsBackOnInvalidValues(t *testing.T) {
+	t.Setenv("CINESYNC_WEBHOOK_TIMEOUT", "soon")
+	t.Setenv("CINESYNC_WEBHOOK_RETRIES", "99")
+	if policy := CurrentPolicy(); policy.Timeout != defaultTimeout || policy.Retries != defaultRetries {
+		t.Fatalf("policy = %+v, want the defaults", policy)
+	}
+}
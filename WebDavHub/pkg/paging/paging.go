@@ -0,0 +1,112 @@
+package paging
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// PagedResponse is the envelope list endpoints answer with. NextCursor is set
+// while more items follow and can be sent back as ?cursor= to fetch them.
+type PagedResponse[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int    `json:"total"`
+	Page       int    `json:"page"`
+	PageSize   int    `json:"pageSize"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// Request is the window of a list a client asked for
+type Request struct {
+	Offset int
+	Limit  int
+}
+
+// Parse reads the requested window from the query string. The size comes from
+// pageSize or limit, capped at maxSize when it is positive. The start comes
+// from cursor, else offset, else page.
+func Parse(r *http.Request, defaultSize, maxSize int) Request {
+	values := r.URL.Query()
+	req := Request{Limit: defaultSize}
+
+	size := values.Get("pageSize")
+	if size == "" {
+		size = values.Get("limit")
+	}
+	if n, err := strconv.Atoi(size); err == nil && n > 0 {
+		req.Limit = n
+	}
+	if maxSize > 0 && req.Limit > maxSize {
+		req.Limit = maxSize
+	}
+
+	if offset, ok := decodeCursor(values.Get("cursor")); ok {
+		req.Offset = offset
+	} else if n, err := strconv.Atoi(values.Get("offset")); err == nil && n >= 0 {
+		req.Offset = n
+	} else if n, err := strconv.Atoi(values.Get("page")); err == nil && n > 0 {
+		req.Offset = (n - 1) * req.Limit
+	}
+	return req
+}
+
+// Page returns the 1-based page the window starts on
+func (req Request) Page() int {
+	if req.Limit <= 0 {
+		return 1
+	}
+	return req.Offset/req.Limit + 1
+}
+
+// NewResponse wraps one window of items out of total matching items
+func NewResponse[T any](items []T, total int, req Request) PagedResponse[T] {
+	if items == nil {
+		items = make([]T, 0)
+	}
+	response := PagedResponse[T]{
+		Items:    items,
+		Total:    total,
+		Page:     req.Page(),
+		PageSize: req.Limit,
+	}
+	if next := req.Offset + len(items); len(items) > 0 && next < total {
+		response.NextCursor = EncodeCursor(next)
+	}
+	return response
+}
+
+// Window returns the part of an in-memory list the request covers
+func Window[T any](items []T, req Request) []T {
+	start := min(max(req.Offset, 0), len(items))
+	end := len(items)
+	if req.Limit > 0 {
+		end = min(start+req.Limit, len(items))
+	}
+	return items[start:end]
+}
+
+// Slice pages an in-memory list
+func Slice[T any](items []T, req Request) PagedResponse[T] {
+	return NewResponse(Window(items, req), len(items), req)
+}
+
+// EncodeCursor returns the opaque cursor pointing at offset
+func EncodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("o:" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, bool) {
+	if cursor == "" {
+		return 0, false
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, false
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), "o:"))
+	if err != nil || offset < 0 || !strings.HasPrefix(string(raw), "o:") {
+		return 0, false
+	}
+	return offset, true
+}
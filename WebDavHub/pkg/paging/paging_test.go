@@ -0,0 +1,43 @@
+package paging
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseReadsSizeAndStart(t *testing.T) {
+	for query, want := range map[string]Request{
+		"":                                     {Offset: 0, Limit: 50},
+		"?pageSize=20&page=3":                  {Offset: 40, Limit: 20},
+		"?limit=10&offset=5":                   {Offset: 5, Limit: 10},
+		"?pageSize=1000":                       {Offset: 0, Limit: 200},
+		"?pageSize=-1&page=0":                  {Offset: 0, Limit: 50},
+		"?offset=5&cursor=" + EncodeCursor(30): {Offset: 30, Limit: 50},
+		"?offset=5&cursor=bogus":               {Offset: 5, Limit: 50},
+	} {
+		if got := Parse(httptest.NewRequest("GET", "/items"+query, nil), 50, 200); got != want {
+			t.Errorf("Parse(%q) = %+v, want %+v", query, got, want)
+		}
+	}
+}
+
+func TestSliceSetsCursorWhileItemsFollow(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+
+	first := Slice(items, Request{Offset: 0, Limit: 2})
+	if len(first.Items) != 2 || first.Total != 5 || first.Page != 1 || first.NextCursor == "" {
+		t.Fatalf("first page = %+v", first)
+	}
+	next := Parse(httptest.NewRequest("GET", "/items?limit=2&cursor="+first.NextCursor, nil), 2, 0)
+	if next.Offset != 2 {
+		t.Fatalf("cursor points at %d, want 2", next.Offset)
+	}
+
+	last := Slice(items, Request{Offset: 4, Limit: 2})
+	if len(last.Items) != 1 || last.Page != 3 || last.NextCursor != "" {
+		t.Fatalf("last page = %+v", last)
+	}
+	if past := Slice(items, Request{Offset: 10, Limit: 2}); past.Items == nil || len(past.Items) != 0 {
+		t.Fatalf("page past the end = %+v, want an empty list", past)
+	}
+}
@@ -30,6 +30,20 @@ const hardcodedInstanceName = "CineSync"
 
 const configFileName = "config.yml"
 
+// ServiceTypes are the identities spoofing can present: Radarr, Sonarr, or
+// auto, which answers as both
+var ServiceTypes = []string{"radarr", "sonarr", "auto"}
+
+// IsValidServiceType reports whether serviceType is one of ServiceTypes
+func IsValidServiceType(serviceType string) bool {
+	for _, known := range ServiceTypes {
+		if serviceType == known {
+			return true
+		}
+	}
+	return false
+}
+
 // DefaultConfig returns the default spoofing configuration
 func DefaultConfig() *SpoofingConfig {
 	return &SpoofingConfig{
@@ -84,7 +98,7 @@ func (c *SpoofingConfig) Validate() error {
 	}
 
 	// Validate service type
-	if c.ServiceType != "radarr" && c.ServiceType != "sonarr" && c.ServiceType != "auto" {
+	if !IsValidServiceType(c.ServiceType) {
 		return fmt.Errorf("invalid service type: %s (must be 'radarr', 'sonarr', or 'auto')", c.ServiceType)
 	}
 
@@ -220,6 +234,8 @@ func SetConfig(newConfig *SpoofingConfig) error {
 	defer configMux.Unlock()
 
 	config = newConfig
+	// Folder mappings decide which titles each list contains
+	InvalidateLibraryCache()
 
 	if err := saveConfigToFile(config); err != nil {
 		return fmt.Errorf("failed to save config: %v", err)
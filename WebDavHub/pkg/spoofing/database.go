@@ -4,7 +4,6 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -13,18 +12,27 @@ import (
 	"time"
 
 	"cinesync/pkg/db"
+	"cinesync/pkg/httpclient"
 )
 
 var (
 	tmdbCache   = make(map[int]*TMDBMovieDetails)
 	tmdbTVCache = make(map[int]*TMDBTVDetails)
 	tmdbMutex   sync.RWMutex
-	tmdbClient  = &http.Client{Timeout: 10 * time.Second}
+	tmdbClient  = httpclient.New(10 * time.Second)
 )
 
 // getMoviesFromDatabase retrieves movies from the CineSync database and formats them for Radarr
 func getMoviesFromDatabase() ([]MovieResource, error) {
+	movies, _, err := getMovieWindowFromDatabase(allTitles)
+	return movies, err
+}
+
+// getMovieWindowFromDatabase retrieves one window of the movies and the number
+// of movies matching it in all
+func getMovieWindowFromDatabase(window listWindow) ([]MovieResource, int, error) {
 	var movies []MovieResource
+	var total int
 
 	err := executeWithRetry(func() error {
 		mediaHubDB, err := db.GetDatabaseConnection()
@@ -32,14 +40,14 @@ func getMoviesFromDatabase() ([]MovieResource, error) {
 			return err
 		}
 
-		movies, err = getMoviesFromDatabaseInternal(mediaHubDB)
+		movies, total, err = getMoviesFromDatabaseInternal(mediaHubDB, window)
 		return err
 	})
 
-	return movies, err
+	return movies, total, err
 }
 
-func getMoviesFromDatabaseInternal(mediaHubDB *sql.DB) ([]MovieResource, error) {
+func getMoviesFromDatabaseInternal(mediaHubDB *sql.DB, window listWindow) ([]MovieResource, int, error) {
 
 	query := `
 		SELECT
@@ -57,29 +65,21 @@ func getMoviesFromDatabaseInternal(mediaHubDB *sql.DB) ([]MovieResource, error)
 		AND destination_path != ''
 		AND proper_name IS NOT NULL
 		AND proper_name != ''
-		GROUP BY proper_name, year, tmdb_id
-		ORDER BY proper_name, year`
+		AND CAST(tmdb_id AS INTEGER) > 0`
 
-	rows, err := mediaHubDB.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var movies []MovieResource
-
-	for rows.Next() {
+	movies := []MovieResource{}
+	total, err := queryListWindow(mediaHubDB, query, "GROUP BY proper_name, year, tmdb_id", nil, window, func(rows *sql.Rows) {
 		var properName, tmdbIDStr, destinationPath, latestProcessedAt, language, quality string
 		var year int
 		var fileSize int64
 
 		if err := rows.Scan(&properName, &year, &tmdbIDStr, &destinationPath, &latestProcessedAt, &fileSize, &language, &quality); err != nil {
-			continue
+			return
 		}
 
 		tmdbID, _ := strconv.Atoi(tmdbIDStr)
 		if tmdbID == 0 {
-			continue
+			return
 		}
 
 		addedTime, _ := time.Parse(time.RFC3339, latestProcessedAt)
@@ -89,9 +89,9 @@ func getMoviesFromDatabaseInternal(mediaHubDB *sql.DB) ([]MovieResource, error)
 
 		movie := createMovieResourceInternal(tmdbID, properName, year, tmdbID, destinationPath, addedTime, fileSize, language, quality)
 		movies = append(movies, movie)
-	}
+	})
 
-	return movies, rows.Err()
+	return movies, total, err
 }
 
 // createMovieResource creates a properly formatted MovieResource with actual file size
@@ -301,7 +301,7 @@ func createMovieResourceInternal(id int, title string, year, tmdbID int, filePat
 		MovieFileId:         id,
 		Path:                filepath.Dir(filePath),
 		QualityProfileId:    1,
-		Monitored:           true,
+		Monitored:           db.IsTitleMonitored(tmdbID, db.MonitoredMediaMovie),
 		MinimumAvailability: "released",
 		IsAvailable:         true,
 		Runtime:             runtime,
@@ -350,7 +350,7 @@ func createSeriesResource(id int, title string, year, tmdbID int, filePath strin
 		QualityProfileId:  1,
 		LanguageProfileId: 1,
 		SeasonFolder:      true,
-		Monitored:         true,
+		Monitored:         db.IsTitleMonitored(tmdbID, db.MonitoredMediaTV),
 		Runtime:           runtime,
 		TvdbId:            tmdbID,
 		TvRageId:          0,
@@ -373,7 +373,15 @@ func createSeriesResource(id int, title string, year, tmdbID int, filePath strin
 }
 
 func getSeriesFromDatabase() ([]SeriesResource, error) {
+	series, _, err := getSeriesWindowFromDatabase(allTitles)
+	return series, err
+}
+
+// getSeriesWindowFromDatabase retrieves one window of the series and the number
+// of series matching it in all
+func getSeriesWindowFromDatabase(window listWindow) ([]SeriesResource, int, error) {
 	var series []SeriesResource
+	var total int
 
 	err := executeWithRetry(func() error {
 		mediaHubDB, err := db.GetDatabaseConnection()
@@ -381,14 +389,14 @@ func getSeriesFromDatabase() ([]SeriesResource, error) {
 			return err
 		}
 
-		series, err = getSeriesFromDatabaseInternal(mediaHubDB)
+		series, total, err = getSeriesFromDatabaseInternal(mediaHubDB, window)
 		return err
 	})
 
-	return series, err
+	return series, total, err
 }
 
-func getSeriesFromDatabaseInternal(mediaHubDB *sql.DB) ([]SeriesResource, error) {
+func getSeriesFromDatabaseInternal(mediaHubDB *sql.DB, window listWindow) ([]SeriesResource, int, error) {
 
 
 
@@ -408,29 +416,21 @@ func getSeriesFromDatabaseInternal(mediaHubDB *sql.DB) ([]SeriesResource, error)
 		AND destination_path != ''
 		AND proper_name IS NOT NULL
 		AND proper_name != ''
-		GROUP BY proper_name, year, tmdb_id
-		ORDER BY proper_name, year`
+		AND CAST(tmdb_id AS INTEGER) > 0`
 
-	rows, err := mediaHubDB.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var series []SeriesResource
-
-	for rows.Next() {
+	series := []SeriesResource{}
+	total, err := queryListWindow(mediaHubDB, query, "GROUP BY proper_name, year, tmdb_id", nil, window, func(rows *sql.Rows) {
 		var properName, tmdbIDStr, destinationPath, latestProcessedAt, language, quality string
 		var year int
 		var totalFileSize int64
 
 		if err := rows.Scan(&properName, &year, &tmdbIDStr, &destinationPath, &latestProcessedAt, &totalFileSize, &language, &quality); err != nil {
-			continue
+			return
 		}
 
 		tmdbID, _ := strconv.Atoi(tmdbIDStr)
 		if tmdbID == 0 {
-			continue
+			return
 		}
 
 		addedTime, _ := time.Parse(time.RFC3339, latestProcessedAt)
@@ -445,9 +445,9 @@ func getSeriesFromDatabaseInternal(mediaHubDB *sql.DB) ([]SeriesResource, error)
 		uniqueSeriesID := generateUniqueSeriesID(tmdbID, properName, year)
 		show := createSeriesResource(uniqueSeriesID, properName, year, tmdbID, seriesPath, addedTime, language, quality)
 		series = append(series, show)
-	}
+	})
 
-	return series, rows.Err()
+	return series, total, err
 }
 
 // getRootFoldersFromDatabase retrieves unique root folders from the database
@@ -580,9 +580,16 @@ func getAvailableFoldersFromDatabase() ([]AvailableFolder, error) {
 
 // getMoviesFromDatabaseByFolder retrieves movies filtered by base folder path
 func getMoviesFromDatabaseByFolder(folderPath string) ([]MovieResource, error) {
+	movies, _, err := getMovieWindowFromDatabaseByFolder(folderPath, allTitles)
+	return movies, err
+}
+
+// getMovieWindowFromDatabaseByFolder retrieves one window of the movies in a
+// base folder and the number of them matching it in all
+func getMovieWindowFromDatabaseByFolder(folderPath string, window listWindow) ([]MovieResource, int, error) {
 	mediaHubDB, err := db.GetDatabaseConnection()
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	query := `
@@ -602,24 +609,16 @@ func getMoviesFromDatabaseByFolder(folderPath string) ([]MovieResource, error) {
 		AND proper_name IS NOT NULL
 		AND proper_name != ''
 		AND base_path = ?
-		GROUP BY proper_name, year, tmdb_id
-		ORDER BY proper_name, year`
+		AND CAST(tmdb_id AS INTEGER) > 0`
 
-	rows, err := mediaHubDB.Query(query, folderPath)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var movies []MovieResource
-
-	for rows.Next() {
+	movies := []MovieResource{}
+	total, err := queryListWindow(mediaHubDB, query, "GROUP BY proper_name, year, tmdb_id", []interface{}{folderPath}, window, func(rows *sql.Rows) {
 		var properName, tmdbIDStr, destinationPath, latestProcessedAt, language, quality string
 		var year int
 		var fileSize int64
 
 		if err := rows.Scan(&properName, &year, &tmdbIDStr, &destinationPath, &latestProcessedAt, &fileSize, &language, &quality); err != nil {
-			continue
+			return
 		}
 
 		// Convert TMDB ID to integer
@@ -632,7 +631,7 @@ func getMoviesFromDatabaseByFolder(folderPath string) ([]MovieResource, error) {
 
 		// Skip movies without valid TMDB ID
 		if tmdbID == 0 {
-			continue
+			return
 		}
 
 		// Parse processed time
@@ -643,16 +642,23 @@ func getMoviesFromDatabaseByFolder(folderPath string) ([]MovieResource, error) {
 
 		movie := createMovieResourceInternal(tmdbID, properName, year, tmdbID, destinationPath, processedTime, fileSize, language, quality)
 		movies = append(movies, movie)
-	}
+	})
 
-	return movies, nil
+	return movies, total, err
 }
 
 // getSeriesFromDatabaseByFolder retrieves TV series filtered by base folder path
 func getSeriesFromDatabaseByFolder(folderPath string) ([]SeriesResource, error) {
+	series, _, err := getSeriesWindowFromDatabaseByFolder(folderPath, allTitles)
+	return series, err
+}
+
+// getSeriesWindowFromDatabaseByFolder retrieves one window of the series in a
+// base folder and the number of them matching it in all
+func getSeriesWindowFromDatabaseByFolder(folderPath string, window listWindow) ([]SeriesResource, int, error) {
 	mediaHubDB, err := db.GetDatabaseConnection()
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	query := `
@@ -670,25 +676,16 @@ func getSeriesFromDatabaseByFolder(folderPath string) ([]SeriesResource, error)
 		AND destination_path != ''
 		AND proper_name IS NOT NULL
 		AND proper_name != ''
-		AND base_path = ?
-		GROUP BY proper_name, year, tmdb_id
-		ORDER BY proper_name, year`
+		AND base_path = ?`
 
-	rows, err := mediaHubDB.Query(query, folderPath)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var series []SeriesResource
-
-	for rows.Next() {
+	series := []SeriesResource{}
+	total, err := queryListWindow(mediaHubDB, query, "GROUP BY proper_name, year, tmdb_id", []interface{}{folderPath}, window, func(rows *sql.Rows) {
 		var properName, tmdbIDStr, destinationPath, basePath, latestProcessedAt string
 		var year int
 		var totalFileSize int64
 
 		if err := rows.Scan(&properName, &year, &tmdbIDStr, &destinationPath, &basePath, &latestProcessedAt, &totalFileSize); err != nil {
-			continue
+			return
 		}
 
 		// Convert TMDB ID to integer
@@ -711,9 +708,9 @@ func getSeriesFromDatabaseByFolder(folderPath string) ([]SeriesResource, error)
 		uniqueSeriesID := generateUniqueSeriesID(tmdbID, properName, year)
 		show := createSeriesResource(uniqueSeriesID, properName, year, tmdbID, seriesPath, processedTime, "", "")
 		series = append(series, show)
-	}
+	})
 
-	return series, nil
+	return series, total, err
 }
 
 // getEpisodesFromDatabase retrieves episodes for a specific series from the database
@@ -748,7 +745,9 @@ func getEpisodesFromDatabaseInternal(mediaHubDB *sql.DB, seriesId string) ([]int
 			MAX(processed_at) as latest_processed_at,
 			SUM(COALESCE(file_size, 0)) as total_file_size,
 			COALESCE(language, '') as language,
-			COALESCE(quality, '') as quality
+			COALESCE(quality, '') as quality,
+			` + optionalTextColumn(mediaHubDB, "episode_numbers") + ` as episode_numbers,
+			` + optionalTextColumn(mediaHubDB, "absolute_episode") + ` as absolute_episode
 		FROM processed_files
 		WHERE UPPER(media_type) = 'TV'
 		AND destination_path IS NOT NULL
@@ -770,25 +769,30 @@ func getEpisodesFromDatabaseInternal(mediaHubDB *sql.DB, seriesId string) ([]int
 	var episodes []interface{}
 
 	for rows.Next() {
-		var properName, seasonNumber, episodeNumber, destinationPath, processedAt, language, quality string
+		var properName, seasonNumber, episodeNumber, destinationPath, processedAt, language, quality, episodeNumbers, absoluteEpisode string
 		var fileSize int64
 
-		if err := rows.Scan(&properName, &seasonNumber, &episodeNumber, &destinationPath, &processedAt, &fileSize, &language, &quality); err != nil {
+		if err := rows.Scan(&properName, &seasonNumber, &episodeNumber, &destinationPath, &processedAt, &fileSize, &language, &quality, &episodeNumbers, &absoluteEpisode); err != nil {
 			continue
 		}
 
 		seasonNum := safeAtoi(seasonNumber, 1)
 		episodeNum := safeAtoi(episodeNumber, 1)
 		seriesIDInt := safeAtoi(seriesId, 1)
-		episodeID := generateUniqueEpisodeID(seriesIDInt, seasonNum, episodeNum)
+		episodeFileID := generateUniqueEpisodeFileID(seriesIDInt, seasonNum, episodeNum)
 
 		processedTime := parseProcessedTime(processedAt)
 		if processedTime.IsZero() {
 			processedTime = time.Now().Add(-24 * time.Hour)
 		}
 
-		episode := createEpisodeResource(episodeID, seriesId, seasonNum, episodeNum, properName, destinationPath, processedTime, fileSize, language, quality)
-		episodes = append(episodes, episode)
+		// A multi-episode file yields one episode per covered number, all sharing the file
+		for offset, covered := range coveredEpisodes(episodeNum, episodeNumbers) {
+			episodeID := generateUniqueEpisodeID(seriesIDInt, seasonNum, covered)
+			absoluteNum := absoluteEpisodeNumber(covered, absoluteEpisode, offset)
+			episode := createEpisodeResource(episodeID, episodeFileID, seriesId, seasonNum, covered, absoluteNum, properName, destinationPath, processedTime, fileSize, language, quality)
+			episodes = append(episodes, episode)
+		}
 	}
 
 	return episodes, nil
@@ -813,7 +817,9 @@ func getEpisodesFromDatabaseByFolder(folderPath, seriesId string) ([]interface{}
 			COALESCE(episode_number, '') as episode_number,
 			COALESCE(destination_path, '') as destination_path,
 			MAX(processed_at) as latest_processed_at,
-			SUM(COALESCE(file_size, 0)) as total_file_size
+			SUM(COALESCE(file_size, 0)) as total_file_size,
+			` + optionalTextColumn(mediaHubDB, "episode_numbers") + ` as episode_numbers,
+			` + optionalTextColumn(mediaHubDB, "absolute_episode") + ` as absolute_episode
 		FROM processed_files
 		WHERE UPPER(media_type) = 'TV'
 		AND destination_path IS NOT NULL
@@ -836,32 +842,36 @@ func getEpisodesFromDatabaseByFolder(folderPath, seriesId string) ([]interface{}
 	var episodes []interface{}
 
 	for rows.Next() {
-		var properName, seasonNumber, episodeNumber, destinationPath, processedAt string
+		var properName, seasonNumber, episodeNumber, destinationPath, processedAt, episodeNumbers, absoluteEpisode string
 		var fileSize int64
 
-		if err := rows.Scan(&properName, &seasonNumber, &episodeNumber, &destinationPath, &processedAt, &fileSize); err != nil {
+		if err := rows.Scan(&properName, &seasonNumber, &episodeNumber, &destinationPath, &processedAt, &fileSize, &episodeNumbers, &absoluteEpisode); err != nil {
 			continue
 		}
 
 		seasonNum := safeAtoi(seasonNumber, 1)
 		episodeNum := safeAtoi(episodeNumber, 1)
 		seriesIDInt := safeAtoi(seriesId, 1)
-		episodeID := generateUniqueEpisodeID(seriesIDInt, seasonNum, episodeNum)
+		episodeFileID := generateUniqueEpisodeFileID(seriesIDInt, seasonNum, episodeNum)
 
 		processedTime := parseProcessedTime(processedAt)
 		if processedTime.IsZero() {
 			processedTime = time.Now().Add(-24 * time.Hour)
 		}
 
-		episode := createEpisodeResource(episodeID, seriesId, seasonNum, episodeNum, properName, destinationPath, processedTime, fileSize, "", "")
-		episodes = append(episodes, episode)
+		for offset, covered := range coveredEpisodes(episodeNum, episodeNumbers) {
+			episodeID := generateUniqueEpisodeID(seriesIDInt, seasonNum, covered)
+			absoluteNum := absoluteEpisodeNumber(covered, absoluteEpisode, offset)
+			episode := createEpisodeResource(episodeID, episodeFileID, seriesId, seasonNum, covered, absoluteNum, properName, destinationPath, processedTime, fileSize, "", "")
+			episodes = append(episodes, episode)
+		}
 	}
 
 	return episodes, nil
 }
 
 // createEpisodeResource creates a properly formatted episode resource
-func createEpisodeResource(id int, seriesId string, seasonNumber, episodeNumber int, seriesTitle, filePath string, airDate time.Time, fileSize int64, language, quality string) interface{} {
+func createEpisodeResource(id, episodeFileID int, seriesId string, seasonNumber, episodeNumber, absoluteNumber int, seriesTitle, filePath string, airDate time.Time, fileSize int64, language, quality string) interface{} {
 	episodeTitle := extractEpisodeTitle(filePath, seasonNumber, episodeNumber)
 	qualityObj := detectQualityFromDatabase(quality, filePath)
 	languages := getLanguagesFromDatabase(language)
@@ -870,7 +880,7 @@ func createEpisodeResource(id int, seriesId string, seasonNumber, episodeNumber
 		"id":                       id,
 		"seriesId":                 seriesId,
 		"tvdbId":                   0,
-		"episodeFileId":            id,
+		"episodeFileId":            episodeFileID,
 		"seasonNumber":             seasonNumber,
 		"episodeNumber":            episodeNumber,
 		"title":                    episodeTitle,
@@ -879,14 +889,14 @@ func createEpisodeResource(id int, seriesId string, seasonNumber, episodeNumber
 		"overview":                 "",
 		"hasFile":                  true,
 		"monitored":                true,
-		"absoluteEpisodeNumber":    episodeNumber,
+		"absoluteEpisodeNumber":    absoluteNumber,
 		"unverifiedSceneNumbering": false,
 		"series": map[string]interface{}{
 			"id":    seriesId,
 			"title": seriesTitle,
 		},
 		"episodeFile": map[string]interface{}{
-			"id":           id,
+			"id":           episodeFileID,
 			"seriesId":     seriesId,
 			"seasonNumber": seasonNumber,
 			"relativePath": filepath.Base(filePath),
@@ -0,0 +1,135 @@
+package spoofing
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// maxListETags bounds the remembered list ETags. Each distinct list, API key
+// scope and set of list parameters takes one entry.
+const maxListETags = 1024
+
+// libraryVersion is bumped whenever the processed files change so cached list
+// ETags can no longer short-circuit a poll
+var (
+	libraryVersion atomic.Uint64
+	listETagsMutex sync.Mutex
+	listETags      = make(map[string]listETag) // request key -> listETag
+)
+
+type listETag struct {
+	version uint64
+	etag    string
+}
+
+// InvalidateLibraryCache marks all cached library list ETags as stale
+func InvalidateLibraryCache() {
+	libraryVersion.Add(1)
+	listETagsMutex.Lock()
+	clear(listETags)
+	listETagsMutex.Unlock()
+}
+
+// listScope names the part of the library a request sees: the folder mapping
+// of its API key in folder mode, everything otherwise
+func listScope(r *http.Request) string {
+	config := GetConfig()
+	if !config.FolderMode {
+		return "*"
+	}
+	mapping := getFolderMappingFromRequest(r, config.FolderMappings)
+	if mapping == nil {
+		return ""
+	}
+	return mapping.ServiceType + ":" + mapping.FolderPath
+}
+
+// listCacheKey identifies a list response by path, API key scope and the
+// parsed list parameters, so unknown or reordered query parameters share an entry
+func listCacheKey(r *http.Request, q listQuery) string {
+	monitored := ""
+	if q.Monitored != nil {
+		monitored = fmt.Sprint(*q.Monitored)
+	}
+	tags := append([]int(nil), q.Tags...)
+	sort.Ints(tags)
+	return fmt.Sprintf("%s|%s|%t|%d|%d|%s|%s|%s|%v",
+		r.URL.Path, listScope(r), q.Paged, q.Page, q.PageSize, strings.ToLower(q.SortKey), q.SortDirection, monitored, tags)
+}
+
+// storeListETag remembers the ETag of a list response, evicting an arbitrary
+// entry once maxListETags are held
+func storeListETag(key string, entry listETag) {
+	listETagsMutex.Lock()
+	defer listETagsMutex.Unlock()
+	if _, ok := listETags[key]; !ok && len(listETags) >= maxListETags {
+		for evict := range listETags {
+			delete(listETags, evict)
+			break
+		}
+	}
+	listETags[key] = entry
+}
+
+// ifNoneMatch reports whether the request's If-None-Match header matches the ETag
+func ifNoneMatch(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCachedListETag answers 304 from the cached ETag when the library has not
+// changed since the client's last poll, avoiding the database query entirely
+func checkCachedListETag(w http.ResponseWriter, r *http.Request, q listQuery) bool {
+	listETagsMutex.Lock()
+	entry, ok := listETags[listCacheKey(r, q)]
+	listETagsMutex.Unlock()
+	if !ok {
+		return false
+	}
+	if entry.version != libraryVersion.Load() || !ifNoneMatch(r, entry.etag) {
+		return false
+	}
+	w.Header().Set("ETag", entry.etag)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// computeListETag hashes the serialized list and remembers it for the request
+func computeListETag[T any](r *http.Request, q listQuery, version uint64, items []T, total int) string {
+	hash := sha256.New()
+	encoder := json.NewEncoder(hash)
+	encoder.Encode(total)
+	for _, item := range items {
+		encoder.Encode(item)
+	}
+	etag := `"` + hex.EncodeToString(hash.Sum(nil))[:20] + `"`
+	storeListETag(listCacheKey(r, q), listETag{version: version, etag: etag})
+	return etag
+}
+
+// writeConditionalList sets the ETag for a list and writes either 304 or the list body
+func writeConditionalList[T any](w http.ResponseWriter, r *http.Request, version uint64, items []T, q listQuery, total int) {
+	etag := computeListETag(r, q, version, items, total)
+	w.Header().Set("ETag", etag)
+	if ifNoneMatch(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeList(w, items, q, total)
+}
@@ -12,6 +12,8 @@ import (
 	"sync"
 	"time"
 
+	"cinesync/pkg/artwork"
+	"cinesync/pkg/db"
 	"cinesync/pkg/logger"
 	"github.com/gorilla/websocket"
 )
@@ -242,79 +244,206 @@ func HandleSystemStatusV1(w http.ResponseWriter, r *http.Request) {
 
 // HandleSpoofedMovies handles the /api/v3/movie endpoint for Radarr
 func HandleSpoofedMovies(w http.ResponseWriter, r *http.Request) {
-	config := GetConfig()
-
 	// Check if this is a request for a specific movie by ID
 	path := strings.TrimPrefix(r.URL.Path, "/api/v3/movie")
 	if path != "" && path != "/" {
 		// Extract movie ID from path
 		movieIDStr := strings.Trim(path, "/")
 		if movieID, err := strconv.Atoi(movieIDStr); err == nil {
+			if r.Method == http.MethodPut {
+				handleUpdateSpoofedMovie(w, r, movieID)
+				return
+			}
 			HandleSpoofedMovieByID(w, r, movieID)
 			return
 		}
 	}
 
-	var movies []MovieResource
-	var err error
-
-	// Check if folder mode is enabled and get folder from request
-	if config.FolderMode {
-		folderMapping := getFolderMappingFromRequest(r, config.FolderMappings)
-		if folderMapping != nil {
-			if folderMapping.ServiceType == "radarr" || folderMapping.ServiceType == "auto" || folderMapping.ServiceType == "" {
-				movies, err = getMoviesFromDatabaseByFolder(folderMapping.FolderPath)
-			} else {
-				movies = []MovieResource{}
-			}
-		} else {
-			movies = []MovieResource{}
-		}
-	} else {
-		movies, err = getMoviesFromDatabase()
+	query := parseListQuery(r)
+	if checkCachedListETag(w, r, query) {
+		return
 	}
+	version := libraryVersion.Load()
 
+	page, total, err := loadSpoofedMovieWindow(r, query.sqlWindow(db.MonitoredMediaMovie, "file_size"))
 	if err != nil {
 		logger.Error("Failed to get movies: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(movies)
+	writeConditionalList(w, r, version, page, query, total)
+}
+
+// loadSpoofedMovies returns the movies visible to the request, honouring folder mode
+func loadSpoofedMovies(r *http.Request) ([]MovieResource, error) {
+	movies, _, err := loadSpoofedMovieWindow(r, allTitles)
+	return movies, err
+}
+
+// loadSpoofedMovieWindow returns one window of the movies visible to the
+// request and how many of them match it in all
+func loadSpoofedMovieWindow(r *http.Request, window listWindow) ([]MovieResource, int, error) {
+	config := GetConfig()
+	if !config.FolderMode {
+		return getMovieWindowFromDatabase(window)
+	}
+
+	folderMapping := getFolderMappingFromRequest(r, config.FolderMappings)
+	if folderMapping == nil {
+		return []MovieResource{}, 0, nil
+	}
+	if folderMapping.ServiceType == "radarr" || folderMapping.ServiceType == "auto" || folderMapping.ServiceType == "" {
+		return getMovieWindowFromDatabaseByFolder(folderMapping.FolderPath, window)
+	}
+	return []MovieResource{}, 0, nil
 }
 
 // HandleSpoofedSeries handles the /api/v3/series endpoint for Sonarr
 func HandleSpoofedSeries(w http.ResponseWriter, r *http.Request) {
+	// Updates address a specific series by ID
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v3/series"), "/")
+	if r.Method == http.MethodPut {
+		seriesID, err := strconv.Atoi(path)
+		if err != nil {
+			http.Error(w, "Series id is required", http.StatusBadRequest)
+			return
+		}
+		handleUpdateSpoofedSeries(w, r, seriesID)
+		return
+	}
+
+	query := parseListQuery(r)
+	if checkCachedListETag(w, r, query) {
+		return
+	}
+	version := libraryVersion.Load()
+
+	// Series resources report no size on disk, so sizeOnDisk keeps title order
+	page, total, err := loadSpoofedSeriesWindow(r, query.sqlWindow(db.MonitoredMediaTV, ""))
+	if err != nil {
+		logger.Error("Failed to get series: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	writeConditionalList(w, r, version, page, query, total)
+}
+
+// loadSpoofedSeries returns the series visible to the request, honouring folder mode
+func loadSpoofedSeries(r *http.Request) ([]SeriesResource, error) {
+	series, _, err := loadSpoofedSeriesWindow(r, allTitles)
+	return series, err
+}
+
+// loadSpoofedSeriesWindow returns one window of the series visible to the
+// request and how many of them match it in all
+func loadSpoofedSeriesWindow(r *http.Request, window listWindow) ([]SeriesResource, int, error) {
 	config := GetConfig()
+	if !config.FolderMode {
+		return getSeriesWindowFromDatabase(window)
+	}
 
-	var series []SeriesResource
-	var err error
+	folderMapping := getFolderMappingFromRequest(r, config.FolderMappings)
+	if folderMapping == nil {
+		return []SeriesResource{}, 0, nil
+	}
+	if folderMapping.ServiceType == "sonarr" || folderMapping.ServiceType == "auto" || folderMapping.ServiceType == "" {
+		return getSeriesWindowFromDatabaseByFolder(folderMapping.FolderPath, window)
+	}
+	return []SeriesResource{}, 0, nil
+}
 
-	// Check if folder mode is enabled and get folder from request
-	if config.FolderMode {
-		folderMapping := getFolderMappingFromRequest(r, config.FolderMappings)
-		if folderMapping != nil {
-			if folderMapping.ServiceType == "sonarr" || folderMapping.ServiceType == "auto" || folderMapping.ServiceType == "" {
-				series, err = getSeriesFromDatabaseByFolder(folderMapping.FolderPath)
-			} else {
-				series = []SeriesResource{}
-			}
-		} else {
-			series = []SeriesResource{}
+// monitoredUpdate is the part of a PUT movie/series body CineSync persists
+type monitoredUpdate struct {
+	Monitored *bool `json:"monitored"`
+}
+
+// decodeMonitoredUpdate reads the monitored flag from a PUT body
+func decodeMonitoredUpdate(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	var update monitoredUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		handleErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return false, false
+	}
+	if update.Monitored == nil {
+		handleErrorResponse(w, "monitored is required", http.StatusBadRequest)
+		return false, false
+	}
+	return *update.Monitored, true
+}
+
+// handleUpdateSpoofedMovie handles PUT /api/v3/movie/{id}. Only the monitored
+// flag is persisted; the rest of the resource is derived from the library.
+func handleUpdateSpoofedMovie(w http.ResponseWriter, r *http.Request, movieID int) {
+	monitored, ok := decodeMonitoredUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	movies, err := loadSpoofedMovies(r)
+	if err != nil {
+		logger.Error("Failed to get movies: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	for _, movie := range movies {
+		if movie.ID != movieID {
+			continue
 		}
-	} else {
-		series, err = getSeriesFromDatabase()
+		if err := db.SetTitleMonitored(movie.TmdbId, db.MonitoredMediaMovie, monitored); err != nil {
+			logger.Error("Failed to update movie %d: %v", movieID, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		InvalidateLibraryCache()
+
+		movie.Monitored = monitored
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(movie)
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+// handleUpdateSpoofedSeries handles PUT /api/v3/series/{id}. Only the monitored
+// flag is persisted; the rest of the resource is derived from the library.
+func handleUpdateSpoofedSeries(w http.ResponseWriter, r *http.Request, seriesID int) {
+	monitored, ok := decodeMonitoredUpdate(w, r)
+	if !ok {
+		return
 	}
 
+	series, err := loadSpoofedSeries(r)
 	if err != nil {
 		logger.Error("Failed to get series: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(series)
+	for _, show := range series {
+		if show.ID != seriesID {
+			continue
+		}
+		// Series resources carry the TMDB id in their tvdbId field
+		if err := db.SetTitleMonitored(show.TvdbId, db.MonitoredMediaTV, monitored); err != nil {
+			logger.Error("Failed to update series %d: %v", seriesID, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		InvalidateLibraryCache()
+
+		show.Monitored = monitored
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(show)
+		return
+	}
+
+	http.NotFound(w, r)
 }
 
 // HandleSpoofedEpisode handles the /api/v3/episode endpoint for Sonarr
@@ -474,21 +603,31 @@ func HandleMediaCover(w http.ResponseWriter, r *http.Request) {
 	}
 
 	tmdbID, imageFile := parts[0], parts[1]
-	var baseImageFile string
 
 	// Handle various image file formats that Bazarr might request
-	if strings.Contains(imageFile, "poster") {
-		baseImageFile = "poster.jpg"
-	} else if strings.Contains(imageFile, "fanart") {
-		baseImageFile = "fanart.jpg"
-	} else {
-		http.NotFound(w, r)
-		return
+	artworkType, width, ok := artwork.ParseFileName(imageFile)
+	if !ok {
+		width = 0
+		if strings.Contains(imageFile, "poster") {
+			artworkType = artwork.TypePoster
+		} else if strings.Contains(imageFile, "fanart") {
+			artworkType = artwork.TypeFanart
+		} else {
+			http.NotFound(w, r)
+			return
+		}
 	}
 
-	filePath := filepath.Join("../db", "MediaCover", tmdbID, baseImageFile)
-
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+	// Serve from the artwork cache, downloading artwork that is not cached yet
+	filePath := artwork.Path(tmdbID, artworkType, width)
+	if cache := artwork.Default(); cache != nil {
+		cached, err := cache.Get(r.Context(), artwork.Title{ID: tmdbID}, artworkType, width)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		filePath = cached
+	} else if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		http.NotFound(w, r)
 		return
 	}
@@ -0,0 +1,240 @@
+package spoofing
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cinesync/pkg/db"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+)
+
+const defaultMaxListSize = 10000
+
+// listQuery holds the paging, sorting and filter parameters accepted by the
+// spoofed library list endpoints. They mirror the Radarr/Sonarr paged API.
+type listQuery struct {
+	Paged         bool
+	Page          int
+	PageSize      int
+	SortKey       string
+	SortDirection string
+	Monitored     *bool
+	Tags          []int
+}
+
+// pagedResponse matches the paging envelope returned by the real *arr APIs.
+// It deliberately differs from paging.PagedResponse since *arr clients parse it.
+type pagedResponse struct {
+	Page          int    `json:"page"`
+	PageSize      int    `json:"pageSize"`
+	SortKey       string `json:"sortKey"`
+	SortDirection string `json:"sortDirection"`
+	TotalRecords  int    `json:"totalRecords"`
+}
+
+// maxListSize caps the number of records returned when a client does not page
+func maxListSize() int {
+	size := env.GetInt("SPOOFING_MAX_LIST_SIZE", defaultMaxListSize)
+	if size <= 0 {
+		return defaultMaxListSize
+	}
+	return size
+}
+
+// parseListQuery reads paging, sorting and filter parameters from the request
+func parseListQuery(r *http.Request) listQuery {
+	values := r.URL.Query()
+	query := listQuery{
+		Page:          1,
+		PageSize:      10,
+		SortKey:       values.Get("sortKey"),
+		SortDirection: strings.ToLower(values.Get("sortDirection")),
+	}
+
+	if page, err := strconv.Atoi(values.Get("page")); err == nil && page > 0 {
+		query.Page = page
+		query.Paged = true
+	}
+	if pageSize, err := strconv.Atoi(values.Get("pageSize")); err == nil && pageSize > 0 {
+		query.PageSize = min(pageSize, maxListSize())
+		query.Paged = true
+	}
+	if query.SortKey == "" {
+		query.SortKey = "sortTitle"
+	}
+	if query.SortDirection != "descending" {
+		query.SortDirection = "ascending"
+	}
+
+	if monitored := values.Get("monitored"); monitored != "" {
+		if b, err := strconv.ParseBool(monitored); err == nil {
+			query.Monitored = &b
+		}
+	}
+	for _, raw := range strings.Split(values.Get("tags"), ",") {
+		if tag, err := strconv.Atoi(strings.TrimSpace(raw)); err == nil {
+			query.Tags = append(query.Tags, tag)
+		}
+	}
+
+	return query
+}
+
+// listWindow is the part of a list query applied in SQL, so only the records
+// of the requested page are read and turned into resources
+type listWindow struct {
+	// filter holds extra WHERE conditions, each starting with AND
+	filter  string
+	args    []interface{}
+	orderBy string
+	limit   int
+	offset  int
+	// empty is set when no record can match, like a tag filter, since
+	// spoofed titles carry no tags
+	empty bool
+}
+
+// allTitles is the window of the loaders that need every title
+var allTitles = listWindow{orderBy: "proper_name, year"}
+
+// sqlWindow translates the query for a list of mediaType titles. sizeColumn is
+// the column sizeOnDisk sorts by, "" when the resources report no size.
+func (q listQuery) sqlWindow(mediaType, sizeColumn string) listWindow {
+	window := listWindow{empty: len(q.Tags) > 0}
+
+	if q.Monitored != nil {
+		if ids := db.UnmonitoredTitleIDs(mediaType); len(ids) > 0 {
+			placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+			operator := "NOT IN"
+			if !*q.Monitored {
+				operator = "IN"
+			}
+			window.filter = " AND CAST(tmdb_id AS INTEGER) " + operator + " (" + placeholders + ")"
+			for _, id := range ids {
+				window.args = append(window.args, id)
+			}
+		} else if !*q.Monitored {
+			window.empty = true
+		}
+	}
+
+	// Series ids are derived from the TMDB id, so it orders them too
+	column := "proper_name COLLATE NOCASE"
+	switch strings.ToLower(q.SortKey) {
+	case "id":
+		column = "CAST(tmdb_id AS INTEGER)"
+	case "year":
+		column = "year"
+	case "added":
+		column = "latest_processed_at"
+	case "sizeondisk":
+		if sizeColumn != "" {
+			column = sizeColumn
+		}
+	}
+	direction := "ASC"
+	if q.SortDirection == "descending" {
+		direction = "DESC"
+	}
+	window.orderBy = column + " " + direction + ", proper_name, year"
+
+	if !q.Paged {
+		window.limit = maxListSize()
+		return window
+	}
+	window.limit = q.PageSize
+	if q.Page-1 > math.MaxInt32/q.PageSize {
+		window.offset = math.MaxInt32
+	} else {
+		window.offset = (q.Page - 1) * q.PageSize
+	}
+	return window
+}
+
+// queryListWindow runs a list query for one window of titles and returns the
+// number of titles matching it in all. selectSQL is a SELECT of the title
+// columns ending in its WHERE clause; the window's filter is appended to it
+// before groupBy, which makes one row per title.
+func queryListWindow(mediaHubDB *sql.DB, selectSQL, groupBy string, args []interface{}, window listWindow, scan func(*sql.Rows)) (int, error) {
+	if window.empty {
+		return 0, nil
+	}
+	grouped := selectSQL + window.filter + " " + groupBy
+	args = append(args[:len(args):len(args)], window.args...)
+
+	var total int
+	if err := mediaHubDB.QueryRow("SELECT COUNT(*) FROM ("+grouped+")", args...).Scan(&total); err != nil {
+		return 0, err
+	}
+
+	query := grouped + " ORDER BY " + window.orderBy
+	if window.limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", window.limit, window.offset)
+	}
+	rows, err := mediaHubDB.Query(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		scan(rows)
+	}
+	return total, rows.Err()
+}
+
+// writeList streams items as a JSON array, wrapped in the paging envelope when
+// the client asked for a page. Items are encoded one at a time so the full
+// response is never held in memory. A bare array cut at SPOOFING_MAX_LIST_SIZE
+// says so in X-Truncated, with the full count in X-Total-Count.
+func writeList[T any](w http.ResponseWriter, items []T, q listQuery, total int) {
+	w.Header().Set("Content-Type", "application/json")
+	if !q.Paged {
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		if total > len(items) {
+			w.Header().Set("X-Truncated", "true")
+			logger.Warn("Spoofed list truncated to %d of %d records, use page/pageSize to fetch the rest", len(items), total)
+		}
+	}
+
+	if q.Paged {
+		header, _ := json.Marshal(pagedResponse{
+			Page:          q.Page,
+			PageSize:      q.PageSize,
+			SortKey:       q.SortKey,
+			SortDirection: q.SortDirection,
+			TotalRecords:  total,
+		})
+		// Re-open the envelope object so the records array can be streamed into it
+		w.Write(header[:len(header)-1])
+		w.Write([]byte(`,"records":`))
+	}
+
+	streamJSONArray(w, items)
+
+	if q.Paged {
+		w.Write([]byte("}"))
+	}
+}
+
+// streamJSONArray writes items as a JSON array one element at a time
+func streamJSONArray[T any](w http.ResponseWriter, items []T) {
+	w.Write([]byte("["))
+	for i, item := range items {
+		if i > 0 {
+			w.Write([]byte(","))
+		}
+		data, err := json.Marshal(item)
+		if err != nil {
+			logger.Error("Failed to encode list item: %v", err)
+			data = []byte("null")
+		}
+		w.Write(data)
+	}
+	w.Write([]byte("]"))
+}
@@ -0,0 +1,55 @@
+package spoofing
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSQLWindowPagesInSQL(t *testing.T) {
+	query := parseListQuery(httptest.NewRequest("GET", "/api/v3/movie?page=3&pageSize=20&sortKey=year&sortDirection=descending", nil))
+	window := query.sqlWindow("movie", "file_size")
+
+	if window.limit != 20 || window.offset != 40 {
+		t.Fatalf("LIMIT %d OFFSET %d, want LIMIT 20 OFFSET 40", window.limit, window.offset)
+	}
+	if window.orderBy != "year DESC, proper_name, year" {
+		t.Fatalf("ORDER BY %q", window.orderBy)
+	}
+}
+
+func TestSQLWindowCapsUnpagedLists(t *testing.T) {
+	t.Setenv("SPOOFING_MAX_LIST_SIZE", "50")
+	window := parseListQuery(httptest.NewRequest("GET", "/api/v3/movie", nil)).sqlWindow("movie", "file_size")
+
+	if window.limit != 50 || window.offset != 0 {
+		t.Fatalf("LIMIT %d OFFSET %d, want LIMIT 50 OFFSET 0", window.limit, window.offset)
+	}
+}
+
+func TestSQLWindowClampsHugePages(t *testing.T) {
+	window := parseListQuery(httptest.NewRequest("GET", "/api/v3/series?page=9223372036854775807&pageSize=100", nil)).sqlWindow("tv", "")
+
+	if window.offset < 0 {
+		t.Fatalf("OFFSET %d overflowed", window.offset)
+	}
+}
+
+func TestSQLWindowTagFilterMatchesNothing(t *testing.T) {
+	window := parseListQuery(httptest.NewRequest("GET", "/api/v3/series?tags=1", nil)).sqlWindow("tv", "")
+
+	if !window.empty {
+		t.Fatal("tag filter should match no spoofed title")
+	}
+	if total, err := queryListWindow(nil, "", "", nil, window, nil); err != nil || total != 0 {
+		t.Fatalf("empty window queried the database: total %d, err %v", total, err)
+	}
+}
+
+func TestWriteListFlagsTruncation(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	writeList(recorder, []int{1, 2}, listQuery{}, 5)
+
+	if recorder.Header().Get("X-Truncated") != "true" || recorder.Header().Get("X-Total-Count") != "5" {
+		t.Fatalf("headers = %v, want the cut list flagged", recorder.Header())
+	}
+}
@@ -6,8 +6,6 @@ import (
 
 // RegisterRoutes registers all spoofing routes with the given mux
 func RegisterRoutes(mux *http.ServeMux) {
-	config := GetConfig()
-
 	// Common endpoints for both services
 	commonEndpoints := map[string]http.HandlerFunc{
 		"/api/v3/system/status":  HandleSystemStatus,
@@ -67,58 +65,31 @@ func RegisterRoutes(mux *http.ServeMux) {
 		"/signalr":                    HandleSignalRMessages,
 	}
 
-	// Service-specific endpoints
+	// Service-specific endpoints are always registered and check the current
+	// configuration on every request, so switching the service type or folder
+	// mode takes effect without a restart
+	radarrEndpoints := map[string]http.HandlerFunc{
+		"/api/v3/movie":      HandleSpoofedMovies,
+		"/api/v3/movie/":     HandleSpoofedMovies,
+		"/api/v3/moviefile":  HandleSpoofedMovieFiles,
+		"/api/v3/moviefile/": HandleSpoofedMovieFiles,
+	}
+	sonarrEndpoints := map[string]http.HandlerFunc{
+		"/api/v3/series":           HandleSpoofedSeries,
+		"/api/v3/series/":          HandleSpoofedSeries,
+		"/api/v3/episode":          HandleSpoofedEpisode,
+		"/api/v3/episode/":         HandleSpoofedEpisode,
+		"/api/v3/episodefile":      HandleSpoofedEpisodeFiles,
+		"/api/v3/episodefile/":     HandleSpoofedEpisodeFiles,
+		"/api/v3/languageprofile":  HandleSpoofedLanguageProfile,
+		"/api/v3/languageprofile/": HandleSpoofedLanguageProfile,
+	}
 	serviceEndpoints := make(map[string]http.HandlerFunc)
-
-	if config.FolderMode {
-		// In folder mode, register both Radarr and Sonarr endpoints
-		// The handlers will determine which data to return based on the folder mapping's service type
-		serviceEndpoints["/api/v3/movie"] = HandleSpoofedMovies
-		serviceEndpoints["/api/v3/movie/"] = HandleSpoofedMovies
-		serviceEndpoints["/api/v3/moviefile"] = HandleSpoofedMovieFiles
-		serviceEndpoints["/api/v3/moviefile/"] = HandleSpoofedMovieFiles
-		serviceEndpoints["/api/v3/series"] = HandleSpoofedSeries
-		serviceEndpoints["/api/v3/series/"] = HandleSpoofedSeries
-		serviceEndpoints["/api/v3/episode"] = HandleSpoofedEpisode
-		serviceEndpoints["/api/v3/episode/"] = HandleSpoofedEpisode
-		serviceEndpoints["/api/v3/episodefile"] = HandleSpoofedEpisodeFiles
-		serviceEndpoints["/api/v3/episodefile/"] = HandleSpoofedEpisodeFiles
-		serviceEndpoints["/api/v3/languageprofile"] = HandleSpoofedLanguageProfile
-		serviceEndpoints["/api/v3/languageprofile/"] = HandleSpoofedLanguageProfile
-	} else {
-		// In global mode, use the configured service type
-		switch config.ServiceType {
-		case "radarr":
-			// Radarr-specific endpoints
-			serviceEndpoints["/api/v3/movie"] = HandleSpoofedMovies
-			serviceEndpoints["/api/v3/movie/"] = HandleSpoofedMovies
-			serviceEndpoints["/api/v3/moviefile"] = HandleSpoofedMovieFiles
-			serviceEndpoints["/api/v3/moviefile/"] = HandleSpoofedMovieFiles
-		case "sonarr":
-			// Sonarr-specific endpoints
-			serviceEndpoints["/api/v3/series"] = HandleSpoofedSeries
-			serviceEndpoints["/api/v3/series/"] = HandleSpoofedSeries
-			serviceEndpoints["/api/v3/episode"] = HandleSpoofedEpisode
-			serviceEndpoints["/api/v3/episode/"] = HandleSpoofedEpisode
-			serviceEndpoints["/api/v3/episodefile"] = HandleSpoofedEpisodeFiles
-			serviceEndpoints["/api/v3/episodefile/"] = HandleSpoofedEpisodeFiles
-			serviceEndpoints["/api/v3/languageprofile"] = HandleSpoofedLanguageProfile
-			serviceEndpoints["/api/v3/languageprofile/"] = HandleSpoofedLanguageProfile
-		case "auto":
-			// Auto mode - register both sets of endpoints
-			serviceEndpoints["/api/v3/movie"] = HandleSpoofedMovies
-			serviceEndpoints["/api/v3/movie/"] = HandleSpoofedMovies
-			serviceEndpoints["/api/v3/moviefile"] = HandleSpoofedMovieFiles
-			serviceEndpoints["/api/v3/moviefile/"] = HandleSpoofedMovieFiles
-			serviceEndpoints["/api/v3/series"] = HandleSpoofedSeries
-			serviceEndpoints["/api/v3/series/"] = HandleSpoofedSeries
-			serviceEndpoints["/api/v3/episode"] = HandleSpoofedEpisode
-			serviceEndpoints["/api/v3/episode/"] = HandleSpoofedEpisode
-			serviceEndpoints["/api/v3/episodefile"] = HandleSpoofedEpisodeFiles
-			serviceEndpoints["/api/v3/episodefile/"] = HandleSpoofedEpisodeFiles
-			serviceEndpoints["/api/v3/languageprofile"] = HandleSpoofedLanguageProfile
-			serviceEndpoints["/api/v3/languageprofile/"] = HandleSpoofedLanguageProfile
-		}
+	for path, handler := range radarrEndpoints {
+		serviceEndpoints[path] = requireServiceType("radarr", handler)
+	}
+	for path, handler := range sonarrEndpoints {
+		serviceEndpoints[path] = requireServiceType("sonarr", handler)
 	}
 
 	// Add folder management endpoints (these don't need auth middleware as they're internal)
@@ -143,4 +114,17 @@ func RegisterRoutes(mux *http.ServeMux) {
 		resilientHandler := PanicRecoveryMiddleware(handler)
 		mux.HandleFunc(path, SignalRAuthMiddleware(resilientHandler))
 	}
-}
\ No newline at end of file
+}
+// requireServiceType serves handler while the current configuration presents
+// serviceType: in folder mode, in auto mode, or when it is the configured
+// type. Otherwise the endpoint does not exist, as on the real service.
+func requireServiceType(serviceType string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		config := GetConfig()
+		if !config.FolderMode && config.ServiceType != "auto" && config.ServiceType != serviceType {
+			http.NotFound(w, r)
+			return
+		}
+		handler(w, r)
+	}
+}
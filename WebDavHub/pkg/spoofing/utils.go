@@ -3,7 +3,6 @@ package spoofing
 import (
 	"database/sql"
 	"fmt"
-	"math/rand"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -12,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"cinesync/pkg/artwork"
 	"cinesync/pkg/db"
 	"cinesync/pkg/logger"
 )
@@ -116,44 +116,11 @@ func getCircuitBreakerStatus() map[string]interface{} {
 	}
 }
 
-// executeWithRetry executes a database operation with retry logic for SQLITE_BUSY errors
+// executeWithRetry executes a database operation through the circuit breaker,
+// retrying it while the database is locked
 func executeWithRetry(operation func() error) error {
 	return dbCircuitBreaker.Execute(func() error {
-		maxRetries := 12
-		baseDelay := 50 * time.Millisecond
-
-		for attempt := 0; attempt < maxRetries; attempt++ {
-			err := operation()
-			if err == nil {
-				return nil
-			}
-
-			// Check if it's a SQLite busy error
-			if strings.Contains(err.Error(), "database is locked") || 
-			   strings.Contains(err.Error(), "SQLITE_BUSY") ||
-			   strings.Contains(err.Error(), "database table is locked") {
-				
-				if attempt < maxRetries-1 {
-					delay := baseDelay * time.Duration(1<<uint(attempt))
-					if delay > 3*time.Second {
-						delay = 3*time.Second
-					}
-
-					jitter := time.Duration(rand.Int63n(int64(delay / 2)))
-					actualDelay := delay + jitter
-					
-					logger.Debug("Database busy (attempt %d/%d), retrying in %v", 
-						attempt+1, maxRetries, actualDelay)
-					
-					time.Sleep(actualDelay)
-					continue
-				}
-				logger.Warn("Database busy error persisted after %d attempts: %v", maxRetries, err)
-			}
-			return err
-		}
-
-		return fmt.Errorf("database operation failed after %d retries", maxRetries)
+		return db.RetryOnLock(operation)
 	})
 }
 
@@ -256,6 +223,64 @@ func generateUniqueEpisodeFileID(seriesID, season, episode int) int {
 	return generateUniqueEpisodeID(seriesID, season, episode)
 }
 
+// coveredEpisodes returns the episodes a file covers. episodeNumbers is the
+// comma separated list MediaHub stores for multi-episode files; it is ignored
+// unless it starts with the file's own episode number.
+func coveredEpisodes(episode int, episodeNumbers string) []int {
+	if episodeNumbers == "" {
+		return []int{episode}
+	}
+
+	var episodes []int
+	for _, part := range strings.Split(episodeNumbers, ",") {
+		number, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || number < 0 {
+			return []int{episode}
+		}
+		episodes = append(episodes, number)
+	}
+	if episodes[0] != episode {
+		return []int{episode}
+	}
+	return episodes
+}
+
+// absoluteEpisodeNumber returns the absolute number of the covered episode at
+// offset within a file. absoluteEpisode is the "125" or "125-126" MediaHub
+// stores for anime libraries using absolute numbering; without it the episode
+// number is used.
+func absoluteEpisodeNumber(episode int, absoluteEpisode string, offset int) int {
+	start, _, _ := strings.Cut(absoluteEpisode, "-")
+	number, err := strconv.Atoi(strings.TrimSpace(start))
+	if err != nil || number < 1 {
+		return episode
+	}
+	return number + offset
+}
+
+// optionalTextColumn selects a processed_files column added by a later MediaHub
+// migration, or an empty string when the database has not been migrated yet
+func optionalTextColumn(mediaHubDB *sql.DB, column string) string {
+	rows, err := mediaHubDB.Query("PRAGMA table_info(processed_files)")
+	if err != nil {
+		return "''"
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+			continue
+		}
+		if name == column {
+			return "COALESCE(" + column + ", '')"
+		}
+	}
+	return "''"
+}
+
 // generateUniqueSeriesID creates a unique series ID based on TMDB ID, title, and year
 // This prevents collisions when the same TMDB ID appears multiple times
 func generateUniqueSeriesID(tmdbID int, title string, year int) int {
@@ -340,8 +365,7 @@ func createImageEntry(coverType, url, remoteUrl string) map[string]interface{} {
 }
 
 func checkLocalMediaExists(tmdbID int, mediaType string) bool {
-	posterPath := filepath.Join("../db", "MediaCover", fmt.Sprintf("%d", tmdbID), "poster.jpg")
-	_, err := os.Stat(posterPath)
+	_, err := os.Stat(artwork.Path(strconv.Itoa(tmdbID), artwork.TypePoster, 0))
 	return err == nil
 }
 
@@ -0,0 +1,214 @@
+package sse
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cinesync/pkg/apierror"
+	"cinesync/pkg/auth"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+)
+
+const (
+	defaultIdleTimeout = 2 * time.Minute
+	maxCheckInterval   = 5 * time.Second
+)
+
+var sessionIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+var (
+	sessionsMu sync.Mutex
+	sessions   = make(map[*Session]struct{})
+)
+
+// Session is one subscriber of an event stream. It ends when the client has
+// not pinged within the idle timeout or the connection has outlived its
+// maximum lifetime, so streams left open by sleeping clients are reclaimed.
+type Session struct {
+	ID     string
+	Stream string
+
+	started  time.Time
+	lastSeen atomic.Int64
+	done     chan struct{}
+	once     sync.Once
+}
+
+// IdleTimeout returns how long a subscriber may go without pinging, from
+// CINESYNC_SSE_IDLE_TIMEOUT. Zero disables the check.
+func IdleTimeout() time.Duration {
+	return durationSetting("CINESYNC_SSE_IDLE_TIMEOUT", defaultIdleTimeout)
+}
+
+// MaxLifetime returns how long a subscription may stay open, from
+// CINESYNC_SSE_MAX_LIFETIME. Zero, the default, disables the check.
+func MaxLifetime() time.Duration {
+	return durationSetting("CINESYNC_SSE_MAX_LIFETIME", 0)
+}
+
+func durationSetting(key string, fallback time.Duration) time.Duration {
+	value := env.GetString(key, "")
+	if value == "" {
+		return fallback
+	}
+	if value == "0" {
+		return 0
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		logger.Warn("Invalid %s %q, using %s", key, value, fallback)
+		return fallback
+	}
+	return duration
+}
+
+// Open registers a subscriber of stream. The client names the session with
+// the session query parameter so it can ping it; otherwise an ID is made up
+// and the client is closed once the idle timeout passes. Callers must Close
+// the session and stop streaming when Done is closed.
+func Open(r *http.Request, stream string) *Session {
+	id := r.URL.Query().Get("session")
+	if !sessionIDPattern.MatchString(id) {
+		id = newSessionID()
+	}
+
+	s := &Session{ID: id, Stream: stream, started: time.Now(), done: make(chan struct{})}
+	s.lastSeen.Store(s.started.UnixNano())
+
+	sessionsMu.Lock()
+	sessions[s] = struct{}{}
+	sessionsMu.Unlock()
+
+	if idle, lifetime := IdleTimeout(), MaxLifetime(); idle > 0 || lifetime > 0 {
+		go s.watch(r, idle, lifetime)
+	}
+	return s
+}
+
+// Done is closed when the subscriber has to be disconnected
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close unregisters the subscriber
+func (s *Session) Close() {
+	s.end()
+	sessionsMu.Lock()
+	delete(sessions, s)
+	sessionsMu.Unlock()
+}
+
+func (s *Session) end() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// touch records activity from the client
+func (s *Session) touch() {
+	s.lastSeen.Store(time.Now().UnixNano())
+}
+
+func (s *Session) watch(r *http.Request, idle, lifetime time.Duration) {
+	ticker := time.NewTicker(checkInterval(idle, lifetime))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			if idle > 0 && now.Sub(time.Unix(0, s.lastSeen.Load())) > idle {
+				logger.Debug("Closing idle %s event subscriber %s", s.Stream, s.ID)
+				s.end()
+				return
+			}
+			if lifetime > 0 && now.Sub(s.started) > lifetime {
+				logger.Debug("Closing %s event subscriber %s after its maximum lifetime", s.Stream, s.ID)
+				s.end()
+				return
+			}
+		}
+	}
+}
+
+// checkInterval polls a few times per window so a timeout is not overshot by
+// much, but never more often than needed for long windows
+func checkInterval(idle, lifetime time.Duration) time.Duration {
+	window := idle
+	if window <= 0 || (lifetime > 0 && lifetime < window) {
+		window = lifetime
+	}
+	return min(max(window/4, 10*time.Millisecond), maxCheckInterval)
+}
+
+func newSessionID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Ping keeps the named sessions alive and returns how many were found
+func Ping(ids []string) int {
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	found := 0
+	for s := range sessions {
+		if wanted[s.ID] {
+			s.touch()
+			found++
+		}
+	}
+	return found
+}
+
+// SubscriberCounts returns the number of open subscribers per stream
+func SubscriberCounts() map[string]int {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	counts := make(map[string]int)
+	for s := range sessions {
+		counts[s.Stream]++
+	}
+	return counts
+}
+
+// PingRequest is the body of POST /api/events/ping
+type PingRequest struct {
+	Sessions []string `json:"sessions"`
+}
+
+// HandlePing serves POST /api/events/ping. Clients call it regularly for the
+// event streams they still listen to; streams that are not pinged are closed
+// after the idle timeout.
+func HandlePing(w http.ResponseWriter, r *http.Request) {
+	if !apierror.RequireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if !auth.RequireStreamAuthenticated(w, r) {
+		return
+	}
+
+	var req PingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{
+		"active": Ping(req.Sessions),
+	})
+}
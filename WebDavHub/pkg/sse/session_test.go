@@ -0,0 +1,70 @@
+package sse
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIdleSessionIsClosedUnlessPinged(t *testing.T) {
+	t.Setenv("CINESYNC_SSE_IDLE_TIMEOUT", "100ms")
+	t.Setenv("CINESYNC_SSE_MAX_LIFETIME", "0")
+
+	pinged := Open(httptest.NewRequest("GET", "/api/events?session=pinged", nil), "test")
+	defer pinged.Close()
+	idle := Open(httptest.NewRequest("GET", "/api/events?session=idle", nil), "test")
+	defer idle.Close()
+
+	stop := time.After(300 * time.Millisecond)
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for waiting := true; waiting; {
+		select {
+		case <-ticker.C:
+			Ping([]string{"pinged"})
+		case <-stop:
+			waiting = false
+		}
+	}
+
+	select {
+	case <-idle.Done():
+	default:
+		t.Error("session that was not pinged is still open")
+	}
+	select {
+	case <-pinged.Done():
+		t.Error("pinged session was closed")
+	default:
+	}
+}
+
+func TestSessionEndsAfterMaxLifetime(t *testing.T) {
+	t.Setenv("CINESYNC_SSE_IDLE_TIMEOUT", "0")
+	t.Setenv("CINESYNC_SSE_MAX_LIFETIME", "50ms")
+
+	s := Open(httptest.NewRequest("GET", "/api/events", nil), "test")
+	defer s.Close()
+	if !sessionIDPattern.MatchString(s.ID) {
+		t.Fatalf("generated session ID %q is not valid", s.ID)
+	}
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatal("session outlived its maximum lifetime")
+	}
+}
+
+func TestSubscriberCountsFollowOpenSessions(t *testing.T) {
+	t.Setenv("CINESYNC_SSE_IDLE_TIMEOUT", "0")
+	t.Setenv("CINESYNC_SSE_MAX_LIFETIME", "0")
+
+	s := Open(httptest.NewRequest("GET", "/api/events", nil), "counted")
+	if SubscriberCounts()["counted"] != 1 {
+		t.Fatal("open session is not counted")
+	}
+	s.Close()
+	if SubscriberCounts()["counted"] != 0 {
+		t.Fatal("closed session is still counted")
+	}
+}
@@ -0,0 +1,134 @@
+// Package strm describes the .strm files MediaHub places in the destination
+// for remote media: small text files holding the URL or path a media server
+// should play, instead of symlinks. Libraries opt in through
+// CINESYNC_LIBRARY_LINK_MODES and contents come from CINESYNC_STRM_PATH_MAP.
+// MediaHub writes them when it links a file; the server reads, moves and
+// recreates them wherever it handles MediaHub's links.
+package strm
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cinesync/pkg/env"
+)
+
+// Link modes a library can use
+const (
+	ModeSymlink = "symlink"
+	ModeStrm    = "strm"
+)
+
+// prefixMap parses prefix=value pairs separated by semicolons, the form
+// LIBRARY_LAYOUT_PROFILES uses for per-library settings
+func prefixMap(key string) map[string]string {
+	mapping := make(map[string]string)
+	for _, entry := range strings.Split(env.GetString(key, ""), ";") {
+		prefix, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		prefix, value = strings.TrimSpace(prefix), strings.TrimSpace(value)
+		if ok && prefix != "" {
+			mapping[filepath.Clean(prefix)] = value
+		}
+	}
+	return mapping
+}
+
+// longestPrefix returns the entry of mapping whose prefix is the longest
+// directory prefix of path
+func longestPrefix(mapping map[string]string, path string) (prefix, value string, ok bool) {
+	path = filepath.Clean(path)
+	for candidate, candidateValue := range mapping {
+		if path != candidate && !strings.HasPrefix(path, strings.TrimSuffix(candidate, string(filepath.Separator))+string(filepath.Separator)) {
+			continue
+		}
+		if !ok || len(candidate) > len(prefix) {
+			prefix, value, ok = candidate, candidateValue, true
+		}
+	}
+	return prefix, value, ok
+}
+
+// LibraryMode returns the link mode of the library holding sourcePath, from
+// CINESYNC_LIBRARY_LINK_MODES ("/mnt/remote=strm;/mnt/local=symlink").
+// Libraries not listed use symlinks.
+func LibraryMode(sourcePath string) string {
+	_, mode, ok := longestPrefix(prefixMap("CINESYNC_LIBRARY_LINK_MODES"), sourcePath)
+	if ok && strings.EqualFold(mode, ModeStrm) {
+		return ModeStrm
+	}
+	return ModeSymlink
+}
+
+// Enabled reports whether any library uses .strm files
+func Enabled() bool {
+	for _, mode := range prefixMap("CINESYNC_LIBRARY_LINK_MODES") {
+		if strings.EqualFold(mode, ModeStrm) {
+			return true
+		}
+	}
+	return false
+}
+
+// Content returns what the .strm file of sourcePath holds. The longest
+// matching prefix of CINESYNC_STRM_PATH_MAP ("/mnt/remote=https://host/media")
+// is replaced, escaping the rest of the path when the replacement is a URL.
+// Without a match the source path itself is used.
+func Content(sourcePath string) string {
+	prefix, replacement, ok := longestPrefix(prefixMap("CINESYNC_STRM_PATH_MAP"), sourcePath)
+	if !ok {
+		return sourcePath
+	}
+	rest := strings.TrimPrefix(filepath.Clean(sourcePath), prefix)
+	if !strings.Contains(replacement, "://") {
+		return filepath.Join(replacement, rest)
+	}
+
+	segments := strings.Split(filepath.ToSlash(rest), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.TrimSuffix(replacement, "/") + strings.Join(segments, "/")
+}
+
+// Extension is the file extension of .strm files
+const Extension = ".strm"
+
+// IsFile reports whether the entry at path, described by info from Lstat, is
+// a .strm file rather than a symlink or real media
+func IsFile(path string, info os.FileInfo) bool {
+	return info.Mode().IsRegular() && strings.EqualFold(filepath.Ext(path), Extension)
+}
+
+// Read returns the URL or path a .strm file holds
+func Read(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Write creates or replaces the .strm file at path with content, the way
+// MediaHub writes them. The file is written next to path and renamed into
+// place, so readers never see it half written.
+func Write(path, content string) error {
+	data := []byte(content + "\n")
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package strm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLibraryModeUsesLongestPrefix(t *testing.T) {
+	t.Setenv("CINESYNC_LIBRARY_LINK_MODES", "/mnt/remote=strm; /mnt/remote/local=symlink")
+	for path, want := range map[string]string{
+		"/mnt/remote/Movie.mkv":       ModeStrm,
+		"/mnt/remote/local/Movie.mkv": ModeSymlink,
+		"/mnt/remote2/Movie.mkv":      ModeSymlink,
+	} {
+		if got := LibraryMode(path); got != want {
+			t.Errorf("LibraryMode(%q) = %q, want %q", path, got, want)
+		}
+	}
+	if !Enabled() {
+		t.Error("Enabled() = false with a strm library")
+	}
+}
+
+func TestContentMapsPrefixes(t *testing.T) {
+	t.Setenv("CINESYNC_STRM_PATH_MAP", "/mnt/remote=https://host/media/;/mnt/nas=/volume1")
+	for path, want := range map[string]string{
+		"/mnt/remote/Movies/A #1.mkv": "https://host/media/Movies/A%20%231.mkv",
+		"/mnt/nas/Movies/A.mkv":       "/volume1/Movies/A.mkv",
+		"/other/A.mkv":                "/other/A.mkv",
+	} {
+		if got := Content(path); got != want {
+			t.Errorf("Content(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestWriteAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Movies", "A.strm")
+	if err := Write(path, "https://host/A.mkv"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Read(path); err != nil || got != "https://host/A.mkv" {
+		t.Fatalf("Read = %q, %v", got, err)
+	}
+	info, err := os.Lstat(path)
+	if err != nil || !IsFile(path, info) {
+		t.Fatalf("written file is not recognized as a .strm file: %v", err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatal("temporary file was left behind")
+	}
+}
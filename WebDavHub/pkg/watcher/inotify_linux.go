@@ -0,0 +1,217 @@
+//go:build linux
+// +build linux
+
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"cinesync/pkg/logger"
+)
+
+const (
+	// inotifyMask selects the events that mean a file appeared or changed
+	inotifyMask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_MODIFY | unix.IN_MOVED_TO |
+		unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+	// pollTimeoutMs bounds how long the reader blocks, so Close is noticed
+	pollTimeoutMs = 500
+)
+
+// inotifyBackend watches directory trees with one inotify watch per directory
+type inotifyBackend struct {
+	fd     int
+	mutex  sync.Mutex
+	dirs   map[int]string
+	events chan string
+	errors chan error
+	done   chan struct{}
+	closed sync.Once
+}
+
+func newInotifyBackend(dirs []string) (backend, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		if errors.Is(err, unix.EMFILE) {
+			return nil, errWatchLimit
+		}
+		return nil, err
+	}
+
+	b := &inotifyBackend{
+		fd:     fd,
+		dirs:   make(map[int]string),
+		events: make(chan string, 1000),
+		errors: make(chan error, 1),
+		done:   make(chan struct{}),
+	}
+
+	for _, dir := range dirs {
+		if err := b.addTree(dir, false); err != nil {
+			unix.Close(fd)
+			return nil, err
+		}
+	}
+
+	go b.readLoop()
+	return b, nil
+}
+
+func (b *inotifyBackend) Events() <-chan string {
+	return b.events
+}
+
+func (b *inotifyBackend) Errors() <-chan error {
+	return b.errors
+}
+
+func (b *inotifyBackend) WatchCount() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.dirs)
+}
+
+func (b *inotifyBackend) Close() error {
+	b.closed.Do(func() {
+		close(b.done)
+	})
+	return nil
+}
+
+// addTree watches dir and every directory below it. When emitFiles is set the
+// files already inside are reported, for directories that were moved in whole.
+func (b *inotifyBackend) addTree(root string, emitFiles bool) error {
+	return filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable entries are skipped, the rest of the tree is still watched
+			logger.Debug("Skipping %s while adding watches: %v", path, err)
+			if entry != nil && entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.IsDir() {
+			if emitFiles && entry.Type().IsRegular() {
+				b.emit(path)
+			}
+			return nil
+		}
+
+		wd, err := unix.InotifyAddWatch(b.fd, path, inotifyMask)
+		if err != nil {
+			if errors.Is(err, unix.ENOSPC) {
+				return errWatchLimit
+			}
+			logger.Debug("Failed to watch %s: %v", path, err)
+			return nil
+		}
+		b.mutex.Lock()
+		b.dirs[wd] = path
+		b.mutex.Unlock()
+		return nil
+	})
+}
+
+func (b *inotifyBackend) emit(path string) {
+	select {
+	case b.events <- path:
+	case <-b.done:
+	}
+}
+
+func (b *inotifyBackend) fail(err error) {
+	select {
+	case b.errors <- err:
+	default:
+	}
+}
+
+// readLoop reads and dispatches events until the backend is closed
+func (b *inotifyBackend) readLoop() {
+	defer unix.Close(b.fd)
+
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	pollFds := []unix.PollFd{{Fd: int32(b.fd), Events: unix.POLLIN}}
+
+	for {
+		select {
+		case <-b.done:
+			return
+		default:
+		}
+
+		n, err := unix.Poll(pollFds, pollTimeoutMs)
+		if err != nil {
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+			b.fail(err)
+			return
+		}
+		if n == 0 {
+			continue
+		}
+
+		n, err = unix.Read(b.fd, buf)
+		if err != nil {
+			if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+				continue
+			}
+			b.fail(err)
+			return
+		}
+
+		if err := b.dispatch(buf[:n]); err != nil {
+			b.fail(err)
+			return
+		}
+	}
+}
+
+// dispatch handles a buffer of raw inotify events
+func (b *inotifyBackend) dispatch(buf []byte) error {
+	for offset := 0; offset+unix.SizeofInotifyEvent <= len(buf); {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		nameStart := offset + unix.SizeofInotifyEvent
+		nameEnd := nameStart + int(event.Len)
+		if nameEnd > len(buf) {
+			return nil
+		}
+		name := string(buf[nameStart:nameEnd])
+		for len(name) > 0 && name[len(name)-1] == 0 {
+			name = name[:len(name)-1]
+		}
+		offset = nameEnd
+
+		if event.Mask&unix.IN_Q_OVERFLOW != 0 {
+			return errors.New("inotify event queue overflowed, events were lost")
+		}
+
+		b.mutex.Lock()
+		dir, known := b.dirs[int(event.Wd)]
+		if event.Mask&unix.IN_IGNORED != 0 {
+			delete(b.dirs, int(event.Wd))
+		}
+		b.mutex.Unlock()
+		if !known || name == "" {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		if event.Mask&unix.IN_ISDIR != 0 {
+			if event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+				if err := b.addTree(path, true); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		b.emit(path)
+	}
+	return nil
+}
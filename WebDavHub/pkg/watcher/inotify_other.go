@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package watcher
+
+func newInotifyBackend(dirs []string) (backend, error) {
+	return nil, errUnsupported
+}
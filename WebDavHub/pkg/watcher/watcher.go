@@ -0,0 +1,379 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"cinesync/pkg/logger"
+)
+
+// Watcher modes reported in the status
+const (
+	ModeDisabled = "disabled"
+	ModeInotify  = "inotify"
+	ModePolling  = "polling"
+)
+
+// errWatchLimit is returned by a backend when the kernel refuses more watches
+var errWatchLimit = errors.New("inotify watch limit reached, raise fs.inotify.max_user_watches")
+
+// errUnsupported is returned by the backend on platforms without inotify
+var errUnsupported = errors.New("file system events are not supported on this platform")
+
+// DefaultPartialPatterns match files that download clients are still writing
+var DefaultPartialPatterns = []string{"*.part", "*.partial", "*.!qB", "*.crdownload", "*.tmp"}
+
+// DefaultMaxBatch is the most files handed to Process at once by default
+const DefaultMaxBatch = 100
+
+// Options configures a Watcher
+type Options struct {
+	// Dirs are the source directories to watch recursively
+	Dirs []string
+	// StabilizeDelay is how long a file's size and modification time must stay
+	// unchanged before it is processed
+	StabilizeDelay time.Duration
+	// FallbackInterval is how often FallbackScan runs when events are unavailable
+	FallbackInterval time.Duration
+	// BatchDelay is how long to wait after a file settled for more files to
+	// settle, so a season pack is processed in one batch
+	BatchDelay time.Duration
+	// MaxBatch caps the files in one batch; DefaultMaxBatch when zero
+	MaxBatch int
+	// Process handles a batch of files that finished being written. Its
+	// context is cancelled when the watcher stops.
+	Process func(ctx context.Context, paths []string) error
+	// FallbackScan rescans the sources when watching is not possible
+	FallbackScan func() error
+	// PartialPatterns are glob patterns of file names that are never processed,
+	// matched case-insensitively; DefaultPartialPatterns when empty
+	PartialPatterns []string
+}
+
+// Status describes the watcher for the health endpoint
+type Status struct {
+	Enabled        bool       `json:"enabled"`
+	Mode           string     `json:"mode"`
+	WatchedDirs    int        `json:"watchedDirs"`
+	PendingFiles   int        `json:"pendingFiles"`
+	ProcessedFiles int        `json:"processedFiles"`
+	FailedFiles    int        `json:"failedFiles"`
+	LastEvent      *time.Time `json:"lastEvent,omitempty"`
+	LastScan       *time.Time `json:"lastScan,omitempty"`
+	FallbackReason string     `json:"fallbackReason,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+}
+
+// backend delivers file system events for a set of directory trees
+type backend interface {
+	// Events delivers paths of files that were created, written or moved in
+	Events() <-chan string
+	// Errors delivers failures that stop the backend from watching reliably
+	Errors() <-chan error
+	// WatchCount returns the number of watched directories
+	WatchCount() int
+	Close() error
+}
+
+// pendingFile is a file waiting for its size to settle
+type pendingFile struct {
+	size       int64
+	modTime    time.Time
+	lastChange time.Time
+}
+
+// Watcher processes new and changed source files as they appear. Files are
+// only handed to Process once they stopped changing for StabilizeDelay, so
+// files still being copied or downloaded are not picked up half written.
+type Watcher struct {
+	opts    Options
+	mutex   sync.Mutex
+	status  Status
+	pending map[string]*pendingFile
+	ready   chan string
+	backend backend
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	// newBackend is swapped out by tests
+	newBackend func(dirs []string) (backend, error)
+}
+
+// New creates a watcher; call Start to begin watching
+func New(opts Options) *Watcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	if len(opts.PartialPatterns) == 0 {
+		opts.PartialPatterns = DefaultPartialPatterns
+	}
+	if opts.MaxBatch <= 0 {
+		opts.MaxBatch = DefaultMaxBatch
+	}
+	return &Watcher{
+		opts:       opts,
+		status:     Status{Enabled: true, Mode: ModeDisabled},
+		pending:    make(map[string]*pendingFile),
+		ready:      make(chan string, 1000),
+		ctx:        ctx,
+		cancel:     cancel,
+		newBackend: newInotifyBackend,
+	}
+}
+
+// Start begins watching. When events cannot be watched the watcher falls
+// back to running FallbackScan every FallbackInterval.
+func (w *Watcher) Start() {
+	w.wg.Add(1)
+	go w.processLoop()
+
+	b, err := w.newBackend(w.opts.Dirs)
+	if err != nil {
+		w.fallBack(err)
+		return
+	}
+
+	w.mutex.Lock()
+	w.backend = b
+	w.status.Mode = ModeInotify
+	w.status.WatchedDirs = b.WatchCount()
+	w.mutex.Unlock()
+	logger.Info("Watching %d source directories for new files", b.WatchCount())
+
+	w.wg.Add(2)
+	go w.eventLoop(b)
+	go w.stabilizeLoop()
+}
+
+// Stop stops watching and waits for the loops to exit. The batch being
+// processed is cancelled through its context.
+func (w *Watcher) Stop() {
+	w.cancel()
+	w.mutex.Lock()
+	b := w.backend
+	w.backend = nil
+	w.mutex.Unlock()
+	if b != nil {
+		b.Close()
+	}
+	w.wg.Wait()
+}
+
+// GetStatus returns the current watcher status
+func (w *Watcher) GetStatus() Status {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	status := w.status
+	status.PendingFiles = len(w.pending)
+	if w.backend != nil {
+		status.WatchedDirs = w.backend.WatchCount()
+	}
+	return status
+}
+
+// fallBack switches to periodic scans after watching failed
+func (w *Watcher) fallBack(reason error) {
+	w.mutex.Lock()
+	if w.status.Mode == ModePolling {
+		w.mutex.Unlock()
+		return
+	}
+	b := w.backend
+	w.backend = nil
+	w.status.Mode = ModePolling
+	w.status.WatchedDirs = 0
+	w.status.FallbackReason = reason.Error()
+	w.mutex.Unlock()
+
+	if b != nil {
+		b.Close()
+	}
+	logger.Warn("Source watcher falling back to scanning every %s: %v", w.opts.FallbackInterval, reason)
+
+	w.wg.Add(1)
+	go w.pollLoop()
+}
+
+// eventLoop collects events from the backend into the pending set
+func (w *Watcher) eventLoop(b backend) {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case path, ok := <-b.Events():
+			if !ok {
+				return
+			}
+			w.addPending(path)
+		case err, ok := <-b.Errors():
+			if !ok {
+				return
+			}
+			w.fallBack(err)
+			return
+		}
+	}
+}
+
+// addPending records a changed file, restarting its stabilization delay
+func (w *Watcher) addPending(path string) {
+	name := strings.ToLower(filepath.Base(path))
+	if strings.HasPrefix(name, ".") {
+		return
+	}
+	for _, pattern := range w.opts.PartialPatterns {
+		if matched, _ := filepath.Match(strings.ToLower(pattern), name); matched {
+			return
+		}
+	}
+
+	now := time.Now()
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.status.LastEvent = &now
+	if file, exists := w.pending[path]; exists {
+		file.lastChange = now
+		return
+	}
+	w.pending[path] = &pendingFile{size: -1, lastChange: now}
+}
+
+// stabilizeLoop moves files whose size and modification time stopped changing
+// to the processing queue
+func (w *Watcher) stabilizeLoop() {
+	defer w.wg.Done()
+
+	interval := w.opts.StabilizeDelay / 4
+	if interval > time.Second {
+		interval = time.Second
+	}
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			w.checkPending()
+		}
+	}
+}
+
+func (w *Watcher) checkPending() {
+	now := time.Now()
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for path, file := range w.pending {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			// Deleted or renamed away before it settled
+			delete(w.pending, path)
+			continue
+		}
+		if info.Size() != file.size || !info.ModTime().Equal(file.modTime) {
+			file.size, file.modTime, file.lastChange = info.Size(), info.ModTime(), now
+			continue
+		}
+		if now.Sub(file.lastChange) < w.opts.StabilizeDelay {
+			continue
+		}
+
+		select {
+		case w.ready <- path:
+			delete(w.pending, path)
+		default:
+			// Queue is full, try again on the next tick
+		}
+	}
+}
+
+// processLoop hands settled files to Process in batches, one batch at a time
+func (w *Watcher) processLoop() {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case path := <-w.ready:
+			batch := w.collectBatch(path)
+			if w.ctx.Err() != nil {
+				return
+			}
+			logger.Info("Processing %d new source files", len(batch))
+			err := w.opts.Process(w.ctx, batch)
+
+			w.mutex.Lock()
+			if err != nil {
+				logger.Error("Failed to process %d source files: %v", len(batch), err)
+				w.status.FailedFiles += len(batch)
+				w.status.LastError = err.Error()
+			} else {
+				w.status.ProcessedFiles += len(batch)
+			}
+			w.mutex.Unlock()
+		}
+	}
+}
+
+// collectBatch returns first with the files that settle within BatchDelay of
+// it, up to MaxBatch files
+func (w *Watcher) collectBatch(first string) []string {
+	batch := []string{first}
+	if w.opts.BatchDelay <= 0 {
+		return batch
+	}
+
+	timer := time.NewTimer(w.opts.BatchDelay)
+	defer timer.Stop()
+	for len(batch) < w.opts.MaxBatch {
+		select {
+		case <-w.ctx.Done():
+			return batch
+		case <-timer.C:
+			return batch
+		case path := <-w.ready:
+			batch = append(batch, path)
+		}
+	}
+	return batch
+}
+
+// pollLoop runs the fallback scan periodically
+func (w *Watcher) pollLoop() {
+	defer w.wg.Done()
+	if w.opts.FallbackInterval <= 0 || w.opts.FallbackScan == nil {
+		return
+	}
+
+	ticker := time.NewTicker(w.opts.FallbackInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			err := w.opts.FallbackScan()
+			now := time.Now()
+			w.mutex.Lock()
+			w.status.LastScan = &now
+			if err != nil {
+				w.status.LastError = err.Error()
+			}
+			w.mutex.Unlock()
+			if err != nil {
+				logger.Warn("Fallback source scan failed: %v", err)
+			}
+		}
+	}
+}
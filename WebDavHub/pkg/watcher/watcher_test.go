@@ -0,0 +1,101 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeBackend delivers the events a test sends it
+type fakeBackend struct {
+	events chan string
+	errors chan error
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{events: make(chan string, 10), errors: make(chan error)}
+}
+
+func (b *fakeBackend) Events() <-chan string { return b.events }
+func (b *fakeBackend) Errors() <-chan error  { return b.errors }
+func (b *fakeBackend) WatchCount() int       { return 1 }
+func (b *fakeBackend) Close() error          { return nil }
+
+func TestFilesSettlingTogetherAreProcessedInOneBatch(t *testing.T) {
+	dir := t.TempDir()
+	fake := newFakeBackend()
+	batches := make(chan []string, 10)
+	w := New(Options{
+		Dirs:           []string{dir},
+		StabilizeDelay: 20 * time.Millisecond,
+		BatchDelay:     200 * time.Millisecond,
+		Process: func(ctx context.Context, paths []string) error {
+			batches <- paths
+			return nil
+		},
+	})
+	w.newBackend = func([]string) (backend, error) { return fake, nil }
+	w.Start()
+	defer w.Stop()
+
+	for _, name := range []string{"S01E01.mkv", "S01E02.mkv", "S01E03.mkv"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		fake.events <- path
+	}
+
+	select {
+	case batch := <-batches:
+		if len(batch) != 3 {
+			t.Fatalf("first batch has %d files, want all 3", len(batch))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no batch was processed")
+	}
+	if status := w.GetStatus(); status.ProcessedFiles != 3 {
+		t.Fatalf("ProcessedFiles = %d, want 3", status.ProcessedFiles)
+	}
+}
+
+func TestStopCancelsTheRunningBatch(t *testing.T) {
+	dir := t.TempDir()
+	fake := newFakeBackend()
+	started := make(chan struct{})
+	w := New(Options{
+		Dirs:           []string{dir},
+		StabilizeDelay: 20 * time.Millisecond,
+		Process: func(ctx context.Context, paths []string) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+	w.newBackend = func([]string) (backend, error) { return fake, nil }
+	w.Start()
+
+	path := filepath.Join(dir, "movie.mkv")
+	if err := os.WriteFile(path, []byte("movie"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fake.events <- path
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch never started")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		w.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not cancel the running batch")
+	}
+}
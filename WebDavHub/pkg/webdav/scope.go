@@ -0,0 +1,46 @@
+package webdav
+
+import (
+	"context"
+	"os"
+	"path"
+
+	"cinesync/pkg/auth"
+	"golang.org/x/net/webdav"
+)
+
+// scopedFileSystem confines each request to the WebDAV root of the user who
+// made it. Names are cleaned as absolute paths before the root is prepended,
+// so ".." can never climb above it.
+type scopedFileSystem struct {
+	fs webdav.FileSystem
+}
+
+// scopedName maps a WebDAV path to the path inside the user's root
+func scopedName(ctx context.Context, name string) string {
+	name = path.Clean("/" + name)
+	if user, ok := auth.WebDAVUserFromContext(ctx); ok && user.Root != "" && user.Root != "/" {
+		return path.Join(user.Root, name)
+	}
+	return name
+}
+
+func (s *scopedFileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return s.fs.Mkdir(ctx, scopedName(ctx, name), perm)
+}
+
+func (s *scopedFileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	return s.fs.OpenFile(ctx, scopedName(ctx, name), flag, perm)
+}
+
+func (s *scopedFileSystem) RemoveAll(ctx context.Context, name string) error {
+	return s.fs.RemoveAll(ctx, scopedName(ctx, name))
+}
+
+func (s *scopedFileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	return s.fs.Rename(ctx, scopedName(ctx, oldName), scopedName(ctx, newName))
+}
+
+func (s *scopedFileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	return s.fs.Stat(ctx, scopedName(ctx, name))
+}
@@ -0,0 +1,115 @@
+package webdav
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cinesync/pkg/auth"
+)
+
+// useScopedUser stores a user confined to root and runs the test in a
+// directory whose user store holds only that user
+func useScopedUser(t *testing.T, username, password, root string) {
+	t.Helper()
+	work := filepath.Join(t.TempDir(), "work")
+	if err := os.MkdirAll(filepath.Join(work, "..", "db"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"users": []auth.User{{Username: username, PasswordHash: hash, Role: auth.RoleUser, WebDAVRoot: root}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("..", "db", "users.json"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.ReloadUsers(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScopedUserStaysInsideTheirRoot(t *testing.T) {
+	t.Setenv("WEBDAV_VIRTUAL_LAYOUT", "false")
+	t.Setenv("WEBDAV_READ_ONLY", "false")
+	t.Setenv("CINESYNC_ACCESS_STATS", "false")
+	t.Setenv("CINESYNC_WEBDAV_AUTH_ENABLED", "true")
+	t.Setenv("CINESYNC_USERNAME", "admin")
+	useScopedUser(t, "kid", "Correct-Horse-42", "/Movies/Kids")
+
+	share := t.TempDir()
+	for name, content := range map[string]string{
+		"Movies/Kids/Cars.mkv":   "cars",
+		"Movies/Heat.mkv":        "heat",
+		"Shows/Chernobyl/E1.mkv": "chernobyl",
+	} {
+		file := filepath.Join(share, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler := auth.BasicAuthMiddleware(NewWebDAVHandler(share))
+
+	request := func(method, target string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/", nil)
+		// Set the raw path, so httptest does not clean ".." away
+		r.URL.Path = target
+		r.SetBasicAuth("kid", "Correct-Horse-42")
+		if method == "PROPFIND" {
+			r.Header.Set("Depth", "1")
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := request(http.MethodGet, "/Cars.mkv"); w.Code != http.StatusOK || w.Body.String() != "cars" {
+		t.Fatalf("GET inside the root = %d %q, want the file", w.Code, w.Body)
+	}
+	for _, target := range []string{"/Heat.mkv", "/../Heat.mkv", "/../../Shows/Chernobyl/E1.mkv", "/Movies/Heat.mkv"} {
+		w := request(http.MethodGet, target)
+		if w.Code == http.StatusOK {
+			body, _ := io.ReadAll(w.Body)
+			t.Errorf("GET %s outside the root served %q", target, body)
+		}
+	}
+
+	for _, target := range []string{"/", "/../", "/../../"} {
+		w := request("PROPFIND", target)
+		if w.Code != http.StatusMultiStatus {
+			t.Fatalf("PROPFIND %s = %d", target, w.Code)
+		}
+		listing := w.Body.String()
+		if !strings.Contains(listing, "Cars.mkv") {
+			t.Errorf("PROPFIND %s does not list the root:\n%s", target, listing)
+		}
+		if strings.Contains(listing, "Heat.mkv") || strings.Contains(listing, "Shows") {
+			t.Errorf("PROPFIND %s lists files outside the root:\n%s", target, listing)
+		}
+	}
+}
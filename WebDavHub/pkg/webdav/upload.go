@@ -0,0 +1,154 @@
+package webdav
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+// uploadTempPrefix marks the hidden sibling a PUT is streamed into before it
+// replaces the target
+const uploadTempPrefix = ".cinesync-upload-"
+
+// uploadIdleTimeout is how long a PUT may go without receiving body data.
+// The deadline moves with every read, so a large upload may take as long as
+// it keeps making progress.
+const uploadIdleTimeout = 60 * time.Second
+
+// writeMethods change the share and are refused in read-only mode
+var writeMethods = map[string]bool{
+	http.MethodPut:    true,
+	http.MethodDelete: true,
+	"MKCOL":           true,
+	"COPY":            true,
+	"MOVE":            true,
+	"PROPPATCH":       true,
+	"LOCK":            true,
+	"UNLOCK":          true,
+}
+
+// upload tracks the request body of a PUT. webdav.Handler closes the target
+// file whether or not the body arrived in full, so the file needs this to tell
+// a finished upload from an aborted one.
+type upload struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (u *upload) fail(err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.err == nil {
+		u.err = err
+	}
+}
+
+func (u *upload) failed() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.err
+}
+
+type uploadKey struct{}
+
+// trackUpload records body read errors of a PUT in its context and extends
+// the read deadline of the connection while the body arrives
+func trackUpload(w http.ResponseWriter, r *http.Request) *http.Request {
+	u := &upload{}
+	r = r.WithContext(context.WithValue(r.Context(), uploadKey{}, u))
+	r.Body = &uploadBody{ReadCloser: r.Body, upload: u, controller: http.NewResponseController(w)}
+	return r
+}
+
+// uploadBody reports a client that disconnects or sends less than its
+// Content-Length, which net/http surfaces as a read error
+type uploadBody struct {
+	io.ReadCloser
+	upload     *upload
+	controller *http.ResponseController
+}
+
+func (b *uploadBody) Read(p []byte) (int, error) {
+	b.controller.SetReadDeadline(time.Now().Add(uploadIdleTimeout))
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		b.upload.fail(err)
+	}
+	return n, err
+}
+
+// atomicFileSystem streams uploads into a temporary file next to the target
+// and renames it into place once the whole body has been written, so an
+// aborted PUT never leaves a truncated target behind
+type atomicFileSystem struct {
+	webdav.FileSystem
+}
+
+func (fs *atomicFileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	u, ok := ctx.Value(uploadKey{}).(*upload)
+	if !ok || flag&os.O_CREATE == 0 || flag&os.O_TRUNC == 0 {
+		return fs.FileSystem.OpenFile(ctx, name, flag, perm)
+	}
+
+	// Fail like the plain filesystem would when the target is a directory or
+	// its parent is missing, before any temporary file is created
+	if info, err := fs.FileSystem.Stat(ctx, name); err == nil && info.IsDir() {
+		return nil, os.ErrExist
+	}
+	if _, err := fs.FileSystem.Stat(ctx, path.Dir(name)); err != nil {
+		return nil, err
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	tempName := path.Join(path.Dir(name), uploadTempPrefix+hex.EncodeToString(suffix)+"-"+path.Base(name))
+	file, err := fs.FileSystem.OpenFile(ctx, tempName, flag|os.O_EXCL, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &atomicFile{File: file, fs: fs.FileSystem, ctx: ctx, upload: u, tempName: tempName, name: name}, nil
+}
+
+// atomicFile is the temporary file of an upload in progress
+type atomicFile struct {
+	webdav.File
+	fs       webdav.FileSystem
+	ctx      context.Context
+	upload   *upload
+	tempName string
+	name     string
+}
+
+func (f *atomicFile) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	if err != nil {
+		f.upload.fail(err)
+	}
+	return n, err
+}
+
+// Close moves the upload into place, or discards it if the body or a write
+// failed
+func (f *atomicFile) Close() error {
+	err := f.File.Close()
+	if err == nil {
+		err = f.upload.failed()
+	}
+	if err == nil {
+		err = f.fs.Rename(f.ctx, f.tempName, f.name)
+	}
+	if err != nil {
+		f.fs.RemoveAll(f.ctx, f.tempName)
+	}
+	return err
+}
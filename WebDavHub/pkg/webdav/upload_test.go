@@ -0,0 +1,48 @@
+package webdav
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlowUploadOutlastsTheServerReadTimeout(t *testing.T) {
+	t.Setenv("WEBDAV_VIRTUAL_LAYOUT", "false")
+	t.Setenv("WEBDAV_READ_ONLY", "false")
+	dir := t.TempDir()
+	server := httptest.NewUnstartedServer(NewWebDAVHandler(dir))
+	server.Config.ReadTimeout = 200 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	body, writer := io.Pipe()
+	go func() {
+		for i := 0; i < 5; i++ {
+			time.Sleep(100 * time.Millisecond)
+			writer.Write([]byte("chunk"))
+		}
+		writer.Close()
+	}()
+	req, err := http.NewRequest(http.MethodPut, server.URL+"/Movie.mkv", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.ContentLength = 25
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT answered %d, want 201", resp.StatusCode)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "Movie.mkv")); string(data) != strings.Repeat("chunk", 5) {
+		t.Fatalf("uploaded file holds %q", data)
+	}
+}
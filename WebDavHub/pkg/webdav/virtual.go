@@ -0,0 +1,402 @@
+package webdav
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cinesync/pkg/db"
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+	"cinesync/pkg/naming"
+	"golang.org/x/net/webdav"
+)
+
+const (
+	defaultMovieLayout = "Movies/{year}/{title} ({year})"
+	defaultShowLayout  = "Shows/{title} ({year})/Season {season:00}"
+	virtualTreeTTL     = 60 * time.Second
+	// virtualTreeRetry is how soon a failed load is retried
+	virtualTreeRetry = 5 * time.Second
+)
+
+// virtualNode is a directory or file in the virtual WebDAV tree. Files carry
+// the path of the underlying file they resolve to.
+type virtualNode struct {
+	name     string
+	target   string
+	modTime  time.Time
+	children map[string]*virtualNode
+}
+
+func (n *virtualNode) isDir() bool {
+	return n.children != nil
+}
+
+// VirtualFileSystem is a read-only webdav.FileSystem that presents processed
+// media in a layout computed from database metadata rather than the on-disk
+// structure. Directory names are rendered from naming templates.
+type VirtualFileSystem struct {
+	rootDir     string
+	movieLayout string
+	showLayout  string
+
+	mu      sync.Mutex
+	root    *virtualNode
+	builtAt time.Time
+	// retryAt holds off reloading after a failed load
+	retryAt time.Time
+	loader  func() ([]VirtualEntry, error)
+}
+
+// VirtualEntry is a single processed file used to build the virtual tree
+type VirtualEntry struct {
+	DestinationPath string
+	Title           string
+	Year            string
+	MediaType       string
+	Season          string
+	Episode         string
+	// Collection is the TMDB collection a movie belongs to, if any
+	Collection string
+}
+
+// NewVirtualFileSystem creates a virtual layout backed by the MediaHub database
+func NewVirtualFileSystem(rootDir string) *VirtualFileSystem {
+	return &VirtualFileSystem{
+		rootDir:     rootDir,
+		movieLayout: env.GetString("WEBDAV_MOVIE_LAYOUT", defaultMovieLayout),
+		showLayout:  env.GetString("WEBDAV_SHOW_LAYOUT", defaultShowLayout),
+		loader:      loadVirtualEntries,
+	}
+}
+
+// loadVirtualEntries reads processed files with a destination from the MediaHub database
+func loadVirtualEntries() ([]VirtualEntry, error) {
+	mediaHubDB, err := db.GetDatabaseConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mediaHubDB.Query(`
+		SELECT
+			destination_path,
+			COALESCE(proper_name, ''),
+			COALESCE(year, ''),
+			COALESCE(media_type, ''),
+			COALESCE(season_number, ''),
+			COALESCE(episode_number, ''),
+			COALESCE(tmdb_id, '')
+		FROM processed_files
+		WHERE destination_path IS NOT NULL AND destination_path != ''`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections, err := db.GetMovieCollections()
+	if err != nil {
+		logger.Warn("[WebDAV] Failed to load movie collections: %v", err)
+	}
+
+	var entries []VirtualEntry
+	for rows.Next() {
+		var e VirtualEntry
+		var tmdbID string
+		if err := rows.Scan(&e.DestinationPath, &e.Title, &e.Year, &e.MediaType, &e.Season, &e.Episode, &tmdbID); err != nil {
+			continue
+		}
+		if id, err := strconv.Atoi(strings.TrimSpace(tmdbID)); err == nil && strings.EqualFold(e.MediaType, "movie") {
+			e.Collection = collections[id].Name
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// tree returns the current virtual tree, rebuilding it when it has expired.
+// A failed load is not cached: the previous tree, or an empty one, is served
+// until the load is retried virtualTreeRetry later.
+func (fs *VirtualFileSystem) tree() *virtualNode {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.root != nil && time.Since(fs.builtAt) < virtualTreeTTL {
+		return fs.root
+	}
+	if time.Now().Before(fs.retryAt) {
+		return fs.rootOrEmpty()
+	}
+
+	entries, err := fs.loader()
+	if err != nil {
+		logger.Warn("[WebDAV] Failed to load virtual layout: %v", err)
+		fs.retryAt = time.Now().Add(virtualTreeRetry)
+		return fs.rootOrEmpty()
+	}
+
+	fs.root = fs.build(entries)
+	fs.builtAt = time.Now()
+	fs.retryAt = time.Time{}
+	return fs.root
+}
+
+// rootOrEmpty returns the last tree built, or an empty one before the first
+func (fs *VirtualFileSystem) rootOrEmpty() *virtualNode {
+	if fs.root != nil {
+		return fs.root
+	}
+	return fs.build(nil)
+}
+
+// build creates the virtual tree from processed file entries. Files that
+// land in the same folder under the same name are told apart by a number, in
+// the order of their paths so the names stay the same between rebuilds.
+func (fs *VirtualFileSystem) build(entries []VirtualEntry) *virtualNode {
+	now := time.Now()
+	root := &virtualNode{name: "/", modTime: now, children: map[string]*virtualNode{}}
+
+	entries = append([]VirtualEntry(nil), entries...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].DestinationPath < entries[j].DestinationPath
+	})
+
+	for _, e := range entries {
+		target := e.DestinationPath
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(fs.rootDir, target)
+		}
+
+		layout := fs.movieLayout
+		if !strings.EqualFold(e.MediaType, "movie") {
+			layout = fs.showLayout
+		}
+
+		dir := naming.RenderPath(layout, map[string]string{
+			"title":      e.Title,
+			"year":       e.Year,
+			"media_type": e.MediaType,
+			"season":     e.Season,
+			"episode":    e.Episode,
+			"collection": e.Collection,
+		})
+
+		node := root
+		if dir != "" {
+			for _, segment := range strings.Split(dir, "/") {
+				node = dirChild(node, segment, now)
+			}
+		}
+
+		name := filepath.Base(target)
+		if existing, ok := node.children[name]; ok {
+			if existing.target == target {
+				continue
+			}
+			name = uniqueChildName(node, name)
+		}
+		node.children[name] = &virtualNode{name: name, target: target, modTime: now}
+	}
+
+	return root
+}
+
+// dirChild returns the folder named segment in dir, creating it when missing.
+// When a file already has the name the folder is numbered instead.
+func dirChild(dir *virtualNode, segment string, modTime time.Time) *virtualNode {
+	name := segment
+	for n := 2; ; n++ {
+		child, ok := dir.children[name]
+		if !ok {
+			child = &virtualNode{name: name, modTime: modTime, children: map[string]*virtualNode{}}
+			dir.children[name] = child
+			return child
+		}
+		if child.isDir() {
+			return child
+		}
+		name = numberedName(segment, "", n)
+	}
+}
+
+// uniqueChildName returns name with the first number that no child of dir
+// uses yet, e.g. "Movie (2).mkv" for "Movie.mkv"
+func uniqueChildName(dir *virtualNode, name string) string {
+	ext := path.Ext(name)
+	for n := 2; ; n++ {
+		candidate := numberedName(strings.TrimSuffix(name, ext), ext, n)
+		if _, taken := dir.children[candidate]; !taken {
+			return candidate
+		}
+	}
+}
+
+// numberedName returns "stem (n)ext"
+func numberedName(stem, ext string, n int) string {
+	return stem + " (" + strconv.Itoa(n) + ")" + ext
+}
+
+// lookup walks the virtual tree for a slash-separated WebDAV path
+func (fs *VirtualFileSystem) lookup(name string) (*virtualNode, error) {
+	node := fs.tree()
+	for _, segment := range strings.Split(strings.Trim(path.Clean("/"+name), "/"), "/") {
+		if segment == "" {
+			continue
+		}
+		if !node.isDir() {
+			return nil, os.ErrNotExist
+		}
+		child, ok := node.children[segment]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		node = child
+	}
+	return node, nil
+}
+
+// Resolve returns the underlying file path for a virtual path
+func (fs *VirtualFileSystem) Resolve(name string) (string, error) {
+	node, err := fs.lookup(name)
+	if err != nil {
+		return "", err
+	}
+	if node.isDir() {
+		return "", os.ErrInvalid
+	}
+	return node.target, nil
+}
+
+func (fs *VirtualFileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return os.ErrPermission
+}
+
+func (fs *VirtualFileSystem) RemoveAll(ctx context.Context, name string) error {
+	return os.ErrPermission
+}
+
+func (fs *VirtualFileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	return os.ErrPermission
+}
+
+func (fs *VirtualFileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, os.ErrPermission
+	}
+
+	node, err := fs.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if node.isDir() {
+		return &virtualDir{node: node}, nil
+	}
+
+	f, err := os.Open(node.target)
+	if err != nil {
+		return nil, err
+	}
+	return &virtualFile{File: f, name: node.name}, nil
+}
+
+func (fs *VirtualFileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	node, err := fs.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return node.stat()
+}
+
+func (n *virtualNode) stat() (os.FileInfo, error) {
+	if n.isDir() {
+		return &virtualDirInfo{node: n}, nil
+	}
+	info, err := os.Stat(n.target)
+	if err != nil {
+		return nil, err
+	}
+	return &renamedFileInfo{FileInfo: info, name: n.name}, nil
+}
+
+// virtualDir is an open directory in the virtual tree
+type virtualDir struct {
+	node   *virtualNode
+	offset int
+}
+
+func (d *virtualDir) Close() error                                 { return nil }
+func (d *virtualDir) Read(p []byte) (int, error)                   { return 0, os.ErrInvalid }
+func (d *virtualDir) Seek(offset int64, whence int) (int64, error) { return 0, os.ErrInvalid }
+func (d *virtualDir) Write(p []byte) (int, error)                  { return 0, os.ErrPermission }
+func (d *virtualDir) Stat() (os.FileInfo, error)                   { return &virtualDirInfo{node: d.node}, nil }
+
+func (d *virtualDir) Readdir(count int) ([]os.FileInfo, error) {
+	names := make([]string, 0, len(d.node.children))
+	for name := range d.node.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var infos []os.FileInfo
+	for _, name := range names[min(d.offset, len(names)):] {
+		d.offset++
+		info, err := d.node.children[name].stat()
+		if err != nil {
+			// Skip entries whose underlying file has gone missing
+			continue
+		}
+		infos = append(infos, info)
+		if count > 0 && len(infos) >= count {
+			break
+		}
+	}
+
+	if count > 0 && len(infos) == 0 {
+		return nil, io.EOF
+	}
+	return infos, nil
+}
+
+// virtualFile is an underlying file exposed under its virtual name
+type virtualFile struct {
+	*os.File
+	name string
+}
+
+func (f *virtualFile) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+func (f *virtualFile) Stat() (os.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &renamedFileInfo{FileInfo: info, name: f.name}, nil
+}
+
+type renamedFileInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (i *renamedFileInfo) Name() string { return i.name }
+
+type virtualDirInfo struct {
+	node *virtualNode
+}
+
+func (i *virtualDirInfo) Name() string       { return i.node.name }
+func (i *virtualDirInfo) Size() int64        { return 0 }
+func (i *virtualDirInfo) Mode() os.FileMode  { return os.ModeDir | 0555 }
+func (i *virtualDirInfo) ModTime() time.Time { return i.node.modTime }
+func (i *virtualDirInfo) IsDir() bool        { return true }
+func (i *virtualDirInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,91 @@
+package webdav
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestVirtualFileSystem(loader func() ([]VirtualEntry, error)) *VirtualFileSystem {
+	return &VirtualFileSystem{
+		rootDir:     "/media",
+		movieLayout: defaultMovieLayout,
+		showLayout:  defaultShowLayout,
+		loader:      loader,
+	}
+}
+
+func TestVirtualTreeNumbersCollidingNames(t *testing.T) {
+	entries := []VirtualEntry{
+		{DestinationPath: "/media/b/Heat.mkv", Title: "Heat", Year: "1995", MediaType: "movie"},
+		{DestinationPath: "/media/a/Heat.mkv", Title: "Heat", Year: "1995", MediaType: "movie"},
+		{DestinationPath: "/media/a/Heat.mkv", Title: "Heat", Year: "1995", MediaType: "movie"},
+	}
+	fs := newTestVirtualFileSystem(func() ([]VirtualEntry, error) { return entries, nil })
+
+	for name, want := range map[string]string{
+		"/Movies/1995/Heat (1995)/Heat.mkv":     "/media/a/Heat.mkv",
+		"/Movies/1995/Heat (1995)/Heat (2).mkv": "/media/b/Heat.mkv",
+	} {
+		got, err := fs.Resolve(name)
+		if err != nil || got != want {
+			t.Errorf("Resolve(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := fs.Resolve("/Movies/1995/Heat (1995)/Heat (3).mkv"); err == nil {
+		t.Error("a duplicate row for the same file was listed twice")
+	}
+}
+
+func TestVirtualTreeDoesNotCacheLoadErrors(t *testing.T) {
+	fail := true
+	fs := newTestVirtualFileSystem(func() ([]VirtualEntry, error) {
+		if fail {
+			return nil, errors.New("database is locked")
+		}
+		return []VirtualEntry{{DestinationPath: "/media/Heat.mkv", Title: "Heat", Year: "1995", MediaType: "movie"}}, nil
+	})
+
+	if _, err := fs.Resolve("/Movies/1995/Heat (1995)/Heat.mkv"); err == nil {
+		t.Fatal("file resolved while the layout failed to load")
+	}
+	fail = false
+	if _, err := fs.Resolve("/Movies/1995/Heat (1995)/Heat.mkv"); err == nil {
+		t.Fatal("failed load was retried before the retry delay")
+	}
+
+	fs.retryAt = time.Now()
+	if _, err := fs.Resolve("/Movies/1995/Heat (1995)/Heat.mkv"); err != nil {
+		t.Fatalf("failed load was cached after the retry delay: %v", err)
+	}
+}
+
+func TestValidatePrefix(t *testing.T) {
+	for prefix, valid := range map[string]bool{
+		"":              true,
+		"/webdav":       true,
+		"/media/dav/":   true,
+		"/api":          false,
+		"/API/dav":      false,
+		"/signalr":      false,
+		"/MediaCover":   false,
+		"/dav/../api":   false,
+		"/dav/{name}":   false,
+		"/dav with gap": false,
+	} {
+		if err := ValidatePrefix(prefix); (err == nil) != valid {
+			t.Errorf("ValidatePrefix(%q) = %v, want valid %v", prefix, err, valid)
+		}
+	}
+}
+
+func TestMountPrefixFallsBackOnReservedPrefix(t *testing.T) {
+	t.Setenv("WEBDAV_PREFIX", "/api")
+	if got := MountPrefix(); got != "/webdav" {
+		t.Fatalf("MountPrefix() = %q, want /webdav", got)
+	}
+	t.Setenv("WEBDAV_PREFIX", "/dav/")
+	if got := MountPrefix(); got != "/dav" {
+		t.Fatalf("MountPrefix() = %q, want /dav", got)
+	}
+}
@@ -1,24 +1,98 @@
 package webdav
 
 import (
+	"fmt"
 	"net/http"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
 
+	"cinesync/pkg/db"
+	"cinesync/pkg/env"
 	"cinesync/pkg/logger"
+	"cinesync/pkg/mimetype"
 	"golang.org/x/net/webdav"
-
 )
 
+// reservedPrefixes are the first path segments the server routes itself
+var reservedPrefixes = []string{"api", "signalr", "mediacover"}
+
+// prefixSegment matches a path segment that is safe in a mux pattern
+var prefixSegment = regexp.MustCompile(`^[A-Za-z0-9._~-]+$`)
+
+// ValidatePrefix reports why prefix cannot be used as WEBDAV_PREFIX. The
+// prefix may not take over a route of the server, such as /api, and may
+// only use plain path segments.
+func ValidatePrefix(prefix string) error {
+	prefix = strings.Trim(prefix, "/ ")
+	if prefix == "" {
+		return nil
+	}
+	segments := strings.Split(prefix, "/")
+	for _, reserved := range reservedPrefixes {
+		if strings.EqualFold(segments[0], reserved) {
+			return fmt.Errorf("WebDAV prefix /%s is used by the server", segments[0])
+		}
+	}
+	for _, segment := range segments {
+		if segment == "." || segment == ".." || !prefixSegment.MatchString(segment) {
+			return fmt.Errorf("WebDAV prefix has an invalid segment %q", segment)
+		}
+	}
+	return nil
+}
+
+// MountPrefix returns the URL prefix the WebDAV tree is served under.
+// It is configured with WEBDAV_PREFIX and defaults to /webdav, which is
+// also used when the configured prefix is not valid.
+func MountPrefix() string {
+	configured := env.GetString("WEBDAV_PREFIX", "/webdav")
+	if err := ValidatePrefix(configured); err != nil {
+		logger.Warn("Ignoring WEBDAV_PREFIX %q: %v", configured, err)
+		return "/webdav"
+	}
+	prefix := strings.Trim(configured, "/ ")
+	if prefix == "" {
+		return "/webdav"
+	}
+	return "/" + prefix
+}
+
 // WebDAVHandler handles WebDAV requests
 type WebDAVHandler struct {
 	handler *webdav.Handler
+	// resolve maps a WebDAV path to the file on disk for access statistics
+	resolve func(name string) string
+	// readOnly refuses every method that changes the share
+	readOnly bool
 }
 
-// NewWebDAVHandler creates a new WebDAV handler
+// NewWebDAVHandler creates a new WebDAV handler. When WEBDAV_VIRTUAL_LAYOUT is
+// enabled the tree is computed from database metadata instead of the directory.
+// Users with a WebDAV root in the user store only see that subtree. Uploads
+// are streamed to a temporary file and renamed over the target when complete.
 func NewWebDAVHandler(dir string) *WebDAVHandler {
+	var fs webdav.FileSystem = webdav.Dir(dir)
+	resolve := func(name string) string {
+		return filepath.Join(dir, filepath.FromSlash(path.Clean("/"+name)))
+	}
+	if env.IsBool("WEBDAV_VIRTUAL_LAYOUT", false) {
+		logger.Info("[WebDAV] Serving virtual folder layout")
+		virtualFS := NewVirtualFileSystem(dir)
+		fs = virtualFS
+		resolve = func(name string) string {
+			target, _ := virtualFS.Resolve(name)
+			return target
+		}
+	}
+
 	return &WebDAVHandler{
+		resolve:  resolve,
+		readOnly: env.IsBool("WEBDAV_READ_ONLY", false),
 		handler: &webdav.Handler{
 			Prefix:     "",
-			FileSystem: webdav.Dir(dir),
+			FileSystem: &scopedFileSystem{fs: &atomicFileSystem{FileSystem: fs}},
 			LockSystem: webdav.NewMemLS(),
 			Logger: func(r *http.Request, err error) {
 				if err != nil {
@@ -33,5 +107,71 @@ func NewWebDAVHandler(dir string) *WebDAVHandler {
 
 // ServeHTTP handles HTTP requests for WebDAV
 func (h *WebDAVHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	h.handler.ServeHTTP(w, r)
+	// Refusals happen before the body is read. net/http only answers
+	// "Expect: 100-continue" once the body is read, so a client that waits
+	// for it never sends an upload that would be rejected.
+	if h.readOnly && writeMethods[r.Method] {
+		logger.Debug("[WebDAV] Refused %s %s, the share is read-only", r.Method, r.URL.Path)
+		http.Error(w, "WebDAV share is read-only", http.StatusForbidden)
+		return
+	}
+	if r.Method == http.MethodPut {
+		defer lockPut(scopedName(r.Context(), r.URL.Path))()
+
+		if hasPutPreconditions(r) {
+			if status, err := checkPutPreconditions(r.Context(), h.handler.FileSystem, r); status != 0 {
+				if err != nil {
+					logger.Error("[WebDAV] Checking preconditions of PUT %s: %v", r.URL.Path, err)
+				}
+				http.Error(w, http.StatusText(status), status)
+				return
+			}
+		}
+		r = trackUpload(w, r)
+	}
+
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		setContentType(w, r)
+	}
+
+	if r.Method != http.MethodGet || !db.AccessStatsEnabled() {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	counter := &countingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+	h.handler.ServeHTTP(counter, r)
+	if counter.status < http.StatusMultipleChoices && counter.written > 0 {
+		db.RecordFileAccess(h.resolve(scopedName(r.Context(), r.URL.Path)), counter.written)
+	}
+}
+
+// setContentType names the media type of a file before webdav.Handler serves
+// it. http.ServeContent keeps a Content-Type that is already set and
+// otherwise falls back to the system MIME table and sniffing, which labels
+// most video as application/octet-stream or misreads it.
+func setContentType(w http.ResponseWriter, r *http.Request) {
+	if contentType := mimetype.TypeByExtension(r.URL.Path); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	} else if !mimetype.SniffEnabled() {
+		w.Header().Set("Content-Type", mimetype.Fallback)
+	}
+}
+
+// countingResponseWriter records the status and number of body bytes written
+type countingResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+func (c *countingResponseWriter) WriteHeader(status int) {
+	c.status = status
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *countingResponseWriter) Write(p []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(p)
+	c.written += int64(n)
+	return n, err
 }
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"cinesync/pkg/env"
+	"cinesync/pkg/logger"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// tlsFiles returns the certificate and key for a listener. Listener specific
+// variables win over the shared CINESYNC_TLS_CERT and CINESYNC_TLS_KEY.
+func tlsFiles(certKey, keyKey string) (string, string) {
+	certFile := env.GetString(certKey, "")
+	keyFile := env.GetString(keyKey, "")
+	if certFile == "" || keyFile == "" {
+		certFile = env.GetString("CINESYNC_TLS_CERT", "")
+		keyFile = env.GetString("CINESYNC_TLS_KEY", "")
+	}
+	return certFile, keyFile
+}
+
+// loadTLSConfig loads the certificate pair up front so a bad path or key
+// fails at startup instead of on the first handshake
+func loadTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// acmeManager returns the certificate manager for CINESYNC_ACME_DOMAINS, nil
+// when the variable is unset. Every listener shares one manager, so they share
+// its certificate cache in CINESYNC_ACME_CACHE_DIR.
+var acmeManager = sync.OnceValue(newACMEManager)
+
+// newACMEManager creates the certificate manager described by the environment
+func newACMEManager() *autocert.Manager {
+	var domains []string
+	for _, domain := range strings.Split(env.GetString("CINESYNC_ACME_DOMAINS", ""), ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	if len(domains) == 0 {
+		return nil
+	}
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(domains...),
+		Cache:      autocert.DirCache(env.GetString("CINESYNC_ACME_CACHE_DIR", filepath.Join("..", "db", "acme"))),
+		Email:      env.GetString("CINESYNC_ACME_EMAIL", ""),
+	}
+}
+
+// listenerTLSConfig returns the TLS configuration of a listener: its
+// certificate files when set, else certificates obtained through ACME, else
+// nil for plain HTTP
+func listenerTLSConfig(certKey, keyKey string) (*tls.Config, error) {
+	if certFile, keyFile := tlsFiles(certKey, keyKey); certFile != "" && keyFile != "" {
+		return loadTLSConfig(certFile, keyFile)
+	}
+	if manager := acmeManager(); manager != nil {
+		tlsConfig := manager.TLSConfig()
+		tlsConfig.MinVersion = tls.VersionTLS12
+		return tlsConfig, nil
+	}
+	return nil, nil
+}
+
+// tlsConfigured reports whether a listener is served over TLS
+func tlsConfigured(certKey, keyKey string) bool {
+	certFile, keyFile := tlsFiles(certKey, keyKey)
+	return (certFile != "" && keyFile != "") || acmeManager() != nil
+}
+
+// listenAndServe serves over TLS when a certificate and key or ACME domains
+// are configured
+func listenAndServe(server *http.Server, certKey, keyKey string) error {
+	tlsConfig, err := listenerTLSConfig(certKey, keyKey)
+	if err != nil {
+		return err
+	}
+	if tlsConfig == nil {
+		return server.ListenAndServe()
+	}
+	server.TLSConfig = tlsConfig
+
+	logger.Info("TLS enabled on %s", server.Addr)
+	return server.ListenAndServeTLS("", "")
+}
+
+// startHTTPSRedirect starts a plain HTTP listener on CINESYNC_HTTPS_REDIRECT_PORT
+// that permanently redirects every request to the TLS listener at tlsAddr. It
+// also answers ACME HTTP-01 challenges when certificates come from ACME. There
+// is one such listener, so it is started once, for the API listener.
+func startHTTPSRedirect(tlsAddr string) {
+	redirectPort := env.GetInt("CINESYNC_HTTPS_REDIRECT_PORT", 0)
+	if redirectPort <= 0 {
+		return
+	}
+
+	host, tlsPort, err := net.SplitHostPort(tlsAddr)
+	if err != nil {
+		logger.Warn("Invalid TLS address %s, HTTPS redirect disabled: %v", tlsAddr, err)
+		return
+	}
+
+	handler := httpsRedirectHandler(tlsPort)
+	if manager := acmeManager(); manager != nil {
+		handler = manager.HTTPHandler(handler)
+	}
+	redirectServer := &http.Server{
+		Addr:         net.JoinHostPort(host, fmt.Sprint(redirectPort)),
+		Handler:      handler,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	logger.Info("Redirecting HTTP on %s to HTTPS", redirectServer.Addr)
+	go func() {
+		if err := redirectServer.ListenAndServe(); err != nil {
+			logger.Error("HTTPS redirect listener stopped: %v", err)
+		}
+	}()
+}
+
+// httpsRedirectHandler answers 308 Permanent Redirect to the same URL on the
+// TLS port, so the method and body are kept
+func httpsRedirectHandler(tlsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		if tlsPort != "443" {
+			host = host + ":" + tlsPort
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCertificate writes a self-signed certificate pair and returns the paths
+func writeTestCertificate(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "cinesync.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certFile, keyFile
+}
+
+func TestLoadTLSConfig(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t)
+	config, err := loadTLSConfig(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Certificates) != 1 {
+		t.Fatalf("loaded %d certificates, want 1", len(config.Certificates))
+	}
+	if _, err := loadTLSConfig(certFile, certFile); err == nil {
+		t.Fatal("a certificate without its key was accepted")
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	httpsRedirectHandler("8443").ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "http://cinesync.test:8080/api/files?path=/", nil))
+
+	if recorder.Code != http.StatusPermanentRedirect {
+		t.Fatalf("redirect answered %d, want 308", recorder.Code)
+	}
+	if location := recorder.Header().Get("Location"); location != "https://cinesync.test:8443/api/files?path=/" {
+		t.Fatalf("Location = %q", location)
+	}
+}
+
+func TestACMEManager(t *testing.T) {
+	t.Setenv("CINESYNC_ACME_DOMAINS", "")
+	if newACMEManager() != nil {
+		t.Fatal("ACME enabled without domains")
+	}
+
+	t.Setenv("CINESYNC_ACME_DOMAINS", "cinesync.example.com, webdav.example.com")
+	t.Setenv("CINESYNC_ACME_CACHE_DIR", t.TempDir())
+	manager := newACMEManager()
+	if manager == nil {
+		t.Fatal("ACME disabled with domains set")
+	}
+	for host, allowed := range map[string]bool{
+		"cinesync.example.com": true,
+		"webdav.example.com":   true,
+		"evil.example.com":     false,
+	} {
+		if err := manager.HostPolicy(context.Background(), host); (err == nil) != allowed {
+			t.Errorf("host policy for %s = %v, want allowed %v", host, err, allowed)
+		}
+	}
+}